@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-instance/cmd/startGreenplumContainer/startContainerUtils/cluster"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	"github.com/pkg/errors"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = ctrllog.Log.WithName("cancelQuery")
+
+// cancelQuery lists the queries running on the active master, or cancels the query of one backend, e.g.
+//
+//	kubectl exec master-0 -- /tools/cancelQuery
+//	kubectl exec master-0 -- /tools/cancelQuery --pid 12345
+//	kubectl exec master-0 -- /tools/cancelQuery --pid 12345 --terminate
+func main() {
+	ctrllog.SetLogger(gplog.ForProd(false))
+
+	var pid = flag.Int("pid", 0, "PID of the backend whose query to cancel; lists the active queries when not set")
+	var terminate = flag.Bool("terminate", false, "terminate the backend with pg_terminate_backend instead of cancelling its query")
+	flag.Parse()
+
+	if *terminate && *pid == 0 {
+		log.Error(errors.New("--terminate requires --pid"), "invalid arguments")
+		os.Exit(2)
+	}
+
+	queries, err := ListActiveQueries(exec.Command)
+	if err != nil {
+		log.Error(err, "error listing active queries")
+		os.Exit(1)
+	}
+
+	if *pid == 0 {
+		if err := PrintActiveQueries(os.Stdout, queries); err != nil {
+			log.Error(err, "error printing active queries")
+			os.Exit(1)
+		}
+		return
+	}
+
+	target, err := SelectTarget(queries, *pid)
+	if err != nil {
+		log.Error(err, "error selecting the backend")
+		os.Exit(1)
+	}
+	if err := SignalBackend(exec.Command, target, *terminate); err != nil {
+		log.Error(err, "error signalling the backend")
+		os.Exit(1)
+	}
+	if *terminate {
+		log.Info("terminated backend", "pid", target.PID, "user", target.User, "query", target.Query)
+	} else {
+		log.Info("cancelled query", "pid", target.PID, "user", target.User, "query", target.Query)
+	}
+}
+
+type ActiveQuery struct {
+	PID      int
+	User     string
+	Database string
+	Duration string
+	Query    string
+}
+
+// Queries are collapsed onto one line, and come last so that a | in them does not split the row
+const activeQueriesQuery = `SELECT pid, usename, datname, date_trunc('second', now() - query_start), regexp_replace(query, '\s+', ' ', 'g') ` +
+	`FROM pg_stat_activity WHERE state = 'active' AND pid <> pg_backend_pid() ORDER BY query_start`
+
+// ListActiveQueries returns the queries running on the master, longest running first, leaving out its own
+func ListActiveQueries(command commandable.CommandFn) ([]ActiveQuery, error) {
+	output, err := psql(command, activeQueriesQuery)
+	if err != nil {
+		return nil, err
+	}
+	var queries []ActiveQuery
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", 5)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected active query row: %q", line)
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected active query row: %q", line)
+		}
+		queries = append(queries, ActiveQuery{
+			PID:      pid,
+			User:     fields[1],
+			Database: fields[2],
+			Duration: fields[3],
+			Query:    fields[4],
+		})
+	}
+	return queries, nil
+}
+
+func PrintActiveQueries(w io.Writer, queries []ActiveQuery) error {
+	if len(queries) == 0 {
+		_, err := fmt.Fprintln(w, "no active queries")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tUSER\tDATABASE\tDURATION\tQUERY")
+	for _, query := range queries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", query.PID, query.User, query.Database, query.Duration, query.Query)
+	}
+	return tw.Flush()
+}
+
+// SelectTarget picks the active query of the backend with pid. Only backends running a query can be chosen,
+// so that a mistyped PID does not end an idle session or a Greenplum process.
+func SelectTarget(queries []ActiveQuery, pid int) (ActiveQuery, error) {
+	for _, query := range queries {
+		if query.PID == pid {
+			return query, nil
+		}
+	}
+	return ActiveQuery{}, fmt.Errorf("no active query with PID %d", pid)
+}
+
+// SignalBackend cancels the query of the target backend with pg_cancel_backend, or ends its session with
+// pg_terminate_backend when terminate is set
+func SignalBackend(command commandable.CommandFn, target ActiveQuery, terminate bool) error {
+	function := "pg_cancel_backend"
+	if terminate {
+		function = "pg_terminate_backend"
+	}
+	output, err := psql(command, fmt.Sprintf("SELECT %s(%d)", function, target.PID))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(output) != "t" {
+		return fmt.Errorf("%s(%d) did not signal the backend; its query may have finished", function, target.PID)
+	}
+	return nil
+}
+
+func psql(command commandable.CommandFn, query string) (string, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	greenplumCommand := cluster.NewGreenplumCommand(command)
+	cmd := greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", query)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrap(err, stderr.String())
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+const expectedActiveQueriesQuery = `SELECT pid, usename, datname, date_trunc('second', now() - query_start), regexp_replace(query, '\s+', ' ', 'g') ` +
+	`FROM pg_stat_activity WHERE state = 'active' AND pid <> pg_backend_pid() ORDER BY query_start`
+
+var _ = Describe("ListActiveQueries", func() {
+	var cmdFake *commandable.CommandFake
+	BeforeEach(func() {
+		cmdFake = commandable.NewFakeCommand()
+	})
+
+	It("parses the active queries", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", expectedActiveQueriesQuery).
+			PrintsOutput("12345|analyst|sales|00:42:07|SELECT * FROM orders o JOIN customers c ON o.id = c.id\n" +
+				"23456|etl_user|gpadmin|00:00:03|SELECT a || '|' || b FROM t\n")
+		queries, err := ListActiveQueries(cmdFake.Command)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queries).To(Equal([]ActiveQuery{
+			{PID: 12345, User: "analyst", Database: "sales", Duration: "00:42:07", Query: "SELECT * FROM orders o JOIN customers c ON o.id = c.id"},
+			{PID: 23456, User: "etl_user", Database: "gpadmin", Duration: "00:00:03", Query: "SELECT a || '|' || b FROM t"},
+		}))
+	})
+
+	It("returns no queries when nothing is running", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", expectedActiveQueriesQuery).
+			PrintsOutput("")
+		queries, err := ListActiveQueries(cmdFake.Command)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queries).To(BeEmpty())
+	})
+
+	It("returns an error when a row cannot be parsed", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", expectedActiveQueriesQuery).
+			PrintsOutput("not a pid|analyst|sales|00:00:01|SELECT 1\n")
+		_, err := ListActiveQueries(cmdFake.Command)
+		Expect(err).To(MatchError(`unexpected active query row: "not a pid|analyst|sales|00:00:01|SELECT 1"`))
+	})
+
+	It("returns an error when psql fails", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", expectedActiveQueriesQuery).
+			ReturnsStatus(2).PrintsError("psql: could not connect to server")
+		_, err := ListActiveQueries(cmdFake.Command)
+		Expect(err).To(MatchError("psql: could not connect to server: exit status 2"))
+	})
+})
+
+var _ = Describe("PrintActiveQueries", func() {
+	It("prints a table of the queries", func() {
+		out := &bytes.Buffer{}
+		Expect(PrintActiveQueries(out, []ActiveQuery{
+			{PID: 12345, User: "analyst", Database: "sales", Duration: "00:42:07", Query: "SELECT 1"},
+		})).To(Succeed())
+		Expect(out.String()).To(Equal("PID    USER     DATABASE  DURATION  QUERY\n" +
+			"12345  analyst  sales     00:42:07  SELECT 1\n"))
+	})
+
+	It("says when there are no queries", func() {
+		out := &bytes.Buffer{}
+		Expect(PrintActiveQueries(out, nil)).To(Succeed())
+		Expect(out.String()).To(Equal("no active queries\n"))
+	})
+})
+
+var _ = Describe("SelectTarget", func() {
+	queries := []ActiveQuery{
+		{PID: 12345, User: "analyst", Query: "SELECT 1"},
+		{PID: 23456, User: "etl_user", Query: "SELECT 2"},
+	}
+
+	It("selects the query of the backend with the PID", func() {
+		target, err := SelectTarget(queries, 23456)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target).To(Equal(queries[1]))
+	})
+
+	It("returns an error when no active query has the PID", func() {
+		_, err := SelectTarget(queries, 99999)
+		Expect(err).To(MatchError("no active query with PID 99999"))
+	})
+})
+
+var _ = Describe("SignalBackend", func() {
+	var (
+		cmdFake *commandable.CommandFake
+		target  ActiveQuery
+	)
+	BeforeEach(func() {
+		cmdFake = commandable.NewFakeCommand()
+		target = ActiveQuery{PID: 12345, User: "analyst", Query: "SELECT 1"}
+	})
+
+	It("cancels the query with pg_cancel_backend", func() {
+		calls := 0
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", "SELECT pg_cancel_backend(12345)").
+			PrintsOutput("t\n").CallCounter(&calls)
+		Expect(SignalBackend(cmdFake.Command, target, false)).To(Succeed())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("terminates the backend with pg_terminate_backend", func() {
+		calls := 0
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", "SELECT pg_terminate_backend(12345)").
+			PrintsOutput("t\n").CallCounter(&calls)
+		Expect(SignalBackend(cmdFake.Command, target, true)).To(Succeed())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("returns an error when the backend was not signalled", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", "SELECT pg_cancel_backend(12345)").
+			PrintsOutput("f\n")
+		Expect(SignalBackend(cmdFake.Command, target, false)).To(MatchError(
+			"pg_cancel_backend(12345) did not signal the backend; its query may have finished"))
+	})
+
+	It("returns an error when psql fails", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "postgres", "-tAc", "SELECT pg_terminate_backend(12345)").
+			ReturnsStatus(1).PrintsError("ERROR:  must be superuser")
+		Expect(SignalBackend(cmdFake.Command, target, true)).To(MatchError("ERROR:  must be superuser: exit status 1"))
+	})
+})
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-instance/cmd/startGreenplumContainer/startContainerUtils/cluster"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+// activityQuery lists the non-idle sessions on the master, oldest first. The query text comes last
+// and has its whitespace collapsed, so that each session is one line even if the text contains the separator.
+const activityQuery = `SELECT pid, usename, datname, state, waiting, COALESCE(waiting_reason, ''), ` +
+	`COALESCE(EXTRACT(EPOCH FROM now() - query_start)::bigint, 0), regexp_replace(query, '\s+', ' ', 'g') ` +
+	`FROM pg_stat_activity WHERE pid <> pg_backend_pid() AND state <> 'idle' ORDER BY query_start`
+
+type ActivityHandler struct {
+	Command commandable.CommandFn
+	Port    int
+}
+
+var _ http.Handler = &ActivityHandler{}
+
+// Session is one non-idle row of pg_stat_activity
+type Session struct {
+	PID             int    `json:"pid"`
+	User            string `json:"user"`
+	Database        string `json:"database"`
+	State           string `json:"state"`
+	Waiting         bool   `json:"waiting"`
+	WaitingReason   string `json:"waitingReason,omitempty"`
+	DurationSeconds int64  `json:"durationSeconds"`
+	Query           string `json:"query"`
+}
+
+// Activity summarizes the non-idle sessions on the cluster
+type Activity struct {
+	Active   int       `json:"active"`
+	Waiting  int       `json:"waiting"`
+	Sessions []Session `json:"sessions"`
+}
+
+func (h *ActivityHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	activity, err := h.collect()
+	if err != nil {
+		log.Error(err, "collecting activity failed")
+		http.Error(w, "unable to collect activity", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(activity); err != nil {
+		log.Error(err, "writing activity failed")
+	}
+}
+
+func (h *ActivityHandler) collect() (Activity, error) {
+	cmd := cluster.NewGreenplumCommand(h.Command).Command("/usr/local/greenplum-db/bin/psql",
+		"-U", "gpadmin", "-d", "postgres", "-h", "localhost", "-p", strconv.Itoa(h.Port), "-tA", "-c", activityQuery)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return Activity{}, fmt.Errorf("querying pg_stat_activity: %w: %s", err, stderr.String())
+	}
+	sessions, err := ParseActivity(stdout.String())
+	if err != nil {
+		return Activity{}, err
+	}
+	return SummarizeActivity(sessions), nil
+}
+
+// ParseActivity parses the unaligned, tuples-only (psql -tA) output of activityQuery
+func ParseActivity(output string) ([]Session, error) {
+	var sessions []Session
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", 8)
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("unexpected pg_stat_activity row: %q", line)
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected pg_stat_activity row: %q", line)
+		}
+		duration, err := strconv.ParseInt(fields[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected pg_stat_activity row: %q", line)
+		}
+		sessions = append(sessions, Session{
+			PID:             pid,
+			User:            fields[1],
+			Database:        fields[2],
+			State:           fields[3],
+			Waiting:         fields[4] == "t",
+			WaitingReason:   fields[5],
+			DurationSeconds: duration,
+			Query:           fields[7],
+		})
+	}
+	return sessions, nil
+}
+
+// SummarizeActivity counts the sessions running a query, and those of them waiting on a lock, resource group or replication
+func SummarizeActivity(sessions []Session) Activity {
+	activity := Activity{Sessions: sessions}
+	if activity.Sessions == nil {
+		activity.Sessions = []Session{}
+	}
+	for _, session := range sessions {
+		if session.State == "active" {
+			activity.Active++
+			if session.Waiting {
+				activity.Waiting++
+			}
+		}
+	}
+	return activity
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+const sampleActivity = `1234|gpadmin|gpadmin|active|f||12|SELECT count(*) FROM sales
+1240|etl|warehouse|active|t|lock|3|UPDATE orders SET status = 'shipped' WHERE id = 7
+1251|etl|warehouse|idle in transaction|f||45|SELECT 'a|b' FROM t
+`
+
+var _ = Describe("ActivityHandler", func() {
+	var (
+		cmdFake  *commandable.CommandFake
+		handler  *ActivityHandler
+		recorder *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		cmdFake = commandable.NewFakeCommand()
+		handler = &ActivityHandler{Command: cmdFake.Command, Port: 5432}
+		recorder = httptest.NewRecorder()
+	})
+	serve := func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/activity", nil))
+	}
+
+	It("queries pg_stat_activity on the configured port", func() {
+		called := 0
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql",
+			"-U", "gpadmin", "-d", "postgres", "-h", "localhost", "-p", "5432", "-tA", "-c", activityQuery).
+			CallCounter(&called)
+		serve()
+		Expect(called).To(Equal(1))
+	})
+
+	When("there are non-idle sessions", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql",
+				"-U", "gpadmin", "-d", "postgres", "-h", "localhost", "-p", "5432", "-tA", "-c", activityQuery).
+				PrintsOutput(sampleActivity)
+		})
+		It("returns a JSON summary of the sessions", func() {
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			var activity Activity
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &activity)).To(Succeed())
+			Expect(activity.Active).To(Equal(2))
+			Expect(activity.Waiting).To(Equal(1))
+			Expect(activity.Sessions).To(HaveLen(3))
+		})
+	})
+
+	When("there are no non-idle sessions", func() {
+		It("returns an empty summary", func() {
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"active": 0, "waiting": 0, "sessions": []}`))
+		})
+	})
+
+	When("psql fails", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql",
+				"-U", "gpadmin", "-d", "postgres", "-h", "localhost", "-p", "5432", "-tA", "-c", activityQuery).
+				PrintsError("psql: could not connect to server").
+				ReturnsStatus(2)
+		})
+		It("returns 503", func() {
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Describe("ParseActivity", func() {
+		It("parses each row of psql output", func() {
+			sessions, err := ParseActivity(sampleActivity)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sessions).To(Equal([]Session{
+				{PID: 1234, User: "gpadmin", Database: "gpadmin", State: "active", DurationSeconds: 12,
+					Query: "SELECT count(*) FROM sales"},
+				{PID: 1240, User: "etl", Database: "warehouse", State: "active", Waiting: true, WaitingReason: "lock",
+					DurationSeconds: 3, Query: "UPDATE orders SET status = 'shipped' WHERE id = 7"},
+				{PID: 1251, User: "etl", Database: "warehouse", State: "idle in transaction", DurationSeconds: 45,
+					Query: "SELECT 'a|b' FROM t"},
+			}))
+		})
+		It("returns no sessions for empty output", func() {
+			sessions, err := ParseActivity("\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sessions).To(BeEmpty())
+		})
+		It("returns an error for a malformed row", func() {
+			_, err := ParseActivity("1234|gpadmin|gpadmin\n")
+			Expect(err).To(MatchError(`unexpected pg_stat_activity row: "1234|gpadmin|gpadmin"`))
+		})
+		It("returns an error for a non-numeric pid", func() {
+			_, err := ParseActivity("abc|gpadmin|gpadmin|active|f||12|SELECT 1\n")
+			Expect(err).To(MatchError(ContainSubstring("unexpected pg_stat_activity row")))
+		})
+	})
+
+	Describe("SummarizeActivity", func() {
+		It("only counts waiting sessions that are active", func() {
+			activity := SummarizeActivity([]Session{
+				{State: "active", Waiting: true},
+				{State: "idle in transaction", Waiting: true},
+				{State: "active"},
+			})
+			Expect(activity.Active).To(Equal(2))
+			Expect(activity.Waiting).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-instance/cmd/startGreenplumContainer/startContainerUtils/cluster"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+// pg_isready exits with 1 when the server is up but rejecting connections,
+// which is the normal state of a mirror segment.
+const pgIsReadyRejectingExitCode = 1
+
+type HealthHandler struct {
+	Command commandable.CommandFn
+	Port    int
+	Mirror  bool
+}
+
+var _ http.Handler = &HealthHandler{}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if err := h.check(); err != nil {
+		log.V(1).Info("health check failed", "error", err)
+		http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "healthy")
+}
+
+func (h *HealthHandler) check() error {
+	cmd := cluster.NewGreenplumCommand(h.Command).Command("/usr/local/greenplum-db/bin/pg_isready",
+		"-q", "-h", "localhost", "-p", strconv.Itoa(h.Port))
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if h.Mirror && errors.As(err, &exitErr) && exitErr.ExitCode() == pgIsReadyRejectingExitCode {
+		return nil
+	}
+	return err
+}
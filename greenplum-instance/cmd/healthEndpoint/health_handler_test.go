@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+var _ = Describe("HealthHandler", func() {
+	var (
+		cmdFake  *commandable.CommandFake
+		handler  *HealthHandler
+		recorder *httptest.ResponseRecorder
+		called   int
+	)
+	BeforeEach(func() {
+		cmdFake = commandable.NewFakeCommand()
+		handler = &HealthHandler{Command: cmdFake.Command, Port: 40000}
+		recorder = httptest.NewRecorder()
+		called = 0
+	})
+	serve := func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	}
+
+	It("checks the configured port with pg_isready", func() {
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/pg_isready", "-q", "-h", "localhost", "-p", "40000").
+			CallCounter(&called)
+		serve()
+		Expect(called).To(Equal(1))
+	})
+
+	When("the instance is accepting connections", func() {
+		It("returns 200", func() {
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(Equal("healthy\n"))
+		})
+	})
+
+	When("the instance is not responding", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/pg_isready", "-q", "-h", "localhost", "-p", "40000").
+				ReturnsStatus(2)
+		})
+		It("returns 503", func() {
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	When("the instance is rejecting connections", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/pg_isready", "-q", "-h", "localhost", "-p", "40000").
+				ReturnsStatus(1)
+		})
+		It("returns 503 for a primary", func() {
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+		It("returns 200 for a mirror", func() {
+			handler.Mirror = true
+			serve()
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
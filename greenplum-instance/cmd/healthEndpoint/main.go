@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = ctrllog.Log.WithName("healthEndpoint")
+
+func main() {
+	ctrllog.SetLogger(gplog.ForProd(false))
+
+	var listenAddress = flag.String("listenAddress", ":8008", "address to serve health checks on")
+	var port = flag.Int("port", 5432, "port of the local Greenplum instance")
+	var mirror = flag.Bool("mirror", false, "the local Greenplum instance is a mirror segment")
+	var activity = flag.Bool("activity", false, "also serve a summary of pg_stat_activity on /activity")
+	flag.Parse()
+
+	http.Handle("/health", &HealthHandler{
+		Command: exec.Command,
+		Port:    *port,
+		Mirror:  *mirror,
+	})
+	if *activity {
+		http.Handle("/activity", &ActivityHandler{
+			Command: exec.Command,
+			Port:    *port,
+		})
+	}
+	log.Info("serving health checks", "address", *listenAddress, "port", *port, "mirror", *mirror, "activity", *activity)
+	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
+		log.Error(err, "health endpoint failed")
+		os.Exit(1)
+	}
+}
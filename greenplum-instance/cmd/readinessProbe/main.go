@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/blang/vfs"
+)
+
+// The output of an exec probe only reaches the kubelet, and only when it fails, so the state is also written to the
+// container's log through the stdout of PID 1
+const containerLogPath = "/proc/1/fd/1"
+
+// readinessProbe exits 0 when the local Greenplum instance is ready, e.g.
+//
+//	/home/gpadmin/tools/readinessProbe --role primary --port 40000
+func main() {
+	var role = flag.String("role", RolePrimary, "expected role of the local Greenplum instance: master, primary or mirror")
+	var port = flag.Int("port", 40000, "port of the local Greenplum instance")
+	flag.Parse()
+
+	if *role != RoleMaster && *role != RolePrimary && *role != RoleMirror {
+		fmt.Fprintf(os.Stderr, "invalid --role %q: must be master, primary or mirror\n", *role)
+		os.Exit(2)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	probe := &Probe{
+		Command:  exec.Command,
+		Fs:       vfs.OS(),
+		Hostname: hostname,
+		Role:     *role,
+		Port:     *port,
+	}
+	state := probe.Check()
+
+	out := io.Writer(os.Stdout)
+	if containerLog, err := os.OpenFile(containerLogPath, os.O_WRONLY|os.O_APPEND, 0); err == nil {
+		out = io.MultiWriter(os.Stdout, containerLog)
+	}
+	if err := WriteState(out, state); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if !state.Ready {
+		os.Exit(1)
+	}
+}
+
+// WriteState writes state as one line of JSON, tagged so that it can be told apart from the other container logs
+func WriteState(w io.Writer, state State) error {
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "readinessProbe: %s\n", stateJSON)
+	return err
+}
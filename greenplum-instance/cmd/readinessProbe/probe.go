@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/blang/vfs"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-instance/cmd/startGreenplumContainer/startContainerUtils/cluster"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/instanceconfig"
+)
+
+const (
+	RoleMaster  = "master"
+	RolePrimary = "primary"
+	RoleMirror  = "mirror"
+)
+
+// pg_isready exits with 1 when the server is up but rejecting connections, which is the normal state of a mirror
+// segment and of the standby master
+const pgIsReadyRejectingExitCode = 1
+
+// A primary segment is in sync once its mirror streams WAL synchronously. This is what gpstate -s reports as
+// "Synchronized" on the master, read from the primary itself. It is only logged: a mirror that is catching up does
+// not keep its primary from serving, and the operator reports it in the MirrorsNotInSync condition
+const (
+	replicationQuery = "SELECT state || '/' || sync_state FROM pg_stat_replication"
+	inSync           = "streaming/sync"
+)
+
+// Every primary segment must accept a connection for the query to complete, like any query dispatched by the master
+const clusterQuery = "SELECT count(*) FROM gp_dist_random('gp_id')"
+
+// State is what the probe found out about the local Greenplum instance
+type State struct {
+	Role          string `json:"role"`
+	ActingRole    string `json:"actingRole"`
+	Replication   string `json:"replication,omitempty"`
+	MirrorInSync  bool   `json:"mirrorInSync,omitempty"`
+	MirrorCause   string `json:"mirrorCause,omitempty"`
+	PrimaryCount  int    `json:"primaryCount,omitempty"`
+	Ready         bool   `json:"ready"`
+	NotReadyCause string `json:"notReadyCause,omitempty"`
+}
+
+// Probe checks that the local Greenplum instance is up in its expected role. The master must be able to reach every
+// primary segment. A primary segment of a mirrored cluster also reports whether it is in sync with its mirror, without
+// it affecting readiness. gpstate only runs on the master, so each segment checks what gpstate -s would report about
+// it from the segment itself.
+// Whether the cluster is mirrored is read from the config map rather than passed as a flag, so that adding mirrors
+// to a running cluster does not change the pod template of the primary segments.
+// A master fenced by a failover does not run Greenplum, and is ready so that it can be reinitialized as the standby.
+type Probe struct {
+	Command  commandable.CommandFn
+	Fs       vfs.Filesystem
+	Hostname string
+	Role     string
+	Port     int
+}
+
+func (p *Probe) Check() State {
+	state := State{Role: p.Role}
+	notReady := func(actingRole, cause string) State {
+		state.ActingRole = actingRole
+		state.NotReadyCause = cause
+		return state
+	}
+	ready := func(actingRole string) State {
+		state.ActingRole = actingRole
+		state.Ready = true
+		return state
+	}
+
+	if p.Role == RoleMaster {
+		fencedMaster, err := instanceconfig.NewReader(p.Fs).GetFencedMaster()
+		if err != nil {
+			return notReady(RoleMaster, err.Error())
+		}
+		if fencedMaster == p.Hostname {
+			return ready("fenced")
+		}
+	}
+
+	// Until gpinitsystem has run there is nothing to check, and the cluster cannot be initialized before the pods
+	// are ready
+	if _, err := p.Fs.Stat(p.dataDirectory() + "/PG_VERSION"); os.IsNotExist(err) {
+		return ready("uninitialized")
+	}
+
+	accepting, err := p.pgIsReady()
+	if err != nil {
+		return notReady("down", err.Error())
+	}
+
+	switch p.Role {
+	case RoleMaster:
+		if !accepting {
+			return ready("standby")
+		}
+		output, err := p.psql(clusterQuery, false)
+		if err != nil {
+			return notReady(RoleMaster, "not every primary segment accepts connections: "+err.Error())
+		}
+		state.PrimaryCount, err = strconv.Atoi(output)
+		if err != nil {
+			return notReady(RoleMaster, fmt.Sprintf("unexpected output of %s: %q", clusterQuery, output))
+		}
+		return ready(RoleMaster)
+	case RolePrimary:
+		if !accepting {
+			return notReady(RoleMirror, "acting as a mirror")
+		}
+		mirrored, err := instanceconfig.NewReader(p.Fs).GetMirrors()
+		if err != nil {
+			return notReady(RolePrimary, err.Error())
+		}
+		if !mirrored {
+			return ready(RolePrimary)
+		}
+		output, err := p.psql(replicationQuery, true)
+		switch {
+		case err != nil:
+			state.MirrorCause = "checking replication: " + err.Error()
+		case output == "":
+			state.MirrorCause = "mirror is not connected"
+		case output != inSync:
+			state.Replication = output
+			state.MirrorCause = "mirror is not in sync"
+		default:
+			state.Replication = output
+			state.MirrorInSync = true
+		}
+		return ready(RolePrimary)
+	default:
+		if accepting {
+			return notReady(RolePrimary, "acting as a primary")
+		}
+		return ready(RoleMirror)
+	}
+}
+
+func (p *Probe) dataDirectory() string {
+	switch p.Role {
+	case RoleMaster:
+		return "/greenplum/data-1"
+	case RoleMirror:
+		return "/greenplum/mirror/data"
+	default:
+		return "/greenplum/data"
+	}
+}
+
+// pgIsReady returns whether the instance accepts connections. An instance that is up but rejects them is not an error
+func (p *Probe) pgIsReady() (accepting bool, err error) {
+	cmd := cluster.NewGreenplumCommand(p.Command).Command("/usr/local/greenplum-db/bin/pg_isready",
+		"-q", "-h", "localhost", "-p", strconv.Itoa(p.Port))
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == pgIsReadyRejectingExitCode {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("pg_isready: %w", err)
+	}
+	return true, nil
+}
+
+// psql runs query on the local instance. Segments are queried in utility mode, which they require of connections
+// that do not come from the master
+func (p *Probe) psql(query string, utility bool) (string, error) {
+	database := "dbname=postgres"
+	if utility {
+		database += " options='-c gp_session_role=utility'"
+	}
+	cmd := cluster.NewGreenplumCommand(p.Command).Command("/usr/local/greenplum-db/bin/psql",
+		"-U", "gpadmin", "-d", database, "-h", "localhost", "-p", strconv.Itoa(p.Port), "-tAc", query)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/blang/vfs"
+	"github.com/blang/vfs/memfs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+var _ = Describe("Probe", func() {
+	var (
+		cmdFake *commandable.CommandFake
+		fs      *memfs.MemFS
+		probe   *Probe
+	)
+	BeforeEach(func() {
+		cmdFake = commandable.NewFakeCommand()
+		fs = memfs.Create()
+		Expect(vfs.MkdirAll(fs, "/greenplum/data-1", 0700)).To(Succeed())
+		Expect(vfs.MkdirAll(fs, "/greenplum/data", 0700)).To(Succeed())
+		Expect(vfs.MkdirAll(fs, "/greenplum/mirror/data", 0700)).To(Succeed())
+		Expect(vfs.MkdirAll(fs, "/etc/config", 0755)).To(Succeed())
+		Expect(vfs.WriteFile(fs, "/etc/config/mirrors", []byte("true"), 0644)).To(Succeed())
+	})
+	initialize := func(dataDirectory string) {
+		Expect(vfs.WriteFile(fs, dataDirectory+"/PG_VERSION", []byte("9.4\n"), 0600)).To(Succeed())
+	}
+	expectPgIsReady := func(port string) *commandable.ExpectedCommand {
+		return cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/pg_isready", "-q", "-h", "localhost", "-p", port)
+	}
+
+	Describe("master", func() {
+		BeforeEach(func() {
+			probe = &Probe{Command: cmdFake.Command, Fs: fs, Hostname: "master-1", Role: RoleMaster, Port: 5432}
+		})
+
+		It("is ready before the cluster is initialized", func() {
+			Expect(probe.Check()).To(Equal(State{Role: "master", ActingRole: "uninitialized", Ready: true}))
+		})
+
+		When("the cluster is initialized", func() {
+			BeforeEach(func() {
+				initialize("/greenplum/data-1")
+			})
+			expectClusterQuery := func() *commandable.ExpectedCommand {
+				return cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "dbname=postgres",
+					"-h", "localhost", "-p", "5432", "-tAc", "SELECT count(*) FROM gp_dist_random('gp_id')")
+			}
+
+			It("is ready when every primary segment accepts connections", func() {
+				expectPgIsReady("5432")
+				expectClusterQuery().PrintsOutput("4\n")
+				Expect(probe.Check()).To(Equal(State{Role: "master", ActingRole: "master", PrimaryCount: 4, Ready: true}))
+			})
+			It("is not ready when a primary segment does not accept connections", func() {
+				expectPgIsReady("5432")
+				expectClusterQuery().ReturnsStatus(1).PrintsError("ERROR:  failed to acquire resources on one or more segments\n")
+				Expect(probe.Check()).To(Equal(State{
+					Role:          "master",
+					ActingRole:    "master",
+					NotReadyCause: "not every primary segment accepts connections: exit status 1: ERROR:  failed to acquire resources on one or more segments",
+				}))
+			})
+			It("is ready as the standby, which rejects connections", func() {
+				expectPgIsReady("5432").ReturnsStatus(1)
+				Expect(probe.Check()).To(Equal(State{Role: "master", ActingRole: "standby", Ready: true}))
+			})
+			It("is not ready when Greenplum does not respond", func() {
+				expectPgIsReady("5432").ReturnsStatus(2)
+				Expect(probe.Check()).To(Equal(State{Role: "master", ActingRole: "down", NotReadyCause: "pg_isready: exit status 2"}))
+			})
+		})
+
+		When("it has been fenced by a failover", func() {
+			BeforeEach(func() {
+				initialize("/greenplum/data-1")
+				Expect(vfs.WriteFile(fs, "/etc/config/fencedMaster", []byte("master-1"), 0644)).To(Succeed())
+			})
+			It("is ready without checking Greenplum, so that it can be reinitialized as the standby", func() {
+				Expect(probe.Check()).To(Equal(State{Role: "master", ActingRole: "fenced", Ready: true}))
+			})
+		})
+
+		When("the other master has been fenced", func() {
+			BeforeEach(func() {
+				initialize("/greenplum/data-1")
+				Expect(vfs.WriteFile(fs, "/etc/config/fencedMaster", []byte("master-0"), 0644)).To(Succeed())
+			})
+			It("checks Greenplum", func() {
+				expectPgIsReady("5432").ReturnsStatus(2)
+				Expect(probe.Check()).To(Equal(State{Role: "master", ActingRole: "down", NotReadyCause: "pg_isready: exit status 2"}))
+			})
+		})
+	})
+
+	Describe("primary segment", func() {
+		BeforeEach(func() {
+			probe = &Probe{Command: cmdFake.Command, Fs: fs, Role: RolePrimary, Port: 40000}
+			initialize("/greenplum/data")
+		})
+		expectReplicationQuery := func() *commandable.ExpectedCommand {
+			return cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "dbname=postgres options='-c gp_session_role=utility'",
+				"-h", "localhost", "-p", "40000", "-tAc", "SELECT state || '/' || sync_state FROM pg_stat_replication")
+		}
+
+		It("is ready when its mirror is in sync", func() {
+			expectPgIsReady("40000")
+			expectReplicationQuery().PrintsOutput("streaming/sync\n")
+			Expect(probe.Check()).To(Equal(State{Role: "primary", ActingRole: "primary", Replication: "streaming/sync", MirrorInSync: true, Ready: true}))
+		})
+		It("stays ready while its mirror catches up", func() {
+			expectPgIsReady("40000")
+			expectReplicationQuery().PrintsOutput("catchup/async\n")
+			Expect(probe.Check()).To(Equal(State{Role: "primary", ActingRole: "primary", Replication: "catchup/async", MirrorCause: "mirror is not in sync", Ready: true}))
+		})
+		It("stays ready when its mirror is not connected", func() {
+			expectPgIsReady("40000")
+			expectReplicationQuery().PrintsOutput("")
+			Expect(probe.Check()).To(Equal(State{Role: "primary", ActingRole: "primary", MirrorCause: "mirror is not connected", Ready: true}))
+		})
+		It("stays ready when checking replication fails", func() {
+			expectPgIsReady("40000")
+			expectReplicationQuery().ReturnsStatus(2)
+			state := probe.Check()
+			Expect(state.Ready).To(BeTrue())
+			Expect(state.MirrorCause).To(HavePrefix("checking replication: "))
+		})
+		It("is not ready when it acts as a mirror after a failover", func() {
+			expectPgIsReady("40000").ReturnsStatus(1)
+			Expect(probe.Check()).To(Equal(State{Role: "primary", ActingRole: "mirror", NotReadyCause: "acting as a mirror"}))
+		})
+		It("does not check replication when the cluster has no mirrors", func() {
+			Expect(vfs.WriteFile(fs, "/etc/config/mirrors", []byte("false"), 0644)).To(Succeed())
+			expectPgIsReady("40000")
+			Expect(probe.Check()).To(Equal(State{Role: "primary", ActingRole: "primary", Ready: true}))
+		})
+	})
+
+	Describe("mirror segment", func() {
+		BeforeEach(func() {
+			probe = &Probe{Command: cmdFake.Command, Fs: fs, Role: RoleMirror, Port: 50000}
+			initialize("/greenplum/mirror/data")
+		})
+
+		It("is ready while it replays WAL, rejecting connections", func() {
+			expectPgIsReady("50000").ReturnsStatus(1)
+			Expect(probe.Check()).To(Equal(State{Role: "mirror", ActingRole: "mirror", Ready: true}))
+		})
+		It("is not ready when it acts as a primary after a failover", func() {
+			expectPgIsReady("50000")
+			Expect(probe.Check()).To(Equal(State{Role: "mirror", ActingRole: "primary", NotReadyCause: "acting as a primary"}))
+		})
+		It("is ready before the cluster is initialized", func() {
+			Expect(fs.Remove("/greenplum/mirror/data/PG_VERSION")).To(Succeed())
+			Expect(probe.Check()).To(Equal(State{Role: "mirror", ActingRole: "uninitialized", Ready: true}))
+		})
+	})
+})
+
+var _ = Describe("WriteState", func() {
+	It("writes the state as one line of JSON", func() {
+		out := &bytes.Buffer{}
+		Expect(WriteState(out, State{Role: "primary", ActingRole: "primary", Replication: "catchup/async", MirrorCause: "mirror is not in sync", Ready: true})).To(Succeed())
+		Expect(out.String()).To(Equal(`readinessProbe: {"role":"primary","actingRole":"primary","replication":"catchup/async","mirrorCause":"mirror is not in sync","ready":true}` + "\n"))
+	})
+})
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
+)
+
+func TestReadinessProbe(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ReadinessProbe Suite")
+}
+
+func TestHelperProcess(t *testing.T) {
+	commandable.Command.HelperProcess()
+}
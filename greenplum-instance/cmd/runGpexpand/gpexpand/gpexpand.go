@@ -53,3 +53,26 @@ func (r *RunGpexpandConfig) Run() error {
 	cmd.Stderr = r.Stderr
 	return cmd.Run()
 }
+
+// Redistribute runs the second phase of gpexpand, which redistributes tables onto the new segments,
+// then removes the expansion schema so that the cluster can be expanded again.
+func (r *RunGpexpandConfig) Redistribute() error {
+	r.Log.Info("redistributing tables onto new segments")
+	cmd := r.Command("bash", "-c",
+		"source /usr/local/greenplum-db/greenplum_path.sh && MASTER_DATA_DIRECTORY=/greenplum/data-1 gpexpand")
+	cmd.Stdout = r.Stdout
+	cmd.Stderr = r.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "redistributing tables failed")
+	}
+
+	r.Log.Info("removing expansion schema")
+	cmd = r.Command("bash", "-c",
+		"source /usr/local/greenplum-db/greenplum_path.sh && MASTER_DATA_DIRECTORY=/greenplum/data-1 gpexpand -c --silent")
+	cmd.Stdout = r.Stdout
+	cmd.Stderr = r.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "removing expansion schema failed")
+	}
+	return nil
+}
@@ -145,4 +145,62 @@ var _ = Describe("RunGpexpand", func() {
 			Expect(stderr).To(gbytes.Say("gpexpand failed"))
 		})
 	})
+	Describe("Redistribute", func() {
+		var redistributeCalled, cleanupCalled int
+		BeforeEach(func() {
+			redistributeCalled = 0
+			cleanupCalled = 0
+			cmdFake.ExpectCommand("bash", "-c",
+				"source /usr/local/greenplum-db/greenplum_path.sh && MASTER_DATA_DIRECTORY=/greenplum/data-1 gpexpand").
+				CallCounter(&redistributeCalled).
+				PrintsOutput("redistribution successful")
+			cmdFake.ExpectCommand("bash", "-c",
+				"source /usr/local/greenplum-db/greenplum_path.sh && MASTER_DATA_DIRECTORY=/greenplum/data-1 gpexpand -c --silent").
+				CallCounter(&cleanupCalled)
+		})
+
+		It("redistributes tables and then removes the expansion schema", func() {
+			Expect(subject.Redistribute()).To(Succeed())
+			Expect(DecodeLogs(logBuf)).To(ContainLogEntry(gstruct.Keys{
+				"msg": Equal("redistributing tables onto new segments"),
+			}))
+			Expect(DecodeLogs(logBuf)).To(ContainLogEntry(gstruct.Keys{
+				"msg": Equal("removing expansion schema"),
+			}))
+			Expect(redistributeCalled).To(Equal(1))
+			Expect(cleanupCalled).To(Equal(1))
+			Expect(stdout).To(gbytes.Say("redistribution successful"))
+		})
+
+		It("does not add segments", func() {
+			Expect(subject.Redistribute()).To(Succeed())
+			Expect(fakeDNSResolver.HostRecords).To(BeEmpty())
+			Expect(gpexpandCalled).To(Equal(0))
+		})
+
+		When("redistribution fails", func() {
+			BeforeEach(func() {
+				cmdFake.ExpectCommand("bash", "-c",
+					"source /usr/local/greenplum-db/greenplum_path.sh && MASTER_DATA_DIRECTORY=/greenplum/data-1 gpexpand").
+					ReturnsStatus(1).
+					PrintsError("table redistribution failed")
+			})
+			It("returns an error without removing the expansion schema", func() {
+				Expect(subject.Redistribute()).To(MatchError("redistributing tables failed: exit status 1"))
+				Expect(stderr).To(gbytes.Say("table redistribution failed"))
+				Expect(cleanupCalled).To(Equal(0))
+			})
+		})
+
+		When("removing the expansion schema fails", func() {
+			BeforeEach(func() {
+				cmdFake.ExpectCommand("bash", "-c",
+					"source /usr/local/greenplum-db/greenplum_path.sh && MASTER_DATA_DIRECTORY=/greenplum/data-1 gpexpand -c --silent").
+					ReturnsStatus(1)
+			})
+			It("returns an error", func() {
+				Expect(subject.Redistribute()).To(MatchError("removing expansion schema failed: exit status 1"))
+			})
+		})
+	})
 })
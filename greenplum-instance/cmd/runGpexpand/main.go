@@ -23,6 +23,7 @@ func main() {
 	ctrllog.SetLogger(gplog.ForProd(false))
 
 	var newPrimarySegmentCount = flag.Int("newPrimarySegmentCount", 0, "new primary segment count")
+	var redistribute = flag.Bool("redistribute", false, "redistribute tables onto the new segments and remove the expansion schema")
 	flag.Parse()
 
 	oldSegmentCount, err := GetOldSegmentCount(exec.Command)
@@ -36,18 +37,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	generateGpexpandConfig := &gpexpandconfig.GenerateGpexpandConfigParams{
-		OldSegmentCount: oldSegmentCount,
-		NewSegmentCount: *newPrimarySegmentCount,
-		IsMirrored:      config.Mirrors,
-		Fs:              vfs.OS(),
-		Command:         exec.Command,
-	}
-	if err := generateGpexpandConfig.Run(); err != nil {
-		log.Error(err, "error generating gpexpand configuration")
-		os.Exit(1)
-	}
-
 	gpexpandRunner := &gpexpand.RunGpexpandConfig{
 		Log:              log,
 		NewSegmentCount:  *newPrimarySegmentCount,
@@ -60,9 +49,41 @@ func main() {
 		SSHExecutor:      ssh.NewMultiHostExec(fmt.Sprintf("/tools/waitForKnownHosts --newPrimarySegmentCount %d", *newPrimarySegmentCount)),
 		Command:          exec.Command,
 	}
-	if err := gpexpandRunner.Run(); err != nil {
-		log.Error(err, "error running gpexpand")
-		os.Exit(1)
+
+	// A job that only redistributes runs after the segments have already been added
+	if oldSegmentCount < *newPrimarySegmentCount {
+		generateGpexpandConfig := &gpexpandconfig.GenerateGpexpandConfigParams{
+			OldSegmentCount: oldSegmentCount,
+			NewSegmentCount: *newPrimarySegmentCount,
+			IsMirrored:      config.Mirrors,
+			Fs:              vfs.OS(),
+			Command:         exec.Command,
+		}
+		if err := generateGpexpandConfig.Run(); err != nil {
+			log.Error(err, "error generating gpexpand configuration")
+			os.Exit(1)
+		}
+
+		if err := gpexpandRunner.Run(); err != nil {
+			log.Error(err, "error running gpexpand")
+			os.Exit(1)
+		}
+	}
+
+	if *redistribute {
+		schemaExists, err := ExpansionSchemaExists(exec.Command)
+		if err != nil {
+			log.Error(err, "error checking for expansion schema")
+			os.Exit(1)
+		}
+		if !schemaExists {
+			log.Info("no expansion schema found; nothing to redistribute")
+			return
+		}
+		if err := gpexpandRunner.Redistribute(); err != nil {
+			log.Error(err, "error redistributing tables")
+			os.Exit(1)
+		}
 	}
 }
 
@@ -74,3 +95,12 @@ func GetOldSegmentCount(command commandable.CommandFn) (int, error) {
 
 	return oldSegmentCount, nil
 }
+
+func ExpansionSchemaExists(command commandable.CommandFn) (bool, error) {
+	schemaCount, err := gpexpandconfig.ExecPsqlQueryAndReturnInt(command, "SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = 'gpexpand'")
+	if err != nil {
+		return false, err
+	}
+
+	return schemaCount > 0, nil
+}
@@ -38,3 +38,45 @@ var _ = Describe("GetOldSegmentCount", func() {
 		})
 	})
 })
+
+var _ = Describe("ExpansionSchemaExists", func() {
+	var (
+		cmdFake *commandable.CommandFake
+	)
+	BeforeEach(func() {
+		cmdFake = commandable.NewFakeCommand()
+	})
+	When("the gpexpand schema exists", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-tAc",
+				"SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = 'gpexpand'",
+			).PrintsOutput("1\n")
+		})
+		It("returns true", func() {
+			Expect(ExpansionSchemaExists(cmdFake.Command)).To(BeTrue())
+		})
+	})
+
+	When("the gpexpand schema does not exist", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-tAc",
+				"SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = 'gpexpand'",
+			).PrintsOutput("0\n")
+		})
+		It("returns false", func() {
+			Expect(ExpansionSchemaExists(cmdFake.Command)).To(BeFalse())
+		})
+	})
+
+	When("querying the schema fails", func() {
+		BeforeEach(func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-tAc",
+				"SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = 'gpexpand'",
+			).ReturnsStatus(1).PrintsError("custom schema query error")
+		})
+		It("returns error", func() {
+			_, err := ExpansionSchemaExists(cmdFake.Command)
+			Expect(err).To(MatchError("custom schema query error: exit status 1"))
+		})
+	})
+})
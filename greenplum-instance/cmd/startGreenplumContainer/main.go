@@ -55,7 +55,7 @@ func main() {
 		App:     s,
 		UID:     os.Getuid(),
 		Root:    &startContainerUtils.RootContainerStarter{App: s, Ubuntu: u},
-		Gpadmin: &startContainerUtils.GpadminContainerStarter{App: s},
+		Gpadmin: &startContainerUtils.GpadminContainerStarter{App: s, Hostname: os.Hostname},
 		LabelPVC: &startContainerUtils.LabelPvcStarter{
 			App:      s,
 			Hostname: os.Hostname,
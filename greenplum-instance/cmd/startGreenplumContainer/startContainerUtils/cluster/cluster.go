@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/blang/vfs"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/commandable"
@@ -13,9 +15,42 @@ import (
 )
 
 const (
-	masterDataDir = "/greenplum/data-1"
+	masterDataDir       = "/greenplum/data-1"
+	tempTablespaceName  = "temp_tablespace"
+	pgStatStatementsLib = "/usr/local/greenplum-db/lib/postgresql/pg_stat_statements.so"
+
+	interconnectTLSCACert = "/etc/interconnect-tls/ca.crt"
+	// Makes libpq verify the certificate and host name of the segments the master connects to
+	interconnectTLSSSLMode = "PGSSLMODE=verify-full"
 )
 
+// InterconnectTLSHostBasedAuthentication goes at the top of pg_hba.conf on every segment when interconnect TLS is
+// enabled, so that segments only accept connections with a certificate signed by the interconnect CA.
+// Greenplum 6 is based on PostgreSQL 9.4, where clientcert=1 is what later releases call clientcert=verify-ca, and
+// where clientcert=verify-full would be read as no client certificate at all. The host names are verified by the
+// connecting side instead, which runs with PGSSLMODE=verify-full.
+const InterconnectTLSHostBasedAuthentication = "hostssl all all 0.0.0.0/0 trust clientcert=1\n" +
+	"hostssl all all ::/0 trust clientcert=1\n" +
+	"hostnossl all all 0.0.0.0/0 reject\n" +
+	"hostnossl all all ::/0 reject\n"
+
+// InterconnectTLSEnabled reports whether the interconnect-tls secret is mounted into this pod
+func InterconnectTLSEnabled(fs vfs.Filesystem) bool {
+	_, err := fs.Stat(interconnectTLSCACert)
+	return err == nil
+}
+
+// SegmentDataDirectory returns the data directory of the segment on podName, or "" if podName is a master
+func SegmentDataDirectory(podName string) string {
+	switch {
+	case strings.HasPrefix(podName, "segment-a-"):
+		return "/greenplum/data"
+	case strings.HasPrefix(podName, "segment-b-"):
+		return "/greenplum/mirror/data"
+	}
+	return ""
+}
+
 type ClusterInterface interface {
 	Initialize() error
 	GPStart() error
@@ -61,6 +96,18 @@ func (c *Cluster) Initialize() error {
 		return fmt.Errorf("master data directory already exists at %s", masterDataDir)
 	}
 
+	pgStatStatements, err := c.Config.GetPgStatStatements()
+	if err != nil {
+		return fmt.Errorf("reading pgStatStatements failed: %w", err)
+	}
+	// shared_preload_libraries is already in the gpinitsystem GUCs, so a missing library
+	// would keep the cluster from starting at all
+	if pgStatStatements {
+		if _, err := c.Filesystem.Stat(pgStatStatementsLib); err != nil {
+			return fmt.Errorf("pg_stat_statements is enabled but %s is not in the image", pgStatStatementsLib)
+		}
+	}
+
 	if err := c.gpInitSystem.GenerateConfig(); err != nil {
 		return fmt.Errorf("gpinitsystem config failed: %w", err)
 	}
@@ -72,6 +119,32 @@ func (c *Cluster) Initialize() error {
 		return fmt.Errorf("createdb failed: %w", err)
 	}
 
+	if err := c.createTempTablespace(); err != nil {
+		return fmt.Errorf("creating temp tablespace failed: %w", err)
+	}
+
+	if pgStatStatements {
+		if err := c.createPgStatStatementsExtension(); err != nil {
+			return fmt.Errorf("creating pg_stat_statements extension failed: %w", err)
+		}
+	}
+
+	if err := c.createConfiguredExtensions(); err != nil {
+		return fmt.Errorf("creating extensions failed: %w", err)
+	}
+
+	if err := c.createResourceQueues(); err != nil {
+		return fmt.Errorf("creating resource queues failed: %w", err)
+	}
+
+	if err := c.applyRoleSettings(); err != nil {
+		return fmt.Errorf("applying role settings failed: %w", err)
+	}
+
+	if err := c.addInterconnectTLSHostBasedAuthentication(); err != nil {
+		return fmt.Errorf("adding interconnect TLS host-based authentication failed: %w", err)
+	}
+
 	// We reload the HBA config in RunPostInitialization
 	return c.addMasterAndStandbyHostBasedAuthentication()
 }
@@ -84,6 +157,87 @@ func (c *Cluster) createDB() error {
 	return cmd.Run()
 }
 
+// The temp_tablespaces GUC names the tablespace before it exists, so it must be
+// created while the cluster is first running for spill files to land in it.
+func (c *Cluster) createTempTablespace() error {
+	location, err := c.Config.GetTempTablespaceLocation()
+	if err != nil {
+		return err
+	}
+	if location == "" {
+		return nil
+	}
+	PrintMessage(c.Stdout, "Creating temp tablespace")
+	cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+		fmt.Sprintf("CREATE TABLESPACE %s LOCATION '%s'", tempTablespaceName, location))
+	cmd.Stderr = c.Stderr
+	cmd.Stdout = c.Stdout
+	return cmd.Run()
+}
+
+func (c *Cluster) createPgStatStatementsExtension() error {
+	PrintMessage(c.Stdout, "Creating pg_stat_statements extension")
+	cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+		"CREATE EXTENSION IF NOT EXISTS pg_stat_statements")
+	cmd.Stderr = c.Stderr
+	cmd.Stdout = c.Stdout
+	return cmd.Run()
+}
+
+// Extension names are checked against an allowlist by the admission webhook; some, like uuid-ossp, need quoting
+func (c *Cluster) createConfiguredExtensions() error {
+	extensions, err := c.Config.GetExtensions()
+	if err != nil {
+		return err
+	}
+	for _, extension := range extensions {
+		PrintMessage(c.Stdout, fmt.Sprintf("Creating %s extension", extension))
+		cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+			fmt.Sprintf(`CREATE EXTENSION IF NOT EXISTS "%s"`, extension))
+		cmd.Stderr = c.Stderr
+		cmd.Stdout = c.Stdout
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resource queues are created before role settings, and the roles assigned to them are created with them
+func (c *Cluster) createResourceQueues() error {
+	statements, err := c.Config.GetResourceQueues()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		PrintMessage(c.Stdout, "Running "+statement)
+		cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", statement)
+		cmd.Stderr = c.Stderr
+		cmd.Stdout = c.Stdout
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) applyRoleSettings() error {
+	statements, err := c.Config.GetRoleSettings()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		PrintMessage(c.Stdout, "Running "+statement)
+		cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", statement)
+		cmd.Stderr = c.Stderr
+		cmd.Stdout = c.Stdout
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Cluster) addMasterAndStandbyHostBasedAuthentication() error {
 	if err := c.addHostBasedAuthentication("master-0"); err != nil {
 		return fmt.Errorf("adding host-based authentication failed: %w", err)
@@ -121,6 +275,42 @@ func (c *Cluster) addHostBasedAuthentication(host string) error {
 	return nil
 }
 
+// addInterconnectTLSHostBasedAuthentication puts InterconnectTLSHostBasedAuthentication at the top of pg_hba.conf on
+// every segment. Segment pods add it themselves when they start, but gpinitsystem created these after that.
+func (c *Cluster) addInterconnectTLSHostBasedAuthentication() error {
+	if !InterconnectTLSEnabled(c.Filesystem) {
+		return nil
+	}
+	segmentCount, err := c.Config.GetSegmentCount()
+	if err != nil {
+		return fmt.Errorf("reading segment count failed: %w", err)
+	}
+	mirrors, err := c.Config.GetMirrors()
+	if err != nil {
+		return fmt.Errorf("reading mirrors failed: %w", err)
+	}
+	var hosts []string
+	for i := 0; i < segmentCount; i++ {
+		hosts = append(hosts, "segment-a-"+strconv.Itoa(i))
+		if mirrors {
+			hosts = append(hosts, "segment-b-"+strconv.Itoa(i))
+		}
+	}
+	records := "'" + strings.ReplaceAll(InterconnectTLSHostBasedAuthentication, "'", `'\''`) + "'"
+	for _, host := range hosts {
+		PrintMessage(c.Stdout, "Adding interconnect TLS host based authentication to "+host+" pg_hba.conf")
+		pgHba := SegmentDataDirectory(host) + "/pg_hba.conf"
+		cmd := c.Command("/usr/bin/ssh", host,
+			"printf", "%s", records, "|", "cat", "-", pgHba, ">", pgHba+".new", "&&", "mv", pgHba+".new", pgHba)
+		cmd.Stderr = c.Stderr
+		cmd.Stdout = c.Stdout
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("on %s: %w", host, err)
+		}
+	}
+	return nil
+}
+
 func (c *Cluster) GPStart() error {
 	cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/gpstart", "-am")
 	cmd.Stderr = c.Stderr
@@ -131,9 +321,13 @@ func (c *Cluster) GPStart() error {
 		return fmt.Errorf("gpstart in maintenance mode failed: %w", err)
 	}
 
+	// The master started here is the one that dispatches to the segments
 	cmd = c.greenplumCommand.Command("/usr/local/greenplum-db/bin/gpstop", "-ar")
 	cmd.Stderr = c.Stderr
 	cmd.Stdout = c.Stdout
+	if InterconnectTLSEnabled(c.Filesystem) {
+		cmd.Env = append(cmd.Env, interconnectTLSSSLMode)
+	}
 
 	err = cmd.Run()
 	if err != nil {
@@ -181,7 +375,8 @@ func (c *Cluster) createPXFExtension() error {
 }
 
 // TODO: turn this into its own starter.Starter.
-//   Then make a []starter.Starter to run gpinitsystem and pxf
+//
+//	Then make a []starter.Starter to run gpinitsystem and pxf
 func (c *Cluster) createExtension(extensionName string) error {
 	PrintMessage(c.Stdout, fmt.Sprintf("Creating %s Extension", extensionName))
 	cmd := c.greenplumCommand.Command("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", extensionName))
@@ -243,6 +243,51 @@ var _ = Describe("cluster", func() {
 			})
 		})
 	})
+	When("the interconnect-tls secret is mounted", func() {
+		var segmentACalled, segmentBCalled int
+		BeforeEach(func() {
+			Expect(vfs.MkdirAll(fs, "/etc/interconnect-tls", 0755)).To(Succeed())
+			Expect(vfs.WriteFile(fs, "/etc/interconnect-tls/ca.crt", []byte("i am ca.crt"), 0444)).To(Succeed())
+			records := "'hostssl all all 0.0.0.0/0 trust clientcert=1\n" +
+				"hostssl all all ::/0 trust clientcert=1\n" +
+				"hostnossl all all 0.0.0.0/0 reject\n" +
+				"hostnossl all all ::/0 reject\n'"
+			segmentACalled, segmentBCalled = 0, 0
+			cmdFake.ExpectCommand("/usr/bin/ssh", "segment-a-0",
+				"printf", "%s", records, "|", "cat", "-", "/greenplum/data/pg_hba.conf",
+				">", "/greenplum/data/pg_hba.conf.new", "&&", "mv", "/greenplum/data/pg_hba.conf.new", "/greenplum/data/pg_hba.conf").
+				CallCounter(&segmentACalled)
+			cmdFake.ExpectCommand("/usr/bin/ssh", "segment-b-0",
+				"printf", "%s", records, "|", "cat", "-", "/greenplum/mirror/data/pg_hba.conf",
+				">", "/greenplum/mirror/data/pg_hba.conf.new", "&&", "mv", "/greenplum/mirror/data/pg_hba.conf.new", "/greenplum/mirror/data/pg_hba.conf").
+				CallCounter(&segmentBCalled)
+		})
+		It("requires interconnect certificates in pg_hba.conf on every segment", func() {
+			exitErr = c.Initialize()
+			Expect(exitErr).NotTo(HaveOccurred())
+			Expect(segmentACalled).To(Equal(1))
+			Expect(segmentBCalled).To(Equal(1))
+			Expect(outBuffer).To(gbytes.Say("Adding interconnect TLS host based authentication to segment-a-0 pg_hba.conf"))
+		})
+		It("returns an error when a segment cannot be updated", func() {
+			cmdFake.ExpectCommand("/usr/bin/ssh", "segment-b-0",
+				"printf", "%s", "'hostssl all all 0.0.0.0/0 trust clientcert=1\n"+
+					"hostssl all all ::/0 trust clientcert=1\n"+
+					"hostnossl all all 0.0.0.0/0 reject\n"+
+					"hostnossl all all ::/0 reject\n'", "|", "cat", "-", "/greenplum/mirror/data/pg_hba.conf",
+				">", "/greenplum/mirror/data/pg_hba.conf.new", "&&", "mv", "/greenplum/mirror/data/pg_hba.conf.new", "/greenplum/mirror/data/pg_hba.conf").
+				ReturnsStatus(1)
+			exitErr = c.Initialize()
+			Expect(exitErr).To(MatchError("adding interconnect TLS host-based authentication failed: on segment-b-0: exit status 1"))
+		})
+		It("starts the master with libpq verifying the segments", func() {
+			envs := make(chan []string, 1)
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/gpstop", "-ar").SendEnvironment(envs)
+			Expect(c.GPStart()).To(Succeed())
+			Expect(envs).To(Receive(ContainElement("PGSSLMODE=verify-full")))
+		})
+	})
+
 	var itDoesNotWriteToPgHba = func() {
 		It("does not add hostBasedAuthentication to master-0/1 pg_hba.conf", func() {
 			masterCalled := 0
@@ -311,6 +356,234 @@ var _ = Describe("cluster", func() {
 		Expect(exitErr).To(MatchError("createdb failed: exit status 1"))
 	})
 
+	It("does not create a temp tablespace by default", func() {
+		createTablespaceCount := 0
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", "CREATE TABLESPACE temp_tablespace LOCATION '/greenplum-temp'").
+			CallCounter(&createTablespaceCount)
+		exitErr = c.Initialize()
+		Expect(errBuffer.Contents()).To(BeEmpty())
+		Expect(createTablespaceCount).To(Equal(0))
+	})
+	When("a temp tablespace location is configured", func() {
+		BeforeEach(func() {
+			mockConfig.TempTablespaceLocation = "/greenplum-temp"
+		})
+		It("creates the temp tablespace after createdb", func() {
+			createTablespaceCount := 0
+			envs := make(chan []string, 1)
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", "CREATE TABLESPACE temp_tablespace LOCATION '/greenplum-temp'").
+				CallCounter(&createTablespaceCount).SendEnvironment(envs)
+			exitErr = c.Initialize()
+			Expect(exitErr).NotTo(HaveOccurred())
+			Expect(outBuffer).To(gbytes.Say("Running createdb"))
+			Expect(outBuffer).To(gbytes.Say("Creating temp tablespace"))
+			Expect(createTablespaceCount).To(Equal(1))
+
+			var env []string
+			Expect(envs).To(Receive(&env))
+			Expect(env).To(ContainGreenplumEnvironment)
+		})
+		It("returns an error when creating the temp tablespace fails", func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", "CREATE TABLESPACE temp_tablespace LOCATION '/greenplum-temp'").
+				ReturnsStatus(1).
+				PrintsError("directory does not exist")
+
+			exitErr = c.Initialize()
+			Expect(errBuffer).To(gbytes.Say("directory does not exist"))
+			Expect(exitErr).To(MatchError("creating temp tablespace failed: exit status 1"))
+		})
+	})
+	It("does not create the pg_stat_statements extension by default", func() {
+		createExtensionCount := 0
+		cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", "CREATE EXTENSION IF NOT EXISTS pg_stat_statements").
+			CallCounter(&createExtensionCount)
+		exitErr = c.Initialize()
+		Expect(exitErr).NotTo(HaveOccurred())
+		Expect(createExtensionCount).To(Equal(0))
+	})
+	When("pg_stat_statements is enabled", func() {
+		BeforeEach(func() {
+			mockConfig.PgStatStatements = true
+		})
+		When("the library is in the image", func() {
+			BeforeEach(func() {
+				Expect(vfs.MkdirAll(fs, "/usr/local/greenplum-db/lib/postgresql", 0755)).To(Succeed())
+				Expect(vfs.WriteFile(fs, "/usr/local/greenplum-db/lib/postgresql/pg_stat_statements.so", []byte{}, 0755)).To(Succeed())
+			})
+			It("creates the extension after createdb", func() {
+				createExtensionCount := 0
+				envs := make(chan []string, 1)
+				cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", "CREATE EXTENSION IF NOT EXISTS pg_stat_statements").
+					CallCounter(&createExtensionCount).SendEnvironment(envs)
+				exitErr = c.Initialize()
+				Expect(exitErr).NotTo(HaveOccurred())
+				Expect(outBuffer).To(gbytes.Say("Running createdb"))
+				Expect(outBuffer).To(gbytes.Say("Creating pg_stat_statements extension"))
+				Expect(createExtensionCount).To(Equal(1))
+
+				var env []string
+				Expect(envs).To(Receive(&env))
+				Expect(env).To(ContainGreenplumEnvironment)
+			})
+			It("returns an error when creating the extension fails", func() {
+				cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c", "CREATE EXTENSION IF NOT EXISTS pg_stat_statements").
+					ReturnsStatus(1).
+					PrintsError("could not open extension control file")
+
+				exitErr = c.Initialize()
+				Expect(errBuffer).To(gbytes.Say("could not open extension control file"))
+				Expect(exitErr).To(MatchError("creating pg_stat_statements extension failed: exit status 1"))
+			})
+		})
+		When("the library is not in the image", func() {
+			It("fails before running gpinitsystem", func() {
+				exitErr = c.Initialize()
+				Expect(exitErr).To(MatchError("pg_stat_statements is enabled but /usr/local/greenplum-db/lib/postgresql/pg_stat_statements.so is not in the image"))
+				Expect(fakeGpInit.generateConfigCallCount).To(Equal(0))
+				Expect(fakeGpInit.runCallCount).To(Equal(0))
+			})
+		})
+	})
+	When("extensions are configured", func() {
+		BeforeEach(func() {
+			mockConfig.Extensions = []string{"pgcrypto", "uuid-ossp"}
+		})
+		It("creates each extension after createdb", func() {
+			var pgcryptoCount, uuidOsspCount int
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`CREATE EXTENSION IF NOT EXISTS "pgcrypto"`).CallCounter(&pgcryptoCount)
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).CallCounter(&uuidOsspCount)
+			exitErr = c.Initialize()
+			Expect(exitErr).NotTo(HaveOccurred())
+			Expect(outBuffer).To(gbytes.Say("Running createdb"))
+			Expect(outBuffer).To(gbytes.Say("Creating pgcrypto extension"))
+			Expect(outBuffer).To(gbytes.Say("Creating uuid-ossp extension"))
+			Expect(pgcryptoCount).To(Equal(1))
+			Expect(uuidOsspCount).To(Equal(1))
+		})
+		It("returns an error when creating an extension fails", func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`CREATE EXTENSION IF NOT EXISTS "pgcrypto"`).
+				ReturnsStatus(1).
+				PrintsError(`ERROR:  could not open extension control file`)
+			exitErr = c.Initialize()
+			Expect(errBuffer).To(gbytes.Say("could not open extension control file"))
+			Expect(exitErr).To(MatchError("creating extensions failed: exit status 1"))
+		})
+	})
+	When("reading extensions fails", func() {
+		BeforeEach(func() {
+			mockConfig.ExtensionsErr = errors.New("read failed")
+		})
+		It("returns an error", func() {
+			exitErr = c.Initialize()
+			Expect(exitErr).To(MatchError("creating extensions failed: read failed"))
+		})
+	})
+	When("resource queues are configured", func() {
+		BeforeEach(func() {
+			mockConfig.ResourceQueues = []string{
+				`CREATE RESOURCE QUEUE "reporting" WITH (ACTIVE_STATEMENTS=5)`,
+				`CREATE ROLE "analyst" RESOURCE QUEUE "reporting"`,
+			}
+			mockConfig.RoleSettings = []string{
+				`ALTER ROLE "analyst" SET search_path = sales, public`,
+			}
+		})
+		It("creates the queues and their roles after createdb, before applying role settings", func() {
+			var queueCount, roleCount int
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`CREATE RESOURCE QUEUE "reporting" WITH (ACTIVE_STATEMENTS=5)`).CallCounter(&queueCount)
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`CREATE ROLE "analyst" RESOURCE QUEUE "reporting"`).CallCounter(&roleCount)
+			exitErr = c.Initialize()
+			Expect(exitErr).NotTo(HaveOccurred())
+			Expect(outBuffer).To(gbytes.Say("Running createdb"))
+			Expect(outBuffer).To(gbytes.Say(`Running CREATE RESOURCE QUEUE "reporting"`))
+			Expect(outBuffer).To(gbytes.Say(`Running CREATE ROLE "analyst" RESOURCE QUEUE "reporting"`))
+			Expect(outBuffer).To(gbytes.Say(`Running ALTER ROLE "analyst" SET search_path`))
+			Expect(queueCount).To(Equal(1))
+			Expect(roleCount).To(Equal(1))
+		})
+		It("returns an error when a statement fails", func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`CREATE RESOURCE QUEUE "reporting" WITH (ACTIVE_STATEMENTS=5)`).
+				ReturnsStatus(1).
+				PrintsError(`ERROR:  resource queues are only available with the queue resource manager`)
+			exitErr = c.Initialize()
+			Expect(errBuffer).To(gbytes.Say("resource queues are only available"))
+			Expect(exitErr).To(MatchError("creating resource queues failed: exit status 1"))
+		})
+	})
+	When("reading resource queues fails", func() {
+		BeforeEach(func() {
+			mockConfig.ResourceQueuesErr = errors.New("read failed")
+		})
+		It("returns an error", func() {
+			exitErr = c.Initialize()
+			Expect(exitErr).To(MatchError("creating resource queues failed: read failed"))
+		})
+	})
+	When("role settings are configured", func() {
+		BeforeEach(func() {
+			mockConfig.RoleSettings = []string{
+				`ALTER ROLE "gpadmin" SET search_path = "$user", public`,
+				`ALTER ROLE "gpadmin" SET statement_timeout = 5min`,
+			}
+		})
+		It("runs each ALTER ROLE SET statement after createdb", func() {
+			var searchPathCount, statementTimeoutCount int
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`ALTER ROLE "gpadmin" SET search_path = "$user", public`).CallCounter(&searchPathCount)
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`ALTER ROLE "gpadmin" SET statement_timeout = 5min`).CallCounter(&statementTimeoutCount)
+			exitErr = c.Initialize()
+			Expect(exitErr).NotTo(HaveOccurred())
+			Expect(outBuffer).To(gbytes.Say("Running createdb"))
+			Expect(outBuffer).To(gbytes.Say(`Running ALTER ROLE "gpadmin" SET search_path`))
+			Expect(outBuffer).To(gbytes.Say(`Running ALTER ROLE "gpadmin" SET statement_timeout`))
+			Expect(searchPathCount).To(Equal(1))
+			Expect(statementTimeoutCount).To(Equal(1))
+		})
+		It("returns an error when a statement fails", func() {
+			cmdFake.ExpectCommand("/usr/local/greenplum-db/bin/psql", "-U", "gpadmin", "-d", "gpadmin", "-c",
+				`ALTER ROLE "gpadmin" SET search_path = "$user", public`).
+				ReturnsStatus(1).
+				PrintsError(`ERROR:  role "gpadmin" does not exist`)
+			exitErr = c.Initialize()
+			Expect(errBuffer).To(gbytes.Say(`role "gpadmin" does not exist`))
+			Expect(exitErr).To(MatchError("applying role settings failed: exit status 1"))
+		})
+	})
+	When("reading role settings fails", func() {
+		BeforeEach(func() {
+			mockConfig.RoleSettingsErr = errors.New("read failed")
+		})
+		It("returns an error", func() {
+			exitErr = c.Initialize()
+			Expect(exitErr).To(MatchError("applying role settings failed: read failed"))
+		})
+	})
+	When("reading pgStatStatements fails", func() {
+		BeforeEach(func() {
+			mockConfig.PgStatStatementsErr = errors.New("read failed")
+		})
+		It("returns an error", func() {
+			exitErr = c.Initialize()
+			Expect(exitErr).To(MatchError("reading pgStatStatements failed: read failed"))
+		})
+	})
+	When("reading the temp tablespace location fails", func() {
+		BeforeEach(func() {
+			mockConfig.TempTablespaceLocationErr = errors.New("read failed")
+		})
+		It("returns an error", func() {
+			exitErr = c.Initialize()
+			Expect(exitErr).To(MatchError("creating temp tablespace failed: read failed"))
+		})
+	})
+
 	Describe("RunPostInitialization", func() {
 		It("checks to see if the database is running", func() {
 			psqlCalled := 0
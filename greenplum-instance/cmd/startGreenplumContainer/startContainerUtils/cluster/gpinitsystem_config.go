@@ -13,6 +13,7 @@ import (
 )
 
 const GpinitsystemConfigPath = "/home/gpadmin/gpinitsystem_config"
+const GpinitsystemHostfilePath = "/home/gpadmin/hostfile_gpinitsystem"
 const gpinitsystemSuccessExitStatus = "exit status 1"
 
 type GpInitSystem interface {
@@ -50,6 +51,10 @@ func (g *gpInitSystem) GenerateConfig() error {
 	if err != nil {
 		return err
 	}
+	arrayName, err := g.configReader.GetArrayName()
+	if err != nil {
+		return err
+	}
 
 	cmd := g.Command("dnsdomainname")
 	output, err := cmd.Output()
@@ -63,6 +68,9 @@ func (g *gpInitSystem) GenerateConfig() error {
 	if err != nil {
 		return err
 	}
+	if arrayName != "" {
+		fmt.Fprintf(configFile, "ARRAY_NAME=\"%s\"\n", arrayName)
+	}
 	dbID := 1
 	fmt.Fprintf(configFile, "QD_PRIMARY_ARRAY=master-0.%v~5432~/greenplum/data-1~%d~-1~0\n", subdomain, dbID)
 	dbID++
@@ -84,8 +92,30 @@ func (g *gpInitSystem) GenerateConfig() error {
 		}
 		fmt.Fprint(configFile, ")\n")
 	}
+	fmt.Fprintf(configFile, "MACHINE_LIST_FILE=%s\n", GpinitsystemHostfilePath)
 	fmt.Fprint(configFile, "HBA_HOSTNAMES=1\n")
-	return configFile.Close()
+	if err := configFile.Close(); err != nil {
+		return err
+	}
+	return g.generateHostfile(subdomain, segmentCount, useMirrors)
+}
+
+// generateHostfile lists the segment hosts, one per line, for gpinitsystem's MACHINE_LIST_FILE
+// and for tools like gpssh that take the same host list.
+func (g *gpInitSystem) generateHostfile(subdomain string, segmentCount int, useMirrors bool) error {
+	hostfile, err := g.Filesystem.OpenFile(GpinitsystemHostfilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for segment := 0; segment < segmentCount; segment++ {
+		fmt.Fprintf(hostfile, "segment-a-%d.%v\n", segment, subdomain)
+	}
+	if useMirrors {
+		for segment := 0; segment < segmentCount; segment++ {
+			fmt.Fprintf(hostfile, "segment-b-%d.%v\n", segment, subdomain)
+		}
+	}
+	return hostfile.Close()
 }
 
 func (g *gpInitSystem) Run() error {
@@ -66,6 +66,7 @@ var _ = Describe("Gpinitsystem.GenerateConfig()", func() {
 						"declare -a MIRROR_ARRAY=(\n" +
 						"segment-b-0.myheadlessservice.mynamespace.svc.cluster.local~50000~/greenplum/mirror/data~3~0\n" +
 						")\n" +
+						"MACHINE_LIST_FILE=/home/gpadmin/hostfile_gpinitsystem\n" +
 						"HBA_HOSTNAMES=1\n"))
 			})
 		})
@@ -90,8 +91,45 @@ var _ = Describe("Gpinitsystem.GenerateConfig()", func() {
 						"segment-b-0.myheadlessservice.mynamespace.svc.cluster.local~50000~/greenplum/mirror/data~4~0\n" +
 						"segment-b-1.myheadlessservice.mynamespace.svc.cluster.local~50000~/greenplum/mirror/data~5~1\n" +
 						")\n" +
+						"MACHINE_LIST_FILE=/home/gpadmin/hostfile_gpinitsystem\n" +
 						"HBA_HOSTNAMES=1\n"))
 			})
+			It("generates a hostfile with the primary and mirror segment hosts", func() {
+				cmdFake.FakeOutput("myheadlessservice.mynamespace.svc.cluster.local")
+				Expect(g.GenerateConfig()).To(Succeed())
+				hostfile, err := vfs.ReadFile(fs, "/home/gpadmin/hostfile_gpinitsystem")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(hostfile)).To(Equal(
+					"segment-a-0.myheadlessservice.mynamespace.svc.cluster.local\n" +
+						"segment-a-1.myheadlessservice.mynamespace.svc.cluster.local\n" +
+						"segment-b-0.myheadlessservice.mynamespace.svc.cluster.local\n" +
+						"segment-b-1.myheadlessservice.mynamespace.svc.cluster.local\n"))
+			})
+		})
+
+		When("ARRAY_NAME is configured", func() {
+			BeforeEach(func() {
+				configReader.SegmentCount = 1
+				configReader.ArrayName = "Analytics Warehouse"
+			})
+			It("generates gpinitsystem_config with ARRAY_NAME", func() {
+				cmdFake.FakeOutput("myheadlessservice.mynamespace.svc.cluster.local")
+				Expect(g.GenerateConfig()).To(Succeed())
+				config, err := vfs.ReadFile(fs, "/home/gpadmin/gpinitsystem_config")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(config)).To(HavePrefix("ARRAY_NAME=\"Analytics Warehouse\"\n" +
+					"QD_PRIMARY_ARRAY=master-0.myheadlessservice.mynamespace.svc.cluster.local~5432~/greenplum/data-1~1~-1~0\n"))
+			})
+		})
+
+		When("ARRAY_NAME fails to read", func() {
+			BeforeEach(func() {
+				configReader.SegmentCount = 1
+				configReader.ArrayNameErr = errors.New("foo bar")
+			})
+			It("returns an error", func() {
+				Expect(g.GenerateConfig()).To(MatchError("foo bar"))
+			})
 		})
 
 		When("SEGMENT_COUNT fails to read", func() {
@@ -119,8 +157,16 @@ var _ = Describe("Gpinitsystem.GenerateConfig()", func() {
 						"declare -a PRIMARY_ARRAY=(\n" +
 						"segment-a-0.myheadlessservice.mynamespace.svc.cluster.local~40000~/greenplum/data~2~0\n" +
 						")\n" +
+						"MACHINE_LIST_FILE=/home/gpadmin/hostfile_gpinitsystem\n" +
 						"HBA_HOSTNAMES=1\n"))
 			})
+			It("generates a hostfile with only the primary segment hosts", func() {
+				cmdFake.FakeOutput("myheadlessservice.mynamespace.svc.cluster.local")
+				Expect(g.GenerateConfig()).To(Succeed())
+				hostfile, err := vfs.ReadFile(fs, "/home/gpadmin/hostfile_gpinitsystem")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(hostfile)).To(Equal("segment-a-0.myheadlessservice.mynamespace.svc.cluster.local\n"))
+			})
 		})
 	})
 
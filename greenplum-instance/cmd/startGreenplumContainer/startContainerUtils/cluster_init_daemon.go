@@ -47,6 +47,23 @@ func (s *ClusterInitDaemon) InitializeCluster() error {
 		return err
 	}
 
+	if err := s.runEntrypointScript(hostname); err != nil {
+		Log.Error(err, "running entrypoint script")
+		return err
+	}
+
+	// A master fenced by a failover would split the cluster if it started Greenplum again, e.g. after failing back
+	// to master-0, master-1 would otherwise run pg_ctl restart on its old data directory
+	fencedMaster, err := s.Config.GetFencedMaster()
+	if err != nil {
+		Log.Error(err, "reading fenced master")
+		return err
+	}
+	if fencedMaster == hostname {
+		Log.Info("this master was fenced by a failover; not starting Greenplum until it is reinitialized as the standby")
+		return nil
+	}
+
 	return s.NewPostgresInitializer(hostname).InitializePostgres()
 }
 
@@ -156,6 +173,9 @@ func (i *masterPostgresInitializer) checkAndRunGPStart(hasStandby bool) error {
 		Log.Info("Automatic gpstart is not currently supported with standby masters. Skipping.")
 		return nil
 	}
+	if err := i.clearStaleLockFile(); err != nil {
+		return err
+	}
 	return i.clusterStarter.C.GPStart()
 }
 
@@ -164,6 +184,9 @@ var _ PostgresInitializer = &segmentPostgresInitializer{}
 func (i *segmentPostgresInitializer) InitializePostgres() error {
 	if i.CheckPreinitalizedCluster() {
 		Log.Info("cluster has been initialized before; starting Postgres")
+		if err := i.clearStaleLockFile(); err != nil {
+			return err
+		}
 		return i.pgCtlRestart()
 	}
 	return nil
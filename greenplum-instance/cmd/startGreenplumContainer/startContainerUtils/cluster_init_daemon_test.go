@@ -169,6 +169,55 @@ var _ = Describe("ClusterInitDaemon", func() {
 
 					It("should run post initialization", ShouldRunPostInitialization)
 
+					When("a stale postmaster.pid was left in the data directory", func() {
+						BeforeEach(func() {
+							Expect(vfs.WriteFile(memoryfs, "/greenplum/data-1/postmaster.pid", []byte("123\n/greenplum/data-1\n"), 0600)).To(Succeed())
+						})
+						It("removes it before gpstart", func() {
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(c.gpstartStub.wasCalled).To(BeTrue())
+							_, err := memoryfs.Stat("/greenplum/data-1/postmaster.pid")
+							Expect(os.IsNotExist(err)).To(BeTrue(), "expected postmaster.pid to be removed")
+						})
+					})
+
+					When("an entrypoint script is mounted", func() {
+						var scriptArgs []string
+						BeforeEach(func() {
+							Expect(vfs.MkdirAll(memoryfs, "/etc/greenplum-entrypoint", 0755)).To(Succeed())
+							Expect(vfs.WriteFile(memoryfs, "/etc/greenplum-entrypoint/entrypoint.sh", []byte("#!/bin/bash\n"), 0555)).To(Succeed())
+							scriptArgs = []string{"--kill-after=10s", "300s", "/etc/greenplum-entrypoint/entrypoint.sh"}
+						})
+						It("runs it with a timeout before gpstart", func() {
+							scriptCalled := 0
+							fakeCmd.ExpectCommand("timeout", scriptArgs...).CallCounter(&scriptCalled)
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(scriptCalled).To(Equal(1))
+							Expect(c.gpstartStub.wasCalled).To(BeTrue())
+							Expect(outBuffer).To(gbytes.Say("running entrypoint script"))
+						})
+						When("the script fails", func() {
+							BeforeEach(func() {
+								fakeCmd.ExpectCommand("timeout", scriptArgs...).ReturnsStatus(1).PrintsError("cannot mount storage")
+							})
+							It("returns an error without starting Postgres", func() {
+								Expect(app.InitializeCluster()).To(MatchError(ContainSubstring("entrypoint script failed: ")))
+								Expect(c.gpstartStub.wasCalled).To(BeFalse())
+								Expect(errorBuffer).To(gbytes.Say("cannot mount storage"))
+							})
+							It("should not run post initialization", ShouldNotRunPostInitialization)
+						})
+					})
+
+					It("does not run an entrypoint script when none is mounted", func() {
+						scriptCalled := 0
+						fakeCmd.ExpectCommandMatching(func(path string, args ...string) bool {
+							return path == "timeout"
+						}).CallCounter(&scriptCalled)
+						Expect(app.InitializeCluster()).To(Succeed())
+						Expect(scriptCalled).To(Equal(0))
+					})
+
 					When("gpstart fails", func() {
 						BeforeEach(func() {
 							c.gpstartStub.err = errors.New("gpstart error")
@@ -229,6 +278,16 @@ var _ = Describe("ClusterInitDaemon", func() {
 
 					It("should not run post initialization", ShouldNotRunPostInitialization)
 				})
+
+				When("master-0 has been fenced by a failover", func() {
+					BeforeEach(func() {
+						mockConfig.FencedMaster = "master-0"
+					})
+
+					It("does not initialize a new cluster over its removed data directory", ShouldNotGpinit)
+
+					It("should not run post initialization", ShouldNotRunPostInitialization)
+				})
 			})
 		})
 
@@ -276,6 +335,42 @@ var _ = Describe("ClusterInitDaemon", func() {
 
 				It("does not run pg_ctl", ShouldNotRunPgCtl)
 			})
+
+			When("it was the active master and has been fenced by a failover back to master-0", func() {
+				BeforeEach(func() {
+					Expect(vfs.MkdirAll(memoryfs, "/greenplum/data-1", 0755)).To(Succeed())
+					mockConfig.FencedMaster = "master-1"
+				})
+
+				It("succeeds", func() {
+					exitErr := app.InitializeCluster()
+					Expect(exitErr).NotTo(HaveOccurred())
+					Expect(outBuffer).To(gbytes.Say("this master was fenced by a failover; not starting Greenplum until it is reinitialized as the standby"))
+				})
+
+				It("does not run pg_ctl", ShouldNotRunPgCtl)
+			})
+
+			When("master-0 has been fenced", func() {
+				BeforeEach(func() {
+					Expect(vfs.MkdirAll(memoryfs, "/greenplum/data-1", 0755)).To(Succeed())
+					mockConfig.FencedMaster = "master-0"
+				})
+
+				It("runs pg_ctl to start the postgres process", ShouldRunPgCtl(masterPgctlArgs))
+			})
+
+			When("reading the fenced master fails", func() {
+				BeforeEach(func() {
+					mockConfig.FencedMasterErr = errors.New("read error")
+				})
+
+				It("returns the error", func() {
+					Expect(app.InitializeCluster()).To(MatchError("read error"))
+				})
+
+				It("does not run pg_ctl", ShouldNotRunPgCtl)
+			})
 		})
 
 		When("hostname is segment-b-42", func() {
@@ -303,6 +398,93 @@ var _ = Describe("ClusterInitDaemon", func() {
 
 				It("runs pg_ctl to start the postgres process", ShouldRunPgCtl(segmentPgctlArgs))
 
+				When("postmaster.pid exists", func() {
+					lockFile := "/greenplum/data/postmaster.pid"
+					var lockFileContents string
+					BeforeEach(func() {
+						lockFileContents = "123\n/greenplum/data\n1600000000\n40000\n/tmp\n*\n"
+					})
+					JustBeforeEach(func() {
+						Expect(vfs.WriteFile(memoryfs, lockFile, []byte(lockFileContents), 0600)).To(Succeed())
+					})
+					lockFileExists := func() bool {
+						_, err := memoryfs.Stat(lockFile)
+						return err == nil
+					}
+					givenProcess := func(pid, cmdline string) {
+						Expect(vfs.MkdirAll(memoryfs, "/proc/"+pid, 0755)).To(Succeed())
+						Expect(vfs.WriteFile(memoryfs, "/proc/"+pid+"/cmdline", []byte(cmdline), 0444)).To(Succeed())
+					}
+
+					When("no process has its PID", func() {
+						It("removes it before running pg_ctl", func() {
+							pgCtlCalled := 0
+							fakeCmd.ExpectCommand("/usr/local/greenplum-db/bin/pg_ctl", segmentPgctlArgs...).CallCounter(&pgCtlCalled)
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(lockFileExists()).To(BeFalse())
+							Expect(pgCtlCalled).To(Equal(1))
+							Expect(outBuffer).To(gbytes.Say(`"msg":"removed stale lock file","lockFile":"/greenplum/data/postmaster.pid","pid":123`))
+						})
+					})
+
+					When("another process has its PID", func() {
+						BeforeEach(func() {
+							givenProcess("123", "/usr/sbin/sshd\x00-D")
+						})
+						It("removes it", func() {
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(lockFileExists()).To(BeFalse())
+						})
+					})
+
+					When("a running postgres holds it", func() {
+						BeforeEach(func() {
+							givenProcess("123", "/usr/local/greenplum-db/bin/postgres\x00-D\x00/greenplum/data")
+						})
+						It("leaves it in place", func() {
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(lockFileExists()).To(BeTrue())
+							Expect(outBuffer).To(gbytes.Say(`"msg":"leaving lock file in place: it is held by a running postgres"`))
+						})
+					})
+
+					When("it does not start with a PID", func() {
+						BeforeEach(func() {
+							lockFileContents = ""
+						})
+						It("leaves it in place", func() {
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(lockFileExists()).To(BeTrue())
+							Expect(outBuffer).To(gbytes.Say(`"msg":"leaving lock file in place: it does not start with a PID"`))
+						})
+					})
+
+					When("it is for another data directory", func() {
+						BeforeEach(func() {
+							lockFileContents = "123\n/greenplum/data-1\n"
+						})
+						It("leaves it in place", func() {
+							Expect(app.InitializeCluster()).To(Succeed())
+							Expect(lockFileExists()).To(BeTrue())
+							Expect(outBuffer).To(gbytes.Say(`"msg":"leaving lock file in place: it is for another data directory"`))
+						})
+					})
+
+					When("removing it fails", func() {
+						BeforeEach(func() {
+							memoryfs.RemoveHook = func(name string) error {
+								return errors.New("remove failed")
+							}
+						})
+						It("returns an error without running pg_ctl", func() {
+							pgCtlCalled := 0
+							fakeCmd.ExpectCommand("/usr/local/greenplum-db/bin/pg_ctl", segmentPgctlArgs...).CallCounter(&pgCtlCalled)
+							Expect(app.InitializeCluster()).To(MatchError("removing stale /greenplum/data/postmaster.pid: remove failed"))
+							Expect(pgCtlCalled).To(Equal(0))
+						})
+					})
+				})
+
 				When("pg_ctl command fails", func() {
 					BeforeEach(func() {
 						fakeCmd.ExpectCommand("/usr/local/greenplum-db/bin/pg_ctl", segmentPgctlArgs...).ReturnsStatus(1).PrintsError("custom pg_ctl error")
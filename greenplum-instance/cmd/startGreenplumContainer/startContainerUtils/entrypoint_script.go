@@ -0,0 +1,34 @@
+package startContainerUtils
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-instance/cmd/startGreenplumContainer/startContainerUtils/cluster"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/instanceconfig"
+)
+
+const entrypointScriptTimeout = "300s"
+
+// runEntrypointScript runs the script mounted from spec.entrypointScript, if there is one, before Postgres is
+// initialized or started. It is bounded by a timeout so that a hung script cannot keep the pod from ever coming up
+// without anything being reported.
+func (s *ClusterInitDaemon) runEntrypointScript(hostname string) error {
+	scriptPath := instanceconfig.EntrypointScriptDir + instanceconfig.EntrypointScriptFileName
+	if _, err := s.Fs.Stat(scriptPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("checking for entrypoint script: %w", err)
+	}
+
+	Log.Info("running entrypoint script", "script", scriptPath)
+	cmd := cluster.NewGreenplumCommand(s.Command).Command("timeout", "--kill-after=10s", entrypointScriptTimeout, scriptPath)
+	cmd.Env = append(cmd.Env, "GREENPLUM_HOSTNAME="+hostname)
+	cmd.Stdout = s.StdoutBuffer
+	cmd.Stderr = s.StderrBuffer
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("entrypoint script failed: %w", err)
+	}
+	return nil
+}
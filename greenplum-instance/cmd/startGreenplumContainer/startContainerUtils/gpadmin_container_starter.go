@@ -2,8 +2,10 @@ package startContainerUtils
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/blang/vfs"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-instance/cmd/startGreenplumContainer/startContainerUtils/cluster"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/fileutil"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/starter"
 )
@@ -12,6 +14,7 @@ const bashrcPath = "/home/gpadmin/.bashrc"
 
 type GpadminContainerStarter struct {
 	*starter.App
+	Hostname func() (string, error)
 }
 
 func (s *GpadminContainerStarter) Run() error {
@@ -21,6 +24,7 @@ func (s *GpadminContainerStarter) Run() error {
 		s.CreateSymLink,
 		s.CreatePsqlHistory,
 		s.CreateMirrorDir,
+		s.InstallInterconnectCertificates,
 	} {
 		if err := step(); err != nil {
 			return err
@@ -47,6 +51,28 @@ func (s *GpadminContainerStarter) WriteContentsToBashrc() error {
 		}
 	}
 
+	umaskFilename := "/etc/config/dataDirectoryUmask"
+	if _, err := s.Fs.Stat(umaskFilename); err == nil {
+		b, err := vfs.ReadFile(s.Fs, umaskFilename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s, was configMap mounted properly?", umaskFilename)
+		}
+		// gpinitsystem creates the segment data directories over ssh, which sources .bashrc
+		if umask := strings.TrimSpace(string(b)); umask != "" {
+			toInsert += "umask " + umask + "\n"
+		}
+	}
+
+	// gpstart starts the segments over ssh, which sources .bashrc rather than keeping the container environment
+	if cluster.InterconnectTLSEnabled(s.Fs) {
+		toInsert += "export PGSSLMODE=verify-full\n"
+	}
+
+	// the gpadmin home may be persisted across restarts; don't insert twice
+	if b, err := vfs.ReadFile(s.Fs, bashrcPath); err == nil && strings.HasPrefix(string(b), toInsert) {
+		return nil
+	}
+
 	fileWriter := fileutil.FileWriter{WritableFileSystem: s.Fs}
 	return fileWriter.Insert(bashrcPath, toInsert)
 }
@@ -56,6 +82,9 @@ func (s *GpadminContainerStarter) CreateSymLink() error {
 	if err := vfs.MkdirAll(s.Fs, "/greenplum/gpAdminLogs", 0755); err != nil {
 		return err
 	}
+	if _, err := s.Fs.Lstat("/home/gpadmin/gpAdminLogs"); err == nil {
+		return nil
+	}
 	return s.Fs.Symlink("/greenplum/gpAdminLogs", "/home/gpadmin/gpAdminLogs")
 }
 
@@ -68,6 +97,9 @@ func (s *GpadminContainerStarter) CreatePsqlHistory() error {
 	const filename = "/home/gpadmin/.psql_history"
 	Log.Info("creating " + filename + " file")
 
+	if _, err := s.Fs.Stat(filename); err == nil {
+		return nil
+	}
 	return vfs.WriteFile(s.Fs, filename, []byte(""), 0600)
 }
 
@@ -106,3 +138,55 @@ func (s *GpadminContainerStarter) SetupSSHForGpadmin() error {
 
 	return nil
 }
+
+// InstallInterconnectCertificates copies this pod's certificate from the interconnect-tls secret into the default
+// libpq location, which the ssl GUCs also point at. Postgres requires the key to be private to gpadmin.
+func (s *GpadminContainerStarter) InstallInterconnectCertificates() error {
+	const (
+		mountPath = "/etc/interconnect-tls"
+		certDir   = "/home/gpadmin/.postgresql"
+	)
+	if _, err := s.Fs.Stat(mountPath + "/ca.crt"); err != nil {
+		return nil
+	}
+	Log.Info("installing interconnect certificates")
+
+	hostname, err := s.Hostname()
+	if err != nil {
+		return err
+	}
+	if err := vfs.MkdirAll(s.Fs, certDir, 0700); err != nil {
+		return err
+	}
+	for _, file := range []struct{ source, destination string }{
+		{source: mountPath + "/" + hostname + ".crt", destination: certDir + "/postgresql.crt"},
+		{source: mountPath + "/" + hostname + ".key", destination: certDir + "/postgresql.key"},
+		{source: mountPath + "/ca.crt", destination: certDir + "/root.crt"},
+	} {
+		content, err := vfs.ReadFile(s.Fs, file.source)
+		if err != nil {
+			return fmt.Errorf("failed to read %s, was the interconnect-tls secret mounted properly?", file.source)
+		}
+		if err := vfs.WriteFile(s.Fs, file.destination, content, 0600); err != nil {
+			return err
+		}
+	}
+	return s.requireInterconnectCertificates(hostname)
+}
+
+// requireInterconnectCertificates puts cluster.InterconnectTLSHostBasedAuthentication at the top of this segment's
+// pg_hba.conf, before it starts. A segment that gpinitsystem has not created yet gets it from cluster.Initialize.
+func (s *GpadminContainerStarter) requireInterconnectCertificates(hostname string) error {
+	dataDirectory := cluster.SegmentDataDirectory(hostname)
+	if dataDirectory == "" {
+		return nil
+	}
+	pgHba := dataDirectory + "/pg_hba.conf"
+	b, err := vfs.ReadFile(s.Fs, pgHba)
+	if err != nil || strings.HasPrefix(string(b), cluster.InterconnectTLSHostBasedAuthentication) {
+		return nil
+	}
+	Log.Info("requiring interconnect certificates in pg_hba.conf")
+	fileWriter := fileutil.FileWriter{WritableFileSystem: s.Fs}
+	return fileWriter.Insert(pgHba, cluster.InterconnectTLSHostBasedAuthentication)
+}
@@ -50,6 +50,91 @@ var _ = Describe("GpadminContainerStarter", func() {
 		})
 
 	})
+	Describe("InstallInterconnectCertificates()", func() {
+		BeforeEach(func() {
+			app.Hostname = func() (string, error) { return "segment-a-0", nil }
+			Expect(vfs.MkdirAll(memoryfs, "/home/gpadmin", 0755)).To(Succeed())
+		})
+		It("does nothing when the interconnect-tls secret is not mounted", func() {
+			Expect(app.InstallInterconnectCertificates()).To(Succeed())
+			_, err := memoryfs.Stat("/home/gpadmin/.postgresql")
+			Expect(err).To(HaveOccurred())
+		})
+		When("the interconnect-tls secret is mounted", func() {
+			BeforeEach(func() {
+				Expect(vfs.MkdirAll(memoryfs, "/etc/interconnect-tls", 0755)).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/etc/interconnect-tls/ca.crt", []byte("i am ca.crt"), 0444)).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/etc/interconnect-tls/segment-a-0.crt", []byte("i am segment-a-0.crt"), 0444)).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/etc/interconnect-tls/segment-a-0.key", []byte("i am segment-a-0.key"), 0444)).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/etc/interconnect-tls/master-0.crt", []byte("i am master-0.crt"), 0444)).To(Succeed())
+			})
+			It("copies this pod's certificate, key and CA into ~/.postgresql", func() {
+				Expect(app.InstallInterconnectCertificates()).To(Succeed())
+				Expect(outBuffer).To(gbytes.Say(`"installing interconnect certificates"`))
+				for filename, content := range map[string]string{
+					"/home/gpadmin/.postgresql/postgresql.crt": "i am segment-a-0.crt",
+					"/home/gpadmin/.postgresql/postgresql.key": "i am segment-a-0.key",
+					"/home/gpadmin/.postgresql/root.crt":       "i am ca.crt",
+				} {
+					b, err := vfs.ReadFile(memoryfs, filename)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(b)).To(Equal(content))
+					fileInfo, err := memoryfs.Stat(filename)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fileInfo.Mode().Perm()).To(Equal(os.FileMode(0600)))
+				}
+			})
+			It("makes Greenplum processes started over ssh verify the servers they connect to", func() {
+				Expect(app.WriteContentsToBashrc()).To(Succeed())
+				b, err := vfs.ReadFile(memoryfs, "/home/gpadmin/.bashrc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(b)).To(ContainSubstring("export PGSSLMODE=verify-full\n"))
+			})
+			When("the segment has been initialized", func() {
+				BeforeEach(func() {
+					Expect(vfs.MkdirAll(memoryfs, "/greenplum/data", 0755)).To(Succeed())
+					Expect(vfs.WriteFile(memoryfs, "/greenplum/data/pg_hba.conf", []byte("local all gpadmin ident\n"), 0600)).To(Succeed())
+				})
+				It("requires interconnect certificates at the top of pg_hba.conf", func() {
+					Expect(app.InstallInterconnectCertificates()).To(Succeed())
+					b, err := vfs.ReadFile(memoryfs, "/greenplum/data/pg_hba.conf")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(b)).To(Equal("hostssl all all 0.0.0.0/0 trust clientcert=1\n" +
+						"hostssl all all ::/0 trust clientcert=1\n" +
+						"hostnossl all all 0.0.0.0/0 reject\n" +
+						"hostnossl all all ::/0 reject\n" +
+						"local all gpadmin ident\n"))
+				})
+				It("does not add the records twice", func() {
+					Expect(app.InstallInterconnectCertificates()).To(Succeed())
+					Expect(app.InstallInterconnectCertificates()).To(Succeed())
+					b, err := vfs.ReadFile(memoryfs, "/greenplum/data/pg_hba.conf")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(strings.Count(string(b), "hostnossl all all 0.0.0.0/0 reject")).To(Equal(1))
+				})
+			})
+			It("leaves pg_hba.conf on the master alone", func() {
+				app.Hostname = func() (string, error) { return "master-0", nil }
+				Expect(vfs.MkdirAll(memoryfs, "/greenplum/data-1", 0755)).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/greenplum/data-1/pg_hba.conf", []byte("local all gpadmin ident\n"), 0600)).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/etc/interconnect-tls/master-0.key", []byte("i am master-0.key"), 0444)).To(Succeed())
+				Expect(app.InstallInterconnectCertificates()).To(Succeed())
+				b, err := vfs.ReadFile(memoryfs, "/greenplum/data-1/pg_hba.conf")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(b)).To(Equal("local all gpadmin ident\n"))
+			})
+			It("returns an error when there is no certificate for this pod", func() {
+				app.Hostname = func() (string, error) { return "segment-b-0", nil }
+				Expect(app.InstallInterconnectCertificates()).To(MatchError(
+					"failed to read /etc/interconnect-tls/segment-b-0.crt, was the interconnect-tls secret mounted properly?"))
+			})
+			It("returns an error when the hostname is unavailable", func() {
+				app.Hostname = func() (string, error) { return "", errors.New("hostname failed") }
+				Expect(app.InstallInterconnectCertificates()).To(MatchError("hostname failed"))
+			})
+		})
+	})
+
 	Describe("on Run()", func() {
 		BeforeEach(func() {
 			// simulate ssh key files that are generated at deployment time (shared by all containers)
@@ -131,6 +216,34 @@ var _ = Describe("GpadminContainerStarter", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("failed to read /etc/config/pxfServiceName, was configMap mounted properly?"))
 		})
+		It("sets the umask when /etc/config/dataDirectoryUmask is not empty", func() {
+			Expect(vfs.WriteFile(memoryfs, "/etc/config/dataDirectoryUmask", []byte("0027"), 0644)).To(Succeed())
+
+			Expect(app.Run()).To(Succeed())
+			b, _ := vfs.ReadFile(memoryfs, "/home/gpadmin/.bashrc")
+			Expect(string(b)).To(ContainSubstring("umask 0027\n"))
+		})
+		It("does not set the umask when /etc/config/dataDirectoryUmask is empty", func() {
+			Expect(vfs.WriteFile(memoryfs, "/etc/config/dataDirectoryUmask", []byte(""), 0644)).To(Succeed())
+
+			Expect(app.Run()).To(Succeed())
+			b, _ := vfs.ReadFile(memoryfs, "/home/gpadmin/.bashrc")
+			Expect(string(b)).NotTo(ContainSubstring("umask"))
+		})
+		It("returns error when /etc/config/dataDirectoryUmask exists but fails to read", func() {
+			Expect(vfs.WriteFile(memoryfs, "/etc/config/dataDirectoryUmask", []byte("0027"), 0644)).To(Succeed())
+			fake := fileutil.HookableFilesystem{Filesystem: memoryfs}
+			fake.OpenFileHook = func(name string, flag int, perm os.FileMode) (vfs.File, error) {
+				if strings.Contains(name, "dataDirectoryUmask") {
+					return nil, errors.New("read failed on /etc/config/dataDirectoryUmask")
+				}
+				return memoryfs.OpenFile(name, flag, perm)
+			}
+			app.Fs = &fake
+
+			err := app.Run()
+			Expect(err).To(MatchError("failed to read /etc/config/dataDirectoryUmask, was configMap mounted properly?"))
+		})
 		It("fails on file writer error", func() {
 			fake := fileutil.HookableFilesystem{Filesystem: memoryfs}
 			app.Fs = &fake
@@ -240,6 +353,27 @@ var _ = Describe("GpadminContainerStarter", func() {
 			Expect(info.Name()).To(Equal(fileName))
 			Expect(info.Mode()).To(Equal(os.FileMode(0600)))
 		})
+
+		When("the gpadmin home has been persisted from a previous run", func() {
+			BeforeEach(func() {
+				Expect(app.Run()).To(Succeed())
+				Expect(vfs.WriteFile(memoryfs, "/home/gpadmin/.psql_history",
+					[]byte("select 1;\n"), 0600)).To(Succeed())
+			})
+			It("runs again successfully", func() {
+				Expect(app.Run()).To(Succeed())
+			})
+			It("does not insert into .bashrc twice", func() {
+				Expect(app.Run()).To(Succeed())
+				b, err := vfs.ReadFile(memoryfs, "/home/gpadmin/.bashrc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(strings.Count(string(b), "source /usr/local/greenplum-db/greenplum_path.sh\n")).To(Equal(1))
+			})
+			It("keeps the existing .psql_history", func() {
+				Expect(app.Run()).To(Succeed())
+				Expect("/home/gpadmin/.psql_history").To(EqualInFilesystem(memoryfs, "select 1;\n"))
+			})
+		})
 	})
 
 })
@@ -0,0 +1,60 @@
+package startContainerUtils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/blang/vfs"
+)
+
+const (
+	postmasterPidFilename = "postmaster.pid"
+	procDir               = "/proc"
+)
+
+// clearStaleLockFile removes the postmaster.pid left in the data directory by a postgres that did not shut down
+// cleanly, e.g. because its container was killed. The new container has its own PID namespace, so the PID in the
+// file can belong to an unrelated process, and postgres would refuse to start, taking it for another postmaster.
+// The file is only removed when the process it names is not a running postgres; anything unexpected in the file
+// leaves it in place for gpstart or pg_ctl to report.
+func (i *postgresInitializer) clearStaleLockFile() error {
+	fs := i.clusterStarter.Fs
+	lockFile := filepath.Join(i.dataDir, postmasterPidFilename)
+	contents, err := vfs.ReadFile(fs, lockFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading %s: %w", lockFile, err)
+	}
+
+	// The first two lines are the PID of the postmaster and its data directory
+	lines := strings.Split(string(contents), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || pid <= 0 {
+		Log.Info("leaving lock file in place: it does not start with a PID", "lockFile", lockFile)
+		return nil
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != i.dataDir {
+		Log.Info("leaving lock file in place: it is for another data directory", "lockFile", lockFile, "dataDir", strings.TrimSpace(lines[1]))
+		return nil
+	}
+
+	cmdline, err := vfs.ReadFile(fs, filepath.Join(procDir, strconv.Itoa(pid), "cmdline"))
+	if err == nil && strings.Contains(string(cmdline), "postgres") {
+		Log.Info("leaving lock file in place: it is held by a running postgres", "lockFile", lockFile, "pid", pid)
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("checking whether postgres (PID %d) is running: %w", pid, err)
+	}
+
+	if err := fs.Remove(lockFile); err != nil {
+		return fmt.Errorf("removing stale %s: %w", lockFile, err)
+	}
+	Log.Info("removed stale lock file", "lockFile", lockFile, "pid", pid)
+	return nil
+}
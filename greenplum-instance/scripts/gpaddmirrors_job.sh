@@ -0,0 +1,18 @@
+#!/usr/bin/env bash
+
+mkdir -p /home/gpadmin/.ssh
+ssh-keyscan -H "$GPADDMIRRORS_HOST" >> /home/gpadmin/.ssh/known_hosts
+# segment-b-N mirrors the primary on segment-a-N, at the same paths gpinitsystem uses for mirrors
+/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPADDMIRRORS_HOST" bash -s -- "$MIRROR_DOMAIN" <<'SCRIPT'
+set -euo pipefail
+source /usr/local/greenplum-db/greenplum_path.sh
+mirror_domain="$1"
+mirror_config=/tmp/gpaddmirrors_config
+: > "$mirror_config"
+for content in $(psql -d postgres -Atc "SELECT content FROM gp_segment_configuration WHERE role = 'p' AND content >= 0 ORDER BY content"); do
+    mirror_host="segment-b-${content}.${mirror_domain}"
+    ssh-keyscan -H "$mirror_host" >> /home/gpadmin/.ssh/known_hosts
+    echo "${content}|${mirror_host}|50000|/greenplum/mirror/data" >> "$mirror_config"
+done
+gpaddmirrors -a --hba-hostnames -i "$mirror_config"
+SCRIPT
@@ -0,0 +1,85 @@
+#!/usr/bin/env bash
+
+mkdir -p /home/gpadmin/.ssh
+ssh-keyscan -H "$GPBACKUP_HOST" >> /home/gpadmin/.ssh/known_hosts
+
+# Single-quote a value for YAML, where a single quote is escaped by doubling it
+yaml_quote() {
+    printf "'%s'" "${1//\'/\'\'}"
+}
+
+# The operator renders the plugin configuration, passed to gpbackup as --plugin-config, without the
+# encryption key or S3 credentials. Add the key from the mounted Secret and the credentials from the
+# environment, and write it to the master, readable only by gpadmin.
+plugin_config_path=/tmp/gpbackup_plugin_config.yaml
+encryption_key_file=/etc/gpbackup-encryption/encryption.key
+if [ -n "$GPBACKUP_PLUGIN_CONFIG" ]; then
+    plugin_config="$GPBACKUP_PLUGIN_CONFIG"
+    if [ -f "$encryption_key_file" ]; then
+        plugin_config+="  encryption_key: $(base64 -w 0 "$encryption_key_file")"$'\n'
+    fi
+    if [ -n "$GPBACKUP_S3_ACCESS_KEY_ID" ]; then
+        plugin_config+="  aws_access_key_id: $(yaml_quote "$GPBACKUP_S3_ACCESS_KEY_ID")"$'\n'
+        plugin_config+="  aws_secret_access_key: $(yaml_quote "$GPBACKUP_S3_SECRET_ACCESS_KEY")"$'\n'
+    fi
+    trap '/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" "rm -f $plugin_config_path"' EXIT
+    printf '%s' "$plugin_config" | /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+        "umask 077 && cat > $plugin_config_path" || exit 1
+fi
+
+# ssh hands the command to a remote shell, so quote the gpbackup arguments to preserve them as-is
+/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+    "source /usr/local/greenplum-db/greenplum_path.sh && gpbackup $(printf '%q ' "$@")" | tee /tmp/gpbackup.log
+gpbackup_status=${PIPESTATUS[0]}
+if [ "$gpbackup_status" -ne 0 ]; then
+    exit "$gpbackup_status"
+fi
+
+# The timestamp key of the backup, and the result of verifying it, go to the termination
+# message so that they can be recorded in status.
+timestamp=$(sed -n 's/.*Backup Timestamp = \([0-9]\{14\}\).*/\1/p' /tmp/gpbackup.log | head -n 1)
+if [ -z "$GPBACKUP_VERIFY_DATABASE" ]; then
+    printf 'timestamp=%s\n' "$timestamp" > /dev/termination-log
+    exit 0
+fi
+
+# Verify the backup by restoring it into a scratch database.
+if [ -z "$timestamp" ]; then
+    printf 'verification=failed\nmessage=%s\n' "could not find the backup timestamp in the gpbackup output" > /dev/termination-log
+    exit 1
+fi
+verify_db=$(printf '%q' "$GPBACKUP_VERIFY_DATABASE")
+restore_args=(--timestamp "$timestamp" --redirect-db "$GPBACKUP_VERIFY_DATABASE" --create-db)
+if [ -n "$GPBACKUP_BACKUP_DIR" ]; then
+    restore_args+=(--backup-dir "$GPBACKUP_BACKUP_DIR")
+fi
+if [ -n "$GPBACKUP_RESTORE_JOBS" ]; then
+    restore_args+=(--jobs "$GPBACKUP_RESTORE_JOBS")
+fi
+if [ "$GPBACKUP_RESTORE_WITH_STATS" = "true" ]; then
+    restore_args+=(--with-stats)
+fi
+if [ -n "$GPBACKUP_PLUGIN_CONFIG" ]; then
+    restore_args+=(--plugin-config "$plugin_config_path")
+fi
+# The operator only accepts a verify database named gpbackup_verify or gpbackup_verify_*, so dropping a leftover one
+# cannot drop a database it does not own. It is dropped again whether or not the restore succeeds.
+drop_verify_db() {
+    /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+        "source /usr/local/greenplum-db/greenplum_path.sh && dropdb --if-exists $verify_db"
+}
+if drop_verify_db && /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+    "source /usr/local/greenplum-db/greenplum_path.sh && gprestore $(printf '%q ' "${restore_args[@]}")"; then
+    verification=succeeded
+else
+    verification=failed
+fi
+if ! drop_verify_db; then
+    echo "could not drop the verify database $GPBACKUP_VERIFY_DATABASE" >&2
+fi
+if [ "$verification" = succeeded ]; then
+    printf 'verification=succeeded\ntimestamp=%s\n' "$timestamp" > /dev/termination-log
+else
+    printf 'verification=failed\ntimestamp=%s\nmessage=%s\n' "$timestamp" "gprestore into $GPBACKUP_VERIFY_DATABASE failed" > /dev/termination-log
+    exit 1
+fi
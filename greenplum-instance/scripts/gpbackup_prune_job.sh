@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+
+# Deletes the backups with the timestamps given as arguments from S3 with gpbackup_s3_plugin delete_backup.
+
+# Single-quote a value for YAML, where a single quote is escaped by doubling it
+yaml_quote() {
+    printf "'%s'" "${1//\'/\'\'}"
+}
+
+# The operator renders the plugin configuration without the S3 credentials. Add them from the environment,
+# and write it readable only by this job.
+plugin_config_path=/tmp/gpbackup_plugin_config.yaml
+plugin_config="$GPBACKUP_PLUGIN_CONFIG"
+plugin_config+="  aws_access_key_id: $(yaml_quote "$GPBACKUP_S3_ACCESS_KEY_ID")"$'\n'
+plugin_config+="  aws_secret_access_key: $(yaml_quote "$GPBACKUP_S3_SECRET_ACCESS_KEY")"$'\n'
+(umask 077 && printf '%s' "$plugin_config" > "$plugin_config_path") || exit 1
+trap 'rm -f "$plugin_config_path"' EXIT
+
+executable_path=$(sed -n 's/^executablepath: *//p' "$plugin_config_path")
+
+# Keep going past a failed deletion, so that one bad backup does not hold back the rest. The deleted
+# timestamps go to the termination message so that they can be recorded in status.
+pruned=()
+prune_status=0
+for timestamp in "$@"; do
+    if "$executable_path" delete_backup "$plugin_config_path" "$timestamp"; then
+        pruned+=("$timestamp")
+    else
+        echo "failed to delete backup $timestamp" >&2
+        prune_status=1
+    fi
+done
+printf 'pruned=%s\n' "$(IFS=,; echo "${pruned[*]}")" > /dev/termination-log
+exit "$prune_status"
@@ -0,0 +1,41 @@
+#!/usr/bin/env bash
+
+mkdir -p /home/gpadmin/.ssh
+ssh-keyscan -H "$GPCHECKPERF_HOST" >> /home/gpadmin/.ssh/known_hosts
+
+ssh_master() {
+    /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPCHECKPERF_HOST" "source /usr/local/greenplum-db/greenplum_path.sh && $1"
+}
+
+# Test every host with a primary or mirror segment
+hostfile=/tmp/gpcheckperf_hosts
+ssh_master "psql -d postgres -tAc 'SELECT DISTINCT hostname FROM gp_segment_configuration WHERE content >= 0' > $hostfile" || exit 1
+trap 'ssh_master "rm -f $hostfile"' EXIT
+
+# The operator reads the lowest throughput of any host from the termination message, in MB/s
+results=""
+
+if [ -n "$GPCHECKPERF_DISK_FILE_SIZE" ]; then
+    # gpcheckperf writes and reads its test file in a directory it creates under /greenplum, on the data volume
+    ssh_master "gpcheckperf -f $hostfile -r d -D -d /greenplum -S $GPCHECKPERF_DISK_FILE_SIZE" | tee /tmp/gpcheckperf_disk.log
+    [ "${PIPESTATUS[0]}" -eq 0 ] || exit 1
+    # the summary has lines like " disk write min bandwidth (MB/s): 180.75 [segment-a-1]"
+    for direction in write read; do
+        mbps=$(sed -n "s/.*disk $direction min bandwidth (MB\/s): *\([0-9.]*\).*/\1/p" /tmp/gpcheckperf_disk.log | head -n 1)
+        results+="disk_${direction}_mbps=$mbps"$'\n'
+    done
+fi
+
+if [ "$GPCHECKPERF_NETWORK" = "true" ]; then
+    if [ "$(ssh_master "wc -l < $hostfile")" -lt 2 ]; then
+        echo "skipping the network test, which needs at least two segment hosts"
+    else
+        ssh_master "gpcheckperf -f $hostfile -r N -d /tmp" | tee /tmp/gpcheckperf_network.log
+        [ "${PIPESTATUS[0]}" -eq 0 ] || exit 1
+        # the summary has a line like "min = 1093.09 MB/sec"
+        mbps=$(sed -n 's/^min = *\([0-9.]*\) MB\/sec.*/\1/p' /tmp/gpcheckperf_network.log | head -n 1)
+        results+="network_mbps=$mbps"$'\n'
+    fi
+fi
+
+printf '%s' "$results" > /dev/termination-log
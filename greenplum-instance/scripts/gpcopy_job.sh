@@ -0,0 +1,32 @@
+#!/usr/bin/env bash
+
+mkdir -p /home/gpadmin/.ssh
+ssh-keyscan -H "$GPCOPY_DEST_HOST" >> /home/gpadmin/.ssh/known_hosts
+
+# Escape a value for a field of a pgpass file, where : and \ are escaped with a backslash
+pgpass_escape() {
+    local value="${1//\\/\\\\}"
+    printf '%s' "${value//:/\\:}"
+}
+
+# Tables that already exist are truncated and reloaded rather than dropped, so that a copy that fails
+# part way leaves the objects of the standby, and their grants, in place
+gpcopy_args="--full --truncate --source-host $(printf '%q' "$GPCOPY_SOURCE_HOST") --source-port $(printf '%q' "$GPCOPY_SOURCE_PORT") --dest-host localhost"
+gpcopy_env=""
+if [ -n "$GPCOPY_SOURCE_USER" ]; then
+    gpcopy_args+=" --source-user $(printf '%q' "$GPCOPY_SOURCE_USER")"
+
+    # The password goes to the destination master on stdin, into a pgpass file readable only by gpadmin,
+    # so that it is not in the arguments of any process
+    pgpass_path=/tmp/gpcopy_pgpass
+    trap '/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPCOPY_DEST_HOST" "rm -f $pgpass_path"' EXIT
+    printf '%s:%s:*:%s:%s\n' \
+        "$(pgpass_escape "$GPCOPY_SOURCE_HOST")" "$GPCOPY_SOURCE_PORT" \
+        "$(pgpass_escape "$GPCOPY_SOURCE_USER")" "$(pgpass_escape "$GPCOPY_SOURCE_PASSWORD")" |
+        /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPCOPY_DEST_HOST" "umask 077 && cat > $pgpass_path" || exit 1
+    gpcopy_env="PGPASSFILE=$pgpass_path "
+fi
+
+# gpcopy runs on the destination master, pulling every database from the source master
+/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPCOPY_DEST_HOST" \
+    "source /usr/local/greenplum-db/greenplum_path.sh && ${gpcopy_env}gpcopy $gpcopy_args"
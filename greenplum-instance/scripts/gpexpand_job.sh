@@ -1,5 +1,10 @@
 #!/usr/bin/env bash
 
+runGpexpand_args=(--newPrimarySegmentCount "$NEW_SEG_COUNT")
+if [ "$REDISTRIBUTE" = "true" ]; then
+    runGpexpand_args+=(--redistribute)
+fi
+
 mkdir -p /home/gpadmin/.ssh
 ssh-keyscan -H "$GPEXPAND_HOST" >> /home/gpadmin/.ssh/known_hosts
-/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPEXPAND_HOST" /tools/runGpexpand --newPrimarySegmentCount "$NEW_SEG_COUNT"
+/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPEXPAND_HOST" /tools/runGpexpand "${runGpexpand_args[@]}"
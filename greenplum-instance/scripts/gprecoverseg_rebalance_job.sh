@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+
+mkdir -p /home/gpadmin/.ssh
+ssh-keyscan -H "$GPRECOVERSEG_HOST" >> /home/gpadmin/.ssh/known_hosts
+/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPRECOVERSEG_HOST" \
+    "source /usr/local/greenplum-db/greenplum_path.sh && gprecoverseg -a -r"
@@ -0,0 +1,53 @@
+#!/usr/bin/env bash
+
+mkdir -p /home/gpadmin/.ssh
+ssh-keyscan -H "$GPBACKUP_HOST" >> /home/gpadmin/.ssh/known_hosts
+
+# Single-quote a value for YAML, where a single quote is escaped by doubling it
+yaml_quote() {
+    printf "'%s'" "${1//\'/\'\'}"
+}
+
+# Record why the restore failed in the termination message, so that it can be shown in status
+fail() {
+    printf 'error=%s\n' "$1" > /dev/termination-log
+    exit "${2:-1}"
+}
+
+# The operator renders the plugin configuration, passed to gprestore as --plugin-config, without the
+# encryption key or S3 credentials. Add the key from the mounted Secret and the credentials from the
+# environment, and write it to the master, readable only by gpadmin.
+plugin_config_path=/tmp/gprestore_plugin_config.yaml
+encryption_key_file=/etc/gpbackup-encryption/encryption.key
+plugin_config="$GPBACKUP_PLUGIN_CONFIG"
+if [ -f "$encryption_key_file" ]; then
+    plugin_config+="  encryption_key: $(base64 -w 0 "$encryption_key_file")"$'\n'
+fi
+plugin_config+="  aws_access_key_id: $(yaml_quote "$GPBACKUP_S3_ACCESS_KEY_ID")"$'\n'
+plugin_config+="  aws_secret_access_key: $(yaml_quote "$GPBACKUP_S3_SECRET_ACCESS_KEY")"$'\n'
+trap '/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" "rm -f $plugin_config_path"' EXIT
+printf '%s' "$plugin_config" | /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+    "umask 077 && cat > $plugin_config_path" || fail "could not write the plugin configuration to $GPBACKUP_HOST"
+
+# The operator only asks for the database, or the restored schemas or tables, to be dropped when
+# the restore was allowed to overwrite them.
+database=$(printf '%q' "$GPRESTORE_DATABASE")
+if [ "$GPRESTORE_DROP_DATABASE" = "true" ]; then
+    /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+        "source /usr/local/greenplum-db/greenplum_path.sh && dropdb --if-exists $database" ||
+        fail "could not drop database $GPRESTORE_DATABASE to overwrite it; check that no clients are connected"
+elif [ -n "$GPRESTORE_DROP_SQL" ]; then
+    /usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+        "source /usr/local/greenplum-db/greenplum_path.sh && psql -v ON_ERROR_STOP=1 -d $database -c $(printf '%q' "$GPRESTORE_DROP_SQL")" ||
+        fail "could not drop the restored schemas or tables in database $GPRESTORE_DATABASE to overwrite them"
+fi
+
+# ssh hands the command to a remote shell, so quote the gprestore arguments to preserve them as-is
+/usr/bin/ssh -i /etc/ssh-key/id_rsa "$GPBACKUP_HOST" \
+    "source /usr/local/greenplum-db/greenplum_path.sh && gprestore $(printf '%q ' "$@")" | tee /tmp/gprestore.log
+gprestore_status=${PIPESTATUS[0]}
+if [ "$gprestore_status" -ne 0 ]; then
+    # gprestore logs lines like "<time> gprestore:gpadmin:<host>:<pid>-[CRITICAL]:-<message>"
+    error=$(sed -n 's/.*-\[\(CRITICAL\|ERROR\)\]:-//p' /tmp/gprestore.log | tail -n 1)
+    fail "${error:-gprestore exited with status $gprestore_status}" "$gprestore_status"
+fi
@@ -0,0 +1,296 @@
+/*
+.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GreenplumBackupOptions are the options rendered into the gpbackup command line
+type GreenplumBackupOptions struct {
+	// Database to back up. Defaults to gpadmin
+	Database string `json:"database,omitempty"`
+
+	// Tables to back up, each in the form <schema>.<table>. Cannot be combined with excludeTables
+	IncludeTables []string `json:"includeTables,omitempty"`
+
+	// Tables to leave out of the backup, each in the form <schema>.<table>. Cannot be combined with includeTables
+	ExcludeTables []string `json:"excludeTables,omitempty"`
+
+	// Schemas to back up, e.g. those of a single tenant. Cannot be combined with excludeSchemas or includeTables
+	IncludeSchemas []string `json:"includeSchemas,omitempty"`
+
+	// Schemas to leave out of the backup. Cannot be combined with includeSchemas, includeTables or excludeTables
+	ExcludeSchemas []string `json:"excludeSchemas,omitempty"`
+
+	// SingleDataFile writes all of a segment's data to a single file instead of one file per table,
+	// as preferred by some storage plugins such as DD Boost. Both a backupDir and a storage plugin, such as the
+	// S3 destination of a GreenplumBackup, can take a single data file. Verifying it cannot use more than one restore job
+	SingleDataFile bool `json:"singleDataFile,omitempty"`
+
+	// Number of COPY commands gpbackup queues per segment when writing a single data file,
+	// which can improve throughput to object storage. Requires singleDataFile
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	CopyQueueSize int32 `json:"copyQueueSize,omitempty"`
+
+	// gzip compression level for the backup files, from 1 (fastest) to 9 (smallest). Defaults to the gpbackup default.
+	// Cannot be combined with noCompression
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9
+	CompressionLevel int32 `json:"compressionLevel,omitempty"`
+
+	// NoCompression writes the backup files uncompressed, e.g. for a storage plugin or destination that compresses them itself
+	NoCompression bool `json:"noCompression,omitempty"`
+
+	// LeafPartitionData backs up each leaf partition of a partitioned table to its own file, so that
+	// individual partitions can be restored. Cannot be combined with metadataOnly
+	LeafPartitionData bool `json:"leafPartitionData,omitempty"`
+
+	// WithStats includes the optimizer statistics in the backup, so that a restore does not need to analyze the tables.
+	// Verification restores them too. Cannot be combined with dataOnly
+	WithStats bool `json:"withStats,omitempty"`
+
+	// MetadataOnly backs up only the schema, without any table data. Cannot be combined with dataOnly, singleDataFile or leafPartitionData
+	MetadataOnly bool `json:"metadataOnly,omitempty"`
+
+	// DataOnly backs up only table data, without the schema. Cannot be combined with metadataOnly or verify,
+	// since restoring it needs the tables to exist already
+	DataOnly bool `json:"dataOnly,omitempty"`
+
+	// Image for the backup job, e.g. one that bundles a gpbackup storage plugin. Defaults to the Greenplum instance image.
+	// The image must provide /home/gpadmin/tools/gpbackup_job.sh
+	PluginImage string `json:"pluginImage,omitempty"`
+
+	// Verify restores the backup into a scratch database once gpbackup completes, to check that it can be restored
+	Verify bool `json:"verify,omitempty"`
+
+	// Scratch database used to verify the backup. It is dropped before and after verification, so it must be
+	// gpbackup_verify, the default, or start with gpbackup_verify_. Requires verify
+	VerifyDatabase string `json:"verifyDatabase,omitempty"`
+
+	// Number of parallel connections gprestore uses to restore the backup when verifying it. Defaults to 1.
+	// Requires verify
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	RestoreJobs int32 `json:"restoreJobs,omitempty"`
+
+	// Absolute path that gpbackup writes to instead of the segment data directories, e.g. an NFS mount.
+	// gpbackup runs on the Greenplum hosts, so the path must be reachable there as well
+	BackupDir string `json:"backupDir,omitempty"`
+
+	// Volume mounted at backupDir in the backup job, e.g. the NFS share backing it. Requires backupDir
+	BackupDirVolume *corev1.VolumeSource `json:"backupDirVolume,omitempty"`
+
+	// Optional bundle of CA certificates to trust in the backup job, e.g. for a storage plugin writing to S3
+	CABundle *GreenplumCABundleSpec `json:"caBundle,omitempty"`
+
+	// Storage plugin that gpbackup writes the backup through, e.g. gpbackup_s3_plugin. The operator generates
+	// the plugin configuration file; gpbackup copies it to the segment hosts
+	Plugin *GreenplumBackupPluginSpec `json:"plugin,omitempty"`
+}
+
+// GreenplumBackupPluginSpec is rendered into the gpbackup plugin configuration file
+type GreenplumBackupPluginSpec struct {
+	// Path of the plugin executable on the Greenplum hosts, e.g. /usr/local/greenplum-db/bin/gpbackup_s3_plugin
+	// +kubebuilder:validation:MinLength=1
+	ExecutablePath string `json:"executablePath"`
+
+	// Options passed to the plugin in the options section of its configuration file, e.g. bucket and folder
+	Options map[string]string `json:"options,omitempty"`
+
+	// Optional encryption of the backup files at rest, with a key from a Secret
+	Encryption *GreenplumBackupEncryptionSpec `json:"encryption,omitempty"`
+}
+
+// GreenplumBackupEncryptionSpec adds the encryption_mode and encryption_key options to the plugin configuration
+type GreenplumBackupEncryptionSpec struct {
+	// server-side has the storage encrypt the files with the key, e.g. S3 SSE-C;
+	// client-side has the plugin encrypt them before they leave the Greenplum hosts
+	// +kubebuilder:validation:Enum=server-side;client-side
+	Mode string `json:"mode"`
+
+	// Name of a Secret in the same namespace holding the 256-bit key
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// Key of the encryption key in the Secret. Defaults to encryption.key
+	Key string `json:"key,omitempty"`
+}
+
+// GreenplumBackupVerificationStatus records the result of verifying a backup
+type GreenplumBackupVerificationStatus struct {
+	// Timestamp of the verified backup, as reported by gpbackup
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// Succeeded is true if the backup was restored into the scratch database without errors
+	Succeeded bool `json:"succeeded"`
+
+	// Message describes why verification failed
+	Message string `json:"message,omitempty"`
+}
+
+type GreenplumBackupMode string
+
+const (
+	GreenplumBackupModeFull         GreenplumBackupMode = "full"
+	GreenplumBackupModeMetadataOnly GreenplumBackupMode = "metadata-only"
+	GreenplumBackupModeDataOnly     GreenplumBackupMode = "data-only"
+)
+
+type GreenplumBackupSpec struct {
+	// Name of the GreenplumCluster in the same namespace to back up. Backups of the same cluster run one at a time,
+	// in the order they were created
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+
+	// full backs up the schema and the table data; metadata-only backs up only the schema; data-only backs up only
+	// the table data, which can only be restored into tables that already exist
+	// +kubebuilder:default=full
+	// +kubebuilder:validation:Enum=full;metadata-only;data-only
+	Mode GreenplumBackupMode `json:"mode,omitempty"`
+
+	// gpbackup options. The destination is either s3 or backupDir, and backupDir cannot be used with s3.
+	// The S3 destination sets plugin, and mode sets metadataOnly and dataOnly, so none of them can be set here
+	GreenplumBackupOptions `json:",inline"`
+
+	// S3 destination that gpbackup writes the backup to with gpbackup_s3_plugin. Required unless backupDir is set
+	S3 *GreenplumBackupS3Spec `json:"s3,omitempty"`
+
+	// Seconds to keep the backup job once the backup has finished, after which the operator deletes it.
+	// The job is kept when unset
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// Retention policy for the backups in the S3 folder. Once this backup succeeds, a prune job deletes the
+	// backups in the folder that are past the policy. This backup itself is always kept. Requires s3
+	Retention *GreenplumBackupRetentionSpec `json:"retention,omitempty"`
+}
+
+// GreenplumBackupRetentionSpec limits the backups kept in an S3 folder. A backup past either limit is deleted
+type GreenplumBackupRetentionSpec struct {
+	// Number of the most recent backups to keep
+	// +kubebuilder:validation:Minimum=1
+	MaxCount *int32 `json:"maxCount,omitempty"`
+
+	// Age, by its timestamp key, after which a backup is deleted, e.g. 720h
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// GreenplumBackupS3Spec is rendered into the options of the gpbackup_s3_plugin configuration
+type GreenplumBackupS3Spec struct {
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Folder in the bucket that gpbackup_s3_plugin writes the backups under
+	// +kubebuilder:validation:MinLength=1
+	Prefix string `json:"prefix"`
+
+	// +kubebuilder:validation:MinLength=1
+	Region string `json:"region"`
+
+	// Endpoint of an S3-compatible object store, e.g. https://minio.example.com. Defaults to AWS S3
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Secret in the same namespace holding the access key of an identity that can write to the bucket
+	Credentials GreenplumBackupS3CredentialsSpec `json:"credentials"`
+
+	// Optional encryption of the backup files at rest, with a key from a Secret. A backup is restored with the same
+	// encryption, so a GreenplumRestore of an encrypted backup must set it too
+	Encryption *GreenplumBackupEncryptionSpec `json:"encryption,omitempty"`
+}
+
+type GreenplumBackupS3CredentialsSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// Key of the access key ID in the Secret. Defaults to aws_access_key_id
+	AccessKeyIDKey string `json:"accessKeyIDKey,omitempty"`
+
+	// Key of the secret access key in the Secret. Defaults to aws_secret_access_key
+	SecretAccessKeyKey string `json:"secretAccessKeyKey,omitempty"`
+}
+
+type GreenplumBackupPhase string
+
+const (
+	// The backup waits for its cluster to be Running, or for earlier backups of the cluster to finish
+	GreenplumBackupPhasePending   GreenplumBackupPhase = "Pending"
+	GreenplumBackupPhaseRunning   GreenplumBackupPhase = "Running"
+	GreenplumBackupPhaseSucceeded GreenplumBackupPhase = "Succeeded"
+	GreenplumBackupPhaseFailed    GreenplumBackupPhase = "Failed"
+)
+
+const (
+	// GreenplumBackupConditionComplete is True once the backup has succeeded
+	GreenplumBackupConditionComplete = "Complete"
+
+	// GreenplumBackupConditionFailed is True once the backup has failed; its message says why
+	GreenplumBackupConditionFailed = "Failed"
+
+	// GreenplumBackupConditionPruned is set once the retention policy has been enforced: True if every backup
+	// past the policy was deleted, False if the prune job failed
+	GreenplumBackupConditionPruned = "Pruned"
+)
+
+type GreenplumBackupStatus struct {
+	Phase GreenplumBackupPhase `json:"phase,omitempty"`
+
+	// Why the backup is pending
+	Message string `json:"message,omitempty"`
+
+	// Name of the job running gpbackup
+	JobName string `json:"jobName,omitempty"`
+
+	// Timestamp key of the backup, as reported by gpbackup, to pass to gprestore --timestamp
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// Total size in bytes of the objects that the backup wrote to S3. Not measured for a backup to backupDir
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// Result of restoring the backup into a scratch database, when spec.verify is set
+	Verification *GreenplumBackupVerificationStatus `json:"verification,omitempty"`
+
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Timestamp keys of the backups that the retention policy deleted from the S3 folder
+	PrunedBackups []string `json:"prunedBackups,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=all
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`,description="The GreenplumCluster backed up"
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`,description="full, metadata-only or data-only"
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`,description="The backup status"
+// +kubebuilder:printcolumn:name="Timestamp",type=string,JSONPath=`.status.timestamp`,description="The gpbackup timestamp key"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="The backup age"
+
+// GreenplumBackup is the Schema for the greenplumbackups API
+type GreenplumBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GreenplumBackupSpec   `json:"spec,omitempty"`
+	Status GreenplumBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GreenplumBackupList contains a list of GreenplumBackup
+type GreenplumBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GreenplumBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GreenplumBackup{}, &GreenplumBackupList{})
+}
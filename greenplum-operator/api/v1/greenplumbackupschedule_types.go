@@ -0,0 +1,82 @@
+/*
+.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type GreenplumBackupScheduleSpec struct {
+	// Cron expression, in UTC, of when to create a GreenplumBackup, e.g. "0 2 * * *" or @daily.
+	// A scheduled run is skipped while the previous backup is still in progress
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Suspend stops the schedule from creating backups. Backups already created are not affected
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Retention policy for the backups created by the schedule. The operator deletes the GreenplumBackups
+	// past the policy, along with their files in S3
+	Retention *GreenplumBackupScheduleRetentionSpec `json:"retention,omitempty"`
+
+	// Spec of the GreenplumBackups created by the schedule
+	Template GreenplumBackupSpec `json:"template"`
+}
+
+// GreenplumBackupScheduleRetentionSpec limits the backups kept by a schedule. Succeeded and failed backups are
+// counted separately, so that failed runs do not push out good backups. A backup past either limit is deleted
+type GreenplumBackupScheduleRetentionSpec struct {
+	// Number of the most recent backups to keep
+	// +kubebuilder:validation:Minimum=1
+	KeepLast *int32 `json:"keepLast,omitempty"`
+
+	// Days to keep a backup after it was created
+	// +kubebuilder:validation:Minimum=1
+	KeepDays *int32 `json:"keepDays,omitempty"`
+}
+
+type GreenplumBackupScheduleStatus struct {
+	// Time of the last scheduled run, whether it created a backup or was skipped
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Completion time of the most recent backup created by the schedule that succeeded
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// Time of the next scheduled run. Unset while the schedule is suspended
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// Name of the backup created by the schedule that is still in progress
+	Active string `json:"active,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=all
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.template.clusterName`,description="The GreenplumCluster backed up"
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`,description="The cron expression"
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`,description="Whether the schedule is suspended"
+// +kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`,description="The last scheduled run"
+// +kubebuilder:printcolumn:name="Next Schedule",type=string,JSONPath=`.status.nextScheduleTime`,description="The next scheduled run"
+
+// GreenplumBackupSchedule is the Schema for the greenplumbackupschedules API
+type GreenplumBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GreenplumBackupScheduleSpec   `json:"spec,omitempty"`
+	Status GreenplumBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GreenplumBackupScheduleList contains a list of GreenplumBackupSchedule
+type GreenplumBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GreenplumBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GreenplumBackupSchedule{}, &GreenplumBackupScheduleList{})
+}
@@ -5,6 +5,7 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -17,6 +18,314 @@ type GreenplumClusterSpec struct {
 	MasterAndStandby GreenplumMasterAndStandbySpec `json:"masterAndStandby"`
 	Segments         GreenplumSegmentsSpec         `json:"segments"`
 	PXF              GreenplumPXFSpec              `json:"pxf,omitempty"`
+	Config           GreenplumConfigSpec           `json:"config,omitempty"`
+
+	// Name of the scheduler for the cluster's pods and jobs, e.g. volcano. Defaults to the default scheduler
+	SchedulerName string `json:"schedulerName,omitempty"`
+
+	// SQL smoke check run against the gpadmin database on the active master once the cluster is up, e.g.
+	// SELECT count(*) FROM gp_dist_random('gp_id'). The cluster stays Pending until the query succeeds
+	ReadinessQuery string `json:"readinessQuery,omitempty"`
+
+	// Kernel parameters to set in the securityContext of the cluster's pods, e.g. net.ipv4.ip_local_port_range.
+	// Unsafe sysctls must be listed in the namespace's greenplum.pivotal.io/allowed-unsafe-sysctls annotation
+	Sysctls []corev1.Sysctl `json:"sysctls,omitempty"`
+
+	// Makes this cluster a warm standby for disaster recovery by periodically copying the databases of a primary
+	// cluster into it with gpcopy. Each copy truncates and reloads the tables of this cluster, so writes to it are
+	// overwritten until it is promoted
+	DisasterRecovery *GreenplumDisasterRecoverySpec `json:"disasterRecovery,omitempty"`
+
+	// Optional persistent volume on every Greenplum pod for a temp tablespace, which is
+	// created when the cluster is initialized and set as temp_tablespaces for spill files
+	TempTablespace *GreenplumTempTablespaceSpec `json:"tempTablespace,omitempty"`
+
+	// Optional emptyDir on every Greenplum pod's node for spill files, used for the temp tablespace in place of a
+	// persistent volume. Its contents are lost when a pod restarts. Cannot be combined with tempTablespace
+	SpillVolume *GreenplumSpillVolumeSpec `json:"spillVolume,omitempty"`
+
+	// Optional bundle of CA certificates to trust in Greenplum pods, for TLS connections to external services such as S3 or LDAP
+	CABundle *GreenplumCABundleSpec `json:"caBundle,omitempty"`
+
+	// Optional script from a ConfigMap that every Greenplum pod runs as gpadmin when it starts, before Postgres is
+	// initialized or started, e.g. to mount extra storage or fetch configuration. The pod does not start Postgres
+	// if the script fails or runs for longer than 5 minutes
+	EntrypointScript *GreenplumEntrypointScriptSpec `json:"entrypointScript,omitempty"`
+
+	// Optional TLS between the master and the segments, using per-pod certificates signed by a CA that the operator
+	// manages and rotates. This encrypts the libpq connections the master dispatches queries over; Greenplum has no
+	// TLS for the UDP motion traffic between segments
+	InterconnectTLS *GreenplumInterconnectTLSSpec `json:"interconnectTLS,omitempty"`
+
+	// Optional TLS for client connections to the master, with a certificate from a Secret. Disabled by default.
+	// Once enabled it cannot be disabled again
+	TLS *GreenplumTLSSpec `json:"tls,omitempty"`
+
+	// Optional authentication of client connections against an external service
+	Auth *GreenplumAuthSpec `json:"auth,omitempty"`
+
+	// Optional monitoring of the cluster
+	Monitoring *GreenplumMonitoringSpec `json:"monitoring,omitempty"`
+}
+
+type GreenplumMonitoringSpec struct {
+	// Optional Prometheus exporter
+	Prometheus *GreenplumPrometheusSpec `json:"prometheus,omitempty"`
+
+	// Optional periodic gpcheckperf runs across the segment hosts, which set the PerformanceDegraded condition
+	// when disk or network throughput falls below the thresholds
+	PerfCheck *GreenplumPerfCheckSpec `json:"perfCheck,omitempty"`
+}
+
+// GreenplumPerfCheckSpec sets how often gpcheckperf runs, and the minimum throughput of any segment host.
+// At least one threshold must be set; only the tests with a threshold are run
+type GreenplumPerfCheckSpec struct {
+	// Interval between runs, e.g. 24h. Defaults to 24h, and must be at least 1h: every run loads the disks
+	// and the network of all the segment hosts
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Size of the file that the disk test writes and reads on each segment host. Defaults to 1Gi
+	DiskFileSize *resource.Quantity `json:"diskFileSize,omitempty"`
+
+	// Minimum disk write throughput of any segment host, in MB/s
+	// +kubebuilder:validation:Minimum=1
+	MinDiskWriteMBps int32 `json:"minDiskWriteMBps,omitempty"`
+
+	// Minimum disk read throughput of any segment host, in MB/s
+	// +kubebuilder:validation:Minimum=1
+	MinDiskReadMBps int32 `json:"minDiskReadMBps,omitempty"`
+
+	// Minimum network throughput between any pair of segment hosts, in MB/s. Needs at least two segment hosts
+	// +kubebuilder:validation:Minimum=1
+	MinNetworkMBps int32 `json:"minNetworkMBps,omitempty"`
+}
+
+type GreenplumPrometheusSpec struct {
+	// Run postgres_exporter as a sidecar of the master pods. It queries the master as gpadmin, with the password in
+	// the greenplum-connection Secret, and exports connection counts, replication lag, segments up and down and the
+	// free disk space of each segment host. The pods that run it are exposed on the greenplum-exporter headless
+	// Service, annotated for Prometheus to scrape. Adding or removing the sidecar restarts the pods of a role once;
+	// the Greenplum container itself is not changed. The operator sets a gpadmin password if the Secret does not
+	// exist yet
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Also run the exporter as a sidecar of the segment pods of each role, exporting the connections, replication
+	// lag and disk usage of the segment in the pod
+	Segments *GreenplumPrometheusSegmentsSpec `json:"segments,omitempty"`
+
+	// Image of the exporter. Defaults to quay.io/prometheuscommunity/postgres-exporter:v0.11.1
+	Image string `json:"image,omitempty"`
+
+	// Optional ConfigMap with additional queries, in the queries.yaml format of postgres_exporter
+	CustomQueries *GreenplumCustomQueriesSpec `json:"customQueries,omitempty"`
+
+	// Generate the greenplum-dashboard ConfigMap with a Grafana dashboard of the exported metrics, labeled
+	// grafana_dashboard: "1" for the Grafana dashboard sidecar to import it
+	GrafanaDashboard bool `json:"grafanaDashboard,omitempty"`
+}
+
+type GreenplumPrometheusSegmentsSpec struct {
+	// Run the exporter beside the primary segments, in the segment-a pods
+	Primary bool `json:"primary,omitempty"`
+
+	// Run the exporter beside the mirror segments, in the segment-b pods. A mirror rejects connections, so its
+	// exporter only reports it as down until the segment takes over as a primary
+	Mirror bool `json:"mirror,omitempty"`
+}
+
+type GreenplumCustomQueriesSpec struct {
+	// Name of a ConfigMap in the same namespace holding the queries
+	// +kubebuilder:validation:MinLength=1
+	ConfigMapName string `json:"configMapName"`
+
+	// Key of the queries in the ConfigMap. Defaults to queries.yaml. Query names must not start with greenplum_,
+	// which is reserved for the built-in queries
+	Key string `json:"key,omitempty"`
+}
+
+type GreenplumAuthSpec struct {
+	// Optional LDAP authentication, which the operator adds to pg_hba.conf on the master and standby, after the
+	// entries created when the cluster is initialized and before config.pgHbaEntries
+	LDAP *GreenplumLDAPSpec `json:"ldap,omitempty"`
+}
+
+type GreenplumLDAPSpec struct {
+	// URL of the LDAP server, e.g. ldap://ldap.example.com:389. Greenplum 6 does not support ldaps://; use tls.startTLS
+	// to encrypt the connection instead
+	// +kubebuilder:validation:MinLength=1
+	ServerURL string `json:"serverURL"`
+
+	// simpleBind binds as the DN made from bindDNTemplate and the user name. searchBind searches baseDN for the
+	// user's entry and binds as that entry
+	// +kubebuilder:validation:Enum=simpleBind;searchBind
+	Mode string `json:"mode"`
+
+	// The DN to bind as in simpleBind mode, with $username in place of the user name,
+	// e.g. uid=$username,ou=people,dc=example,dc=com
+	BindDNTemplate string `json:"bindDNTemplate,omitempty"`
+
+	// The DN to search for users under in searchBind mode
+	BaseDN string `json:"baseDN,omitempty"`
+
+	// The attribute matched against the user name in searchBind mode. Defaults to uid
+	SearchAttribute string `json:"searchAttribute,omitempty"`
+
+	// The DN to bind as for the search in searchBind mode. The search is anonymous if unset
+	SearchBindDN string `json:"searchBindDN,omitempty"`
+
+	// The password for searchBindDN, from a Secret
+	SearchBindPassword *GreenplumLDAPBindPasswordSpec `json:"searchBindPassword,omitempty"`
+
+	// Optional TLS for the connection to the LDAP server. The server certificate must be signed by a CA in caBundle
+	TLS *GreenplumLDAPTLSSpec `json:"tls,omitempty"`
+
+	// Roles that authenticate with LDAP. All roles but gpadmin when empty
+	Roles []string `json:"roles,omitempty"`
+
+	// Create the roles that do not exist in Greenplum, with LOGIN. Requires roles to be listed
+	CreateMissingRoles bool `json:"createMissingRoles,omitempty"`
+}
+
+type GreenplumLDAPBindPasswordSpec struct {
+	// Name of a Secret in the same namespace holding the password
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// Key of the password in the Secret. Defaults to password
+	Key string `json:"key,omitempty"`
+}
+
+type GreenplumLDAPTLSSpec struct {
+	// Upgrade the connection with StartTLS before binding
+	StartTLS bool `json:"startTLS,omitempty"`
+}
+
+type GreenplumTLSSpec struct {
+	// Name of a Secret in the same namespace holding the master certificate in server.crt and its key in server.key,
+	// and optionally a CA for verifying client certificates in ca.crt. The certificate must be valid for the
+	// greenplum Service, greenplum.<namespace>.svc.cluster.local. Greenplum 6 only loads the certificate on startup,
+	// so a new or renewed certificate takes effect once Greenplum restarts
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// Whether the operator restarts Greenplum as soon as the certificate or the ssl GUCs change. Otherwise it
+	// sets the TLSRestartPending condition and waits for the restart-for-tls annotation, so that the restart,
+	// which disconnects every session, can be done in a maintenance window
+	AutomaticRestart bool `json:"automaticRestart,omitempty"`
+}
+
+type GreenplumInterconnectTLSSpec struct {
+	// How long the pod certificates are valid for, e.g. 720h. Defaults to 2160h (90 days).
+	// The operator reissues them once a third of the validity is left; pods pick them up when they restart
+	CertificateValidity *metav1.Duration `json:"certificateValidity,omitempty"`
+}
+
+type GreenplumEntrypointScriptSpec struct {
+	// Name of a ConfigMap in the same namespace holding the script
+	// +kubebuilder:validation:MinLength=1
+	ConfigMapName string `json:"configMapName"`
+
+	// Key of the script in the ConfigMap. Defaults to entrypoint.sh. The script must start with a #! line
+	Key string `json:"key,omitempty"`
+}
+
+type GreenplumCABundleSpec struct {
+	// Name of a ConfigMap in the same namespace holding PEM-encoded CA certificates
+	// +kubebuilder:validation:MinLength=1
+	ConfigMapName string `json:"configMapName"`
+
+	// Key of the bundle in the ConfigMap. Defaults to ca.crt
+	Key string `json:"key,omitempty"`
+}
+
+// ConnectionSecretName is the Secret in which the operator stores the gpadmin credentials, in the username and
+// password keys, once it has set a generated gpadmin password
+const ConnectionSecretName = "greenplum-connection"
+
+// AllowedUnsafeSysctlsAnnotation is the namespace annotation listing the unsafe sysctls that
+// GreenplumClusters in the namespace may set, separated by commas. A trailing * matches any suffix, e.g. kernel.shm*
+const AllowedUnsafeSysctlsAnnotation = "greenplum.pivotal.io/allowed-unsafe-sysctls"
+
+// RebalanceAnnotation on a GreenplumCluster asks the operator to return segments that are not in their
+// preferred roles, e.g. after gprecoverseg recovered failed primaries as mirrors, with gprecoverseg -r.
+// The operator removes it once it starts the rebalance job, or finds the segments already balanced.
+const RebalanceAnnotation = "greenplum.pivotal.io/rebalance"
+
+// ReinitializeStandbyAnnotation on a GreenplumCluster asks the operator to make the master pod that was
+// fenced by an automatic failover the new standby, with gpinitstandby, once the pod is ready again.
+// The operator removes it once the standby is initialized.
+const ReinitializeStandbyAnnotation = "greenplum.pivotal.io/reinitialize-standby"
+
+// RestartForTLSAnnotation on a GreenplumCluster lets the operator restart Greenplum to load the certificate
+// in spec.tls while the TLSRestartPending condition is True. The operator removes it once Greenplum is restarted.
+const RestartForTLSAnnotation = "greenplum.pivotal.io/restart-for-tls"
+
+// AdoptAnnotation on a new GreenplumCluster asks the operator to take over the Greenplum cluster already running
+// in the namespace, e.g. one whose GreenplumCluster was deleted with its dependents orphaned, rather than initialize
+// a new one. The operator removes it once its StatefulSets have taken over the pods and data volumes of the cluster.
+const AdoptAnnotation = "greenplum.pivotal.io/adopt"
+
+type GreenplumConfigSpec struct {
+	// Greenplum configuration parameters (GUCs) to set when the cluster is initialized
+	GUCs map[string]string `json:"gucs,omitempty"`
+
+	// Umask applied to gpadmin processes that create the Greenplum data directories, e.g. 0077
+	// +kubebuilder:validation:Pattern=`^(?:0?[0-7]{3}|)$`
+	DataDirectoryUmask string `json:"dataDirectoryUmask,omitempty"`
+
+	// ARRAY_NAME given to gpinitsystem when the cluster is initialized. Defaults to the gpinitsystem default
+	// +kubebuilder:validation:Pattern=`^(?:[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}|)$`
+	ArrayName string `json:"arrayName,omitempty"`
+
+	// Maximum number of concurrent connections for each named role, applied with ALTER ROLE ... CONNECTION LIMIT.
+	// -1 removes the limit. Roles that do not exist yet are limited once they are created
+	RoleConnectionLimits map[string]int32 `json:"roleConnectionLimits,omitempty"`
+
+	// YES or NO, specify whether or not to preload pg_stat_statements and create the extension when the cluster is initialized
+	// +kubebuilder:default="no"
+	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
+	PgStatStatements string `json:"pgStatStatements,omitempty"`
+
+	// Session defaults for each named role, e.g. search_path, applied with ALTER ROLE ... SET when the cluster is initialized.
+	// The roles must exist by then, which leaves gpadmin and the roles of resourceQueues unless the image creates others
+	RoleSettings map[string]map[string]string `json:"roleSettings,omitempty"`
+
+	// Extensions to create in the gpadmin database when the cluster is initialized, e.g. pgcrypto. Extensions added
+	// later are created by the operator; removing one from the list does not drop it. Only extensions shipped with
+	// Greenplum that need no preloading are allowed
+	Extensions []string `json:"extensions,omitempty"`
+
+	// Resource queues to create when the cluster is initialized. They require gucs gp_resource_manager to be queue,
+	// in place of the resource groups that are used by default, and cannot be changed afterwards
+	ResourceQueues []GreenplumResourceQueueSpec `json:"resourceQueues,omitempty"`
+
+	// pg_hba.conf lines, e.g. "host all app_user 10.0.0.0/16 md5", added after the entries created when the cluster is
+	// initialized and masterAndStandby.hostBasedAuthentication, which take precedence. The operator keeps them in place on
+	// the master and standby and reloads the configuration when they change
+	PgHbaEntries []string `json:"pgHbaEntries,omitempty"`
+
+	// Allow pgHbaEntries to trust connections from addresses other than loopback
+	AllowInsecureAuth bool `json:"allowInsecureAuth,omitempty"`
+}
+
+type GreenplumResourceQueueSpec struct {
+	// Name of the resource queue
+	Name string `json:"name"`
+
+	// Maximum number of statements that may run at once in the queue
+	// +kubebuilder:validation:Minimum=1
+	ActiveStatements int32 `json:"activeStatements"`
+
+	// Total memory for the statements running in the queue on each segment, e.g. 2GB. Unlimited when unset
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+
+	// CPU priority of the statements in the queue when gucs gp_resqueue_priority is on: MIN, LOW, MEDIUM, HIGH or MAX
+	// +kubebuilder:validation:Pattern=`^(?:MIN|LOW|MEDIUM|HIGH|MAX|)$`
+	Priority string `json:"priority,omitempty"`
+
+	// Roles to create in the queue, without the LOGIN privilege. gpadmin cannot be assigned, since superusers
+	// are not limited by resource queues
+	Roles []string `json:"roles,omitempty"`
 }
 
 type GreenplumPodSpec struct {
@@ -26,6 +335,11 @@ type GreenplumPodSpec struct {
 	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc.
 	CPU resource.Quantity `json:"cpu,omitempty"`
 
+	// Resource requests and limits of the greenplum container. The memory and cpu limits default to memory and cpu.
+	// Unlike memory and cpu, resources can be changed after the cluster has been created: the pods are then
+	// restarted one at a time and keep their data volumes
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
 	// Name of storage class to use for statefulset PVs
 	// +kubebuilder:validation:MinLength=1
 	StorageClassName string `json:"storageClassName"`
@@ -40,6 +354,30 @@ type GreenplumPodSpec struct {
 	// +kubebuilder:default="no"
 	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
 	AntiAffinity string `json:"antiAffinity,omitempty"`
+
+	// Node labels the pods must match, merged with workerSelector. A key cannot be given a different value than in
+	// workerSelector, and the greenplum-affinity-* labels that the operator puts on nodes for antiAffinity cannot be used
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the pods, used as given. The operator adds none of its own
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity of the pods, combined with the affinity the operator sets rather than replacing it:
+	// required node selector terms are ANDed with those for antiAffinity and for the nodes of local PVs,
+	// and preferred node terms and pod (anti-)affinity terms are added to the operator's own terms.
+	// Required pod affinity terms cannot bring together the pods that the operator keeps apart on a node:
+	// the master and standby with antiAffinity, and primary and mirror segments
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// YES or NO, specify whether or not to run an HTTP health endpoint sidecar for load balancer health checks
+	// +kubebuilder:default="no"
+	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
+	HealthEndpoint string `json:"healthEndpoint,omitempty"`
+
+	// Number of consecutive failures of the readiness probe, run every 10 seconds, before the pods are reported not
+	// ready. Raise it so that a segment failover or recovery does not make the pods flap between ready and not ready
+	// +kubebuilder:validation:Minimum=1
+	ReadinessFailureThreshold int32 `json:"readinessFailureThreshold,omitempty"`
 }
 
 type GreenplumMasterAndStandbySpec struct {
@@ -52,20 +390,119 @@ type GreenplumMasterAndStandbySpec struct {
 	// +kubebuilder:default="no"
 	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
 	Standby string `json:"standby,omitempty"`
+
+	// Optional persistent volume for the gpadmin home directory on the master and standby
+	GpadminHome *GreenplumGpadminHomeSpec `json:"gpadminHome,omitempty"`
+
+	// Interval at which to regenerate the gpadmin password stored in the greenplum-connection Secret, e.g. 720h.
+	// The password is not managed when unset
+	AdminPasswordRotationInterval *metav1.Duration `json:"adminPasswordRotationInterval,omitempty"`
+
+	// Promote the standby with gpactivatestandby when the active master pod has not been ready for
+	// failoverTimeoutSeconds. Requires standby to be yes
+	AutoFailover bool `json:"autoFailover,omitempty"`
+
+	// Seconds the active master pod may be not ready before autoFailover promotes the standby. Defaults to 120
+	// +kubebuilder:validation:Minimum=1
+	FailoverTimeoutSeconds int32 `json:"failoverTimeoutSeconds,omitempty"`
+}
+
+type GreenplumGpadminHomeSpec struct {
+	// Name of storage class to use for the gpadmin home PV
+	// +kubebuilder:validation:MinLength=1
+	StorageClassName string `json:"storageClassName"`
+
+	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc.
+	Storage resource.Quantity `json:"storage"`
+}
+
+// TempTablespaceLocation is where the temp tablespace PV, or the spill volume, is mounted in Greenplum pods
+const TempTablespaceLocation = "/greenplum-temp"
+
+type GreenplumTempTablespaceSpec struct {
+	// Name of storage class to use for the temp tablespace PVs, e.g. a class backed by local SSDs
+	// +kubebuilder:validation:MinLength=1
+	StorageClassName string `json:"storageClassName"`
+
+	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc.
+	Storage resource.Quantity `json:"storage"`
+}
+
+type GreenplumSpillVolumeSpec struct {
+	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc. Pods are evicted when their spill files exceed it
+	SizeLimit resource.Quantity `json:"sizeLimit"`
+
+	// Memory to back the volume with a tmpfs, which counts against the memory limit of the pod;
+	// by default it is on the node's disk
+	// +kubebuilder:validation:Enum="";Memory
+	Medium corev1.StorageMedium `json:"medium,omitempty"`
 }
 
 type GreenplumSegmentsSpec struct {
 	GreenplumPodSpec `json:",inline"`
 
-	// Number of primary segments to create
+	// Number of primary segments to create. Must be left unset when autoPrimarySegmentCount is yes
 	// +kubebuilder:validation:Minimum=1
 	// +kubebuilder:validation:Maximum=10000
-	PrimarySegmentCount int32 `json:"primarySegmentCount"`
+	PrimarySegmentCount int32 `json:"primarySegmentCount,omitempty"`
 
-	// YES or NO, specify whether or not to deploy a PrimarySegmentCount number of mirror segments
+	// YES or NO, specify whether or not to create one primary segment per schedulable node when the cluster is created.
+	// The resolved count is recorded in status and does not change afterwards. To expand the cluster, set it to no and
+	// primarySegmentCount to at least the count in status; it cannot be set to yes again
+	// +kubebuilder:default="no"
+	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
+	AutoPrimarySegmentCount string `json:"autoPrimarySegmentCount,omitempty"`
+
+	// YES or NO, specify whether or not to deploy a PrimarySegmentCount number of mirror segments.
+	// Changing it from no to yes on a Running cluster adds the mirrors with gpaddmirrors; mirrors cannot be removed.
+	// The mirror of the primary on segment-a-N is on segment-b-N, so group and spread mirroring are the same
 	// +kubebuilder:default="no"
 	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
 	Mirrors string `json:"mirrors,omitempty"`
+
+	// YES or NO, specify whether or not to restart segment pods whose data volume has become read-only. A pod is only
+	// restarted once it has been up for 10 minutes, so that one whose volume stays read-only is not restarted repeatedly
+	// +kubebuilder:default="no"
+	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
+	FenceReadOnly string `json:"fenceReadOnly,omitempty"`
+
+	// YES or NO, specify whether or not to keep segment pods on the nodes that their data PVs are restricted to by the
+	// PV nodeAffinity, e.g. for local PVs. Takes effect once the data PVCs of all segments are bound
+	// +kubebuilder:default="no"
+	// +kubebuilder:validation:Pattern=`^(?:yes|Yes|YES|no|No|NO|)$`
+	PersistentVolumeAffinity string `json:"persistentVolumeAffinity,omitempty"`
+
+	// IMMEDIATE or DEFERRED, specify whether gpexpand redistributes table data onto new segments as soon as they are added,
+	// or leaves it until this is changed to immediate, e.g. during a maintenance window
+	// +kubebuilder:default="deferred"
+	// +kubebuilder:validation:Pattern=`^(?:immediate|Immediate|IMMEDIATE|deferred|Deferred|DEFERRED|)$`
+	Redistribution string `json:"redistribution,omitempty"`
+}
+
+type GreenplumDisasterRecoverySpec struct {
+	// Namespace of a primary GreenplumCluster in this Kubernetes cluster. Exactly one of primaryNamespace and
+	// primaryHost must be set
+	PrimaryNamespace string `json:"primaryNamespace,omitempty"`
+
+	// Host of the master of a primary cluster outside this Kubernetes cluster. gpcopy moves data directly between
+	// segments, so the segment hosts of the primary must also be reachable from this cluster's segments
+	PrimaryHost string `json:"primaryHost,omitempty"`
+
+	// Port of the primary's master. Defaults to 5432
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	PrimaryPort int32 `json:"primaryPort,omitempty"`
+
+	// Name of a Secret of type kubernetes.io/basic-auth with the role gpcopy connects to the primary as, which
+	// must be a superuser there. If not set, gpcopy connects as gpadmin without a password
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty"`
+
+	// Interval between copies from the primary, e.g. 1h. Defaults to 1h
+	ReplicationInterval *metav1.Duration `json:"replicationInterval,omitempty"`
+
+	// Fails over to this cluster: replication stops, and a running copy is stopped, so that writes to this
+	// cluster are kept. Cannot be unset once set
+	Promote bool `json:"promote,omitempty"`
 }
 
 type GreenplumPXFSpec struct {
@@ -80,6 +517,101 @@ const (
 	GreenplumClusterPhaseRunning  GreenplumClusterPhase = "Running"
 	GreenplumClusterPhaseFailed   GreenplumClusterPhase = "Failed"
 	GreenplumClusterPhaseDeleting GreenplumClusterPhase = "Deleting"
+
+	// Segments are being added, or tables redistributed onto them, after spec.segments.primarySegmentCount was raised
+	GreenplumClusterPhaseExpanding GreenplumClusterPhase = "Expanding"
+)
+
+type GreenplumRedistributionPhase string
+
+const (
+	// Segments were added and redistribution is deferred
+	GreenplumRedistributionPhasePending  GreenplumRedistributionPhase = "Pending"
+	GreenplumRedistributionPhaseRunning  GreenplumRedistributionPhase = "Running"
+	GreenplumRedistributionPhaseComplete GreenplumRedistributionPhase = "Complete"
+	GreenplumRedistributionPhaseFailed   GreenplumRedistributionPhase = "Failed"
+)
+
+const (
+	// GreenplumClusterConditionDataVolumeReadOnly is True when the data volume of
+	// at least one Greenplum pod can no longer be written to
+	GreenplumClusterConditionDataVolumeReadOnly = "DataVolumeReadOnly"
+
+	// GreenplumClusterConditionAdoptionFailed is True when the cluster running in the namespace
+	// cannot be adopted, e.g. because its topology does not match the GreenplumCluster
+	GreenplumClusterConditionAdoptionFailed = "AdoptionFailed"
+
+	// GreenplumClusterConditionPVCBindingFailed is True when at least one of the
+	// cluster's PersistentVolumeClaims is pending and cannot be bound
+	GreenplumClusterConditionPVCBindingFailed = "PVCBindingFailed"
+
+	// GreenplumClusterConditionReplicationFailed is True when a disaster recovery standby
+	// cannot find its primary, or the last copy from the primary failed
+	GreenplumClusterConditionReplicationFailed = "ReplicationFailed"
+
+	// GreenplumClusterConditionVersionMismatch is True when the Greenplum pods are not all
+	// running the same Greenplum version, e.g. after a partial upgrade
+	GreenplumClusterConditionVersionMismatch = "VersionMismatch"
+
+	// GreenplumClusterConditionSegmentsInChangeTracking is True when gpstate reports primary
+	// segments in change tracking, i.e. their mirrors are down and need gprecoverseg
+	GreenplumClusterConditionSegmentsInChangeTracking = "SegmentsInChangeTracking"
+
+	// GreenplumClusterConditionMirrorsNotInSync is True when gpstate reports primary segments whose
+	// mirrors are resynchronizing. It does not affect the readiness of the segment pods.
+	GreenplumClusterConditionMirrorsNotInSync = "MirrorsNotInSync"
+
+	// GreenplumClusterConditionReadinessCheckFailed is True when spec.readinessQuery last failed,
+	// which keeps a new cluster from becoming Running
+	GreenplumClusterConditionReadinessCheckFailed = "ReadinessCheckFailed"
+
+	// GreenplumClusterConditionSegmentsNotBalanced is True when segments are not in their preferred
+	// roles, while a rebalance job returns them, and when the last rebalance job failed
+	GreenplumClusterConditionSegmentsNotBalanced = "SegmentsNotBalanced"
+
+	// GreenplumClusterConditionExpanding is True while the operator adds segments to the cluster and
+	// redistributes tables onto them, and reports the progress of the redistribution
+	GreenplumClusterConditionExpanding = "Expanding"
+
+	// GreenplumClusterConditionAddingMirrors is True while the operator adds mirrors to a cluster that was
+	// created without them, and reports why it is waiting or why the last attempt failed
+	GreenplumClusterConditionAddingMirrors = "AddingMirrors"
+
+	// GreenplumClusterConditionMasterFailedOver is True once autoFailover has promoted the standby, until
+	// the old master is reinitialized as the new standby
+	GreenplumClusterConditionMasterFailedOver = "MasterFailedOver"
+
+	// GreenplumClusterConditionSegmentsFenced is True when segments are down because the data volume of
+	// their pod failed, as listed in status.fencedSegments
+	GreenplumClusterConditionSegmentsFenced = "SegmentsFenced"
+
+	// GreenplumClusterConditionStorageResizing is True while data PVCs are smaller than the storage in the spec,
+	// and reports why a resize is waiting, e.g. for a pod restart or a storage class that allows expansion
+	GreenplumClusterConditionStorageResizing = "StorageResizing"
+
+	// GreenplumClusterConditionGUCsNotApplied is True when the live value of a GUC in spec.config.gucs differs from
+	// the spec, e.g. while the pods are restarted for a GUC that needs a restart
+	GreenplumClusterConditionGUCsNotApplied = "GUCsNotApplied"
+
+	// GreenplumClusterConditionMasterInRecovery is True when the active master reports pg_is_in_recovery(),
+	// e.g. after an unclean start, so it only accepts read-only transactions
+	GreenplumClusterConditionMasterInRecovery = "MasterInRecovery"
+
+	// GreenplumClusterConditionTLSCertificateExpiring is True when the certificate in spec.tls expires within 30 days,
+	// has expired or is invalid, and False otherwise; its message says when the certificate expires
+	GreenplumClusterConditionTLSCertificateExpiring = "TLSCertificateExpiring"
+
+	// GreenplumClusterConditionTLSRestartPending is True when Greenplum must restart to load the certificate in
+	// spec.tls, and spec.tls.automaticRestart is not set
+	GreenplumClusterConditionTLSRestartPending = "TLSRestartPending"
+
+	// GreenplumClusterConditionLDAPUnreachable is True when the active master cannot connect to the LDAP server in
+	// spec.auth.ldap, which the operator probes periodically
+	GreenplumClusterConditionLDAPUnreachable = "LDAPUnreachable"
+
+	// GreenplumClusterConditionPerformanceDegraded is True when the last gpcheckperf run of monitoring.perfCheck
+	// measured a throughput below its threshold, and Unknown when the run failed
+	GreenplumClusterConditionPerformanceDegraded = "PerformanceDegraded"
 )
 
 // GreenplumClusterStatus is the status for a GreenplumCluster resource
@@ -87,6 +619,105 @@ type GreenplumClusterStatus struct {
 	InstanceImage   string                `json:"instanceImage,omitempty"`
 	OperatorVersion string                `json:"operatorVersion,omitempty"`
 	Phase           GreenplumClusterPhase `json:"phase,omitempty"`
+
+	// Number of primary segments resolved from the schedulable node count when autoPrimarySegmentCount was yes at creation
+	PrimarySegmentCount int32 `json:"primarySegmentCount,omitempty"`
+
+	// Progress of redistributing table data onto the segments added by the last expansion
+	Redistribution GreenplumRedistributionPhase `json:"redistribution,omitempty"`
+
+	// Pod running the active master, once autoFailover has promoted the standby. master-0 when unset
+	ActiveMaster string `json:"activeMaster,omitempty"`
+
+	// Master pod that autoFailover fenced before promoting the standby. It does not start Greenplum until it has
+	// been reinitialized as the standby
+	FencedMaster string `json:"fencedMaster,omitempty"`
+
+	// Counts of the segments in gp_segment_configuration, and of those that are down
+	Segments *GreenplumSegmentsStatus `json:"segments,omitempty"`
+
+	// Segments that are down because the data volume of their pod became read-only or ran out of space.
+	// Other down segments are only counted in segments
+	FencedSegments []GreenplumFencedSegment `json:"fencedSegments,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Replication state of a disaster recovery standby
+	DisasterRecovery *GreenplumDisasterRecoveryStatus `json:"disasterRecovery,omitempty"`
+
+	// Live values on the active master of the GUCs the operator sets, as reported by SHOW,
+	// so that drift from spec.config.gucs is visible
+	AppliedGUCs map[string]string `json:"appliedGUCs,omitempty"`
+
+	// The last rolling restart of the Greenplum pods, for GUCs that only take effect after a restart or for
+	// reissued interconnect certificates
+	GUCRestart *GreenplumGUCRestartStatus `json:"gucRestart,omitempty"`
+
+	// SHA-256 of the config.pgHbaEntries block in pg_hba.conf on the active master, so that drift is detectable
+	PgHbaEntriesHash string `json:"pgHbaEntriesHash,omitempty"`
+
+	// Throughput measured by the last gpcheckperf run of monitoring.perfCheck
+	PerfCheck *GreenplumPerfCheckStatus `json:"perfCheck,omitempty"`
+}
+
+// GreenplumPerfCheckStatus holds the lowest throughput of any segment host, in MB/s.
+// A throughput is 0 when its test was not run
+type GreenplumPerfCheckStatus struct {
+	// Completion time of the last successful run
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+
+	DiskWriteMBps int32 `json:"diskWriteMBps,omitempty"`
+	DiskReadMBps  int32 `json:"diskReadMBps,omitempty"`
+	NetworkMBps   int32 `json:"networkMBps,omitempty"`
+}
+
+type GreenplumGUCRestartStatus struct {
+	// GUCs written with gpconfig before the restart, with their values. Empty for a restart for interconnect certificates
+	GUCs map[string]string `json:"gucs"`
+
+	// When the restart was requested. The operator sets it in the pod template of one statefulset at a time,
+	// for the statefulset to restart its pods
+	RequestedAt metav1.Time `json:"requestedAt"`
+
+	// Whether all the statefulsets have restarted their pods
+	Finished bool `json:"finished,omitempty"`
+}
+
+type GreenplumSegmentsStatus struct {
+	Primaries     int32 `json:"primaries"`
+	PrimariesDown int32 `json:"primariesDown,omitempty"`
+	Mirrors       int32 `json:"mirrors,omitempty"`
+	MirrorsDown   int32 `json:"mirrorsDown,omitempty"`
+}
+
+type GreenplumFencedSegment struct {
+	// Content ID of the segment
+	Content int32 `json:"content"`
+
+	// Role of the segment when it went down, p for primary or m for mirror
+	Role string `json:"role"`
+
+	// Pod hosting the segment
+	Pod string `json:"pod"`
+
+	// Why the data volume failed: ReadOnlyFilesystem or NoSpaceLeftOnDevice
+	Reason string `json:"reason"`
+
+	// When the operator first found the segment fenced
+	Since metav1.Time `json:"since"`
+}
+
+type GreenplumDisasterRecoveryStatus struct {
+	// Name of the primary GreenplumCluster
+	PrimaryCluster string `json:"primaryCluster,omitempty"`
+
+	// Completion time of the last successful copy from the primary
+	LastReplicationTime *metav1.Time `json:"lastReplicationTime,omitempty"`
+
+	// When replication was stopped by spec.disasterRecovery.promote
+	PromotionTime *metav1.Time `json:"promotionTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -189,12 +189,17 @@ var _ = Describe("GreenplumCluster validation", func() {
 		Context("spec.segments", func() {
 			// primarySegmentCount
 			It("does not allow primarySegmentCount < 1", func() {
-				greenplumCluster.Spec.Segments.PrimarySegmentCount = 0
+				greenplumCluster.Spec.Segments.PrimarySegmentCount = -1
 				Expect(validator.Validate(greenplumCluster).IsValid()).To(BeFalse())
 				Expect(validator.Validate(greenplumCluster).AsError()).To(
 					MatchError("validation failure list:\nspec.segments.primarySegmentCount in body should be greater than or equal to 1"),
 					"%#v", validator.Validate(greenplumCluster).AsError().Error())
 			})
+			It("allows primarySegmentCount to be left unset, for autoPrimarySegmentCount", func() {
+				greenplumCluster.Spec.Segments.PrimarySegmentCount = 0
+				greenplumCluster.Spec.Segments.AutoPrimarySegmentCount = "yes"
+				Expect(validator.Validate(greenplumCluster).IsValid()).To(BeTrue())
+			})
 			It("allows 1-10000 primarySegmentCount", func() {
 				for i := int32(1); i <= 10000; i *= 2 {
 					greenplumCluster.Spec.Segments.PrimarySegmentCount = i
@@ -272,9 +277,9 @@ var _ = Describe("GreenplumCluster validation", func() {
 			})
 
 			// required properties
-			It("requires primarySegmentCount, storageClassName and storage to be specified", func() {
+			It("requires storageClassName and storage to be specified", func() {
 				required := apiCrd.Spec.Validation.OpenAPIV3Schema.Properties["spec"].Properties["segments"].Required
-				Expect(required).To(ConsistOf("primarySegmentCount", "storageClassName", "storage"))
+				Expect(required).To(ConsistOf("storageClassName", "storage"))
 			})
 		})
 
@@ -0,0 +1,65 @@
+/*
+.
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GreenplumClusterDefaultsName is the name of the GreenplumClusterDefaults whose values are
+// merged into new GreenplumClusters. Other GreenplumClusterDefaults are ignored.
+const GreenplumClusterDefaultsName = "default"
+
+// GreenplumClusterDefaultsPodSpec holds the pod values to use when a new GreenplumCluster leaves them unset
+type GreenplumClusterDefaultsPodSpec struct {
+	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc.
+	Memory resource.Quantity `json:"memory,omitempty"`
+
+	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc.
+	CPU resource.Quantity `json:"cpu,omitempty"`
+
+	// Name of storage class to use for statefulset PVs
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Quantity expressed with an SI suffix, like 2Gi, 200m, 3.5, etc.
+	Storage resource.Quantity `json:"storage,omitempty"`
+
+	// A set of node labels for scheduling pods
+	WorkerSelector map[string]string `json:"workerSelector,omitempty"`
+}
+
+type GreenplumClusterDefaultsSpec struct {
+	MasterAndStandby GreenplumClusterDefaultsPodSpec `json:"masterAndStandby,omitempty"`
+	Segments         GreenplumClusterDefaultsPodSpec `json:"segments,omitempty"`
+
+	// GUCs are merged by name; a GUC set on the GreenplumCluster wins
+	Config GreenplumConfigSpec `json:"config,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="The defaults age"
+
+// GreenplumClusterDefaults is the Schema for the greenplumclusterdefaults API
+type GreenplumClusterDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GreenplumClusterDefaultsSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GreenplumClusterDefaultsList contains a list of GreenplumClusterDefaults
+type GreenplumClusterDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GreenplumClusterDefaults `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GreenplumClusterDefaults{}, &GreenplumClusterDefaultsList{})
+}
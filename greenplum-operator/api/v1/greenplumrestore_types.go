@@ -0,0 +1,123 @@
+/*
+.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type GreenplumRestoreSpec struct {
+	// Name of the GreenplumCluster in the same namespace to restore into. It must have as many primary segments
+	// as the cluster that was backed up
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+
+	// Timestamp key of the backup to restore, as reported by gpbackup, e.g. the status.timestamp of a GreenplumBackup
+	// +kubebuilder:validation:Pattern=`^[0-9]{14}$`
+	Timestamp string `json:"timestamp"`
+
+	// S3 folder that gpbackup_s3_plugin wrote the backup to
+	S3 GreenplumBackupS3Spec `json:"s3"`
+
+	// Database to restore into, with gprestore --redirect-db. It is created if it does not exist. Defaults to gpadmin
+	Database string `json:"database,omitempty"`
+
+	// Schemas to restore from the backup. The whole backup is restored when neither includeSchemas nor includeTables is set.
+	// Cannot be combined with includeTables
+	IncludeSchemas []string `json:"includeSchemas,omitempty"`
+
+	// Tables to restore from the backup, each in the form <schema>.<table>. Cannot be combined with includeSchemas
+	IncludeTables []string `json:"includeTables,omitempty"`
+
+	// OnErrorContinue has gprestore log and skip the objects it fails to restore, instead of stopping at the first error.
+	// The restore still fails if any object was skipped
+	OnErrorContinue bool `json:"onErrorContinue,omitempty"`
+
+	// WithStats restores the optimizer statistics of a backup taken with withStats, with gprestore --with-stats,
+	// so that the restored tables do not need to be analyzed
+	WithStats bool `json:"withStats,omitempty"`
+
+	// Number of parallel connections gprestore uses to restore the table data, with gprestore --jobs. Defaults to 1.
+	// Leave it unset for a backup taken with singleDataFile, whose data files are read with one connection
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	Jobs int32 `json:"jobs,omitempty"`
+
+	// AllowOverwrite restores into a database that the cluster is already serving. The database is dropped before
+	// the restore, or only the restored schemas or tables when includeSchemas or includeTables is set.
+	// Without it, a restore into an existing database is refused
+	AllowOverwrite bool `json:"allowOverwrite,omitempty"`
+
+	// Image for the restore job, e.g. the image with the gpbackup storage plugin that the backup was taken with.
+	// Defaults to the Greenplum instance image. The image must provide /home/gpadmin/tools/gprestore_job.sh
+	PluginImage string `json:"pluginImage,omitempty"`
+
+	// Optional bundle of CA certificates to trust in the restore job, e.g. for an S3 endpoint with a private CA
+	CABundle *GreenplumCABundleSpec `json:"caBundle,omitempty"`
+}
+
+type GreenplumRestorePhase string
+
+const (
+	// The restore waits for its cluster to be Running, for the S3 credentials Secret, and for a valid encryption key and caBundle
+	GreenplumRestorePhasePending   GreenplumRestorePhase = "Pending"
+	GreenplumRestorePhaseRunning   GreenplumRestorePhase = "Running"
+	GreenplumRestorePhaseSucceeded GreenplumRestorePhase = "Succeeded"
+	GreenplumRestorePhaseFailed    GreenplumRestorePhase = "Failed"
+)
+
+const (
+	// GreenplumRestoreConditionComplete is True once the restore has succeeded
+	GreenplumRestoreConditionComplete = "Complete"
+
+	// GreenplumRestoreConditionFailed is True once the restore has failed, or was refused; its message says why
+	GreenplumRestoreConditionFailed = "Failed"
+)
+
+type GreenplumRestoreStatus struct {
+	Phase GreenplumRestorePhase `json:"phase,omitempty"`
+
+	// Why the restore is pending, or how it finished
+	Message string `json:"message,omitempty"`
+
+	// Name of the job running gprestore. The job is kept once the restore finishes, so that its logs can be read
+	JobName string `json:"jobName,omitempty"`
+
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=all
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`,description="The GreenplumCluster restored into"
+// +kubebuilder:printcolumn:name="Timestamp",type=string,JSONPath=`.spec.timestamp`,description="The gpbackup timestamp key"
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`,description="The restore status"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="The restore age"
+
+// GreenplumRestore is the Schema for the greenplumrestores API
+type GreenplumRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GreenplumRestoreSpec   `json:"spec,omitempty"`
+	Status GreenplumRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GreenplumRestoreList contains a list of GreenplumRestore
+type GreenplumRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GreenplumRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GreenplumRestore{}, &GreenplumRestoreList{})
+}
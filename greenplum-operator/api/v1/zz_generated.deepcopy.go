@@ -9,97 +9,1028 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GreenplumCluster) DeepCopyInto(out *GreenplumCluster) {
+func (in *GreenplumAuthSpec) DeepCopyInto(out *GreenplumAuthSpec) {
+	*out = *in
+	if in.LDAP != nil {
+		in, out := &in.LDAP, &out.LDAP
+		*out = new(GreenplumLDAPSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumAuthSpec.
+func (in *GreenplumAuthSpec) DeepCopy() *GreenplumAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackup) DeepCopyInto(out *GreenplumBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackup.
+func (in *GreenplumBackup) DeepCopy() *GreenplumBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupEncryptionSpec) DeepCopyInto(out *GreenplumBackupEncryptionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupEncryptionSpec.
+func (in *GreenplumBackupEncryptionSpec) DeepCopy() *GreenplumBackupEncryptionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupEncryptionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupList) DeepCopyInto(out *GreenplumBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GreenplumBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupList.
+func (in *GreenplumBackupList) DeepCopy() *GreenplumBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupOptions) DeepCopyInto(out *GreenplumBackupOptions) {
+	*out = *in
+	if in.IncludeTables != nil {
+		in, out := &in.IncludeTables, &out.IncludeTables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeTables != nil {
+		in, out := &in.ExcludeTables, &out.ExcludeTables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IncludeSchemas != nil {
+		in, out := &in.IncludeSchemas, &out.IncludeSchemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeSchemas != nil {
+		in, out := &in.ExcludeSchemas, &out.ExcludeSchemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackupDirVolume != nil {
+		in, out := &in.BackupDirVolume, &out.BackupDirVolume
+		*out = new(corev1.VolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(GreenplumCABundleSpec)
+		**out = **in
+	}
+	if in.Plugin != nil {
+		in, out := &in.Plugin, &out.Plugin
+		*out = new(GreenplumBackupPluginSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupOptions.
+func (in *GreenplumBackupOptions) DeepCopy() *GreenplumBackupOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupPluginSpec) DeepCopyInto(out *GreenplumBackupPluginSpec) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(GreenplumBackupEncryptionSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupPluginSpec.
+func (in *GreenplumBackupPluginSpec) DeepCopy() *GreenplumBackupPluginSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupPluginSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupRetentionSpec) DeepCopyInto(out *GreenplumBackupRetentionSpec) {
+	*out = *in
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupRetentionSpec.
+func (in *GreenplumBackupRetentionSpec) DeepCopy() *GreenplumBackupRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupS3CredentialsSpec) DeepCopyInto(out *GreenplumBackupS3CredentialsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupS3CredentialsSpec.
+func (in *GreenplumBackupS3CredentialsSpec) DeepCopy() *GreenplumBackupS3CredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupS3CredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupS3Spec) DeepCopyInto(out *GreenplumBackupS3Spec) {
+	*out = *in
+	out.Credentials = in.Credentials
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(GreenplumBackupEncryptionSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupS3Spec.
+func (in *GreenplumBackupS3Spec) DeepCopy() *GreenplumBackupS3Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupS3Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupSchedule) DeepCopyInto(out *GreenplumBackupSchedule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupSchedule.
+func (in *GreenplumBackupSchedule) DeepCopy() *GreenplumBackupSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumBackupSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupScheduleList) DeepCopyInto(out *GreenplumBackupScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GreenplumBackupSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupScheduleList.
+func (in *GreenplumBackupScheduleList) DeepCopy() *GreenplumBackupScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumBackupScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupScheduleRetentionSpec) DeepCopyInto(out *GreenplumBackupScheduleRetentionSpec) {
+	*out = *in
+	if in.KeepLast != nil {
+		in, out := &in.KeepLast, &out.KeepLast
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KeepDays != nil {
+		in, out := &in.KeepDays, &out.KeepDays
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupScheduleRetentionSpec.
+func (in *GreenplumBackupScheduleRetentionSpec) DeepCopy() *GreenplumBackupScheduleRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupScheduleRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupScheduleSpec) DeepCopyInto(out *GreenplumBackupScheduleSpec) {
+	*out = *in
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(GreenplumBackupScheduleRetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupScheduleSpec.
+func (in *GreenplumBackupScheduleSpec) DeepCopy() *GreenplumBackupScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupScheduleStatus) DeepCopyInto(out *GreenplumBackupScheduleStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupScheduleStatus.
+func (in *GreenplumBackupScheduleStatus) DeepCopy() *GreenplumBackupScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupSpec) DeepCopyInto(out *GreenplumBackupSpec) {
+	*out = *in
+	in.GreenplumBackupOptions.DeepCopyInto(&out.GreenplumBackupOptions)
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(GreenplumBackupS3Spec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(GreenplumBackupRetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupSpec.
+func (in *GreenplumBackupSpec) DeepCopy() *GreenplumBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupStatus) DeepCopyInto(out *GreenplumBackupStatus) {
+	*out = *in
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(GreenplumBackupVerificationStatus)
+		**out = **in
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PrunedBackups != nil {
+		in, out := &in.PrunedBackups, &out.PrunedBackups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupStatus.
+func (in *GreenplumBackupStatus) DeepCopy() *GreenplumBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumBackupVerificationStatus) DeepCopyInto(out *GreenplumBackupVerificationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumBackupVerificationStatus.
+func (in *GreenplumBackupVerificationStatus) DeepCopy() *GreenplumBackupVerificationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumBackupVerificationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumCABundleSpec) DeepCopyInto(out *GreenplumCABundleSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumCABundleSpec.
+func (in *GreenplumCABundleSpec) DeepCopy() *GreenplumCABundleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumCABundleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumCluster) DeepCopyInto(out *GreenplumCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumCluster.
+func (in *GreenplumCluster) DeepCopy() *GreenplumCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterDefaults) DeepCopyInto(out *GreenplumClusterDefaults) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterDefaults.
+func (in *GreenplumClusterDefaults) DeepCopy() *GreenplumClusterDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumClusterDefaults) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterDefaultsList) DeepCopyInto(out *GreenplumClusterDefaultsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GreenplumClusterDefaults, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterDefaultsList.
+func (in *GreenplumClusterDefaultsList) DeepCopy() *GreenplumClusterDefaultsList {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterDefaultsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumClusterDefaultsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterDefaultsPodSpec) DeepCopyInto(out *GreenplumClusterDefaultsPodSpec) {
+	*out = *in
+	out.Memory = in.Memory.DeepCopy()
+	out.CPU = in.CPU.DeepCopy()
+	out.Storage = in.Storage.DeepCopy()
+	if in.WorkerSelector != nil {
+		in, out := &in.WorkerSelector, &out.WorkerSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterDefaultsPodSpec.
+func (in *GreenplumClusterDefaultsPodSpec) DeepCopy() *GreenplumClusterDefaultsPodSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterDefaultsPodSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterDefaultsSpec) DeepCopyInto(out *GreenplumClusterDefaultsSpec) {
+	*out = *in
+	in.MasterAndStandby.DeepCopyInto(&out.MasterAndStandby)
+	in.Segments.DeepCopyInto(&out.Segments)
+	in.Config.DeepCopyInto(&out.Config)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterDefaultsSpec.
+func (in *GreenplumClusterDefaultsSpec) DeepCopy() *GreenplumClusterDefaultsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterDefaultsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterList) DeepCopyInto(out *GreenplumClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GreenplumCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterList.
+func (in *GreenplumClusterList) DeepCopy() *GreenplumClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterSpec) DeepCopyInto(out *GreenplumClusterSpec) {
+	*out = *in
+	in.MasterAndStandby.DeepCopyInto(&out.MasterAndStandby)
+	in.Segments.DeepCopyInto(&out.Segments)
+	out.PXF = in.PXF
+	in.Config.DeepCopyInto(&out.Config)
+	if in.Sysctls != nil {
+		in, out := &in.Sysctls, &out.Sysctls
+		*out = make([]corev1.Sysctl, len(*in))
+		copy(*out, *in)
+	}
+	if in.DisasterRecovery != nil {
+		in, out := &in.DisasterRecovery, &out.DisasterRecovery
+		*out = new(GreenplumDisasterRecoverySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TempTablespace != nil {
+		in, out := &in.TempTablespace, &out.TempTablespace
+		*out = new(GreenplumTempTablespaceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpillVolume != nil {
+		in, out := &in.SpillVolume, &out.SpillVolume
+		*out = new(GreenplumSpillVolumeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(GreenplumCABundleSpec)
+		**out = **in
+	}
+	if in.EntrypointScript != nil {
+		in, out := &in.EntrypointScript, &out.EntrypointScript
+		*out = new(GreenplumEntrypointScriptSpec)
+		**out = **in
+	}
+	if in.InterconnectTLS != nil {
+		in, out := &in.InterconnectTLS, &out.InterconnectTLS
+		*out = new(GreenplumInterconnectTLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(GreenplumTLSSpec)
+		**out = **in
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(GreenplumAuthSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(GreenplumMonitoringSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterSpec.
+func (in *GreenplumClusterSpec) DeepCopy() *GreenplumClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumClusterStatus) DeepCopyInto(out *GreenplumClusterStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Segments != nil {
+		in, out := &in.Segments, &out.Segments
+		*out = new(GreenplumSegmentsStatus)
+		**out = **in
+	}
+	if in.FencedSegments != nil {
+		in, out := &in.FencedSegments, &out.FencedSegments
+		*out = make([]GreenplumFencedSegment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DisasterRecovery != nil {
+		in, out := &in.DisasterRecovery, &out.DisasterRecovery
+		*out = new(GreenplumDisasterRecoveryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AppliedGUCs != nil {
+		in, out := &in.AppliedGUCs, &out.AppliedGUCs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.GUCRestart != nil {
+		in, out := &in.GUCRestart, &out.GUCRestart
+		*out = new(GreenplumGUCRestartStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PerfCheck != nil {
+		in, out := &in.PerfCheck, &out.PerfCheck
+		*out = new(GreenplumPerfCheckStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterStatus.
+func (in *GreenplumClusterStatus) DeepCopy() *GreenplumClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumConfigSpec) DeepCopyInto(out *GreenplumConfigSpec) {
+	*out = *in
+	if in.GUCs != nil {
+		in, out := &in.GUCs, &out.GUCs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RoleConnectionLimits != nil {
+		in, out := &in.RoleConnectionLimits, &out.RoleConnectionLimits
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RoleSettings != nil {
+		in, out := &in.RoleSettings, &out.RoleSettings
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Extensions != nil {
+		in, out := &in.Extensions, &out.Extensions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceQueues != nil {
+		in, out := &in.ResourceQueues, &out.ResourceQueues
+		*out = make([]GreenplumResourceQueueSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PgHbaEntries != nil {
+		in, out := &in.PgHbaEntries, &out.PgHbaEntries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumConfigSpec.
+func (in *GreenplumConfigSpec) DeepCopy() *GreenplumConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumCustomQueriesSpec) DeepCopyInto(out *GreenplumCustomQueriesSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumCustomQueriesSpec.
+func (in *GreenplumCustomQueriesSpec) DeepCopy() *GreenplumCustomQueriesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumCustomQueriesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumDisasterRecoverySpec) DeepCopyInto(out *GreenplumDisasterRecoverySpec) {
+	*out = *in
+	if in.ReplicationInterval != nil {
+		in, out := &in.ReplicationInterval, &out.ReplicationInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumDisasterRecoverySpec.
+func (in *GreenplumDisasterRecoverySpec) DeepCopy() *GreenplumDisasterRecoverySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumDisasterRecoverySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumDisasterRecoveryStatus) DeepCopyInto(out *GreenplumDisasterRecoveryStatus) {
+	*out = *in
+	if in.LastReplicationTime != nil {
+		in, out := &in.LastReplicationTime, &out.LastReplicationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PromotionTime != nil {
+		in, out := &in.PromotionTime, &out.PromotionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumDisasterRecoveryStatus.
+func (in *GreenplumDisasterRecoveryStatus) DeepCopy() *GreenplumDisasterRecoveryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumDisasterRecoveryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumEntrypointScriptSpec) DeepCopyInto(out *GreenplumEntrypointScriptSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumEntrypointScriptSpec.
+func (in *GreenplumEntrypointScriptSpec) DeepCopy() *GreenplumEntrypointScriptSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumEntrypointScriptSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumFencedSegment) DeepCopyInto(out *GreenplumFencedSegment) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumFencedSegment.
+func (in *GreenplumFencedSegment) DeepCopy() *GreenplumFencedSegment {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumFencedSegment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumGUCRestartStatus) DeepCopyInto(out *GreenplumGUCRestartStatus) {
+	*out = *in
+	if in.GUCs != nil {
+		in, out := &in.GUCs, &out.GUCs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.RequestedAt.DeepCopyInto(&out.RequestedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumGUCRestartStatus.
+func (in *GreenplumGUCRestartStatus) DeepCopy() *GreenplumGUCRestartStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumGUCRestartStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumGpadminHomeSpec) DeepCopyInto(out *GreenplumGpadminHomeSpec) {
+	*out = *in
+	out.Storage = in.Storage.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumGpadminHomeSpec.
+func (in *GreenplumGpadminHomeSpec) DeepCopy() *GreenplumGpadminHomeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumGpadminHomeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumInterconnectTLSSpec) DeepCopyInto(out *GreenplumInterconnectTLSSpec) {
+	*out = *in
+	if in.CertificateValidity != nil {
+		in, out := &in.CertificateValidity, &out.CertificateValidity
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumCluster.
-func (in *GreenplumCluster) DeepCopy() *GreenplumCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumInterconnectTLSSpec.
+func (in *GreenplumInterconnectTLSSpec) DeepCopy() *GreenplumInterconnectTLSSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GreenplumCluster)
+	out := new(GreenplumInterconnectTLSSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GreenplumCluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GreenplumClusterList) DeepCopyInto(out *GreenplumClusterList) {
+func (in *GreenplumLDAPBindPasswordSpec) DeepCopyInto(out *GreenplumLDAPBindPasswordSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]GreenplumCluster, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterList.
-func (in *GreenplumClusterList) DeepCopy() *GreenplumClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumLDAPBindPasswordSpec.
+func (in *GreenplumLDAPBindPasswordSpec) DeepCopy() *GreenplumLDAPBindPasswordSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GreenplumClusterList)
+	out := new(GreenplumLDAPBindPasswordSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GreenplumClusterList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GreenplumClusterSpec) DeepCopyInto(out *GreenplumClusterSpec) {
+func (in *GreenplumLDAPSpec) DeepCopyInto(out *GreenplumLDAPSpec) {
 	*out = *in
-	in.MasterAndStandby.DeepCopyInto(&out.MasterAndStandby)
-	in.Segments.DeepCopyInto(&out.Segments)
-	out.PXF = in.PXF
+	if in.SearchBindPassword != nil {
+		in, out := &in.SearchBindPassword, &out.SearchBindPassword
+		*out = new(GreenplumLDAPBindPasswordSpec)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(GreenplumLDAPTLSSpec)
+		**out = **in
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterSpec.
-func (in *GreenplumClusterSpec) DeepCopy() *GreenplumClusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumLDAPSpec.
+func (in *GreenplumLDAPSpec) DeepCopy() *GreenplumLDAPSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GreenplumClusterSpec)
+	out := new(GreenplumLDAPSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GreenplumClusterStatus) DeepCopyInto(out *GreenplumClusterStatus) {
+func (in *GreenplumLDAPTLSSpec) DeepCopyInto(out *GreenplumLDAPTLSSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumClusterStatus.
-func (in *GreenplumClusterStatus) DeepCopy() *GreenplumClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumLDAPTLSSpec.
+func (in *GreenplumLDAPTLSSpec) DeepCopy() *GreenplumLDAPTLSSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GreenplumClusterStatus)
+	out := new(GreenplumLDAPTLSSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -108,6 +1039,16 @@ func (in *GreenplumClusterStatus) DeepCopy() *GreenplumClusterStatus {
 func (in *GreenplumMasterAndStandbySpec) DeepCopyInto(out *GreenplumMasterAndStandbySpec) {
 	*out = *in
 	in.GreenplumPodSpec.DeepCopyInto(&out.GreenplumPodSpec)
+	if in.GpadminHome != nil {
+		in, out := &in.GpadminHome, &out.GpadminHome
+		*out = new(GreenplumGpadminHomeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdminPasswordRotationInterval != nil {
+		in, out := &in.AdminPasswordRotationInterval, &out.AdminPasswordRotationInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumMasterAndStandbySpec.
@@ -120,6 +1061,31 @@ func (in *GreenplumMasterAndStandbySpec) DeepCopy() *GreenplumMasterAndStandbySp
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumMonitoringSpec) DeepCopyInto(out *GreenplumMonitoringSpec) {
+	*out = *in
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(GreenplumPrometheusSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PerfCheck != nil {
+		in, out := &in.PerfCheck, &out.PerfCheck
+		*out = new(GreenplumPerfCheckSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumMonitoringSpec.
+func (in *GreenplumMonitoringSpec) DeepCopy() *GreenplumMonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumMonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GreenplumPXFSpec) DeepCopyInto(out *GreenplumPXFSpec) {
 	*out = *in
@@ -135,11 +1101,56 @@ func (in *GreenplumPXFSpec) DeepCopy() *GreenplumPXFSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumPerfCheckSpec) DeepCopyInto(out *GreenplumPerfCheckSpec) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.DiskFileSize != nil {
+		in, out := &in.DiskFileSize, &out.DiskFileSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumPerfCheckSpec.
+func (in *GreenplumPerfCheckSpec) DeepCopy() *GreenplumPerfCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumPerfCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumPerfCheckStatus) DeepCopyInto(out *GreenplumPerfCheckStatus) {
+	*out = *in
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumPerfCheckStatus.
+func (in *GreenplumPerfCheckStatus) DeepCopy() *GreenplumPerfCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumPerfCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GreenplumPodSpec) DeepCopyInto(out *GreenplumPodSpec) {
 	*out = *in
 	out.Memory = in.Memory.DeepCopy()
 	out.CPU = in.CPU.DeepCopy()
+	in.Resources.DeepCopyInto(&out.Resources)
 	out.Storage = in.Storage.DeepCopy()
 	if in.WorkerSelector != nil {
 		in, out := &in.WorkerSelector, &out.WorkerSelector
@@ -148,6 +1159,25 @@ func (in *GreenplumPodSpec) DeepCopyInto(out *GreenplumPodSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumPodSpec.
@@ -160,6 +1190,186 @@ func (in *GreenplumPodSpec) DeepCopy() *GreenplumPodSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumPrometheusSegmentsSpec) DeepCopyInto(out *GreenplumPrometheusSegmentsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumPrometheusSegmentsSpec.
+func (in *GreenplumPrometheusSegmentsSpec) DeepCopy() *GreenplumPrometheusSegmentsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumPrometheusSegmentsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumPrometheusSpec) DeepCopyInto(out *GreenplumPrometheusSpec) {
+	*out = *in
+	if in.Segments != nil {
+		in, out := &in.Segments, &out.Segments
+		*out = new(GreenplumPrometheusSegmentsSpec)
+		**out = **in
+	}
+	if in.CustomQueries != nil {
+		in, out := &in.CustomQueries, &out.CustomQueries
+		*out = new(GreenplumCustomQueriesSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumPrometheusSpec.
+func (in *GreenplumPrometheusSpec) DeepCopy() *GreenplumPrometheusSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumPrometheusSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumResourceQueueSpec) DeepCopyInto(out *GreenplumResourceQueueSpec) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumResourceQueueSpec.
+func (in *GreenplumResourceQueueSpec) DeepCopy() *GreenplumResourceQueueSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumResourceQueueSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumRestore) DeepCopyInto(out *GreenplumRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumRestore.
+func (in *GreenplumRestore) DeepCopy() *GreenplumRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumRestoreList) DeepCopyInto(out *GreenplumRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GreenplumRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumRestoreList.
+func (in *GreenplumRestoreList) DeepCopy() *GreenplumRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GreenplumRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumRestoreSpec) DeepCopyInto(out *GreenplumRestoreSpec) {
+	*out = *in
+	in.S3.DeepCopyInto(&out.S3)
+	if in.IncludeSchemas != nil {
+		in, out := &in.IncludeSchemas, &out.IncludeSchemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IncludeTables != nil {
+		in, out := &in.IncludeTables, &out.IncludeTables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(GreenplumCABundleSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumRestoreSpec.
+func (in *GreenplumRestoreSpec) DeepCopy() *GreenplumRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumRestoreStatus) DeepCopyInto(out *GreenplumRestoreStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumRestoreStatus.
+func (in *GreenplumRestoreStatus) DeepCopy() *GreenplumRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GreenplumSegmentsSpec) DeepCopyInto(out *GreenplumSegmentsSpec) {
 	*out = *in
@@ -175,3 +1385,65 @@ func (in *GreenplumSegmentsSpec) DeepCopy() *GreenplumSegmentsSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumSegmentsStatus) DeepCopyInto(out *GreenplumSegmentsStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumSegmentsStatus.
+func (in *GreenplumSegmentsStatus) DeepCopy() *GreenplumSegmentsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumSegmentsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumSpillVolumeSpec) DeepCopyInto(out *GreenplumSpillVolumeSpec) {
+	*out = *in
+	out.SizeLimit = in.SizeLimit.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumSpillVolumeSpec.
+func (in *GreenplumSpillVolumeSpec) DeepCopy() *GreenplumSpillVolumeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumSpillVolumeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumTLSSpec) DeepCopyInto(out *GreenplumTLSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumTLSSpec.
+func (in *GreenplumTLSSpec) DeepCopy() *GreenplumTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GreenplumTempTablespaceSpec) DeepCopyInto(out *GreenplumTempTablespaceSpec) {
+	*out = *in
+	out.Storage = in.Storage.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GreenplumTempTablespaceSpec.
+func (in *GreenplumTempTablespaceSpec) DeepCopy() *GreenplumTempTablespaceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GreenplumTempTablespaceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	goruntime "runtime"
+	"runtime/debug"
 
 	// Enable auth plugin for GCP
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -13,13 +14,16 @@ import (
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/admission"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sshkeygen"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/multidaemon"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	// +kubebuilder:scaffold:imports
@@ -44,7 +48,11 @@ func Run() error {
 
 	logGoInfo(setupLog)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if err := ApplyMemoryLimit(options, debug.SetMemoryLimit); err != nil {
+		return err
+	}
+
+	mgr, err := ctrl.NewManager(ConfigureClient(ctrl.GetConfigOrDie(), options), ctrl.Options{
 		Scheme:             scheme.Scheme,
 		MetricsBindAddress: ":8080",
 	})
@@ -88,15 +96,49 @@ func Run() error {
 
 	if err = (&greenplumcluster.GreenplumClusterReconciler{
 		Client:        mgr.GetClient(),
+		APIReader:     mgr.GetAPIReader(),
 		Log:           ctrl.Log.WithName("controllers").WithName("GreenplumCluster"),
 		SSHCreator:    sshkeygen.New(),
 		InstanceImage: instanceImage,
 		OperatorImage: operatorImage,
 		PodExec:       podExec,
+		Recorder:      mgr.GetEventRecorderFor("greenplumcluster-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GreenplumCluster")
 		return err
 	}
+
+	if err = (&controllers.GreenplumBackupReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("GreenplumBackup"),
+		InstanceImage: instanceImage,
+		PodExec:       podExec,
+		Recorder:      mgr.GetEventRecorderFor("greenplumbackup-controller"),
+		NewS3Lister:   backupjob.NewS3Lister,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GreenplumBackup")
+		return err
+	}
+	if err = (&controllers.GreenplumBackupScheduleReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("GreenplumBackupSchedule"),
+		InstanceImage: instanceImage,
+		Recorder:      mgr.GetEventRecorderFor("greenplumbackupschedule-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GreenplumBackupSchedule")
+		return err
+	}
+	if err = (&controllers.GreenplumRestoreReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("GreenplumRestore"),
+		InstanceImage: instanceImage,
+		PodExec:       podExec,
+		Recorder:      mgr.GetEventRecorderFor("greenplumrestore-controller"),
+		NewS3Lister:   backupjob.NewS3Lister,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GreenplumRestore")
+		return err
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -111,7 +153,35 @@ func logGoInfo(log logr.Logger) {
 }
 
 type GreenplumOperatorOptions struct {
-	LogLevel string `short:"v" long:"logLevel" default:"info" description:"Log verbosity" choice:"info" choice:"debug"`
+	LogLevel     string  `short:"v" long:"logLevel" default:"info" description:"Log verbosity" choice:"info" choice:"debug"`
+	KubeAPIQPS   float32 `long:"kubeAPIQPS" default:"20" description:"Sustained queries per second to the Kubernetes API server"`
+	KubeAPIBurst int     `long:"kubeAPIBurst" default:"30" description:"Queries to the Kubernetes API server allowed in a burst above kubeAPIQPS"`
+	MemoryLimit  string  `long:"memoryLimit" description:"Soft memory limit for the operator, e.g. 512Mi; set it below the container's memory limit"`
+}
+
+// ConfigureClient sets the rate limits of the clients built from config, which the
+// manager's controllers, and the webhook, use to talk to the Kubernetes API server
+func ConfigureClient(config *rest.Config, options GreenplumOperatorOptions) *rest.Config {
+	config.QPS = options.KubeAPIQPS
+	config.Burst = options.KubeAPIBurst
+	return config
+}
+
+// ApplyMemoryLimit sets the Go runtime's soft memory limit, so that the garbage collector
+// works harder before the operator reaches its container's memory limit
+func ApplyMemoryLimit(options GreenplumOperatorOptions, setMemoryLimit func(int64) int64) error {
+	if options.MemoryLimit == "" {
+		return nil
+	}
+	limit, err := resource.ParseQuantity(options.MemoryLimit)
+	if err != nil {
+		return errors.Wrap(err, "parsing memoryLimit")
+	}
+	if limit.Sign() <= 0 {
+		return errors.New("memoryLimit must be positive")
+	}
+	setMemoryLimit(limit.Value())
+	return nil
 }
 
 // Parse with both jessevdk/go-flags and the golang flag package
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"github.com/jessevdk/go-flags"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
 )
 
 var _ = Describe("GetInstanceImageFromEnv", func() {
@@ -102,3 +104,67 @@ var _ = Describe("GetOperatorImageFromEnv", func() {
 		})
 	})
 })
+
+var _ = Describe("ConfigureClient", func() {
+	var options GreenplumOperatorOptions
+
+	When("no client flags are given", func() {
+		BeforeEach(func() {
+			_, err := flags.ParseArgs(&options, []string{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("uses the controller-runtime defaults", func() {
+			config := ConfigureClient(&rest.Config{Host: "https://kubernetes"}, options)
+			Expect(config.Host).To(Equal("https://kubernetes"))
+			Expect(config.QPS).To(Equal(float32(20)))
+			Expect(config.Burst).To(Equal(30))
+		})
+	})
+
+	When("kubeAPIQPS and kubeAPIBurst are given", func() {
+		BeforeEach(func() {
+			_, err := flags.ParseArgs(&options, []string{"--kubeAPIQPS", "75.5", "--kubeAPIBurst", "150"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("sets them on the rest.Config", func() {
+			config := ConfigureClient(&rest.Config{}, options)
+			Expect(config.QPS).To(Equal(float32(75.5)))
+			Expect(config.Burst).To(Equal(150))
+		})
+	})
+})
+
+var _ = Describe("ApplyMemoryLimit", func() {
+	var (
+		setLimit int64
+		called   bool
+	)
+	setMemoryLimit := func(limit int64) int64 {
+		called = true
+		setLimit = limit
+		return 0
+	}
+	BeforeEach(func() {
+		setLimit = 0
+		called = false
+	})
+
+	It("leaves the runtime's memory limit alone by default", func() {
+		Expect(ApplyMemoryLimit(GreenplumOperatorOptions{}, setMemoryLimit)).To(Succeed())
+		Expect(called).To(BeFalse())
+	})
+	It("sets the runtime's memory limit in bytes", func() {
+		Expect(ApplyMemoryLimit(GreenplumOperatorOptions{MemoryLimit: "512Mi"}, setMemoryLimit)).To(Succeed())
+		Expect(setLimit).To(Equal(int64(512 * 1024 * 1024)))
+	})
+	It("rejects a limit that is not a quantity", func() {
+		err := ApplyMemoryLimit(GreenplumOperatorOptions{MemoryLimit: "lots"}, setMemoryLimit)
+		Expect(err).To(MatchError(HavePrefix("parsing memoryLimit: ")))
+		Expect(called).To(BeFalse())
+	})
+	It("rejects a limit that is not positive", func() {
+		err := ApplyMemoryLimit(GreenplumOperatorOptions{MemoryLimit: "0"}, setMemoryLimit)
+		Expect(err).To(MatchError("memoryLimit must be positive"))
+		Expect(called).To(BeFalse())
+	})
+})
@@ -0,0 +1,520 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often a pending backup checks whether it can start
+const backupPendingPollInterval = 30 * time.Second
+
+// GreenplumBackupReconciler reconciles a GreenplumBackup object
+type GreenplumBackupReconciler struct {
+	client.Client
+	Log           logr.Logger
+	InstanceImage string
+	PodExec       executor.PodExecInterface
+	Recorder      record.EventRecorder
+	// NewS3Lister connects to S3 to measure a finished backup
+	NewS3Lister func(s3 greenplumv1.GreenplumBackupS3Spec, accessKeyID, secretAccessKey string) (backupjob.S3Lister, error)
+	// Now is time.Now, unless replaced for testing
+	Now func() time.Time
+}
+
+var _ client.Client = &GreenplumBackupReconciler{}
+
+// +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumbackups/status,verbs=get;update;patch
+
+func (r *GreenplumBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var greenplumBackup greenplumv1.GreenplumBackup
+	if err := r.Get(ctx, req.NamespacedName, &greenplumBackup); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("unable to fetch GreenplumBackup: %w", err)
+	}
+
+	jobKey := backupJobKey(&greenplumBackup)
+	job, err := r.getJob(ctx, jobKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch greenplumBackup.Status.Phase {
+	case greenplumv1.GreenplumBackupPhaseSucceeded, greenplumv1.GreenplumBackupPhaseFailed:
+		return r.reconcileFinished(ctx, &greenplumBackup, job)
+	case greenplumv1.GreenplumBackupPhaseRunning:
+		if job == nil {
+			return ctrl.Result{}, r.finishBackup(ctx, &greenplumBackup, greenplumBackup.DeepCopy(), false,
+				"JobDeleted", fmt.Sprintf("gpbackup job %s was deleted before it finished", jobKey.Name))
+		}
+	}
+
+	if job != nil {
+		switch {
+		case job.Status.Succeeded > 0:
+			if err := r.recordBackupSucceeded(ctx, &greenplumBackup, job); err != nil {
+				return ctrl.Result{}, err
+			}
+			return r.reconcileFinished(ctx, &greenplumBackup, job)
+		case job.Status.Failed > 0:
+			if err := r.recordBackupFailed(ctx, &greenplumBackup, job); err != nil {
+				return ctrl.Result{}, err
+			}
+			return r.reconcileFinished(ctx, &greenplumBackup, job)
+		}
+		// the job is owned, so it finishing triggers another reconcile
+		return ctrl.Result{}, r.setBackupRunning(ctx, &greenplumBackup, job)
+	}
+
+	return r.startBackup(ctx, &greenplumBackup)
+}
+
+// startBackup creates the backup job once the cluster is Running and every earlier backup of the cluster has finished.
+// Backups are started oldest first, so two backups of a cluster never run at the same time. The backup fails without
+// a job if its gpbackup options are invalid
+func (r *GreenplumBackupReconciler) startBackup(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup) (ctrl.Result, error) {
+	if err := backupjob.ValidateBackupSpec(greenplumBackup.Spec); err != nil {
+		return ctrl.Result{}, r.finishBackup(ctx, greenplumBackup, greenplumBackup.DeepCopy(), false, "InvalidSpec", err.Error())
+	}
+
+	earlier, err := r.earlierUnfinishedBackup(ctx, greenplumBackup)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if earlier != "" {
+		return r.setBackupPending(ctx, greenplumBackup, fmt.Sprintf("waiting for backup %s of cluster %s to finish", earlier, greenplumBackup.Spec.ClusterName))
+	}
+
+	var greenplumCluster greenplumv1.GreenplumCluster
+	clusterKey := types.NamespacedName{Namespace: greenplumBackup.Namespace, Name: greenplumBackup.Spec.ClusterName}
+	if err := r.Get(ctx, clusterKey, &greenplumCluster); err != nil {
+		if apierrs.IsNotFound(err) {
+			return r.setBackupPending(ctx, greenplumBackup, fmt.Sprintf("GreenplumCluster %s not found", clusterKey.Name))
+		}
+		return ctrl.Result{}, err
+	}
+	if greenplumCluster.Status.Phase != greenplumv1.GreenplumClusterPhaseRunning {
+		return r.setBackupPending(ctx, greenplumBackup, fmt.Sprintf("waiting for GreenplumCluster %s to be Running", clusterKey.Name))
+	}
+
+	if s3 := greenplumBackup.Spec.S3; s3 != nil {
+		var credentials corev1.Secret
+		credentialsKey := types.NamespacedName{Namespace: greenplumBackup.Namespace, Name: s3.Credentials.SecretName}
+		if err := r.Get(ctx, credentialsKey, &credentials); err != nil {
+			if apierrs.IsNotFound(err) {
+				return r.setBackupPending(ctx, greenplumBackup, fmt.Sprintf("S3 credentials Secret %s not found", credentialsKey.Name))
+			}
+			return ctrl.Result{}, err
+		}
+		if s3.Encryption != nil {
+			if err := backupjob.ValidateEncryptionSecret(ctx, r, greenplumBackup.Namespace, *s3.Encryption); err != nil {
+				return r.setBackupPending(ctx, greenplumBackup, "waiting for a valid encryption key: "+err.Error())
+			}
+		}
+	}
+	if caBundle := greenplumBackup.Spec.CABundle; caBundle != nil {
+		if err := cabundle.ValidateConfigMap(ctx, r, greenplumBackup.Namespace, *caBundle); err != nil {
+			return r.setBackupPending(ctx, greenplumBackup, "waiting for a valid caBundle: "+err.Error())
+		}
+	}
+
+	activeMaster := executor.GetCurrentActiveMaster(r.PodExec, greenplumBackup.Namespace)
+	if activeMaster == "" {
+		return r.setBackupPending(ctx, greenplumBackup, fmt.Sprintf("no active master found for GreenplumCluster %s", clusterKey.Name))
+	}
+
+	jobKey := backupJobKey(greenplumBackup)
+	hostname := fmt.Sprintf("%s.agent.%s.svc.cluster.local", activeMaster, greenplumBackup.Namespace)
+	job := backupjob.GenerateGreenplumBackupJob(r.InstanceImage, hostname, greenplumBackup.Spec)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Labels = map[string]string{
+		"greenplum-cluster": greenplumBackup.Spec.ClusterName,
+		"greenplum-backup":  greenplumBackup.Name,
+	}
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+	if err := ctrl.SetControllerReference(greenplumBackup, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return ctrl.Result{}, fmt.Errorf("creating gpbackup job: %w", err)
+	}
+	r.Log.Info("started backup", "greenplumbackup", greenplumBackup.Name, "job", job.Name)
+	r.recordEvent(greenplumBackup, corev1.EventTypeNormal, "BackupStarted",
+		fmt.Sprintf("backing up GreenplumCluster %s to %s", clusterKey.Name, backupDestination(greenplumBackup.Spec)))
+	return ctrl.Result{}, r.setBackupRunning(ctx, greenplumBackup, &job)
+}
+
+// backupDestination describes where the backup is written: its S3 folder, or its backupDir
+func backupDestination(spec greenplumv1.GreenplumBackupSpec) string {
+	if spec.S3 != nil {
+		return fmt.Sprintf("s3://%s/%s", spec.S3.Bucket, spec.S3.Prefix)
+	}
+	return spec.BackupDir
+}
+
+// earlierUnfinishedBackup returns the name of a backup of the same cluster that was created before greenplumBackup
+// and has not finished yet, or "" if there is none
+func (r *GreenplumBackupReconciler) earlierUnfinishedBackup(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup) (string, error) {
+	var backups greenplumv1.GreenplumBackupList
+	if err := r.List(ctx, &backups, client.InNamespace(greenplumBackup.Namespace)); err != nil {
+		return "", fmt.Errorf("listing GreenplumBackups: %w", err)
+	}
+	for _, other := range backups.Items {
+		if other.Spec.ClusterName != greenplumBackup.Spec.ClusterName || other.Name == greenplumBackup.Name {
+			continue
+		}
+		if other.Status.Phase == greenplumv1.GreenplumBackupPhaseSucceeded || other.Status.Phase == greenplumv1.GreenplumBackupPhaseFailed {
+			continue
+		}
+		if createdBefore(&other, greenplumBackup) {
+			return other.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// createdBefore orders backups by creation, and by name when they were created in the same second
+func createdBefore(a, b *greenplumv1.GreenplumBackup) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+func (r *GreenplumBackupReconciler) setBackupPending(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, message string) (ctrl.Result, error) {
+	originalGreenplumBackup := greenplumBackup.DeepCopy()
+	greenplumBackup.Status.Phase = greenplumv1.GreenplumBackupPhasePending
+	greenplumBackup.Status.Message = message
+	if err := r.patchStatus(ctx, greenplumBackup, originalGreenplumBackup); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: backupPendingPollInterval}, nil
+}
+
+func (r *GreenplumBackupReconciler) setBackupRunning(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, job *batchv1.Job) error {
+	originalGreenplumBackup := greenplumBackup.DeepCopy()
+	greenplumBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseRunning
+	greenplumBackup.Status.Message = ""
+	greenplumBackup.Status.JobName = job.Name
+	if greenplumBackup.Status.StartTime == nil {
+		startTime := metav1.NewTime(r.now())
+		greenplumBackup.Status.StartTime = &startTime
+	}
+	return r.patchStatus(ctx, greenplumBackup, originalGreenplumBackup)
+}
+
+// recordBackupSucceeded records the timestamp key that the job reported, the result of verifying the backup,
+// and the size of a backup in S3
+func (r *GreenplumBackupReconciler) recordBackupSucceeded(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, job *batchv1.Job) error {
+	originalGreenplumBackup := greenplumBackup.DeepCopy()
+	if err := r.recordJobResults(ctx, greenplumBackup, job); err != nil {
+		return err
+	}
+	if greenplumBackup.Status.Timestamp == "" {
+		return r.finishBackup(ctx, greenplumBackup, originalGreenplumBackup, false,
+			"TimestampNotFound", fmt.Sprintf("gpbackup job %s succeeded without reporting the backup timestamp", job.Name))
+	}
+
+	message := fmt.Sprintf("backup %s written to %s", greenplumBackup.Status.Timestamp, backupDestination(greenplumBackup.Spec))
+	if verification := greenplumBackup.Status.Verification; verification != nil && verification.Succeeded {
+		message += " and verified"
+	}
+	if greenplumBackup.Spec.S3 != nil {
+		size, err := r.backupSize(ctx, greenplumBackup)
+		if err != nil {
+			r.Log.Error(err, "measuring backup size", "greenplumbackup", greenplumBackup.Name)
+			message += "; its size could not be measured: " + err.Error()
+		}
+		greenplumBackup.Status.SizeBytes = size
+	}
+	return r.finishBackup(ctx, greenplumBackup, originalGreenplumBackup, true, "BackupSucceeded", message)
+}
+
+// recordBackupFailed fails the backup, saying why when gpbackup succeeded but the backup could not be verified
+func (r *GreenplumBackupReconciler) recordBackupFailed(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, job *batchv1.Job) error {
+	originalGreenplumBackup := greenplumBackup.DeepCopy()
+	if err := r.recordJobResults(ctx, greenplumBackup, job); err != nil {
+		return err
+	}
+	if verification := greenplumBackup.Status.Verification; verification != nil && !verification.Succeeded {
+		return r.finishBackup(ctx, greenplumBackup, originalGreenplumBackup, false,
+			"VerificationFailed", fmt.Sprintf("backup %s could not be restored: %s", verification.Timestamp, verification.Message))
+	}
+	return r.finishBackup(ctx, greenplumBackup, originalGreenplumBackup, false,
+		"JobFailed", fmt.Sprintf("gpbackup job %s failed; check its logs", job.Name))
+}
+
+// recordJobResults records the timestamp key and the verification result that the job reported in its termination message
+func (r *GreenplumBackupReconciler) recordJobResults(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, job *batchv1.Job) error {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return fmt.Errorf("listing gpbackup job pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if timestamp := backupjob.BackupTimestamp(pod); timestamp != "" {
+			greenplumBackup.Status.Timestamp = timestamp
+		}
+		if verification := backupjob.VerificationStatus(pod); verification != nil {
+			greenplumBackup.Status.Verification = verification
+		}
+	}
+	return nil
+}
+
+func (r *GreenplumBackupReconciler) backupSize(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup) (int64, error) {
+	lister, err := r.s3Lister(ctx, greenplumBackup)
+	if err != nil {
+		return 0, err
+	}
+	return backupjob.BackupSize(lister, *greenplumBackup.Spec.S3, greenplumBackup.Status.Timestamp)
+}
+
+// s3Lister connects to S3 with the credentials of a backup to S3
+func (r *GreenplumBackupReconciler) s3Lister(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup) (backupjob.S3Lister, error) {
+	s3 := *greenplumBackup.Spec.S3
+	var credentials corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumBackup.Namespace, Name: s3.Credentials.SecretName}, &credentials); err != nil {
+		return nil, fmt.Errorf("getting S3 credentials Secret %s: %w", s3.Credentials.SecretName, err)
+	}
+	lister, err := r.NewS3Lister(s3,
+		string(credentials.Data[backupjob.S3AccessKeyIDKey(s3.Credentials)]),
+		string(credentials.Data[backupjob.S3SecretAccessKeyKey(s3.Credentials)]))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to S3: %w", err)
+	}
+	return lister, nil
+}
+
+// finishBackup sets the final phase and condition of the backup, and emits an Event. The status is patched
+// from originalGreenplumBackup, so that results the caller already recorded on greenplumBackup are saved too
+func (r *GreenplumBackupReconciler) finishBackup(ctx context.Context, greenplumBackup, originalGreenplumBackup *greenplumv1.GreenplumBackup, succeeded bool, reason, message string) error {
+	phase, conditionType, eventType := greenplumv1.GreenplumBackupPhaseFailed, greenplumv1.GreenplumBackupConditionFailed, corev1.EventTypeWarning
+	if succeeded {
+		phase, conditionType, eventType = greenplumv1.GreenplumBackupPhaseSucceeded, greenplumv1.GreenplumBackupConditionComplete, corev1.EventTypeNormal
+	}
+	greenplumBackup.Status.Phase = phase
+	greenplumBackup.Status.Message = ""
+	completionTime := metav1.NewTime(r.now())
+	greenplumBackup.Status.CompletionTime = &completionTime
+	meta.SetStatusCondition(&greenplumBackup.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: greenplumBackup.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if err := r.patchStatus(ctx, greenplumBackup, originalGreenplumBackup); err != nil {
+		return err
+	}
+	r.Log.Info("backup finished", "greenplumbackup", greenplumBackup.Name, "phase", phase, "message", message)
+	r.recordEvent(greenplumBackup, eventType, reason, message)
+	return nil
+}
+
+// reconcileFinished enforces the retention policy once the backup has succeeded, and then cleans up its jobs
+func (r *GreenplumBackupReconciler) reconcileFinished(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, job *batchv1.Job) (ctrl.Result, error) {
+	pruneJob, err := r.getJob(ctx, pruneJobKey(greenplumBackup))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if greenplumBackup.Status.Phase == greenplumv1.GreenplumBackupPhaseSucceeded && greenplumBackup.Spec.Retention != nil && greenplumBackup.Spec.S3 != nil &&
+		meta.FindStatusCondition(greenplumBackup.Status.Conditions, greenplumv1.GreenplumBackupConditionPruned) == nil {
+		done, err := r.enforceRetention(ctx, greenplumBackup, pruneJob)
+		if err != nil || !done {
+			return ctrl.Result{}, err
+		}
+	}
+	return r.cleanUpJobs(ctx, greenplumBackup, job, pruneJob)
+}
+
+// enforceRetention starts a job that deletes the backups in the S3 folder that are past the retention policy, and
+// records which were deleted once it finishes. It returns true once the policy has been enforced
+func (r *GreenplumBackupReconciler) enforceRetention(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, pruneJob *batchv1.Job) (bool, error) {
+	s3 := *greenplumBackup.Spec.S3
+	if pruneJob == nil {
+		lister, err := r.s3Lister(ctx, greenplumBackup)
+		if err != nil {
+			return false, err
+		}
+		timestamps, err := backupjob.ListBackupTimestamps(lister, s3)
+		if err != nil {
+			return false, err
+		}
+		expired := backupjob.ExpiredBackups(timestamps, greenplumBackup.Status.Timestamp, *greenplumBackup.Spec.Retention, r.now())
+		if len(expired) == 0 {
+			return true, r.finishPruning(ctx, greenplumBackup, nil, true,
+				"NothingToPrune", fmt.Sprintf("no backups in s3://%s/%s are past the retention policy", s3.Bucket, s3.Prefix))
+		}
+		return false, r.startPruneJob(ctx, greenplumBackup, expired)
+	}
+
+	// the prune job is owned, so it finishing triggers another reconcile
+	if pruneJob.Status.Succeeded == 0 && pruneJob.Status.Failed == 0 {
+		return false, nil
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(pruneJob.Namespace), client.MatchingLabels{"job-name": pruneJob.Name}); err != nil {
+		return false, fmt.Errorf("listing prune job pods: %w", err)
+	}
+	var pruned []string
+	for _, pod := range pods.Items {
+		pruned = append(pruned, backupjob.PrunedBackups(pod)...)
+	}
+	if pruneJob.Status.Failed > 0 {
+		return true, r.finishPruning(ctx, greenplumBackup, pruned, false,
+			"PruneJobFailed", fmt.Sprintf("prune job %s failed after deleting %d backups; check its logs", pruneJob.Name, len(pruned)))
+	}
+	return true, r.finishPruning(ctx, greenplumBackup, pruned, true,
+		"BackupsPruned", fmt.Sprintf("deleted %d backups from s3://%s/%s", len(pruned), s3.Bucket, s3.Prefix))
+}
+
+func (r *GreenplumBackupReconciler) startPruneJob(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, expired []string) error {
+	jobKey := pruneJobKey(greenplumBackup)
+	job := backupjob.GeneratePruneJob(r.InstanceImage, greenplumBackup.Spec, expired)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Labels = map[string]string{
+		"greenplum-cluster": greenplumBackup.Spec.ClusterName,
+		"greenplum-backup":  greenplumBackup.Name,
+	}
+	var greenplumCluster greenplumv1.GreenplumCluster
+	clusterKey := types.NamespacedName{Namespace: greenplumBackup.Namespace, Name: greenplumBackup.Spec.ClusterName}
+	if err := r.Get(ctx, clusterKey, &greenplumCluster); err == nil {
+		job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+	} else if !apierrs.IsNotFound(err) {
+		return err
+	}
+	if err := ctrl.SetControllerReference(greenplumBackup, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return fmt.Errorf("creating prune job: %w", err)
+	}
+	r.Log.Info("started prune job", "greenplumbackup", greenplumBackup.Name, "job", job.Name, "backups", expired)
+	return nil
+}
+
+// finishPruning records the backups that the retention policy deleted, and emits an Event
+func (r *GreenplumBackupReconciler) finishPruning(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, pruned []string, succeeded bool, reason, message string) error {
+	originalGreenplumBackup := greenplumBackup.DeepCopy()
+	conditionStatus, eventType := metav1.ConditionFalse, corev1.EventTypeWarning
+	if succeeded {
+		conditionStatus, eventType = metav1.ConditionTrue, corev1.EventTypeNormal
+	}
+	greenplumBackup.Status.PrunedBackups = pruned
+	meta.SetStatusCondition(&greenplumBackup.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumBackupConditionPruned,
+		Status:             conditionStatus,
+		ObservedGeneration: greenplumBackup.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if err := r.patchStatus(ctx, greenplumBackup, originalGreenplumBackup); err != nil {
+		return err
+	}
+	r.Log.Info("retention policy enforced", "greenplumbackup", greenplumBackup.Name, "pruned", pruned, "message", message)
+	r.recordEvent(greenplumBackup, eventType, reason, message)
+	return nil
+}
+
+// cleanUpJobs deletes the jobs of a finished backup once spec.ttlSecondsAfterFinished has passed
+func (r *GreenplumBackupReconciler) cleanUpJobs(ctx context.Context, greenplumBackup *greenplumv1.GreenplumBackup, jobs ...*batchv1.Job) (ctrl.Result, error) {
+	ttl := greenplumBackup.Spec.TTLSecondsAfterFinished
+	if ttl == nil || greenplumBackup.Status.CompletionTime == nil {
+		return ctrl.Result{}, nil
+	}
+	expiry := greenplumBackup.Status.CompletionTime.Add(time.Duration(*ttl) * time.Second)
+	if remaining := expiry.Sub(r.now()); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrs.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("deleting job %s: %w", job.Name, err)
+		}
+		r.Log.Info("deleted job", "greenplumbackup", greenplumBackup.Name, "job", job.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// getJob returns the job with key, or nil if it does not exist
+func (r *GreenplumBackupReconciler) getJob(ctx context.Context, key types.NamespacedName) (*batchv1.Job, error) {
+	var job batchv1.Job
+	if err := r.Get(ctx, key, &job); err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *GreenplumBackupReconciler) patchStatus(ctx context.Context, greenplumBackup, originalGreenplumBackup *greenplumv1.GreenplumBackup) error {
+	if equality.Semantic.DeepEqual(greenplumBackup, originalGreenplumBackup) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumBackup, client.MergeFrom(originalGreenplumBackup)); err != nil {
+		return fmt.Errorf("updating GreenplumBackup status: %w", err)
+	}
+	return nil
+}
+
+// recordEvent emits an Event on the GreenplumBackup, when the reconciler has a Recorder
+func (r *GreenplumBackupReconciler) recordEvent(greenplumBackup *greenplumv1.GreenplumBackup, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(greenplumBackup, eventType, reason, message)
+}
+
+func (r *GreenplumBackupReconciler) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
+
+func backupJobKey(greenplumBackup *greenplumv1.GreenplumBackup) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: greenplumBackup.Namespace,
+		Name:      fmt.Sprintf("%s-gpbackup-job", greenplumBackup.Name),
+	}
+}
+
+func pruneJobKey(greenplumBackup *greenplumv1.GreenplumBackup) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: greenplumBackup.Namespace,
+		Name:      fmt.Sprintf("%s-gpbackup-prune-job", greenplumBackup.Name),
+	}
+}
+
+func (r *GreenplumBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&greenplumv1.GreenplumBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,825 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	cabundletesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle/testing"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("GreenplumBackup controller", func() {
+	var (
+		ctx              context.Context
+		logBuf           *gbytes.Buffer
+		podExec          *fake.PodExec
+		recorder         *record.FakeRecorder
+		s3Lister         *fakeS3Lister
+		now              time.Time
+		backupReconciler *GreenplumBackupReconciler
+		greenplumBackup  *greenplumv1.GreenplumBackup
+		greenplumCluster *greenplumv1.GreenplumCluster
+		credentials      *corev1.Secret
+
+		backupRequest = reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: "test-ns", Name: "nightly"},
+		}
+		jobKey      = types.NamespacedName{Namespace: "test-ns", Name: "nightly-gpbackup-job"}
+		pruneJobKey = types.NamespacedName{Namespace: "test-ns", Name: "nightly-gpbackup-prune-job"}
+	)
+
+	getBackup := func() *greenplumv1.GreenplumBackup {
+		var backup greenplumv1.GreenplumBackup
+		Expect(reactiveClient.Get(ctx, backupRequest.NamespacedName, &backup)).To(Succeed())
+		return &backup
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		s3Lister = &fakeS3Lister{}
+		now = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		backupReconciler = &GreenplumBackupReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			InstanceImage: "greenplum-for-kubernetes:v1.7.5",
+			PodExec:       podExec,
+			Recorder:      recorder,
+			NewS3Lister: func(s3 greenplumv1.GreenplumBackupS3Spec, accessKeyID, secretAccessKey string) (backupjob.S3Lister, error) {
+				s3Lister.accessKeyID, s3Lister.secretAccessKey = accessKeyID, secretAccessKey
+				return s3Lister, nil
+			},
+			Now: func() time.Time { return now },
+		}
+
+		greenplumBackup = &greenplumv1.GreenplumBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "test-ns",
+				Name:              "nightly",
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			},
+			Spec: greenplumv1.GreenplumBackupSpec{
+				ClusterName: "my-greenplum",
+				Mode:        greenplumv1.GreenplumBackupModeFull,
+				S3: &greenplumv1.GreenplumBackupS3Spec{
+					Bucket:      "backups",
+					Prefix:      "greenplum/prod",
+					Region:      "us-east-1",
+					Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+				},
+			},
+		}
+		greenplumCluster = &greenplumv1.GreenplumCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-greenplum"},
+			Spec:       greenplumv1.GreenplumClusterSpec{SchedulerName: "my-scheduler"},
+			Status:     greenplumv1.GreenplumClusterStatus{Phase: greenplumv1.GreenplumClusterPhaseRunning},
+		}
+		credentials = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "s3-creds"},
+			Data: map[string][]byte{
+				"aws_access_key_id":     []byte("my-id"),
+				"aws_secret_access_key": []byte("my-secret"),
+			},
+		}
+	})
+
+	When("the backup does not exist", func() {
+		It("does nothing", func() {
+			result, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+		})
+	})
+
+	When("getting the backup fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("get", "greenplumbackups", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns the error", func() {
+			_, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).To(MatchError("unable to fetch GreenplumBackup: injected error"))
+		})
+	})
+
+	When("a new backup can start", func() {
+		JustBeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+		})
+
+		It("creates a backup job on the active master", func() {
+			result, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Labels).To(Equal(map[string]string{
+				"greenplum-cluster": "my-greenplum",
+				"greenplum-backup":  "nightly",
+			}))
+			Expect(job.OwnerReferences).To(HaveLen(1))
+			Expect(job.OwnerReferences[0].Kind).To(Equal("GreenplumBackup"))
+			Expect(job.OwnerReferences[0].Name).To(Equal("nightly"))
+			backupPod := job.Spec.Template.Spec
+			Expect(backupPod.SchedulerName).To(Equal("my-scheduler"))
+			Expect(backupPod.Containers[0].Image).To(Equal("greenplum-for-kubernetes:v1.7.5"))
+			Expect(backupPod.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "GPBACKUP_HOST",
+				Value: "master-0.agent.test-ns.svc.cluster.local",
+			}))
+		})
+
+		It("marks the backup Running", func() {
+			_, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			backup := getBackup()
+			Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseRunning))
+			Expect(backup.Status.JobName).To(Equal("nightly-gpbackup-job"))
+			Expect(backup.Status.StartTime.Time).To(BeTemporally("==", now))
+			Expect(recorder.Events).To(Receive(Equal("Normal BackupStarted backing up GreenplumCluster my-greenplum to s3://backups/greenplum/prod")))
+		})
+
+		It("backs up on master-1 when it is the active master", func() {
+			podExec.ErrorMsgOnMaster0 = "master-0 is not active"
+			_, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "GPBACKUP_HOST",
+				Value: "master-1.agent.test-ns.svc.cluster.local",
+			}))
+		})
+
+		When("the spec has gpbackup options", func() {
+			BeforeEach(func() {
+				greenplumBackup.Spec.Database = "sales"
+				greenplumBackup.Spec.IncludeSchemas = []string{"tenant_a"}
+				greenplumBackup.Spec.WithStats = true
+			})
+			It("passes them to gpbackup", func() {
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+					"--dbname", "sales",
+					"--include-schema", "tenant_a",
+					"--with-stats",
+					"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+				}))
+			})
+		})
+
+		When("the backup is written to a backup directory", func() {
+			BeforeEach(func() {
+				greenplumBackup.Spec.S3 = nil
+				greenplumBackup.Spec.BackupDir = "/backups"
+			})
+			It("backs up there without S3 credentials", func() {
+				Expect(reactiveClient.Delete(ctx, credentials)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+					"--dbname", "gpadmin",
+					"--backup-dir", "/backups",
+				}))
+				Expect(recorder.Events).To(Receive(Equal("Normal BackupStarted backing up GreenplumCluster my-greenplum to /backups")))
+			})
+		})
+
+		When("the spec has a CA bundle", func() {
+			BeforeEach(func() {
+				greenplumBackup.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "s3-ca"}
+				Expect(reactiveClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "s3-ca"},
+					Data:       map[string]string{"ca.crt": string(cabundletesting.SelfSignedCAPEM("s3-ca"))},
+				})).To(Succeed())
+			})
+			It("mounts it into the trust store of the backup job", func() {
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Volumes).To(ContainElement(cabundle.Volume(*greenplumBackup.Spec.CABundle)))
+				Expect(job.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+					Name:      "ca-bundle",
+					MountPath: "/etc/ssl/certs/greenplum-ca-bundle.crt",
+					SubPath:   "greenplum-ca-bundle.crt",
+					ReadOnly:  true,
+				}))
+			})
+		})
+
+		When("the spec has a plugin image", func() {
+			BeforeEach(func() {
+				greenplumBackup.Spec.PluginImage = "registry.example.com/gpbackup-plugins:1.0"
+			})
+			It("runs the backup job in it", func() {
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.example.com/gpbackup-plugins:1.0"))
+			})
+		})
+
+		When("the gpbackup options are invalid", func() {
+			BeforeEach(func() {
+				greenplumBackup.Spec.IncludeTables = []string{"public.orders"}
+				greenplumBackup.Spec.ExcludeTables = []string{"public.scratch"}
+			})
+			It("fails the backup without a job", func() {
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseFailed))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionFailed)
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Reason).To(Equal("InvalidSpec"))
+				Expect(condition.Message).To(Equal("includeTables and excludeTables cannot be used together"))
+				var job batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+				Expect(recorder.Events).To(Receive(Equal("Warning InvalidSpec includeTables and excludeTables cannot be used together")))
+			})
+		})
+
+		When("creating the job fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("create", "jobs", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("injected error")
+				})
+			})
+			It("returns the error", func() {
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).To(MatchError("creating gpbackup job: injected error"))
+			})
+		})
+	})
+
+	When("the backup cannot start yet", func() {
+		expectPending := func(message string) {
+			result, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{RequeueAfter: 30 * time.Second}))
+
+			backup := getBackup()
+			Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhasePending))
+			Expect(backup.Status.Message).To(Equal(message))
+			var job batchv1.Job
+			Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+		}
+
+		BeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+		})
+
+		It("waits for the cluster to exist", func() {
+			expectPending("GreenplumCluster my-greenplum not found")
+		})
+
+		It("waits for the cluster to be Running", func() {
+			greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhasePending
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			expectPending("waiting for GreenplumCluster my-greenplum to be Running")
+		})
+
+		It("waits for the S3 credentials Secret", func() {
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			expectPending("S3 credentials Secret s3-creds not found")
+		})
+
+		It("waits for a valid encryption key", func() {
+			backup := getBackup()
+			backup.Spec.S3.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key"}
+			Expect(reactiveClient.Update(ctx, backup)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "backup-key"},
+				Data:       map[string][]byte{"encryption.key": []byte("too short")},
+			})).To(Succeed())
+			expectPending(`waiting for a valid encryption key: Secret "backup-key" key "encryption.key": must be a 256-bit key of 32 bytes, not 9 bytes`)
+		})
+
+		It("waits for a valid CA bundle", func() {
+			backup := getBackup()
+			backup.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "s3-ca"}
+			Expect(reactiveClient.Update(ctx, backup)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			expectPending(`waiting for a valid caBundle: ConfigMap "s3-ca" not found`)
+		})
+
+		It("waits for an active master", func() {
+			podExec.ErrorMsgOnMaster0 = "master-0 is not active"
+			podExec.ErrorMsgOnMaster1 = "master-1 is not active"
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			expectPending("no active master found for GreenplumCluster my-greenplum")
+		})
+
+		When("an earlier backup of the cluster has not finished", func() {
+			var earlierBackup *greenplumv1.GreenplumBackup
+			BeforeEach(func() {
+				Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+				Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+				earlierBackup = greenplumBackup.DeepCopy()
+				earlierBackup.ResourceVersion = ""
+				earlierBackup.Name = "hourly"
+				earlierBackup.CreationTimestamp = metav1.NewTime(now.Add(-2 * time.Hour))
+			})
+
+			It("waits for it to finish", func() {
+				earlierBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseRunning
+				Expect(reactiveClient.Create(ctx, earlierBackup)).To(Succeed())
+				expectPending("waiting for backup hourly of cluster my-greenplum to finish")
+			})
+
+			It("starts once it has finished", func() {
+				earlierBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseSucceeded
+				Expect(reactiveClient.Create(ctx, earlierBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(getBackup().Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseRunning))
+			})
+
+			It("does not wait for a backup of another cluster", func() {
+				earlierBackup.Spec.ClusterName = "other-greenplum"
+				Expect(reactiveClient.Create(ctx, earlierBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(getBackup().Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseRunning))
+			})
+
+			It("orders backups created in the same second by name", func() {
+				earlierBackup.CreationTimestamp = greenplumBackup.CreationTimestamp
+				earlierBackup.Name = "zzz"
+				Expect(reactiveClient.Create(ctx, earlierBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(getBackup().Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseRunning))
+			})
+		})
+	})
+
+	When("the backup job has finished", func() {
+		var job *batchv1.Job
+		BeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			greenplumBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseRunning
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+			job = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name}}
+		})
+
+		createJobPod := func(message string) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "test-ns",
+					Name:      "nightly-gpbackup-job-abcde",
+					Labels:    map[string]string{"job-name": jobKey.Name},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name: "gpbackup",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{Message: message},
+						},
+					}},
+				},
+			}
+			Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+		}
+
+		When("it succeeded", func() {
+			BeforeEach(func() {
+				job.Status.Succeeded = 1
+				Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			})
+
+			It("records the timestamp and size of the backup", func() {
+				createJobPod("timestamp=20200102030405\n")
+				s3Lister.objects = []minio.ObjectInfo{
+					{Key: "greenplum/prod/backups/20200102/20200102030405/gpbackup_20200102030405_metadata.sql", Size: 1000},
+					{Key: "greenplum/prod/backups/20200102/20200102030405/gpbackup_20200102030405_report", Size: 24},
+					{Key: "greenplum/prod/backups/20200101/20200101000000/gpbackup_20200101000000_report", Size: 99},
+				}
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+				Expect(backup.Status.Timestamp).To(Equal("20200102030405"))
+				Expect(backup.Status.SizeBytes).To(Equal(int64(1024)))
+				Expect(backup.Status.CompletionTime.Time).To(BeTemporally("==", now))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionComplete)
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				Expect(condition.Reason).To(Equal("BackupSucceeded"))
+				Expect(condition.Message).To(Equal("backup 20200102030405 written to s3://backups/greenplum/prod"))
+				Expect(recorder.Events).To(Receive(Equal("Normal BackupSucceeded backup 20200102030405 written to s3://backups/greenplum/prod")))
+
+				Expect(s3Lister.accessKeyID).To(Equal("my-id"))
+				Expect(s3Lister.secretAccessKey).To(Equal("my-secret"))
+				Expect(s3Lister.prefix).To(Equal("greenplum/prod/backups/20200102/20200102030405/"))
+			})
+
+			It("enforces the retention policy once the backup is recorded", func() {
+				createJobPod("timestamp=20200102030405\n")
+				maxCount := int32(1)
+				backup := getBackup()
+				backup.Spec.Retention = &greenplumv1.GreenplumBackupRetentionSpec{MaxCount: &maxCount}
+				Expect(reactiveClient.Update(ctx, backup)).To(Succeed())
+				s3Lister.objects = []minio.ObjectInfo{
+					{Key: "greenplum/prod/backups/20200102/20200102030405/gpbackup_20200102030405_report"},
+					{Key: "greenplum/prod/backups/20200101/20200101000000/gpbackup_20200101000000_report"},
+				}
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(getBackup().Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+				var pruneJob batchv1.Job
+				Expect(reactiveClient.Get(ctx, pruneJobKey, &pruneJob)).To(Succeed())
+				Expect(pruneJob.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"20200101000000"}))
+			})
+
+			It("still succeeds when the size cannot be measured", func() {
+				createJobPod("timestamp=20200102030405\n")
+				s3Lister.objects = []minio.ObjectInfo{{Err: errors.New("injected error")}}
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+				Expect(backup.Status.SizeBytes).To(BeZero())
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionComplete)
+				Expect(condition.Message).To(ContainSubstring("its size could not be measured"))
+			})
+
+			It("does not measure the size of a backup to a backup directory", func() {
+				createJobPod("timestamp=20200102030405\n")
+				backup := getBackup()
+				backup.Spec.S3 = nil
+				backup.Spec.BackupDir = "/backups"
+				Expect(reactiveClient.Update(ctx, backup)).To(Succeed())
+				s3Lister.objects = []minio.ObjectInfo{{Err: errors.New("injected error")}}
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup = getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+				Expect(backup.Status.SizeBytes).To(BeZero())
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionComplete)
+				Expect(condition.Message).To(Equal("backup 20200102030405 written to /backups"))
+			})
+
+			It("records that the backup was verified", func() {
+				createJobPod("verification=succeeded\ntimestamp=20200102030405\n")
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+				Expect(backup.Status.Timestamp).To(Equal("20200102030405"))
+				Expect(backup.Status.Verification).To(Equal(&greenplumv1.GreenplumBackupVerificationStatus{
+					Timestamp: "20200102030405",
+					Succeeded: true,
+				}))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionComplete)
+				Expect(condition.Message).To(Equal("backup 20200102030405 written to s3://backups/greenplum/prod and verified"))
+			})
+
+			It("fails the backup when the job did not report a timestamp", func() {
+				createJobPod("")
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseFailed))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionFailed)
+				Expect(condition.Reason).To(Equal("TimestampNotFound"))
+				Expect(recorder.Events).To(Receive(HavePrefix("Warning TimestampNotFound")))
+			})
+		})
+
+		When("it failed", func() {
+			BeforeEach(func() {
+				job.Status.Failed = 1
+				Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			})
+
+			It("fails the backup", func() {
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseFailed))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionFailed)
+				Expect(condition.Reason).To(Equal("JobFailed"))
+				Expect(condition.Message).To(Equal("gpbackup job nightly-gpbackup-job failed; check its logs"))
+				Expect(recorder.Events).To(Receive(Equal("Warning JobFailed gpbackup job nightly-gpbackup-job failed; check its logs")))
+			})
+
+			It("says why when the backup could not be verified", func() {
+				createJobPod("verification=failed\ntimestamp=20200102030405\nmessage=gprestore into gpbackup_verify failed\n")
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseFailed))
+				Expect(backup.Status.Timestamp).To(Equal("20200102030405"))
+				Expect(backup.Status.Verification).To(Equal(&greenplumv1.GreenplumBackupVerificationStatus{
+					Timestamp: "20200102030405",
+					Succeeded: false,
+					Message:   "gprestore into gpbackup_verify failed",
+				}))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionFailed)
+				Expect(condition.Reason).To(Equal("VerificationFailed"))
+				Expect(condition.Message).To(Equal("backup 20200102030405 could not be restored: gprestore into gpbackup_verify failed"))
+				Expect(recorder.Events).To(Receive(Equal("Warning VerificationFailed backup 20200102030405 could not be restored: gprestore into gpbackup_verify failed")))
+			})
+		})
+	})
+
+	When("the job of a running backup was deleted", func() {
+		BeforeEach(func() {
+			greenplumBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseRunning
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+		})
+
+		It("fails the backup", func() {
+			_, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			backup := getBackup()
+			Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseFailed))
+			condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionFailed)
+			Expect(condition.Reason).To(Equal("JobDeleted"))
+		})
+	})
+
+	When("the backup has finished", func() {
+		BeforeEach(func() {
+			completionTime := metav1.NewTime(now.Add(-time.Minute))
+			greenplumBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseSucceeded
+			greenplumBackup.Status.CompletionTime = &completionTime
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name}}
+			job.Status.Succeeded = 1
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+		})
+
+		It("keeps the job when no TTL is set", func() {
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+			result, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+		})
+
+		It("requeues until the TTL has passed", func() {
+			ttl := int32(300)
+			greenplumBackup.Spec.TTLSecondsAfterFinished = &ttl
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+			result, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{RequeueAfter: 4 * time.Minute}))
+
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+		})
+
+		It("deletes the job once the TTL has passed", func() {
+			ttl := int32(60)
+			greenplumBackup.Spec.TTLSecondsAfterFinished = &ttl
+			Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+			result, err := backupReconciler.Reconcile(ctx, backupRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+
+			var job batchv1.Job
+			Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+			Expect(getBackup().Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+		})
+
+		When("a retention policy is set", func() {
+			createPruneJob := func(succeeded bool, message string) {
+				pruneJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: pruneJobKey.Namespace, Name: pruneJobKey.Name}}
+				if succeeded {
+					pruneJob.Status.Succeeded = 1
+				} else {
+					pruneJob.Status.Failed = 1
+				}
+				Expect(reactiveClient.Create(ctx, pruneJob)).To(Succeed())
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "test-ns",
+						Name:      "nightly-gpbackup-prune-job-abcde",
+						Labels:    map[string]string{"job-name": pruneJobKey.Name},
+					},
+					Status: corev1.PodStatus{
+						ContainerStatuses: []corev1.ContainerStatus{{
+							Name: "gpbackup-prune",
+							State: corev1.ContainerState{
+								Terminated: &corev1.ContainerStateTerminated{Message: message},
+							},
+						}},
+					},
+				}
+				Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+			}
+
+			BeforeEach(func() {
+				maxCount := int32(2)
+				greenplumBackup.Spec.Retention = &greenplumv1.GreenplumBackupRetentionSpec{MaxCount: &maxCount}
+				greenplumBackup.Status.Timestamp = "20200102030405"
+				Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+				Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+				s3Lister.objects = []minio.ObjectInfo{
+					{Key: "greenplum/prod/backups/20200102/20200102030405/gpbackup_20200102030405_report"},
+					{Key: "greenplum/prod/backups/20200101/20200101000000/gpbackup_20200101000000_report"},
+					{Key: "greenplum/prod/backups/20191231/20191231000000/gpbackup_20191231000000_report"},
+					{Key: "greenplum/prod/backups/20191230/20191230000000/gpbackup_20191230000000_report"},
+				}
+			})
+
+			It("starts a prune job for the backups past the policy", func() {
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var pruneJob batchv1.Job
+				Expect(reactiveClient.Get(ctx, pruneJobKey, &pruneJob)).To(Succeed())
+				Expect(pruneJob.Labels).To(Equal(map[string]string{
+					"greenplum-cluster": "my-greenplum",
+					"greenplum-backup":  "nightly",
+				}))
+				Expect(pruneJob.OwnerReferences).To(HaveLen(1))
+				Expect(pruneJob.OwnerReferences[0].Name).To(Equal("nightly"))
+				prunePod := pruneJob.Spec.Template.Spec
+				Expect(prunePod.SchedulerName).To(Equal("my-scheduler"))
+				Expect(prunePod.Containers[0].Image).To(Equal("greenplum-for-kubernetes:v1.7.5"))
+				Expect(prunePod.Containers[0].Args).To(Equal([]string{"20191231000000", "20191230000000"}))
+				Expect(s3Lister.accessKeyID).To(Equal("my-id"))
+				Expect(s3Lister.prefix).To(Equal("greenplum/prod/backups/"))
+				Expect(meta.FindStatusCondition(getBackup().Status.Conditions, greenplumv1.GreenplumBackupConditionPruned)).To(BeNil())
+			})
+
+			It("records that there was nothing to prune", func() {
+				s3Lister.objects = s3Lister.objects[:2]
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var pruneJob batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, pruneJobKey, &pruneJob))).To(BeTrue())
+				backup := getBackup()
+				Expect(backup.Status.PrunedBackups).To(BeEmpty())
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionPruned)
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				Expect(condition.Reason).To(Equal("NothingToPrune"))
+				Expect(recorder.Events).To(Receive(Equal("Normal NothingToPrune no backups in s3://backups/greenplum/prod are past the retention policy")))
+			})
+
+			It("returns an error when listing the backups fails", func() {
+				s3Lister.objects = []minio.ObjectInfo{{Err: errors.New("injected error")}}
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).To(MatchError("listing s3://backups/greenplum/prod/backups/: injected error"))
+			})
+
+			It("waits for the prune job to finish", func() {
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				Expect(reactiveClient.Create(ctx, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: pruneJobKey.Namespace, Name: pruneJobKey.Name}})).To(Succeed())
+				result, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(reconcile.Result{}))
+				Expect(meta.FindStatusCondition(getBackup().Status.Conditions, greenplumv1.GreenplumBackupConditionPruned)).To(BeNil())
+			})
+
+			It("records the backups that the prune job deleted", func() {
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				createPruneJob(true, "pruned=20191231000000,20191230000000\n")
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.PrunedBackups).To(Equal([]string{"20191231000000", "20191230000000"}))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionPruned)
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				Expect(condition.Reason).To(Equal("BackupsPruned"))
+				Expect(recorder.Events).To(Receive(Equal("Normal BackupsPruned deleted 2 backups from s3://backups/greenplum/prod")))
+			})
+
+			It("records the backups deleted before the prune job failed", func() {
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				createPruneJob(false, "pruned=20191231000000\n")
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				backup := getBackup()
+				Expect(backup.Status.Phase).To(Equal(greenplumv1.GreenplumBackupPhaseSucceeded))
+				Expect(backup.Status.PrunedBackups).To(Equal([]string{"20191231000000"}))
+				condition := meta.FindStatusCondition(backup.Status.Conditions, greenplumv1.GreenplumBackupConditionPruned)
+				Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				Expect(condition.Reason).To(Equal("PruneJobFailed"))
+				Expect(recorder.Events).To(Receive(Equal("Warning PruneJobFailed prune job nightly-gpbackup-prune-job failed after deleting 1 backups; check its logs")))
+			})
+
+			It("does not prune again once the policy has been enforced", func() {
+				meta.SetStatusCondition(&greenplumBackup.Status.Conditions, metav1.Condition{
+					Type:   greenplumv1.GreenplumBackupConditionPruned,
+					Status: metav1.ConditionTrue,
+					Reason: "BackupsPruned",
+				})
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var pruneJob batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, pruneJobKey, &pruneJob))).To(BeTrue())
+				Expect(s3Lister.prefix).To(BeEmpty())
+			})
+
+			It("deletes the prune job along with the backup job once the TTL has passed", func() {
+				ttl := int32(60)
+				greenplumBackup.Spec.TTLSecondsAfterFinished = &ttl
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				createPruneJob(true, "pruned=20191231000000,20191230000000\n")
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var job batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, pruneJobKey, &job))).To(BeTrue())
+			})
+
+			It("does not prune after a failed backup", func() {
+				greenplumBackup.Status.Phase = greenplumv1.GreenplumBackupPhaseFailed
+				Expect(reactiveClient.Create(ctx, greenplumBackup)).To(Succeed())
+				_, err := backupReconciler.Reconcile(ctx, backupRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var pruneJob batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, pruneJobKey, &pruneJob))).To(BeTrue())
+			})
+		})
+	})
+})
+
+type fakeS3Lister struct {
+	objects         []minio.ObjectInfo
+	prefix          string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func (l *fakeS3Lister) ListObjects(_, objectPrefix string, _ bool, _ <-chan struct{}) <-chan minio.ObjectInfo {
+	l.prefix = objectPrefix
+	objectCh := make(chan minio.ObjectInfo, len(l.objects))
+	for _, object := range l.objects {
+		if object.Err != nil || strings.HasPrefix(object.Key, objectPrefix) {
+			objectCh <- object
+		}
+	}
+	close(objectCh)
+	return objectCh
+}
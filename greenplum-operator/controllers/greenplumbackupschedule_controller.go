@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cron"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GreenplumBackupScheduleReconciler reconciles a GreenplumBackupSchedule object
+type GreenplumBackupScheduleReconciler struct {
+	client.Client
+	Log           logr.Logger
+	InstanceImage string
+	Recorder      record.EventRecorder
+	// Now is time.Now, unless replaced for testing
+	Now func() time.Time
+}
+
+var _ client.Client = &GreenplumBackupScheduleReconciler{}
+
+// +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumbackupschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumbackupschedules/status,verbs=get;update;patch
+
+func (r *GreenplumBackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var schedule greenplumv1.GreenplumBackupSchedule
+	if err := r.Get(ctx, req.NamespacedName, &schedule); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("unable to fetch GreenplumBackupSchedule: %w", err)
+	}
+	originalSchedule := schedule.DeepCopy()
+
+	backups, err := r.scheduledBackups(ctx, &schedule)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	schedule.Status.Active = ""
+	for i := range backups {
+		backup := &backups[i]
+		switch backup.Status.Phase {
+		case greenplumv1.GreenplumBackupPhaseSucceeded:
+			if completion := backup.Status.CompletionTime; completion != nil &&
+				(schedule.Status.LastSuccessfulTime == nil || schedule.Status.LastSuccessfulTime.Before(completion)) {
+				schedule.Status.LastSuccessfulTime = completion.DeepCopy()
+			}
+		case greenplumv1.GreenplumBackupPhaseFailed:
+		default:
+			schedule.Status.Active = backup.Name
+		}
+	}
+
+	if schedule.Spec.Retention != nil {
+		if err := r.enforceRetention(ctx, &schedule, backups); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	cronSchedule, err := cron.Parse(schedule.Spec.Schedule)
+	if err != nil {
+		// the admission webhook rejects invalid schedules, so this is only reached without it
+		r.Log.Error(err, "invalid schedule", "greenplumbackupschedule", schedule.Name)
+		r.recordEvent(&schedule, corev1.EventTypeWarning, "InvalidSchedule", fmt.Sprintf("invalid schedule %q: %s", schedule.Spec.Schedule, err))
+		schedule.Status.NextScheduleTime = nil
+		return ctrl.Result{}, r.patchScheduleStatus(ctx, &schedule, originalSchedule)
+	}
+
+	if schedule.Spec.Suspend {
+		schedule.Status.NextScheduleTime = nil
+		return ctrl.Result{}, r.patchScheduleStatus(ctx, &schedule, originalSchedule)
+	}
+
+	now := r.now()
+	if scheduledTime := mostRecentScheduleTime(cronSchedule, &schedule, now); !scheduledTime.IsZero() {
+		if schedule.Status.Active != "" {
+			r.Log.Info("skipped scheduled backup", "greenplumbackupschedule", schedule.Name, "active", schedule.Status.Active)
+			r.recordEvent(&schedule, corev1.EventTypeNormal, "BackupSkipped",
+				fmt.Sprintf("skipped the backup scheduled for %s: backup %s is still in progress", scheduledTime.Format(time.RFC3339), schedule.Status.Active))
+		} else {
+			name, err := r.createScheduledBackup(ctx, &schedule, scheduledTime)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			schedule.Status.Active = name
+		}
+		lastScheduleTime := metav1.NewTime(scheduledTime)
+		schedule.Status.LastScheduleTime = &lastScheduleTime
+	}
+
+	next := cronSchedule.Next(now)
+	if next.IsZero() {
+		schedule.Status.NextScheduleTime = nil
+		return ctrl.Result{}, r.patchScheduleStatus(ctx, &schedule, originalSchedule)
+	}
+	nextScheduleTime := metav1.NewTime(next)
+	schedule.Status.NextScheduleTime = &nextScheduleTime
+	if err := r.patchScheduleStatus(ctx, &schedule, originalSchedule); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// mostRecentScheduleTime returns the latest time up to now that the schedule was due to run and has not run yet,
+// or the zero time if there is none. Earlier missed runs are not caught up on
+func mostRecentScheduleTime(cronSchedule *cron.Schedule, schedule *greenplumv1.GreenplumBackupSchedule, now time.Time) time.Time {
+	earliest := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliest = schedule.Status.LastScheduleTime.Time
+	}
+	var mostRecent time.Time
+	for t := cronSchedule.Next(earliest.UTC()); !t.IsZero() && !t.After(now); t = cronSchedule.Next(t) {
+		mostRecent = t
+	}
+	return mostRecent
+}
+
+// createScheduledBackup creates the GreenplumBackup for the run scheduled at scheduledTime. Its name is derived from
+// the scheduled time, so that a run is never backed up twice
+func (r *GreenplumBackupScheduleReconciler) createScheduledBackup(ctx context.Context, schedule *greenplumv1.GreenplumBackupSchedule, scheduledTime time.Time) (string, error) {
+	backup := &greenplumv1.GreenplumBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: schedule.Namespace,
+			Name:      fmt.Sprintf("%s-%d", schedule.Name, scheduledTime.Unix()/60),
+			Labels: map[string]string{
+				"greenplum-cluster":         schedule.Spec.Template.ClusterName,
+				"greenplum-backup-schedule": schedule.Name,
+			},
+		},
+		Spec: *schedule.Spec.Template.DeepCopy(),
+	}
+	if err := ctrl.SetControllerReference(schedule, backup, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return "", err
+	}
+	if err := r.Create(ctx, backup); err != nil {
+		if apierrs.IsAlreadyExists(err) {
+			return backup.Name, nil
+		}
+		return "", fmt.Errorf("creating scheduled GreenplumBackup: %w", err)
+	}
+	r.Log.Info("created scheduled backup", "greenplumbackupschedule", schedule.Name, "greenplumbackup", backup.Name)
+	r.recordEvent(schedule, corev1.EventTypeNormal, "BackupCreated", fmt.Sprintf("created GreenplumBackup %s", backup.Name))
+	return backup.Name, nil
+}
+
+// scheduledBackups returns the GreenplumBackups created by the schedule
+func (r *GreenplumBackupScheduleReconciler) scheduledBackups(ctx context.Context, schedule *greenplumv1.GreenplumBackupSchedule) ([]greenplumv1.GreenplumBackup, error) {
+	var backups greenplumv1.GreenplumBackupList
+	if err := r.List(ctx, &backups, client.InNamespace(schedule.Namespace), client.MatchingLabels{"greenplum-backup-schedule": schedule.Name}); err != nil {
+		return nil, fmt.Errorf("listing GreenplumBackups: %w", err)
+	}
+	var scheduled []greenplumv1.GreenplumBackup
+	for _, backup := range backups.Items {
+		if metav1.IsControlledBy(&backup, schedule) {
+			scheduled = append(scheduled, backup)
+		}
+	}
+	return scheduled, nil
+}
+
+// expiredBackups returns the finished backups that are past the retention policy at now. Succeeded and failed
+// backups are counted separately
+func expiredBackups(backups []greenplumv1.GreenplumBackup, retention greenplumv1.GreenplumBackupScheduleRetentionSpec, now time.Time) []greenplumv1.GreenplumBackup {
+	var expired []greenplumv1.GreenplumBackup
+	for _, phase := range []greenplumv1.GreenplumBackupPhase{greenplumv1.GreenplumBackupPhaseSucceeded, greenplumv1.GreenplumBackupPhaseFailed} {
+		var finished []greenplumv1.GreenplumBackup
+		for _, backup := range backups {
+			if backup.Status.Phase == phase {
+				finished = append(finished, backup)
+			}
+		}
+		sort.Slice(finished, func(i, j int) bool { return createdBefore(&finished[j], &finished[i]) })
+		for i, backup := range finished {
+			switch {
+			case retention.KeepLast != nil && i >= int(*retention.KeepLast):
+				expired = append(expired, backup)
+			case retention.KeepDays != nil && now.Sub(backup.CreationTimestamp.Time) > time.Duration(*retention.KeepDays)*24*time.Hour:
+				expired = append(expired, backup)
+			}
+		}
+	}
+	return expired
+}
+
+// enforceRetention deletes the GreenplumBackups that are past the retention policy. The files of a succeeded backup
+// are deleted from S3 first by a prune job; the GreenplumBackup is deleted once the job reports it deleted.
+// A failed prune job is kept, for its logs, until a later backup succeeds, and is then retried
+func (r *GreenplumBackupScheduleReconciler) enforceRetention(ctx context.Context, schedule *greenplumv1.GreenplumBackupSchedule, backups []greenplumv1.GreenplumBackup) error {
+	jobKey := schedulePruneJobKey(schedule)
+	var pruneJob batchv1.Job
+	if err := r.Get(ctx, jobKey, &pruneJob); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return err
+		}
+		return r.startPruning(ctx, schedule, expiredBackups(backups, *schedule.Spec.Retention, r.now()))
+	}
+
+	// the prune job is owned, so it finishing triggers another reconcile
+	if pruneJob.Status.Succeeded == 0 && pruneJob.Status.Failed == 0 {
+		return nil
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(pruneJob.Namespace), client.MatchingLabels{"job-name": pruneJob.Name}); err != nil {
+		return fmt.Errorf("listing prune job pods: %w", err)
+	}
+	pruned := map[string]bool{}
+	for _, pod := range pods.Items {
+		for _, timestamp := range backupjob.PrunedBackups(pod) {
+			pruned[timestamp] = true
+		}
+	}
+	deleted := 0
+	for i := range backups {
+		backup := &backups[i]
+		if backup.Status.Timestamp == "" || !pruned[backup.Status.Timestamp] {
+			continue
+		}
+		if err := r.deleteBackup(ctx, schedule, backup); err != nil {
+			return err
+		}
+		deleted++
+	}
+
+	if pruneJob.Status.Failed > 0 {
+		if schedule.Status.LastSuccessfulTime == nil || !pruneJob.CreationTimestamp.Before(schedule.Status.LastSuccessfulTime) {
+			r.recordEvent(schedule, corev1.EventTypeWarning, "PruneJobFailed",
+				fmt.Sprintf("prune job %s failed after deleting %d backups from S3; it is retried once another backup succeeds", pruneJob.Name, len(pruned)))
+			return nil
+		}
+	} else if deleted > 0 {
+		r.recordEvent(schedule, corev1.EventTypeNormal, "BackupsPruned", fmt.Sprintf("deleted %d backups past the retention policy", deleted))
+	}
+	if err := r.Delete(ctx, &pruneJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("deleting prune job: %w", err)
+	}
+	r.Log.Info("deleted job", "greenplumbackupschedule", schedule.Name, "job", pruneJob.Name)
+	return nil
+}
+
+// startPruning deletes the expired backups that have no files in S3, and starts a prune job for the rest.
+// A job deletes from a single S3 folder, so backups written elsewhere, e.g. before the template was changed,
+// wait for a later job. Backups written to a backupDir are deleted, but their files are left in the directory
+func (r *GreenplumBackupScheduleReconciler) startPruning(ctx context.Context, schedule *greenplumv1.GreenplumBackupSchedule, expired []greenplumv1.GreenplumBackup) error {
+	var spec *greenplumv1.GreenplumBackupSpec
+	var timestamps []string
+	for i := range expired {
+		backup := &expired[i]
+		if backup.Status.Phase != greenplumv1.GreenplumBackupPhaseSucceeded || backup.Status.Timestamp == "" || backup.Spec.S3 == nil {
+			if err := r.deleteBackup(ctx, schedule, backup); err != nil {
+				return err
+			}
+			continue
+		}
+		if spec == nil {
+			spec = &backup.Spec
+		}
+		if equality.Semantic.DeepEqual(backup.Spec.S3, spec.S3) {
+			timestamps = append(timestamps, backup.Status.Timestamp)
+		}
+	}
+	if len(timestamps) == 0 {
+		return nil
+	}
+
+	jobKey := schedulePruneJobKey(schedule)
+	job := backupjob.GeneratePruneJob(r.InstanceImage, *spec, timestamps)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Labels = map[string]string{
+		"greenplum-cluster":         schedule.Spec.Template.ClusterName,
+		"greenplum-backup-schedule": schedule.Name,
+	}
+	var greenplumCluster greenplumv1.GreenplumCluster
+	clusterKey := types.NamespacedName{Namespace: schedule.Namespace, Name: schedule.Spec.Template.ClusterName}
+	if err := r.Get(ctx, clusterKey, &greenplumCluster); err == nil {
+		job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+	} else if !apierrs.IsNotFound(err) {
+		return err
+	}
+	if err := ctrl.SetControllerReference(schedule, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return fmt.Errorf("creating prune job: %w", err)
+	}
+	r.Log.Info("started prune job", "greenplumbackupschedule", schedule.Name, "job", job.Name, "backups", timestamps)
+	return nil
+}
+
+func (r *GreenplumBackupScheduleReconciler) deleteBackup(ctx context.Context, schedule *greenplumv1.GreenplumBackupSchedule, backup *greenplumv1.GreenplumBackup) error {
+	if err := r.Delete(ctx, backup, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("deleting GreenplumBackup %s: %w", backup.Name, err)
+	}
+	r.Log.Info("deleted backup past the retention policy", "greenplumbackupschedule", schedule.Name, "greenplumbackup", backup.Name)
+	return nil
+}
+
+func (r *GreenplumBackupScheduleReconciler) patchScheduleStatus(ctx context.Context, schedule, originalSchedule *greenplumv1.GreenplumBackupSchedule) error {
+	if equality.Semantic.DeepEqual(schedule, originalSchedule) {
+		return nil
+	}
+	if err := r.Patch(ctx, schedule, client.MergeFrom(originalSchedule)); err != nil {
+		return fmt.Errorf("updating GreenplumBackupSchedule status: %w", err)
+	}
+	return nil
+}
+
+// recordEvent emits an Event on the GreenplumBackupSchedule, when the reconciler has a Recorder
+func (r *GreenplumBackupScheduleReconciler) recordEvent(schedule *greenplumv1.GreenplumBackupSchedule, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(schedule, eventType, reason, message)
+}
+
+func (r *GreenplumBackupScheduleReconciler) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
+
+func schedulePruneJobKey(schedule *greenplumv1.GreenplumBackupSchedule) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: schedule.Namespace,
+		Name:      fmt.Sprintf("%s-schedule-prune-job", schedule.Name),
+	}
+}
+
+func (r *GreenplumBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&greenplumv1.GreenplumBackupSchedule{}).
+		Owns(&greenplumv1.GreenplumBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
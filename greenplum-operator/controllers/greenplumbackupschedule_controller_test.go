@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("GreenplumBackupSchedule controller", func() {
+	var (
+		ctx                context.Context
+		logBuf             *gbytes.Buffer
+		recorder           *record.FakeRecorder
+		now                time.Time
+		scheduleReconciler *GreenplumBackupScheduleReconciler
+		schedule           *greenplumv1.GreenplumBackupSchedule
+
+		scheduleRequest = reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: "test-ns", Name: "nightly"},
+		}
+		pruneJobKey = types.NamespacedName{Namespace: "test-ns", Name: "nightly-schedule-prune-job"}
+	)
+
+	getSchedule := func() *greenplumv1.GreenplumBackupSchedule {
+		var s greenplumv1.GreenplumBackupSchedule
+		Expect(reactiveClient.Get(ctx, scheduleRequest.NamespacedName, &s)).To(Succeed())
+		return &s
+	}
+	listBackups := func() []greenplumv1.GreenplumBackup {
+		var backups greenplumv1.GreenplumBackupList
+		Expect(reactiveClient.List(ctx, &backups)).To(Succeed())
+		return backups.Items
+	}
+	backupNames := func() []string {
+		var names []string
+		for _, backup := range listBackups() {
+			names = append(names, backup.Name)
+		}
+		return names
+	}
+	// createScheduledBackup creates a backup as if the schedule created it at created
+	createScheduledBackup := func(name string, created time.Time, status greenplumv1.GreenplumBackupStatus) {
+		backup := &greenplumv1.GreenplumBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "test-ns",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+				Labels:            map[string]string{"greenplum-backup-schedule": "nightly"},
+			},
+			Spec:   schedule.Spec.Template,
+			Status: status,
+		}
+		s := getSchedule()
+		Expect(ctrl.SetControllerReference(s, backup, reactiveClient.Scheme())).To(Succeed())
+		Expect(reactiveClient.Create(ctx, backup)).To(Succeed())
+	}
+	succeeded := func(timestamp string, completed time.Time) greenplumv1.GreenplumBackupStatus {
+		completionTime := metav1.NewTime(completed)
+		return greenplumv1.GreenplumBackupStatus{
+			Phase:          greenplumv1.GreenplumBackupPhaseSucceeded,
+			Timestamp:      timestamp,
+			CompletionTime: &completionTime,
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		recorder = record.NewFakeRecorder(10)
+		now = time.Date(2020, 1, 10, 2, 0, 30, 0, time.UTC)
+
+		scheduleReconciler = &GreenplumBackupScheduleReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			InstanceImage: "greenplum-for-kubernetes:v1.7.5",
+			Recorder:      recorder,
+			Now:           func() time.Time { return now },
+		}
+
+		lastScheduleTime := metav1.NewTime(time.Date(2020, 1, 9, 2, 0, 0, 0, time.UTC))
+		schedule = &greenplumv1.GreenplumBackupSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "test-ns",
+				Name:              "nightly",
+				CreationTimestamp: metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+			Spec: greenplumv1.GreenplumBackupScheduleSpec{
+				Schedule: "0 2 * * *",
+				Template: greenplumv1.GreenplumBackupSpec{
+					ClusterName: "my-greenplum",
+					S3: &greenplumv1.GreenplumBackupS3Spec{
+						Bucket:      "backups",
+						Prefix:      "greenplum/prod",
+						Region:      "us-east-1",
+						Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+					},
+				},
+			},
+			Status: greenplumv1.GreenplumBackupScheduleStatus{
+				LastScheduleTime: &lastScheduleTime,
+			},
+		}
+	})
+
+	var (
+		reconcileResult ctrl.Result
+		reconcileErr    error
+	)
+	JustBeforeEach(func() {
+		reconcileResult, reconcileErr = scheduleReconciler.Reconcile(ctx, scheduleRequest)
+	})
+
+	When("the schedule does not exist", func() {
+		It("does nothing", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconcileResult).To(Equal(ctrl.Result{}))
+		})
+	})
+
+	When("a run is due", func() {
+		BeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, schedule)).To(Succeed())
+		})
+
+		It("creates a GreenplumBackup from the template", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			backups := listBackups()
+			Expect(backups).To(HaveLen(1))
+			backup := backups[0]
+			Expect(backup.Name).To(Equal("nightly-26310360"))
+			Expect(backup.Labels).To(Equal(map[string]string{
+				"greenplum-cluster":         "my-greenplum",
+				"greenplum-backup-schedule": "nightly",
+			}))
+			Expect(backup.Spec).To(Equal(schedule.Spec.Template))
+			Expect(metav1.IsControlledBy(&backup, getSchedule())).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Normal BackupCreated created GreenplumBackup nightly-26310360")))
+		})
+
+		When("the template has gpbackup options", func() {
+			BeforeEach(func() {
+				s := getSchedule()
+				s.Spec.Template.SingleDataFile = true
+				s.Spec.Template.CopyQueueSize = 8
+				Expect(reactiveClient.Update(ctx, s)).To(Succeed())
+			})
+			It("creates the GreenplumBackup with them", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				backups := listBackups()
+				Expect(backups).To(HaveLen(1))
+				Expect(backups[0].Spec.SingleDataFile).To(BeTrue())
+				Expect(backups[0].Spec.CopyQueueSize).To(Equal(int32(8)))
+			})
+		})
+
+		It("records the run and the next run in status", func() {
+			status := getSchedule().Status
+			Expect(status.LastScheduleTime.Time).To(BeTemporally("==", time.Date(2020, 1, 10, 2, 0, 0, 0, time.UTC)))
+			Expect(status.NextScheduleTime.Time).To(BeTemporally("==", time.Date(2020, 1, 11, 2, 0, 0, 0, time.UTC)))
+			Expect(status.Active).To(Equal("nightly-26310360"))
+		})
+
+		It("requeues at the next run", func() {
+			Expect(reconcileResult).To(Equal(ctrl.Result{RequeueAfter: 24*time.Hour - 30*time.Second}))
+		})
+
+		When("several runs were missed", func() {
+			BeforeEach(func() {
+				now = now.Add(72 * time.Hour)
+			})
+			It("only runs the most recent one", func() {
+				Expect(backupNames()).To(ConsistOf("nightly-26314680"))
+				Expect(getSchedule().Status.LastScheduleTime.Time).To(BeTemporally("==", time.Date(2020, 1, 13, 2, 0, 0, 0, time.UTC)))
+			})
+		})
+
+		When("the previous backup is still in progress", func() {
+			BeforeEach(func() {
+				createScheduledBackup("nightly-26308920", now.Add(-24*time.Hour), greenplumv1.GreenplumBackupStatus{Phase: greenplumv1.GreenplumBackupPhaseRunning})
+			})
+			It("skips the run", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(backupNames()).To(ConsistOf("nightly-26308920"))
+				status := getSchedule().Status
+				Expect(status.Active).To(Equal("nightly-26308920"))
+				Expect(status.LastScheduleTime.Time).To(BeTemporally("==", time.Date(2020, 1, 10, 2, 0, 0, 0, time.UTC)))
+				Expect(recorder.Events).To(Receive(Equal("Normal BackupSkipped skipped the backup scheduled for 2020-01-10T02:00:00Z: backup nightly-26308920 is still in progress")))
+			})
+		})
+
+		When("the previous backup has finished", func() {
+			BeforeEach(func() {
+				createScheduledBackup("nightly-26308920", now.Add(-24*time.Hour), succeeded("20200109020012", now.Add(-23*time.Hour)))
+			})
+			It("runs and records the last successful backup", func() {
+				Expect(backupNames()).To(ConsistOf("nightly-26308920", "nightly-26310360"))
+				Expect(getSchedule().Status.LastSuccessfulTime.Time).To(BeTemporally("==", now.Add(-23*time.Hour)))
+			})
+		})
+
+		When("the schedule is suspended", func() {
+			BeforeEach(func() {
+				s := getSchedule()
+				s.Spec.Suspend = true
+				nextScheduleTime := metav1.NewTime(now)
+				s.Status.NextScheduleTime = &nextScheduleTime
+				Expect(reactiveClient.Update(ctx, s)).To(Succeed())
+			})
+			It("does not create a backup", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconcileResult).To(Equal(ctrl.Result{}))
+				Expect(listBackups()).To(BeEmpty())
+				Expect(getSchedule().Status.NextScheduleTime).To(BeNil())
+			})
+		})
+
+		When("the schedule is invalid", func() {
+			BeforeEach(func() {
+				s := getSchedule()
+				s.Spec.Schedule = "0 25 * * *"
+				Expect(reactiveClient.Update(ctx, s)).To(Succeed())
+			})
+			It("reports it without creating a backup", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(listBackups()).To(BeEmpty())
+				Expect(recorder.Events).To(Receive(Equal(`Warning InvalidSchedule invalid schedule "0 25 * * *": value 25 out of range 0-23 in hour field "25"`)))
+			})
+		})
+	})
+
+	When("no run is due", func() {
+		BeforeEach(func() {
+			now = time.Date(2020, 1, 9, 12, 0, 0, 0, time.UTC)
+			Expect(reactiveClient.Create(ctx, schedule)).To(Succeed())
+		})
+		It("only records the next run", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(listBackups()).To(BeEmpty())
+			Expect(getSchedule().Status.NextScheduleTime.Time).To(BeTemporally("==", time.Date(2020, 1, 10, 2, 0, 0, 0, time.UTC)))
+			Expect(reconcileResult).To(Equal(ctrl.Result{RequeueAfter: 14 * time.Hour}))
+		})
+	})
+
+	Describe("retention", func() {
+		keep := func(n int32) *int32 { return &n }
+		BeforeEach(func() {
+			now = time.Date(2020, 1, 9, 12, 0, 0, 0, time.UTC)
+			schedule.Spec.Retention = &greenplumv1.GreenplumBackupScheduleRetentionSpec{KeepLast: keep(2)}
+			Expect(reactiveClient.Create(ctx, schedule)).To(Succeed())
+			day := func(d int) time.Time { return time.Date(2020, 1, d, 2, 0, 0, 0, time.UTC) }
+			createScheduledBackup("nightly-1", day(5), succeeded("20200105020000", day(5)))
+			createScheduledBackup("nightly-2", day(6), succeeded("20200106020000", day(6)))
+			createScheduledBackup("nightly-3", day(7), greenplumv1.GreenplumBackupStatus{Phase: greenplumv1.GreenplumBackupPhaseFailed})
+			createScheduledBackup("nightly-4", day(8), succeeded("20200108020000", day(8)))
+			createScheduledBackup("nightly-5", day(9), succeeded("20200109020000", day(9)))
+		})
+
+		It("starts a job that deletes the files of the expired backups from S3", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, pruneJobKey, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"20200106020000", "20200105020000"}))
+			Expect(job.Labels).To(Equal(map[string]string{
+				"greenplum-cluster":         "my-greenplum",
+				"greenplum-backup-schedule": "nightly",
+			}))
+			Expect(metav1.IsControlledBy(&job, getSchedule())).To(BeTrue())
+			Expect(backupNames()).To(ConsistOf("nightly-1", "nightly-2", "nightly-3", "nightly-4", "nightly-5"))
+		})
+
+		When("the backups are also older than keepDays", func() {
+			BeforeEach(func() {
+				s := getSchedule()
+				s.Spec.Retention = &greenplumv1.GreenplumBackupScheduleRetentionSpec{KeepLast: keep(3), KeepDays: keep(2)}
+				Expect(reactiveClient.Update(ctx, s)).To(Succeed())
+			})
+			It("expires backups past either limit, counting failed backups separately", func() {
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, pruneJobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"20200106020000", "20200105020000"}))
+				// the failed backup is the only failed one, but it is older than keepDays
+				Expect(backupNames()).To(ConsistOf("nightly-1", "nightly-2", "nightly-4", "nightly-5"))
+			})
+		})
+
+		When("the expired backups were written to a backup directory", func() {
+			BeforeEach(func() {
+				for _, name := range []string{"nightly-1", "nightly-2"} {
+					var backup greenplumv1.GreenplumBackup
+					Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: "test-ns", Name: name}, &backup)).To(Succeed())
+					backup.Spec.S3 = nil
+					backup.Spec.BackupDir = "/backups"
+					Expect(reactiveClient.Update(ctx, &backup)).To(Succeed())
+				}
+			})
+			It("deletes them without a prune job", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, pruneJobKey, &job))).To(BeTrue())
+				Expect(backupNames()).To(ConsistOf("nightly-3", "nightly-4", "nightly-5"))
+			})
+		})
+
+		When("the prune job is running", func() {
+			BeforeEach(func() {
+				Expect(reactiveClient.Create(ctx, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: pruneJobKey.Namespace, Name: pruneJobKey.Name}})).To(Succeed())
+			})
+			It("waits for it", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(backupNames()).To(HaveLen(5))
+			})
+		})
+
+		When("the prune job has finished", func() {
+			var jobStatus batchv1.JobStatus
+			BeforeEach(func() {
+				jobStatus = batchv1.JobStatus{Succeeded: 1}
+			})
+			JustBeforeEach(func() {
+				// the reconcile in the outer JustBeforeEach started the job
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, pruneJobKey, &job)).To(Succeed())
+				job.CreationTimestamp = metav1.NewTime(now)
+				job.Status = jobStatus
+				Expect(reactiveClient.Update(ctx, &job)).To(Succeed())
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "nightly-schedule-prune-job-abcde", Labels: map[string]string{"job-name": pruneJobKey.Name}},
+					Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "gpbackup-prune",
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "pruned=20200105020000\n"}},
+					}}},
+				}
+				Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+				Expect(recorder.Events).NotTo(Receive())
+				reconcileResult, reconcileErr = scheduleReconciler.Reconcile(ctx, scheduleRequest)
+			})
+
+			It("deletes the GreenplumBackups whose files were deleted, and the job", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(backupNames()).To(ConsistOf("nightly-2", "nightly-3", "nightly-4", "nightly-5"))
+				var job batchv1.Job
+				err := reactiveClient.Get(ctx, pruneJobKey, &job)
+				Expect(apierrs.IsNotFound(err)).To(BeTrue())
+				Expect(recorder.Events).To(Receive(Equal("Normal BackupsPruned deleted 1 backups past the retention policy")))
+				Expect(logBuf).To(gbytes.Say(`"msg":"deleted backup past the retention policy","greenplumbackupschedule":"nightly","greenplumbackup":"nightly-1"`))
+			})
+
+			When("it failed", func() {
+				BeforeEach(func() {
+					jobStatus = batchv1.JobStatus{Failed: 1}
+				})
+				It("deletes the backups it did delete, and keeps the job", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(backupNames()).To(ConsistOf("nightly-2", "nightly-3", "nightly-4", "nightly-5"))
+					var job batchv1.Job
+					Expect(reactiveClient.Get(ctx, pruneJobKey, &job)).To(Succeed())
+					Expect(recorder.Events).To(Receive(Equal("Warning PruneJobFailed prune job nightly-schedule-prune-job failed after deleting 1 backups from S3; it is retried once another backup succeeds")))
+				})
+
+				When("a backup has succeeded since", func() {
+					BeforeEach(func() {
+						s := getSchedule()
+						lastSuccessfulTime := metav1.NewTime(now.Add(time.Hour))
+						s.Status.LastSuccessfulTime = &lastSuccessfulTime
+						Expect(reactiveClient.Update(ctx, s)).To(Succeed())
+					})
+					It("deletes the job so that it is retried", func() {
+						var job batchv1.Job
+						err := reactiveClient.Get(ctx, pruneJobKey, &job)
+						Expect(apierrs.IsNotFound(err)).To(BeTrue())
+					})
+				})
+			})
+		})
+	})
+})
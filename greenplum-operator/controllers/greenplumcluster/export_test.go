@@ -0,0 +1,12 @@
+package greenplumcluster
+
+var (
+	NodeStartedDraining             = nodeStartedDraining
+	GreenplumClustersOnDrainingNode = (*GreenplumClusterReconciler).greenplumClustersOnDrainingNode
+	GreenplumClusterForPVC          = (*GreenplumClusterReconciler).greenplumClusterForPVC
+)
+
+// EnablePodProbes reuses the results of exec probes into pods, as SetupWithManager does
+func (r *GreenplumClusterReconciler) EnablePodProbes() {
+	r.podProbes = &podProbes{}
+}
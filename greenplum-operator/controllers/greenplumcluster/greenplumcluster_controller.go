@@ -18,16 +18,21 @@ import (
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sshkeygen"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
@@ -38,11 +43,17 @@ const (
 // GreenplumClusterReconciler reconciles a GreenplumCluster object
 type GreenplumClusterReconciler struct {
 	client.Client
+	// APIReader reads the objects that are not cached, such as events
+	APIReader     client.Reader
 	Log           logr.Logger
 	SSHCreator    sshkeygen.SSHSecretCreator
 	InstanceImage string
 	OperatorImage string
 	PodExec       executor.PodExecInterface
+	Recorder      record.EventRecorder
+
+	// podProbes is set up with the manager; without it, every probe into a pod runs
+	podProbes *podProbes
 }
 
 var _ client.Client = &GreenplumClusterReconciler{}
@@ -51,9 +62,17 @@ var _ client.Client = &GreenplumClusterReconciler{}
 // +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumclusters/status,verbs=get;update;patch
 
 func (r *GreenplumClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podNodeNameField, podNodeName); err != nil {
+		return err
+	}
+	r.podProbes = &podProbes{}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&greenplumv1.GreenplumCluster{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, handler.EnqueueRequestsFromMapFunc(r.greenplumClusterForPVC)).
+		Watches(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(r.greenplumClustersOnDrainingNode),
+			builder.WithPredicates(nodeStartedDraining)).
 		Complete(r)
 }
 
@@ -76,12 +95,28 @@ func (r *GreenplumClusterReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	if err := r.handleFinalizer(ctx, &greenplumCluster, &activeMaster); err != nil {
 		return ctrl.Result{}, err
 	}
+	if !greenplumCluster.DeletionTimestamp.IsZero() {
+		// Any further write could be to a cluster that is already gone
+		return ctrl.Result{}, nil
+	}
 
 	if greenplumCluster.Status.InstanceImage != "" &&
 		greenplumCluster.Status.InstanceImage != r.InstanceImage {
 		return ctrl.Result{}, nil
 	}
 
+	if err := r.resolvePrimarySegmentCount(ctx, &greenplumCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	adopting, untilAdoptionCheck, err := r.handleAdoption(ctx, &greenplumCluster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to adopt existing cluster: %w", err)
+	}
+	if adopting {
+		return ctrl.Result{RequeueAfter: untilAdoptionCheck}, nil
+	}
+
 	clusterExists, err := r.clusterExists(ctx, greenplumCluster)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("unable to check if GreenplumCluster resources exist: %w", err)
@@ -92,6 +127,12 @@ func (r *GreenplumClusterReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}
 
+	// The certificates are in place before the statefulsets that mount them
+	untilNextCertificate, err := r.handleInterconnectTLS(ctx, &greenplumCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	if err := r.createOrUpdateClusterResources(ctx, greenplumCluster); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -100,42 +141,172 @@ func (r *GreenplumClusterReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	untilNextFence, err := r.handleReadOnlyVolumes(ctx, &greenplumCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.handlePVCBinding(ctx, &greenplumCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	untilNextStorageCheck, err := r.handleStorageResize(ctx, &greenplumCluster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to resize storage: %w", err)
+	}
+
+	versionMismatch, err := r.handleVersionMismatch(ctx, &greenplumCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// TODO: Decide when to set status to greenplumv1.GreenplumClusterPhaseFailed
 
 	if greenplumCluster.Status.Phase == greenplumv1.GreenplumClusterPhasePending && activeMaster != "" {
+		ready, err := r.handleReadinessCheck(ctx, &greenplumCluster, activeMaster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !ready {
+			return ctrl.Result{RequeueAfter: readinessCheckRetryInterval}, nil
+		}
 		r.setStatus(ctx, &greenplumCluster, greenplumv1.GreenplumClusterPhaseRunning)
 	}
 
 	if activeMaster == "" {
+		if err := r.handleMasterFailover(ctx, &greenplumCluster); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to fail over to the standby master: %w", err)
+		}
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
-	if err := r.handleExpand(ctx, &greenplumCluster, activeMaster); err != nil {
-		return ctrl.Result{}, fmt.Errorf("unable to run gpexpand: %w", err)
+	// Reporting is best effort, like the applied GUCs below
+	if err := r.handleMasterRecovery(ctx, &greenplumCluster, activeMaster); err != nil {
+		log.Error(err, "unable to check whether the master is in recovery")
 	}
 
-	return ctrl.Result{}, nil
+	var untilNextExpansionCheck, untilNextGUCRestartCheck time.Duration
+	if versionMismatch {
+		log.Info("skipping gpexpand and GUC changes until all pods run the same Greenplum version")
+	} else {
+		untilNextExpansionCheck, err = r.handleExpand(ctx, &greenplumCluster, activeMaster)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to run gpexpand: %w", err)
+		}
+
+		untilNextGUCRestartCheck, err = r.handleGUCs(ctx, &greenplumCluster, activeMaster)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to apply GUCs: %w", err)
+		}
+	}
+
+	if err := r.handleRoleConnectionLimits(&greenplumCluster, activeMaster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to apply role connection limits: %w", err)
+	}
+
+	if err := r.handleExtensions(&greenplumCluster, activeMaster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to create extensions: %w", err)
+	}
+
+	untilNextTLSCheck, err := r.handleTLS(ctx, &greenplumCluster, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to configure TLS: %w", err)
+	}
+
+	untilNextLDAPProbe, err := r.handleLDAP(ctx, &greenplumCluster, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to configure LDAP authentication: %w", err)
+	}
+
+	if err := r.handlePgHbaEntries(ctx, &greenplumCluster, activeMaster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to apply pg_hba entries: %w", err)
+	}
+
+	// Reporting is best effort; it should not hold up the steps that keep the cluster healthy
+	if err := r.handleAppliedGUCs(ctx, &greenplumCluster, activeMaster); err != nil {
+		log.Error(err, "unable to report applied GUCs")
+	}
+
+	untilNextRotation, err := r.handleAdminPasswordRotation(ctx, &greenplumCluster, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.handleMonitoring(ctx, &greenplumCluster, activeMaster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to configure monitoring: %w", err)
+	}
+
+	// The steps below act on the segments as of this query; what they change is seen by the next reconcile
+	segments, err := r.querySegmentConfiguration(greenplumCluster.Namespace, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to query segment configuration: %w", err)
+	}
+
+	if err := r.handleSegmentMap(ctx, &greenplumCluster, segments); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to refresh segment map: %w", err)
+	}
+
+	if err := r.handleNodeDrain(ctx, &greenplumCluster, segments); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to fail over segments on draining nodes: %w", err)
+	}
+
+	if err := r.handleMissingSegmentData(&greenplumCluster, activeMaster, segments); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to recover segments with missing data: %w", err)
+	}
+
+	if err := r.handleChangeTracking(ctx, &greenplumCluster, activeMaster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to check segment mirroring status: %w", err)
+	}
+
+	if err := r.handleSegmentFencing(ctx, &greenplumCluster, segments); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to report fenced segments: %w", err)
+	}
+
+	untilNextMirrorsCheck, err := r.handleMirrors(ctx, &greenplumCluster, activeMaster, segments)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to add mirrors: %w", err)
+	}
+
+	untilNextRebalanceCheck, err := r.handleRebalance(ctx, &greenplumCluster, activeMaster, segments)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to rebalance segments: %w", err)
+	}
+
+	if err := r.handleStandbyReinitialization(ctx, &greenplumCluster, activeMaster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to reinitialize the standby master: %w", err)
+	}
+
+	untilNextReplication, err := r.handleDisasterRecovery(ctx, &greenplumCluster, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to replicate from primary: %w", err)
+	}
+
+	untilNextPerfCheck, err := r.handlePerfCheck(ctx, &greenplumCluster, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to check disk and network performance: %w", err)
+	}
+
+	return ctrl.Result{RequeueAfter: earliestRequeue(untilNextRotation, untilNextReplication, untilNextCertificate, untilNextRebalanceCheck, untilNextExpansionCheck, untilNextMirrorsCheck, untilNextStorageCheck, untilNextGUCRestartCheck, untilNextTLSCheck, untilNextLDAPProbe, untilNextPerfCheck, untilNextFence)}, nil
+}
+
+// earliestRequeue returns the shortest of the non-zero durations, or zero if there are none
+func earliestRequeue(durations ...time.Duration) time.Duration {
+	var earliest time.Duration
+	for _, d := range durations {
+		if d > 0 && (earliest == 0 || d < earliest) {
+			earliest = d
+		}
+	}
+	return earliest
 }
 
 func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.Context, greenplumCluster greenplumv1.GreenplumCluster) error {
 	ns := greenplumCluster.Namespace
 	gpName := greenplumCluster.Name
 
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "greenplum-config",
-			Namespace: ns,
-		},
-	}
-
-	operationResult, err := ctrl.CreateOrUpdate(ctx, r, configMap, func() error {
-		configmap.ModifyConfigMap(&greenplumCluster, configMap)
-		return controllerutil.SetControllerReference(&greenplumCluster, configMap, r.Scheme())
-	})
-	if err != nil {
+	if err := r.createOrUpdateConfigMap(ctx, greenplumCluster); err != nil {
 		return err
 	}
-	r.logReconcileResult(operationResult, configMap)
 
 	sshSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -143,9 +314,10 @@ func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.
 			Namespace: ns,
 		},
 	}
-	operationResult, err = ctrl.CreateOrUpdate(ctx, r, sshSecret, func() error {
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, sshSecret, func() error {
 		var keyData map[string][]byte
 		if sshSecret.Data == nil {
+			var err error
 			keyData, err = r.SSHCreator.GenerateKey()
 			if err != nil {
 				return err
@@ -181,7 +353,7 @@ func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.
 		},
 	}
 	operationResult, err = ctrl.CreateOrUpdate(ctx, r, greenplumService, func() error {
-		service.ModifyGreenplumService(gpName, greenplumService)
+		service.ModifyGreenplumService(gpName, greenplumCluster.Status.ActiveMaster, greenplumService)
 		return ctrl.SetControllerReference(&greenplumCluster, greenplumService, r.Scheme())
 	})
 	if err != nil {
@@ -189,6 +361,36 @@ func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.
 	}
 	r.logReconcileResult(operationResult, greenplumService)
 
+	metricsService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "greenplum-metrics",
+			Namespace: ns,
+		},
+	}
+	operationResult, err = ctrl.CreateOrUpdate(ctx, r, metricsService, func() error {
+		service.ModifyGreenplumMetricsService(gpName, metricsService)
+		return ctrl.SetControllerReference(&greenplumCluster, metricsService, r.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	r.logReconcileResult(operationResult, metricsService)
+
+	connectionConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "greenplum-connection",
+			Namespace: ns,
+		},
+	}
+	operationResult, err = ctrl.CreateOrUpdate(ctx, r, connectionConfigMap, func() error {
+		configmap.ModifyConnectionConfigMap(&greenplumCluster, connectionConfigMap)
+		return ctrl.SetControllerReference(&greenplumCluster, connectionConfigMap, r.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	r.logReconcileResult(operationResult, connectionConfigMap)
+
 	serviceAccount := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "greenplum-system-pod",
@@ -250,8 +452,15 @@ func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.
 		return err
 	}
 	r.logReconcileResult(operationResult, masterStatefulSet)
+	if operationResult != controllerutil.OperationResultNone {
+		r.warnAboutSmallMemoryRequest(&greenplumCluster, greenplumCluster.Spec.MasterAndStandby.GreenplumPodSpec, "masterAndStandby")
+	}
 
 	primaryStatefulSetParams := sset.GenerateStatefulSetParams(sset.TypeSegmentA, &greenplumCluster, r.InstanceImage)
+	primaryStatefulSetParams.PersistentVolumeNodeSelectorTerms, err = r.persistentVolumeNodeSelectorTerms(ctx, greenplumCluster, primaryStatefulSetParams)
+	if err != nil {
+		return err
+	}
 	primaryStatefulSet := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "segment-a",
@@ -266,9 +475,16 @@ func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.
 		return err
 	}
 	r.logReconcileResult(operationResult, primaryStatefulSet)
+	if operationResult != controllerutil.OperationResultNone {
+		r.warnAboutSmallMemoryRequest(&greenplumCluster, greenplumCluster.Spec.Segments.GreenplumPodSpec, "segments")
+	}
 
 	if greenplumCluster.Spec.Segments.Mirrors == "yes" {
 		mirrorStatefulSetParams := sset.GenerateStatefulSetParams(sset.TypeSegmentB, &greenplumCluster, r.InstanceImage)
+		mirrorStatefulSetParams.PersistentVolumeNodeSelectorTerms, err = r.persistentVolumeNodeSelectorTerms(ctx, greenplumCluster, mirrorStatefulSetParams)
+		if err != nil {
+			return err
+		}
 		mirrorStatefulSet := &appsv1.StatefulSet{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "segment-b",
@@ -288,6 +504,25 @@ func (r *GreenplumClusterReconciler) createOrUpdateClusterResources(ctx context.
 	return nil
 }
 
+func (r *GreenplumClusterReconciler) createOrUpdateConfigMap(ctx context.Context, greenplumCluster greenplumv1.GreenplumCluster) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "greenplum-config",
+			Namespace: greenplumCluster.Namespace,
+		},
+	}
+
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, configMap, func() error {
+		configmap.ModifyConfigMap(&greenplumCluster, configMap)
+		return controllerutil.SetControllerReference(&greenplumCluster, configMap, r.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	r.logReconcileResult(operationResult, configMap)
+	return nil
+}
+
 func (r *GreenplumClusterReconciler) logReconcileResult(operationResult controllerutil.OperationResult, obj runtime.Object) {
 	if operationResult == controllerutil.OperationResultNone {
 		return
@@ -307,6 +542,14 @@ func (r *GreenplumClusterReconciler) logReconcileResult(operationResult controll
 		"namespace", mo.GetNamespace())
 }
 
+// recordEvent emits an Event on the GreenplumCluster, when the reconciler has a Recorder
+func (r *GreenplumClusterReconciler) recordEvent(greenplumCluster *greenplumv1.GreenplumCluster, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(greenplumCluster, eventType, reason, message)
+}
+
 // deprecated: This function is only used as a crutch for antiaffinity v1. It will go away soon (hopefully)
 func (r *GreenplumClusterReconciler) clusterExists(ctx context.Context, greenplumCluster greenplumv1.GreenplumCluster) (bool, error) {
 	var ssetList appsv1.StatefulSetList
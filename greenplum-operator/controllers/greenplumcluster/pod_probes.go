@@ -0,0 +1,48 @@
+package greenplumcluster
+
+import (
+	"sync"
+	"time"
+)
+
+// How long the result of an exec probe into a pod is reused. A reconcile is triggered by every change to the
+// statefulsets, jobs and PVCs of a cluster, which would otherwise exec into the same pods many times a minute.
+const podProbeInterval = time.Minute
+
+// podProbes remembers the results of exec probes into pods, so that each probe of a pod runs at most once
+// per podProbeInterval. A probe that fails is not remembered, since its pod may only not be up yet.
+type podProbes struct {
+	mu      sync.Mutex
+	results map[string]podProbeResult
+}
+
+type podProbeResult struct {
+	value    string
+	probedAt time.Time
+}
+
+// probe returns the result of run for key, running it unless it ran within the last podProbeInterval.
+// A nil podProbes runs every probe.
+func (p *podProbes) probe(key string, run func() (string, error)) (string, error) {
+	if p == nil {
+		return run()
+	}
+	p.mu.Lock()
+	result, ok := p.results[key]
+	p.mu.Unlock()
+	if ok && time.Since(result.probedAt) < podProbeInterval {
+		return result.value, nil
+	}
+
+	value, err := run()
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.results == nil {
+		p.results = map[string]podProbeResult{}
+	}
+	p.results[key] = podProbeResult{value: value, probedAt: time.Now()}
+	return value, nil
+}
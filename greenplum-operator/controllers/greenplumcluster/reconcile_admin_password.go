@@ -0,0 +1,196 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ConnectionSecretName             = greenplumv1.ConnectionSecretName
+	AdminPasswordRotatedAtAnnotation = "greenplum.pivotal.io/password-rotated-at"
+
+	// pendingAdminPasswordKey holds a generated password in the connection Secret until it is set on the role
+	pendingAdminPasswordKey = "pending-password"
+)
+
+// handleAdminPasswordRotation regenerates the gpadmin password once the rotation interval has
+// elapsed. It returns how long to wait before the next rotation is due.
+func (r *GreenplumClusterReconciler) handleAdminPasswordRotation(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	interval := greenplumCluster.Spec.MasterAndStandby.AdminPasswordRotationInterval
+	if interval == nil {
+		return 0, nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: greenplumCluster.Namespace,
+			Name:      ConnectionSecretName,
+		},
+	}
+	err := r.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, secret)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return 0, fmt.Errorf("unable to fetch connection secret: %w", err)
+	}
+	if err == nil {
+		if _, ok := secret.Data[pendingAdminPasswordKey]; ok {
+			if err := r.applyPendingAdminPassword(ctx, greenplumCluster, activeMaster, secret); err != nil {
+				return 0, err
+			}
+			r.Log.Info("rotated admin password", "next rotation", interval.Duration.String())
+			return interval.Duration, nil
+		}
+		rotatedAt, parseErr := time.Parse(time.RFC3339, secret.Annotations[AdminPasswordRotatedAtAnnotation])
+		if parseErr != nil {
+			// The password in a Secret that we have not rotated yet is still in use, so the interval starts now
+			if err := r.initializeAdminPasswordRotatedAt(ctx, secret); err != nil {
+				return 0, err
+			}
+			return interval.Duration, nil
+		}
+		if untilNext := time.Until(rotatedAt.Add(interval.Duration)); untilNext > 0 {
+			return untilNext, nil
+		}
+	}
+
+	if err := r.storeAdminPassword(ctx, greenplumCluster, activeMaster, secret); err != nil {
+		return 0, err
+	}
+	r.Log.Info("rotated admin password", "next rotation", interval.Duration.String())
+	return interval.Duration, nil
+}
+
+// ensureAdminPasswordSecret returns the greenplum-connection Secret, first setting a generated gpadmin password and
+// storing it there if the Secret does not exist yet
+func (r *GreenplumClusterReconciler) ensureAdminPasswordSecret(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: greenplumCluster.Namespace,
+			Name:      ConnectionSecretName,
+		},
+	}
+	err := r.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, secret)
+	if err == nil {
+		if _, ok := secret.Data[pendingAdminPasswordKey]; ok {
+			if err := r.applyPendingAdminPassword(ctx, greenplumCluster, activeMaster, secret); err != nil {
+				return nil, err
+			}
+		}
+		return secret, nil
+	}
+	if !apierrs.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to fetch connection secret: %w", err)
+	}
+	if err := r.storeAdminPassword(ctx, greenplumCluster, activeMaster, secret); err != nil {
+		return nil, err
+	}
+	r.Log.Info("set admin password")
+	return secret, nil
+}
+
+// storeAdminPassword generates a gpadmin password and sets it. The password is stored in secret, which is created
+// if it does not exist, before the role is altered, and alongside the current one until the role has been altered,
+// so a password that may already be set is never lost when altering the role or the operator fails.
+func (r *GreenplumClusterReconciler) storeAdminPassword(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, secret *corev1.Secret) error {
+	password, err := generatePassword()
+	if err != nil {
+		return fmt.Errorf("generating admin password: %w", err)
+	}
+
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, secret, func() error {
+		secret.Labels = map[string]string{
+			"app":               "greenplum",
+			"greenplum-cluster": greenplumCluster.Name,
+		}
+		secret.Type = corev1.SecretTypeBasicAuth
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[corev1.BasicAuthUsernameKey] = []byte("gpadmin")
+		secret.Data[pendingAdminPasswordKey] = []byte(password)
+		return ctrl.SetControllerReference(greenplumCluster, secret, r.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("storing pending admin password: %w", err)
+	}
+	r.logReconcileResult(operationResult, secret)
+
+	return r.applyPendingAdminPassword(ctx, greenplumCluster, activeMaster, secret)
+}
+
+// applyPendingAdminPassword sets the pending password of secret on the gpadmin role, then makes it the password
+// of secret. Setting the same password again is harmless, so an interrupted change is applied by calling it again.
+func (r *GreenplumClusterReconciler) applyPendingAdminPassword(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, secret *corev1.Secret) error {
+	password := secret.Data[pendingAdminPasswordKey]
+	if err := r.alterAdminPassword(greenplumCluster.Namespace, activeMaster, string(password)); err != nil {
+		return err
+	}
+
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, secret, func() error {
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[AdminPasswordRotatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		secret.Data[corev1.BasicAuthPasswordKey] = password
+		delete(secret.Data, pendingAdminPasswordKey)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("updating connection secret: %w", err)
+	}
+	r.logReconcileResult(operationResult, secret)
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) initializeAdminPasswordRotatedAt(ctx context.Context, secret *corev1.Secret) error {
+	original := secret.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[AdminPasswordRotatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, secret, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("initializing admin password rotation timestamp: %w", err)
+	}
+	r.Log.Info("initialized admin password rotation timestamp")
+	return nil
+}
+
+// alterAdminPassword sets password on the gpadmin role. The statement is streamed to psql on stdin,
+// so that the password does not appear in the arguments of any process in the pod.
+func (r *GreenplumClusterReconciler) alterAdminPassword(namespace, activeMaster, password string) error {
+	alterRoleCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -v ON_ERROR_STOP=1 -f -",
+	}
+	stdin := strings.NewReader(fmt.Sprintf("ALTER ROLE gpadmin WITH PASSWORD '%s';\n", password))
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.ExecuteWithStdin(alterRoleCommand, namespace, activeMaster, stdin, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("altering admin password: %w: %s", err, stderrBuf.String())
+	}
+	return nil
+}
+
+// generatePassword returns a random password made of URL-safe base64 characters,
+// so it can be embedded in a SQL string literal without escaping.
+func generatePassword() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
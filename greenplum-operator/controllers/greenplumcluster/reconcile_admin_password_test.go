@@ -0,0 +1,210 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile admin password rotation for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		secretKey           types.NamespacedName
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+
+		podExec = &fake.PodExec{}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		secretKey = types.NamespacedName{Namespace: namespaceName, Name: greenplumcluster.ConnectionSecretName}
+	})
+
+	var (
+		reconcileResult ctrl.Result
+		reconcileErr    error
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	createSecret := func(password string, rotatedAt time.Time) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: secretKey.Namespace,
+				Name:      secretKey.Name,
+				Annotations: map[string]string{
+					greenplumcluster.AdminPasswordRotatedAtAnnotation: rotatedAt.UTC().Format(time.RFC3339),
+				},
+			},
+			Data: map[string][]byte{
+				"username": []byte("gpadmin"),
+				"password": []byte(password),
+			},
+		}
+		Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+	}
+
+	getSecret := func() *corev1.Secret {
+		var secret corev1.Secret
+		Expect(reactiveClient.Get(ctx, secretKey, &secret)).To(Succeed())
+		return &secret
+	}
+
+	When("adminPasswordRotationInterval is not set", func() {
+		It("does not manage the admin password", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconcileResult.RequeueAfter).To(BeZero())
+			err := reactiveClient.Get(ctx, secretKey, &corev1.Secret{})
+			Expect(apierrs.IsNotFound(err)).To(BeTrue(), "expected connection secret to not exist")
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ALTER ROLE")))
+		})
+	})
+
+	When("adminPasswordRotationInterval is set", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.MasterAndStandby.AdminPasswordRotationInterval = &metav1.Duration{Duration: 24 * time.Hour}
+		})
+
+		When("the connection secret does not exist", func() {
+			It("sets the admin password and creates the connection secret", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				secret := getSecret()
+				Expect(secret.Type).To(Equal(corev1.SecretTypeBasicAuth))
+				Expect(string(secret.Data["username"])).To(Equal("gpadmin"))
+				password := string(secret.Data["password"])
+				Expect(password).To(HaveLen(32))
+				Expect(podExec.CalledPodName).To(Equal("master-0"))
+				Expect(podExec.RecordedStdin).To(ConsistOf("ALTER ROLE gpadmin WITH PASSWORD '" + password + "';\n"))
+				Expect(secret.Data).NotTo(HaveKey("pending-password"))
+				Expect(secret.OwnerReferences).To(HaveLen(1))
+				Expect(secret.OwnerReferences[0].Name).To(Equal(clusterName))
+			})
+			It("records when the password was rotated", func() {
+				rotatedAt, err := time.Parse(time.RFC3339, getSecret().Annotations[greenplumcluster.AdminPasswordRotatedAtAnnotation])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rotatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			})
+			It("requeues when the next rotation is due", func() {
+				Expect(reconcileResult.RequeueAfter).To(Equal(24 * time.Hour))
+			})
+			It("does not pass the password in the arguments of psql", func() {
+				password := string(getSecret().Data["password"])
+				Expect(podExec.RecordedCommands).To(ContainElement(
+					"/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -v ON_ERROR_STOP=1 -f -"))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring(password)))
+			})
+		})
+
+		When("the connection secret has no rotation timestamp", func() {
+			BeforeEach(func() {
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: secretKey.Namespace, Name: secretKey.Name},
+					Data: map[string][]byte{
+						"username": []byte("gpadmin"),
+						"password": []byte("old-password"),
+					},
+				}
+				Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+			})
+			It("starts the rotation interval without rotating the password", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				secret := getSecret()
+				Expect(string(secret.Data["password"])).To(Equal("old-password"))
+				Expect(podExec.RecordedStdin).To(BeEmpty())
+				rotatedAt, err := time.Parse(time.RFC3339, secret.Annotations[greenplumcluster.AdminPasswordRotatedAtAnnotation])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rotatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+				Expect(reconcileResult.RequeueAfter).To(Equal(24 * time.Hour))
+			})
+		})
+
+		When("the connection secret has a pending password", func() {
+			BeforeEach(func() {
+				createSecret("old-password", time.Now().Add(-1*time.Hour))
+				var secret corev1.Secret
+				Expect(reactiveClient.Get(ctx, secretKey, &secret)).To(Succeed())
+				secret.Data["pending-password"] = []byte("new-password")
+				Expect(reactiveClient.Update(ctx, &secret)).To(Succeed())
+			})
+			It("sets the pending password on the role and in the connection secret", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedStdin).To(ConsistOf("ALTER ROLE gpadmin WITH PASSWORD 'new-password';\n"))
+				secret := getSecret()
+				Expect(string(secret.Data["password"])).To(Equal("new-password"))
+				Expect(secret.Data).NotTo(HaveKey("pending-password"))
+				Expect(reconcileResult.RequeueAfter).To(Equal(24 * time.Hour))
+			})
+		})
+
+		When("the password was rotated within the interval", func() {
+			BeforeEach(func() {
+				createSecret("old-password", time.Now().Add(-1*time.Hour))
+			})
+			It("does not rotate the password", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(string(getSecret().Data["password"])).To(Equal("old-password"))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ALTER ROLE")))
+			})
+			It("requeues when the next rotation is due", func() {
+				Expect(reconcileResult.RequeueAfter).To(BeNumerically("~", 23*time.Hour, time.Minute))
+			})
+		})
+
+		When("the rotation interval has elapsed", func() {
+			BeforeEach(func() {
+				createSecret("old-password", time.Now().Add(-25*time.Hour))
+			})
+			It("rotates the password in the database and the connection secret", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				password := string(getSecret().Data["password"])
+				Expect(password).NotTo(Equal("old-password"))
+				Expect(podExec.RecordedStdin).To(ConsistOf("ALTER ROLE gpadmin WITH PASSWORD '" + password + "';\n"))
+				Expect(logBuf).To(gbytes.Say("rotated admin password"))
+			})
+			It("updates the rotation timestamp", func() {
+				rotatedAt, err := time.Parse(time.RFC3339, getSecret().Annotations[greenplumcluster.AdminPasswordRotatedAtAnnotation])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rotatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			})
+		})
+
+		When("altering the role fails", func() {
+			BeforeEach(func() {
+				createSecret("old-password", time.Now().Add(-25*time.Hour))
+				podExec.ErrorMsgOnCommand = "injected error"
+			})
+			It("returns an error and keeps the new password alongside the old one", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("altering admin password: injected error")))
+				secret := getSecret()
+				Expect(string(secret.Data["password"])).To(Equal("old-password"))
+				Expect(secret.Data).To(HaveKey("pending-password"))
+				Expect(podExec.RecordedStdin).To(ConsistOf("ALTER ROLE gpadmin WITH PASSWORD '" + string(secret.Data["pending-password"]) + "';\n"))
+			})
+		})
+	})
+})
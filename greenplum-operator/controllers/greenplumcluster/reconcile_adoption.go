@@ -0,0 +1,248 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often to check whether the StatefulSets of an adopted cluster have been deleted
+const adoptionPollInterval = 5 * time.Second
+
+// handleAdoption takes over the Greenplum cluster running in the namespace when the GreenplumCluster has the adopt
+// annotation. StatefulSets cannot change their selector, so rather than update the existing StatefulSets, it labels
+// their pods and PVCs to match the operator's, and deletes them with their pods orphaned. The operator's StatefulSets
+// then take over the running pods and bind the same PVCs, so the master finds its data directory and starts the
+// existing cluster instead of initializing a new one.
+// It returns true while adoption holds up the rest of the reconcile, with how long to wait before checking again.
+func (r *GreenplumClusterReconciler) handleAdoption(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (bool, time.Duration, error) {
+	if _, requested := greenplumCluster.Annotations[greenplumv1.AdoptAnnotation]; !requested {
+		return false, 0, nil
+	}
+
+	var statefulSets []appsv1.StatefulSet
+	for _, ssetType := range []sset.StatefulSetType{sset.TypeMaster, sset.TypeSegmentA, sset.TypeSegmentB} {
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: string(ssetType)}, &statefulSet); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return false, 0, err
+		}
+		if metav1.IsControlledBy(&statefulSet, greenplumCluster) {
+			continue
+		}
+		if statefulSet.DeletionTimestamp != nil {
+			r.Log.Info("waiting for StatefulSet to be deleted before adopting its pods", "statefulset", statefulSet.Name)
+			return true, adoptionPollInterval, nil
+		}
+		statefulSets = append(statefulSets, statefulSet)
+	}
+
+	if len(statefulSets) == 0 {
+		// either an earlier reconcile deleted the StatefulSets, leaving their pods, or there is no cluster to adopt
+		var masterPod corev1.Pod
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: "master-0"}, &masterPod); err != nil {
+			if apierrs.IsNotFound(err) {
+				return true, 0, r.setAdoptionFailed(ctx, greenplumCluster, "NotFound", "no Greenplum cluster found in the namespace")
+			}
+			return false, 0, err
+		}
+		return false, 0, r.finishAdoption(ctx, greenplumCluster)
+	}
+
+	problems, err := r.adoptionProblems(ctx, greenplumCluster, statefulSets)
+	if err != nil {
+		return false, 0, err
+	}
+	if len(problems) > 0 {
+		return true, 0, r.setAdoptionFailed(ctx, greenplumCluster, "Incompatible", strings.Join(problems, "; "))
+	}
+
+	var names []string
+	for i := range statefulSets {
+		statefulSet := &statefulSets[i]
+		if err := r.labelForAdoption(ctx, greenplumCluster, statefulSet); err != nil {
+			return false, 0, fmt.Errorf("labeling the pods and PVCs of StatefulSet %s: %w", statefulSet.Name, err)
+		}
+		if err := r.Delete(ctx, statefulSet, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !apierrs.IsNotFound(err) {
+			return false, 0, fmt.Errorf("deleting StatefulSet %s: %w", statefulSet.Name, err)
+		}
+		r.Log.Info("deleted StatefulSet, orphaning its pods for adoption", "statefulset", statefulSet.Name)
+		names = append(names, statefulSet.Name)
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "Adopting",
+		fmt.Sprintf("taking over the pods and data volumes of StatefulSets %s", strings.Join(names, ", ")))
+	return true, adoptionPollInterval, nil
+}
+
+// adoptionProblems checks that the cluster running in the namespace has the topology of the GreenplumCluster,
+// that the operator's StatefulSets will bind its data volumes, and that no other controller owns its objects
+func (r *GreenplumClusterReconciler) adoptionProblems(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, statefulSets []appsv1.StatefulSet) ([]string, error) {
+	var problems []string
+	found := map[string]bool{}
+	dataVolumeClaimName := sset.DataVolumeClaimName(greenplumCluster.Name)
+	for i := range statefulSets {
+		statefulSet := &statefulSets[i]
+		found[statefulSet.Name] = true
+		if owner := metav1.GetControllerOf(statefulSet); owner != nil {
+			problems = append(problems, fmt.Sprintf("StatefulSet %s is controlled by %s %s", statefulSet.Name, owner.Kind, owner.Name))
+		}
+		params := sset.GenerateStatefulSetParams(sset.StatefulSetType(statefulSet.Name), greenplumCluster, r.InstanceImage)
+		var replicas int32 = 1
+		if statefulSet.Spec.Replicas != nil {
+			replicas = *statefulSet.Spec.Replicas
+		}
+		if replicas != params.Replicas {
+			problems = append(problems, fmt.Sprintf("StatefulSet %s has %d replicas, but the GreenplumCluster needs %d", statefulSet.Name, replicas, params.Replicas))
+		}
+		if !hasVolumeClaimTemplate(statefulSet, dataVolumeClaimName) {
+			problems = append(problems, fmt.Sprintf("StatefulSet %s has no volumeClaimTemplate %s holding its data", statefulSet.Name, dataVolumeClaimName))
+		}
+	}
+
+	// the master is deleted first, so the whole cluster is only checked for before any of it is handed over
+	if found[string(sset.TypeMaster)] && !found[string(sset.TypeSegmentA)] {
+		problems = append(problems, "StatefulSet segment-a not found")
+	}
+	mirrors := greenplumCluster.Spec.Segments.Mirrors == "yes"
+	if found[string(sset.TypeMaster)] && mirrors && !found[string(sset.TypeSegmentB)] {
+		problems = append(problems, "StatefulSet segment-b not found, but spec.segments.mirrors is yes")
+	}
+	if found[string(sset.TypeSegmentB)] && !mirrors {
+		problems = append(problems, "StatefulSet segment-b holds mirrors, but spec.segments.mirrors is not yes")
+	}
+
+	ownedObjects := []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "agent"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "greenplum"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "greenplum-config"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ssh-secrets"}},
+	}
+	for _, obj := range ownedObjects {
+		name := obj.GetName()
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: name}, obj); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if owner := metav1.GetControllerOf(obj); owner != nil && owner.UID != greenplumCluster.UID {
+			problems = append(problems, fmt.Sprintf("%s is controlled by %s %s", name, owner.Kind, owner.Name))
+		}
+	}
+	return problems, nil
+}
+
+func hasVolumeClaimTemplate(statefulSet *appsv1.StatefulSet, name string) bool {
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		if template.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// labelForAdoption gives the pods and PVCs of statefulSet the labels of the operator's StatefulSet of the same name,
+// so that it takes them over
+func (r *GreenplumClusterReconciler) labelForAdoption(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, statefulSet *appsv1.StatefulSet) error {
+	labels := sset.GenerateGPClusterLabels(statefulSet.Name, greenplumCluster.Name)
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(statefulSet.Namespace)); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !metav1.IsControlledBy(pod, statefulSet) {
+			continue
+		}
+		if err := r.addLabels(ctx, pod, labels); err != nil {
+			return err
+		}
+	}
+
+	var replicas int32 = 1
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			var pvc corev1.PersistentVolumeClaim
+			pvcKey := types.NamespacedName{Namespace: statefulSet.Namespace, Name: fmt.Sprintf("%s-%s-%d", template.Name, statefulSet.Name, ordinal)}
+			if err := r.Get(ctx, pvcKey, &pvc); err != nil {
+				if apierrs.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			if err := r.addLabels(ctx, &pvc, labels); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) addLabels(ctx context.Context, obj client.Object, labels map[string]string) error {
+	original := obj.DeepCopyObject().(client.Object)
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	for key, value := range labels {
+		objLabels[key] = value
+	}
+	obj.SetLabels(objLabels)
+	if equality.Semantic.DeepEqual(obj, original) {
+		return nil
+	}
+	return r.Patch(ctx, obj, client.MergeFrom(original))
+}
+
+// setAdoptionFailed reports why the cluster cannot be adopted. Nothing is created until the GreenplumCluster is
+// changed to match the cluster, or the adopt annotation is removed to initialize a new cluster instead.
+func (r *GreenplumClusterReconciler) setAdoptionFailed(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, reason, message string) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionAdoptionFailed,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating adoption status: %w", err)
+	}
+	r.Log.Info("unable to adopt the existing cluster", "reason", reason, "message", message)
+	r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "AdoptionFailed", message)
+	return nil
+}
+
+// finishAdoption removes the adopt annotation, leaving the operator's StatefulSets to be created around the pods
+func (r *GreenplumClusterReconciler) finishAdoption(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	delete(greenplumCluster.Annotations, greenplumv1.AdoptAnnotation)
+	meta.RemoveStatusCondition(&greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionAdoptionFailed)
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("removing adopt annotation: %w", err)
+	}
+	r.Log.Info("adopted the existing cluster")
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "Adopted", "took over the existing Greenplum cluster and its data volumes")
+	return nil
+}
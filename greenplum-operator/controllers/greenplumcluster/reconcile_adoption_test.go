@@ -0,0 +1,290 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile adoption of an existing cluster for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		recorder            *record.FakeRecorder
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		pvcTemplateName     string
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			Recorder:      recorder,
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       &fake.PodExec{},
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Annotations = map[string]string{greenplumv1.AdoptAnnotation: "true"}
+		pvcTemplateName = "my-greenplum-pgdata"
+	})
+
+	oldLabels := func(name string) map[string]string {
+		return map[string]string{"app": "legacy-greenplum", "statefulset": name}
+	}
+	createExistingStatefulSet := func(name string, replicas int32) {
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: name, UID: types.UID(name + "-uid")},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: oldLabels(name)},
+				VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+					{ObjectMeta: metav1.ObjectMeta{Name: pvcTemplateName}},
+				},
+			},
+		}
+		Expect(reactiveClient.Create(ctx, statefulSet)).To(Succeed())
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       namespaceName,
+					Name:            fmt.Sprintf("%s-%d", name, ordinal),
+					Labels:          oldLabels(name),
+					OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(statefulSet, appsv1.SchemeGroupVersion.WithKind("StatefulSet"))},
+				},
+			}
+			Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespaceName,
+					Name:      fmt.Sprintf("%s-%s-%d", pvcTemplateName, name, ordinal),
+					Labels:    oldLabels(name),
+				},
+			}
+			Expect(reactiveClient.Create(ctx, pvc)).To(Succeed())
+		}
+	}
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	reconcile := func() {
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	}
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcile()
+	})
+
+	When("a compatible cluster is running in the namespace", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.MasterAndStandby.Standby = "yes"
+			greenplumCluster.Spec.Segments.Mirrors = "yes"
+			createExistingStatefulSet("master", 2)
+			createExistingStatefulSet("segment-a", 1)
+			createExistingStatefulSet("segment-b", 1)
+		})
+
+		It("labels its pods and PVCs for the operator's StatefulSets", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			for _, name := range []string{"master-0", "master-1", "segment-a-0", "segment-b-0"} {
+				var pod corev1.Pod
+				Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &pod)).To(Succeed())
+				Expect(pod.Labels).To(HaveKeyWithValue("app", "greenplum"))
+				Expect(pod.Labels).To(HaveKeyWithValue("greenplum-cluster", "my-greenplum"))
+				Expect(pod.Labels).To(HaveKeyWithValue("type", pod.OwnerReferences[0].Name))
+
+				var pvc corev1.PersistentVolumeClaim
+				Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "my-greenplum-pgdata-" + name}, &pvc)).To(Succeed())
+				Expect(pvc.Labels).To(HaveKeyWithValue("app", "greenplum"))
+				Expect(pvc.Labels).To(HaveKeyWithValue("greenplum-cluster", "my-greenplum"))
+				Expect(pvc.Labels).To(HaveKeyWithValue("type", pod.OwnerReferences[0].Name))
+			}
+		})
+
+		It("deletes its StatefulSets, leaving the pods to be adopted", func() {
+			var statefulSets appsv1.StatefulSetList
+			Expect(reactiveClient.List(ctx, &statefulSets)).To(Succeed())
+			Expect(statefulSets.Items).To(HaveLen(3))
+			for _, statefulSet := range statefulSets.Items {
+				Expect(statefulSet.DeletionTimestamp).NotTo(BeNil())
+				Expect(statefulSet.Finalizers).To(ConsistOf(metav1.FinalizerOrphanDependents))
+			}
+			var pods corev1.PodList
+			Expect(reactiveClient.List(ctx, &pods)).To(Succeed())
+			Expect(pods.Items).To(HaveLen(4))
+			Expect(logBuf).To(gbytes.Say(`"msg":"deleted StatefulSet, orphaning its pods for adoption","statefulset":"master"`))
+			Expect(recorder.Events).To(Receive(Equal("Normal Adopting taking over the pods and data volumes of StatefulSets master, segment-a, segment-b")))
+		})
+
+		It("does not create any cluster resources yet", func() {
+			Expect(reconcileResult).To(Equal(ctrl.Result{RequeueAfter: 5 * time.Second}))
+			var configMap corev1.ConfigMap
+			err := reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "greenplum-config"}, &configMap)
+			Expect(err).To(HaveOccurred())
+			Expect(reconciledCluster.Annotations).To(HaveKey(greenplumv1.AdoptAnnotation))
+		})
+
+		When("reconciled while the StatefulSets are being deleted", func() {
+			JustBeforeEach(func() {
+				reconcile()
+			})
+
+			It("waits for them to be deleted", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconcileResult).To(Equal(ctrl.Result{RequeueAfter: 5 * time.Second}))
+				Expect(reconciledCluster.Annotations).To(HaveKey(greenplumv1.AdoptAnnotation))
+				Expect(logBuf).To(gbytes.Say(`"msg":"waiting for StatefulSet to be deleted before adopting its pods","statefulset":"master"`))
+			})
+		})
+
+		When("reconciled again once the StatefulSets are deleted", func() {
+			JustBeforeEach(func() {
+				// the garbage collector removes the owner references from the pods, then the StatefulSets
+				var pods corev1.PodList
+				Expect(reactiveClient.List(ctx, &pods)).To(Succeed())
+				for i := range pods.Items {
+					pods.Items[i].OwnerReferences = nil
+					Expect(reactiveClient.Update(ctx, &pods.Items[i])).To(Succeed())
+				}
+				var statefulSets appsv1.StatefulSetList
+				Expect(reactiveClient.List(ctx, &statefulSets)).To(Succeed())
+				for i := range statefulSets.Items {
+					statefulSets.Items[i].Finalizers = nil
+					Expect(reactiveClient.Update(ctx, &statefulSets.Items[i])).To(Succeed())
+				}
+				reconcile()
+			})
+
+			It("removes the adopt annotation and creates the operator's StatefulSets", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.AdoptAnnotation))
+				var master appsv1.StatefulSet
+				Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "master"}, &master)).To(Succeed())
+				Expect(metav1.IsControlledBy(&master, &reconciledCluster)).To(BeTrue())
+				Expect(master.Spec.Selector.MatchLabels).To(Equal(map[string]string{
+					"app":               "greenplum",
+					"type":              "master",
+					"greenplum-cluster": "my-greenplum",
+				}))
+				Expect(master.Spec.VolumeClaimTemplates[0].Name).To(Equal(pvcTemplateName))
+				Expect(recorder.Events).To(Receive(HavePrefix("Normal Adopting")))
+				Expect(recorder.Events).To(Receive(Equal("Normal Adopted took over the existing Greenplum cluster and its data volumes")))
+			})
+
+			It("keeps the pods and data volumes of the cluster rather than reinitializing it", func() {
+				var pods corev1.PodList
+				Expect(reactiveClient.List(ctx, &pods)).To(Succeed())
+				Expect(pods.Items).To(HaveLen(4))
+				var master0 corev1.Pod
+				Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "master-0"}, &master0)).To(Succeed())
+				Expect(master0.Labels).To(HaveKeyWithValue("type", "master"))
+
+				var pvcs corev1.PersistentVolumeClaimList
+				Expect(reactiveClient.List(ctx, &pvcs)).To(Succeed())
+				Expect(pvcs.Items).To(HaveLen(4))
+				Expect(pvcs.Items).To(ContainElement(MatchFields(IgnoreExtras, Fields{
+					"ObjectMeta": MatchFields(IgnoreExtras, Fields{"Name": Equal("my-greenplum-pgdata-master-0")}),
+				})))
+			})
+		})
+	})
+
+	When("the existing cluster does not match the GreenplumCluster", func() {
+		BeforeEach(func() {
+			pvcTemplateName = "legacy-pgdata"
+			createExistingStatefulSet("master", 1)
+			createExistingStatefulSet("segment-a", 2)
+		})
+
+		It("reports why and leaves the cluster alone", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconcileResult).To(Equal(ctrl.Result{}))
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionAdoptionFailed)
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionTrue),
+				"Reason": Equal("Incompatible"),
+				"Message": Equal("StatefulSet master has no volumeClaimTemplate my-greenplum-pgdata holding its data; " +
+					"StatefulSet segment-a has 2 replicas, but the GreenplumCluster needs 1; " +
+					"StatefulSet segment-a has no volumeClaimTemplate my-greenplum-pgdata holding its data"),
+			})))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning AdoptionFailed StatefulSet master has no volumeClaimTemplate")))
+
+			var statefulSets appsv1.StatefulSetList
+			Expect(reactiveClient.List(ctx, &statefulSets)).To(Succeed())
+			Expect(statefulSets.Items).To(HaveLen(2))
+			for _, statefulSet := range statefulSets.Items {
+				Expect(statefulSet.Spec.Selector.MatchLabels).To(Equal(oldLabels(statefulSet.Name)))
+			}
+			var pod corev1.Pod
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "master-0"}, &pod)).To(Succeed())
+			Expect(pod.Labels).To(Equal(oldLabels("master")))
+		})
+	})
+
+	When("a mirrored cluster is missing its mirrors", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Mirrors = "yes"
+			createExistingStatefulSet("master", 1)
+			createExistingStatefulSet("segment-a", 1)
+		})
+
+		It("reports the missing StatefulSet", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionAdoptionFailed)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Message).To(Equal("StatefulSet segment-b not found, but spec.segments.mirrors is yes"))
+		})
+	})
+
+	When("there is no cluster in the namespace", func() {
+		It("does not initialize a new cluster", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionAdoptionFailed)
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("NotFound"),
+				"Message": Equal("no Greenplum cluster found in the namespace"),
+			})))
+			var statefulSets appsv1.StatefulSetList
+			Expect(reactiveClient.List(ctx, &statefulSets)).To(Succeed())
+			Expect(statefulSets.Items).To(BeEmpty())
+		})
+	})
+
+	When("adoption is not requested", func() {
+		BeforeEach(func() {
+			greenplumCluster.Annotations = nil
+		})
+
+		It("creates the cluster as usual", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.Conditions).NotTo(ContainElement(MatchFields(IgnoreExtras, Fields{
+				"Type": Equal(greenplumv1.GreenplumClusterConditionAdoptionFailed),
+			})))
+			var master appsv1.StatefulSet
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "master"}, &master)).To(Succeed())
+		})
+	})
+})
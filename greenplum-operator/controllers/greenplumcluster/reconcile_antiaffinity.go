@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -23,15 +24,15 @@ func handleAntiAffinity(ctx context.Context, c client.Client, greenplumCluster g
 		greenplumCluster.Spec.Segments.AntiAffinity == "yes" {
 
 		var masterNodeList corev1.NodeList
-		masterWorkerSelectorLabel := greenplumCluster.Spec.MasterAndStandby.WorkerSelector
-		err = c.List(ctx, &masterNodeList, client.MatchingLabels(masterWorkerSelectorLabel))
+		masterNodeSelector := sset.NodeSelector(greenplumCluster.Spec.MasterAndStandby.GreenplumPodSpec)
+		err = c.List(ctx, &masterNodeList, client.MatchingLabels(masterNodeSelector))
 		if err != nil {
 			return fmt.Errorf("master node worker selector list: %w", err)
 		}
 
 		var segmentNodeList corev1.NodeList
-		segmentWorkerSelectorLabel := greenplumCluster.Spec.Segments.WorkerSelector
-		err = c.List(ctx, &segmentNodeList, client.MatchingLabels(segmentWorkerSelectorLabel))
+		segmentNodeSelector := sset.NodeSelector(greenplumCluster.Spec.Segments.GreenplumPodSpec)
+		err = c.List(ctx, &segmentNodeList, client.MatchingLabels(segmentNodeSelector))
 		if err != nil {
 			return fmt.Errorf("segment node worker selector list: %w", err)
 		}
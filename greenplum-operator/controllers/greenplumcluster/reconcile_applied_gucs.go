@@ -0,0 +1,104 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleAppliedGUCs reports the live values of the GUCs the operator sets in status.appliedGUCs.
+// GUCs unknown to the server, e.g. those of an extension that is not loaded, are left out. The GUCsNotApplied
+// condition lists the GUCs in spec.config.gucs whose live value differs, e.g. while they wait for a restart.
+func (r *GreenplumClusterReconciler) handleAppliedGUCs(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	appliedGUCs, err := r.queryAppliedGUCs(greenplumCluster.Namespace, activeMaster, configmap.GUCNames(greenplumCluster))
+	if err != nil {
+		return err
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.AppliedGUCs = appliedGUCs
+	setGUCsNotAppliedCondition(greenplumCluster)
+	if equality.Semantic.DeepEqual(greenplumCluster.Status, originalGreenplumCluster.Status) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating applied GUCs: %w", err)
+	}
+	return nil
+}
+
+func setGUCsNotAppliedCondition(greenplumCluster *greenplumv1.GreenplumCluster) {
+	var notApplied []string
+	for name, value := range greenplumCluster.Spec.Config.GUCs {
+		appliedValue, ok := greenplumCluster.Status.AppliedGUCs[strings.ToLower(name)]
+		if ok && !sameGUCValue(name, appliedValue, value) {
+			notApplied = append(notApplied, name)
+		}
+	}
+	if len(notApplied) == 0 {
+		if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionGUCsNotApplied) == nil {
+			return
+		}
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionGUCsNotApplied,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "GUCsApplied",
+			Message:            "all GUCs in spec.config.gucs have their requested values",
+		})
+		return
+	}
+	sort.Strings(notApplied)
+	reason := "Pending"
+	if restart := greenplumCluster.Status.GUCRestart; restart != nil && !restart.Finished {
+		reason = "RestartInProgress"
+	}
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionGUCsNotApplied,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            "GUCs differ from spec.config.gucs: " + strings.Join(notApplied, ", "),
+	})
+}
+
+func (r *GreenplumClusterReconciler) queryAppliedGUCs(namespace, activeMaster string, names []string) (map[string]string, error) {
+	quotedNames := make([]string, len(names))
+	for i, name := range names {
+		quotedNames[i] = "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	}
+	// current_setting reports values the way SHOW does, with units. The query is quoted for the shell
+	query := fmt.Sprintf("SELECT name, current_setting(name) FROM pg_settings WHERE name IN (%s) ORDER BY name", strings.Join(quotedNames, ", "))
+	appliedGUCsCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf("source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc '%s'", strings.ReplaceAll(query, "'", `'\''`)),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(appliedGUCsCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return nil, fmt.Errorf("querying applied GUCs: %w: %s", err, stderrBuf.String())
+	}
+
+	var appliedGUCs map[string]string
+	for _, line := range strings.Split(stdoutBuf.String(), "\n") {
+		fields := strings.SplitN(line, "|", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		if appliedGUCs == nil {
+			appliedGUCs = map[string]string{}
+		}
+		appliedGUCs[fields[0]] = fields[1]
+	}
+	return appliedGUCs, nil
+}
@@ -0,0 +1,155 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Reconcile applied GUCs for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			AppliedGUCs: "gp_resource_group_memory_limit|1\n" +
+				"gp_resource_manager|group\n" +
+				"optimizer|on\n",
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			PodExec:       podExec,
+			InstanceImage: "greenplum-for-kubernetes:greenplumv1.0",
+			OperatorImage: "greenplum-operator:greenplumv1.0",
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+		greenplumCluster.Status = greenplumv1.GreenplumClusterStatus{
+			InstanceImage:   greenplumReconciler.InstanceImage,
+			OperatorVersion: greenplumReconciler.OperatorImage,
+			Phase:           greenplumv1.GreenplumClusterPhaseRunning,
+		}
+	})
+
+	var (
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	It("reports the live values of the GUCs the operator sets", func() {
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		Expect(reconciledCluster.Status.AppliedGUCs).To(Equal(map[string]string{
+			"gp_resource_group_memory_limit": "1",
+			"gp_resource_manager":            "group",
+			"optimizer":                      "on",
+		}))
+	})
+
+	When("applied GUCs were reported before", func() {
+		BeforeEach(func() {
+			greenplumCluster.Status.AppliedGUCs = map[string]string{
+				"gp_resource_manager": "group",
+				"optimizer":           "off",
+			}
+		})
+		It("replaces them with the live values", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.AppliedGUCs).To(HaveKeyWithValue("optimizer", "on"))
+			Expect(reconciledCluster.Status.AppliedGUCs).To(HaveLen(3))
+		})
+	})
+
+	It("reports the GUCs in the spec whose live value differs", func() {
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionGUCsNotApplied)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("Pending"))
+		Expect(condition.Message).To(Equal("GUCs differ from spec.config.gucs: optimizer"))
+	})
+
+	When("a restart for GUCs is in progress", func() {
+		BeforeEach(func() {
+			greenplumCluster.Status.GUCRestart = &greenplumv1.GreenplumGUCRestartStatus{
+				GUCs:        map[string]string{"max_connections": "500"},
+				RequestedAt: metav1.Now(),
+			}
+		})
+		It("reports that the GUCs wait for the restart", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionGUCsNotApplied)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal("RestartInProgress"))
+		})
+	})
+
+	When("the GUCs in the spec have their live values", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"optimizer": "on", "gp_resource_group_memory_limit": "1.0"}
+		})
+		It("does not add the condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionGUCsNotApplied)).To(BeNil())
+		})
+
+		When("the condition was reported before", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:               greenplumv1.GreenplumClusterConditionGUCsNotApplied,
+					Status:             metav1.ConditionTrue,
+					Reason:             "Pending",
+					Message:            "GUCs differ from spec.config.gucs: optimizer",
+					LastTransitionTime: metav1.Now(),
+				}}
+			})
+			It("sets it to False", func() {
+				condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionGUCsNotApplied)
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				Expect(condition.Reason).To(Equal("GUCsApplied"))
+			})
+		})
+	})
+
+	When("the applied GUCs are unchanged", func() {
+		It("does not patch the cluster again", func() {
+			reactiveClient.ExpectIdempotentReconcile(func() error {
+				_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				return err
+			})
+		})
+	})
+
+	When("querying the applied GUCs fails", func() {
+		BeforeEach(func() {
+			podExec.AppliedGUCsErr = errors.New("injected error")
+			greenplumCluster.Status.AppliedGUCs = map[string]string{"optimizer": "off"}
+		})
+		It("logs the error, keeps the previous values and carries on reconciling", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(logBuf).To(gbytes.Say(`"msg":"unable to report applied GUCs","greenplumcluster":"test-ns/my-greenplum","error":"querying applied GUCs: injected error: "`))
+			Expect(reconciledCluster.Status.AppliedGUCs).To(Equal(map[string]string{"optimizer": "off"}))
+		})
+	})
+})
@@ -0,0 +1,141 @@
+package greenplumcluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleChangeTracking reports the primary segments that gpstate -e lists in change tracking in the
+// SegmentsInChangeTracking condition, so that an administrator knows to run gprecoverseg. The primary
+// segments whose mirrors are still catching up are reported in the MirrorsNotInSync condition.
+func (r *GreenplumClusterReconciler) handleChangeTracking(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	if greenplumCluster.Spec.Segments.Mirrors != "yes" {
+		return nil
+	}
+	mirroringStatusCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && gpstate -e",
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(mirroringStatusCommand, greenplumCluster.Namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("running gpstate -e: %w: %s", err, stderrBuf.String())
+	}
+	changeTrackingSegments := parseChangeTrackingSegments(stdoutBuf.String())
+	unsyncedSegments := parseUnsyncedSegments(stdoutBuf.String())
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if len(changeTrackingSegments) > 0 {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "ChangeTracking",
+			Message: "primary segments are in change tracking: " + strings.Join(changeTrackingSegments, ", ") +
+				"; run gprecoverseg to recover their mirrors",
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "MirrorsInSync",
+			Message:            "no primary segments are in change tracking",
+		})
+	}
+	if len(unsyncedSegments) > 0 {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionMirrorsNotInSync,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "Resynchronizing",
+			Message:            "mirrors of primary segments are not in sync: " + strings.Join(unsyncedSegments, ", "),
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMirrorsNotInSync) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionMirrorsNotInSync,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "MirrorsInSync",
+			Message:            "all mirrors are in sync",
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if len(changeTrackingSegments) > 0 {
+			r.Log.Info("detected segments in change tracking; run gprecoverseg to recover their mirrors", "segments", changeTrackingSegments)
+		}
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return fmt.Errorf("updating mirroring conditions: %w", err)
+		}
+	}
+	return nil
+}
+
+// gpstate prefixes every line with a timestamp, program, host and log level, e.g.
+// 20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Segments in Change Tracking
+var gpstateLogLine = regexp.MustCompile(`^\S+ \S+-\[\w+\]:-(.*)$`)
+
+// parseChangeTrackingSegments returns the primaries, as host:port, listed in the change tracking
+// table of gpstate -e output, e.g.
+//
+//	...-[INFO]:-Segments in Change Tracking
+//	...-[INFO]:-   Current Primary   Port    Change tracking size   Mirror        Port
+//	...-[INFO]:-   segment-a-0       40000   128 bytes              segment-b-0   50000
+func parseChangeTrackingSegments(output string) []string {
+	return parseSegmentTable(output, "in change tracking")
+}
+
+// parseUnsyncedSegments returns the primaries, as host:port, listed in the table of segment pairs
+// that are not in sync in gpstate -e output, e.g.
+//
+//	...-[INFO]:-Unsynchronized Segment Pairs
+//	...-[INFO]:-   Current Primary   Port    WAL sync remaining bytes   Mirror        Port
+//	...-[INFO]:-   segment-a-0       40000   4096                       segment-b-0   50000
+func parseUnsyncedSegments(output string) []string {
+	return parseSegmentTable(output, "unsynchronized segment pairs")
+}
+
+// parseSegmentTable returns the first two columns, as host:port, of the tables that follow a line
+// containing heading, compared in lower case
+func parseSegmentTable(output, heading string) []string {
+	var segments []string
+	inSection, inTable := false, false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := gpstateLogLine.FindStringSubmatch(line); match != nil {
+			line = match[1]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case inTable:
+			if len(fields) < 2 {
+				inSection, inTable = false, false
+				continue
+			}
+			if _, err := strconv.Atoi(fields[1]); err != nil {
+				inSection, inTable = false, false
+				continue
+			}
+			segments = append(segments, fields[0]+":"+fields[1])
+		case inSection && strings.HasPrefix(strings.TrimSpace(line), "Current Primary"):
+			inTable = true
+		case strings.Contains(strings.ToLower(line), heading):
+			inSection = true
+		}
+	}
+	return segments
+}
@@ -0,0 +1,191 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gpstateMirrorsInSync = `20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Starting gpstate with args: -e
+20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Gathering data from segments...
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-Segment Mirroring Status Report
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-All segments are running normally
+`
+
+const gpstateChangeTracking = `20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Starting gpstate with args: -e
+20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Gathering data from segments...
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-Segment Mirroring Status Report
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-Segments in Change Tracking
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-   Current Primary   Port    Change tracking size   Mirror        Port
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-   segment-a-0       40000   128 bytes              segment-b-0   50000
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-   segment-a-1       40000   4 kB                   segment-b-1   50000
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[WARNING]:-2 segment pairs are in Change Tracking
+`
+
+const gpstateUnsynchronized = `20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Starting gpstate with args: -e
+20200622:22:31:09:001234 gpstate:master-0:gpadmin-[INFO]:-Gathering data from segments...
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-Segment Mirroring Status Report
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-Unsynchronized Segment Pairs
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-   Current Primary   Port    WAL sync remaining bytes   Mirror        Port
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-   segment-a-1       40000   4096                       segment-b-1   50000
+20200622:22:31:10:001234 gpstate:master-0:gpadmin-[INFO]:-----------------------------------------------------
+`
+
+var _ = Describe("Reconcile segments in change tracking for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{MirroringStatus: gpstateMirrorsInSync}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+	})
+
+	var reconcileErr error
+	var reconciledCluster greenplumv1.GreenplumCluster
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	When("all mirrors are in sync", func() {
+		It("does not set a change tracking condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking)).To(BeNil())
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionMirrorsNotInSync)).To(BeNil())
+		})
+
+		When("mirrors were not in sync before", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:    greenplumv1.GreenplumClusterConditionMirrorsNotInSync,
+					Status:  metav1.ConditionTrue,
+					Reason:  "Resynchronizing",
+					Message: "mirrors of primary segments are not in sync: segment-a-1:40000",
+				}}
+			})
+			It("clears the condition", func() {
+				condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+					greenplumv1.GreenplumClusterConditionMirrorsNotInSync)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionFalse),
+					"Reason":  Equal("MirrorsInSync"),
+					"Message": Equal("all mirrors are in sync"),
+				})))
+			})
+		})
+
+		When("segments were in change tracking before", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:    greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking,
+					Status:  metav1.ConditionTrue,
+					Reason:  "ChangeTracking",
+					Message: "primary segments are in change tracking: segment-a-0:40000; run gprecoverseg to recover their mirrors",
+				}}
+			})
+			It("clears the change tracking condition", func() {
+				condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+					greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionFalse),
+					"Reason":  Equal("MirrorsInSync"),
+					"Message": Equal("no primary segments are in change tracking"),
+				})))
+			})
+		})
+	})
+
+	When("gpstate reports segments in change tracking", func() {
+		BeforeEach(func() {
+			podExec.MirroringStatus = gpstateChangeTracking
+		})
+		It("succeeds", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+		})
+		It("sets the change tracking condition with the primaries from the gpstate table", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking)
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionTrue),
+				"Reason": Equal("ChangeTracking"),
+				"Message": Equal("primary segments are in change tracking: segment-a-0:40000, segment-a-1:40000; " +
+					"run gprecoverseg to recover their mirrors"),
+			})))
+		})
+		It("logs that recovery is needed", func() {
+			Expect(logBuf).To(gbytes.Say("detected segments in change tracking; run gprecoverseg to recover their mirrors"))
+		})
+	})
+
+	When("gpstate reports segment pairs that are not in sync", func() {
+		BeforeEach(func() {
+			podExec.MirroringStatus = gpstateUnsynchronized
+		})
+		It("sets the condition with the primaries from the gpstate table", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionMirrorsNotInSync)
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("Resynchronizing"),
+				"Message": Equal("mirrors of primary segments are not in sync: segment-a-1:40000"),
+			})))
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking)).To(BeNil())
+		})
+	})
+
+	When("gpstate fails", func() {
+		BeforeEach(func() {
+			podExec.MirroringStatusErr = errors.New("injected error")
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("unable to check segment mirroring status: running gpstate -e: injected error")))
+		})
+	})
+
+	When("the cluster has no mirrors", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Mirrors = "no"
+			podExec.MirroringStatusErr = errors.New("gpstate should not be run")
+		})
+		It("does not run gpstate", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Reconcile connection configmap for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+	)
+	BeforeEach(func() {
+		ctx = context.WithValue(context.Background(), struct{ key string }{"test"}, CurrentGinkgoTestDescription().TestText)
+		logBuf = gbytes.NewBuffer()
+
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    &fake.PodExec{},
+		}
+
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var reconcileErr error
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(context.TODO(), greenplumClusterRequest)
+	})
+
+	When("we expect a connection configmap to exist after Reconcile", func() {
+		var configMap corev1.ConfigMap
+		JustBeforeEach(func() {
+			configMapKey := types.NamespacedName{Namespace: namespaceName, Name: "greenplum-connection"}
+			Expect(reactiveClient.Get(ctx, configMapKey, &configMap)).To(Succeed())
+		})
+
+		When("connection configmap doesn't exist before Reconcile", func() {
+			It("succeeds", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+			})
+			It("fills in connection details", func() {
+				Expect(configMap.Data).To(Equal(map[string]string{
+					"host":         "greenplum.test-ns.svc.cluster.local",
+					"port":         "5432",
+					"database":     "gpadmin",
+					"segmentCount": "1",
+				}))
+			})
+			It("takes ownership", func() {
+				Expect(configMap.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+			})
+		})
+
+		When("connection configmap is out of date before Reconcile", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.PrimarySegmentCount = 3
+				originalConfigMap := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "greenplum-connection"},
+					Data: map[string]string{
+						"host":         "greenplum.test-ns.svc.cluster.local",
+						"port":         "5432",
+						"database":     "gpadmin",
+						"segmentCount": "1",
+					},
+				}
+				Expect(reactiveClient.Create(ctx, originalConfigMap)).To(Succeed())
+			})
+			It("succeeds", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+			})
+			It("updates the segment count", func() {
+				Expect(configMap.Data).To(HaveKeyWithValue("segmentCount", "3"))
+			})
+			It("takes ownership", func() {
+				Expect(configMap.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+			})
+		})
+	})
+
+	When("Creating connection configmap fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("create", "configmaps", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				om, err := meta.Accessor(action.(testing.CreateAction).GetObject())
+				Expect(err).NotTo(HaveOccurred())
+				if om.GetName() == "greenplum-connection" {
+					return true, nil, errors.New("error creating connection configmap")
+				}
+				return false, nil, nil
+			})
+		})
+		It("returns the error", func() {
+			Expect(reconcileErr).To(MatchError("error creating connection configmap"))
+		})
+	})
+})
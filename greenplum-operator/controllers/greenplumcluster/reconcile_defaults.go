@@ -12,6 +12,13 @@ func SetDefaultGreenplumClusterValues(greenplumCluster *greenplumv1.GreenplumClu
 		&greenplumCluster.Spec.Segments.AntiAffinity,
 		&greenplumCluster.Spec.MasterAndStandby.Standby,
 		&greenplumCluster.Spec.Segments.Mirrors,
+		&greenplumCluster.Spec.Segments.FenceReadOnly,
+		&greenplumCluster.Spec.Segments.PersistentVolumeAffinity,
+		&greenplumCluster.Spec.Segments.AutoPrimarySegmentCount,
+		&greenplumCluster.Spec.Segments.Redistribution,
+		&greenplumCluster.Spec.MasterAndStandby.HealthEndpoint,
+		&greenplumCluster.Spec.Segments.HealthEndpoint,
+		&greenplumCluster.Spec.Config.PgStatStatements,
 	}
 	for _, p := range defaultLowercaseFields {
 		// It will be easier to deal with these properties later if they are guaranteed to be lowercase
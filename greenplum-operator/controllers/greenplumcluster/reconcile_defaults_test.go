@@ -51,4 +51,13 @@ var _ = Describe("greenplumcluster.SetDefaultGreenplumClusterValues", func() {
 			}
 		})
 	})
+	When("given a greenplumCluster with redistribution possibly containing uppercase characters", func() {
+		It("sets segments.redistribution to lowercase when given", func() {
+			for _, value := range []string{"Immediate", "IMMEDIATE", "immediate", "Deferred", "DEFERRED", "deferred"} {
+				fakeGreenplumCluster.Spec.Segments.Redistribution = value
+				greenplumcluster.SetDefaultGreenplumClusterValues(fakeGreenplumCluster)
+				Expect(fakeGreenplumCluster.Spec.Segments.Redistribution).To(Equal(strings.ToLower(value)))
+			}
+		})
+	})
 })
@@ -0,0 +1,192 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/replicationjob"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultReplicationInterval = time.Hour
+
+	// How often to check on a running replication job
+	replicationPollInterval = time.Minute
+)
+
+// handleDisasterRecovery keeps a disaster recovery standby in sync with its primary by
+// running a gpcopy job every replicationInterval until it is promoted, and records the outcome in status.
+// It returns how long to wait before checking again, or zero if the cluster is not a standby.
+func (r *GreenplumClusterReconciler) handleDisasterRecovery(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	if greenplumCluster.Spec.DisasterRecovery == nil {
+		return 0, nil
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	untilNextCheck, err := r.replicateFromPrimary(ctx, greenplumCluster, activeMaster)
+	if err != nil {
+		return 0, err
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return 0, fmt.Errorf("updating replication status: %w", err)
+		}
+	}
+	return untilNextCheck, nil
+}
+
+func (r *GreenplumClusterReconciler) replicateFromPrimary(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	disasterRecovery := greenplumCluster.Spec.DisasterRecovery
+	interval := DefaultReplicationInterval
+	if disasterRecovery.ReplicationInterval != nil {
+		interval = disasterRecovery.ReplicationInterval.Duration
+	}
+	jobKey := types.NamespacedName{
+		Namespace: greenplumCluster.Namespace,
+		Name:      fmt.Sprintf("%s-gpcopy-job", greenplumCluster.Name),
+	}
+	if greenplumCluster.Status.DisasterRecovery == nil {
+		greenplumCluster.Status.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoveryStatus{}
+	}
+
+	if disasterRecovery.Promote {
+		return 0, r.stopReplication(ctx, greenplumCluster, jobKey)
+	}
+
+	primaryHost := disasterRecovery.PrimaryHost
+	primaryDescription := primaryHost
+	if disasterRecovery.PrimaryNamespace != "" {
+		primaryNamespace := disasterRecovery.PrimaryNamespace
+		var primaryList greenplumv1.GreenplumClusterList
+		if err := r.List(ctx, &primaryList, client.InNamespace(primaryNamespace)); err != nil {
+			return 0, fmt.Errorf("listing primary GreenplumClusters: %w", err)
+		}
+		if len(primaryList.Items) == 0 {
+			setReplicationFailedCondition(greenplumCluster, metav1.ConditionTrue, "PrimaryNotFound",
+				fmt.Sprintf("no GreenplumCluster found in namespace %s", primaryNamespace))
+			return interval, nil
+		}
+		// there can only be one GreenplumCluster per namespace
+		primary := primaryList.Items[0]
+		greenplumCluster.Status.DisasterRecovery.PrimaryCluster = primary.Name
+		// the greenplum Service of the primary points at its active master
+		primaryHost = fmt.Sprintf("greenplum.%s.svc", primaryNamespace)
+		primaryDescription = primaryNamespace + "/" + primary.Name
+	}
+
+	var existingJob batchv1.Job
+	if err := r.Get(ctx, jobKey, &existingJob); err == nil {
+		var lastRun time.Time
+		switch {
+		case existingJob.Status.Succeeded > 0:
+			completionTime := metav1.Now()
+			if existingJob.Status.CompletionTime != nil {
+				completionTime = *existingJob.Status.CompletionTime
+			}
+			greenplumCluster.Status.DisasterRecovery.LastReplicationTime = &completionTime
+			setReplicationFailedCondition(greenplumCluster, metav1.ConditionFalse, "Replicated",
+				fmt.Sprintf("copied the databases of %s", primaryDescription))
+			lastRun = completionTime.Time
+		case existingJob.Status.Failed > 0:
+			setReplicationFailedCondition(greenplumCluster, metav1.ConditionTrue, "JobFailed",
+				fmt.Sprintf("replication job %s failed", existingJob.Name))
+			lastRun = existingJob.CreationTimestamp.Time
+			if existingJob.Status.StartTime != nil {
+				lastRun = existingJob.Status.StartTime.Time
+			}
+		default:
+			// still running
+			return replicationPollInterval, nil
+		}
+
+		if untilNextReplication := time.Until(lastRun.Add(interval)); untilNextReplication > 0 {
+			return untilNextReplication, nil
+		}
+		err = r.Delete(ctx, &existingJob, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil {
+			return 0, fmt.Errorf("deleting previous replication job: %w", err)
+		}
+	} else if !apierrs.IsNotFound(err) {
+		return 0, fmt.Errorf("getting replication job: %w", err)
+	}
+
+	if secretName := disasterRecovery.CredentialsSecretName; secretName != "" {
+		var secret corev1.Secret
+		err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: secretName}, &secret)
+		if apierrs.IsNotFound(err) {
+			setReplicationFailedCondition(greenplumCluster, metav1.ConditionTrue, "CredentialsNotFound",
+				fmt.Sprintf("credentials Secret %s not found", secretName))
+			return replicationPollInterval, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("getting replication credentials: %w", err)
+		}
+	}
+
+	primaryPort := disasterRecovery.PrimaryPort
+	if primaryPort == 0 {
+		primaryPort = 5432
+	}
+	activeMasterFQDN := fmt.Sprintf("%s.agent.%s.svc.cluster.local", activeMaster, greenplumCluster.Namespace)
+	job := replicationjob.GenerateJob(r.InstanceImage, primaryHost, primaryPort, disasterRecovery.CredentialsSecretName, activeMasterFQDN)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+
+	if err := ctrl.SetControllerReference(greenplumCluster, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return 0, err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return 0, fmt.Errorf("creating replication job: %w", err)
+	}
+	r.Log.Info("started replication from primary", "primary", primaryDescription, "job", job.Name)
+	return replicationPollInterval, nil
+}
+
+// stopReplication fails over to a promoted standby. A copy that is still running is stopped, since it would
+// overwrite the writes made to the standby from now on
+func (r *GreenplumClusterReconciler) stopReplication(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, jobKey types.NamespacedName) error {
+	var existingJob batchv1.Job
+	err := r.Get(ctx, jobKey, &existingJob)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("getting replication job: %w", err)
+	}
+	if err == nil && existingJob.Status.Succeeded == 0 && existingJob.Status.Failed == 0 {
+		err = r.Delete(ctx, &existingJob, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrs.IsNotFound(err) {
+			return fmt.Errorf("stopping running replication job: %w", err)
+		}
+		r.Log.Info("stopped running replication for promotion", "job", existingJob.Name)
+	}
+
+	if greenplumCluster.Status.DisasterRecovery.PromotionTime == nil {
+		now := metav1.Now()
+		greenplumCluster.Status.DisasterRecovery.PromotionTime = &now
+		r.Log.Info("promoted disaster recovery standby; replication stopped")
+	}
+	setReplicationFailedCondition(greenplumCluster, metav1.ConditionFalse, "Promoted",
+		"replication stopped: the cluster was promoted")
+	return nil
+}
+
+func setReplicationFailedCondition(greenplumCluster *greenplumv1.GreenplumCluster, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionReplicationFailed,
+		Status:             status,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
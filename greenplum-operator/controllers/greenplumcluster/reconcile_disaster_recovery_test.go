@@ -0,0 +1,310 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile disaster recovery for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		jobKey              types.NamespacedName
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       &fake.PodExec{},
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{PrimaryNamespace: "primary-ns"}
+		jobKey = types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpcopy-job"}
+	})
+
+	var (
+		result       ctrl.Result
+		reconcileErr error
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		result, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	reconciledCluster := func() greenplumv1.GreenplumCluster {
+		var cluster greenplumv1.GreenplumCluster
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &cluster)).To(Succeed())
+		return cluster
+	}
+
+	When("the cluster is not a disaster recovery standby", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.DisasterRecovery = nil
+		})
+		It("does not create a replication job", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(MatchError(ContainSubstring("not found")))
+			Expect(reconciledCluster().Status.DisasterRecovery).To(BeNil())
+		})
+	})
+
+	When("there is no GreenplumCluster in the primary namespace", func() {
+		It("reports that the primary cannot be found", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := meta.FindStatusCondition(reconciledCluster().Status.Conditions, greenplumv1.GreenplumClusterConditionReplicationFailed)
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("PrimaryNotFound"),
+				"Message": Equal("no GreenplumCluster found in namespace primary-ns"),
+			})))
+		})
+		It("checks again after the replication interval", func() {
+			Expect(result.RequeueAfter).To(Equal(greenplumcluster.DefaultReplicationInterval))
+		})
+	})
+
+	When("there is a primary GreenplumCluster", func() {
+		BeforeEach(func() {
+			primary := exampleGreenplumCluster.DeepCopy()
+			primary.Name = "primary-greenplum"
+			primary.Namespace = "primary-ns"
+			Expect(reactiveClient.Create(ctx, primary)).To(Succeed())
+		})
+
+		When("no replication has run yet", func() {
+			It("creates a gpcopy job from the primary into the active master", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("greenplum-for-kubernetes:latest"))
+				Expect(job.Spec.Template.Spec.Containers[0].Env).To(ConsistOf(
+					corev1.EnvVar{Name: "GPCOPY_SOURCE_HOST", Value: "greenplum.primary-ns.svc"},
+					corev1.EnvVar{Name: "GPCOPY_SOURCE_PORT", Value: "5432"},
+					corev1.EnvVar{Name: "GPCOPY_DEST_HOST", Value: "master-0.agent.test-ns.svc.cluster.local"},
+				))
+				Expect(job.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+				Expect(logBuf).To(gbytes.Say("started replication from primary"))
+			})
+			It("records the primary in status", func() {
+				Expect(reconciledCluster().Status.DisasterRecovery).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"PrimaryCluster":      Equal("primary-greenplum"),
+					"LastReplicationTime": BeNil(),
+				})))
+			})
+			It("checks on the job again soon", func() {
+				Expect(result.RequeueAfter).To(Equal(time.Minute))
+			})
+		})
+
+		When("the last replication succeeded within the interval", func() {
+			var completionTime metav1.Time
+			BeforeEach(func() {
+				completionTime = metav1.NewTime(time.Now().Add(-10 * time.Minute).Truncate(time.Second))
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+					Status:     batchv1.JobStatus{Succeeded: 1, CompletionTime: &completionTime},
+				}
+				Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			})
+			It("records the replication time and clears the failure condition", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				cluster := reconciledCluster()
+				Expect(cluster.Status.DisasterRecovery.LastReplicationTime.Time).To(BeTemporally("==", completionTime.Time))
+				condition := meta.FindStatusCondition(cluster.Status.Conditions, greenplumv1.GreenplumClusterConditionReplicationFailed)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionFalse),
+					"Reason":  Equal("Replicated"),
+					"Message": Equal("copied the databases of primary-ns/primary-greenplum"),
+				})))
+			})
+			It("waits for the rest of the interval before replicating again", func() {
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Status.Succeeded).To(Equal(int32(1)))
+				Expect(result.RequeueAfter).To(BeNumerically("~", 50*time.Minute, time.Minute))
+			})
+		})
+
+		When("the last replication succeeded longer ago than the interval", func() {
+			BeforeEach(func() {
+				completionTime := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+					Status:     batchv1.JobStatus{Succeeded: 1, CompletionTime: &completionTime},
+				}
+				Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			})
+			It("replaces the job with a new one", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Status.Succeeded).To(BeZero())
+				Expect(job.Spec.Template.Spec.Containers[0].Name).To(Equal("gpcopy"))
+			})
+		})
+
+		When("the last replication failed", func() {
+			BeforeEach(func() {
+				startTime := metav1.NewTime(time.Now().Add(-5 * time.Minute))
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+					Status:     batchv1.JobStatus{Failed: 1, StartTime: &startTime},
+				}
+				Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			})
+			It("reports the failure", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				condition := meta.FindStatusCondition(reconciledCluster().Status.Conditions, greenplumv1.GreenplumClusterConditionReplicationFailed)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("JobFailed"),
+					"Message": Equal("replication job my-greenplum-gpcopy-job failed"),
+				})))
+			})
+		})
+
+		When("a replication is running", func() {
+			BeforeEach(func() {
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+					Status:     batchv1.JobStatus{Active: 1},
+				}
+				Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			})
+			It("leaves it alone and checks again soon", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Status.Active).To(Equal(int32(1)))
+				Expect(result.RequeueAfter).To(Equal(time.Minute))
+			})
+		})
+
+		When("the cluster is promoted", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.DisasterRecovery.Promote = true
+			})
+
+			When("a replication is running", func() {
+				BeforeEach(func() {
+					job := &batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+						Status:     batchv1.JobStatus{Active: 1},
+					}
+					Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+				})
+				It("stops it", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var job batchv1.Job
+					Expect(reactiveClient.Get(ctx, jobKey, &job)).To(MatchError(ContainSubstring("not found")))
+					Expect(logBuf).To(gbytes.Say("stopped running replication for promotion"))
+				})
+			})
+
+			When("the last replication succeeded longer ago than the interval", func() {
+				BeforeEach(func() {
+					completionTime := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+					job := &batchv1.Job{
+						ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+						Status:     batchv1.JobStatus{Succeeded: 1, CompletionTime: &completionTime},
+					}
+					Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+				})
+				It("does not replicate again", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var job batchv1.Job
+					Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+					Expect(job.Status.Succeeded).To(Equal(int32(1)))
+					Expect(result.RequeueAfter).To(BeZero())
+				})
+			})
+
+			It("records the promotion", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				cluster := reconciledCluster()
+				Expect(cluster.Status.DisasterRecovery.PromotionTime).NotTo(BeNil())
+				Expect(cluster.Status.DisasterRecovery.PromotionTime.Time).To(BeTemporally("~", time.Now(), time.Minute))
+				condition := meta.FindStatusCondition(cluster.Status.Conditions, greenplumv1.GreenplumClusterConditionReplicationFailed)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionFalse),
+					"Reason": Equal("Promoted"),
+				})))
+			})
+		})
+	})
+
+	When("the primary is outside this Kubernetes cluster", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{
+				PrimaryHost:           "primary.example.com",
+				PrimaryPort:           6432,
+				CredentialsSecretName: "primary-credentials",
+			}
+		})
+
+		When("the credentials Secret exists", func() {
+			BeforeEach(func() {
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "primary-credentials"},
+					Type:       corev1.SecretTypeBasicAuth,
+					Data:       map[string][]byte{"username": []byte("replicator"), "password": []byte("secret")},
+				}
+				Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+			})
+			It("creates a gpcopy job from the primary host with the credentials", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				env := job.Spec.Template.Spec.Containers[0].Env
+				Expect(env).To(ContainElements(
+					corev1.EnvVar{Name: "GPCOPY_SOURCE_HOST", Value: "primary.example.com"},
+					corev1.EnvVar{Name: "GPCOPY_SOURCE_PORT", Value: "6432"},
+				))
+				Expect(env).To(ContainElement(MatchFields(IgnoreExtras, Fields{
+					"Name": Equal("GPCOPY_SOURCE_PASSWORD"),
+					"ValueFrom": PointTo(MatchFields(IgnoreExtras, Fields{
+						"SecretKeyRef": PointTo(MatchFields(IgnoreExtras, Fields{"Key": Equal("password")})),
+					})),
+				})))
+				Expect(logBuf).To(gbytes.Say(`"primary":"primary.example.com"`))
+			})
+		})
+
+		When("the credentials Secret does not exist", func() {
+			It("reports it and does not create a job", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(MatchError(ContainSubstring("not found")))
+				condition := meta.FindStatusCondition(reconciledCluster().Status.Conditions, greenplumv1.GreenplumClusterConditionReplicationFailed)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("CredentialsNotFound"),
+					"Message": Equal("credentials Secret primary-credentials not found"),
+				})))
+				Expect(result.RequeueAfter).To(Equal(time.Minute))
+			})
+		})
+	})
+})
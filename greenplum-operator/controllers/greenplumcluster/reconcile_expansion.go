@@ -6,53 +6,281 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/gpexpandjob"
+	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *GreenplumClusterReconciler) handleExpand(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+// How often to check on an expansion that is waiting for a backup, or redistributing tables
+const expansionPollInterval = 30 * time.Second
+
+// handleExpand adds segments with a gpexpand job once spec.segments.primarySegmentCount is raised and
+// the pods of the new segments are ready, and reports its progress in the Expanding phase and condition.
+// An expansion in progress is picked up again from the job and status.redistribution, so it survives
+// operator restarts. It returns how long to wait before checking on the expansion again.
+func (r *GreenplumClusterReconciler) handleExpand(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
 	segmentCount, err := r.getCurrentSegmentCount(greenplumCluster.Namespace, activeMaster)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if greenplumCluster.Spec.Segments.PrimarySegmentCount <= segmentCount {
-		return nil
-	}
-
-	jobKey := types.NamespacedName{
-		Namespace: greenplumCluster.Namespace,
-		Name:      fmt.Sprintf("%s-gpexpand-job", greenplumCluster.Name),
+		return r.handleRedistribution(ctx, greenplumCluster, activeMaster)
 	}
 
+	jobKey := gpexpandJobKey(greenplumCluster)
 	var existingJob batchv1.Job
 	if err := r.Get(ctx, jobKey, &existingJob); err == nil {
 		// Job already exists, and is not complete yet
 		if existingJob.Status.Succeeded < 1 {
-			return nil
+			return 0, nil
 		}
 
 		// A job already exists, and has completed successfully
 		err = r.Delete(ctx, &existingJob, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground))
 		if err != nil {
-			return err
+			return 0, err
 		}
 	} else {
 		if !apierrs.IsNotFound(err) {
-			return err
+			return 0, err
+		}
+	}
+
+	notReady, err := r.segmentPodsNotReady(ctx, greenplumCluster)
+	if err != nil {
+		return 0, err
+	}
+	if notReady > 0 {
+		// The segment statefulsets are owned, so their pods becoming ready triggers another reconcile
+		message := fmt.Sprintf("waiting for %d segment pods to be ready before adding segments", notReady)
+		return 0, r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionTrue, "WaitingForSegmentPods", message)
+	}
+	if backupRunning, err := r.isBackupRunning(greenplumCluster.Namespace, activeMaster); err != nil {
+		return 0, err
+	} else if backupRunning {
+		if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionTrue, "WaitingForBackup",
+			"waiting for gpbackup to finish before adding segments"); err != nil {
+			return 0, err
+		}
+		return expansionPollInterval, nil
+	}
+
+	redistribute := greenplumCluster.Spec.Segments.Redistribution == "immediate"
+	if err := r.createGpexpandJob(ctx, greenplumCluster, activeMaster, redistribute); err != nil {
+		return 0, err
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "ExpansionStarted",
+		fmt.Sprintf("adding segments to grow from %d to %d primary segments", segmentCount, greenplumCluster.Spec.Segments.PrimarySegmentCount))
+	if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionTrue, "AddingSegments",
+		fmt.Sprintf("gpexpand is adding segments to grow from %d to %d primary segments", segmentCount, greenplumCluster.Spec.Segments.PrimarySegmentCount)); err != nil {
+		return 0, err
+	}
+	if redistribute {
+		return 0, r.setRedistributionStatus(ctx, greenplumCluster, greenplumv1.GreenplumRedistributionPhaseRunning)
+	}
+	return 0, r.setRedistributionStatus(ctx, greenplumCluster, greenplumv1.GreenplumRedistributionPhasePending)
+}
+
+// handleRedistribution tracks a gpexpand job that is redistributing tables onto new segments, and
+// starts one for an expansion whose redistribution was deferred once redistribution is set to immediate.
+func (r *GreenplumClusterReconciler) handleRedistribution(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	switch greenplumCluster.Status.Redistribution {
+	case greenplumv1.GreenplumRedistributionPhaseRunning:
+		var job batchv1.Job
+		if err := r.Get(ctx, gpexpandJobKey(greenplumCluster), &job); err != nil {
+			if apierrs.IsNotFound(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		switch {
+		case job.Status.Succeeded > 0:
+			r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "ExpansionSucceeded", "added segments and redistributed tables onto them")
+			if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionFalse, "ExpansionComplete",
+				"added segments and redistributed tables onto them"); err != nil {
+				return 0, err
+			}
+			return 0, r.setRedistributionStatus(ctx, greenplumCluster, greenplumv1.GreenplumRedistributionPhaseComplete)
+		case job.Status.Failed > 0:
+			message := fmt.Sprintf("gpexpand job %s failed; check its logs", job.Name)
+			r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "ExpansionFailed", message)
+			if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionFalse, "ExpansionFailed", message); err != nil {
+				return 0, err
+			}
+			return 0, r.setRedistributionStatus(ctx, greenplumCluster, greenplumv1.GreenplumRedistributionPhaseFailed)
+		}
+		if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionTrue, "Redistributing",
+			r.redistributionProgress(greenplumCluster.Namespace, activeMaster)); err != nil {
+			return 0, err
+		}
+		return expansionPollInterval, nil
+	case greenplumv1.GreenplumRedistributionPhasePending:
+		var job batchv1.Job
+		jobErr := r.Get(ctx, gpexpandJobKey(greenplumCluster), &job)
+		if jobErr != nil && !apierrs.IsNotFound(jobErr) {
+			return 0, jobErr
+		}
+		// wait for the job that added the segments before redistributing onto them
+		if jobErr == nil && job.Status.Succeeded < 1 {
+			return 0, nil
+		}
+		if greenplumCluster.Spec.Segments.Redistribution != "immediate" {
+			return 0, r.setRedistributionDeferred(ctx, greenplumCluster)
+		}
+		if backupRunning, err := r.isBackupRunning(greenplumCluster.Namespace, activeMaster); err != nil {
+			return 0, err
+		} else if backupRunning {
+			if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionTrue, "WaitingForBackup",
+				"waiting for gpbackup to finish before redistributing tables"); err != nil {
+				return 0, err
+			}
+			return expansionPollInterval, nil
+		}
+		if jobErr == nil {
+			if err := r.Delete(ctx, &job, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return 0, err
+			}
+		}
+		r.Log.Info("starting deferred redistribution", "greenplumcluster", greenplumCluster.Name)
+		if err := r.createGpexpandJob(ctx, greenplumCluster, activeMaster, true); err != nil {
+			return 0, err
+		}
+		r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "RedistributionStarted", "redistributing tables onto the new segments")
+		if err := r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionTrue, "Redistributing",
+			"gpexpand is redistributing tables onto the new segments"); err != nil {
+			return 0, err
+		}
+		return expansionPollInterval, r.setRedistributionStatus(ctx, greenplumCluster, greenplumv1.GreenplumRedistributionPhaseRunning)
+	}
+	return 0, nil
+}
+
+// setRedistributionDeferred finishes an expansion whose redistribution is deferred, once its segments have been added
+func (r *GreenplumClusterReconciler) setRedistributionDeferred(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	const message = "added segments; set spec.segments.redistribution to immediate to redistribute tables onto them"
+	condition := meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)
+	if condition != nil && condition.Reason == "RedistributionDeferred" {
+		return nil
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "ExpansionSucceeded", message)
+	return r.setExpansionStatus(ctx, greenplumCluster, metav1.ConditionFalse, "RedistributionDeferred", message)
+}
+
+// setExpansionStatus sets the Expanding condition, and keeps the phase Expanding while the condition is True
+func (r *GreenplumClusterReconciler) setExpansionStatus(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, status metav1.ConditionStatus, reason, message string) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionExpanding,
+		Status:             status,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if status == metav1.ConditionTrue {
+		greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseExpanding
+	} else if greenplumCluster.Status.Phase == greenplumv1.GreenplumClusterPhaseExpanding {
+		greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+	}
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating expansion status: %w", err)
+	}
+	return nil
+}
+
+// segmentPodsNotReady returns how many pods of the segment statefulsets are not ready yet
+func (r *GreenplumClusterReconciler) segmentPodsNotReady(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (int32, error) {
+	statefulSetNames := []string{"segment-a"}
+	if greenplumCluster.Spec.Segments.Mirrors == "yes" {
+		statefulSetNames = append(statefulSetNames, "segment-b")
+	}
+	var notReady int32
+	for _, name := range statefulSetNames {
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: name}, &statefulSet); err != nil {
+			return 0, err
+		}
+		if statefulSet.Status.ReadyReplicas < greenplumCluster.Spec.Segments.PrimarySegmentCount {
+			notReady += greenplumCluster.Spec.Segments.PrimarySegmentCount - statefulSet.Status.ReadyReplicas
 		}
 	}
+	return notReady, nil
+}
 
+// isBackupRunning reports whether gpbackup is running on the master. An expansion would change the
+// segments out from under it, and gpexpand takes locks that would block it.
+func (r *GreenplumClusterReconciler) isBackupRunning(namespace, activeMaster string) (bool, error) {
+	backupCheckCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"if pgrep -x gpbackup > /dev/null; then echo running; else echo idle; fi",
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(backupCheckCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return false, fmt.Errorf("checking for a running gpbackup: %w", err)
+	}
+	return strings.TrimSpace(stdoutBuf.String()) == "running", nil
+}
+
+// redistributionProgress describes how many of the tables gpexpand has redistributed, from
+// gpexpand.status_detail. The schema only exists while gpexpand is redistributing, so a failed
+// query is not an error.
+func (r *GreenplumClusterReconciler) redistributionProgress(namespace, activeMaster string) string {
+	const noProgress = "gpexpand is redistributing tables onto the new segments"
+	progressCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		`source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc ` +
+			`"SELECT sum(CASE WHEN status = 'COMPLETED' THEN 1 ELSE 0 END), count(*) FROM gpexpand.status_detail"`,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(progressCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		r.Log.Info("unable to query redistribution progress", "error", err.Error(), "stderr", stderrBuf.String())
+		return noProgress
+	}
+	fields := strings.Split(strings.TrimSpace(stdoutBuf.String()), "|")
+	if len(fields) != 2 {
+		return noProgress
+	}
+	done, doneErr := strconv.Atoi(fields[0])
+	total, totalErr := strconv.Atoi(fields[1])
+	if doneErr != nil || totalErr != nil || total == 0 {
+		return noProgress
+	}
+	return fmt.Sprintf("gpexpand has redistributed %d of %d tables (%d%%)", done, total, done*100/total)
+}
+
+func gpexpandJobKey(greenplumCluster *greenplumv1.GreenplumCluster) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: greenplumCluster.Namespace,
+		Name:      fmt.Sprintf("%s-gpexpand-job", greenplumCluster.Name),
+	}
+}
+
+func (r *GreenplumClusterReconciler) createGpexpandJob(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, redistribute bool) error {
+	jobKey := gpexpandJobKey(greenplumCluster)
 	activeMasterFQDN := fmt.Sprintf("%s.agent.%s.svc.cluster.local", activeMaster, greenplumCluster.Namespace)
-	job := gpexpandjob.GenerateJob(r.InstanceImage, activeMasterFQDN, greenplumCluster.Spec.Segments.PrimarySegmentCount)
+	job := gpexpandjob.GenerateJob(r.InstanceImage, activeMasterFQDN, greenplumCluster.Spec.Segments.PrimarySegmentCount, redistribute)
 	job.Namespace = jobKey.Namespace
 	job.Name = jobKey.Name
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
 
 	if err := ctrl.SetControllerReference(greenplumCluster, &job, r.Scheme()); err != nil {
 		// not tested: not really possible to fail here
@@ -61,6 +289,19 @@ func (r *GreenplumClusterReconciler) handleExpand(ctx context.Context, greenplum
 	return r.Create(ctx, &job)
 }
 
+func (r *GreenplumClusterReconciler) setRedistributionStatus(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, phase greenplumv1.GreenplumRedistributionPhase) error {
+	if greenplumCluster.Status.Redistribution == phase {
+		return nil
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.Redistribution = phase
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating redistribution status: %w", err)
+	}
+	r.Log.Info("set redistribution status", "greenplumcluster", greenplumCluster.Name, "redistribution", phase)
+	return nil
+}
+
 func (r *GreenplumClusterReconciler) getCurrentSegmentCount(namespace, masterPodName string) (int32, error) {
 	getSegmentCountCommand := []string{
 		"/bin/bash",
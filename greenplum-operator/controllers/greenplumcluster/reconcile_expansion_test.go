@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -18,9 +19,13 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -29,6 +34,7 @@ var _ = Describe("Reconcile expansion", func() {
 		logBuf              *gbytes.Buffer
 		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
 		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
 		ctx                 context.Context
 	)
 	BeforeEach(func() {
@@ -36,6 +42,7 @@ var _ = Describe("Reconcile expansion", func() {
 		ctx = context.WithValue(context.Background(), struct{ key string }{"test"}, CurrentGinkgoTestDescription().TestText)
 
 		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
 		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
 			Client:        reactiveClient,
 			Log:           gplog.ForTest(logBuf),
@@ -43,6 +50,7 @@ var _ = Describe("Reconcile expansion", func() {
 			InstanceImage: "greenplum-for-kubernetes:latest",
 			OperatorImage: "greenplum-operator:latest",
 			PodExec:       podExec,
+			Recorder:      recorder,
 		}
 	})
 
@@ -66,6 +74,32 @@ var _ = Describe("Reconcile expansion", func() {
 		podExec.SegmentCount = "5\n"
 	})
 
+	setReadySegmentPods := func(readyReplicas int32) {
+		for _, name := range []string{"segment-a", "segment-b"} {
+			var statefulSet appsv1.StatefulSet
+			err := reactiveClient.Get(nil, types.NamespacedName{Namespace: namespaceName, Name: name}, &statefulSet)
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			Expect(err).NotTo(HaveOccurred())
+			statefulSet.Status.ReadyReplicas = readyReplicas
+			Expect(reactiveClient.Update(nil, &statefulSet)).To(Succeed())
+		}
+	}
+	fetchGreenplumCluster := func() greenplumv1.GreenplumCluster {
+		var greenplumCluster greenplumv1.GreenplumCluster
+		Expect(reactiveClient.Get(nil, greenplumClusterRequest.NamespacedName, &greenplumCluster)).To(Succeed())
+		return greenplumCluster
+	}
+	// expandedGreenplumCluster grows the first spec to 6 segments. It starts from the stored cluster,
+	// since the first reconcile updated it.
+	expandedGreenplumCluster := func() *greenplumv1.GreenplumCluster {
+		greenplumCluster := fetchGreenplumCluster()
+		greenplumCluster.Spec = *firstGreenplumClusterSpec.Spec.DeepCopy()
+		greenplumCluster.Spec.Segments.PrimarySegmentCount = 6
+		return &greenplumCluster
+	}
+
 	When("gpexpand-job does not exist", func() {
 		BeforeEach(func() {
 			// Sanity check
@@ -78,14 +112,19 @@ var _ = Describe("Reconcile expansion", func() {
 		})
 		When("gpdb cluster size is increased to 6", func() {
 			var (
-				reconcileErr error
+				readyReplicas   int32
+				reconcileResult ctrl.Result
+				reconcileErr    error
 			)
+			BeforeEach(func() {
+				readyReplicas = 6
+			})
 			JustBeforeEach(func() {
-				newGreenplumClusterSpec = firstGreenplumClusterSpec.DeepCopy()
-				newGreenplumClusterSpec.Spec.Segments.PrimarySegmentCount = 6
+				newGreenplumClusterSpec = expandedGreenplumCluster()
 
 				Expect(reactiveClient.Update(nil, newGreenplumClusterSpec)).To(Succeed())
-				_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				setReadySegmentPods(readyReplicas)
+				reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
 			})
 			It("Creates a job to run gpexpand", func() {
 				Expect(reconcileErr).NotTo(HaveOccurred())
@@ -111,6 +150,114 @@ var _ = Describe("Reconcile expansion", func() {
 				}))
 				By("setting a controller reference")
 				Expect(gpexpandJob.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+				By("using the default scheduler")
+				Expect(gpexpandJob.Spec.Template.Spec.SchedulerName).To(BeEmpty())
+			})
+			It("reports that the cluster is expanding", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				greenplumCluster := fetchGreenplumCluster()
+				Expect(greenplumCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseExpanding))
+				Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("AddingSegments"),
+					"Message": Equal("gpexpand is adding segments to grow from 5 to 6 primary segments"),
+				})))
+				Expect(recorder.Events).To(Receive(Equal("Normal ExpansionStarted adding segments to grow from 5 to 6 primary segments")))
+			})
+			When("the new segment pods are not ready", func() {
+				BeforeEach(func() {
+					readyReplicas = 5
+				})
+				It("waits for them before running gpexpand", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var gpexpandJob batchv1.Job
+					jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+					Expect(reactiveClient.Get(nil, jobKey, &gpexpandJob)).To(MatchError(`jobs.batch "my-greenplum-gpexpand-job" not found`))
+
+					greenplumCluster := fetchGreenplumCluster()
+					Expect(greenplumCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseExpanding))
+					Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status": Equal(metav1.ConditionTrue),
+						"Reason": Equal("WaitingForSegmentPods"),
+					})))
+					Expect(recorder.Events).NotTo(Receive())
+				})
+			})
+			When("a backup is running", func() {
+				BeforeEach(func() {
+					podExec.BackupRunning = true
+				})
+				It("waits for it to finish before running gpexpand", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+					var gpexpandJob batchv1.Job
+					jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+					Expect(reactiveClient.Get(nil, jobKey, &gpexpandJob)).To(MatchError(`jobs.batch "my-greenplum-gpexpand-job" not found`))
+
+					greenplumCluster := fetchGreenplumCluster()
+					Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status": Equal(metav1.ConditionTrue),
+						"Reason": Equal("WaitingForBackup"),
+					})))
+				})
+			})
+			When("checking for a backup fails", func() {
+				BeforeEach(func() {
+					podExec.BackupRunningErr = errors.New("pgrep failed")
+				})
+				It("returns an error", func() {
+					Expect(reconcileErr).To(MatchError("unable to run gpexpand: checking for a running gpbackup: pgrep failed"))
+				})
+			})
+			When("a schedulerName is set", func() {
+				BeforeEach(func() {
+					firstGreenplumClusterSpec.Spec.SchedulerName = "volcano"
+				})
+				It("creates the job with the schedulerName", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var gpexpandJob batchv1.Job
+					jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+					Expect(reactiveClient.Get(nil, jobKey, &gpexpandJob)).To(Succeed())
+					Expect(gpexpandJob.Spec.Template.Spec.SchedulerName).To(Equal("volcano"))
+
+					var segmentA appsv1.StatefulSet
+					segmentAKey := types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}
+					Expect(reactiveClient.Get(nil, segmentAKey, &segmentA)).To(Succeed())
+					Expect(segmentA.Spec.Template.Spec.SchedulerName).To(Equal("volcano"))
+				})
+			})
+			It("defers redistribution by default", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var gpexpandJob batchv1.Job
+				jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+				Expect(reactiveClient.Get(nil, jobKey, &gpexpandJob)).To(Succeed())
+				Expect(gpexpandJob.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+					Name:  "REDISTRIBUTE",
+					Value: "false",
+				}))
+
+				var greenplumCluster greenplumv1.GreenplumCluster
+				Expect(reactiveClient.Get(nil, greenplumClusterRequest.NamespacedName, &greenplumCluster)).To(Succeed())
+				Expect(greenplumCluster.Status.Redistribution).To(Equal(greenplumv1.GreenplumRedistributionPhasePending))
+			})
+			When("redistribution is immediate", func() {
+				BeforeEach(func() {
+					firstGreenplumClusterSpec.Spec.Segments.Redistribution = "Immediate"
+				})
+				It("creates a job that redistributes after adding the segments", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var gpexpandJob batchv1.Job
+					jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+					Expect(reactiveClient.Get(nil, jobKey, &gpexpandJob)).To(Succeed())
+					Expect(gpexpandJob.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+						Name:  "REDISTRIBUTE",
+						Value: "true",
+					}))
+
+					var greenplumCluster greenplumv1.GreenplumCluster
+					Expect(reactiveClient.Get(nil, greenplumClusterRequest.NamespacedName, &greenplumCluster)).To(Succeed())
+					Expect(greenplumCluster.Status.Redistribution).To(Equal(greenplumv1.GreenplumRedistributionPhaseRunning))
+				})
 			})
 			It("increases the number of replicas in the segment statefulsets", func() {
 				var segmentA appsv1.StatefulSet
@@ -191,12 +338,206 @@ var _ = Describe("Reconcile expansion", func() {
 		})
 	})
 
+	When("the segments have been added", func() {
+		var (
+			existingJob     batchv1.Job
+			reconcileResult ctrl.Result
+			reconcileErr    error
+			sawCreate       bool
+		)
+		BeforeEach(func() {
+			existingJob = gpexpandjob.GenerateJob(greenplumReconciler.InstanceImage, "master-0", 5, false)
+			existingJob.Namespace = namespaceName
+			existingJob.Name = clusterName + "-gpexpand-job"
+			existingJob.Status.Succeeded = 1
+			sawCreate = false
+		})
+		JustBeforeEach(func() {
+			Expect(reactiveClient.Create(nil, &existingJob)).To(Succeed())
+			reactiveClient.PrependReactor("create", "jobs", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				sawCreate = true
+				return false, nil, nil
+			})
+			reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		})
+		setRedistribution := func(spec string, phase greenplumv1.GreenplumRedistributionPhase) {
+			var greenplumCluster greenplumv1.GreenplumCluster
+			Expect(reactiveClient.Get(nil, greenplumClusterRequest.NamespacedName, &greenplumCluster)).To(Succeed())
+			greenplumCluster.Spec.Segments.Redistribution = spec
+			greenplumCluster.Status.Redistribution = phase
+			Expect(reactiveClient.Update(nil, &greenplumCluster)).To(Succeed())
+		}
+		redistributionStatus := func() greenplumv1.GreenplumRedistributionPhase {
+			var greenplumCluster greenplumv1.GreenplumCluster
+			Expect(reactiveClient.Get(nil, greenplumClusterRequest.NamespacedName, &greenplumCluster)).To(Succeed())
+			return greenplumCluster.Status.Redistribution
+		}
+
+		When("redistribution is deferred", func() {
+			BeforeEach(func() {
+				setRedistribution("deferred", greenplumv1.GreenplumRedistributionPhasePending)
+			})
+			It("leaves redistribution pending", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(sawCreate).To(BeFalse(), "should not create a job")
+				Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhasePending))
+			})
+			It("reports that the expansion is done, but tables are not redistributed", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				greenplumCluster := fetchGreenplumCluster()
+				Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionFalse),
+					"Reason": Equal("RedistributionDeferred"),
+				})))
+				Expect(recorder.Events).To(Receive(HavePrefix("Normal ExpansionSucceeded added segments")))
+
+				By("not emitting the event again")
+				_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(recorder.Events).NotTo(Receive())
+			})
+		})
+
+		When("deferred redistribution is changed to immediate", func() {
+			BeforeEach(func() {
+				setRedistribution("immediate", greenplumv1.GreenplumRedistributionPhasePending)
+			})
+			It("replaces the job with one that redistributes", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(sawCreate).To(BeTrue())
+				var gpexpandJob batchv1.Job
+				jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+				Expect(reactiveClient.Get(nil, jobKey, &gpexpandJob)).To(Succeed())
+				Expect(gpexpandJob.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+					Name:  "REDISTRIBUTE",
+					Value: "true",
+				}))
+				Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhaseRunning))
+				Expect(logBuf).To(gbytes.Say("starting deferred redistribution"))
+				Expect(recorder.Events).To(Receive(Equal("Normal RedistributionStarted redistributing tables onto the new segments")))
+				Expect(fetchGreenplumCluster().Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseExpanding))
+			})
+			When("a backup is running", func() {
+				BeforeEach(func() {
+					podExec.BackupRunning = true
+				})
+				It("waits for it to finish before redistributing", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+					Expect(sawCreate).To(BeFalse(), "should not create a job")
+					Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhasePending))
+				})
+			})
+			When("the job adding the segments has not finished", func() {
+				BeforeEach(func() {
+					existingJob.Status.Succeeded = 0
+				})
+				It("waits for it", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(sawCreate).To(BeFalse(), "should not create a job")
+					Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhasePending))
+				})
+			})
+			When("deleting the old job fails", func() {
+				BeforeEach(func() {
+					reactiveClient.PrependReactor("delete", "jobs", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+						return true, nil, errors.New("delete failure")
+					})
+				})
+				It("returns an error", func() {
+					Expect(reconcileErr).To(MatchError("unable to run gpexpand: delete failure"))
+					Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhasePending))
+				})
+			})
+		})
+
+		When("redistribution is running", func() {
+			BeforeEach(func() {
+				existingJob.Status.Succeeded = 0
+				setRedistribution("immediate", greenplumv1.GreenplumRedistributionPhaseRunning)
+			})
+			It("leaves redistribution running", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(sawCreate).To(BeFalse(), "should not create a job")
+				Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhaseRunning))
+				Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+			})
+			When("gpexpand reports its progress", func() {
+				BeforeEach(func() {
+					podExec.RedistributionProgress = "12|40\n"
+				})
+				It("reports the percentage of tables redistributed", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					greenplumCluster := fetchGreenplumCluster()
+					Expect(greenplumCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseExpanding))
+					Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status":  Equal(metav1.ConditionTrue),
+						"Reason":  Equal("Redistributing"),
+						"Message": Equal("gpexpand has redistributed 12 of 40 tables (30%)"),
+					})))
+				})
+			})
+			When("the progress query fails", func() {
+				BeforeEach(func() {
+					podExec.RedistributionProgressErr = errors.New(`schema "gpexpand" does not exist`)
+				})
+				It("still reports that tables are being redistributed", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					greenplumCluster := fetchGreenplumCluster()
+					Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Reason":  Equal("Redistributing"),
+						"Message": Equal("gpexpand is redistributing tables onto the new segments"),
+					})))
+				})
+			})
+			When("the job succeeds", func() {
+				BeforeEach(func() {
+					existingJob.Status.Succeeded = 1
+				})
+				It("records that redistribution is complete", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhaseComplete))
+				})
+				It("reports that the expansion is complete", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					greenplumCluster := fetchGreenplumCluster()
+					Expect(greenplumCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+					Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status": Equal(metav1.ConditionFalse),
+						"Reason": Equal("ExpansionComplete"),
+					})))
+					Expect(recorder.Events).To(Receive(Equal("Normal ExpansionSucceeded added segments and redistributed tables onto them")))
+				})
+			})
+			When("the job fails", func() {
+				BeforeEach(func() {
+					existingJob.Status.Failed = 1
+				})
+				It("records that redistribution failed", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(sawCreate).To(BeFalse(), "should not create a job")
+					Expect(redistributionStatus()).To(Equal(greenplumv1.GreenplumRedistributionPhaseFailed))
+				})
+				It("reports that the expansion failed", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					greenplumCluster := fetchGreenplumCluster()
+					Expect(greenplumCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+					Expect(meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status": Equal(metav1.ConditionFalse),
+						"Reason": Equal("ExpansionFailed"),
+					})))
+					Expect(recorder.Events).To(Receive(Equal("Warning ExpansionFailed gpexpand job my-greenplum-gpexpand-job failed; check its logs")))
+				})
+			})
+		})
+	})
+
 	When("gpexpand-job exists", func() {
 		var existingJob batchv1.Job
 		var sawCreate bool
 		var sawDelete bool
 		BeforeEach(func() {
-			existingJob = gpexpandjob.GenerateJob(greenplumReconciler.InstanceImage, "master-0", 5)
+			existingJob = gpexpandjob.GenerateJob(greenplumReconciler.InstanceImage, "master-0", 5, false)
 			existingJob.Namespace = firstGreenplumClusterSpec.Namespace
 			existingJob.Name = fmt.Sprintf("%s-gpexpand-job", firstGreenplumClusterSpec.Name)
 		})
@@ -227,10 +568,12 @@ var _ = Describe("Reconcile expansion", func() {
 					}
 					return false, nil, nil
 				})
-				newGreenplumClusterSpec = firstGreenplumClusterSpec.DeepCopy()
-				newGreenplumClusterSpec.Spec.Segments.PrimarySegmentCount = 6
+				newGreenplumClusterSpec = expandedGreenplumCluster()
 
 			})
+			JustBeforeEach(func() {
+				setReadySegmentPods(6)
+			})
 			It("deletes the old job", func() {
 				Expect(reactiveClient.Update(nil, newGreenplumClusterSpec)).To(Succeed())
 				Expect(greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)).To(Equal(ctrl.Result{}))
@@ -292,8 +635,7 @@ var _ = Describe("Reconcile expansion", func() {
 					}
 					return false, nil, nil
 				})
-				newGreenplumClusterSpec = firstGreenplumClusterSpec.DeepCopy()
-				newGreenplumClusterSpec.Spec.Segments.PrimarySegmentCount = 6
+				newGreenplumClusterSpec = expandedGreenplumCluster()
 			})
 			It("does nothing", func() {
 				Expect(reactiveClient.Update(nil, newGreenplumClusterSpec)).To(Succeed())
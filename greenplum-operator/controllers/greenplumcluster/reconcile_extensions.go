@@ -0,0 +1,41 @@
+package greenplumcluster
+
+import (
+	"fmt"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+)
+
+const extensionsQuery = "SELECT extname FROM pg_extension ORDER BY extname"
+
+// handleExtensions creates the extensions in spec.config.extensions that are missing from the gpadmin database,
+// such as those added after the cluster was initialized. Extensions left out of the spec are not dropped.
+func (r *GreenplumClusterReconciler) handleExtensions(greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	if len(greenplumCluster.Spec.Config.Extensions) == 0 {
+		return nil
+	}
+	stdout, err := r.psqlOnDatabase(greenplumCluster.Namespace, activeMaster, "gpadmin", extensionsQuery)
+	if err != nil {
+		return fmt.Errorf("getting current extensions: %w", err)
+	}
+	currentExtensions := map[string]bool{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if extension := strings.TrimSpace(line); extension != "" {
+			currentExtensions[extension] = true
+		}
+	}
+
+	for _, extension := range greenplumCluster.Spec.Config.Extensions {
+		if currentExtensions[extension] {
+			continue
+		}
+		// Extension names are checked against an allowlist by the admission webhook; some, like uuid-ossp, need quoting
+		statement := fmt.Sprintf(`CREATE EXTENSION IF NOT EXISTS "%s"`, extension)
+		if _, err := r.psqlOnDatabase(greenplumCluster.Namespace, activeMaster, "gpadmin", statement); err != nil {
+			return fmt.Errorf("running %s: %w", statement, err)
+		}
+		r.Log.Info("created extension", "extension", extension)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+)
+
+var _ = Describe("Reconcile extensions for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			Extensions: "pgcrypto\nplpgsql\n",
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var reconcileErr error
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	createExtensionCommands := func() []string {
+		var commands []string
+		for _, command := range podExec.RecordedCommands {
+			if strings.Contains(command, "CREATE EXTENSION") {
+				commands = append(commands, command)
+			}
+		}
+		return commands
+	}
+
+	When("no extensions are listed", func() {
+		BeforeEach(func() {
+			podExec.ExtensionsErr = errors.New("pg_extension should not be queried")
+		})
+		It("does not create any extensions", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(createExtensionCommands()).To(BeEmpty())
+		})
+	})
+
+	When("listed extensions are missing from the running cluster", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.Extensions = []string{"uuid-ossp", "pgcrypto", "hstore"}
+		})
+		It("creates each missing extension, in order, in the gpadmin database on the active master", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.CalledPodName).To(Equal("master-0"))
+			Expect(createExtensionCommands()).To(Equal([]string{
+				`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d gpadmin -tAc 'CREATE EXTENSION IF NOT EXISTS "uuid-ossp"'`,
+				`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d gpadmin -tAc 'CREATE EXTENSION IF NOT EXISTS "hstore"'`,
+			}))
+			Expect(logBuf).To(gbytes.Say("created extension"))
+		})
+	})
+
+	When("listed extensions already exist", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.Extensions = []string{"pgcrypto"}
+		})
+		It("does not create them again", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(createExtensionCommands()).To(BeEmpty())
+		})
+	})
+
+	When("querying the current extensions fails", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.Extensions = []string{"hstore"}
+			podExec.ExtensionsErr = errors.New("injected error")
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring(
+				"unable to create extensions: getting current extensions: injected error")))
+		})
+	})
+
+	When("creating an extension fails", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.Extensions = []string{"hstore"}
+			podExec.ErrorMsgOnCommand = "could not open extension control file"
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring(
+				`unable to create extensions: running CREATE EXTENSION IF NOT EXISTS "hstore": could not open extension control file`)))
+		})
+	})
+})
@@ -0,0 +1,115 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// GUCRestartAnnotation is set on the pod template of each statefulset to the time in status.gucRestart.requestedAt,
+	// which rolls its pods
+	GUCRestartAnnotation = "greenplum.pivotal.io/guc-restart-requested-at"
+
+	// How often to check on a rolling restart for GUCs
+	gucRestartPollInterval = 30 * time.Second
+)
+
+// startGUCRestart records in status.gucRestart that the pods must be restarted for the GUCs to take effect
+func (r *GreenplumClusterReconciler) startGUCRestart(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, gucs map[string]string) error {
+	names := make([]string, 0, len(gucs))
+	for name := range gucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return r.startPodRestart(ctx, greenplumCluster, gucs, "RestartingForGUCs",
+		"restarting the pods to apply GUCs: "+strings.Join(names, ", "))
+}
+
+// startPodRestart records in status.gucRestart that the pods must be restarted, which continueGUCRestart then does.
+// gucs is empty when the restart is for something else that the pods only read when they start.
+func (r *GreenplumClusterReconciler) startPodRestart(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, gucs map[string]string, reason, message string) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.GUCRestart = &greenplumv1.GreenplumGUCRestartStatus{
+		GUCs:        gucs,
+		RequestedAt: metav1.Now(),
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("recording pod restart: %w", err)
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, reason, message)
+	return nil
+}
+
+// continueGUCRestart rolls the statefulsets one at a time, mirrors first and the master last, so that at most one
+// statefulset is restarting. Each statefulset is only rolled once the cluster is healthy again. When every statefulset
+// has rolled out, the restart is marked finished.
+func (r *GreenplumClusterReconciler) continueGUCRestart(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (time.Duration, error) {
+	requestedAt := greenplumCluster.Status.GUCRestart.RequestedAt.UTC().Format(time.RFC3339)
+	statefulSetNames := []string{"segment-a", "master"}
+	if greenplumCluster.Spec.Segments.Mirrors == "yes" {
+		statefulSetNames = append([]string{"segment-b"}, statefulSetNames...)
+	}
+	for _, name := range statefulSetNames {
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: name}, &statefulSet); err != nil {
+			return 0, fmt.Errorf("getting statefulset %s: %w", name, err)
+		}
+		if statefulSet.Spec.Template.Annotations[GUCRestartAnnotation] != requestedAt {
+			safe, err := r.safeToRestartSegmentPod(ctx, greenplumCluster)
+			if err != nil {
+				return 0, err
+			}
+			if !safe {
+				return gucRestartPollInterval, nil
+			}
+			originalStatefulSet := statefulSet.DeepCopy()
+			if statefulSet.Spec.Template.Annotations == nil {
+				statefulSet.Spec.Template.Annotations = map[string]string{}
+			}
+			statefulSet.Spec.Template.Annotations[GUCRestartAnnotation] = requestedAt
+			if err := r.Patch(ctx, &statefulSet, client.MergeFrom(originalStatefulSet)); err != nil {
+				return 0, fmt.Errorf("restarting statefulset %s: %w", name, err)
+			}
+			r.Log.Info("restarting pods to apply GUCs", "statefulset", name)
+			return gucRestartPollInterval, nil
+		}
+		if !statefulSetRolledOut(&statefulSet) {
+			return gucRestartPollInterval, nil
+		}
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.GUCRestart.Finished = true
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return 0, fmt.Errorf("recording finished GUC restart: %w", err)
+	}
+	if len(greenplumCluster.Status.GUCRestart.GUCs) == 0 {
+		r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "RestartedPods", "restarted the pods")
+		return 0, nil
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "RestartedForGUCs", "restarted the pods to apply GUCs")
+	return 0, nil
+}
+
+// statefulSetRolledOut reports whether every pod of the statefulset runs its latest template and is ready
+func statefulSetRolledOut(statefulSet *appsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	status := statefulSet.Status
+	return status.ObservedGeneration >= statefulSet.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.ReadyReplicas == replicas &&
+		status.CurrentRevision == status.UpdateRevision
+}
@@ -0,0 +1,186 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+)
+
+// handleGUCs brings the running cluster in line with spec.config.gucs, so that changes made outside the operator,
+// e.g. with gpconfig, are reverted on the next reconcile. GUCs left out of the spec, and init-only GUCs, are not touched.
+// GUCs that take effect on a reload are applied with gpconfig followed by a configuration reload. Changes to
+// configmap.RestartGUCs are written with gpconfig and then applied by a rolling restart of the pods; it returns
+// how long to wait before checking on a restart in progress.
+func (r *GreenplumClusterReconciler) handleGUCs(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	gucs := greenplumCluster.Spec.Config.GUCs
+	names := make([]string, 0, len(gucs))
+	for name := range gucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lastRestart := greenplumCluster.Status.GUCRestart
+	restartInProgress := lastRestart != nil && !lastRestart.Finished
+	restartGUCs := map[string]string{}
+	for _, name := range names {
+		if configmap.InitOnlyGUCs[name] {
+			continue
+		}
+		value := gucs[name]
+		currentValue, err := r.showGUC(greenplumCluster.Namespace, activeMaster, name)
+		if err != nil && strings.Contains(err.Error(), "unrecognized configuration parameter") {
+			// e.g. a GUC of an extension that is not loaded; gpconfig would refuse it as well
+			r.Log.Info("skipping GUC unknown to the server", "name", name)
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("getting current value of %s: %w", name, err)
+		}
+		if sameGUCValue(name, currentValue, value) {
+			continue
+		}
+		if configmap.RestartGUCs[name] {
+			// A value that was already restarted for, or is being restarted for, is not restarted for again;
+			// changes made while a restart is in progress wait for the next one
+			if restartInProgress || (lastRestart != nil && lastRestart.GUCs[name] == value) {
+				continue
+			}
+			if err := r.gpconfigGUC(greenplumCluster.Namespace, activeMaster, name, value, false); err != nil {
+				return 0, fmt.Errorf("setting %s with gpconfig: %w", name, err)
+			}
+			r.Log.Info("wrote GUC with gpconfig; it takes effect after a restart", "name", name, "value", value, "previous value", currentValue)
+			restartGUCs[name] = value
+			continue
+		}
+		if err := r.gpconfigGUC(greenplumCluster.Namespace, activeMaster, name, value, true); err != nil {
+			return 0, fmt.Errorf("setting %s with gpconfig: %w", name, err)
+		}
+		r.Log.Info("applied GUC with gpconfig", "name", name, "value", value, "previous value", currentValue)
+	}
+
+	if restartInProgress {
+		return r.continueGUCRestart(ctx, greenplumCluster)
+	}
+	if len(restartGUCs) == 0 {
+		return 0, nil
+	}
+	if err := r.startGUCRestart(ctx, greenplumCluster, restartGUCs); err != nil {
+		return 0, err
+	}
+	return r.continueGUCRestart(ctx, greenplumCluster)
+}
+
+// SHOW reports time and memory GUCs in the largest unit that divides them exactly, e.g. 1s for 1000ms
+// or 125MB for 128000kB, and numbers without trailing zeros, so values are compared after converting
+// them to milliseconds, kilobytes or floats
+var (
+	timeGUCValue   = regexp.MustCompile(`^([0-9]+)(ms|s|min|h|d)?$`)
+	memoryGUCValue = regexp.MustCompile(`^([0-9]+)(kB|MB|GB|TB)$`)
+)
+
+// secondsGUCs are time GUCs in seconds, rather than milliseconds, when no unit is given
+var secondsGUCs = map[string]bool{
+	"gp_segment_connect_timeout":       true,
+	"gp_interconnect_setup_timeout":    true,
+	"gp_interconnect_transmit_timeout": true,
+	"gp_fts_probe_interval":            true,
+	"gp_fts_probe_timeout":             true,
+}
+
+func sameGUCValue(name, currentValue, value string) bool {
+	return normalizeGUCValue(name, currentValue) == normalizeGUCValue(name, value)
+}
+
+func normalizeGUCValue(name, value string) string {
+	if match := timeGUCValue.FindStringSubmatch(value); match != nil {
+		milliseconds, err := strconv.ParseInt(match[1], 10, 64)
+		if err == nil {
+			switch match[2] {
+			case "":
+				if secondsGUCs[name] {
+					milliseconds *= 1000
+				}
+			case "s":
+				milliseconds *= 1000
+			case "min":
+				milliseconds *= 60 * 1000
+			case "h":
+				milliseconds *= 60 * 60 * 1000
+			case "d":
+				milliseconds *= 24 * 60 * 60 * 1000
+			}
+			return strconv.FormatInt(milliseconds, 10)
+		}
+	}
+	if match := memoryGUCValue.FindStringSubmatch(value); match != nil {
+		kilobytes, err := strconv.ParseInt(match[1], 10, 64)
+		if err == nil {
+			switch match[2] {
+			case "MB":
+				kilobytes *= 1024
+			case "GB":
+				kilobytes *= 1024 * 1024
+			case "TB":
+				kilobytes *= 1024 * 1024 * 1024
+			}
+			return strconv.FormatInt(kilobytes, 10)
+		}
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return value
+}
+
+func (r *GreenplumClusterReconciler) showGUC(namespace, activeMaster, name string) (string, error) {
+	showCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf(`source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc "SHOW %s"`, name),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(showCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return "", fmt.Errorf("%w: %s", err, stderrBuf.String())
+	}
+	return strings.TrimSpace(stdoutBuf.String()), nil
+}
+
+// gpconfigValue quotes value for postgresql.conf, and then for the shell
+func gpconfigValue(value string) string {
+	value = configmap.FormatGUCValue(value)
+	if strings.HasPrefix(value, "'") {
+		value = "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+	return value
+}
+
+// gpconfigGUC sets the GUC on the master and every segment, and reloads the configuration if reload is set.
+// GUC names are validated by the admission webhook; values are quoted for postgresql.conf, and then for the shell.
+func (r *GreenplumClusterReconciler) gpconfigGUC(namespace, activeMaster, name, value string, reload bool) error {
+	command := fmt.Sprintf("source /usr/local/greenplum-db/greenplum_path.sh && gpconfig -c %s -v %s", name, gpconfigValue(value))
+	if reload {
+		command += " && gpstop -u -a"
+	}
+	gpconfigCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		command,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(gpconfigCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("%w: %s", err, stderrBuf.String())
+	}
+	return nil
+}
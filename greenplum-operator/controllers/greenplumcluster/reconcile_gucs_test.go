@@ -0,0 +1,407 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile GUCs for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+			Recorder:   recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	statefulSetAnnotation := func(name string) string {
+		var statefulSet appsv1.StatefulSet
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &statefulSet)).To(Succeed())
+		return statefulSet.Spec.Template.Annotations[greenplumcluster.GUCRestartAnnotation]
+	}
+	createRolledOutStatefulSet := func(name, restartedAt string) {
+		replicas := int32(1)
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: name},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{greenplumcluster.GUCRestartAnnotation: restartedAt},
+					},
+				},
+			},
+			Status: appsv1.StatefulSetStatus{
+				ObservedGeneration: 1,
+				Replicas:           1,
+				ReadyReplicas:      1,
+				UpdatedReplicas:    1,
+				CurrentRevision:    name + "-2",
+				UpdateRevision:     name + "-2",
+			},
+		}
+		Expect(reactiveClient.Create(ctx, statefulSet)).To(Succeed())
+	}
+
+	When("optimizer is not set in the gucs", func() {
+		It("does not query or change it", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("optimizer")))
+		})
+	})
+
+	When("optimizer is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+		})
+
+		When("the running cluster has a different value", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "on\n"
+			})
+			It("applies the value with gpconfig and reloads the configuration", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.CalledPodName).To(Equal("master-0"))
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring(`psql -U gpadmin -d postgres -tAc "SHOW optimizer"`)))
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c optimizer -v off && gpstop -u -a")))
+				Expect(logBuf).To(gbytes.Say("applied GUC with gpconfig"))
+			})
+		})
+
+		When("the running cluster already has the value", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "off\n"
+			})
+			It("does not run gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("SHOW optimizer")))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+			})
+		})
+
+		When("the command fails", func() {
+			BeforeEach(func() {
+				podExec.ErrorMsgOnCommand = "psql: could not connect to server"
+			})
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("unable to apply GUCs: getting current value of optimizer: psql: could not connect to server")))
+			})
+		})
+	})
+
+	When("bgwriter_delay is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"bgwriter_delay": "1000ms"}
+		})
+
+		When("the running cluster has a different value", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "200ms\n"
+			})
+			It("applies the value with gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c bgwriter_delay -v 1000ms && gpstop -u -a")))
+			})
+		})
+
+		When("the running cluster reports the same value in a different unit", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "1s\n"
+			})
+			It("does not run gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("SHOW bgwriter_delay")))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+			})
+		})
+	})
+
+	When("statement_mem is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"statement_mem": "256MB"}
+		})
+
+		When("the running cluster has a different value", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "125MB\n"
+			})
+			It("applies the value with gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring(`psql -U gpadmin -d postgres -tAc "SHOW statement_mem"`)))
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c statement_mem -v 256MB && gpstop -u -a")))
+			})
+		})
+
+		When("the running cluster reports the same value in a different unit", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Config.GUCs = map[string]string{"statement_mem": "262144kB"}
+				podExec.StdoutResult = "256MB\n"
+			})
+			It("does not run gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+			})
+		})
+	})
+
+	When("gp_max_slices and max_statement_mem are set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"gp_max_slices": "50", "max_statement_mem": "4GB"}
+			podExec.StdoutResult = "0\n"
+		})
+		It("applies both with gpconfig", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_max_slices -v 50 && gpstop -u -a")))
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c max_statement_mem -v 4GB && gpstop -u -a")))
+		})
+	})
+
+	When("gp_segment_connect_timeout is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"gp_segment_connect_timeout": "600"}
+		})
+
+		When("the running cluster has a different value", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "3min\n"
+			})
+			It("applies the value with gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_segment_connect_timeout -v 600 && gpstop -u -a")))
+			})
+		})
+
+		When("the running cluster reports the same number of seconds in a different unit", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "10min\n"
+			})
+			It("does not run gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("SHOW gp_segment_connect_timeout")))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+			})
+		})
+	})
+
+	When("the interconnect timeouts are set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{
+				"gp_interconnect_setup_timeout":              "1h",
+				"gp_interconnect_transmit_timeout":           "600s",
+				"gp_interconnect_min_retries_before_timeout": "200",
+			}
+			podExec.StdoutResult = "2h\n"
+		})
+		It("applies them with gpconfig", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_interconnect_setup_timeout -v 1h && gpstop -u -a")))
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_interconnect_transmit_timeout -v 600s && gpstop -u -a")))
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_interconnect_min_retries_before_timeout -v 200 && gpstop -u -a")))
+		})
+	})
+
+	When("the FTS probe settings are set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{
+				"gp_fts_probe_interval": "30",
+				"gp_fts_probe_timeout":  "10s",
+				"gp_fts_probe_retries":  "3",
+			}
+		})
+
+		When("the running cluster has the defaults", func() {
+			BeforeEach(func() {
+				podExec.StdoutResult = "1min\n"
+			})
+			It("applies them with gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_fts_probe_interval -v 30 && gpstop -u -a")))
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_fts_probe_timeout -v 10s && gpstop -u -a")))
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("gpconfig -c gp_fts_probe_retries -v 3 && gpstop -u -a")))
+			})
+		})
+
+		When("the running cluster reports the same number of seconds", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Config.GUCs = map[string]string{"gp_fts_probe_interval": "120"}
+				podExec.StdoutResult = "2min\n"
+			})
+			It("does not run gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("SHOW gp_fts_probe_interval")))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+			})
+		})
+	})
+
+	When("checkpoint_completion_target is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"checkpoint_completion_target": "0.90"}
+			podExec.StdoutResult = "0.9\n"
+		})
+		It("compares the values as numbers", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring("SHOW checkpoint_completion_target")))
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+		})
+	})
+
+	When("an arbitrary GUC is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"search_path": "my_schema, public"}
+			podExec.StdoutResult = "\"$user\", public\n"
+		})
+		It("quotes the value for postgresql.conf and the shell", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).To(ContainElement(ContainSubstring(`gpconfig -c search_path -v ''\''my_schema, public'\''' && gpstop -u -a`)))
+		})
+	})
+
+	When("a GUC is unknown to the server", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"pxf.enable_filter_pushdown": "on"}
+			podExec.ErrorMsgOnCommand = `ERROR:  unrecognized configuration parameter "pxf.enable_filter_pushdown"`
+		})
+		It("skips it", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(logBuf).To(gbytes.Say(`"msg":"skipping GUC unknown to the server","name":"pxf.enable_filter_pushdown"`))
+		})
+	})
+
+	When("an init-only GUC is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"gp_resource_manager": "queue"}
+			podExec.StdoutResult = "group\n"
+		})
+		It("does not query or change it", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gp_resource_manager")))
+		})
+	})
+
+	When("a GUC that requires a restart is set in the gucs", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"max_connections": "500"}
+			greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+			podExec.StdoutResult = "250\n"
+		})
+
+		It("writes it with gpconfig without reloading", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).To(ContainElement(HaveSuffix("gpconfig -c max_connections -v 500")))
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpstop")))
+			Expect(logBuf).To(gbytes.Say("wrote GUC with gpconfig; it takes effect after a restart"))
+		})
+
+		It("records the restart and starts rolling the segments", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			restart := reconciledCluster.Status.GUCRestart
+			Expect(restart).NotTo(BeNil())
+			Expect(restart.GUCs).To(Equal(map[string]string{"max_connections": "500"}))
+			Expect(restart.Finished).To(BeFalse())
+			Expect(recorder.Events).To(Receive(Equal("Normal RestartingForGUCs restarting the pods to apply GUCs: max_connections")))
+			Expect(statefulSetAnnotation("segment-a")).To(Equal(restart.RequestedAt.UTC().Format(time.RFC3339)))
+			Expect(statefulSetAnnotation("master")).To(BeEmpty())
+			Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+		})
+
+		When("the cluster is not healthy", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Segments = &greenplumv1.GreenplumSegmentsStatus{Primaries: 1, PrimariesDown: 1}
+			})
+			It("waits before restarting any pods", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconciledCluster.Status.GUCRestart).NotTo(BeNil())
+				Expect(statefulSetAnnotation("segment-a")).To(BeEmpty())
+				Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+			})
+		})
+
+		When("the value was already restarted for", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.GUCRestart = &greenplumv1.GreenplumGUCRestartStatus{
+					GUCs:        map[string]string{"max_connections": "500"},
+					RequestedAt: metav1.NewTime(time.Now().Add(-time.Hour)),
+					Finished:    true,
+				}
+			})
+			It("does not restart again", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+				Expect(reconciledCluster.Status.GUCRestart.Finished).To(BeTrue())
+				Expect(statefulSetAnnotation("segment-a")).To(BeEmpty())
+			})
+		})
+
+		When("a restart is in progress", func() {
+			var requestedAt metav1.Time
+			BeforeEach(func() {
+				requestedAt = metav1.NewTime(time.Now().Add(-10 * time.Minute).Truncate(time.Second))
+				greenplumCluster.Status.GUCRestart = &greenplumv1.GreenplumGUCRestartStatus{
+					GUCs:        map[string]string{"max_connections": "500"},
+					RequestedAt: requestedAt,
+				}
+				createRolledOutStatefulSet("segment-a", requestedAt.UTC().Format(time.RFC3339))
+			})
+
+			It("restarts the master once the segments have rolled out", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+				Expect(statefulSetAnnotation("master")).To(Equal(requestedAt.UTC().Format(time.RFC3339)))
+				Expect(reconciledCluster.Status.GUCRestart.Finished).To(BeFalse())
+			})
+
+			When("every statefulset has rolled out", func() {
+				BeforeEach(func() {
+					createRolledOutStatefulSet("master", requestedAt.UTC().Format(time.RFC3339))
+					podExec.StdoutResult = "500\n"
+				})
+				It("marks the restart finished", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(reconciledCluster.Status.GUCRestart.Finished).To(BeTrue())
+					Expect(recorder.Events).To(Receive(Equal("Normal RestartedForGUCs restarted the pods to apply GUCs")))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/interconnecttls"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleInterconnectTLS keeps the interconnect CA and a certificate for every pod in their secrets, reissuing them
+// when they are due for renewal. It returns how long to wait before the next certificate is due.
+func (r *GreenplumClusterReconciler) handleInterconnectTLS(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (time.Duration, error) {
+	spec := greenplumCluster.Spec.InterconnectTLS
+	if spec == nil {
+		return 0, nil
+	}
+	now := time.Now()
+	labels := map[string]string{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": greenplumCluster.Name,
+	}
+
+	caRenewed := false
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: greenplumCluster.Namespace,
+			Name:      interconnecttls.CASecretName,
+		},
+	}
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, caSecret, func() error {
+		if caSecret.Data == nil {
+			caSecret.Data = map[string][]byte{}
+		}
+		if interconnecttls.NeedsRenewal(caSecret.Data[interconnecttls.CACertKey], now) {
+			certPEM, keyPEM, err := interconnecttls.GenerateCA(greenplumCluster.Name, now, interconnecttls.CAValidity(*spec))
+			if err != nil {
+				return err
+			}
+			// Pods keep trusting the previous CA until they have all restarted with certificates from the new one
+			if previous, ok := caSecret.Data[interconnecttls.CACertKey]; ok {
+				caSecret.Data[interconnecttls.PreviousCACertKey] = previous
+			}
+			caSecret.Data[interconnecttls.CACertKey] = certPEM
+			caSecret.Data[interconnecttls.CAPrivateKeyKey] = keyPEM
+			caRenewed = true
+		}
+		caSecret.Labels = labels
+		caSecret.Type = corev1.SecretTypeOpaque
+		return ctrl.SetControllerReference(greenplumCluster, caSecret, r.Scheme())
+	})
+	if err != nil {
+		return 0, fmt.Errorf("updating interconnect CA secret: %w", err)
+	}
+	r.logReconcileResult(operationResult, caSecret)
+
+	nextRenewal, err := interconnecttls.RenewalTime(caSecret.Data[interconnecttls.CACertKey])
+	if err != nil {
+		return 0, fmt.Errorf("reading interconnect CA certificate: %w", err)
+	}
+
+	reissued := 0
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: greenplumCluster.Namespace,
+			Name:      interconnecttls.SecretName,
+		},
+	}
+	operationResult, err = ctrl.CreateOrUpdate(ctx, r, secret, func() error {
+		caBundle := append([]byte{}, caSecret.Data[interconnecttls.CACertKey]...)
+		caBundle = append(caBundle, caSecret.Data[interconnecttls.PreviousCACertKey]...)
+		data := map[string][]byte{
+			interconnecttls.CACertKey: caBundle,
+		}
+		for _, podName := range interconnecttls.PodNames(greenplumCluster) {
+			certKey, keyKey := interconnecttls.CertificateKey(podName), interconnecttls.PrivateKeyKey(podName)
+			certPEM, keyPEM := secret.Data[certKey], secret.Data[keyKey]
+			if caRenewed || interconnecttls.NeedsRenewal(certPEM, now) {
+				certPEM, keyPEM, err = interconnecttls.GenerateCertificate(
+					caSecret.Data[interconnecttls.CACertKey], caSecret.Data[interconnecttls.CAPrivateKeyKey],
+					podName, greenplumCluster.Namespace, now, interconnecttls.CertificateValidity(*spec))
+				if err != nil {
+					return fmt.Errorf("generating certificate for %s: %w", podName, err)
+				}
+				reissued++
+			}
+			data[certKey], data[keyKey] = certPEM, keyPEM
+			if renewAt, err := interconnecttls.RenewalTime(certPEM); err == nil && renewAt.Before(nextRenewal) {
+				nextRenewal = renewAt
+			}
+		}
+		secret.Data = data
+		if reissued > 0 {
+			if secret.Annotations == nil {
+				secret.Annotations = map[string]string{}
+			}
+			secret.Annotations[interconnecttls.IssuedAtAnnotation] = now.UTC().Format(time.RFC3339)
+		}
+		secret.Labels = labels
+		secret.Type = corev1.SecretTypeOpaque
+		return ctrl.SetControllerReference(greenplumCluster, secret, r.Scheme())
+	})
+	if err != nil {
+		return 0, fmt.Errorf("updating interconnect TLS secret: %w", err)
+	}
+	r.logReconcileResult(operationResult, secret)
+	if reissued > 0 {
+		r.Log.Info("issued interconnect certificates; pods use them once they restart", "certificates", reissued)
+	}
+
+	if greenplumCluster.Status.Phase == greenplumv1.GreenplumClusterPhaseRunning {
+		if err := r.restartForInterconnectCertificates(ctx, greenplumCluster, secret); err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Until(nextRenewal), nil
+}
+
+// restartForInterconnectCertificates starts a rolling restart of the pods when any of them started before the
+// certificates in secret were issued, since the container only installs them on startup. A restart already in progress
+// is left to finish first; the pods it restarted too early are restarted again afterwards.
+func (r *GreenplumClusterReconciler) restartForInterconnectCertificates(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, secret *corev1.Secret) error {
+	if restart := greenplumCluster.Status.GUCRestart; restart != nil && !restart.Finished {
+		return nil
+	}
+	issuedAt, err := time.Parse(time.RFC3339, secret.Annotations[interconnecttls.IssuedAtAnnotation])
+	if err != nil {
+		return nil
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(greenplumCluster.Namespace),
+		client.MatchingLabels{"greenplum-cluster": greenplumCluster.Name}); err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.StartTime != nil && pod.Status.StartTime.Time.Before(issuedAt) {
+			return r.startPodRestart(ctx, greenplumCluster, map[string]string{}, "RestartingForInterconnectTLS",
+				"restarting the pods to use the interconnect certificates issued at "+issuedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/interconnecttls"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile interconnect TLS for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		caSecretKey         types.NamespacedName
+		secretKey           types.NamespacedName
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		recorder = record.NewFakeRecorder(10)
+
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    &fake.PodExec{},
+			Recorder:   recorder,
+		}
+
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		caSecretKey = types.NamespacedName{Namespace: namespaceName, Name: interconnecttls.CASecretName}
+		secretKey = types.NamespacedName{Namespace: namespaceName, Name: interconnecttls.SecretName}
+	})
+
+	var (
+		reconcileResult ctrl.Result
+		reconcileErr    error
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	getSecret := func(key types.NamespacedName) *corev1.Secret {
+		var secret corev1.Secret
+		Expect(reactiveClient.Get(ctx, key, &secret)).To(Succeed())
+		return &secret
+	}
+	parseCertificate := func(certPEM []byte) *x509.Certificate {
+		block, _ := pem.Decode(certPEM)
+		Expect(block).NotTo(BeNil())
+		cert, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).NotTo(HaveOccurred())
+		return cert
+	}
+	expectSignedBy := func(certPEM, caCertPEM []byte) {
+		roots := x509.NewCertPool()
+		Expect(roots.AppendCertsFromPEM(caCertPEM)).To(BeTrue())
+		_, err := parseCertificate(certPEM).Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	When("interconnectTLS is not set", func() {
+		It("does not manage certificates", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, caSecretKey, &corev1.Secret{}))).To(BeTrue(), "expected CA secret to not exist")
+			Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, secretKey, &corev1.Secret{}))).To(BeTrue(), "expected TLS secret to not exist")
+		})
+	})
+
+	When("interconnectTLS is set", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{
+				CertificateValidity: &metav1.Duration{Duration: 90 * time.Hour},
+			}
+		})
+
+		When("the secrets do not exist", func() {
+			It("generates a CA", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				caSecret := getSecret(caSecretKey)
+				Expect(caSecret.Data).To(HaveKey("ca.crt"))
+				Expect(caSecret.Data).To(HaveKey("ca.key"))
+				caCert := parseCertificate(caSecret.Data["ca.crt"])
+				Expect(caCert.IsCA).To(BeTrue())
+				Expect(caCert.NotAfter).To(BeTemporally("~", time.Now().Add(900*time.Hour), time.Minute))
+				Expect(caSecret.OwnerReferences).To(HaveLen(1))
+				Expect(caSecret.OwnerReferences[0].Name).To(Equal(clusterName))
+			})
+			It("generates a certificate for every pod, signed by the CA", func() {
+				caCertPEM := getSecret(caSecretKey).Data["ca.crt"]
+				secret := getSecret(secretKey)
+				Expect(secret.Data).To(HaveLen(5))
+				Expect(secret.Data["ca.crt"]).To(Equal(caCertPEM))
+				Expect(secret.Data).To(HaveKey("master-0.key"))
+				Expect(secret.Data).To(HaveKey("segment-a-0.key"))
+				for _, podName := range []string{"master-0", "segment-a-0"} {
+					expectSignedBy(secret.Data[podName+".crt"], caCertPEM)
+					Expect(parseCertificate(secret.Data[podName+".crt"]).NotAfter).To(BeTemporally("~", time.Now().Add(90*time.Hour), time.Minute))
+				}
+				Expect(secret.OwnerReferences).To(HaveLen(1))
+				Expect(secret.OwnerReferences[0].Name).To(Equal(clusterName))
+				Expect(logBuf).To(gbytes.Say(`"issued interconnect certificates; pods use them once they restart".*"certificates":2`))
+			})
+			It("mounts the certificates into the pods", func() {
+				for _, name := range []string{"master", "segment-a"} {
+					var statefulSet appsv1.StatefulSet
+					Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &statefulSet)).To(Succeed())
+					Expect(statefulSet.Spec.Template.Spec.Volumes).To(ContainElement(interconnecttls.Volume()))
+					Expect(statefulSet.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(interconnecttls.VolumeMount()))
+				}
+			})
+			It("requeues when the certificates are due for renewal", func() {
+				Expect(reconcileResult.RequeueAfter).To(BeNumerically("~", 60*time.Hour, time.Minute))
+			})
+		})
+
+		When("pods of a running cluster started before the certificates were issued", func() {
+			var podStartTime time.Time
+			BeforeEach(func() {
+				greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+				podStartTime = time.Now().Add(-time.Hour)
+			})
+			JustBeforeEach(func() {
+				// The pod is only listed on the next reconcile, as if it was running while the certificates were issued
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: namespaceName,
+						Name:      "segment-a-0",
+						Labels:    map[string]string{"greenplum-cluster": clusterName},
+					},
+					Status: corev1.PodStatus{StartTime: &metav1.Time{Time: podStartTime}},
+				}
+				Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+				reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+			})
+			It("restarts the pods so that they install the new certificates", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var reconciledCluster greenplumv1.GreenplumCluster
+				Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+				Expect(reconciledCluster.Status.GUCRestart).NotTo(BeNil())
+				Expect(reconciledCluster.Status.GUCRestart.GUCs).To(BeEmpty())
+				Expect(recorder.Events).To(Receive(HavePrefix("Normal RestartingForInterconnectTLS restarting the pods to use the interconnect certificates issued at ")))
+			})
+			When("the pods started after the certificates were issued", func() {
+				BeforeEach(func() {
+					podStartTime = time.Now().Add(time.Minute)
+				})
+				It("does not restart them", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var reconciledCluster greenplumv1.GreenplumCluster
+					Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+					Expect(reconciledCluster.Status.GUCRestart).To(BeNil())
+				})
+			})
+		})
+
+		When("the cluster has a standby and mirrors", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.MasterAndStandby.Standby = "yes"
+				greenplumCluster.Spec.Segments.Mirrors = "yes"
+			})
+			It("generates certificates for them too", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				secret := getSecret(secretKey)
+				Expect(secret.Data).To(HaveKey("master-1.crt"))
+				Expect(secret.Data).To(HaveKey("segment-b-0.crt"))
+			})
+		})
+
+		When("the certificates exist", func() {
+			var (
+				caCertPEM, caKeyPEM []byte
+				caIssuedAt          time.Time
+				certsIssuedAt       time.Time
+				certPEM, keyPEM     []byte
+			)
+			BeforeEach(func() {
+				caIssuedAt = time.Now().Add(-100 * time.Hour)
+				certsIssuedAt = time.Now().Add(-30 * time.Hour)
+			})
+			JustBeforeEach(func() {
+				// Reconcile again with secrets issued in the past
+				var err error
+				caCertPEM, caKeyPEM, err = interconnecttls.GenerateCA(clusterName, caIssuedAt, 900*time.Hour)
+				Expect(err).NotTo(HaveOccurred())
+				certPEM, keyPEM, err = interconnecttls.GenerateCertificate(caCertPEM, caKeyPEM, "master-0", namespaceName, certsIssuedAt, 90*time.Hour)
+				Expect(err).NotTo(HaveOccurred())
+
+				caSecret := getSecret(caSecretKey)
+				caSecret.Data = map[string][]byte{"ca.crt": caCertPEM, "ca.key": caKeyPEM}
+				Expect(reactiveClient.Update(ctx, caSecret)).To(Succeed())
+				secret := getSecret(secretKey)
+				secret.Data = map[string][]byte{
+					"ca.crt":          caCertPEM,
+					"master-0.crt":    certPEM,
+					"master-0.key":    keyPEM,
+					"segment-a-0.crt": certPEM,
+					"segment-a-0.key": keyPEM,
+				}
+				Expect(reactiveClient.Update(ctx, secret)).To(Succeed())
+
+				reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+			})
+
+			When("they are not due for renewal", func() {
+				It("keeps them", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(getSecret(caSecretKey).Data["ca.crt"]).To(Equal(caCertPEM))
+					Expect(getSecret(secretKey).Data["master-0.crt"]).To(Equal(certPEM))
+				})
+				It("requeues when the first certificate is due for renewal", func() {
+					Expect(reconcileResult.RequeueAfter).To(BeNumerically("~", 30*time.Hour, time.Minute))
+				})
+			})
+
+			When("a third of their validity is left", func() {
+				BeforeEach(func() {
+					certsIssuedAt = time.Now().Add(-70 * time.Hour)
+				})
+				It("reissues them with the same CA", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(getSecret(caSecretKey).Data["ca.crt"]).To(Equal(caCertPEM))
+					secret := getSecret(secretKey)
+					Expect(secret.Data["master-0.crt"]).NotTo(Equal(certPEM))
+					expectSignedBy(secret.Data["master-0.crt"], caCertPEM)
+					Expect(parseCertificate(secret.Data["master-0.crt"]).NotAfter).To(BeTemporally("~", time.Now().Add(90*time.Hour), time.Minute))
+				})
+			})
+
+			When("the CA is due for renewal", func() {
+				BeforeEach(func() {
+					caIssuedAt = time.Now().Add(-700 * time.Hour)
+				})
+				It("generates a new CA and keeps trusting the previous one", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					caSecret := getSecret(caSecretKey)
+					Expect(caSecret.Data["ca.crt"]).NotTo(Equal(caCertPEM))
+					Expect(caSecret.Data["previous-ca.crt"]).To(Equal(caCertPEM))
+					Expect(getSecret(secretKey).Data["ca.crt"]).To(Equal(append(caSecret.Data["ca.crt"], caCertPEM...)))
+				})
+				It("reissues every certificate with the new CA", func() {
+					newCACertPEM := getSecret(caSecretKey).Data["ca.crt"]
+					secret := getSecret(secretKey)
+					for _, podName := range []string{"master-0", "segment-a-0"} {
+						Expect(secret.Data[podName+".crt"]).NotTo(Equal(certPEM))
+						expectSignedBy(secret.Data[podName+".crt"], newCACertPEM)
+					}
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,146 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/ldapauth"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often to probe the LDAP server from the active master
+const ldapProbeInterval = 5 * time.Minute
+
+// handleLDAP creates the roles in spec.auth.ldap that do not exist yet, if createMissingRoles is set, and probes the
+// LDAP server from the active master, reporting the result in the LDAPUnreachable condition. The pg_hba.conf
+// records are written by handlePgHbaEntries.
+func (r *GreenplumClusterReconciler) handleLDAP(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	if greenplumCluster.Spec.Auth == nil || greenplumCluster.Spec.Auth.LDAP == nil {
+		return 0, r.removeLDAPCondition(ctx, greenplumCluster)
+	}
+	ldapSpec := greenplumCluster.Spec.Auth.LDAP
+
+	if ldapSpec.CreateMissingRoles {
+		if err := r.createMissingLDAPRoles(greenplumCluster.Namespace, activeMaster, ldapSpec.Roles); err != nil {
+			return 0, err
+		}
+	}
+
+	host, port, err := ldapauth.ParseServerURL(ldapSpec.ServerURL)
+	if err != nil {
+		return 0, err
+	}
+	probeErr := r.probeLDAP(greenplumCluster.Namespace, activeMaster, host, port)
+	if probeErr != nil {
+		r.Log.Info("LDAP server is unreachable from the master", "pod", activeMaster, "serverURL", ldapSpec.ServerURL, "reason", probeErr.Error())
+	}
+	if err := r.setLDAPCondition(ctx, greenplumCluster, activeMaster, probeErr); err != nil {
+		return 0, err
+	}
+	return ldapProbeInterval, nil
+}
+
+// ldapPgHbaEntries returns the pg_hba.conf records for spec.auth.ldap, if any
+func (r *GreenplumClusterReconciler) ldapPgHbaEntries(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) ([]string, error) {
+	if greenplumCluster.Spec.Auth == nil || greenplumCluster.Spec.Auth.LDAP == nil {
+		return nil, nil
+	}
+	ldapSpec := *greenplumCluster.Spec.Auth.LDAP
+	bindPassword, err := ldapauth.GetBindPassword(ctx, r, greenplumCluster.Namespace, ldapSpec)
+	if err != nil {
+		return nil, fmt.Errorf("getting LDAP searchBindPassword: %w", err)
+	}
+	return ldapauth.PgHbaEntries(ldapSpec, bindPassword), nil
+}
+
+func (r *GreenplumClusterReconciler) createMissingLDAPRoles(namespace, activeMaster string, roles []string) error {
+	stdout, err := r.psqlOnMaster(namespace, activeMaster, roleConnectionLimitsQuery)
+	if err != nil {
+		return fmt.Errorf("getting current roles: %w", err)
+	}
+	existingRoles, err := parseRoleConnectionLimits(stdout)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if _, ok := existingRoles[role]; ok {
+			continue
+		}
+		// Role names are validated by the admission webhook, so quoting them is enough. The role has no password;
+		// it can only log in through LDAP
+		statement := fmt.Sprintf(`CREATE ROLE "%s" LOGIN`, role)
+		if _, err := r.psqlOnMaster(namespace, activeMaster, statement); err != nil {
+			return fmt.Errorf("running %s: %w", statement, err)
+		}
+		r.Log.Info("created role for LDAP authentication", "role", role)
+	}
+	return nil
+}
+
+// probeLDAP checks that the active master can open a TCP connection to the LDAP server
+func (r *GreenplumClusterReconciler) probeLDAP(namespace, activeMaster, host string, port int) error {
+	probeCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf("timeout 5 bash -c '</dev/tcp/%s/%d'", host, port),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(probeCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		if stderr := strings.TrimSpace(stderrBuf.String()); stderr != "" {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) setLDAPCondition(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, probeErr error) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	wasUnreachable := meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionLDAPUnreachable)
+	serverURL := greenplumCluster.Spec.Auth.LDAP.ServerURL
+	condition := metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionLDAPUnreachable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             "Reachable",
+		Message:            fmt.Sprintf("%s can connect to %s", activeMaster, serverURL),
+	}
+	if probeErr != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ProbeFailed"
+		condition.Message = fmt.Sprintf("%s cannot connect to %s: %s", activeMaster, serverURL, probeErr.Error())
+	}
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, condition)
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating LDAP condition: %w", err)
+	}
+	if condition.Status == metav1.ConditionTrue && !wasUnreachable {
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "LDAPUnreachable", condition.Message)
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) removeLDAPCondition(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionLDAPUnreachable) == nil {
+		return nil
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	meta.RemoveStatusCondition(&greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionLDAPUnreachable)
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating LDAP condition: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/pghba"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile LDAP authentication for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+			Recorder:   recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	commandsContaining := func(substr string) []string {
+		var commands []string
+		for _, command := range podExec.RecordedCommands {
+			if strings.Contains(command, substr) {
+				commands = append(commands, command)
+			}
+		}
+		return commands
+	}
+	ldapCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionLDAPUnreachable)
+	}
+
+	When("auth.ldap is not set", func() {
+		It("does not probe an LDAP server", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(commandsContaining("/dev/tcp/")).To(BeEmpty())
+			Expect(ldapCondition()).To(BeNil())
+		})
+
+		When("LDAP was reported before", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:               greenplumv1.GreenplumClusterConditionLDAPUnreachable,
+					Status:             metav1.ConditionTrue,
+					Reason:             "ProbeFailed",
+					Message:            "master-0 cannot connect to ldap://ldap.example.com",
+					LastTransitionTime: metav1.Now(),
+				}}
+			})
+			It("removes the condition", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(ldapCondition()).To(BeNil())
+			})
+		})
+	})
+
+	When("auth.ldap is set", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Auth = &greenplumv1.GreenplumAuthSpec{LDAP: &greenplumv1.GreenplumLDAPSpec{
+				ServerURL:      "ldap://ldap.example.com",
+				Mode:           "simpleBind",
+				BindDNTemplate: "uid=$username,ou=people,dc=example,dc=com",
+				Roles:          []string{"analyst", "etl_user"},
+			}}
+		})
+
+		It("adds ldap records to pg_hba.conf", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(commandsContaining(pghba.Path)).To(ContainElement(ContainSubstring(
+				`'host all analyst,etl_user 0.0.0.0/0 ldap ldapserver=ldap.example.com ldapport=389 ldapprefix="uid=" ldapsuffix=",ou=people,dc=example,dc=com"'`)))
+			Expect(reconciledCluster.Status.PgHbaEntriesHash).NotTo(BeEmpty())
+		})
+
+		It("probes the LDAP server from the active master", func() {
+			Expect(commandsContaining("/dev/tcp/")).To(Equal([]string{
+				"/bin/bash -c -- timeout 5 bash -c '</dev/tcp/ldap.example.com/389'",
+			}))
+			Expect(podExec.CalledPodName).To(Equal("master-0"))
+		})
+
+		It("reports the server as reachable and requeues to probe it again", func() {
+			condition := ldapCondition()
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("Reachable"))
+			Expect(condition.Message).To(Equal("master-0 can connect to ldap://ldap.example.com"))
+			Expect(reconcileResult.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(reconcileResult.RequeueAfter).To(BeNumerically("<=", 5*time.Minute))
+		})
+
+		It("does not create roles", func() {
+			Expect(commandsContaining("CREATE ROLE")).To(BeEmpty())
+		})
+
+		When("createMissingRoles is set", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Auth.LDAP.CreateMissingRoles = true
+				podExec.RoleConnectionLimits = "analyst|-1\ngpadmin|-1\n"
+			})
+			It("creates the roles that do not exist", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(commandsContaining("CREATE ROLE")).To(Equal([]string{
+					`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc 'CREATE ROLE "etl_user" LOGIN'`,
+				}))
+				Expect(logBuf).To(gbytes.Say(`"msg":"created role for LDAP authentication","role":"etl_user"`))
+			})
+		})
+
+		When("the LDAP server is unreachable", func() {
+			BeforeEach(func() {
+				podExec.LDAPProbeErr = errors.New("command terminated with exit code 124")
+			})
+			It("sets the condition and emits a Warning event", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				condition := ldapCondition()
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				Expect(condition.Reason).To(Equal("ProbeFailed"))
+				Expect(condition.Message).To(Equal("master-0 cannot connect to ldap://ldap.example.com: command terminated with exit code 124"))
+				Expect(recorder.Events).To(Receive(Equal("Warning LDAPUnreachable " + condition.Message)))
+			})
+
+			When("the condition was already reported", func() {
+				BeforeEach(func() {
+					greenplumCluster.Status.Conditions = []metav1.Condition{{
+						Type:               greenplumv1.GreenplumClusterConditionLDAPUnreachable,
+						Status:             metav1.ConditionTrue,
+						Reason:             "ProbeFailed",
+						Message:            "master-0 cannot connect to ldap://ldap.example.com: command terminated with exit code 124",
+						LastTransitionTime: metav1.Now(),
+					}}
+				})
+				It("does not emit another event", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(recorder.Events).NotTo(Receive())
+				})
+			})
+		})
+
+		When("searchBind uses a password from a Secret", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Auth.LDAP = &greenplumv1.GreenplumLDAPSpec{
+					ServerURL:          "ldap://ldap.example.com:3389",
+					Mode:               "searchBind",
+					BaseDN:             "ou=people,dc=example,dc=com",
+					SearchBindDN:       "cn=greenplum,dc=example,dc=com",
+					SearchBindPassword: &greenplumv1.GreenplumLDAPBindPasswordSpec{SecretName: "ldap-bind"},
+				}
+			})
+
+			When("the Secret exists", func() {
+				BeforeEach(func() {
+					Expect(reactiveClient.Create(ctx, &corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "ldap-bind"},
+						Data:       map[string][]byte{"password": []byte("s3cret")},
+					})).To(Succeed())
+				})
+				It("writes the password to pg_hba.conf and keeps gpadmin on md5", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					commands := commandsContaining(pghba.Path)
+					Expect(commands).To(ContainElement(ContainSubstring(`'host all gpadmin 0.0.0.0/0 md5' 'host all gpadmin ::/0 md5'`)))
+					Expect(commands).To(ContainElement(ContainSubstring(`ldapbinddn="cn=greenplum,dc=example,dc=com" ldapbindpasswd="s3cret"`)))
+				})
+			})
+
+			When("the Secret does not exist", func() {
+				It("returns an error", func() {
+					Expect(reconcileErr).To(MatchError(`unable to apply pg_hba entries: getting LDAP searchBindPassword: Secret "ldap-bind" not found`))
+				})
+			})
+		})
+
+		When("pgHbaEntries are also listed", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Config.PgHbaEntries = []string{"host all app_user 10.0.0.0/16 md5"}
+			})
+			It("puts the ldap records first", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				commands := commandsContaining(pghba.Path)
+				Expect(commands).NotTo(BeEmpty())
+				write := commands[len(commands)-1]
+				Expect(strings.Index(write, "ldapserver=")).To(BeNumerically("<", strings.Index(write, "app_user")))
+			})
+		})
+	})
+})
@@ -0,0 +1,243 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How long the active master pod may be not ready before autoFailover promotes the standby, when
+// spec.masterAndStandby.failoverTimeoutSeconds is unset
+const defaultFailoverTimeout = 120 * time.Second
+
+// handleMasterFailover promotes the standby with gpactivatestandby when autoFailover is enabled, no master
+// is answering, and the active master pod has not been ready for failoverTimeoutSeconds. The old master is
+// fenced first: it is recorded in status and in the greenplum-config configmap, which the instance reads when
+// its container starts, and then its pod is deleted. The pod that replaces it does not start Greenplum, even
+// when it is master-1, until it has been reinitialized as the standby.
+// When promoting the standby fails after the old master has been fenced, the next reconcile retries without
+// waiting for the timeout again.
+func (r *GreenplumClusterReconciler) handleMasterFailover(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	if !greenplumCluster.Spec.MasterAndStandby.AutoFailover || greenplumCluster.Spec.MasterAndStandby.Standby != "yes" {
+		return nil
+	}
+	// a cluster that has never been running has nothing to fail over
+	if greenplumCluster.Status.Phase == "" || greenplumCluster.Status.Phase == greenplumv1.GreenplumClusterPhasePending {
+		return nil
+	}
+
+	oldMaster := activeMasterPod(greenplumCluster)
+	standby := otherMasterPod(oldMaster)
+	fenced := greenplumCluster.Status.FencedMaster == oldMaster
+	var oldMasterPod corev1.Pod
+	var notReadyFor time.Duration
+	if !fenced {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: oldMaster}, &oldMasterPod); err != nil {
+			if apierrs.IsNotFound(err) {
+				// the statefulset is replacing it
+				return nil
+			}
+			return err
+		}
+		notReadySince, ready := podNotReadySince(&oldMasterPod)
+		if ready {
+			return nil
+		}
+		timeout := defaultFailoverTimeout
+		if greenplumCluster.Spec.MasterAndStandby.FailoverTimeoutSeconds > 0 {
+			timeout = time.Duration(greenplumCluster.Spec.MasterAndStandby.FailoverTimeoutSeconds) * time.Second
+		}
+		notReadyFor = time.Since(notReadySince)
+		if notReadyFor < timeout {
+			r.Log.Info("active master pod is not ready", "pod", oldMaster, "notReadyFor", notReadyFor.Truncate(time.Second).String())
+			return nil
+		}
+	}
+
+	if greenplumCluster.Status.FencedMaster == standby {
+		return r.setMasterFailedOverCondition(ctx, greenplumCluster, metav1.ConditionFalse, "StandbyFenced",
+			fmt.Sprintf("%s is not ready, but %s was fenced by the last failover and has not been reinitialized as the standby", oldMaster, standby))
+	}
+	var standbyPod corev1.Pod
+	if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: standby}, &standbyPod); err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	if _, standbyReady := podNotReadySince(&standbyPod); !standbyReady {
+		return r.setMasterFailedOverCondition(ctx, greenplumCluster, metav1.ConditionFalse, "StandbyNotReady",
+			fmt.Sprintf("%s is not ready, but the standby %s is not ready to take over", oldMaster, standby))
+	}
+
+	if !fenced {
+		if err := r.fenceMaster(ctx, greenplumCluster, &oldMasterPod, notReadyFor); err != nil {
+			return err
+		}
+	}
+
+	activateStandbyCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && gpactivatestandby -a -d /greenplum/data-1",
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(activateStandbyCommand, greenplumCluster.Namespace, standby, stdoutBuf, stderrBuf); err != nil {
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "MasterFailoverFailed", fmt.Sprintf("gpactivatestandby failed on %s: %s", standby, err))
+		return fmt.Errorf("promoting %s: %w: %s", standby, err, stderrBuf.String())
+	}
+
+	message := fmt.Sprintf("promoted %s after fencing %s; annotate the GreenplumCluster with %s to make %s the new standby",
+		standby, oldMaster, greenplumv1.ReinitializeStandbyAnnotation, oldMaster)
+	r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "MasterFailedOver", message)
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.ActiveMaster = standby
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionMasterFailedOver,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             "StandbyPromoted",
+		Message:            message,
+	})
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating failover status: %w", err)
+	}
+	return nil
+}
+
+// fenceMaster keeps the old master from starting Greenplum again before its pod is deleted. Deleting the pod alone
+// does not fence it: master-1 runs pg_ctl restart on its data directory whenever its container starts.
+func (r *GreenplumClusterReconciler) fenceMaster(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, oldMasterPod *corev1.Pod, notReadyFor time.Duration) error {
+	r.Log.Info("fencing the active master before promoting the standby", "pod", oldMasterPod.Name)
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.FencedMaster = oldMasterPod.Name
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("fencing %s: %w", oldMasterPod.Name, err)
+	}
+	if err := r.createOrUpdateConfigMap(ctx, *greenplumCluster); err != nil {
+		return fmt.Errorf("fencing %s: %w", oldMasterPod.Name, err)
+	}
+	if err := r.Delete(ctx, oldMasterPod, client.GracePeriodSeconds(0)); err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("fencing %s: %w", oldMasterPod.Name, err)
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "MasterFenced",
+		fmt.Sprintf("fenced and deleted %s, which has not been ready for %s", oldMasterPod.Name, notReadyFor.Truncate(time.Second)))
+	return nil
+}
+
+// handleStandbyReinitialization makes the master pod fenced by a failover the new standby with gpinitstandby,
+// once the GreenplumCluster has the reinitialize-standby annotation and the pod is ready again. The stale data
+// directory on the old master is removed first, since gpinitstandby copies the active master's. The fence is
+// lifted once the pod is the standby.
+func (r *GreenplumClusterReconciler) handleStandbyReinitialization(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	if _, requested := greenplumCluster.Annotations[greenplumv1.ReinitializeStandbyAnnotation]; !requested {
+		return nil
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if !meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMasterFailedOver) {
+		r.Log.Info("no master has been fenced by a failover; not reinitializing the standby")
+		delete(greenplumCluster.Annotations, greenplumv1.ReinitializeStandbyAnnotation)
+		return r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster))
+	}
+
+	oldMaster := otherMasterPod(activeMaster)
+	var oldMasterPod corev1.Pod
+	if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: oldMaster}, &oldMasterPod); err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	if _, ready := podNotReadySince(&oldMasterPod); !ready {
+		r.Log.Info("waiting for the old master pod to be ready before reinitializing it as the standby", "pod", oldMaster)
+		return nil
+	}
+
+	removeDataCommand := []string{"/bin/bash", "-c", "--", "rm -rf /greenplum/data-1"}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(removeDataCommand, greenplumCluster.Namespace, oldMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("removing the stale data directory on %s: %w: %s", oldMaster, err, stderrBuf.String())
+	}
+	oldMasterFQDN := fmt.Sprintf("%s.agent.%s.svc.cluster.local", oldMaster, greenplumCluster.Namespace)
+	initStandbyCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && gpinitstandby -a -s " + oldMasterFQDN,
+	}
+	stdoutBuf.Reset()
+	stderrBuf.Reset()
+	if err := r.PodExec.Execute(initStandbyCommand, greenplumCluster.Namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("initializing %s as the standby: %w: %s", oldMaster, err, stderrBuf.String())
+	}
+
+	message := fmt.Sprintf("reinitialized %s as the standby of %s", oldMaster, activeMaster)
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "StandbyReinitialized", message)
+	delete(greenplumCluster.Annotations, greenplumv1.ReinitializeStandbyAnnotation)
+	greenplumCluster.Status.FencedMaster = ""
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionMasterFailedOver,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             "StandbyReinitialized",
+		Message:            message,
+	})
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating failover status: %w", err)
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) setMasterFailedOverCondition(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, status metav1.ConditionStatus, reason, message string) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionMasterFailedOver,
+		Status:             status,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating failover status: %w", err)
+	}
+	return nil
+}
+
+// activeMasterPod is the master pod that the greenplum service selects
+func activeMasterPod(greenplumCluster *greenplumv1.GreenplumCluster) string {
+	if greenplumCluster.Status.ActiveMaster != "" {
+		return greenplumCluster.Status.ActiveMaster
+	}
+	return "master-0"
+}
+
+func otherMasterPod(master string) string {
+	if master == "master-1" {
+		return "master-0"
+	}
+	return "master-1"
+}
+
+// podNotReadySince reports whether the pod is ready, and if not, since when. A pod that has not
+// reported readiness yet has been not ready since it was created.
+func podNotReadySince(pod *corev1.Pod) (time.Time, bool) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			if condition.Status == corev1.ConditionTrue {
+				return time.Time{}, true
+			}
+			return condition.LastTransitionTime.Time, false
+		}
+	}
+	return pod.CreationTimestamp.Time, false
+}
@@ -0,0 +1,341 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	activateStandbyCommand  = "/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && gpactivatestandby -a -d /greenplum/data-1"
+	removeMasterDataCommand = "/bin/bash -c -- rm -rf /greenplum/data-1"
+	initStandbyCommand      = "/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && gpinitstandby -a -s master-0.agent.test-ns.svc.cluster.local"
+)
+
+var _ = Describe("Reconcile master failover for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       podExec,
+			Recorder:      recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.MasterAndStandby.Standby = "yes"
+		greenplumCluster.Spec.MasterAndStandby.AutoFailover = true
+		greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	createMasterPod := func(name string, ready corev1.ConditionStatus, since time.Duration) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: name},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodReady,
+					Status:             ready,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+				}},
+			},
+		}
+		Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+	}
+	masterPodExists := func(name string) bool {
+		var pod corev1.Pod
+		err := reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &pod)
+		if apierrs.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+	greenplumConfigMap := func() corev1.ConfigMap {
+		var configMap corev1.ConfigMap
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "greenplum-config"}, &configMap)).To(Succeed())
+		return configMap
+	}
+	failedOverCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMasterFailedOver)
+	}
+
+	When("no master is answering", func() {
+		BeforeEach(func() {
+			podExec.ErrorMsgOnMaster0 = "not active"
+			podExec.ErrorMsgOnMaster1 = "not active"
+			createMasterPod("master-1", corev1.ConditionTrue, time.Hour)
+		})
+
+		When("the active master pod has been not ready for longer than the failover timeout", func() {
+			BeforeEach(func() {
+				createMasterPod("master-0", corev1.ConditionFalse, 3*time.Minute)
+			})
+			It("fences the old master and promotes the standby", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconcileResult).To(Equal(ctrl.Result{RequeueAfter: 5 * time.Second}))
+				Expect(masterPodExists("master-0")).To(BeFalse(), "expected master-0 to be deleted")
+				Expect(podExec.RecordedCommands).To(ContainElement(activateStandbyCommand))
+				Expect(podExec.CalledPodName).To(Equal("master-1"))
+			})
+			It("records the failover in the status", func() {
+				Expect(reconciledCluster.Status.ActiveMaster).To(Equal("master-1"))
+				Expect(reconciledCluster.Status.FencedMaster).To(Equal("master-0"))
+				Expect(failedOverCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("StandbyPromoted"),
+					"Message": HavePrefix("promoted master-1 after fencing master-0; annotate the GreenplumCluster with greenplum.pivotal.io/reinitialize-standby"),
+				})))
+				Expect(recorder.Events).To(Receive(Equal("Warning MasterFenced fenced and deleted master-0, which has not been ready for 3m0s")))
+				Expect(recorder.Events).To(Receive(HavePrefix("Warning MasterFailedOver promoted master-1")))
+			})
+			It("fences the old master in the configmap, so that the pod replacing it does not start Greenplum", func() {
+				Expect(greenplumConfigMap().Data).To(HaveKeyWithValue(configmap.FencedMaster, "master-0"))
+			})
+
+			When("gpactivatestandby fails", func() {
+				BeforeEach(func() {
+					podExec.ErrorMsgOnCommand = "gpactivatestandby failed"
+				})
+				It("returns the error", func() {
+					Expect(reconcileErr).To(MatchError("unable to fail over to the standby master: promoting master-1: gpactivatestandby failed: gpactivatestandby failed"))
+					Expect(reconciledCluster.Status.ActiveMaster).To(BeEmpty())
+				})
+				It("keeps the old master fenced", func() {
+					Expect(reconciledCluster.Status.FencedMaster).To(Equal("master-0"))
+					Expect(greenplumConfigMap().Data).To(HaveKeyWithValue(configmap.FencedMaster, "master-0"))
+				})
+			})
+
+			When("the standby pod is not ready", func() {
+				BeforeEach(func() {
+					Expect(reactiveClient.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "master-1"}})).To(Succeed())
+					createMasterPod("master-1", corev1.ConditionFalse, time.Minute)
+				})
+				It("does not fail over", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(masterPodExists("master-0")).To(BeTrue())
+					Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+					Expect(failedOverCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status":  Equal(metav1.ConditionFalse),
+						"Reason":  Equal("StandbyNotReady"),
+						"Message": Equal("master-0 is not ready, but the standby master-1 is not ready to take over"),
+					})))
+				})
+			})
+
+			When("the failover timeout is longer", func() {
+				BeforeEach(func() {
+					greenplumCluster.Spec.MasterAndStandby.FailoverTimeoutSeconds = 600
+				})
+				It("does not fail over yet", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(masterPodExists("master-0")).To(BeTrue())
+					Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+				})
+			})
+
+			When("autoFailover is not enabled", func() {
+				BeforeEach(func() {
+					greenplumCluster.Spec.MasterAndStandby.AutoFailover = false
+				})
+				It("does not fail over", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(masterPodExists("master-0")).To(BeTrue())
+					Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+					Expect(failedOverCondition()).To(BeNil())
+				})
+			})
+
+			When("the cluster has not been running yet", func() {
+				BeforeEach(func() {
+					greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhasePending
+				})
+				It("does not fail over", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(masterPodExists("master-0")).To(BeTrue())
+					Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+				})
+			})
+		})
+
+		When("the old master was fenced but promoting the standby failed", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.FencedMaster = "master-0"
+				// the pod that replaced it stays fenced, and so not ready
+				createMasterPod("master-0", corev1.ConditionFalse, 10*time.Second)
+			})
+			It("promotes the standby again without waiting for the failover timeout", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).To(ContainElement(activateStandbyCommand))
+				Expect(podExec.CalledPodName).To(Equal("master-1"))
+				Expect(reconciledCluster.Status.ActiveMaster).To(Equal("master-1"))
+				Expect(masterPodExists("master-0")).To(BeTrue(), "expected the fenced master-0 not to be deleted again")
+			})
+		})
+
+		When("master-1 is the active master, after an earlier failover, and fails", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.ActiveMaster = "master-1"
+				Expect(reactiveClient.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "master-1"}})).To(Succeed())
+				createMasterPod("master-1", corev1.ConditionFalse, 3*time.Minute)
+				createMasterPod("master-0", corev1.ConditionTrue, time.Hour)
+			})
+			It("fences master-1 and fails back to master-0", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(masterPodExists("master-1")).To(BeFalse(), "expected master-1 to be deleted")
+				Expect(podExec.RecordedCommands).To(ContainElement(activateStandbyCommand))
+				Expect(podExec.CalledPodName).To(Equal("master-0"))
+				Expect(reconciledCluster.Status.ActiveMaster).To(Equal("master-0"))
+			})
+			It("fences master-1 in the configmap, since its container would otherwise restart postgres on its data directory", func() {
+				Expect(reconciledCluster.Status.FencedMaster).To(Equal("master-1"))
+				Expect(greenplumConfigMap().Data).To(HaveKeyWithValue(configmap.FencedMaster, "master-1"))
+			})
+
+			When("master-0 was fenced by the earlier failover and has not been reinitialized as the standby", func() {
+				BeforeEach(func() {
+					greenplumCluster.Status.FencedMaster = "master-0"
+				})
+				It("does not fail over", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(masterPodExists("master-1")).To(BeTrue())
+					Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+					Expect(failedOverCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status":  Equal(metav1.ConditionFalse),
+						"Reason":  Equal("StandbyFenced"),
+						"Message": Equal("master-1 is not ready, but master-0 was fenced by the last failover and has not been reinitialized as the standby"),
+					})))
+				})
+			})
+		})
+
+		When("the active master pod has been not ready for less than the failover timeout", func() {
+			BeforeEach(func() {
+				createMasterPod("master-0", corev1.ConditionFalse, 30*time.Second)
+			})
+			It("does not fail over yet", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(masterPodExists("master-0")).To(BeTrue())
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+				Expect(logBuf).To(gbytes.Say("active master pod is not ready"))
+			})
+		})
+
+		When("the active master pod is ready", func() {
+			BeforeEach(func() {
+				createMasterPod("master-0", corev1.ConditionTrue, time.Hour)
+			})
+			It("does not fail over", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(activateStandbyCommand))
+			})
+		})
+	})
+
+	When("the standby has been promoted", func() {
+		BeforeEach(func() {
+			podExec.ErrorMsgOnMaster0 = "not active"
+			greenplumCluster.Status.ActiveMaster = "master-1"
+			greenplumCluster.Status.FencedMaster = "master-0"
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:               greenplumv1.GreenplumClusterConditionMasterFailedOver,
+				Status:             metav1.ConditionTrue,
+				Reason:             "StandbyPromoted",
+				LastTransitionTime: metav1.Now(),
+			}}
+		})
+
+		It("points the greenplum service at the promoted master", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var greenplumService corev1.Service
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "greenplum"}, &greenplumService)).To(Succeed())
+			Expect(greenplumService.Spec.Selector).To(Equal(map[string]string{"statefulset.kubernetes.io/pod-name": "master-1"}))
+		})
+
+		When("reinitializing the old master as the standby is requested", func() {
+			BeforeEach(func() {
+				greenplumCluster.Annotations = map[string]string{greenplumv1.ReinitializeStandbyAnnotation: "true"}
+			})
+
+			When("the old master pod is ready", func() {
+				BeforeEach(func() {
+					createMasterPod("master-0", corev1.ConditionTrue, time.Minute)
+				})
+				It("runs gpinitstandby for it", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(podExec.RecordedCommands).To(ContainElements(removeMasterDataCommand, initStandbyCommand))
+					Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.ReinitializeStandbyAnnotation))
+					Expect(reconciledCluster.Status.FencedMaster).To(BeEmpty())
+					Expect(failedOverCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+						"Status":  Equal(metav1.ConditionFalse),
+						"Reason":  Equal("StandbyReinitialized"),
+						"Message": Equal("reinitialized master-0 as the standby of master-1"),
+					})))
+					Expect(recorder.Events).To(Receive(Equal("Normal StandbyReinitialized reinitialized master-0 as the standby of master-1")))
+				})
+			})
+
+			When("the old master pod is not ready", func() {
+				BeforeEach(func() {
+					createMasterPod("master-0", corev1.ConditionFalse, time.Minute)
+				})
+				It("waits for it", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(podExec.RecordedCommands).NotTo(ContainElement(initStandbyCommand))
+					Expect(reconciledCluster.Annotations).To(HaveKey(greenplumv1.ReinitializeStandbyAnnotation))
+				})
+			})
+		})
+	})
+
+	When("reinitializing the standby is requested without a failover", func() {
+		BeforeEach(func() {
+			greenplumCluster.Annotations = map[string]string{greenplumv1.ReinitializeStandbyAnnotation: "true"}
+			createMasterPod("master-1", corev1.ConditionTrue, time.Minute)
+		})
+		It("removes the annotation without reinitializing anything", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(removeMasterDataCommand))
+			Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.ReinitializeStandbyAnnotation))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleMasterRecovery reports in the MasterInRecovery condition whether the active master is in recovery,
+// e.g. after an unclean start, in which case it rejects writes. A Warning Event is emitted when it enters recovery.
+func (r *GreenplumClusterReconciler) handleMasterRecovery(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	inRecovery, err := r.isInRecovery(greenplumCluster.Namespace, activeMaster)
+	if err != nil {
+		return err
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	wasInRecovery := meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMasterInRecovery)
+	if inRecovery {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionMasterInRecovery,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "InRecovery",
+			Message:            activeMaster + " is in recovery and only accepts read-only transactions",
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMasterInRecovery) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionMasterInRecovery,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "AcceptingWrites",
+			Message:            activeMaster + " accepts writes",
+		})
+	}
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating master recovery condition: %w", err)
+	}
+	if inRecovery && !wasInRecovery {
+		r.Log.Info("active master is in recovery", "pod", activeMaster)
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "MasterInRecovery",
+			fmt.Sprintf("%s is in recovery and rejects writes", activeMaster))
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) isInRecovery(namespace, activeMaster string) (bool, error) {
+	recoveryCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		`source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc "SELECT pg_is_in_recovery()"`,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(recoveryCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return false, fmt.Errorf("checking whether %s is in recovery: %w: %s", activeMaster, err, stderrBuf.String())
+	}
+	return strings.TrimSpace(stdoutBuf.String()) == "t", nil
+}
@@ -0,0 +1,127 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Reconcile master recovery for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+			Recorder:   recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var (
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	masterInRecoveryCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMasterInRecovery)
+	}
+
+	When("the master accepts writes", func() {
+		It("does not set the condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(masterInRecoveryCondition()).To(BeNil())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+
+	When("the master is in recovery", func() {
+		BeforeEach(func() {
+			podExec.MasterInRecovery = true
+		})
+		It("sets the condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := masterInRecoveryCondition()
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal("InRecovery"))
+			Expect(condition.Message).To(Equal("master-0 is in recovery and only accepts read-only transactions"))
+		})
+		It("emits a Warning event", func() {
+			Expect(recorder.Events).To(Receive(Equal("Warning MasterInRecovery master-0 is in recovery and rejects writes")))
+		})
+
+		When("the condition was already reported", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:               greenplumv1.GreenplumClusterConditionMasterInRecovery,
+					Status:             metav1.ConditionTrue,
+					Reason:             "InRecovery",
+					Message:            "master-0 is in recovery and only accepts read-only transactions",
+					LastTransitionTime: metav1.Now(),
+				}}
+			})
+			It("does not emit another event", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(recorder.Events).NotTo(Receive())
+			})
+		})
+	})
+
+	When("the master has left recovery", func() {
+		BeforeEach(func() {
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:               greenplumv1.GreenplumClusterConditionMasterInRecovery,
+				Status:             metav1.ConditionTrue,
+				Reason:             "InRecovery",
+				Message:            "master-0 is in recovery and only accepts read-only transactions",
+				LastTransitionTime: metav1.Now(),
+			}}
+		})
+		It("sets the condition to False", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := masterInRecoveryCondition()
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("AcceptingWrites"))
+		})
+	})
+
+	When("the check fails", func() {
+		BeforeEach(func() {
+			podExec.MasterInRecoveryErr = errors.New("injected error")
+		})
+		It("logs the error and carries on reconciling", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(logBuf).To(gbytes.Say(`"msg":"unable to check whether the master is in recovery","greenplumcluster":"test-ns/my-greenplum","error":"checking whether master-0 is in recovery: injected error: "`))
+			Expect(masterInRecoveryCondition()).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,201 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/addmirrorsjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often to check on a running gpaddmirrors job
+const addMirrorsPollInterval = 30 * time.Second
+
+// handleMirrors reports the segments in status.segments, and adds mirrors with a gpaddmirrors job once
+// spec.segments.mirrors is changed to yes on a cluster that was created without them. Each mirror must be
+// on a different node than its primary; a mirror pod scheduled beside its primary is deleted so that it
+// is scheduled again. It returns how long to wait before checking on a running job.
+func (r *GreenplumClusterReconciler) handleMirrors(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, segments []configmap.Segment) (time.Duration, error) {
+	segmentsStatus := countSegments(segments)
+	if err := r.setSegmentsStatus(ctx, greenplumCluster, segmentsStatus); err != nil {
+		return 0, err
+	}
+
+	jobKey := addMirrorsJobKey(greenplumCluster)
+	var job *batchv1.Job
+	var existingJob batchv1.Job
+	if err := r.Get(ctx, jobKey, &existingJob); err == nil {
+		job = &existingJob
+	} else if !apierrs.IsNotFound(err) {
+		return 0, err
+	}
+
+	if greenplumCluster.Spec.Segments.Mirrors != "yes" || segmentsStatus.Mirrors >= segmentsStatus.Primaries {
+		if job != nil && job.Status.Succeeded > 0 {
+			if err := r.Delete(ctx, job, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return 0, err
+			}
+		}
+		if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionAddingMirrors) == nil {
+			return 0, nil
+		}
+		return 0, r.setAddingMirrorsCondition(ctx, greenplumCluster, metav1.ConditionFalse, "MirrorsAdded",
+			fmt.Sprintf("every one of the %d primary segments has a mirror", segmentsStatus.Primaries))
+	}
+	if meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionExpanding) {
+		// the mirrors are added once the expansion settles the primaries they are for
+		return 0, nil
+	}
+
+	switch {
+	case job != nil && job.Status.Succeeded < 1 && job.Status.Failed < 1:
+		return addMirrorsPollInterval, nil
+	case job != nil && job.Status.Failed > 0:
+		return 0, r.setAddingMirrorsCondition(ctx, greenplumCluster, metav1.ConditionTrue, "AddMirrorsFailed",
+			fmt.Sprintf("gpaddmirrors job %s failed; check its logs, then delete it to retry", job.Name))
+	}
+
+	var mirrorStatefulSet appsv1.StatefulSet
+	if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: "segment-b"}, &mirrorStatefulSet); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return 0, err
+		}
+	}
+	if notReady := segmentsStatus.Primaries - mirrorStatefulSet.Status.ReadyReplicas; notReady > 0 {
+		// segment-b is owned, so its pods becoming ready triggers another reconcile
+		return 0, r.setAddingMirrorsCondition(ctx, greenplumCluster, metav1.ConditionTrue, "WaitingForMirrorPods",
+			fmt.Sprintf("waiting for %d mirror pods to be ready before adding mirrors", notReady))
+	}
+
+	misplaced, err := r.mirrorPodsBesidePrimaries(ctx, greenplumCluster, segmentsStatus.Primaries)
+	if err != nil {
+		return 0, err
+	}
+	if len(misplaced) > 0 {
+		for _, pod := range misplaced {
+			r.Log.Info("deleting mirror pod on the same node as its primary", "pod", pod.Name, "node", pod.Spec.NodeName)
+			if err := r.Delete(ctx, pod); err != nil && !apierrs.IsNotFound(err) {
+				return 0, fmt.Errorf("deleting mirror pod %s: %w", pod.Name, err)
+			}
+		}
+		return 0, r.setAddingMirrorsCondition(ctx, greenplumCluster, metav1.ConditionTrue, "WaitingForMirrorPlacement",
+			fmt.Sprintf("rescheduling %d mirror pods that were on the same node as their primary", len(misplaced)))
+	}
+
+	if err := r.createAddMirrorsJob(ctx, greenplumCluster, activeMaster); err != nil {
+		return 0, err
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "AddingMirrors",
+		fmt.Sprintf("adding mirrors for %d primary segments", segmentsStatus.Primaries))
+	if err := r.setAddingMirrorsCondition(ctx, greenplumCluster, metav1.ConditionTrue, "AddingMirrors",
+		fmt.Sprintf("gpaddmirrors is adding mirrors for %d primary segments", segmentsStatus.Primaries)); err != nil {
+		return 0, err
+	}
+	return addMirrorsPollInterval, nil
+}
+
+// countSegments counts the primary and mirror segments, and those marked down, skipping the master and standby
+func countSegments(segments []configmap.Segment) greenplumv1.GreenplumSegmentsStatus {
+	var segmentsStatus greenplumv1.GreenplumSegmentsStatus
+	for _, segment := range segments {
+		if segment.Content < 0 {
+			continue
+		}
+		down := segment.Status == "d"
+		if segment.Role == "p" {
+			segmentsStatus.Primaries++
+			if down {
+				segmentsStatus.PrimariesDown++
+			}
+		} else {
+			segmentsStatus.Mirrors++
+			if down {
+				segmentsStatus.MirrorsDown++
+			}
+		}
+	}
+	return segmentsStatus
+}
+
+func (r *GreenplumClusterReconciler) setSegmentsStatus(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, segmentsStatus greenplumv1.GreenplumSegmentsStatus) error {
+	if greenplumCluster.Status.Segments != nil && *greenplumCluster.Status.Segments == segmentsStatus {
+		return nil
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.Segments = &segmentsStatus
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating segments status: %w", err)
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) setAddingMirrorsCondition(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, status metav1.ConditionStatus, reason, message string) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionAddingMirrors,
+		Status:             status,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating mirrors status: %w", err)
+	}
+	return nil
+}
+
+// mirrorPodsBesidePrimaries returns the segment-b pods scheduled on the same node as the segment-a pod
+// whose primary they will mirror
+func (r *GreenplumClusterReconciler) mirrorPodsBesidePrimaries(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, primaries int32) ([]*corev1.Pod, error) {
+	var misplaced []*corev1.Pod
+	for i := int32(0); i < primaries; i++ {
+		var primaryPod, mirrorPod corev1.Pod
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: fmt.Sprintf("segment-a-%d", i)}, &primaryPod); err != nil {
+			return nil, fmt.Errorf("getting primary pod: %w", err)
+		}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: fmt.Sprintf("segment-b-%d", i)}, &mirrorPod); err != nil {
+			return nil, fmt.Errorf("getting mirror pod: %w", err)
+		}
+		if mirrorPod.Spec.NodeName != "" && mirrorPod.Spec.NodeName == primaryPod.Spec.NodeName {
+			misplaced = append(misplaced, &mirrorPod)
+		}
+	}
+	return misplaced, nil
+}
+
+func addMirrorsJobKey(greenplumCluster *greenplumv1.GreenplumCluster) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: greenplumCluster.Namespace,
+		Name:      fmt.Sprintf("%s-gpaddmirrors-job", greenplumCluster.Name),
+	}
+}
+
+func (r *GreenplumClusterReconciler) createAddMirrorsJob(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	jobKey := addMirrorsJobKey(greenplumCluster)
+	mirrorDomain := fmt.Sprintf("agent.%s.svc.cluster.local", greenplumCluster.Namespace)
+	job := addmirrorsjob.GenerateJob(r.InstanceImage, activeMaster+"."+mirrorDomain, mirrorDomain)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+
+	if err := ctrl.SetControllerReference(greenplumCluster, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return err
+	}
+	return r.Create(ctx, &job)
+}
@@ -0,0 +1,282 @@
+package greenplumcluster_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	unmirroredSegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+		"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n"
+	mirroredSegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+		"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+		"3|0|m|m|d|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+)
+
+var _ = Describe("Reconcile mirrors for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+		addMirrorsJobKey    types.NamespacedName
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			MirroringStatus:      gpstateMirrorsInSync,
+			SegmentConfiguration: unmirroredSegmentConfiguration,
+		}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       podExec,
+			Recorder:      recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+		addMirrorsJobKey = types.NamespacedName{Namespace: namespaceName, Name: "my-greenplum-gpaddmirrors-job"}
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	createReadyMirrorStatefulSet := func() {
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "segment-b"},
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+		}
+		Expect(reactiveClient.Create(ctx, statefulSet)).To(Succeed())
+	}
+	createSegmentPod := func(name, nodeName string) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: name},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+		}
+		Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+	}
+	createAddMirrorsJob := func(status batchv1.JobStatus) {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: addMirrorsJobKey.Namespace, Name: addMirrorsJobKey.Name},
+			Status:     status,
+		}
+		Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+	}
+	addingMirrorsCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionAddingMirrors)
+	}
+
+	It("reports the segments in the status", func() {
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		Expect(reconciledCluster.Status.Segments).To(PointTo(Equal(greenplumv1.GreenplumSegmentsStatus{Primaries: 1})))
+	})
+
+	When("the mirror pods are not ready", func() {
+		It("waits for them before adding mirrors", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, addMirrorsJobKey, &job)).To(MatchError(ContainSubstring("not found")))
+			Expect(addingMirrorsCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("WaitingForMirrorPods"),
+				"Message": Equal("waiting for 1 mirror pods to be ready before adding mirrors"),
+			})))
+		})
+	})
+
+	When("the mirror pods are ready on other nodes than their primaries", func() {
+		BeforeEach(func() {
+			createReadyMirrorStatefulSet()
+			createSegmentPod("segment-a-0", "node-1")
+			createSegmentPod("segment-b-0", "node-2")
+		})
+		It("starts a gpaddmirrors job", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, addMirrorsJobKey, &job)).To(Succeed())
+			Expect(job.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("greenplum-for-kubernetes:latest"))
+			Expect(container.Env).To(ConsistOf(
+				corev1.EnvVar{Name: "GPADDMIRRORS_HOST", Value: "master-0.agent.test-ns.svc.cluster.local"},
+				corev1.EnvVar{Name: "MIRROR_DOMAIN", Value: "agent.test-ns.svc.cluster.local"},
+			))
+		})
+		It("reports that it is adding mirrors", func() {
+			Expect(addingMirrorsCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("AddingMirrors"),
+				"Message": Equal("gpaddmirrors is adding mirrors for 1 primary segments"),
+			})))
+			Expect(recorder.Events).To(Receive(Equal("Normal AddingMirrors adding mirrors for 1 primary segments")))
+		})
+
+		When("the cluster is expanding", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:               greenplumv1.GreenplumClusterConditionExpanding,
+					Status:             metav1.ConditionTrue,
+					Reason:             "AddingSegments",
+					LastTransitionTime: metav1.Now(),
+				}}
+			})
+			It("waits for the expansion before adding mirrors", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, addMirrorsJobKey, &job)).To(MatchError(ContainSubstring("not found")))
+			})
+		})
+
+		When("creating the job fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("create", "jobs", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("injected error")
+				})
+			})
+			It("returns the error", func() {
+				Expect(reconcileErr).To(MatchError("unable to add mirrors: injected error"))
+			})
+		})
+	})
+
+	When("a mirror pod is on the same node as its primary", func() {
+		BeforeEach(func() {
+			createReadyMirrorStatefulSet()
+			createSegmentPod("segment-a-0", "node-1")
+			createSegmentPod("segment-b-0", "node-1")
+		})
+		It("deletes the mirror pod so that it is scheduled again", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var pod corev1.Pod
+			err := reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-b-0"}, &pod)
+			Expect(apierrs.IsNotFound(err)).To(BeTrue(), "expected segment-b-0 to be deleted")
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, addMirrorsJobKey, &job)).To(MatchError(ContainSubstring("not found")))
+			Expect(addingMirrorsCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("WaitingForMirrorPlacement"),
+				"Message": Equal("rescheduling 1 mirror pods that were on the same node as their primary"),
+			})))
+		})
+	})
+
+	When("the gpaddmirrors job is running", func() {
+		BeforeEach(func() {
+			createAddMirrorsJob(batchv1.JobStatus{Active: 1})
+		})
+		It("checks on it again later", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+		})
+	})
+
+	When("the gpaddmirrors job failed", func() {
+		BeforeEach(func() {
+			createAddMirrorsJob(batchv1.JobStatus{Failed: 1})
+		})
+		It("reports the failure", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(addingMirrorsCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("AddMirrorsFailed"),
+				"Message": Equal("gpaddmirrors job my-greenplum-gpaddmirrors-job failed; check its logs, then delete it to retry"),
+			})))
+		})
+	})
+
+	When("every primary has a mirror", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = mirroredSegmentConfiguration
+			createAddMirrorsJob(batchv1.JobStatus{Succeeded: 1})
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:               greenplumv1.GreenplumClusterConditionAddingMirrors,
+				Status:             metav1.ConditionTrue,
+				Reason:             "AddingMirrors",
+				LastTransitionTime: metav1.Now(),
+			}}
+		})
+		It("counts the segments that are down", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.Segments).To(PointTo(Equal(greenplumv1.GreenplumSegmentsStatus{
+				Primaries:   1,
+				Mirrors:     1,
+				MirrorsDown: 1,
+			})))
+		})
+		It("deletes the succeeded job", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, addMirrorsJobKey, &job)).To(MatchError(ContainSubstring("not found")))
+		})
+		It("reports that the mirrors were added", func() {
+			Expect(addingMirrorsCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionFalse),
+				"Reason":  Equal("MirrorsAdded"),
+				"Message": Equal("every one of the 1 primary segments has a mirror"),
+			})))
+		})
+	})
+
+	When("mirrors are not enabled", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Mirrors = "no"
+		})
+		It("does not add mirrors", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, addMirrorsJobKey, &job)).To(MatchError(ContainSubstring("not found")))
+			Expect(addingMirrorsCondition()).To(BeNil())
+		})
+	})
+
+	When("patching the segments status fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("patch", "greenplumclusters", func(action testing.Action) (bool, runtime.Object, error) {
+				if !bytes.Contains(action.(testing.PatchAction).GetPatch(), []byte(`"segments"`)) {
+					return false, nil, nil
+				}
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError("unable to add mirrors: updating segments status: injected error"))
+		})
+	})
+})
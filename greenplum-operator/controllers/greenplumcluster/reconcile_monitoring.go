@@ -0,0 +1,96 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/exporter"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleMonitoring writes the queries of the greenplum-exporter sidecars, which the statefulsets run in the pods of
+// each enabled role, and exposes them on the greenplum-exporter Service while spec.monitoring.prometheus is enabled,
+// with the greenplum-dashboard ConfigMap if grafanaDashboard is set. It deletes them once monitoring is disabled.
+func (r *GreenplumClusterReconciler) handleMonitoring(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	monitoring := greenplumCluster.Spec.Monitoring
+	if !exporter.Enabled(monitoring, exporter.RoleMaster) {
+		return r.deleteExporter(ctx,
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: exporter.Name}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: exporter.QueriesConfigMapName}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: exporter.DashboardConfigMapName}})
+	}
+	prometheusSpec := monitoring.Prometheus
+
+	// The exporters log in with the gpadmin password, which only exists once it has been set by us
+	if _, err := r.ensureAdminPasswordSecret(ctx, greenplumCluster, activeMaster); err != nil {
+		return err
+	}
+
+	customQueries, err := exporter.GetCustomQueries(ctx, r, greenplumCluster.Namespace, prometheusSpec.CustomQueries)
+	if err != nil {
+		return fmt.Errorf("getting customQueries: %w", err)
+	}
+
+	queriesConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: exporter.QueriesConfigMapName},
+	}
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, queriesConfigMap, func() error {
+		exporter.ModifyQueriesConfigMap(greenplumCluster.Name, queriesConfigMap, exporter.MasterQueries(customQueries), exporter.SegmentQueries())
+		return ctrl.SetControllerReference(greenplumCluster, queriesConfigMap, r.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("updating exporter queries: %w", err)
+	}
+	r.logReconcileResult(operationResult, queriesConfigMap)
+
+	exporterService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: exporter.Name},
+	}
+	operationResult, err = ctrl.CreateOrUpdate(ctx, r, exporterService, func() error {
+		exporter.ModifyService(greenplumCluster.Name, exporterService)
+		return ctrl.SetControllerReference(greenplumCluster, exporterService, r.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("updating exporter service: %w", err)
+	}
+	r.logReconcileResult(operationResult, exporterService)
+
+	dashboardConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: exporter.DashboardConfigMapName},
+	}
+	if !prometheusSpec.GrafanaDashboard {
+		return r.deleteExporter(ctx, dashboardConfigMap)
+	}
+	operationResult, err = ctrl.CreateOrUpdate(ctx, r, dashboardConfigMap, func() error {
+		dashboard := exporter.Dashboard(greenplumCluster.Namespace, exporter.SegmentsEnabled(monitoring))
+		exporter.ModifyDashboardConfigMap(greenplumCluster.Name, dashboardConfigMap, dashboard)
+		return ctrl.SetControllerReference(greenplumCluster, dashboardConfigMap, r.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("updating grafana dashboard: %w", err)
+	}
+	r.logReconcileResult(operationResult, dashboardConfigMap)
+	return nil
+}
+
+// deleteExporter deletes those of objs that exist
+func (r *GreenplumClusterReconciler) deleteExporter(ctx context.Context, objs ...client.Object) error {
+	for _, obj := range objs {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("getting %s: %w", obj.GetName(), err)
+		}
+		if err := r.Delete(ctx, obj); err != nil && !apierrs.IsNotFound(err) {
+			return fmt.Errorf("deleting %s: %w", obj.GetName(), err)
+		}
+		r.Log.Info("deleted disabled exporter resource", "name", obj.GetName())
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Reconcile monitoring for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		reconcileErr        error
+
+		exporterKey  = types.NamespacedName{Namespace: namespaceName, Name: "greenplum-exporter"}
+		queriesKey   = types.NamespacedName{Namespace: namespaceName, Name: "greenplum-exporter-queries"}
+		dashboardKey = types.NamespacedName{Namespace: namespaceName, Name: "greenplum-dashboard"}
+		secretKey    = types.NamespacedName{Namespace: namespaceName, Name: greenplumcluster.ConnectionSecretName}
+		doesNotExist = func(key types.NamespacedName, obj client.Object) bool {
+			return apierrs.IsNotFound(reactiveClient.Get(context.Background(), key, obj))
+		}
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	getContainers := func(name string) []corev1.Container {
+		var sset appsv1.StatefulSet
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &sset)).To(Succeed())
+		return sset.Spec.Template.Spec.Containers
+	}
+	containerNames := func(name string) []string {
+		var names []string
+		for _, container := range getContainers(name) {
+			names = append(names, container.Name)
+		}
+		return names
+	}
+	setMonitoring := func(monitoring *greenplumv1.GreenplumMonitoringSpec) {
+		var cluster greenplumv1.GreenplumCluster
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &cluster)).To(Succeed())
+		cluster.Spec.Monitoring = monitoring
+		Expect(reactiveClient.Update(ctx, &cluster)).To(Succeed())
+	}
+
+	When("monitoring is not set", func() {
+		It("does not run an exporter", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(containerNames("master")).To(Equal([]string{"greenplum"}))
+			Expect(doesNotExist(exporterKey, &corev1.Service{})).To(BeTrue(), "expected exporter service to not exist")
+			Expect(doesNotExist(queriesKey, &corev1.ConfigMap{})).To(BeTrue(), "expected exporter queries to not exist")
+			Expect(doesNotExist(secretKey, &corev1.Secret{})).To(BeTrue(), "expected connection secret to not exist")
+		})
+	})
+
+	When("monitoring.prometheus is enabled", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+				Prometheus: &greenplumv1.GreenplumPrometheusSpec{Enabled: true},
+			}
+		})
+
+		It("runs the exporter beside the master only", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			containers := getContainers("master")
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Name).To(Equal("greenplum-exporter"))
+			Expect(containers[1].Image).To(Equal("quay.io/prometheuscommunity/postgres-exporter:v0.11.1"))
+			Expect(containers[1].Args).To(ContainElement("--extend.query-path=/etc/greenplum-exporter/master.yaml"))
+			Expect(containerNames("segment-a")).To(Equal([]string{"greenplum"}))
+		})
+
+		It("writes the queries of the master and of the segments", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var queries corev1.ConfigMap
+			Expect(reactiveClient.Get(ctx, queriesKey, &queries)).To(Succeed())
+			Expect(queries.OwnerReferences).To(HaveLen(1))
+			Expect(queries.OwnerReferences[0].Name).To(Equal(clusterName))
+			Expect(queries.Data["master.yaml"]).To(ContainSubstring("greenplum_connections:"))
+			Expect(queries.Data["master.yaml"]).To(ContainSubstring("greenplum_host_disk:"))
+			Expect(queries.Data["segment.yaml"]).To(ContainSubstring("greenplum_segment_disk:"))
+		})
+
+		It("exposes the exporters on a headless Service annotated for Prometheus", func() {
+			var service corev1.Service
+			Expect(reactiveClient.Get(ctx, exporterKey, &service)).To(Succeed())
+			Expect(service.OwnerReferences).To(HaveLen(1))
+			Expect(service.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			Expect(service.Spec.Selector).To(HaveKeyWithValue("greenplum-cluster", clusterName))
+			Expect(service.Annotations).To(HaveKeyWithValue("prometheus.io/scrape", "true"))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(9187)))
+		})
+
+		When("the connection secret does not exist", func() {
+			It("sets the admin password for the exporter", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var secret corev1.Secret
+				Expect(reactiveClient.Get(ctx, secretKey, &secret)).To(Succeed())
+				password := string(secret.Data["password"])
+				Expect(password).To(HaveLen(32))
+				Expect(podExec.RecordedStdin).To(ConsistOf("ALTER ROLE gpadmin WITH PASSWORD '" + password + "';\n"))
+				Expect(logBuf).To(gbytes.Say(`"msg":"set admin password"`))
+			})
+		})
+
+		When("the connection secret exists", func() {
+			BeforeEach(func() {
+				Expect(reactiveClient.Create(ctx, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: secretKey.Namespace,
+						Name:      secretKey.Name,
+						Annotations: map[string]string{
+							greenplumcluster.AdminPasswordRotatedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+						},
+					},
+					Data: map[string][]byte{
+						"username": []byte("gpadmin"),
+						"password": []byte("existing-password"),
+					},
+				})).To(Succeed())
+			})
+			It("keeps the password", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var secret corev1.Secret
+				Expect(reactiveClient.Get(ctx, secretKey, &secret)).To(Succeed())
+				Expect(string(secret.Data["password"])).To(Equal("existing-password"))
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ALTER ROLE")))
+			})
+		})
+
+		When("segments is set", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.Mirrors = "yes"
+			})
+
+			When("primary is enabled", func() {
+				BeforeEach(func() {
+					greenplumCluster.Spec.Monitoring.Prometheus.Segments = &greenplumv1.GreenplumPrometheusSegmentsSpec{Primary: true}
+				})
+				It("runs the exporter beside the primaries only", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					containers := getContainers("segment-a")
+					Expect(containers).To(HaveLen(2))
+					Expect(containers[1].Name).To(Equal("greenplum-exporter"))
+					Expect(containers[1].Args).To(ContainElement("--extend.query-path=/etc/greenplum-exporter/segment.yaml"))
+					Expect(containerNames("segment-b")).To(Equal([]string{"greenplum"}))
+				})
+			})
+
+			When("mirror is enabled", func() {
+				BeforeEach(func() {
+					greenplumCluster.Spec.Monitoring.Prometheus.Segments = &greenplumv1.GreenplumPrometheusSegmentsSpec{Mirror: true}
+				})
+				It("runs the exporter beside the mirrors only", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(containerNames("segment-a")).To(Equal([]string{"greenplum"}))
+					Expect(containerNames("segment-b")).To(Equal([]string{"greenplum", "greenplum-exporter"}))
+				})
+			})
+		})
+
+		When("customQueries is set", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Monitoring.Prometheus.CustomQueries = &greenplumv1.GreenplumCustomQueriesSpec{ConfigMapName: "my-queries"}
+			})
+
+			When("the ConfigMap exists", func() {
+				BeforeEach(func() {
+					Expect(reactiveClient.Create(ctx, &corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "my-queries"},
+						Data:       map[string]string{"queries.yaml": "my_query:\n  query: SELECT 1\n"},
+					})).To(Succeed())
+				})
+				It("appends the custom queries to those of the master", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var queries corev1.ConfigMap
+					Expect(reactiveClient.Get(ctx, queriesKey, &queries)).To(Succeed())
+					Expect(queries.Data["master.yaml"]).To(HaveSuffix("my_query:\n  query: SELECT 1\n"))
+					Expect(queries.Data["segment.yaml"]).NotTo(ContainSubstring("my_query:"))
+				})
+				It("updates the queries without changing the statefulsets when the custom queries change", func() {
+					before := getContainers("master")
+
+					var customQueries corev1.ConfigMap
+					Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "my-queries"}, &customQueries)).To(Succeed())
+					customQueries.Data["queries.yaml"] = "my_query:\n  query: SELECT 2\n"
+					Expect(reactiveClient.Update(ctx, &customQueries)).To(Succeed())
+					_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+					Expect(err).NotTo(HaveOccurred())
+
+					var queries corev1.ConfigMap
+					Expect(reactiveClient.Get(ctx, queriesKey, &queries)).To(Succeed())
+					Expect(queries.Data["master.yaml"]).To(HaveSuffix("my_query:\n  query: SELECT 2\n"))
+					Expect(getContainers("master")).To(Equal(before))
+				})
+			})
+
+			When("the ConfigMap does not exist", func() {
+				It("returns an error", func() {
+					Expect(reconcileErr).To(MatchError(`unable to configure monitoring: getting customQueries: ConfigMap "my-queries" not found`))
+				})
+			})
+		})
+
+		It("leaves the greenplum container unchanged when the exporter is removed", func() {
+			before := getContainers("master")
+
+			setMonitoring(nil)
+			_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			after := getContainers("master")
+			Expect(after).To(HaveLen(1))
+			Expect(after[0]).To(Equal(before[0]))
+		})
+
+		It("does not generate a Grafana dashboard", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(doesNotExist(dashboardKey, &corev1.ConfigMap{})).To(BeTrue(), "expected dashboard to not exist")
+		})
+
+		When("grafanaDashboard is set", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Monitoring.Prometheus.GrafanaDashboard = true
+			})
+			It("generates the dashboard ConfigMap with the import label", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var dashboard corev1.ConfigMap
+				Expect(reactiveClient.Get(ctx, dashboardKey, &dashboard)).To(Succeed())
+				Expect(dashboard.Labels).To(HaveKeyWithValue("grafana_dashboard", "1"))
+				Expect(dashboard.OwnerReferences).To(HaveLen(1))
+				Expect(dashboard.OwnerReferences[0].Name).To(Equal(clusterName))
+				Expect(dashboard.Data["greenplum.json"]).To(ContainSubstring(`greenplum_segments_down{namespace=\"test-ns\"}`))
+			})
+			It("deletes the dashboard once grafanaDashboard is unset", func() {
+				setMonitoring(&greenplumv1.GreenplumMonitoringSpec{
+					Prometheus: &greenplumv1.GreenplumPrometheusSpec{Enabled: true},
+				})
+				_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(doesNotExist(dashboardKey, &corev1.ConfigMap{})).To(BeTrue(), "expected dashboard to be deleted")
+				Expect(doesNotExist(exporterKey, &corev1.Service{})).To(BeFalse(), "expected exporter service to be kept")
+			})
+			It("deletes the dashboard once monitoring is disabled", func() {
+				setMonitoring(nil)
+				_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(doesNotExist(dashboardKey, &corev1.ConfigMap{})).To(BeTrue(), "expected dashboard to be deleted")
+			})
+		})
+
+		When("monitoring is disabled again", func() {
+			It("deletes the exporter", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				setMonitoring(&greenplumv1.GreenplumMonitoringSpec{
+					Prometheus: &greenplumv1.GreenplumPrometheusSpec{Enabled: false},
+				})
+				_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(containerNames("master")).To(Equal([]string{"greenplum"}))
+				Expect(doesNotExist(exporterKey, &corev1.Service{})).To(BeTrue(), "expected exporter service to be deleted")
+				Expect(doesNotExist(queriesKey, &corev1.ConfigMap{})).To(BeTrue(), "expected exporter queries to be deleted")
+				Expect(logBuf).To(gbytes.Say(`"msg":"deleted disabled exporter resource","name":"greenplum-exporter"`))
+			})
+		})
+	})
+})
@@ -0,0 +1,188 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// handleNodeDrain fails over primary segments running on cordoned or NoExecute-tainted nodes
+// to their mirrors before the pods are evicted. Stopping the primary with a fast shutdown lets
+// FTS promote an in-sync mirror in a controlled way, instead of the primary being killed
+// mid-transaction. Once the pod is rescheduled, its segment comes back as a mirror in change
+// tracking and gprecoverseg brings it back in sync.
+func (r *GreenplumClusterReconciler) handleNodeDrain(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, segments []configmap.Segment) error {
+	if greenplumCluster.Spec.Segments.Mirrors != "yes" {
+		return nil
+	}
+
+	drainingPods, err := r.podsOnDrainingNodes(ctx, greenplumCluster)
+	if err != nil {
+		return err
+	}
+	if len(drainingPods) == 0 {
+		return nil
+	}
+
+	for _, primary := range primariesToFailOver(segments, drainingPods) {
+		r.Log.Info("stopping primary segment on draining node so that its mirror takes over",
+			"pod", primary.Hostname, "node", drainingPods[primary.Hostname], "content", primary.Content)
+		stopSegmentCommand := []string{
+			"/bin/bash",
+			"-c",
+			"--",
+			"source /usr/local/greenplum-db/greenplum_path.sh && pg_ctl stop -m fast -D " + primary.DataDir,
+		}
+		stdoutBuf := &bytes.Buffer{}
+		stderrBuf := &bytes.Buffer{}
+		if err := r.PodExec.Execute(stopSegmentCommand, greenplumCluster.Namespace, primary.Hostname, stdoutBuf, stderrBuf); err != nil {
+			return fmt.Errorf("stopping primary segment on %s: %w: %s", primary.Hostname, err, stderrBuf.String())
+		}
+	}
+	return nil
+}
+
+// podsOnDrainingNodes maps the name of each segment pod on a draining node to the name of that node
+func (r *GreenplumClusterReconciler) podsOnDrainingNodes(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (map[string]string, error) {
+	var podList corev1.PodList
+	labelMatcher := client.MatchingLabels{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": greenplumCluster.Name,
+	}
+	if err := r.List(ctx, &podList, labelMatcher, client.InNamespace(greenplumCluster.Namespace)); err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	drainingPods := map[string]string{}
+	nodeDraining := map[string]bool{}
+	for _, pod := range podList.Items {
+		nodeName := pod.Spec.NodeName
+		if nodeName == "" {
+			continue
+		}
+		draining, ok := nodeDraining[nodeName]
+		if !ok {
+			var node corev1.Node
+			if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+				if !apierrs.IsNotFound(err) {
+					return nil, fmt.Errorf("getting node %s: %w", nodeName, err)
+				}
+			} else {
+				draining = isNodeDraining(node)
+			}
+			nodeDraining[nodeName] = draining
+		}
+		if draining {
+			drainingPods[pod.Name] = nodeName
+		}
+	}
+	return drainingPods, nil
+}
+
+// isNodeDraining is true for nodes that are cordoned, or tainted so that their pods will be evicted
+func isNodeDraining(node corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoExecute {
+			return true
+		}
+	}
+	return false
+}
+
+// primariesToFailOver returns the up primaries hosted by a draining pod whose mirror is up and
+// hosted elsewhere. Without a healthy mirror to take over, stopping the primary would take its
+// content offline, so it is left to be evicted as usual.
+func primariesToFailOver(segments []configmap.Segment, drainingPods map[string]string) []configmap.Segment {
+	mirrors := map[int]configmap.Segment{}
+	for _, segment := range segments {
+		if segment.Content >= 0 && segment.Role == "m" {
+			mirrors[segment.Content] = segment
+		}
+	}
+	var primaries []configmap.Segment
+	for _, segment := range segments {
+		if segment.Content < 0 || segment.Role != "p" || segment.Status != "u" {
+			continue
+		}
+		if _, ok := drainingPods[segment.Hostname]; !ok {
+			continue
+		}
+		mirror, ok := mirrors[segment.Content]
+		if !ok || mirror.Status != "u" {
+			continue
+		}
+		if _, ok := drainingPods[mirror.Hostname]; ok {
+			continue
+		}
+		primaries = append(primaries, segment)
+	}
+	return primaries
+}
+
+// podNodeNameField indexes pods by the node they are scheduled on, so that the pods on a draining node
+// can be listed from the cache
+const podNodeNameField = "spec.nodeName"
+
+func podNodeName(obj client.Object) []string {
+	return []string{obj.(*corev1.Pod).Spec.NodeName}
+}
+
+// nodeStartedDraining passes on the node events that start a drain: a node being cordoned or tainted NoExecute,
+// or created that way. Every other node event, such as the periodic status updates of the kubelet, is dropped
+var nodeStartedDraining = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		node, ok := e.Object.(*corev1.Node)
+		return ok && isNodeDraining(*node)
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, oldOK := e.ObjectOld.(*corev1.Node)
+		newNode, newOK := e.ObjectNew.(*corev1.Node)
+		return oldOK && newOK && !isNodeDraining(*oldNode) && isNodeDraining(*newNode)
+	},
+	DeleteFunc: func(event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
+
+// greenplumClustersOnDrainingNode enqueues the GreenplumClusters with pods on a node that starts draining,
+// so that primaries on it can be failed over before eviction
+func (r *GreenplumClusterReconciler) greenplumClustersOnDrainingNode(obj client.Object) []reconcile.Request {
+	var podList corev1.PodList
+	if err := r.List(context.Background(), &podList,
+		client.MatchingLabels{"app": greenplumv1.AppName},
+		client.MatchingFields{podNodeNameField: obj.GetName()}); err != nil {
+		r.Log.Error(err, "listing pods on draining node", "node", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	enqueued := map[types.NamespacedName]bool{}
+	for _, pod := range podList.Items {
+		clusterName := pod.Labels["greenplum-cluster"]
+		if clusterName == "" {
+			continue
+		}
+		key := types.NamespacedName{Namespace: pod.Namespace, Name: clusterName}
+		if enqueued[key] {
+			continue
+		}
+		enqueued[key] = true
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
@@ -0,0 +1,305 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const stopSegmentCommand = "/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && pg_ctl stop -m fast -D /greenplum/data"
+
+var _ = Describe("Reconcile draining nodes for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			MirroringStatus: gpstateMirrorsInSync,
+			SegmentConfiguration: "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+				"3|0|m|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n",
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+	})
+
+	createNode := func(name string, modify func(*corev1.Node)) {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if modify != nil {
+			modify(node)
+		}
+		Expect(reactiveClient.Create(ctx, node)).To(Succeed())
+	}
+
+	createSegmentPod := func(name, typ, nodeName string) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespaceName,
+				Name:      name,
+				Labels: map[string]string{
+					"app":               "greenplum",
+					"greenplum-cluster": clusterName,
+					"type":              typ,
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName},
+		}
+		Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+	}
+
+	cordon := func(node *corev1.Node) {
+		node.Spec.Unschedulable = true
+	}
+
+	stopCommands := func() []string {
+		var commands []string
+		for _, command := range podExec.RecordedCommands {
+			if strings.Contains(command, "pg_ctl stop") {
+				commands = append(commands, command)
+			}
+		}
+		return commands
+	}
+
+	var reconcileErr error
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	When("no segment pod is on a draining node", func() {
+		BeforeEach(func() {
+			createNode("node-1", nil)
+			createNode("node-2", nil)
+			createSegmentPod("segment-a-0", "segment-a", "node-1")
+			createSegmentPod("segment-b-0", "segment-b", "node-2")
+		})
+		It("does not stop any segment", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(stopCommands()).To(BeEmpty())
+		})
+	})
+
+	When("a node hosting a primary segment is cordoned", func() {
+		BeforeEach(func() {
+			createNode("node-1", cordon)
+			createNode("node-2", nil)
+			createSegmentPod("segment-a-0", "segment-a", "node-1")
+			createSegmentPod("segment-b-0", "segment-b", "node-2")
+		})
+		It("stops the primary so that its mirror takes over", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(stopCommands()).To(ConsistOf(stopSegmentCommand))
+			Expect(logBuf).To(gbytes.Say("stopping primary segment on draining node so that its mirror takes over"))
+		})
+
+		When("the primary has already failed over", func() {
+			BeforeEach(func() {
+				podExec.SegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+					"2|0|m|p|d|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+					"3|0|p|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+			})
+			It("does not stop any segment", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(stopCommands()).To(BeEmpty())
+			})
+		})
+
+		When("the mirror is down", func() {
+			BeforeEach(func() {
+				podExec.SegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+					"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+					"3|0|m|m|d|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+			})
+			It("leaves the primary running", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(stopCommands()).To(BeEmpty())
+			})
+		})
+
+		When("mirrors are not enabled", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.Mirrors = "no"
+			})
+			It("does not stop any segment", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(stopCommands()).To(BeEmpty())
+			})
+		})
+
+		When("stopping the primary fails", func() {
+			BeforeEach(func() {
+				podExec.ErrorMsgOnCommand = "pg_ctl: could not send stop signal"
+			})
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring(
+					"unable to fail over segments on draining nodes: stopping primary segment on segment-a-0: pg_ctl: could not send stop signal")))
+			})
+		})
+	})
+
+	When("a node hosting a mirror that has taken over as primary is cordoned", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|m|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+				"3|0|p|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+			createNode("node-1", nil)
+			createNode("node-2", cordon)
+			createSegmentPod("segment-a-0", "segment-a", "node-1")
+			createSegmentPod("segment-b-0", "segment-b", "node-2")
+		})
+		It("stops it in its own data directory", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(stopCommands()).To(ConsistOf(
+				"/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && pg_ctl stop -m fast -D /greenplum/mirror/data"))
+			Expect(podExec.CalledPodName).To(Equal("segment-b-0"))
+		})
+	})
+
+	When("the node hosting the mirror is cordoned as well", func() {
+		BeforeEach(func() {
+			createNode("node-1", cordon)
+			createSegmentPod("segment-a-0", "segment-a", "node-1")
+			createSegmentPod("segment-b-0", "segment-b", "node-1")
+		})
+		It("leaves the primary running", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(stopCommands()).To(BeEmpty())
+		})
+	})
+
+	When("a node hosting a primary segment has a NoExecute taint", func() {
+		BeforeEach(func() {
+			createNode("node-1", func(node *corev1.Node) {
+				node.Spec.Taints = []corev1.Taint{{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}}
+			})
+			createNode("node-2", nil)
+			createSegmentPod("segment-a-0", "segment-a", "node-1")
+			createSegmentPod("segment-b-0", "segment-b", "node-2")
+		})
+		It("stops the primary so that its mirror takes over", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(stopCommands()).To(HaveLen(1))
+		})
+	})
+
+	When("a node hosting a primary segment only has a NoSchedule taint", func() {
+		BeforeEach(func() {
+			createNode("node-1", func(node *corev1.Node) {
+				node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Effect: corev1.TaintEffectNoSchedule}}
+			})
+			createSegmentPod("segment-a-0", "segment-a", "node-1")
+		})
+		It("does not stop any segment", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(stopCommands()).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Watching nodes for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		node                *corev1.Node
+		cordonedNode        *corev1.Node
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client: reactiveClient,
+			Log:    gplog.ForTest(gbytes.NewBuffer()),
+		}
+		node = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+		cordonedNode = node.DeepCopy()
+		cordonedNode.Spec.Unschedulable = true
+	})
+
+	Describe("NodeStartedDraining", func() {
+		It("passes a node that is cordoned", func() {
+			Expect(greenplumcluster.NodeStartedDraining.Update(event.UpdateEvent{ObjectOld: node, ObjectNew: cordonedNode})).To(BeTrue())
+		})
+		It("passes a node that is tainted NoExecute", func() {
+			taintedNode := node.DeepCopy()
+			taintedNode.Spec.Taints = []corev1.Taint{{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}}
+			Expect(greenplumcluster.NodeStartedDraining.Update(event.UpdateEvent{ObjectOld: node, ObjectNew: taintedNode})).To(BeTrue())
+		})
+		It("drops updates to a node that was already draining", func() {
+			updatedNode := cordonedNode.DeepCopy()
+			updatedNode.Labels = map[string]string{"updated": "true"}
+			Expect(greenplumcluster.NodeStartedDraining.Update(event.UpdateEvent{ObjectOld: cordonedNode, ObjectNew: updatedNode})).To(BeFalse())
+		})
+		It("drops updates to a node that is not draining", func() {
+			updatedNode := node.DeepCopy()
+			updatedNode.Labels = map[string]string{"updated": "true"}
+			Expect(greenplumcluster.NodeStartedDraining.Update(event.UpdateEvent{ObjectOld: node, ObjectNew: updatedNode})).To(BeFalse())
+		})
+		It("drops a node that is uncordoned", func() {
+			Expect(greenplumcluster.NodeStartedDraining.Update(event.UpdateEvent{ObjectOld: cordonedNode, ObjectNew: node})).To(BeFalse())
+		})
+		It("passes a node that is created cordoned", func() {
+			Expect(greenplumcluster.NodeStartedDraining.Create(event.CreateEvent{Object: cordonedNode})).To(BeTrue())
+			Expect(greenplumcluster.NodeStartedDraining.Create(event.CreateEvent{Object: node})).To(BeFalse())
+		})
+		It("drops deleted nodes", func() {
+			Expect(greenplumcluster.NodeStartedDraining.Delete(event.DeleteEvent{Object: cordonedNode})).To(BeFalse())
+		})
+	})
+
+	Describe("GreenplumClustersOnDrainingNode", func() {
+		createPod := func(namespace, name, cluster, nodeName string) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      name,
+					Labels: map[string]string{
+						"app":               "greenplum",
+						"greenplum-cluster": cluster,
+					},
+				},
+				Spec: corev1.PodSpec{NodeName: nodeName},
+			}
+			Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+		}
+		BeforeEach(func() {
+			createPod("ns-1", "segment-a-0", "cluster-1", "node-1")
+			createPod("ns-1", "segment-b-0", "cluster-1", "node-1")
+			createPod("ns-2", "segment-a-0", "cluster-2", "node-1")
+			createPod("ns-3", "segment-a-0", "cluster-3", "node-2")
+		})
+		It("enqueues each cluster with pods on the node once", func() {
+			Expect(greenplumcluster.GreenplumClustersOnDrainingNode(greenplumReconciler, cordonedNode)).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns-1", Name: "cluster-1"}},
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns-2", Name: "cluster-2"}},
+			))
+		})
+		It("does not enqueue clusters without pods on the node", func() {
+			otherNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}}
+			Expect(greenplumcluster.GreenplumClustersOnDrainingNode(greenplumReconciler, otherNode)).To(BeEmpty())
+		})
+	})
+})
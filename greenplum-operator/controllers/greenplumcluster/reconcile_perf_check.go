@@ -0,0 +1,163 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/perfcheckjob"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often to check on a running gpcheckperf job
+const perfCheckPollInterval = time.Minute
+
+// handlePerfCheck runs a gpcheckperf job every monitoring.perfCheck.interval, records the measured throughput
+// in status, and sets the PerformanceDegraded condition when a throughput is below its threshold.
+// It returns how long to wait before checking again, or zero if perfCheck is not set.
+func (r *GreenplumClusterReconciler) handlePerfCheck(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	if greenplumCluster.Spec.Monitoring == nil || greenplumCluster.Spec.Monitoring.PerfCheck == nil {
+		return 0, r.removePerfCheckStatus(ctx, greenplumCluster)
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	wasDegraded := meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionPerformanceDegraded)
+	untilNextCheck, err := r.checkPerf(ctx, greenplumCluster, activeMaster)
+	if err != nil {
+		return 0, err
+	}
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return untilNextCheck, nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return 0, fmt.Errorf("updating performance check status: %w", err)
+	}
+	condition := meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionPerformanceDegraded)
+	if condition != nil && condition.Status == metav1.ConditionTrue && !wasDegraded {
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "PerformanceDegraded", condition.Message)
+	}
+	return untilNextCheck, nil
+}
+
+func (r *GreenplumClusterReconciler) checkPerf(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	perfCheck := *greenplumCluster.Spec.Monitoring.PerfCheck
+	interval := perfcheckjob.DefaultInterval
+	if perfCheck.Interval != nil {
+		interval = perfCheck.Interval.Duration
+	}
+
+	jobKey := types.NamespacedName{
+		Namespace: greenplumCluster.Namespace,
+		Name:      fmt.Sprintf("%s-gpcheckperf-job", greenplumCluster.Name),
+	}
+	var existingJob batchv1.Job
+	if err := r.Get(ctx, jobKey, &existingJob); err == nil {
+		var lastRun time.Time
+		switch {
+		case existingJob.Status.Succeeded > 0:
+			completionTime := metav1.Now()
+			if existingJob.Status.CompletionTime != nil {
+				completionTime = *existingJob.Status.CompletionTime
+			}
+			if err := r.recordPerfCheckResults(ctx, greenplumCluster, &existingJob, completionTime); err != nil {
+				return 0, err
+			}
+			lastRun = completionTime.Time
+		case existingJob.Status.Failed > 0:
+			setPerformanceDegradedCondition(greenplumCluster, metav1.ConditionUnknown, "JobFailed",
+				fmt.Sprintf("gpcheckperf job %s failed; check its logs", existingJob.Name))
+			lastRun = existingJob.CreationTimestamp.Time
+			if existingJob.Status.StartTime != nil {
+				lastRun = existingJob.Status.StartTime.Time
+			}
+		default:
+			// still running
+			return perfCheckPollInterval, nil
+		}
+
+		if untilNextCheck := time.Until(lastRun.Add(interval)); untilNextCheck > 0 {
+			return untilNextCheck, nil
+		}
+		err = r.Delete(ctx, &existingJob, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil {
+			return 0, fmt.Errorf("deleting previous gpcheckperf job: %w", err)
+		}
+	} else if !apierrs.IsNotFound(err) {
+		return 0, fmt.Errorf("getting gpcheckperf job: %w", err)
+	}
+
+	activeMasterFQDN := fmt.Sprintf("%s.agent.%s.svc.cluster.local", activeMaster, greenplumCluster.Namespace)
+	job := perfcheckjob.GenerateJob(r.InstanceImage, activeMasterFQDN, perfCheck)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+
+	if err := ctrl.SetControllerReference(greenplumCluster, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return 0, err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return 0, fmt.Errorf("creating gpcheckperf job: %w", err)
+	}
+	r.Log.Info("started gpcheckperf", "job", job.Name)
+	return perfCheckPollInterval, nil
+}
+
+// recordPerfCheckResults compares the throughput that a successful job reported against the thresholds.
+// Status is left as it is if the job pod, with its termination message, is gone
+func (r *GreenplumClusterReconciler) recordPerfCheckResults(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, job *batchv1.Job, completionTime metav1.Time) error {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return fmt.Errorf("listing gpcheckperf job pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		results, ok := perfcheckjob.Results(pod)
+		if !ok {
+			continue
+		}
+		results.LastRunTime = &completionTime
+		greenplumCluster.Status.PerfCheck = &results
+		if degraded := perfcheckjob.BelowThresholds(*greenplumCluster.Spec.Monitoring.PerfCheck, results); len(degraded) > 0 {
+			setPerformanceDegradedCondition(greenplumCluster, metav1.ConditionTrue, "BelowThreshold", strings.Join(degraded, "; "))
+		} else {
+			setPerformanceDegradedCondition(greenplumCluster, metav1.ConditionFalse, "WithinThresholds",
+				"the last gpcheckperf run met every threshold")
+		}
+		break
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) removePerfCheckStatus(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	if greenplumCluster.Status.PerfCheck == nil &&
+		meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionPerformanceDegraded) == nil {
+		return nil
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.PerfCheck = nil
+	meta.RemoveStatusCondition(&greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionPerformanceDegraded)
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating performance check status: %w", err)
+	}
+	return nil
+}
+
+func setPerformanceDegradedCondition(greenplumCluster *greenplumv1.GreenplumCluster, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionPerformanceDegraded,
+		Status:             status,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
@@ -0,0 +1,245 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile performance checks for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		recorder            *record.FakeRecorder
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		jobKey              types.NamespacedName
+		jobCompletionTime   metav1.Time
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       &fake.PodExec{},
+			Recorder:      recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+			PerfCheck: &greenplumv1.GreenplumPerfCheckSpec{
+				Interval:         &metav1.Duration{Duration: 24 * time.Hour},
+				MinDiskWriteMBps: 200,
+				MinNetworkMBps:   1000,
+			},
+		}
+		jobKey = types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpcheckperf-job"}
+	})
+
+	var (
+		result       ctrl.Result
+		reconcileErr error
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		result, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	reconciledCluster := func() greenplumv1.GreenplumCluster {
+		var cluster greenplumv1.GreenplumCluster
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &cluster)).To(Succeed())
+		return cluster
+	}
+	perfCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster().Status.Conditions, greenplumv1.GreenplumClusterConditionPerformanceDegraded)
+	}
+	createFinishedJob := func(completedAgo time.Duration, terminationMessage string) {
+		jobCompletionTime = metav1.NewTime(time.Now().Add(-completedAgo).Truncate(time.Second))
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+			Status:     batchv1.JobStatus{Succeeded: 1, CompletionTime: &jobCompletionTime},
+		}
+		Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: jobKey.Namespace,
+				Name:      jobKey.Name + "-abcde",
+				Labels:    map[string]string{"job-name": jobKey.Name},
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "gpcheckperf",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: terminationMessage}},
+			}}},
+		}
+		Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+	}
+
+	When("perfCheck is not set", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Monitoring = nil
+			greenplumCluster.Status.PerfCheck = &greenplumv1.GreenplumPerfCheckStatus{DiskWriteMBps: 180}
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:               greenplumv1.GreenplumClusterConditionPerformanceDegraded,
+				Status:             metav1.ConditionTrue,
+				Reason:             "BelowThreshold",
+				LastTransitionTime: metav1.Now(),
+			}}
+		})
+		It("does not create a gpcheckperf job, and clears the previous results", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(MatchError(ContainSubstring("not found")))
+			Expect(reconciledCluster().Status.PerfCheck).To(BeNil())
+			Expect(perfCondition()).To(BeNil())
+		})
+	})
+
+	When("no check has run yet", func() {
+		It("creates a gpcheckperf job against the active master", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("greenplum-for-kubernetes:latest"))
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ConsistOf(
+				corev1.EnvVar{Name: "GPCHECKPERF_HOST", Value: "master-0.agent.test-ns.svc.cluster.local"},
+				corev1.EnvVar{Name: "GPCHECKPERF_DISK_FILE_SIZE", Value: "1024MB"},
+				corev1.EnvVar{Name: "GPCHECKPERF_NETWORK", Value: "true"},
+			))
+			Expect(job.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+			Expect(logBuf).To(gbytes.Say("started gpcheckperf"))
+		})
+		It("checks on the job again soon", func() {
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+		})
+	})
+
+	When("the last check met every threshold", func() {
+		BeforeEach(func() {
+			createFinishedJob(time.Hour, "disk_write_mbps=250.5\ndisk_read_mbps=400.1\nnetwork_mbps=1100.9\n")
+		})
+		It("records the throughput and clears the condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			perfCheck := reconciledCluster().Status.PerfCheck
+			Expect(perfCheck).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"DiskWriteMBps": Equal(int32(250)),
+				"DiskReadMBps":  Equal(int32(400)),
+				"NetworkMBps":   Equal(int32(1100)),
+			})))
+			Expect(perfCheck.LastRunTime.Time).To(BeTemporally("==", jobCompletionTime.Time))
+			Expect(perfCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionFalse),
+				"Reason":  Equal("WithinThresholds"),
+				"Message": Equal("the last gpcheckperf run met every threshold"),
+			})))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+		It("waits for the rest of the interval before checking again", func() {
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Status.Succeeded).To(Equal(int32(1)))
+			Expect(result.RequeueAfter).To(BeNumerically("~", 23*time.Hour, time.Minute))
+		})
+	})
+
+	When("the last check measured degraded throughput", func() {
+		BeforeEach(func() {
+			createFinishedJob(time.Hour, "disk_write_mbps=180.75\ndisk_read_mbps=400.1\nnetwork_mbps=900\n")
+		})
+		It("sets the condition and emits a Warning event", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := perfCondition()
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionTrue),
+				"Reason": Equal("BelowThreshold"),
+				"Message": Equal("disk write throughput 180 MB/s is below 200 MB/s; " +
+					"network throughput 900 MB/s is below 1000 MB/s"),
+			})))
+			Expect(recorder.Events).To(Receive(Equal("Warning PerformanceDegraded " + condition.Message)))
+		})
+
+		When("the condition was already reported", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:               greenplumv1.GreenplumClusterConditionPerformanceDegraded,
+					Status:             metav1.ConditionTrue,
+					Reason:             "BelowThreshold",
+					Message:            "disk write throughput 150 MB/s is below 200 MB/s",
+					LastTransitionTime: metav1.Now(),
+				}}
+			})
+			It("updates the message without emitting another event", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(perfCondition().Message).To(HavePrefix("disk write throughput 180 MB/s"))
+				Expect(recorder.Events).NotTo(Receive())
+			})
+		})
+	})
+
+	When("the last check ran longer ago than the interval", func() {
+		BeforeEach(func() {
+			createFinishedJob(25*time.Hour, "disk_write_mbps=250\nnetwork_mbps=1100\n")
+		})
+		It("replaces the job with a new one", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Status.Succeeded).To(BeZero())
+			Expect(job.Spec.Template.Spec.Containers[0].Name).To(Equal("gpcheckperf"))
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+		})
+	})
+
+	When("the last check failed", func() {
+		BeforeEach(func() {
+			startTime := metav1.NewTime(time.Now().Add(-5 * time.Minute))
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+				Status:     batchv1.JobStatus{Failed: 1, StartTime: &startTime},
+			}
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+		})
+		It("reports the performance as unknown", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(perfCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionUnknown),
+				"Reason":  Equal("JobFailed"),
+				"Message": Equal("gpcheckperf job my-greenplum-gpcheckperf-job failed; check its logs"),
+			})))
+		})
+	})
+
+	When("a check is running", func() {
+		BeforeEach(func() {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name},
+				Status:     batchv1.JobStatus{Active: 1},
+			}
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+		})
+		It("leaves it alone and checks again soon", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Status.Active).To(Equal(int32(1)))
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+		})
+	})
+})
@@ -0,0 +1,96 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/pghba"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handlePgHbaEntries keeps the records for spec.auth.ldap and spec.config.pgHbaEntries at the end of pg_hba.conf on
+// the master and standby, between markers so that the entries written by the instance stay first and take precedence.
+// The configuration is reloaded after a change, and status.pgHbaEntriesHash records the ruleset the active master
+// has loaded.
+func (r *GreenplumClusterReconciler) handlePgHbaEntries(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	entries, err := r.ldapPgHbaEntries(ctx, greenplumCluster)
+	if err != nil {
+		return err
+	}
+	desired := pghba.Block(append(entries, greenplumCluster.Spec.Config.PgHbaEntries...))
+
+	if err := r.applyPgHbaEntries(greenplumCluster.Namespace, activeMaster, desired, "gpstop -u -a"); err != nil {
+		return err
+	}
+	if greenplumCluster.Spec.MasterAndStandby.Standby == "yes" {
+		// The standby does not replicate pg_hba.conf, but needs the same entries once it is promoted
+		standby := otherMasterPod(activeMaster)
+		if err := r.applyPgHbaEntries(greenplumCluster.Namespace, standby, desired, "pg_ctl reload -D /greenplum/data-1"); err != nil {
+			r.Log.Error(err, "unable to apply pg_hba entries on the standby master", "pod", standby)
+		}
+	}
+
+	hash := pghba.Hash(desired)
+	if greenplumCluster.Status.PgHbaEntriesHash == hash {
+		return nil
+	}
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.PgHbaEntriesHash = hash
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating pg_hba entries hash: %w", err)
+	}
+	return nil
+}
+
+// applyPgHbaEntries replaces the operator's block in pg_hba.conf on podName with desired, and runs reloadCommand,
+// unless the block is already up to date
+func (r *GreenplumClusterReconciler) applyPgHbaEntries(namespace, podName, desired, reloadCommand string) error {
+	current, err := r.readPgHbaEntries(namespace, podName)
+	if err != nil {
+		return err
+	}
+	if current == desired {
+		return nil
+	}
+
+	command := fmt.Sprintf("sed -i '/^%s$/,/^%s$/d' %s", pghba.BeginMarker, pghba.EndMarker, pghba.Path)
+	if desired != "" {
+		// Entries are validated by the admission webhook to be single lines; quote them for the shell
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSuffix(desired, "\n"), "\n") {
+			lines = append(lines, "'"+strings.ReplaceAll(line, "'", `'\''`)+"'")
+		}
+		command += fmt.Sprintf(" && printf '%%s\\n' %s >> %s", strings.Join(lines, " "), pghba.Path)
+	}
+	writeCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && " + command + " && " + reloadCommand,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(writeCommand, namespace, podName, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("writing pg_hba entries on %s: %w: %s", podName, err, stderrBuf.String())
+	}
+	r.Log.Info("applied pg_hba entries", "pod", podName)
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) readPgHbaEntries(namespace, podName string) (string, error) {
+	readCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf("sed -n '/^%s$/,/^%s$/p' %s", pghba.BeginMarker, pghba.EndMarker, pghba.Path),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(readCommand, namespace, podName, stdoutBuf, stderrBuf); err != nil {
+		return "", fmt.Errorf("reading pg_hba entries on %s: %w: %s", podName, err, stderrBuf.String())
+	}
+	return stdoutBuf.String(), nil
+}
@@ -0,0 +1,156 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/pghba"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+)
+
+var _ = Describe("Reconcile pg_hba entries for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var (
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	pgHbaCommands := func() []string {
+		var commands []string
+		for _, command := range podExec.RecordedCommands {
+			if strings.Contains(command, pghba.Path) {
+				commands = append(commands, command)
+			}
+		}
+		return commands
+	}
+
+	const entriesBlock = "# BEGIN greenplum-operator pgHbaEntries\n" +
+		"host all app_user 10.0.0.0/16 md5\n" +
+		"hostssl all 'o''brien' 10.1.0.0/16 cert\n" +
+		"# END greenplum-operator pgHbaEntries\n"
+
+	When("no entries are listed and none were applied", func() {
+		It("does not change pg_hba.conf", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(pgHbaCommands()).To(BeEmpty())
+			Expect(reconciledCluster.Status.PgHbaEntriesHash).To(BeEmpty())
+		})
+	})
+
+	When("entries are listed", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.PgHbaEntries = []string{
+				"host all app_user 10.0.0.0/16 md5",
+				"hostssl all 'o''brien' 10.1.0.0/16 cert",
+			}
+		})
+		It("appends them to pg_hba.conf on the active master and reloads the configuration", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.CalledPodName).To(Equal("master-0"))
+			Expect(pgHbaCommands()).To(Equal([]string{
+				`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && ` +
+					`sed -i '/^# BEGIN greenplum-operator pgHbaEntries$/,/^# END greenplum-operator pgHbaEntries$/d' /greenplum/data-1/pg_hba.conf && ` +
+					`printf '%s\n' '# BEGIN greenplum-operator pgHbaEntries' 'host all app_user 10.0.0.0/16 md5' ` +
+					`'hostssl all '\''o'\'''\''brien'\'' 10.1.0.0/16 cert' '# END greenplum-operator pgHbaEntries' >> /greenplum/data-1/pg_hba.conf && ` +
+					`gpstop -u -a`,
+			}))
+			Expect(logBuf).To(gbytes.Say(`"msg":"applied pg_hba entries","pod":"master-0"`))
+		})
+		It("records the hash of the ruleset in status", func() {
+			Expect(reconciledCluster.Status.PgHbaEntriesHash).To(Equal(pghba.Hash(entriesBlock)))
+		})
+
+		When("the entries are already applied", func() {
+			BeforeEach(func() {
+				podExec.PgHbaEntries = entriesBlock
+			})
+			It("does not change pg_hba.conf", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(pgHbaCommands()).To(BeEmpty())
+				Expect(reconciledCluster.Status.PgHbaEntriesHash).To(Equal(pghba.Hash(entriesBlock)))
+			})
+		})
+
+		When("there is a standby master", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.MasterAndStandby.Standby = "yes"
+			})
+			It("applies them on the standby too, reloading it with pg_ctl", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				commands := pgHbaCommands()
+				Expect(commands).To(HaveLen(2))
+				Expect(commands[0]).To(HaveSuffix("&& gpstop -u -a"))
+				Expect(commands[1]).To(HaveSuffix("&& pg_ctl reload -D /greenplum/data-1"))
+				Expect(logBuf).To(gbytes.Say(`"msg":"applied pg_hba entries","pod":"master-1"`))
+			})
+		})
+
+		When("writing pg_hba.conf fails", func() {
+			BeforeEach(func() {
+				podExec.ErrorMsgOnCommand = "injected error"
+			})
+			It("returns an error and does not update the hash", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("unable to apply pg_hba entries: writing pg_hba entries on master-0: injected error")))
+				Expect(reconciledCluster.Status.PgHbaEntriesHash).To(BeEmpty())
+			})
+		})
+	})
+
+	When("the entries are removed from the spec", func() {
+		BeforeEach(func() {
+			podExec.PgHbaEntries = entriesBlock
+			greenplumCluster.Status.PgHbaEntriesHash = pghba.Hash(entriesBlock)
+		})
+		It("removes them from pg_hba.conf and clears the hash", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(pgHbaCommands()).To(Equal([]string{
+				`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && ` +
+					`sed -i '/^# BEGIN greenplum-operator pgHbaEntries$/,/^# END greenplum-operator pgHbaEntries$/d' /greenplum/data-1/pg_hba.conf && ` +
+					`gpstop -u -a`,
+			}))
+			Expect(reconciledCluster.Status.PgHbaEntriesHash).To(BeEmpty())
+		})
+	})
+
+	When("reading pg_hba.conf fails", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.PgHbaEntries = []string{"host all app_user 10.0.0.0/16 md5"}
+			podExec.PgHbaEntriesErr = errors.New("injected error")
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("unable to apply pg_hba entries: reading pg_hba entries on master-0: injected error")))
+		})
+	})
+})
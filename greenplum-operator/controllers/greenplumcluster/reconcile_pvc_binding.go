@@ -0,0 +1,116 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// handlePVCBinding surfaces the reason a pending PVC cannot be bound, as reported by
+// the PV controller or provisioner in a Warning event, on the GreenplumCluster status.
+func (r *GreenplumClusterReconciler) handlePVCBinding(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	var pvcList corev1.PersistentVolumeClaimList
+	labelMatcher := client.MatchingLabels{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": greenplumCluster.Name,
+	}
+	if err := r.List(ctx, &pvcList, labelMatcher, client.InNamespace(greenplumCluster.Namespace)); err != nil {
+		return fmt.Errorf("listing PVCs: %w", err)
+	}
+
+	var pendingPVCs []string
+	for _, pvc := range pvcList.Items {
+		if pvc.Status.Phase == corev1.ClaimPending {
+			pendingPVCs = append(pendingPVCs, pvc.Name)
+		}
+	}
+
+	var failures []string
+	var reason string
+	sort.Strings(pendingPVCs)
+	for _, pvcName := range pendingPVCs {
+		// Events are not cached, so they are read from the apiserver, and only those of the PVC
+		var eventList corev1.EventList
+		if err := r.APIReader.List(ctx, &eventList,
+			client.InNamespace(greenplumCluster.Namespace),
+			client.MatchingFields{
+				"involvedObject.kind": "PersistentVolumeClaim",
+				"involvedObject.name": pvcName,
+			}); err != nil {
+			return fmt.Errorf("listing PVC events: %w", err)
+		}
+		event := latestWarningEvent(eventList.Items)
+		if event == nil {
+			// e.g. still waiting for the first consumer, which is not a failure
+			continue
+		}
+		if reason == "" {
+			reason = event.Reason
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", pvcName, event.Message))
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if len(failures) > 0 {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionPVCBindingFailed,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             reason,
+			Message:            strings.Join(failures, "; "),
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionPVCBindingFailed) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionPVCBindingFailed,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "NoBindingFailures",
+			Message:            "no PVCs are failing to bind",
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if len(failures) > 0 {
+			r.Log.Info("detected PVC binding failures", "failures", failures)
+		}
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return fmt.Errorf("updating PVC binding condition: %w", err)
+		}
+	}
+	return nil
+}
+
+func latestWarningEvent(events []corev1.Event) *corev1.Event {
+	var latest *corev1.Event
+	for i := range events {
+		event := &events[i]
+		if event.Type != corev1.EventTypeWarning {
+			continue
+		}
+		if latest == nil || latest.LastTimestamp.Before(&event.LastTimestamp) {
+			latest = event
+		}
+	}
+	return latest
+}
+
+// greenplumClusterForPVC enqueues the GreenplumCluster of a PVC, so that the binding condition follows the PVC.
+// The PVCs are created by the statefulsets from their volumeClaimTemplates, so they are not owned by the cluster
+func (r *GreenplumClusterReconciler) greenplumClusterForPVC(obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels["app"] != greenplumv1.AppName || labels["greenplum-cluster"] == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: labels["greenplum-cluster"]},
+	}}
+}
@@ -0,0 +1,221 @@
+package greenplumcluster_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Reconcile PVC binding failures for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			APIReader:  reactiveClient,
+			Log:        gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    &fake.PodExec{},
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var reconcileErr error
+	var reconciledCluster greenplumv1.GreenplumCluster
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	createPVC := func(name string, phase corev1.PersistentVolumeClaimPhase) {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespaceName,
+				Name:      name,
+				Labels: map[string]string{
+					"app":               "greenplum",
+					"greenplum-cluster": clusterName,
+					"type":              "segment-a",
+				},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+		Expect(reactiveClient.Create(ctx, pvc)).To(Succeed())
+	}
+
+	createPVCEvent := func(name, pvcName, eventType, reason, message string, lastTimestamp time.Time) {
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: name},
+			InvolvedObject: corev1.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: namespaceName,
+				Name:      pvcName,
+			},
+			Type:          eventType,
+			Reason:        reason,
+			Message:       message,
+			LastTimestamp: metav1.NewTime(lastTimestamp),
+		}
+		Expect(reactiveClient.Create(ctx, event)).To(Succeed())
+	}
+
+	findCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+			greenplumv1.GreenplumClusterConditionPVCBindingFailed)
+	}
+
+	When("all PVCs are bound", func() {
+		BeforeEach(func() {
+			createPVC("my-greenplum-pgdata-segment-a-0", corev1.ClaimBound)
+		})
+		It("does not set a binding failure condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(findCondition()).To(BeNil())
+		})
+	})
+
+	When("a PVC is pending without any warnings", func() {
+		BeforeEach(func() {
+			createPVC("my-greenplum-pgdata-segment-a-0", corev1.ClaimPending)
+			createPVCEvent("waiting", "my-greenplum-pgdata-segment-a-0", corev1.EventTypeNormal,
+				"WaitForFirstConsumer", "waiting for first consumer to be created before binding", time.Now())
+		})
+		It("does not set a binding failure condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(findCondition()).To(BeNil())
+		})
+	})
+
+	When("a PVC fails to bind", func() {
+		BeforeEach(func() {
+			createPVC("my-greenplum-pgdata-segment-a-0", corev1.ClaimPending)
+			createPVC("my-greenplum-pgdata-segment-a-1", corev1.ClaimBound)
+			createPVCEvent("old-failure", "my-greenplum-pgdata-segment-a-0", corev1.EventTypeWarning,
+				"ProvisioningFailed", "an older failure", time.Now().Add(-time.Hour))
+			createPVCEvent("failure", "my-greenplum-pgdata-segment-a-0", corev1.EventTypeWarning,
+				"ProvisioningFailed", `storageclass.storage.k8s.io "standard" not found`, time.Now())
+			createPVCEvent("other-pvc", "some-other-pvc", corev1.EventTypeWarning,
+				"FailedBinding", "no persistent volumes available for this claim", time.Now())
+		})
+		It("reports the latest failure reason in the status", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := findCondition()
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal("ProvisioningFailed"))
+			Expect(condition.Message).To(Equal(`my-greenplum-pgdata-segment-a-0: storageclass.storage.k8s.io "standard" not found`))
+		})
+
+		When("patching the condition fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("patch", "greenplumclusters", func(action testing.Action) (bool, runtime.Object, error) {
+					if !bytes.Contains(action.(testing.PatchAction).GetPatch(), []byte(greenplumv1.GreenplumClusterConditionPVCBindingFailed)) {
+						return false, nil, nil
+					}
+					return true, nil, errors.New("injected error")
+				})
+			})
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError("updating PVC binding condition: injected error"))
+			})
+		})
+	})
+
+	When("a previously failing PVC has been bound", func() {
+		BeforeEach(func() {
+			createPVC("my-greenplum-pgdata-segment-a-0", corev1.ClaimBound)
+			greenplumCluster.Status.Conditions = []metav1.Condition{
+				{
+					Type:               greenplumv1.GreenplumClusterConditionPVCBindingFailed,
+					Status:             metav1.ConditionTrue,
+					Reason:             "ProvisioningFailed",
+					Message:            "my-greenplum-pgdata-segment-a-0: failed",
+					LastTransitionTime: metav1.Now(),
+				},
+			}
+		})
+		It("clears the binding failure condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			condition := findCondition()
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("NoBindingFailures"))
+		})
+	})
+
+	When("a PVC is pending", func() {
+		BeforeEach(func() {
+			createPVC("my-greenplum-pgdata-segment-a-0", corev1.ClaimPending)
+		})
+		It("lists only the events of that PVC", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var restrictions []string
+			for _, action := range reactiveClient.Actions() {
+				if action.Matches("list", "events") {
+					restrictions = append(restrictions, action.(testing.ListAction).GetListRestrictions().Fields.String())
+				}
+			}
+			Expect(restrictions).To(ConsistOf("involvedObject.kind=PersistentVolumeClaim,involvedObject.name=my-greenplum-pgdata-segment-a-0"))
+		})
+
+		When("listing its events fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("list", "events", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("injected error")
+				})
+			})
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError("listing PVC events: injected error"))
+			})
+		})
+	})
+
+	When("listing PVCs fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("list", "persistentvolumeclaims", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError("listing PVCs: injected error"))
+		})
+	})
+
+	Describe("GreenplumClusterForPVC", func() {
+		pvc := func(pvcLabels map[string]string) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespaceName,
+				Name:      "my-greenplum-pgdata-segment-a-0",
+				Labels:    pvcLabels,
+			}}
+		}
+		It("enqueues the cluster of a Greenplum PVC", func() {
+			Expect(greenplumcluster.GreenplumClusterForPVC(greenplumReconciler,
+				pvc(map[string]string{"app": "greenplum", "greenplum-cluster": clusterName}))).
+				To(ConsistOf(greenplumClusterRequest))
+		})
+		It("ignores other PVCs", func() {
+			Expect(greenplumcluster.GreenplumClusterForPVC(greenplumReconciler, pvc(map[string]string{"app": "other"}))).To(BeEmpty())
+			Expect(greenplumcluster.GreenplumClusterForPVC(greenplumReconciler, pvc(map[string]string{"app": "greenplum"}))).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,69 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const readinessCheckRetryInterval = 10 * time.Second
+
+// handleReadinessCheck runs spec.readinessQuery on the active master of a Pending cluster, and reports
+// whether the cluster is ready to become Running. A failing query is recorded in the ReadinessCheckFailed
+// condition rather than returned, so that it is retried.
+func (r *GreenplumClusterReconciler) handleReadinessCheck(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (bool, error) {
+	if greenplumCluster.Spec.ReadinessQuery == "" {
+		return true, nil
+	}
+	// The query is quoted for the shell; psql stops at the first error so that any failing statement fails the check
+	readinessCheckCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf("source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d gpadmin -v ON_ERROR_STOP=1 -tAc '%s'",
+			strings.ReplaceAll(greenplumCluster.Spec.ReadinessQuery, "'", `'\''`)),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	checkErr := r.PodExec.Execute(readinessCheckCommand, greenplumCluster.Namespace, activeMaster, stdoutBuf, stderrBuf)
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if checkErr != nil {
+		message := strings.TrimSpace(stderrBuf.String())
+		if message == "" {
+			message = checkErr.Error()
+		}
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionReadinessCheckFailed,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "QueryFailed",
+			Message:            "readinessQuery failed: " + message,
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionReadinessCheckFailed) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionReadinessCheckFailed,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "QuerySucceeded",
+			Message:            "readinessQuery succeeded",
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if checkErr != nil {
+			r.Log.Info("readiness check failed; cluster stays Pending", "error", checkErr.Error())
+		}
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return false, fmt.Errorf("updating readiness check condition: %w", err)
+		}
+	}
+	return checkErr == nil, nil
+}
@@ -0,0 +1,133 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile readiness check for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			PodExec:       podExec,
+			InstanceImage: "greenplum-for-kubernetes:greenplumv1.0",
+			OperatorImage: "greenplum-operator:greenplumv1.0",
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.ReadinessQuery = "SELECT count(*) FROM gp_dist_random('gp_id')"
+		greenplumCluster.Status = greenplumv1.GreenplumClusterStatus{
+			InstanceImage:   greenplumReconciler.InstanceImage,
+			OperatorVersion: greenplumReconciler.OperatorImage,
+			Phase:           greenplumv1.GreenplumClusterPhasePending,
+		}
+	})
+
+	var (
+		reconcileErr      error
+		reconcileResult   ctrl.Result
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	const readinessCheckCommand = `/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && ` +
+		`psql -U gpadmin -d gpadmin -v ON_ERROR_STOP=1 -tAc 'SELECT count(*) FROM gp_dist_random('\''gp_id'\'')'`
+
+	When("the readiness query succeeds", func() {
+		It("runs the query on the active master and sets the cluster Running", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).To(ContainElement(readinessCheckCommand))
+			Expect(reconciledCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionReadinessCheckFailed)).To(BeNil())
+		})
+
+		When("the readiness query failed before", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:    greenplumv1.GreenplumClusterConditionReadinessCheckFailed,
+					Status:  metav1.ConditionTrue,
+					Reason:  "QueryFailed",
+					Message: "readinessQuery failed: ERROR",
+				}}
+			})
+			It("clears the condition", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconciledCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+				Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+					greenplumv1.GreenplumClusterConditionReadinessCheckFailed)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionFalse),
+					"Reason": Equal("QuerySucceeded"),
+				})))
+			})
+		})
+	})
+
+	When("the readiness query fails", func() {
+		BeforeEach(func() {
+			podExec.ErrorMsgOnCommand = `ERROR:  relation "gp_id" does not exist`
+		})
+		It("keeps the cluster Pending and retries later", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhasePending))
+			Expect(reconcileResult).To(Equal(ctrl.Result{RequeueAfter: 10 * time.Second}))
+		})
+		It("surfaces the failure in the ReadinessCheckFailed condition", func() {
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionReadinessCheckFailed)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("QueryFailed"),
+				"Message": Equal(`readinessQuery failed: ERROR:  relation "gp_id" does not exist`),
+			})))
+			Expect(logBuf).To(gbytes.Say("readiness check failed; cluster stays Pending"))
+		})
+	})
+
+	When("no readiness query is configured", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.ReadinessQuery = ""
+		})
+		It("sets the cluster Running without a check", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ON_ERROR_STOP")))
+			Expect(reconciledCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+		})
+	})
+
+	When("the cluster is already Running", func() {
+		BeforeEach(func() {
+			greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+		})
+		It("does not run the readiness query", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(readinessCheckCommand))
+		})
+	})
+})
@@ -0,0 +1,155 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	readOnlyFilesystemMessage = "Read-only file system"
+	noSpaceLeftMessage        = "No space left on device"
+
+	// A segment pod is only fenced once it has been up this long, so that a pod whose replacement still finds
+	// its volume read-only is not restarted on every reconcile
+	readOnlyFenceBackoff = 10 * time.Minute
+)
+
+// handleReadOnlyVolumes reports the pods whose data volume is read-only in the DataVolumeReadOnly condition. With
+// fenceReadOnly, it deletes such segment pods so that they are started again, at most once per readOnlyFenceBackoff,
+// and returns how long to wait before fencing a pod that is held back.
+func (r *GreenplumClusterReconciler) handleReadOnlyVolumes(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (time.Duration, error) {
+	var readOnlyPods []string
+	for _, podName := range greenplumPodNames(greenplumCluster) {
+		if r.isDataVolumeReadOnly(greenplumCluster.Namespace, podName) {
+			readOnlyPods = append(readOnlyPods, podName)
+		}
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if len(readOnlyPods) > 0 {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionDataVolumeReadOnly,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "ReadOnlyFilesystem",
+			Message:            "data volume is read-only on pods: " + strings.Join(readOnlyPods, ", "),
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionDataVolumeReadOnly) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionDataVolumeReadOnly,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "DataVolumesWritable",
+			Message:            "all data volumes are writable",
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if len(readOnlyPods) > 0 {
+			r.Log.Info("detected read-only data volumes", "pods", readOnlyPods)
+			r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "DataVolumeReadOnly",
+				"data volume is read-only on pods: "+strings.Join(readOnlyPods, ", "))
+		}
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return 0, fmt.Errorf("updating read-only volume condition: %w", err)
+		}
+	}
+
+	if greenplumCluster.Spec.Segments.FenceReadOnly != "yes" {
+		return 0, nil
+	}
+	var untilNextFence time.Duration
+	for _, podName := range readOnlyPods {
+		if !strings.HasPrefix(podName, "segment-") {
+			r.Log.Info("not fencing master pod with read-only data volume", "pod", podName)
+			continue
+		}
+		var pod corev1.Pod
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: podName}, &pod); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return 0, fmt.Errorf("getting segment pod %s: %w", podName, err)
+		}
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if up := time.Since(pod.CreationTimestamp.Time); up < readOnlyFenceBackoff {
+			r.Log.Info("not fencing segment pod with read-only data volume yet", "pod", podName, "up", up.Round(time.Second))
+			untilNextFence = earliestRequeue(untilNextFence, readOnlyFenceBackoff-up)
+			continue
+		}
+		r.Log.Info("fencing segment pod with read-only data volume", "pod", podName)
+		if err := r.Delete(ctx, &pod); err != nil && !apierrs.IsNotFound(err) {
+			return 0, fmt.Errorf("fencing segment pod %s: %w", podName, err)
+		}
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "FencedReadOnlyPod",
+			fmt.Sprintf("deleted segment pod %s, whose data volume is read-only", podName))
+	}
+	return untilNextFence, nil
+}
+
+// isDataVolumeReadOnly only reports true when the write fails because the
+// filesystem is read-only; pods that are not up yet are ignored.
+func (r *GreenplumClusterReconciler) isDataVolumeReadOnly(namespace, podName string) bool {
+	return r.dataVolumeFailure(namespace, podName) == "ReadOnlyFilesystem"
+}
+
+// dataVolumeFailure returns ReadOnlyFilesystem or NoSpaceLeftOnDevice when writing to the data
+// volume of the pod fails for that reason, and "" otherwise, including when the pod is not up.
+// The result is reused for podProbeInterval.
+func (r *GreenplumClusterReconciler) dataVolumeFailure(namespace, podName string) string {
+	failure, err := r.podProbes.probe(namespace+"/"+podName+"/data-volume", func() (string, error) {
+		rwCheckCommand := []string{
+			"/bin/bash",
+			"-c",
+			"--",
+			"touch /greenplum/.operator-rw-check && rm -f /greenplum/.operator-rw-check",
+		}
+		stderrBuf := &bytes.Buffer{}
+		err := r.PodExec.Execute(rwCheckCommand, namespace, podName, ioutil.Discard, stderrBuf)
+		if err == nil {
+			return "", nil
+		}
+		r.Log.V(1).Info("data volume write check failed", "pod", podName, "error", err, "stderr", stderrBuf.String())
+		switch {
+		case strings.Contains(stderrBuf.String(), readOnlyFilesystemMessage):
+			return "ReadOnlyFilesystem", nil
+		case strings.Contains(stderrBuf.String(), noSpaceLeftMessage):
+			return "NoSpaceLeftOnDevice", nil
+		}
+		return "", err
+	})
+	if err != nil {
+		return ""
+	}
+	return failure
+}
+
+func greenplumPodNames(greenplumCluster *greenplumv1.GreenplumCluster) []string {
+	podNames := []string{"master-0"}
+	if greenplumCluster.Spec.MasterAndStandby.Standby == "yes" {
+		podNames = append(podNames, "master-1")
+	}
+	for i := int32(0); i < greenplumCluster.Spec.Segments.PrimarySegmentCount; i++ {
+		podNames = append(podNames, fmt.Sprintf("segment-a-%d", i))
+	}
+	if greenplumCluster.Spec.Segments.Mirrors == "yes" {
+		for i := int32(0); i < greenplumCluster.Spec.Segments.PrimarySegmentCount; i++ {
+			podNames = append(podNames, fmt.Sprintf("segment-b-%d", i))
+		}
+	}
+	return podNames
+}
@@ -0,0 +1,246 @@
+package greenplumcluster_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile read-only data volumes for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.WithValue(context.Background(), struct{ key string }{"test"}, CurrentGinkgoTestDescription().TestText)
+		logBuf = gbytes.NewBuffer()
+
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+			Recorder:   recorder,
+		}
+
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+
+		for _, podName := range []string{"master-0", "segment-a-0", "segment-b-0"} {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: podName}}
+			Expect(reactiveClient.Create(context.TODO(), pod)).To(Succeed())
+		}
+	})
+
+	var reconcileErr error
+	var reconcileResult ctrl.Result
+	var reconciledCluster greenplumv1.GreenplumCluster
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(context.TODO(), greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	podExists := func(podName string) bool {
+		var pod corev1.Pod
+		err := reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: podName}, &pod)
+		if apierrs.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	When("all data volumes are writable", func() {
+		It("succeeds", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+		})
+		It("does not set a read-only condition", func() {
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionDataVolumeReadOnly)).To(BeNil())
+		})
+	})
+
+	When("a segment data volume is read-only", func() {
+		BeforeEach(func() {
+			podExec.ReadOnlyPods = []string{"segment-b-0"}
+		})
+		It("succeeds", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+		})
+		It("sets the read-only condition", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionDataVolumeReadOnly)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal("ReadOnlyFilesystem"))
+			Expect(condition.Message).To(Equal("data volume is read-only on pods: segment-b-0"))
+		})
+		It("emits a warning event", func() {
+			Expect(recorder.Events).To(Receive(Equal("Warning DataVolumeReadOnly data volume is read-only on pods: segment-b-0")))
+		})
+		It("does not fence the segment by default", func() {
+			Expect(podExists("segment-b-0")).To(BeTrue())
+		})
+		When("the read-only condition already lists the pod", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{
+					{
+						Type:               greenplumv1.GreenplumClusterConditionDataVolumeReadOnly,
+						Status:             metav1.ConditionTrue,
+						Reason:             "ReadOnlyFilesystem",
+						Message:            "data volume is read-only on pods: segment-b-0",
+						LastTransitionTime: metav1.Now(),
+					},
+				}
+			})
+			It("does not emit the warning event again", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(recorder.Events).NotTo(Receive(HavePrefix("Warning DataVolumeReadOnly")))
+			})
+		})
+
+		When("fenceReadOnly is yes", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.FenceReadOnly = "yes"
+			})
+			It("deletes the read-only segment pod", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExists("segment-b-0")).To(BeFalse())
+				Expect(podExists("segment-a-0")).To(BeTrue())
+				Expect(recorder.Events).To(Receive(HavePrefix("Warning DataVolumeReadOnly")))
+				Expect(recorder.Events).To(Receive(Equal("Warning FencedReadOnlyPod deleted segment pod segment-b-0, whose data volume is read-only")))
+			})
+			When("the segment pod was started recently", func() {
+				BeforeEach(func() {
+					var pod corev1.Pod
+					Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-b-0"}, &pod)).To(Succeed())
+					pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+					Expect(reactiveClient.Update(ctx, &pod)).To(Succeed())
+				})
+				It("does not fence it again until it has been up for the backoff", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(podExists("segment-b-0")).To(BeTrue())
+					Expect(recorder.Events).NotTo(Receive(HavePrefix("Warning FencedReadOnlyPod")))
+					Expect(logBuf).To(gbytes.Say("not fencing segment pod with read-only data volume yet"))
+					Expect(reconcileResult.RequeueAfter).To(BeNumerically("~", 9*time.Minute, 5*time.Second))
+				})
+			})
+			When("getting the pod fails", func() {
+				BeforeEach(func() {
+					reactiveClient.PrependReactor("get", "pods", func(action testing.Action) (bool, runtime.Object, error) {
+						return true, nil, errors.New("injected error")
+					})
+				})
+				It("returns an error", func() {
+					Expect(reconcileErr).To(MatchError("getting segment pod segment-b-0: injected error"))
+				})
+			})
+			When("deleting the pod fails", func() {
+				BeforeEach(func() {
+					reactiveClient.PrependReactor("delete", "pods", func(action testing.Action) (bool, runtime.Object, error) {
+						return true, nil, errors.New("injected error")
+					})
+				})
+				It("returns an error", func() {
+					Expect(reconcileErr).To(MatchError("fencing segment pod segment-b-0: injected error"))
+				})
+			})
+		})
+	})
+
+	When("the pod probes are reused", func() {
+		BeforeEach(func() {
+			greenplumReconciler.EnablePodProbes()
+			podExec.ReadOnlyPods = []string{"segment-b-0"}
+		})
+		It("checks the data volume of each pod once within the probe interval", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			checks := podExec.ReadOnlyChecks
+			Expect(checks).To(Equal(3), "one check of master-0, segment-a-0 and segment-b-0 each")
+
+			_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podExec.ReadOnlyChecks).To(Equal(checks))
+			Expect(meta.IsStatusConditionTrue(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionDataVolumeReadOnly)).To(BeTrue())
+		})
+	})
+
+	When("the master data volume is read-only and fenceReadOnly is yes", func() {
+		BeforeEach(func() {
+			podExec.ReadOnlyPods = []string{"master-0"}
+			greenplumCluster.Spec.Segments.FenceReadOnly = "yes"
+		})
+		It("sets the read-only condition", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionDataVolumeReadOnly)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(Equal("data volume is read-only on pods: master-0"))
+		})
+		It("does not fence the master", func() {
+			Expect(podExists("master-0")).To(BeTrue())
+		})
+	})
+
+	When("a previously read-only data volume has recovered", func() {
+		BeforeEach(func() {
+			greenplumCluster.Status.Conditions = []metav1.Condition{
+				{
+					Type:               greenplumv1.GreenplumClusterConditionDataVolumeReadOnly,
+					Status:             metav1.ConditionTrue,
+					Reason:             "ReadOnlyFilesystem",
+					Message:            "data volume is read-only on pods: segment-a-0",
+					LastTransitionTime: metav1.Now(),
+				},
+			}
+		})
+		It("clears the read-only condition", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionDataVolumeReadOnly)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("DataVolumesWritable"))
+		})
+	})
+
+	When("patching the condition fails", func() {
+		BeforeEach(func() {
+			podExec.ReadOnlyPods = []string{"segment-a-0"}
+			reactiveClient.PrependReactor("patch", "greenplumclusters", func(action testing.Action) (bool, runtime.Object, error) {
+				if !bytes.Contains(action.(testing.PatchAction).GetPatch(), []byte(greenplumv1.GreenplumClusterConditionDataVolumeReadOnly)) {
+					return false, nil, nil
+				}
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError("updating read-only volume condition: injected error"))
+		})
+	})
+})
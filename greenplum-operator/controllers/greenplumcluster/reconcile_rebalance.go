@@ -0,0 +1,160 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/rebalancejob"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often to check on a running rebalance job
+const rebalancePollInterval = 30 * time.Second
+
+// handleRebalance starts a job running gprecoverseg -r when the GreenplumCluster has the rebalance
+// annotation and segments are not in their preferred roles, and reports the balance of the segments
+// in the SegmentsNotBalanced condition. It returns how long to wait before checking on a running job.
+func (r *GreenplumClusterReconciler) handleRebalance(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, segments []configmap.Segment) (time.Duration, error) {
+	if greenplumCluster.Spec.Segments.Mirrors != "yes" {
+		return 0, nil
+	}
+	unbalanced := unbalancedSegments(segments)
+
+	jobKey := rebalanceJobKey(greenplumCluster)
+	var job *batchv1.Job
+	var existingJob batchv1.Job
+	if err := r.Get(ctx, jobKey, &existingJob); err == nil {
+		job = &existingJob
+	} else if !apierrs.IsNotFound(err) {
+		return 0, err
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	var requeueAfter time.Duration
+	_, requested := greenplumCluster.Annotations[greenplumv1.RebalanceAnnotation]
+	switch {
+	case job != nil && job.Status.Succeeded < 1 && job.Status.Failed < 1:
+		// the job is still running
+		requeueAfter = rebalancePollInterval
+	case requested && len(unbalanced) == 0:
+		r.Log.Info("segments are already in their preferred roles; not rebalancing")
+		delete(greenplumCluster.Annotations, greenplumv1.RebalanceAnnotation)
+	case requested && !canRebalance(greenplumCluster, segments):
+		// gprecoverseg -r needs every mirror up and in sync; keep the annotation until they are
+		r.Log.Info("waiting for all segments to be up and in sync before rebalancing")
+	case requested:
+		if job != nil {
+			// a failed job is kept for its logs until the rebalance is requested again
+			if err := r.Delete(ctx, job, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return 0, err
+			}
+		}
+		var err error
+		job, err = r.createRebalanceJob(ctx, greenplumCluster, activeMaster)
+		if err != nil {
+			return 0, err
+		}
+		r.Log.Info("started rebalance job", "segments", unbalanced)
+		delete(greenplumCluster.Annotations, greenplumv1.RebalanceAnnotation)
+		requeueAfter = rebalancePollInterval
+	case job != nil && job.Status.Succeeded > 0:
+		if err := r.Delete(ctx, job, client.GracePeriodSeconds(0), client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return 0, err
+		}
+		job = nil
+	}
+
+	setSegmentsNotBalancedCondition(greenplumCluster, job, unbalanced)
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return 0, fmt.Errorf("updating rebalance status: %w", err)
+		}
+	}
+	return requeueAfter, nil
+}
+
+func setSegmentsNotBalancedCondition(greenplumCluster *greenplumv1.GreenplumCluster, job *batchv1.Job, unbalanced []string) {
+	condition := metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionSegmentsNotBalanced,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: greenplumCluster.Generation,
+	}
+	switch {
+	case job != nil && job.Status.Succeeded < 1 && job.Status.Failed < 1:
+		condition.Reason = "Rebalancing"
+		condition.Message = "gprecoverseg -r is returning segments to their preferred roles: " + strings.Join(unbalanced, ", ")
+	case len(unbalanced) > 0 && job != nil && job.Status.Failed > 0:
+		condition.Reason = "RebalanceFailed"
+		condition.Message = fmt.Sprintf("rebalance job %s failed; check its logs, then annotate the GreenplumCluster with %s to retry",
+			job.Name, greenplumv1.RebalanceAnnotation)
+	case len(unbalanced) > 0:
+		condition.Reason = "NotBalanced"
+		condition.Message = fmt.Sprintf("segments are not in their preferred roles: %s; annotate the GreenplumCluster with %s to run gprecoverseg -r",
+			strings.Join(unbalanced, ", "), greenplumv1.RebalanceAnnotation)
+	default:
+		if meta.FindStatusCondition(greenplumCluster.Status.Conditions, condition.Type) == nil {
+			return
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Balanced"
+		condition.Message = "all segments are in their preferred roles"
+	}
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, condition)
+}
+
+// unbalancedSegments returns the segments, as host:port, that are not in their preferred role
+func unbalancedSegments(segments []configmap.Segment) []string {
+	var unbalanced []string
+	for _, segment := range segments {
+		if segment.Content >= 0 && segment.Role != segment.PreferredRole {
+			unbalanced = append(unbalanced, fmt.Sprintf("%s:%d", segment.Hostname, segment.Port))
+		}
+	}
+	return unbalanced
+}
+
+// canRebalance reports whether every segment is up and no primary is in change tracking
+func canRebalance(greenplumCluster *greenplumv1.GreenplumCluster, segments []configmap.Segment) bool {
+	for _, segment := range segments {
+		if segment.Status != "u" {
+			return false
+		}
+	}
+	return !meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionSegmentsInChangeTracking)
+}
+
+func rebalanceJobKey(greenplumCluster *greenplumv1.GreenplumCluster) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: greenplumCluster.Namespace,
+		Name:      fmt.Sprintf("%s-rebalance-job", greenplumCluster.Name),
+	}
+}
+
+func (r *GreenplumClusterReconciler) createRebalanceJob(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (*batchv1.Job, error) {
+	jobKey := rebalanceJobKey(greenplumCluster)
+	activeMasterFQDN := fmt.Sprintf("%s.agent.%s.svc.cluster.local", activeMaster, greenplumCluster.Namespace)
+	job := rebalancejob.GenerateJob(r.InstanceImage, activeMasterFQDN)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+
+	if err := ctrl.SetControllerReference(greenplumCluster, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return nil, err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,250 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	balancedSegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+		"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+		"3|0|m|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+	unbalancedSegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+		"2|0|m|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+		"3|0|p|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+)
+
+var _ = Describe("Reconcile segment rebalancing for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		rebalanceJobKey     types.NamespacedName
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			MirroringStatus:      gpstateMirrorsInSync,
+			SegmentConfiguration: unbalancedSegmentConfiguration,
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+		rebalanceJobKey = types.NamespacedName{Namespace: namespaceName, Name: "my-greenplum-rebalance-job"}
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	requestRebalance := func() {
+		greenplumCluster.Annotations = map[string]string{greenplumv1.RebalanceAnnotation: "true"}
+	}
+	createRebalanceJob := func(status batchv1.JobStatus) {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: rebalanceJobKey.Namespace, Name: rebalanceJobKey.Name},
+			Status:     status,
+		}
+		Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+	}
+	segmentsNotBalancedCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionSegmentsNotBalanced)
+	}
+
+	When("the segments are in their preferred roles", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = balancedSegmentConfiguration
+		})
+		It("does not set a balance condition or create a job", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(segmentsNotBalancedCondition()).To(BeNil())
+			Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+		})
+
+		When("a rebalance is requested", func() {
+			BeforeEach(requestRebalance)
+			It("removes the annotation without creating a job", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.RebalanceAnnotation))
+				Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+				Expect(logBuf).To(gbytes.Say("segments are already in their preferred roles; not rebalancing"))
+			})
+		})
+	})
+
+	When("the cluster has no mirrors", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Mirrors = "no"
+			requestRebalance()
+		})
+		It("does not rebalance", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(segmentsNotBalancedCondition()).To(BeNil())
+			Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+		})
+	})
+
+	When("segments are not in their preferred roles", func() {
+		It("reports them in the balance condition without creating a job", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(segmentsNotBalancedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionTrue),
+				"Reason": Equal("NotBalanced"),
+				"Message": Equal("segments are not in their preferred roles: segment-a-0:40000, segment-b-0:50000; " +
+					"annotate the GreenplumCluster with greenplum.pivotal.io/rebalance to run gprecoverseg -r"),
+			})))
+			Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+		})
+
+		When("a rebalance is requested", func() {
+			BeforeEach(requestRebalance)
+			It("creates a job to run gprecoverseg -r on the active master", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var rebalanceJob batchv1.Job
+				Expect(reactiveClient.Get(ctx, rebalanceJobKey, &rebalanceJob)).To(Succeed())
+				rebalanceContainer := rebalanceJob.Spec.Template.Spec.Containers[0]
+				Expect(rebalanceContainer.Image).To(Equal("greenplum-for-kubernetes:latest"))
+				Expect(rebalanceContainer.Env).To(ContainElement(corev1.EnvVar{
+					Name:  "GPRECOVERSEG_HOST",
+					Value: "master-0.agent.test-ns.svc.cluster.local",
+				}))
+				Expect(rebalanceJob.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+			})
+			It("removes the annotation and reports that the segments are rebalancing", func() {
+				Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.RebalanceAnnotation))
+				Expect(segmentsNotBalancedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("Rebalancing"),
+					"Message": Equal("gprecoverseg -r is returning segments to their preferred roles: segment-a-0:40000, segment-b-0:50000"),
+				})))
+			})
+			It("requeues to check on the job", func() {
+				Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+			})
+
+			When("a segment is down", func() {
+				BeforeEach(func() {
+					podExec.SegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+						"2|0|m|p|d|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+						"3|0|p|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+				})
+				It("keeps the annotation until the segments can be rebalanced", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+					Expect(reconciledCluster.Annotations).To(HaveKey(greenplumv1.RebalanceAnnotation))
+					Expect(logBuf).To(gbytes.Say("waiting for all segments to be up and in sync before rebalancing"))
+				})
+			})
+
+			When("primaries are in change tracking", func() {
+				BeforeEach(func() {
+					podExec.MirroringStatus = gpstateChangeTracking
+				})
+				It("does not create a job", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+					Expect(reconciledCluster.Annotations).To(HaveKey(greenplumv1.RebalanceAnnotation))
+				})
+			})
+
+			When("a previous rebalance job failed", func() {
+				BeforeEach(func() {
+					createRebalanceJob(batchv1.JobStatus{Failed: 1})
+				})
+				It("replaces it with a new job", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					var rebalanceJob batchv1.Job
+					Expect(reactiveClient.Get(ctx, rebalanceJobKey, &rebalanceJob)).To(Succeed())
+					Expect(rebalanceJob.Status.Failed).To(BeZero())
+					Expect(rebalanceJob.Spec.Template.Spec.Containers).To(HaveLen(1))
+				})
+			})
+		})
+
+		When("a rebalance job is running", func() {
+			BeforeEach(func() {
+				createRebalanceJob(batchv1.JobStatus{Active: 1})
+			})
+			It("reports that the segments are rebalancing and requeues", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(segmentsNotBalancedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Reason": Equal("Rebalancing"),
+				})))
+				Expect(reconcileResult.RequeueAfter).To(Equal(30 * time.Second))
+			})
+		})
+
+		When("the rebalance job failed", func() {
+			BeforeEach(func() {
+				createRebalanceJob(batchv1.JobStatus{Failed: 1})
+			})
+			It("keeps the job for its logs and reports the failure", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Succeed())
+				Expect(segmentsNotBalancedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionTrue),
+					"Reason": Equal("RebalanceFailed"),
+					"Message": Equal("rebalance job my-greenplum-rebalance-job failed; check its logs, " +
+						"then annotate the GreenplumCluster with greenplum.pivotal.io/rebalance to retry"),
+				})))
+			})
+		})
+	})
+
+	When("the rebalance job succeeded", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = balancedSegmentConfiguration
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:    greenplumv1.GreenplumClusterConditionSegmentsNotBalanced,
+				Status:  metav1.ConditionTrue,
+				Reason:  "Rebalancing",
+				Message: "gprecoverseg -r is returning segments to their preferred roles: segment-a-0:40000, segment-b-0:50000",
+			}}
+			createRebalanceJob(batchv1.JobStatus{Succeeded: 1})
+		})
+		It("deletes the job", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reactiveClient.Get(ctx, rebalanceJobKey, &batchv1.Job{})).To(Satisfy(apierrs.IsNotFound))
+		})
+		It("clears the balance condition", func() {
+			Expect(segmentsNotBalancedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionFalse),
+				"Reason":  Equal("Balanced"),
+				"Message": Equal("all segments are in their preferred roles"),
+			})))
+			Expect(reconcileResult.RequeueAfter).To(BeZero())
+		})
+	})
+})
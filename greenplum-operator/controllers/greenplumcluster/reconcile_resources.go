@@ -0,0 +1,37 @@
+package greenplumcluster
+
+import (
+	"fmt"
+	"strconv"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// defaultVmemProtectLimitMB is the Greenplum default of gp_vmem_protect_limit, in MB
+const defaultVmemProtectLimitMB = 8192
+
+// warnAboutSmallMemoryRequest emits a Warning Event when resources.requests.memory of the master and standby or of
+// the segments is less than gp_vmem_protect_limit. Each pod runs a single Greenplum instance, which then counts on
+// more memory than the scheduler has set aside for it, and can be OOM killed before Greenplum limits its queries.
+// It is only checked when the statefulset changes, so that the Event is not repeated on every reconcile.
+func (r *GreenplumClusterReconciler) warnAboutSmallMemoryRequest(greenplumCluster *greenplumv1.GreenplumCluster, gpPodSpec greenplumv1.GreenplumPodSpec, typ string) {
+	memoryRequest, ok := gpPodSpec.Resources.Requests[corev1.ResourceMemory]
+	if !ok {
+		return
+	}
+	vmemProtectLimitMB := int64(defaultVmemProtectLimitMB)
+	if value, ok := greenplumCluster.Spec.Config.GUCs["gp_vmem_protect_limit"]; ok {
+		if limit, err := strconv.ParseInt(value, 10, 64); err == nil && limit > 0 {
+			vmemProtectLimitMB = limit
+		}
+	}
+	vmemProtectLimit := resource.NewQuantity(vmemProtectLimitMB*1024*1024, resource.BinarySI)
+	if memoryRequest.Cmp(*vmemProtectLimit) >= 0 {
+		return
+	}
+	r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "MemoryRequestTooSmall",
+		fmt.Sprintf("%s resources.requests.memory %s is less than gp_vmem_protect_limit %dMB; pods may be OOM killed under load",
+			typ, memoryRequest.String(), vmemProtectLimitMB))
+}
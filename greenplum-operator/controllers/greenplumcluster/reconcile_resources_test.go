@@ -0,0 +1,89 @@
+package greenplumcluster_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Reconcile resources for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		recorder            *record.FakeRecorder
+		reconcileErr        error
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       &fake.PodExec{},
+			Recorder:      recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Memory = resource.MustParse("16Gi")
+		greenplumCluster.Spec.Segments.Resources = corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+				corev1.ResourceCPU:    resource.MustParse("250m"),
+			},
+		}
+	})
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	It("sets the requests and limits of the segment containers", func() {
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		var segmentStatefulSet appsv1.StatefulSet
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}, &segmentStatefulSet)).To(Succeed())
+		resources := segmentStatefulSet.Spec.Template.Spec.Containers[0].Resources
+		Expect(resources.Requests.Memory().String()).To(Equal("4Gi"))
+		Expect(resources.Requests.Cpu().String()).To(Equal("250m"))
+		Expect(resources.Limits.Memory().String()).To(Equal("16Gi"))
+	})
+
+	It("warns that the memory request is less than the default gp_vmem_protect_limit", func() {
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(Equal("Warning MemoryRequestTooSmall segments resources.requests.memory 4Gi is less than gp_vmem_protect_limit 8192MB; pods may be OOM killed under load")))
+	})
+
+	When("gp_vmem_protect_limit fits in the memory request", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.GUCs = map[string]string{"gp_vmem_protect_limit": "4096"}
+			// the running cluster already has it, so no restart is needed
+			greenplumReconciler.PodExec = &fake.PodExec{StdoutResult: "4096\n"}
+		})
+		It("does not warn", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+
+	When("no memory request is given", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Resources = corev1.ResourceRequirements{}
+		})
+		It("does not warn", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+)
+
+const roleConnectionLimitsQuery = "SELECT rolname, rolconnlimit FROM pg_roles ORDER BY rolname"
+
+// handleRoleConnectionLimits brings the connection limit of each role in spec.config.roleConnectionLimits
+// in line with the spec. Roles left out of the spec are not touched, and roles that do not exist are skipped.
+func (r *GreenplumClusterReconciler) handleRoleConnectionLimits(greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	if len(greenplumCluster.Spec.Config.RoleConnectionLimits) == 0 {
+		return nil
+	}
+	stdout, err := r.psqlOnMaster(greenplumCluster.Namespace, activeMaster, roleConnectionLimitsQuery)
+	if err != nil {
+		return fmt.Errorf("getting current role connection limits: %w", err)
+	}
+	currentLimits, err := parseRoleConnectionLimits(stdout)
+	if err != nil {
+		return err
+	}
+
+	statements, missingRoles := roleConnectionLimitStatements(greenplumCluster.Spec.Config.RoleConnectionLimits, currentLimits)
+	if len(missingRoles) > 0 {
+		r.Log.V(1).Info("skipping connection limits for roles that do not exist", "roles", missingRoles)
+	}
+	for _, statement := range statements {
+		if _, err := r.psqlOnMaster(greenplumCluster.Namespace, activeMaster, statement); err != nil {
+			return fmt.Errorf("running %s: %w", statement, err)
+		}
+		r.Log.Info("applied role connection limit", "statement", statement)
+	}
+	return nil
+}
+
+// roleConnectionLimitStatements returns the ALTER ROLE statements, sorted by role, that change the
+// current limits into the desired ones, along with the desired roles that do not exist
+func roleConnectionLimitStatements(desiredLimits, currentLimits map[string]int32) (statements, missingRoles []string) {
+	roles := make([]string, 0, len(desiredLimits))
+	for role := range desiredLimits {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		currentLimit, ok := currentLimits[role]
+		if !ok {
+			missingRoles = append(missingRoles, role)
+			continue
+		}
+		if currentLimit == desiredLimits[role] {
+			continue
+		}
+		// Role names are validated by the admission webhook, so quoting them is enough
+		statements = append(statements, fmt.Sprintf(`ALTER ROLE "%s" CONNECTION LIMIT %d`, role, desiredLimits[role]))
+	}
+	return statements, missingRoles
+}
+
+// parseRoleConnectionLimits parses the unaligned, tuples-only (psql -tA) output of roleConnectionLimitsQuery
+func parseRoleConnectionLimits(output string) (map[string]int32, error) {
+	limits := map[string]int32{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		separator := strings.LastIndex(line, "|")
+		if separator < 0 {
+			return nil, fmt.Errorf("unexpected role connection limit row: %q", line)
+		}
+		limit, err := strconv.ParseInt(line[separator+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected role connection limit row: %q", line)
+		}
+		limits[line[:separator]] = int32(limit)
+	}
+	return limits, nil
+}
+
+func (r *GreenplumClusterReconciler) psqlOnMaster(namespace, activeMaster, sql string) (string, error) {
+	return r.psqlOnDatabase(namespace, activeMaster, "postgres", sql)
+}
+
+func (r *GreenplumClusterReconciler) psqlOnDatabase(namespace, activeMaster, database, sql string) (string, error) {
+	psqlCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf(`source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d %s -tAc '%s'`, database, sql),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(psqlCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return "", fmt.Errorf("%w: %s", err, stderrBuf.String())
+	}
+	return stdoutBuf.String(), nil
+}
@@ -0,0 +1,128 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+)
+
+var _ = Describe("Reconcile role connection limits for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			RoleConnectionLimits: "analyst|-1\netl_user|5\ngpadmin|-1\n",
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var reconcileErr error
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	When("no role connection limits are set", func() {
+		BeforeEach(func() {
+			podExec.RoleConnectionLimitsErr = errors.New("pg_roles should not be queried")
+		})
+		It("does not alter any roles", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ALTER ROLE")))
+		})
+	})
+
+	When("role connection limits differ from the running cluster", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.RoleConnectionLimits = map[string]int32{"etl_user": 2, "analyst": 10}
+		})
+		It("alters each changed role, sorted by name, on the active master", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.CalledPodName).To(Equal("master-0"))
+			var alterCommands []string
+			for _, command := range podExec.RecordedCommands {
+				if strings.Contains(command, "ALTER ROLE") {
+					alterCommands = append(alterCommands, command)
+				}
+			}
+			Expect(alterCommands).To(Equal([]string{
+				`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc 'ALTER ROLE "analyst" CONNECTION LIMIT 10'`,
+				`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc 'ALTER ROLE "etl_user" CONNECTION LIMIT 2'`,
+			}))
+			Expect(logBuf).To(gbytes.Say("applied role connection limit"))
+		})
+	})
+
+	When("role connection limits already match the running cluster", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": -1, "etl_user": 5}
+		})
+		It("does not alter any roles", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ALTER ROLE")))
+		})
+	})
+
+	When("a role does not exist yet", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.RoleConnectionLimits = map[string]int32{"new_tenant": 3}
+		})
+		It("skips it", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("ALTER ROLE")))
+		})
+	})
+
+	When("querying the current limits fails", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": 10}
+			podExec.RoleConnectionLimitsErr = errors.New("injected error")
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring(
+				"unable to apply role connection limits: getting current role connection limits: injected error")))
+		})
+	})
+
+	When("the current limits cannot be parsed", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": 10}
+			podExec.RoleConnectionLimits = "analyst\n"
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring(`unexpected role connection limit row: "analyst"`)))
+		})
+	})
+
+	When("altering a role fails", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": 10}
+			podExec.ErrorMsgOnCommand = "psql: could not connect to server"
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring(
+				`unable to apply role connection limits: running ALTER ROLE "analyst" CONNECTION LIMIT 10: psql: could not connect to server`)))
+		})
+	})
+})
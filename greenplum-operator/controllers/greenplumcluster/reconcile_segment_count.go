@@ -0,0 +1,54 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// resolvePrimarySegmentCount fixes the primary segment count of an auto-sized cluster the first
+// time it is reconciled. Later reconciles use the count recorded in status, so that nodes
+// joining or leaving the cluster do not change its shape.
+func (r *GreenplumClusterReconciler) resolvePrimarySegmentCount(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) error {
+	if greenplumCluster.Spec.Segments.AutoPrimarySegmentCount != "yes" {
+		return nil
+	}
+
+	if greenplumCluster.Status.PrimarySegmentCount == 0 {
+		nodeCount, err := r.countSchedulableSegmentNodes(ctx, greenplumCluster)
+		if err != nil {
+			return fmt.Errorf("counting schedulable nodes: %w", err)
+		}
+		if nodeCount == 0 {
+			return fmt.Errorf("unable to resolve primarySegmentCount: no schedulable nodes match the segments workerSelector")
+		}
+
+		originalGreenplumCluster := greenplumCluster.DeepCopy()
+		greenplumCluster.Status.PrimarySegmentCount = nodeCount
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return fmt.Errorf("recording resolved primarySegmentCount: %w", err)
+		}
+		r.Log.Info("resolved primarySegmentCount from schedulable nodes", "primarySegmentCount", nodeCount)
+	}
+
+	greenplumCluster.Spec.Segments.PrimarySegmentCount = greenplumCluster.Status.PrimarySegmentCount
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) countSchedulableSegmentNodes(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (int32, error) {
+	var nodeList corev1.NodeList
+	if err := r.List(ctx, &nodeList, client.MatchingLabels(sset.NodeSelector(greenplumCluster.Spec.Segments.GreenplumPodSpec))); err != nil {
+		return 0, err
+	}
+	var count int32
+	for _, node := range nodeList.Items {
+		if sset.IsNodeSchedulable(node) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,183 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Reconcile auto primarySegmentCount for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{SegmentCount: "3\n"}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.AutoPrimarySegmentCount = "yes"
+		greenplumCluster.Spec.Segments.PrimarySegmentCount = 0
+	})
+
+	createNode := func(name string, modify func(node *corev1.Node)) {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+		if modify != nil {
+			modify(node)
+		}
+		Expect(reactiveClient.Create(ctx, node)).To(Succeed())
+	}
+
+	var reconcileErr error
+	var reconciledCluster greenplumv1.GreenplumCluster
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	When("the cluster is reconciled for the first time", func() {
+		BeforeEach(func() {
+			createNode("node-1", nil)
+			createNode("node-2", nil)
+			createNode("node-3", nil)
+			createNode("cordoned", func(node *corev1.Node) {
+				node.Spec.Unschedulable = true
+			})
+			createNode("tainted", func(node *corev1.Node) {
+				node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "master", Effect: corev1.TaintEffectNoSchedule}}
+			})
+			createNode("not-ready", func(node *corev1.Node) {
+				node.Status.Conditions[0].Status = corev1.ConditionFalse
+			})
+		})
+		It("records one primary segment per schedulable node in status", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.PrimarySegmentCount).To(Equal(int32(3)))
+			Expect(reconciledCluster.Spec.Segments.PrimarySegmentCount).To(BeZero())
+			Expect(logBuf).To(gbytes.Say("resolved primarySegmentCount from schedulable nodes"))
+		})
+		It("creates the segments with the resolved count", func() {
+			var segmentA appsv1.StatefulSet
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}, &segmentA)).To(Succeed())
+			Expect(*segmentA.Spec.Replicas).To(Equal(int32(3)))
+
+			var configMap corev1.ConfigMap
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "greenplum-config"}, &configMap)).To(Succeed())
+			Expect(configMap.Data[configmap.SegmentCount]).To(Equal("3"))
+		})
+	})
+
+	When("the segments workerSelector is set", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.WorkerSelector = map[string]string{"worker": "greenplum"}
+			createNode("node-1", func(node *corev1.Node) {
+				node.Labels = map[string]string{"worker": "greenplum"}
+			})
+			createNode("node-2", nil)
+		})
+		It("only counts matching nodes", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.PrimarySegmentCount).To(Equal(int32(1)))
+		})
+	})
+
+	When("the count has already been resolved", func() {
+		BeforeEach(func() {
+			greenplumCluster.Status.PrimarySegmentCount = 2
+			createNode("node-1", nil)
+			createNode("node-2", nil)
+			createNode("node-3", nil)
+			createNode("node-4", nil)
+		})
+		It("keeps the resolved count when nodes are added", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.PrimarySegmentCount).To(Equal(int32(2)))
+
+			var segmentA appsv1.StatefulSet
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}, &segmentA)).To(Succeed())
+			Expect(*segmentA.Spec.Replicas).To(Equal(int32(2)))
+		})
+	})
+
+	When("autoPrimarySegmentCount has been switched to no to expand the cluster", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.AutoPrimarySegmentCount = "no"
+			greenplumCluster.Spec.Segments.PrimarySegmentCount = 4
+			greenplumCluster.Status.PrimarySegmentCount = 2
+			createNode("node-1", nil)
+		})
+		It("uses primarySegmentCount from the spec", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.PrimarySegmentCount).To(Equal(int32(2)))
+
+			var segmentA appsv1.StatefulSet
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}, &segmentA)).To(Succeed())
+			Expect(*segmentA.Spec.Replicas).To(Equal(int32(4)))
+		})
+	})
+
+	When("there are no schedulable nodes", func() {
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError("unable to resolve primarySegmentCount: no schedulable nodes match the segments workerSelector"))
+			Expect(reconciledCluster.Status.PrimarySegmentCount).To(BeZero())
+		})
+	})
+
+	When("listing nodes fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("list", "nodes", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError("counting schedulable nodes: injected error"))
+		})
+	})
+
+	When("autoPrimarySegmentCount is not set", func() {
+		BeforeEach(func() {
+			greenplumCluster = exampleGreenplumCluster.DeepCopy()
+			createNode("node-1", nil)
+			createNode("node-2", nil)
+		})
+		It("uses primarySegmentCount from the spec", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.PrimarySegmentCount).To(BeZero())
+
+			var segmentA appsv1.StatefulSet
+			Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}, &segmentA)).To(Succeed())
+			Expect(*segmentA.Spec.Replicas).To(Equal(int32(fake.DefaultSegmentCount)))
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleSegmentFencing lists the down segments whose pod cannot write to its data volume in
+// status.fencedSegments, with the reason, and reports them in the SegmentsFenced condition. A fenced
+// segment stays listed until it is up again, even while its pod is being replaced, e.g. by fenceReadOnly.
+// Segments that are down for any other reason are only counted in status.segments.
+func (r *GreenplumClusterReconciler) handleSegmentFencing(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, segments []configmap.Segment) error {
+	previouslyFenced := map[string]greenplumv1.GreenplumFencedSegment{}
+	for _, fencedSegment := range greenplumCluster.Status.FencedSegments {
+		previouslyFenced[fencedSegment.Pod] = fencedSegment
+	}
+
+	var fencedSegments, newlyFenced []greenplumv1.GreenplumFencedSegment
+	for _, segment := range segments {
+		if segment.Content < 0 || segment.Status != "d" {
+			continue
+		}
+		reason := r.dataVolumeFailure(greenplumCluster.Namespace, segment.Hostname)
+		if previous, ok := previouslyFenced[segment.Hostname]; ok && previous.Content == int32(segment.Content) {
+			if reason != "" {
+				previous.Reason = reason
+			}
+			fencedSegments = append(fencedSegments, previous)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		fencedSegment := greenplumv1.GreenplumFencedSegment{
+			Content: int32(segment.Content),
+			Role:    segment.Role,
+			Pod:     segment.Hostname,
+			Reason:  reason,
+			Since:   metav1.Now(),
+		}
+		fencedSegments = append(fencedSegments, fencedSegment)
+		newlyFenced = append(newlyFenced, fencedSegment)
+	}
+
+	for _, fencedSegment := range newlyFenced {
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "SegmentFenced",
+			fmt.Sprintf("segment %d %s on %s is down: %s", fencedSegment.Content, segmentRoleName(fencedSegment.Role),
+				fencedSegment.Pod, describeDataVolumeFailure(fencedSegment.Reason)))
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	greenplumCluster.Status.FencedSegments = fencedSegments
+	if len(fencedSegments) > 0 {
+		var descriptions []string
+		for _, fencedSegment := range fencedSegments {
+			descriptions = append(descriptions, fmt.Sprintf("%s (content %d, %s)", fencedSegment.Pod, fencedSegment.Content, fencedSegment.Reason))
+		}
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionSegmentsFenced,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "DataVolumeFailed",
+			Message:            "segments are down because of their data volumes: " + strings.Join(descriptions, ", "),
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionSegmentsFenced) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionSegmentsFenced,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "NoSegmentsFenced",
+			Message:            "no segments are down because of their data volumes",
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return fmt.Errorf("updating fenced segments status: %w", err)
+		}
+	}
+	return nil
+}
+
+func segmentRoleName(role string) string {
+	if role == "p" {
+		return "primary"
+	}
+	return "mirror"
+}
+
+func describeDataVolumeFailure(reason string) string {
+	switch reason {
+	case "ReadOnlyFilesystem":
+		return "its data volume is read-only"
+	case "NoSpaceLeftOnDevice":
+		return "its data volume has no space left"
+	}
+	return "its data volume failed"
+}
@@ -0,0 +1,168 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Reconcile fenced segments for GreenplumCluster", func() {
+	const (
+		primaryDownConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+			"2|0|p|p|d|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+			"3|0|m|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+		mirrorDownConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+			"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+			"3|0|m|m|d|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+		allUpConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+			"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+			"3|0|m|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+	)
+
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       podExec,
+			Recorder:      recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+	})
+
+	var (
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	segmentsFencedCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionSegmentsFenced)
+	}
+
+	When("a primary segment is down and its data volume is read-only", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = primaryDownConfiguration
+			podExec.ReadOnlyPods = []string{"segment-a-0"}
+		})
+		It("reports the segment as fenced with its reason", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.FencedSegments).To(ConsistOf(MatchFields(IgnoreExtras, Fields{
+				"Content": Equal(int32(0)),
+				"Role":    Equal("p"),
+				"Pod":     Equal("segment-a-0"),
+				"Reason":  Equal("ReadOnlyFilesystem"),
+			})))
+			Expect(segmentsFencedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("DataVolumeFailed"),
+				"Message": Equal("segments are down because of their data volumes: segment-a-0 (content 0, ReadOnlyFilesystem)"),
+			})))
+		})
+		It("emits an event", func() {
+			Expect(recorder.Events).To(Receive(Equal("Warning DataVolumeReadOnly data volume is read-only on pods: segment-a-0")))
+			Expect(recorder.Events).To(Receive(Equal("Warning SegmentFenced segment 0 primary on segment-a-0 is down: its data volume is read-only")))
+		})
+	})
+
+	When("a mirror segment is down and its data volume is full", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = mirrorDownConfiguration
+			podExec.FullDiskPods = []string{"segment-b-0"}
+		})
+		It("reports the segment as fenced with its reason", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.FencedSegments).To(ConsistOf(MatchFields(IgnoreExtras, Fields{
+				"Content": Equal(int32(0)),
+				"Role":    Equal("m"),
+				"Pod":     Equal("segment-b-0"),
+				"Reason":  Equal("NoSpaceLeftOnDevice"),
+			})))
+			Expect(recorder.Events).To(Receive(Equal("Warning SegmentFenced segment 0 mirror on segment-b-0 is down: its data volume has no space left")))
+		})
+	})
+
+	When("a segment is down and its data volume is writable", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = mirrorDownConfiguration
+		})
+		It("does not report it as fenced", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(reconciledCluster.Status.FencedSegments).To(BeEmpty())
+			Expect(segmentsFencedCondition()).To(BeNil())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+
+	When("a segment was already reported as fenced", func() {
+		var since metav1.Time
+		BeforeEach(func() {
+			since = metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			greenplumCluster.Status.FencedSegments = []greenplumv1.GreenplumFencedSegment{
+				{Content: 0, Role: "p", Pod: "segment-a-0", Reason: "ReadOnlyFilesystem", Since: since},
+			}
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:               greenplumv1.GreenplumClusterConditionSegmentsFenced,
+				Status:             metav1.ConditionTrue,
+				Reason:             "DataVolumeFailed",
+				Message:            "segments are down because of their data volumes: segment-a-0 (content 0, ReadOnlyFilesystem)",
+				LastTransitionTime: metav1.Now(),
+			}}
+		})
+
+		When("it is still down while its pod is being replaced", func() {
+			BeforeEach(func() {
+				podExec.SegmentConfiguration = primaryDownConfiguration
+			})
+			It("keeps reporting it without another event", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconciledCluster.Status.FencedSegments).To(ConsistOf(MatchFields(IgnoreExtras, Fields{
+					"Pod":    Equal("segment-a-0"),
+					"Reason": Equal("ReadOnlyFilesystem"),
+					"Since":  Equal(since),
+				})))
+				Expect(recorder.Events).NotTo(Receive())
+			})
+		})
+
+		When("it is up again", func() {
+			BeforeEach(func() {
+				podExec.SegmentConfiguration = allUpConfiguration
+			})
+			It("clears it", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(reconciledCluster.Status.FencedSegments).To(BeEmpty())
+				Expect(segmentsFencedCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionFalse),
+					"Reason": Equal("NoSegmentsFenced"),
+				})))
+			})
+		})
+	})
+})
@@ -0,0 +1,49 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// handleSegmentMap refreshes the ConfigMap that maps each segment's dbid and content to the pod hosting it
+func (r *GreenplumClusterReconciler) handleSegmentMap(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, segments []configmap.Segment) error {
+	segmentMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configmap.SegmentMapConfigMapName,
+			Namespace: greenplumCluster.Namespace,
+		},
+	}
+	operationResult, err := ctrl.CreateOrUpdate(ctx, r, segmentMap, func() error {
+		configmap.ModifySegmentMapConfigMap(greenplumCluster, segments, segmentMap)
+		return ctrl.SetControllerReference(greenplumCluster, segmentMap, r.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("updating segment map: %w", err)
+	}
+	r.logReconcileResult(operationResult, segmentMap)
+	return nil
+}
+
+// querySegmentConfiguration reads gp_segment_configuration from the active master. It is queried once per
+// reconcile, and the segments passed to each step that needs them
+func (r *GreenplumClusterReconciler) querySegmentConfiguration(namespace, activeMaster string) ([]configmap.Segment, error) {
+	segmentConfigurationCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf(`source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc "%s"`, configmap.SegmentConfigurationQuery),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(segmentConfigurationCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderrBuf.String())
+	}
+	return configmap.ParseSegmentConfiguration(stdoutBuf.String())
+}
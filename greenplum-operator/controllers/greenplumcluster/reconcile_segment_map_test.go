@@ -0,0 +1,115 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Reconcile segment map for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		configMapKey        types.NamespacedName
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		podExec = &fake.PodExec{
+			SegmentConfiguration: "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n",
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		configMapKey = types.NamespacedName{Namespace: namespaceName, Name: "greenplum-segment-map"}
+	})
+
+	var reconcileErr error
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	When("the segment map doesn't exist before Reconcile", func() {
+		It("maps each segment to its pod", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var configMap corev1.ConfigMap
+			Expect(reactiveClient.Get(ctx, configMapKey, &configMap)).To(Succeed())
+			Expect(configMap.Data).To(Equal(map[string]string{
+				"dbid-1": "content=-1 role=p preferredRole=p status=u pod=master-0 host=master-0.agent.test-ns.svc.cluster.local port=5432",
+				"dbid-2": "content=0 role=p preferredRole=p status=u pod=segment-a-0 host=segment-a-0.agent.test-ns.svc.cluster.local port=40000",
+			}))
+			Expect(configMap.Labels).To(HaveKeyWithValue("greenplum-cluster", "my-greenplum"))
+		})
+		It("takes ownership", func() {
+			var configMap corev1.ConfigMap
+			Expect(reactiveClient.Get(ctx, configMapKey, &configMap)).To(Succeed())
+			Expect(configMap.GetOwnerReferences()).To(ConsistOf(beOwnedByGreenplum))
+		})
+	})
+
+	When("the segment map is out of date before Reconcile", func() {
+		BeforeEach(func() {
+			originalConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "greenplum-segment-map"},
+				Data: map[string]string{
+					"dbid-1": "content=-1 role=p preferredRole=p status=u pod=master-1 host=master-1.agent.test-ns.svc.cluster.local port=5432",
+					"dbid-3": "content=0 role=m preferredRole=m status=d pod=mirror-a-0 host=mirror-a-0.agent.test-ns.svc.cluster.local port=50000",
+				},
+			}
+			Expect(reactiveClient.Create(ctx, originalConfigMap)).To(Succeed())
+		})
+		It("replaces the stale entries", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			var configMap corev1.ConfigMap
+			Expect(reactiveClient.Get(ctx, configMapKey, &configMap)).To(Succeed())
+			Expect(configMap.Data).To(HaveLen(2))
+			Expect(configMap.Data).To(HaveKeyWithValue("dbid-1", ContainSubstring("pod=master-0")))
+			Expect(configMap.Data).NotTo(HaveKey("dbid-3"))
+		})
+	})
+
+	When("mirrors are enabled", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Mirrors = "yes"
+		})
+		It("queries the segment configuration once for all the steps that use it", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.SegmentConfigurationQueries).To(Equal(1))
+		})
+	})
+
+	When("querying the segment configuration fails", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfigurationErr = errors.New("psql: could not connect to server")
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("unable to query segment configuration: psql: could not connect to server")))
+		})
+	})
+
+	When("the segment configuration cannot be parsed", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = "not a segment\n"
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring(`unable to query segment configuration: unexpected segment configuration row: "not a segment"`)))
+		})
+	})
+})
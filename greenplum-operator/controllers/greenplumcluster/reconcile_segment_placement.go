@@ -0,0 +1,65 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// persistentVolumeNodeSelectorTerms collects the nodeAffinity terms of the PVs bound to the data PVCs of a segment
+// statefulset, for segments.persistentVolumeAffinity. Rescheduled segments then only consider the nodes holding the
+// data of the statefulset, and the scheduler picks the node of each segment's own PV among them.
+// No terms are returned until every segment has a bound PV with nodeAffinity, so that a segment whose PVC is
+// yet to be bound, e.g. after an expansion, is not kept off the node that will provide its PV.
+func (r *GreenplumClusterReconciler) persistentVolumeNodeSelectorTerms(ctx context.Context, greenplumCluster greenplumv1.GreenplumCluster, params *sset.GreenplumStatefulSetParams) ([]corev1.NodeSelectorTerm, error) {
+	if greenplumCluster.Spec.Segments.PersistentVolumeAffinity != "yes" {
+		return nil, nil
+	}
+
+	var terms []corev1.NodeSelectorTerm
+	for i := int32(0); i < params.Replicas; i++ {
+		pvcName := fmt.Sprintf("%s-pgdata-%s-%d", greenplumCluster.Name, params.Type, i)
+		var pvc corev1.PersistentVolumeClaim
+		if err := r.Get(ctx, types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: pvcName}, &pvc); err != nil {
+			if apierrs.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("getting PVC %s: %w", pvcName, err)
+		}
+		if pvc.Status.Phase != corev1.ClaimBound || pvc.Spec.VolumeName == "" {
+			return nil, nil
+		}
+
+		var pv corev1.PersistentVolume
+		if err := r.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+			if apierrs.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("getting PV %s: %w", pvc.Spec.VolumeName, err)
+		}
+		if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			return nil, nil
+		}
+		for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			if !containsNodeSelectorTerm(terms, term) {
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms, nil
+}
+
+func containsNodeSelectorTerm(terms []corev1.NodeSelectorTerm, term corev1.NodeSelectorTerm) bool {
+	for _, t := range terms {
+		if equality.Semantic.DeepEqual(t, term) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package greenplumcluster_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Reconcile segment placement on PVs for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		reconcileErr        error
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    &fake.PodExec{},
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.PersistentVolumeAffinity = "yes"
+	})
+
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	hostnameTerm := func(nodeName string) corev1.NodeSelectorTerm {
+		return corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: "kubernetes.io/hostname", Operator: corev1.NodeSelectorOpIn, Values: []string{nodeName}},
+			},
+		}
+	}
+	createLocalPV := func(pvName, nodeName string) {
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: pvName},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					Local: &corev1.LocalVolumeSource{Path: "/mnt/disks/" + pvName},
+				},
+				NodeAffinity: &corev1.VolumeNodeAffinity{
+					Required: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{hostnameTerm(nodeName)},
+					},
+				},
+			},
+		}
+		Expect(reactiveClient.Create(ctx, pv)).To(Succeed())
+	}
+	createPVC := func(pvcName, pvName string, phase corev1.PersistentVolumeClaimPhase) {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: pvcName},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: pvName},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+		Expect(reactiveClient.Create(ctx, pvc)).To(Succeed())
+	}
+	statefulSetAffinity := func(name string) *corev1.Affinity {
+		var statefulSet appsv1.StatefulSet
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &statefulSet)).To(Succeed())
+		return statefulSet.Spec.Template.Spec.Affinity
+	}
+
+	When("the segment data PVC is bound to a PV with nodeAffinity", func() {
+		BeforeEach(func() {
+			createLocalPV("local-pv-1", "node-1")
+			createPVC("my-greenplum-pgdata-segment-a-0", "local-pv-1", corev1.ClaimBound)
+		})
+
+		It("keeps the segment pods on the node of the PV", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			affinity := statefulSetAffinity("segment-a")
+			Expect(affinity).NotTo(BeNil())
+			Expect(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(
+				Equal([]corev1.NodeSelectorTerm{hostnameTerm("node-1")}))
+		})
+
+		It("does not change the master pods", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(statefulSetAffinity("master")).To(BeNil())
+		})
+
+		When("persistentVolumeAffinity is not requested", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.PersistentVolumeAffinity = "no"
+			})
+			It("does not add node affinity", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(statefulSetAffinity("segment-a")).To(BeNil())
+			})
+		})
+	})
+
+	When("there are several segments", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.PrimarySegmentCount = 3
+			greenplumReconciler.PodExec = &fake.PodExec{SegmentCount: "3\n"}
+			createLocalPV("local-pv-1", "node-1")
+			createLocalPV("local-pv-2", "node-2")
+			createLocalPV("local-pv-3", "node-1")
+			createPVC("my-greenplum-pgdata-segment-a-0", "local-pv-1", corev1.ClaimBound)
+			createPVC("my-greenplum-pgdata-segment-a-1", "local-pv-2", corev1.ClaimBound)
+		})
+
+		When("all of their PVCs are bound", func() {
+			BeforeEach(func() {
+				createPVC("my-greenplum-pgdata-segment-a-2", "local-pv-3", corev1.ClaimBound)
+			})
+			It("allows the nodes of all their PVs, once each", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				affinity := statefulSetAffinity("segment-a")
+				Expect(affinity).NotTo(BeNil())
+				Expect(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(
+					Equal([]corev1.NodeSelectorTerm{hostnameTerm("node-1"), hostnameTerm("node-2")}))
+			})
+		})
+
+		When("a PVC is not bound yet", func() {
+			BeforeEach(func() {
+				createPVC("my-greenplum-pgdata-segment-a-2", "", corev1.ClaimPending)
+			})
+			It("does not restrict the nodes", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(statefulSetAffinity("segment-a")).To(BeNil())
+			})
+		})
+
+		When("a PVC does not exist yet", func() {
+			It("does not restrict the nodes", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(statefulSetAffinity("segment-a")).To(BeNil())
+			})
+		})
+	})
+
+	When("the bound PV has no nodeAffinity", func() {
+		BeforeEach(func() {
+			pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "network-pv"}}
+			Expect(reactiveClient.Create(ctx, pv)).To(Succeed())
+			createPVC("my-greenplum-pgdata-segment-a-0", "network-pv", corev1.ClaimBound)
+		})
+		It("does not restrict the nodes", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(statefulSetAffinity("segment-a")).To(BeNil())
+		})
+	})
+
+	When("the cluster has mirrors", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.Segments.Mirrors = "yes"
+			createLocalPV("local-pv-1", "node-1")
+			createLocalPV("local-pv-2", "node-2")
+			createPVC("my-greenplum-pgdata-segment-a-0", "local-pv-1", corev1.ClaimBound)
+			createPVC("my-greenplum-pgdata-segment-b-0", "local-pv-2", corev1.ClaimBound)
+		})
+		It("keeps primaries and mirrors on the nodes of their own PVs", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(statefulSetAffinity("segment-a").NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(
+				Equal([]corev1.NodeSelectorTerm{hostnameTerm("node-1")}))
+			Expect(statefulSetAffinity("segment-b").NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(
+				Equal([]corev1.NodeSelectorTerm{hostnameTerm("node-2")}))
+		})
+	})
+})
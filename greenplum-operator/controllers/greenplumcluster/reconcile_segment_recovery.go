@@ -0,0 +1,96 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+)
+
+const missingSegmentsRecoverFile = "/tmp/recover_missing_segments"
+
+// handleMissingSegmentData rebuilds down segments that have lost their data directory, e.g. because
+// the node holding their local persistent volume was replaced. An incremental gprecoverseg cannot
+// recover such a segment, so it is fully recovered from the segment that took over its content.
+func (r *GreenplumClusterReconciler) handleMissingSegmentData(greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string, segments []configmap.Segment) error {
+	if greenplumCluster.Spec.Segments.Mirrors != "yes" {
+		return nil
+	}
+
+	var missingSegments []configmap.Segment
+	for _, segment := range recoverableSegments(segments) {
+		missing, err := r.isSegmentDataMissing(greenplumCluster.Namespace, segment.Hostname, segment.DataDir)
+		if err != nil {
+			// The pod may not be running yet, e.g. while it waits to be scheduled on the replacement node
+			r.Log.Info("unable to check segment data directory", "pod", segment.Hostname, "error", err.Error())
+			continue
+		}
+		if missing {
+			missingSegments = append(missingSegments, segment)
+		}
+	}
+	if len(missingSegments) == 0 {
+		return nil
+	}
+
+	var recoverConfig []string
+	var pods []string
+	for _, segment := range missingSegments {
+		// Quoted, since the fields are separated by pipes
+		recoverConfig = append(recoverConfig, fmt.Sprintf("'%s|%d|%s'", segment.Address, segment.Port, segment.DataDir))
+		pods = append(pods, segment.Hostname)
+	}
+	r.Log.Info("segment data directories are missing; starting full recovery", "pods", pods)
+	recoverCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf("source /usr/local/greenplum-db/greenplum_path.sh && printf '%%s\\n' %s > %s && gprecoverseg -a -F -i %s",
+			strings.Join(recoverConfig, " "), missingSegmentsRecoverFile, missingSegmentsRecoverFile),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(recoverCommand, greenplumCluster.Namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("running gprecoverseg: %w: %s", err, stderrBuf.String())
+	}
+	return nil
+}
+
+// isSegmentDataMissing checks for the data directory in the pod. The result is reused for podProbeInterval.
+func (r *GreenplumClusterReconciler) isSegmentDataMissing(namespace, podName, dataDirectory string) (bool, error) {
+	presence, err := r.podProbes.probe(namespace+"/"+podName+"/data-directory:"+dataDirectory, func() (string, error) {
+		dataDirectoryCheckCommand := []string{
+			"/bin/bash",
+			"-c",
+			"--",
+			fmt.Sprintf("if [ -f %s/PG_VERSION ]; then echo present; else echo missing; fi", dataDirectory),
+		}
+		stdoutBuf := &bytes.Buffer{}
+		stderrBuf := &bytes.Buffer{}
+		if err := r.PodExec.Execute(dataDirectoryCheckCommand, namespace, podName, stdoutBuf, stderrBuf); err != nil {
+			return "", fmt.Errorf("%w: %s", err, stderrBuf.String())
+		}
+		return strings.TrimSpace(stdoutBuf.String()), nil
+	})
+	return presence == "missing", err
+}
+
+// recoverableSegments returns the down segments whose content is still being served by an up
+// segment, which gprecoverseg can copy their data from
+func recoverableSegments(segments []configmap.Segment) []configmap.Segment {
+	upContents := map[int]bool{}
+	for _, segment := range segments {
+		if segment.Content >= 0 && segment.Status == "u" {
+			upContents[segment.Content] = true
+		}
+	}
+	var downSegments []configmap.Segment
+	for _, segment := range segments {
+		if segment.Content >= 0 && segment.Status == "d" && upContents[segment.Content] {
+			downSegments = append(downSegments, segment)
+		}
+	}
+	return downSegments
+}
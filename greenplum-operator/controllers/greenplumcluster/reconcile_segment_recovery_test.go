@@ -0,0 +1,165 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+)
+
+var _ = Describe("Reconcile segments with missing data for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{
+			MirroringStatus: gpstateMirrorsInSync,
+			SegmentConfiguration: "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|m|p|d|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+				"3|0|p|m|u|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n" +
+				"4|1|p|p|u|segment-a-1|segment-a-1.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+				"5|1|m|m|u|segment-b-1|segment-b-1.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n",
+		}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+	})
+
+	recoverCommands := func() []string {
+		var commands []string
+		for _, command := range podExec.RecordedCommands {
+			if strings.Contains(command, "gprecoverseg") {
+				commands = append(commands, command)
+			}
+		}
+		return commands
+	}
+
+	var reconcileErr error
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+	})
+
+	When("the down segment still has its data directory", func() {
+		It("does not run gprecoverseg", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recoverCommands()).To(BeEmpty())
+		})
+	})
+
+	When("the down segment has lost its data directory", func() {
+		BeforeEach(func() {
+			podExec.MissingDataDirectoryPods = []string{"segment-a-0"}
+		})
+		It("fully recovers it from the segment that took over", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recoverCommands()).To(ConsistOf("/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && " +
+				"printf '%s\\n' 'segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data' > /tmp/recover_missing_segments && " +
+				"gprecoverseg -a -F -i /tmp/recover_missing_segments"))
+			Expect(podExec.CalledPodName).To(Equal("master-0"))
+			Expect(logBuf).To(gbytes.Say("segment data directories are missing; starting full recovery"))
+		})
+
+		When("gprecoverseg fails", func() {
+			BeforeEach(func() {
+				podExec.ErrorMsgOnCommand = "gprecoverseg failed"
+			})
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("unable to recover segments with missing data: running gprecoverseg: gprecoverseg failed")))
+			})
+		})
+
+		When("mirrors are not enabled", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.Mirrors = "no"
+			})
+			It("does not run gprecoverseg", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(recoverCommands()).To(BeEmpty())
+			})
+		})
+	})
+
+	When("a down segment on a segment-b pod has lost its data directory", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|p|p|u|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+				"3|0|m|m|d|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+			podExec.MissingDataDirectoryPods = []string{"segment-b-0"}
+		})
+		It("recovers it into the data directory from gp_segment_configuration", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recoverCommands()).To(ConsistOf("/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && " +
+				"printf '%s\\n' 'segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data' > /tmp/recover_missing_segments && " +
+				"gprecoverseg -a -F -i /tmp/recover_missing_segments"))
+		})
+	})
+
+	When("a segment is up but its data directory is missing", func() {
+		BeforeEach(func() {
+			podExec.MissingDataDirectoryPods = []string{"segment-a-1"}
+		})
+		It("does not run gprecoverseg", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recoverCommands()).To(BeEmpty())
+		})
+	})
+
+	When("both segments of a content are down", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfiguration = "1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|p|p|d|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n" +
+				"3|0|m|m|d|segment-b-0|segment-b-0.agent.test-ns.svc.cluster.local|50000|/greenplum/mirror/data\n"
+			podExec.MissingDataDirectoryPods = []string{"segment-a-0"}
+		})
+		It("does not run gprecoverseg, since there is nothing to recover from", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(recoverCommands()).To(BeEmpty())
+		})
+	})
+
+	When("the pod probes are reused", func() {
+		BeforeEach(func() {
+			greenplumReconciler.EnablePodProbes()
+			podExec.MissingDataDirectoryPods = []string{"segment-a-0"}
+		})
+		It("checks the data directory of a pod once within the probe interval", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExec.DataDirectoryChecks).To(Equal(1))
+
+			_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podExec.DataDirectoryChecks).To(Equal(1))
+			Expect(podExec.SegmentConfigurationQueries).To(Equal(2))
+			Expect(recoverCommands()).To(HaveLen(2))
+		})
+	})
+
+	When("querying the segment configuration fails", func() {
+		BeforeEach(func() {
+			podExec.SegmentConfigurationErr = errors.New("injected error")
+		})
+		It("returns an error", func() {
+			Expect(reconcileErr).To(HaveOccurred())
+		})
+	})
+})
@@ -46,7 +46,7 @@ var _ = Describe("Reconcile services for GreenplumCluster", func() {
 		_, reconcileErr = greenplumReconciler.Reconcile(context.TODO(), greenplumClusterRequest)
 	})
 
-	for _, sn := range []string{"agent", "greenplum"} {
+	for _, sn := range []string{"agent", "greenplum", "greenplum-metrics"} {
 		serviceName := sn
 
 		When("we expect the "+serviceName+" service to exist after Reconcile", func() {
@@ -0,0 +1,58 @@
+package greenplumcluster_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Reconcile GreenplumCluster against a stale cache", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    &fake.PodExec{},
+		}
+		Expect(reactiveClient.Create(ctx, exampleGreenplumCluster.DeepCopy())).To(Succeed())
+	})
+
+	reconcile := func() error {
+		_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		return err
+	}
+
+	It("fails while the cache is stale and converges once it has caught up", func() {
+		Expect(reconcile()).To(Succeed())
+
+		// The resources created above are not in the stale cache yet, so creating them again conflicts
+		reactiveClient.SimulateStaleCache(10)
+		Expect(reconcile()).NotTo(Succeed())
+
+		Eventually(reconcile).Should(Succeed())
+		Expect(reactiveClient.StaleGetsRemaining()).To(BeZero())
+
+		var reconciledCluster greenplumv1.GreenplumCluster
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+		Expect(reconciledCluster.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+
+		var configMap corev1.ConfigMap
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "greenplum-config"}, &configMap)).To(Succeed())
+		var segmentA appsv1.StatefulSet
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}, &segmentA)).To(Succeed())
+	})
+})
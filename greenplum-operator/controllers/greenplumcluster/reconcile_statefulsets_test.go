@@ -13,10 +13,12 @@ import (
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/testing"
 )
@@ -157,6 +159,35 @@ var _ = Describe("Reconcile statefulsets for GreenplumCluster", func() {
 		})
 	}
 
+	When("updating the segment-a statefulset conflicts", func() {
+		BeforeEach(func() {
+			segmentA := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "segment-a"},
+				Spec: appsv1.StatefulSetSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Image: "oops-wrong-image"}},
+						},
+					}},
+			}
+			Expect(reactiveClient.Create(ctx, segmentA)).To(Succeed())
+			conflictErr := apierrs.NewConflict(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, "segment-a",
+				errors.New("the object has been modified; please apply your changes to the latest version and try again"))
+			reactiveClient.AddNamedErrorReactor("update", "statefulsets", "segment-a", 1, conflictErr)
+		})
+		It("returns the conflict, so that the reconcile is retried", func() {
+			Expect(apierrs.IsConflict(reconcileErr)).To(BeTrue(), "expected a conflict, got %v", reconcileErr)
+		})
+		It("updates the statefulset when the reconcile is retried", func() {
+			_, err := greenplumReconciler.Reconcile(context.TODO(), greenplumClusterRequest)
+			Expect(err).NotTo(HaveOccurred())
+			var statefulset appsv1.StatefulSet
+			statefulsetKey := types.NamespacedName{Namespace: namespaceName, Name: "segment-a"}
+			Expect(reactiveClient.Get(ctx, statefulsetKey, &statefulset)).To(Succeed())
+			Expect(statefulset.Spec.Template.Spec.Containers[0].Image).To(Equal("greenplum-for-kubernetes:v1.0"))
+		})
+	})
+
 	When(`mirrors: "no"`, func() {
 		BeforeEach(func() {
 			greenplumCluster.Spec.Segments.Mirrors = "no"
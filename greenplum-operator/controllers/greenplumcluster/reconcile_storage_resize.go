@@ -0,0 +1,229 @@
+package greenplumcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// How often to check on data PVCs that are being resized
+	storageResizePollInterval = 30 * time.Second
+
+	// How long the kubelet may take to resize the filesystem of a resized data PVC online
+	// before its pod is restarted to finish the resize
+	fileSystemResizeRestartDelay = 2 * time.Minute
+)
+
+// handleStorageResize grows the data PVCs of the cluster when spec.masterAndStandby.storage or
+// spec.segments.storage is increased, since the volumeClaimTemplates of the statefulsets cannot change.
+// A PVC is only resized if its storage class allows volume expansion. When the filesystem of a resized
+// PVC is not expanded online, its segment pod is restarted, one pod at a time, once the cluster is
+// healthy; master pods and unmirrored segment pods are left for the administrator to restart. PVCs that
+// could not be patched are retried on the next poll. Progress is reported in the StorageResizing condition,
+// and it returns how long to wait before checking again.
+func (r *GreenplumClusterReconciler) handleStorageResize(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (time.Duration, error) {
+	var resizing, expansionNotAllowed, resizeFailed, restartRequired []string
+	var restartCandidate string
+	for _, podName := range greenplumPodNames(greenplumCluster) {
+		desired := greenplumCluster.Spec.Segments.Storage
+		if strings.HasPrefix(podName, "master-") {
+			desired = greenplumCluster.Spec.MasterAndStandby.Storage
+		}
+		var pvc corev1.PersistentVolumeClaim
+		pvcKey := types.NamespacedName{Namespace: greenplumCluster.Namespace, Name: fmt.Sprintf("%s-pgdata-%s", greenplumCluster.Name, podName)}
+		if err := r.Get(ctx, pvcKey, &pvc); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return 0, fmt.Errorf("getting PVC %s: %w", pvcKey.Name, err)
+		}
+		requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if pvc.Status.Phase != corev1.ClaimBound || requested.IsZero() {
+			continue
+		}
+
+		if requested.Cmp(desired) < 0 {
+			allowed, err := r.storageClassAllowsExpansion(ctx, pvc.Spec.StorageClassName)
+			if err != nil {
+				return 0, err
+			}
+			if !allowed {
+				expansionNotAllowed = append(expansionNotAllowed, pvc.Name)
+				continue
+			}
+			if err := r.resizePVC(ctx, &pvc, desired); err != nil {
+				r.Log.Info("unable to resize PVC; retrying", "pvc", pvc.Name, "error", err.Error())
+				resizeFailed = append(resizeFailed, pvc.Name)
+				continue
+			}
+			r.Log.Info("resizing PVC", "pvc", pvc.Name, "from", requested.String(), "to", desired.String())
+			resizing = append(resizing, pvc.Name)
+			continue
+		}
+
+		capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+		if capacity.IsZero() || capacity.Cmp(desired) >= 0 {
+			continue
+		}
+		resizing = append(resizing, pvc.Name)
+		if pendingSince, pending := fileSystemResizePendingSince(&pvc); pending && time.Since(pendingSince) >= fileSystemResizeRestartDelay {
+			if strings.HasPrefix(podName, "segment-") && greenplumCluster.Spec.Segments.Mirrors == "yes" {
+				if restartCandidate == "" {
+					restartCandidate = podName
+				}
+			} else {
+				restartRequired = append(restartRequired, podName)
+			}
+		}
+	}
+
+	if restartCandidate != "" {
+		safe, err := r.safeToRestartSegmentPod(ctx, greenplumCluster)
+		if err != nil {
+			return 0, err
+		}
+		if safe {
+			r.Log.Info("restarting segment pod to finish resizing its data volume", "pod", restartCandidate)
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: greenplumCluster.Namespace, Name: restartCandidate}}
+			if err := r.Delete(ctx, pod); err != nil && !apierrs.IsNotFound(err) {
+				return 0, fmt.Errorf("restarting %s: %w", restartCandidate, err)
+			}
+			r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "RestartingForResize",
+				fmt.Sprintf("restarting %s to finish resizing its data volume", restartCandidate))
+		}
+	}
+
+	var reason string
+	var messages []string
+	if len(expansionNotAllowed) > 0 {
+		reason = "ExpansionNotAllowed"
+		messages = append(messages, "the storage class does not allow volume expansion for: "+strings.Join(expansionNotAllowed, ", "))
+	}
+	if len(resizeFailed) > 0 {
+		if reason == "" {
+			reason = "ResizeFailed"
+		}
+		messages = append(messages, "retrying resizing: "+strings.Join(resizeFailed, ", "))
+	}
+	if len(restartRequired) > 0 {
+		if reason == "" {
+			reason = "RestartRequired"
+		}
+		messages = append(messages, "restart pods to finish resizing their data volumes: "+strings.Join(restartRequired, ", "))
+	}
+	if len(resizing) > 0 {
+		if reason == "" {
+			reason = "Resizing"
+		}
+		messages = append(messages, "resizing: "+strings.Join(resizing, ", "))
+	}
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if reason != "" {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionStorageResizing,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             reason,
+			Message:            strings.Join(messages, "; "),
+		})
+	} else if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionStorageResizing) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionStorageResizing,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "StorageResized",
+			Message:            "all data volumes have the requested storage",
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return 0, fmt.Errorf("updating storage resizing condition: %w", err)
+		}
+	}
+	if reason == "" {
+		return 0, nil
+	}
+	return storageResizePollInterval, nil
+}
+
+func (r *GreenplumClusterReconciler) storageClassAllowsExpansion(ctx context.Context, storageClassName *string) (bool, error) {
+	if storageClassName == nil || *storageClassName == "" {
+		return false, nil
+	}
+	var storageClass storagev1.StorageClass
+	if err := r.Get(ctx, types.NamespacedName{Name: *storageClassName}, &storageClass); err != nil {
+		if apierrs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting storage class %s: %w", *storageClassName, err)
+	}
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// resizePVC raises the storage request of the PVC, which is the only part of its spec that may change
+func (r *GreenplumClusterReconciler) resizePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, storage resource.Quantity) error {
+	originalPVC := pvc.DeepCopy()
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = storage
+	return r.Patch(ctx, pvc, client.MergeFrom(originalPVC))
+}
+
+// safeToRestartSegmentPod reports whether a segment pod may be restarted without taking a segment
+// content offline: the cluster is Running, no segment is down, every mirror is in sync with its primary,
+// and every Greenplum pod has been ready for long enough that FTS would have marked down a segment whose
+// pod was just restarted. A primary whose mirror is still catching up is ready, but cannot fail over to it.
+func (r *GreenplumClusterReconciler) safeToRestartSegmentPod(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (bool, error) {
+	if greenplumCluster.Status.Phase != greenplumv1.GreenplumClusterPhaseRunning {
+		return false, nil
+	}
+	if segments := greenplumCluster.Status.Segments; segments != nil && segments.PrimariesDown+segments.MirrorsDown > 0 {
+		return false, nil
+	}
+	if meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionMirrorsNotInSync) {
+		return false, nil
+	}
+	var podList corev1.PodList
+	labelMatcher := client.MatchingLabels{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": greenplumCluster.Name,
+	}
+	if err := r.List(ctx, &podList, labelMatcher, client.InNamespace(greenplumCluster.Namespace)); err != nil {
+		return false, fmt.Errorf("listing pods: %w", err)
+	}
+	for _, pod := range podList.Items {
+		readyLongEnough := false
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				readyLongEnough = time.Since(condition.LastTransitionTime.Time) >= fileSystemResizeRestartDelay
+			}
+		}
+		if !readyLongEnough {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fileSystemResizePendingSince reports whether the volume of the PVC has been resized, but not its
+// filesystem, and if so, since when
+func fileSystemResizePendingSince(pvc *corev1.PersistentVolumeClaim) (time.Time, bool) {
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending && condition.Status == corev1.ConditionTrue {
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,302 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile storage resize for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		recorder            *record.FakeRecorder
+		allowExpansion      bool
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(gbytes.NewBuffer()),
+			SSHCreator:    fakeSecretCreator{},
+			InstanceImage: "greenplum-for-kubernetes:latest",
+			PodExec:       &fake.PodExec{},
+			Recorder:      recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+		greenplumCluster.Spec.Segments.Storage = resource.MustParse("2G")
+		greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+		allowExpansion = true
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		storageClass := &storagev1.StorageClass{
+			ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+			Provisioner:          "kubernetes.io/no-provisioner",
+			AllowVolumeExpansion: &allowExpansion,
+		}
+		Expect(reactiveClient.Create(ctx, storageClass)).To(Succeed())
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	createPVC := func(podName, requested, capacity string, conditions ...corev1.PersistentVolumeClaimCondition) {
+		storageClassName := "standard"
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "my-greenplum-pgdata-" + podName},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClassName,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(requested)},
+				},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase:      corev1.ClaimBound,
+				Capacity:   corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+				Conditions: conditions,
+			},
+		}
+		Expect(reactiveClient.Create(ctx, pvc)).To(Succeed())
+	}
+	requestedStorage := func(podName string) resource.Quantity {
+		var pvc corev1.PersistentVolumeClaim
+		Expect(reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: "my-greenplum-pgdata-" + podName}, &pvc)).To(Succeed())
+		return pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+	createReadyPod := func(name string, readyFor time.Duration) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespaceName,
+				Name:      name,
+				Labels:    map[string]string{"app": "greenplum", "greenplum-cluster": "my-greenplum"},
+			},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodReady,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-readyFor)),
+				}},
+			},
+		}
+		Expect(reactiveClient.Create(ctx, pod)).To(Succeed())
+	}
+	podExists := func(name string) bool {
+		var pod corev1.Pod
+		err := reactiveClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: name}, &pod)
+		if apierrs.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+	storageResizingCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionStorageResizing)
+	}
+	fileSystemResizePending := func(since time.Duration) corev1.PersistentVolumeClaimCondition {
+		return corev1.PersistentVolumeClaimCondition{
+			Type:               corev1.PersistentVolumeClaimFileSystemResizePending,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+		}
+	}
+
+	When("the segment storage is increased", func() {
+		BeforeEach(func() {
+			createPVC("master-0", "1G", "1G")
+			createPVC("segment-a-0", "1G", "1G")
+			createPVC("segment-b-0", "1G", "1G")
+		})
+
+		It("resizes the segment PVCs", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(requestedStorage("segment-a-0")).To(Equal(resource.MustParse("2G")))
+			Expect(requestedStorage("segment-b-0")).To(Equal(resource.MustParse("2G")))
+			Expect(requestedStorage("master-0")).To(Equal(resource.MustParse("1G")))
+		})
+
+		It("reports the resize and polls it", func() {
+			Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("Resizing"),
+				"Message": Equal("resizing: my-greenplum-pgdata-segment-a-0, my-greenplum-pgdata-segment-b-0"),
+			})))
+			Expect(reconcileResult.RequeueAfter).To(BeNumerically("<=", 30*time.Second))
+		})
+
+		When("the storage class does not allow volume expansion", func() {
+			BeforeEach(func() {
+				allowExpansion = false
+			})
+			It("does not resize the PVCs", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(requestedStorage("segment-a-0")).To(Equal(resource.MustParse("1G")))
+				Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionTrue),
+					"Reason": Equal("ExpansionNotAllowed"),
+					"Message": Equal("the storage class does not allow volume expansion for: " +
+						"my-greenplum-pgdata-segment-a-0, my-greenplum-pgdata-segment-b-0"),
+				})))
+			})
+		})
+
+		When("resizing one of the PVCs fails", func() {
+			BeforeEach(func() {
+				reactiveClient.AddNamedErrorReactor("patch", "persistentvolumeclaims", "my-greenplum-pgdata-segment-a-0", 1, errors.New("injected error"))
+			})
+			It("resizes the others and reports the failure", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(requestedStorage("segment-a-0")).To(Equal(resource.MustParse("1G")))
+				Expect(requestedStorage("segment-b-0")).To(Equal(resource.MustParse("2G")))
+				Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("ResizeFailed"),
+					"Message": Equal("retrying resizing: my-greenplum-pgdata-segment-a-0; resizing: my-greenplum-pgdata-segment-b-0"),
+				})))
+			})
+			It("retries it on the next reconcile", func() {
+				_, err := greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requestedStorage("segment-a-0")).To(Equal(resource.MustParse("2G")))
+			})
+		})
+	})
+
+	When("a resized segment PVC waits for a pod restart to resize its filesystem", func() {
+		BeforeEach(func() {
+			createPVC("segment-a-0", "2G", "1G", fileSystemResizePending(5*time.Minute))
+			createPVC("segment-b-0", "2G", "2G")
+			createReadyPod("master-0", time.Hour)
+			createReadyPod("segment-a-0", time.Hour)
+			createReadyPod("segment-b-0", time.Hour)
+		})
+
+		It("restarts the segment pod", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExists("segment-a-0")).To(BeFalse())
+			Expect(podExists("segment-b-0")).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Normal RestartingForResize restarting segment-a-0 to finish resizing its data volume")))
+			Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("Resizing"),
+				"Message": Equal("resizing: my-greenplum-pgdata-segment-a-0"),
+			})))
+		})
+
+		When("another pod was restarted recently", func() {
+			BeforeEach(func() {
+				Expect(reactiveClient.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "segment-b-0"}})).To(Succeed())
+				createReadyPod("segment-b-0", 30*time.Second)
+			})
+			It("waits before restarting it", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExists("segment-a-0")).To(BeTrue())
+			})
+		})
+
+		When("a segment is down", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Segments = &greenplumv1.GreenplumSegmentsStatus{Primaries: 1, Mirrors: 1, MirrorsDown: 1}
+			})
+			It("waits before restarting it", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExists("segment-a-0")).To(BeTrue())
+			})
+		})
+
+		When("a mirror is not in sync with its primary", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:               greenplumv1.GreenplumClusterConditionMirrorsNotInSync,
+					Status:             metav1.ConditionTrue,
+					Reason:             "Resynchronizing",
+					LastTransitionTime: metav1.Now(),
+				}}
+			})
+			It("waits before restarting it", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExists("segment-a-0")).To(BeTrue())
+			})
+		})
+
+		When("the cluster has no mirrors", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.Mirrors = "no"
+				greenplumCluster.Spec.MasterAndStandby.AntiAffinity = "no"
+				greenplumCluster.Spec.Segments.AntiAffinity = "no"
+			})
+			It("asks for the pod to be restarted instead", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExists("segment-a-0")).To(BeTrue())
+				Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionTrue),
+					"Reason":  Equal("RestartRequired"),
+					"Message": Equal("restart pods to finish resizing their data volumes: segment-a-0; resizing: my-greenplum-pgdata-segment-a-0"),
+				})))
+			})
+		})
+	})
+
+	When("a resized master PVC waits for a pod restart to resize its filesystem", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.MasterAndStandby.Storage = resource.MustParse("2G")
+			createPVC("master-0", "2G", "1G", fileSystemResizePending(5*time.Minute))
+			createReadyPod("master-0", time.Hour)
+		})
+		It("asks for the pod to be restarted", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(podExists("master-0")).To(BeTrue())
+			Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Reason":  Equal("RestartRequired"),
+				"Message": HavePrefix("restart pods to finish resizing their data volumes: master-0"),
+			})))
+		})
+	})
+
+	When("the PVCs have been resized", func() {
+		BeforeEach(func() {
+			createPVC("segment-a-0", "2G", "2G")
+			createPVC("segment-b-0", "2G", "2G")
+			greenplumCluster.Status.Conditions = []metav1.Condition{{
+				Type:               greenplumv1.GreenplumClusterConditionStorageResizing,
+				Status:             metav1.ConditionTrue,
+				Reason:             "Resizing",
+				Message:            "resizing: my-greenplum-pgdata-segment-a-0",
+				LastTransitionTime: metav1.Now(),
+			}}
+		})
+		It("clears the condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(storageResizingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionFalse),
+				"Reason": Equal("StorageResized"),
+			})))
+		})
+	})
+})
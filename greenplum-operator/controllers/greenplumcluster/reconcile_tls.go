@@ -0,0 +1,238 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often to check the TLS Secret for a renewed certificate, and its expiry
+const tlsPollInterval = 5 * time.Minute
+
+// installTLSCertificateScript copies the files of the mounted Secret into clienttls.InstallDir, readable only by
+// gpadmin, and prints "installed" if any of them changed
+var installTLSCertificateScript = fmt.Sprintf(`changed=; for file in %[3]s %[4]s %[5]s; do `+
+	`[ -f %[1]s/$file ] || continue; `+
+	`cmp -s %[1]s/$file %[2]s/$file && continue; `+
+	`mkdir -p %[2]s && install -m 0600 %[1]s/$file %[2]s/$file || exit 1; `+
+	`changed=1; `+
+	`done; [ -z "$changed" ] || echo installed`,
+	clienttls.MountPath, clienttls.InstallDir, clienttls.CertificateKey, clienttls.PrivateKeyKey, clienttls.CAKey)
+
+// handleTLS enables TLS for client connections to the master with the certificate in spec.tls. It installs the
+// certificate on the master and standby, and sets the ssl GUCs on the master only. Greenplum 6 only loads the
+// certificate on startup, so Greenplum must restart when the GUCs or the active master's certificate change, e.g.
+// when the Secret is renewed; see restartForTLS. A certificate that is invalid is not installed. The
+// TLSCertificateExpiring condition reports when the certificate expires.
+func (r *GreenplumClusterReconciler) handleTLS(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) (time.Duration, error) {
+	spec := greenplumCluster.Spec.TLS
+	if spec == nil {
+		return 0, nil
+	}
+	certPEM, keyPEM, caPEM, err := clienttls.GetSecret(ctx, r, greenplumCluster.Namespace, *spec)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	expiry, invalidErr := clienttls.Validate(certPEM, keyPEM, caPEM, clienttls.MasterDNSName(greenplumCluster.Namespace), now)
+	if err := r.setTLSCertificateCondition(ctx, greenplumCluster, expiry, invalidErr, now); err != nil {
+		return 0, err
+	}
+	if invalidErr != nil {
+		// The installed certificate, if any, keeps being served
+		r.Log.Info("not installing the TLS certificate", "secret", spec.SecretName, "reason", invalidErr.Error())
+		return tlsPollInterval, nil
+	}
+
+	restart := false
+	masterPods := []string{activeMaster}
+	if greenplumCluster.Spec.MasterAndStandby.Standby == "yes" {
+		masterPods = append(masterPods, otherMasterPod(activeMaster))
+	}
+	for _, podName := range masterPods {
+		installed, err := r.installTLSCertificate(greenplumCluster.Namespace, podName)
+		if err != nil && podName != activeMaster {
+			// The standby picks it up on a later reconcile; it only needs it once it is promoted
+			r.Log.Error(err, "unable to install the TLS certificate on the standby master", "pod", podName)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if installed {
+			r.Log.Info("installed TLS certificate", "pod", podName)
+			restart = restart || podName == activeMaster
+		}
+	}
+
+	// With interconnect TLS, ssl_ca_file already points at the interconnect CA, which the standby and
+	// segments are verified with
+	withCA := caPEM != nil && greenplumCluster.Spec.InterconnectTLS == nil
+	for _, guc := range clienttls.GUCs(withCA) {
+		currentValue, err := r.showGUC(greenplumCluster.Namespace, activeMaster, guc.Name)
+		if err != nil {
+			return 0, fmt.Errorf("getting current value of %s: %w", guc.Name, err)
+		}
+		if currentValue == guc.Value {
+			continue
+		}
+		if err := r.gpconfigMasterOnlyGUC(greenplumCluster.Namespace, activeMaster, guc.Name, guc.Value); err != nil {
+			return 0, fmt.Errorf("setting %s with gpconfig: %w", guc.Name, err)
+		}
+		r.Log.Info("wrote GUC with gpconfig; it takes effect after a restart", "name", guc.Name, "value", guc.Value, "previous value", currentValue)
+		restart = true
+	}
+
+	if restart || meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionTLSRestartPending) {
+		if err := r.restartForTLS(ctx, greenplumCluster, activeMaster); err != nil {
+			return 0, err
+		}
+	} else if _, requested := greenplumCluster.Annotations[greenplumv1.RestartForTLSAnnotation]; requested {
+		r.Log.Info("no restart is pending for the TLS certificate; removing the annotation")
+		originalGreenplumCluster := greenplumCluster.DeepCopy()
+		delete(greenplumCluster.Annotations, greenplumv1.RestartForTLSAnnotation)
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return 0, fmt.Errorf("removing the %s annotation: %w", greenplumv1.RestartForTLSAnnotation, err)
+		}
+	}
+	return tlsPollInterval, nil
+}
+
+// restartForTLS restarts Greenplum to load the certificate right away when spec.tls.automaticRestart is set, and
+// otherwise once the GreenplumCluster has the restart-for-tls annotation. Until then the TLSRestartPending condition
+// is True, which also keeps the restart pending across reconciles once the certificate and GUCs are in place.
+func (r *GreenplumClusterReconciler) restartForTLS(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, activeMaster string) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	secretName := greenplumCluster.Spec.TLS.SecretName
+	_, approved := greenplumCluster.Annotations[greenplumv1.RestartForTLSAnnotation]
+	if !greenplumCluster.Spec.TLS.AutomaticRestart && !approved {
+		if meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionTLSRestartPending) {
+			return nil
+		}
+		message := fmt.Sprintf("Greenplum must restart to load the certificate in Secret %s; annotate the GreenplumCluster with %s to restart it",
+			secretName, greenplumv1.RestartForTLSAnnotation)
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionTLSRestartPending,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "WaitingForApproval",
+			Message:            message,
+		})
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return fmt.Errorf("updating TLS restart condition: %w", err)
+		}
+		r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "TLSRestartPending", message)
+		return nil
+	}
+
+	r.recordEvent(greenplumCluster, corev1.EventTypeNormal, "RestartingForTLS", "restarting Greenplum to load the TLS certificate")
+	if err := r.restartGreenplum(greenplumCluster.Namespace, activeMaster); err != nil {
+		return fmt.Errorf("restarting Greenplum to load the TLS certificate: %w", err)
+	}
+	delete(greenplumCluster.Annotations, greenplumv1.RestartForTLSAnnotation)
+	if meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionTLSRestartPending) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionTLSRestartPending,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "Restarted",
+			Message:            fmt.Sprintf("restarted Greenplum to load the certificate in Secret %s", secretName),
+		})
+	}
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating TLS restart condition: %w", err)
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) setTLSCertificateCondition(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster, expiry time.Time, invalidErr error, now time.Time) error {
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	wasExpiring := meta.IsStatusConditionTrue(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionTLSCertificateExpiring)
+	condition := metav1.Condition{
+		Type:               greenplumv1.GreenplumClusterConditionTLSCertificateExpiring,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: greenplumCluster.Generation,
+		Reason:             "Valid",
+		Message:            fmt.Sprintf("certificate in Secret %s expires at %s", greenplumCluster.Spec.TLS.SecretName, expiry.UTC().Format(time.RFC3339)),
+	}
+	switch {
+	case invalidErr != nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Invalid"
+		condition.Message = fmt.Sprintf("certificate in Secret %s is not installed: %s", greenplumCluster.Spec.TLS.SecretName, invalidErr.Error())
+	case expiry.Sub(now) < clienttls.ExpiryWarningPeriod:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ExpiresSoon"
+	}
+	meta.SetStatusCondition(&greenplumCluster.Status.Conditions, condition)
+	if equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+		return fmt.Errorf("updating TLS certificate condition: %w", err)
+	}
+	if condition.Status == metav1.ConditionTrue && !wasExpiring {
+		r.recordEvent(greenplumCluster, corev1.EventTypeWarning, "TLSCertificateExpiring", condition.Message)
+	}
+	return nil
+}
+
+func (r *GreenplumClusterReconciler) installTLSCertificate(namespace, podName string) (bool, error) {
+	installCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		installTLSCertificateScript,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(installCommand, namespace, podName, stdoutBuf, stderrBuf); err != nil {
+		return false, fmt.Errorf("installing TLS certificate on %s: %w: %s", podName, err, stderrBuf.String())
+	}
+	return strings.TrimSpace(stdoutBuf.String()) == "installed", nil
+}
+
+// gpconfigMasterOnlyGUC sets the GUC on the master and standby, but not on the segments
+func (r *GreenplumClusterReconciler) gpconfigMasterOnlyGUC(namespace, activeMaster, name, value string) error {
+	gpconfigCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		fmt.Sprintf("source /usr/local/greenplum-db/greenplum_path.sh && gpconfig -c %s -v %s --masteronly", name, gpconfigValue(value)),
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(gpconfigCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("%w: %s", err, stderrBuf.String())
+	}
+	return nil
+}
+
+// restartGreenplum restarts the whole cluster in place, without restarting the pods. Sessions are disconnected
+func (r *GreenplumClusterReconciler) restartGreenplum(namespace, activeMaster string) error {
+	restartCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && gpstop -a -r -M fast",
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(restartCommand, namespace, activeMaster, stdoutBuf, stderrBuf); err != nil {
+		return fmt.Errorf("%w: %s", err, stderrBuf.String())
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package greenplumcluster_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	clienttlstesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls/testing"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile client TLS for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+		recorder            *record.FakeRecorder
+		secret              *corev1.Secret
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+			Recorder:   recorder,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM(
+			[]string{"greenplum.test-ns.svc.cluster.local"}, time.Now().Add(-time.Hour), time.Now().Add(90*24*time.Hour))
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "greenplum-tls"},
+			Data:       map[string][]byte{"server.crt": certPEM, "server.key": keyPEM},
+		}
+	})
+
+	var (
+		reconcileResult   ctrl.Result
+		reconcileErr      error
+		reconciledCluster greenplumv1.GreenplumCluster
+	)
+	JustBeforeEach(func() {
+		if greenplumCluster.Spec.TLS != nil {
+			Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+		}
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		reconcileResult, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	commandsContaining := func(substr string) []string {
+		var commands []string
+		for _, command := range podExec.RecordedCommands {
+			if strings.Contains(command, substr) {
+				commands = append(commands, command)
+			}
+		}
+		return commands
+	}
+	tlsCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionTLSCertificateExpiring)
+	}
+	restartPendingCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(reconciledCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionTLSRestartPending)
+	}
+
+	When("tls is not set", func() {
+		It("does not configure TLS", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(commandsContaining("/etc/greenplum-tls/")).To(BeEmpty())
+			Expect(commandsContaining("--masteronly")).To(BeEmpty())
+			Expect(tlsCondition()).To(BeNil())
+		})
+	})
+
+	When("tls is set", func() {
+		BeforeEach(func() {
+			greenplumCluster.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-tls"}
+		})
+
+		When("TLS is not configured yet", func() {
+			BeforeEach(func() {
+				podExec.TLSCertificateChanged = true
+			})
+			It("installs the certificate on the master", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(commandsContaining("/etc/greenplum-tls/")).To(Equal([]string{
+					`/bin/bash -c -- changed=; for file in server.crt server.key ca.crt; do ` +
+						`[ -f /etc/greenplum-tls/$file ] || continue; ` +
+						`cmp -s /etc/greenplum-tls/$file /greenplum/tls/$file && continue; ` +
+						`mkdir -p /greenplum/tls && install -m 0600 /etc/greenplum-tls/$file /greenplum/tls/$file || exit 1; ` +
+						`changed=1; ` +
+						`done; [ -z "$changed" ] || echo installed`,
+				}))
+				Expect(logBuf).To(gbytes.Say(`"msg":"installed TLS certificate","pod":"master-0"`))
+			})
+			It("sets the ssl GUCs on the master only", func() {
+				Expect(commandsContaining("--masteronly")).To(Equal([]string{
+					"/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && gpconfig -c ssl -v on --masteronly",
+					// Quoted for postgresql.conf, and then for the shell
+					`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && gpconfig -c ssl_cert_file -v ''\''/greenplum/tls/server.crt'\''' --masteronly`,
+					`/bin/bash -c -- source /usr/local/greenplum-db/greenplum_path.sh && gpconfig -c ssl_key_file -v ''\''/greenplum/tls/server.key'\''' --masteronly`,
+				}))
+			})
+			It("waits for the restart-for-tls annotation before restarting Greenplum", func() {
+				Expect(commandsContaining("gpstop -a -r")).To(BeEmpty())
+				Expect(restartPendingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status": Equal(metav1.ConditionTrue),
+					"Reason": Equal("WaitingForApproval"),
+					"Message": Equal("Greenplum must restart to load the certificate in Secret greenplum-tls; " +
+						"annotate the GreenplumCluster with greenplum.pivotal.io/restart-for-tls to restart it"),
+				})))
+				Expect(recorder.Events).To(Receive(HavePrefix("Normal TLSRestartPending Greenplum must restart")))
+			})
+
+			When("automaticRestart is set", func() {
+				BeforeEach(func() {
+					greenplumCluster.Spec.TLS.AutomaticRestart = true
+				})
+				It("restarts Greenplum", func() {
+					Expect(commandsContaining("gpstop -a -r -M fast")).To(HaveLen(1))
+					Expect(podExec.CalledPodName).To(Equal("master-0"))
+					Expect(recorder.Events).To(Receive(Equal("Normal RestartingForTLS restarting Greenplum to load the TLS certificate")))
+					Expect(restartPendingCondition()).To(BeNil())
+				})
+			})
+
+			When("the GreenplumCluster has the restart-for-tls annotation", func() {
+				BeforeEach(func() {
+					greenplumCluster.Annotations = map[string]string{greenplumv1.RestartForTLSAnnotation: ""}
+				})
+				It("restarts Greenplum and removes the annotation", func() {
+					Expect(commandsContaining("gpstop -a -r -M fast")).To(HaveLen(1))
+					Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.RestartForTLSAnnotation))
+				})
+			})
+			It("reports that the certificate is valid", func() {
+				condition := tlsCondition()
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				Expect(condition.Reason).To(Equal("Valid"))
+				Expect(condition.Message).To(HavePrefix("certificate in Secret greenplum-tls expires at "))
+			})
+			It("requeues to pick up a renewed certificate", func() {
+				Expect(reconcileResult.RequeueAfter).To(BeNumerically(">", 0))
+				Expect(reconcileResult.RequeueAfter).To(BeNumerically("<=", 5*time.Minute))
+			})
+
+			When("the Secret has a CA", func() {
+				BeforeEach(func() {
+					secret.Data["ca.crt"] = secret.Data["server.crt"]
+				})
+				It("sets ssl_ca_file", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(commandsContaining(`gpconfig -c ssl_ca_file -v ''\''/greenplum/tls/ca.crt'\''' --masteronly`)).To(HaveLen(1))
+				})
+
+				When("interconnectTLS is also set", func() {
+					BeforeEach(func() {
+						greenplumCluster.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{}
+					})
+					It("leaves ssl_ca_file pointing at the interconnect CA", func() {
+						Expect(reconcileErr).NotTo(HaveOccurred())
+						Expect(commandsContaining("gpconfig -c ssl_ca_file")).To(BeEmpty())
+					})
+				})
+			})
+
+			When("there is a standby master", func() {
+				BeforeEach(func() {
+					greenplumCluster.Spec.MasterAndStandby.Standby = "yes"
+				})
+				It("installs the certificate on the standby too", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(commandsContaining("/etc/greenplum-tls/")).To(HaveLen(2))
+					Expect(logBuf).To(gbytes.Say(`"msg":"installed TLS certificate","pod":"master-1"`))
+				})
+			})
+		})
+
+		When("TLS is already configured", func() {
+			BeforeEach(func() {
+				podExec.GUCValues = map[string]string{
+					"ssl":           "on",
+					"ssl_cert_file": "/greenplum/tls/server.crt",
+					"ssl_key_file":  "/greenplum/tls/server.key",
+				}
+			})
+			It("does not restart Greenplum", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(commandsContaining("--masteronly")).To(BeEmpty())
+				Expect(commandsContaining("gpstop -a -r")).To(BeEmpty())
+				Expect(recorder.Events).NotTo(Receive())
+			})
+
+			When("the certificate in the Secret was renewed", func() {
+				BeforeEach(func() {
+					podExec.TLSCertificateChanged = true
+					greenplumCluster.Spec.TLS.AutomaticRestart = true
+				})
+				It("restarts Greenplum to load it", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(commandsContaining("--masteronly")).To(BeEmpty())
+					Expect(commandsContaining("gpstop -a -r -M fast")).To(HaveLen(1))
+				})
+			})
+
+			When("a restart is pending", func() {
+				BeforeEach(func() {
+					greenplumCluster.Status.Conditions = []metav1.Condition{{
+						Type:               greenplumv1.GreenplumClusterConditionTLSRestartPending,
+						Status:             metav1.ConditionTrue,
+						Reason:             "WaitingForApproval",
+						LastTransitionTime: metav1.Now(),
+					}}
+				})
+				It("keeps waiting without emitting another event", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(commandsContaining("gpstop -a -r")).To(BeEmpty())
+					Expect(restartPendingCondition().Status).To(Equal(metav1.ConditionTrue))
+					Expect(recorder.Events).NotTo(Receive())
+				})
+
+				When("the GreenplumCluster is annotated with restart-for-tls", func() {
+					BeforeEach(func() {
+						greenplumCluster.Annotations = map[string]string{greenplumv1.RestartForTLSAnnotation: ""}
+					})
+					It("restarts Greenplum and clears the condition", func() {
+						Expect(reconcileErr).NotTo(HaveOccurred())
+						Expect(commandsContaining("gpstop -a -r -M fast")).To(HaveLen(1))
+						Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.RestartForTLSAnnotation))
+						Expect(restartPendingCondition()).To(PointTo(MatchFields(IgnoreExtras, Fields{
+							"Status":  Equal(metav1.ConditionFalse),
+							"Reason":  Equal("Restarted"),
+							"Message": Equal("restarted Greenplum to load the certificate in Secret greenplum-tls"),
+						})))
+					})
+				})
+			})
+
+			When("the GreenplumCluster is annotated with restart-for-tls but no restart is pending", func() {
+				BeforeEach(func() {
+					greenplumCluster.Annotations = map[string]string{greenplumv1.RestartForTLSAnnotation: ""}
+				})
+				It("removes the annotation without restarting", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(commandsContaining("gpstop -a -r")).To(BeEmpty())
+					Expect(reconciledCluster.Annotations).NotTo(HaveKey(greenplumv1.RestartForTLSAnnotation))
+				})
+			})
+		})
+
+		When("the certificate expires within 30 days", func() {
+			BeforeEach(func() {
+				certPEM, keyPEM := clienttlstesting.ServerCertificatePEM(
+					[]string{"greenplum.test-ns.svc.cluster.local"}, time.Now().Add(-time.Hour), time.Now().Add(10*24*time.Hour))
+				secret.Data = map[string][]byte{"server.crt": certPEM, "server.key": keyPEM}
+			})
+			It("sets the condition and emits a Warning event", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				condition := tlsCondition()
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				Expect(condition.Reason).To(Equal("ExpiresSoon"))
+				Expect(recorder.Events).To(Receive(HavePrefix("Warning TLSCertificateExpiring certificate in Secret greenplum-tls expires at ")))
+			})
+			It("still installs the certificate", func() {
+				Expect(commandsContaining("/etc/greenplum-tls/")).To(HaveLen(1))
+			})
+		})
+
+		When("the certificate has expired", func() {
+			BeforeEach(func() {
+				certPEM, keyPEM := clienttlstesting.ServerCertificatePEM(
+					[]string{"greenplum.test-ns.svc.cluster.local"}, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+				secret.Data = map[string][]byte{"server.crt": certPEM, "server.key": keyPEM}
+			})
+			It("reports it as invalid and does not install it", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				condition := tlsCondition()
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				Expect(condition.Reason).To(Equal("Invalid"))
+				Expect(condition.Message).To(Equal("certificate in Secret greenplum-tls is not installed: certificate expired at 2020-01-01T00:00:00Z"))
+				Expect(recorder.Events).To(Receive(Equal("Warning TLSCertificateExpiring " + condition.Message)))
+				Expect(commandsContaining("/etc/greenplum-tls/")).To(BeEmpty())
+				Expect(commandsContaining("gpstop -a -r")).To(BeEmpty())
+			})
+
+			When("the condition was already reported", func() {
+				BeforeEach(func() {
+					greenplumCluster.Status.Conditions = []metav1.Condition{{
+						Type:               greenplumv1.GreenplumClusterConditionTLSCertificateExpiring,
+						Status:             metav1.ConditionTrue,
+						Reason:             "Invalid",
+						Message:            "certificate in Secret greenplum-tls is not installed: certificate expired at 2020-01-01T00:00:00Z",
+						LastTransitionTime: metav1.Now(),
+					}}
+				})
+				It("does not emit another event", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(recorder.Events).NotTo(Receive())
+				})
+			})
+		})
+
+		When("the Secret does not exist", func() {
+			BeforeEach(func() {
+				secret.Name = "other-secret"
+			})
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError(`unable to configure TLS: Secret "greenplum-tls" not found`))
+			})
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package greenplumcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// e.g. postgres (Greenplum Database) 6.10.1 build commit:efba04ce26ebb29b535a255a5e95d1f5ebfde94e
+var greenplumVersionOutput = regexp.MustCompile(`\(Greenplum Database\) (\S+)`)
+
+// handleVersionMismatch compares the Greenplum version of each pod and reports pods that differ
+// in the VersionMismatch condition. It returns true if the versions differ, in which case
+// disruptive reconciles should be skipped. Pods that are not up yet are ignored.
+func (r *GreenplumClusterReconciler) handleVersionMismatch(ctx context.Context, greenplumCluster *greenplumv1.GreenplumCluster) (bool, error) {
+	podsByVersion := map[string][]string{}
+	for _, podName := range greenplumPodNames(greenplumCluster) {
+		version, ok := r.greenplumVersion(greenplumCluster.Namespace, podName)
+		if ok {
+			podsByVersion[version] = append(podsByVersion[version], podName)
+		}
+	}
+	versions := make([]string, 0, len(podsByVersion))
+	for version := range podsByVersion {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	mismatch := len(versions) > 1
+
+	originalGreenplumCluster := greenplumCluster.DeepCopy()
+	if mismatch {
+		var versionDescriptions []string
+		for _, version := range versions {
+			versionDescriptions = append(versionDescriptions, fmt.Sprintf("%s (%s)", version, strings.Join(podsByVersion[version], ", ")))
+		}
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionVersionMismatch,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "VersionMismatch",
+			Message:            "Greenplum versions differ across pods: " + strings.Join(versionDescriptions, "; "),
+		})
+	} else if len(versions) == 1 && meta.FindStatusCondition(greenplumCluster.Status.Conditions, greenplumv1.GreenplumClusterConditionVersionMismatch) != nil {
+		meta.SetStatusCondition(&greenplumCluster.Status.Conditions, metav1.Condition{
+			Type:               greenplumv1.GreenplumClusterConditionVersionMismatch,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: greenplumCluster.Generation,
+			Reason:             "VersionsMatch",
+			Message:            "all pods run Greenplum " + versions[0],
+		})
+	}
+	if !equality.Semantic.DeepEqual(greenplumCluster, originalGreenplumCluster) {
+		if mismatch {
+			r.Log.Info("detected Greenplum version mismatch", "versions", podsByVersion)
+		}
+		if err := r.Patch(ctx, greenplumCluster, client.MergeFrom(originalGreenplumCluster)); err != nil {
+			return mismatch, fmt.Errorf("updating version mismatch condition: %w", err)
+		}
+	}
+	return mismatch, nil
+}
+
+func (r *GreenplumClusterReconciler) greenplumVersion(namespace, podName string) (string, bool) {
+	versionCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		"source /usr/local/greenplum-db/greenplum_path.sh && postgres --gp-version",
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(versionCommand, namespace, podName, stdoutBuf, stderrBuf); err != nil {
+		r.Log.V(1).Info("Greenplum version check failed", "pod", podName, "error", err, "stderr", stderrBuf.String())
+		return "", false
+	}
+	output := strings.TrimSpace(stdoutBuf.String())
+	if match := greenplumVersionOutput.FindStringSubmatch(output); match != nil {
+		return match[1], true
+	}
+	return output, output != ""
+}
@@ -0,0 +1,121 @@
+package greenplumcluster_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Reconcile Greenplum version mismatch for GreenplumCluster", func() {
+	var (
+		ctx                 context.Context
+		logBuf              *gbytes.Buffer
+		greenplumReconciler *greenplumcluster.GreenplumClusterReconciler
+		greenplumCluster    *greenplumv1.GreenplumCluster
+		podExec             *fake.PodExec
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		greenplumReconciler = &greenplumcluster.GreenplumClusterReconciler{
+			Client:     reactiveClient,
+			Log:        gplog.ForTest(logBuf),
+			SSHCreator: fakeSecretCreator{},
+			PodExec:    podExec,
+		}
+		greenplumCluster = exampleGreenplumCluster.DeepCopy()
+		greenplumCluster.Spec.Segments.Mirrors = "yes"
+	})
+
+	var reconcileErr error
+	var reconciledCluster greenplumv1.GreenplumCluster
+	JustBeforeEach(func() {
+		Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+		_, reconcileErr = greenplumReconciler.Reconcile(ctx, greenplumClusterRequest)
+		Expect(reactiveClient.Get(ctx, greenplumClusterRequest.NamespacedName, &reconciledCluster)).To(Succeed())
+	})
+
+	When("all pods run the same Greenplum version", func() {
+		It("does not set a version mismatch condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionVersionMismatch)).To(BeNil())
+		})
+
+		When("the versions differed before", func() {
+			BeforeEach(func() {
+				greenplumCluster.Status.Conditions = []metav1.Condition{{
+					Type:    greenplumv1.GreenplumClusterConditionVersionMismatch,
+					Status:  metav1.ConditionTrue,
+					Reason:  "VersionMismatch",
+					Message: "Greenplum versions differ across pods: 6.0.0 (master-0); 6.1.0 (segment-a-0)",
+				}}
+			})
+			It("clears the version mismatch condition", func() {
+				condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+					greenplumv1.GreenplumClusterConditionVersionMismatch)
+				Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Status":  Equal(metav1.ConditionFalse),
+					"Reason":  Equal("VersionsMatch"),
+					"Message": Equal("all pods run Greenplum 6.0.0"),
+				})))
+			})
+		})
+	})
+
+	When("a segment runs a different Greenplum version", func() {
+		BeforeEach(func() {
+			podExec.GreenplumVersions = map[string]string{"segment-b-0": "6.1.0"}
+		})
+		It("succeeds", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+		})
+		It("sets the version mismatch condition", func() {
+			condition := meta.FindStatusCondition(reconciledCluster.Status.Conditions,
+				greenplumv1.GreenplumClusterConditionVersionMismatch)
+			Expect(condition).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionTrue),
+				"Reason":  Equal("VersionMismatch"),
+				"Message": Equal("Greenplum versions differ across pods: 6.0.0 (master-0, segment-a-0); 6.1.0 (segment-b-0)"),
+			})))
+			Expect(logBuf).To(gbytes.Say("detected Greenplum version mismatch"))
+		})
+
+		When("the cluster needs to be expanded", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Segments.PrimarySegmentCount = 2
+				podExec.SegmentCount = "1\n"
+			})
+			It("does not start gpexpand", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				var job batchv1.Job
+				jobKey := types.NamespacedName{Namespace: namespaceName, Name: clusterName + "-gpexpand-job"}
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(MatchError(ContainSubstring("not found")))
+				Expect(logBuf).To(gbytes.Say("skipping gpexpand and GUC changes until all pods run the same Greenplum version"))
+			})
+		})
+
+		When("an online GUC has changed", func() {
+			BeforeEach(func() {
+				greenplumCluster.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+				podExec.StdoutResult = "on\n"
+			})
+			It("does not run gpconfig", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(podExec.RecordedCommands).NotTo(ContainElement(ContainSubstring("gpconfig")))
+			})
+		})
+	})
+})
@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How often a pending restore checks whether it can start
+const restorePendingPollInterval = 30 * time.Second
+
+// GreenplumRestoreReconciler reconciles a GreenplumRestore object
+type GreenplumRestoreReconciler struct {
+	client.Client
+	Log           logr.Logger
+	InstanceImage string
+	PodExec       executor.PodExecInterface
+	Recorder      record.EventRecorder
+	// NewS3Lister connects to S3 to check the backup before restoring it
+	NewS3Lister func(s3 greenplumv1.GreenplumBackupS3Spec, accessKeyID, secretAccessKey string) (backupjob.S3Lister, error)
+	// Now is time.Now, unless replaced for testing
+	Now func() time.Time
+}
+
+var _ client.Client = &GreenplumRestoreReconciler{}
+
+// +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=greenplum.pivotal.io,resources=greenplumrestores/status,verbs=get;update;patch
+
+func (r *GreenplumRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var greenplumRestore greenplumv1.GreenplumRestore
+	if err := r.Get(ctx, req.NamespacedName, &greenplumRestore); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("unable to fetch GreenplumRestore: %w", err)
+	}
+
+	switch greenplumRestore.Status.Phase {
+	case greenplumv1.GreenplumRestorePhaseSucceeded, greenplumv1.GreenplumRestorePhaseFailed:
+		// the job is kept, so that its logs can be read
+		return ctrl.Result{}, nil
+	}
+
+	jobKey := restoreJobKey(&greenplumRestore)
+	var job batchv1.Job
+	if err := r.Get(ctx, jobKey, &job); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if greenplumRestore.Status.Phase == greenplumv1.GreenplumRestorePhaseRunning {
+			return ctrl.Result{}, r.finishRestore(ctx, &greenplumRestore, false,
+				"JobDeleted", fmt.Sprintf("gprestore job %s was deleted before it finished", jobKey.Name))
+		}
+		return r.startRestore(ctx, &greenplumRestore)
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		return ctrl.Result{}, r.finishRestore(ctx, &greenplumRestore, true, "RestoreSucceeded",
+			fmt.Sprintf("restored backup %s into database %s of GreenplumCluster %s",
+				greenplumRestore.Spec.Timestamp, backupjob.RestoreDatabase(greenplumRestore.Spec), greenplumRestore.Spec.ClusterName))
+	case job.Status.Failed > 0:
+		message, err := r.restoreFailureMessage(ctx, &job)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.finishRestore(ctx, &greenplumRestore, false, "JobFailed", message)
+	}
+	// the job is owned, so it finishing triggers another reconcile
+	return ctrl.Result{}, r.setRestoreRunning(ctx, &greenplumRestore, &job)
+}
+
+// startRestore creates the restore job once the cluster is Running. The restore fails without a job if the
+// backup does not fit the cluster, or would overwrite a database that the cluster is serving
+func (r *GreenplumRestoreReconciler) startRestore(ctx context.Context, greenplumRestore *greenplumv1.GreenplumRestore) (ctrl.Result, error) {
+	if err := backupjob.ValidateRestoreSpec(greenplumRestore.Spec); err != nil {
+		return ctrl.Result{}, r.finishRestore(ctx, greenplumRestore, false, "InvalidSpec", err.Error())
+	}
+
+	var greenplumCluster greenplumv1.GreenplumCluster
+	clusterKey := types.NamespacedName{Namespace: greenplumRestore.Namespace, Name: greenplumRestore.Spec.ClusterName}
+	if err := r.Get(ctx, clusterKey, &greenplumCluster); err != nil {
+		if apierrs.IsNotFound(err) {
+			return r.setRestorePending(ctx, greenplumRestore, fmt.Sprintf("GreenplumCluster %s not found", clusterKey.Name))
+		}
+		return ctrl.Result{}, err
+	}
+	if greenplumCluster.Status.Phase != greenplumv1.GreenplumClusterPhaseRunning {
+		return r.setRestorePending(ctx, greenplumRestore, fmt.Sprintf("waiting for GreenplumCluster %s to be Running", clusterKey.Name))
+	}
+
+	s3 := greenplumRestore.Spec.S3
+	var credentials corev1.Secret
+	credentialsKey := types.NamespacedName{Namespace: greenplumRestore.Namespace, Name: s3.Credentials.SecretName}
+	if err := r.Get(ctx, credentialsKey, &credentials); err != nil {
+		if apierrs.IsNotFound(err) {
+			return r.setRestorePending(ctx, greenplumRestore, fmt.Sprintf("S3 credentials Secret %s not found", credentialsKey.Name))
+		}
+		return ctrl.Result{}, err
+	}
+	if s3.Encryption != nil {
+		if err := backupjob.ValidateEncryptionSecret(ctx, r, greenplumRestore.Namespace, *s3.Encryption); err != nil {
+			return r.setRestorePending(ctx, greenplumRestore, "waiting for a valid encryption key: "+err.Error())
+		}
+	}
+	if caBundle := greenplumRestore.Spec.CABundle; caBundle != nil {
+		if err := cabundle.ValidateConfigMap(ctx, r, greenplumRestore.Namespace, *caBundle); err != nil {
+			return r.setRestorePending(ctx, greenplumRestore, "waiting for a valid caBundle: "+err.Error())
+		}
+	}
+
+	activeMaster := executor.GetCurrentActiveMaster(r.PodExec, greenplumRestore.Namespace)
+	if activeMaster == "" {
+		return r.setRestorePending(ctx, greenplumRestore, fmt.Sprintf("no active master found for GreenplumCluster %s", clusterKey.Name))
+	}
+
+	lister, err := r.NewS3Lister(s3,
+		string(credentials.Data[backupjob.S3AccessKeyIDKey(s3.Credentials)]),
+		string(credentials.Data[backupjob.S3SecretAccessKeyKey(s3.Credentials)]))
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("connecting to S3: %w", err)
+	}
+	backupSegmentCount, err := backupjob.BackupSegmentCount(lister, s3, greenplumRestore.Spec.Timestamp)
+	if errors.Is(err, backupjob.ErrBackupNotFound) {
+		return ctrl.Result{}, r.finishRestore(ctx, greenplumRestore, false, "BackupNotFound",
+			fmt.Sprintf("backup %s not found in s3://%s/%s", greenplumRestore.Spec.Timestamp, s3.Bucket, s3.Prefix))
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	clusterSegmentCount, err := r.getSegmentCount(greenplumRestore.Namespace, activeMaster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("counting segments of GreenplumCluster %s: %w", clusterKey.Name, err)
+	}
+	// a backup without table data, e.g. a metadata-only backup, can be restored into any number of segments
+	if backupSegmentCount != 0 && backupSegmentCount != clusterSegmentCount {
+		return ctrl.Result{}, r.finishRestore(ctx, greenplumRestore, false, "SegmentCountMismatch",
+			fmt.Sprintf("backup %s was taken from %d segments, but GreenplumCluster %s has %d primary segments; "+
+				"gprestore can only restore into a cluster with the same number of segments",
+				greenplumRestore.Spec.Timestamp, backupSegmentCount, clusterKey.Name, clusterSegmentCount))
+	}
+
+	database := backupjob.RestoreDatabase(greenplumRestore.Spec)
+	databaseExists, err := r.databaseExists(greenplumRestore.Namespace, activeMaster, database)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("listing databases of GreenplumCluster %s: %w", clusterKey.Name, err)
+	}
+	if databaseExists && !greenplumRestore.Spec.AllowOverwrite {
+		return ctrl.Result{}, r.finishRestore(ctx, greenplumRestore, false, "OverwriteRefused",
+			fmt.Sprintf("GreenplumCluster %s is serving database %s; set allowOverwrite to restore over it", clusterKey.Name, database))
+	}
+
+	jobKey := restoreJobKey(greenplumRestore)
+	hostname := fmt.Sprintf("%s.agent.%s.svc.cluster.local", activeMaster, greenplumRestore.Namespace)
+	job := backupjob.GenerateS3RestoreJob(r.InstanceImage, hostname, greenplumRestore.Spec, databaseExists)
+	job.Namespace = jobKey.Namespace
+	job.Name = jobKey.Name
+	job.Labels = map[string]string{
+		"greenplum-cluster": greenplumRestore.Spec.ClusterName,
+		"greenplum-restore": greenplumRestore.Name,
+	}
+	job.Spec.Template.Spec.SchedulerName = greenplumCluster.Spec.SchedulerName
+	if err := ctrl.SetControllerReference(greenplumRestore, &job, r.Scheme()); err != nil {
+		// not tested: not really possible to fail here
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, &job); err != nil {
+		return ctrl.Result{}, fmt.Errorf("creating gprestore job: %w", err)
+	}
+	r.Log.Info("started restore", "greenplumrestore", greenplumRestore.Name, "job", job.Name)
+	r.recordEvent(greenplumRestore, corev1.EventTypeNormal, "RestoreStarted",
+		fmt.Sprintf("restoring backup %s from s3://%s/%s into database %s of GreenplumCluster %s",
+			greenplumRestore.Spec.Timestamp, s3.Bucket, s3.Prefix, database, clusterKey.Name))
+	return ctrl.Result{}, r.setRestoreRunning(ctx, greenplumRestore, &job)
+}
+
+// getSegmentCount counts the primary segments of the cluster from the active master
+func (r *GreenplumRestoreReconciler) getSegmentCount(namespace, masterPodName string) (int, error) {
+	getSegmentCountCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		`source /usr/local/greenplum-db/greenplum_path.sh && psql -t -U gpadmin -c "SELECT COUNT(*) FROM gp_segment_configuration WHERE hostname LIKE 'segment-a%'"`,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(getSegmentCountCommand, namespace, masterPodName, stdoutBuf, stderrBuf); err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(stdoutBuf.String()))
+}
+
+// databaseExists checks from the active master whether the cluster has the database
+func (r *GreenplumRestoreReconciler) databaseExists(namespace, masterPodName, database string) (bool, error) {
+	listDatabasesCommand := []string{
+		"/bin/bash",
+		"-c",
+		"--",
+		`source /usr/local/greenplum-db/greenplum_path.sh && psql -U gpadmin -d postgres -tAc "SELECT datname FROM pg_database"`,
+	}
+	stdoutBuf := &bytes.Buffer{}
+	stderrBuf := &bytes.Buffer{}
+	if err := r.PodExec.Execute(listDatabasesCommand, namespace, masterPodName, stdoutBuf, stderrBuf); err != nil {
+		return false, err
+	}
+	for _, name := range strings.Split(stdoutBuf.String(), "\n") {
+		if name == database {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// restoreFailureMessage describes a failed job with the last error that gprestore logged
+func (r *GreenplumRestoreReconciler) restoreFailureMessage(ctx context.Context, job *batchv1.Job) (string, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return "", fmt.Errorf("listing gprestore job pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if restoreError := backupjob.RestoreError(pod); restoreError != "" {
+			return fmt.Sprintf("gprestore job %s failed: %s; check its logs", job.Name, restoreError), nil
+		}
+	}
+	return fmt.Sprintf("gprestore job %s failed; check its logs", job.Name), nil
+}
+
+func (r *GreenplumRestoreReconciler) setRestorePending(ctx context.Context, greenplumRestore *greenplumv1.GreenplumRestore, message string) (ctrl.Result, error) {
+	originalGreenplumRestore := greenplumRestore.DeepCopy()
+	greenplumRestore.Status.Phase = greenplumv1.GreenplumRestorePhasePending
+	greenplumRestore.Status.Message = message
+	if err := r.patchStatus(ctx, greenplumRestore, originalGreenplumRestore); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: restorePendingPollInterval}, nil
+}
+
+func (r *GreenplumRestoreReconciler) setRestoreRunning(ctx context.Context, greenplumRestore *greenplumv1.GreenplumRestore, job *batchv1.Job) error {
+	originalGreenplumRestore := greenplumRestore.DeepCopy()
+	greenplumRestore.Status.Phase = greenplumv1.GreenplumRestorePhaseRunning
+	greenplumRestore.Status.Message = ""
+	greenplumRestore.Status.JobName = job.Name
+	if greenplumRestore.Status.StartTime == nil {
+		startTime := metav1.NewTime(r.now())
+		greenplumRestore.Status.StartTime = &startTime
+	}
+	return r.patchStatus(ctx, greenplumRestore, originalGreenplumRestore)
+}
+
+// finishRestore sets the final phase and condition of the restore, and emits an Event
+func (r *GreenplumRestoreReconciler) finishRestore(ctx context.Context, greenplumRestore *greenplumv1.GreenplumRestore, succeeded bool, reason, message string) error {
+	originalGreenplumRestore := greenplumRestore.DeepCopy()
+	phase, conditionType, eventType := greenplumv1.GreenplumRestorePhaseFailed, greenplumv1.GreenplumRestoreConditionFailed, corev1.EventTypeWarning
+	if succeeded {
+		phase, conditionType, eventType = greenplumv1.GreenplumRestorePhaseSucceeded, greenplumv1.GreenplumRestoreConditionComplete, corev1.EventTypeNormal
+	}
+	greenplumRestore.Status.Phase = phase
+	greenplumRestore.Status.Message = message
+	completionTime := metav1.NewTime(r.now())
+	greenplumRestore.Status.CompletionTime = &completionTime
+	meta.SetStatusCondition(&greenplumRestore.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: greenplumRestore.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if err := r.patchStatus(ctx, greenplumRestore, originalGreenplumRestore); err != nil {
+		return err
+	}
+	r.Log.Info("restore finished", "greenplumrestore", greenplumRestore.Name, "phase", phase, "message", message)
+	r.recordEvent(greenplumRestore, eventType, reason, message)
+	return nil
+}
+
+func (r *GreenplumRestoreReconciler) patchStatus(ctx context.Context, greenplumRestore, originalGreenplumRestore *greenplumv1.GreenplumRestore) error {
+	if equality.Semantic.DeepEqual(greenplumRestore, originalGreenplumRestore) {
+		return nil
+	}
+	if err := r.Patch(ctx, greenplumRestore, client.MergeFrom(originalGreenplumRestore)); err != nil {
+		return fmt.Errorf("updating GreenplumRestore status: %w", err)
+	}
+	return nil
+}
+
+// recordEvent emits an Event on the GreenplumRestore, when the reconciler has a Recorder
+func (r *GreenplumRestoreReconciler) recordEvent(greenplumRestore *greenplumv1.GreenplumRestore, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(greenplumRestore, eventType, reason, message)
+}
+
+func (r *GreenplumRestoreReconciler) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
+
+func restoreJobKey(greenplumRestore *greenplumv1.GreenplumRestore) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: greenplumRestore.Namespace,
+		Name:      fmt.Sprintf("%s-gprestore-job", greenplumRestore.Name),
+	}
+}
+
+func (r *GreenplumRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&greenplumv1.GreenplumRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/minio/minio-go/v6"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	cabundletesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle/testing"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("GreenplumRestore controller", func() {
+	var (
+		ctx               context.Context
+		logBuf            *gbytes.Buffer
+		podExec           *fake.PodExec
+		recorder          *record.FakeRecorder
+		s3Lister          *fakeS3Lister
+		now               time.Time
+		restoreReconciler *GreenplumRestoreReconciler
+		greenplumRestore  *greenplumv1.GreenplumRestore
+		greenplumCluster  *greenplumv1.GreenplumCluster
+		credentials       *corev1.Secret
+
+		restoreRequest = reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: "test-ns", Name: "recover"},
+		}
+		jobKey    = types.NamespacedName{Namespace: "test-ns", Name: "recover-gprestore-job"}
+		backupDir = "greenplum/prod/backups/20200101/20200101020000/"
+	)
+
+	getRestore := func() *greenplumv1.GreenplumRestore {
+		var restore greenplumv1.GreenplumRestore
+		Expect(reactiveClient.Get(ctx, restoreRequest.NamespacedName, &restore)).To(Succeed())
+		return &restore
+	}
+
+	expectFailed := func(reason, message string) {
+		result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(reconcile.Result{}))
+
+		restore := getRestore()
+		Expect(restore.Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhaseFailed))
+		Expect(restore.Status.Message).To(Equal(message))
+		Expect(restore.Status.CompletionTime.Time).To(BeTemporally("==", now))
+		condition := meta.FindStatusCondition(restore.Status.Conditions, greenplumv1.GreenplumRestoreConditionFailed)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(reason))
+		Expect(condition.Message).To(Equal(message))
+		Expect(recorder.Events).To(Receive(Equal("Warning " + reason + " " + message)))
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		logBuf = gbytes.NewBuffer()
+		podExec = &fake.PodExec{}
+		recorder = record.NewFakeRecorder(10)
+		s3Lister = &fakeS3Lister{
+			objects: []minio.ObjectInfo{
+				{Key: backupDir + "gpbackup_20200101020000_metadata.sql"},
+				{Key: backupDir + "gpbackup_0_20200101020000_16384.gz"},
+			},
+		}
+		now = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		restoreReconciler = &GreenplumRestoreReconciler{
+			Client:        reactiveClient,
+			Log:           gplog.ForTest(logBuf),
+			InstanceImage: "greenplum-for-kubernetes:v1.7.5",
+			PodExec:       podExec,
+			Recorder:      recorder,
+			NewS3Lister: func(s3 greenplumv1.GreenplumBackupS3Spec, accessKeyID, secretAccessKey string) (backupjob.S3Lister, error) {
+				s3Lister.accessKeyID, s3Lister.secretAccessKey = accessKeyID, secretAccessKey
+				return s3Lister, nil
+			},
+			Now: func() time.Time { return now },
+		}
+
+		greenplumRestore = &greenplumv1.GreenplumRestore{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "recover"},
+			Spec: greenplumv1.GreenplumRestoreSpec{
+				ClusterName: "my-greenplum",
+				Timestamp:   "20200101020000",
+				Database:    "sales",
+				S3: greenplumv1.GreenplumBackupS3Spec{
+					Bucket:      "backups",
+					Prefix:      "greenplum/prod",
+					Region:      "us-east-1",
+					Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+				},
+			},
+		}
+		greenplumCluster = &greenplumv1.GreenplumCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-greenplum"},
+			Spec:       greenplumv1.GreenplumClusterSpec{SchedulerName: "my-scheduler"},
+			Status:     greenplumv1.GreenplumClusterStatus{Phase: greenplumv1.GreenplumClusterPhaseRunning},
+		}
+		credentials = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "s3-creds"},
+			Data: map[string][]byte{
+				"aws_access_key_id":     []byte("my-id"),
+				"aws_secret_access_key": []byte("my-secret"),
+			},
+		}
+	})
+
+	When("the restore does not exist", func() {
+		It("does nothing", func() {
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+		})
+	})
+
+	When("getting the restore fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("get", "greenplumrestores", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns the error", func() {
+			_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).To(MatchError("unable to fetch GreenplumRestore: injected error"))
+		})
+	})
+
+	When("a new restore can start", func() {
+		BeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+		})
+
+		JustBeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, greenplumRestore)).To(Succeed())
+		})
+
+		It("creates a restore job on the active master", func() {
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+
+			var job batchv1.Job
+			Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+			Expect(job.Labels).To(Equal(map[string]string{
+				"greenplum-cluster": "my-greenplum",
+				"greenplum-restore": "recover",
+			}))
+			Expect(job.OwnerReferences).To(HaveLen(1))
+			Expect(job.OwnerReferences[0].Kind).To(Equal("GreenplumRestore"))
+			Expect(job.OwnerReferences[0].Name).To(Equal("recover"))
+			restorePod := job.Spec.Template.Spec
+			Expect(restorePod.SchedulerName).To(Equal("my-scheduler"))
+			Expect(restorePod.Containers[0].Image).To(Equal("greenplum-for-kubernetes:v1.7.5"))
+			Expect(restorePod.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "GPBACKUP_HOST",
+				Value: "master-0.agent.test-ns.svc.cluster.local",
+			}))
+			Expect(restorePod.Containers[0].Args).To(Equal([]string{
+				"--timestamp", "20200101020000",
+				"--redirect-db", "sales",
+				"--plugin-config", "/tmp/gprestore_plugin_config.yaml",
+				"--create-db",
+			}))
+		})
+
+		It("checks the backup in S3 with the credentials from the Secret", func() {
+			_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s3Lister.prefix).To(Equal(backupDir))
+			Expect(s3Lister.accessKeyID).To(Equal("my-id"))
+			Expect(s3Lister.secretAccessKey).To(Equal("my-secret"))
+		})
+
+		It("marks the restore Running", func() {
+			_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			restore := getRestore()
+			Expect(restore.Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhaseRunning))
+			Expect(restore.Status.JobName).To(Equal("recover-gprestore-job"))
+			Expect(restore.Status.StartTime.Time).To(BeTemporally("==", now))
+			Expect(recorder.Events).To(Receive(Equal("Normal RestoreStarted restoring backup 20200101020000 from s3://backups/greenplum/prod into database sales of GreenplumCluster my-greenplum")))
+		})
+
+		When("the backup is not in S3", func() {
+			BeforeEach(func() {
+				s3Lister.objects = nil
+			})
+			It("fails the restore", func() {
+				expectFailed("BackupNotFound", "backup 20200101020000 not found in s3://backups/greenplum/prod")
+			})
+		})
+
+		When("listing the backup fails", func() {
+			BeforeEach(func() {
+				s3Lister.objects = []minio.ObjectInfo{{Err: errors.New("injected error")}}
+			})
+			It("returns the error", func() {
+				_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+				Expect(err).To(MatchError("listing s3://backups/" + backupDir + ": injected error"))
+				Expect(getRestore().Status.Phase).To(BeEmpty())
+			})
+		})
+
+		When("the backup was taken from a different number of segments", func() {
+			BeforeEach(func() {
+				podExec.SegmentCount = "2\n"
+			})
+			It("fails the restore without creating a job", func() {
+				expectFailed("SegmentCountMismatch", "backup 20200101020000 was taken from 1 segments, but GreenplumCluster my-greenplum has 2 primary segments; "+
+					"gprestore can only restore into a cluster with the same number of segments")
+				var job batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+			})
+		})
+
+		When("the backup has no table data", func() {
+			BeforeEach(func() {
+				podExec.SegmentCount = "2\n"
+				s3Lister.objects = s3Lister.objects[:1]
+			})
+			It("restores into any number of segments", func() {
+				_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(getRestore().Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhaseRunning))
+			})
+		})
+
+		When("counting the segments fails", func() {
+			BeforeEach(func() {
+				podExec.SegmentCountErr = errors.New("injected error")
+			})
+			It("returns the error", func() {
+				_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+				Expect(err).To(MatchError("counting segments of GreenplumCluster my-greenplum: injected error"))
+			})
+		})
+
+		When("the cluster is serving the database", func() {
+			BeforeEach(func() {
+				podExec.Databases = "postgres\ngpadmin\nsales\n"
+			})
+
+			It("refuses to overwrite it", func() {
+				expectFailed("OverwriteRefused", "GreenplumCluster my-greenplum is serving database sales; set allowOverwrite to restore over it")
+				var job batchv1.Job
+				Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+			})
+
+			When("allowOverwrite is set", func() {
+				BeforeEach(func() {
+					greenplumRestore.Spec.AllowOverwrite = true
+				})
+				It("drops the database before restoring", func() {
+					_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+					Expect(err).NotTo(HaveOccurred())
+
+					var job batchv1.Job
+					Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+					Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "GPRESTORE_DROP_DATABASE", Value: "true"}))
+				})
+			})
+		})
+
+		When("listing the databases fails", func() {
+			BeforeEach(func() {
+				podExec.DatabasesErr = errors.New("injected error")
+			})
+			It("returns the error", func() {
+				_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+				Expect(err).To(MatchError("listing databases of GreenplumCluster my-greenplum: injected error"))
+			})
+		})
+
+		When("the filters are invalid", func() {
+			BeforeEach(func() {
+				greenplumRestore.Spec.IncludeSchemas = []string{"tenant_a"}
+				greenplumRestore.Spec.IncludeTables = []string{"public.orders"}
+			})
+			It("fails the restore", func() {
+				expectFailed("InvalidSpec", "includeSchemas and includeTables cannot be used together")
+			})
+		})
+
+		When("the spec has a CA bundle", func() {
+			BeforeEach(func() {
+				greenplumRestore.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "s3-ca"}
+				Expect(reactiveClient.Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "s3-ca"},
+					Data:       map[string]string{"ca.crt": string(cabundletesting.SelfSignedCAPEM("s3-ca"))},
+				})).To(Succeed())
+			})
+			It("mounts it into the trust store of the restore job", func() {
+				_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				var job batchv1.Job
+				Expect(reactiveClient.Get(ctx, jobKey, &job)).To(Succeed())
+				Expect(job.Spec.Template.Spec.Volumes).To(ContainElement(cabundle.Volume(*greenplumRestore.Spec.CABundle)))
+				Expect(job.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+					Name:      "ca-bundle",
+					MountPath: "/etc/ssl/certs/greenplum-ca-bundle.crt",
+					SubPath:   "greenplum-ca-bundle.crt",
+					ReadOnly:  true,
+				}))
+			})
+		})
+
+		When("creating the job fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("create", "jobs", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("injected error")
+				})
+			})
+			It("returns the error", func() {
+				_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+				Expect(err).To(MatchError("creating gprestore job: injected error"))
+			})
+		})
+	})
+
+	When("the restore cannot start yet", func() {
+		expectPending := func(message string) {
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{RequeueAfter: 30 * time.Second}))
+
+			restore := getRestore()
+			Expect(restore.Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhasePending))
+			Expect(restore.Status.Message).To(Equal(message))
+			var job batchv1.Job
+			Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &job))).To(BeTrue())
+		}
+
+		BeforeEach(func() {
+			Expect(reactiveClient.Create(ctx, greenplumRestore)).To(Succeed())
+		})
+
+		It("waits for the cluster to exist", func() {
+			expectPending("GreenplumCluster my-greenplum not found")
+		})
+
+		It("waits for the cluster to be Running", func() {
+			greenplumCluster.Status.Phase = greenplumv1.GreenplumClusterPhasePending
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			expectPending("waiting for GreenplumCluster my-greenplum to be Running")
+		})
+
+		It("waits for the S3 credentials Secret", func() {
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			expectPending("S3 credentials Secret s3-creds not found")
+		})
+
+		It("waits for a valid encryption key", func() {
+			restore := getRestore()
+			restore.Spec.S3.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key"}
+			Expect(reactiveClient.Update(ctx, restore)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "backup-key"},
+				Data:       map[string][]byte{"encryption.key": []byte("too short")},
+			})).To(Succeed())
+			expectPending(`waiting for a valid encryption key: Secret "backup-key" key "encryption.key": must be a 256-bit key of 32 bytes, not 9 bytes`)
+		})
+
+		It("waits for a valid CA bundle", func() {
+			restore := getRestore()
+			restore.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "s3-ca"}
+			Expect(reactiveClient.Update(ctx, restore)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			expectPending(`waiting for a valid caBundle: ConfigMap "s3-ca" not found`)
+		})
+
+		It("waits for an active master", func() {
+			podExec.ErrorMsgOnMaster0 = "master-0 is not active"
+			podExec.ErrorMsgOnMaster1 = "master-1 is not active"
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+			expectPending("no active master found for GreenplumCluster my-greenplum")
+		})
+	})
+
+	When("the restore job is running", func() {
+		var job *batchv1.Job
+		BeforeEach(func() {
+			greenplumRestore.Status.Phase = greenplumv1.GreenplumRestorePhaseRunning
+			greenplumRestore.Status.JobName = jobKey.Name
+			Expect(reactiveClient.Create(ctx, greenplumRestore)).To(Succeed())
+			job = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: jobKey.Namespace, Name: jobKey.Name}}
+		})
+
+		It("waits for it to finish", func() {
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+			Expect(getRestore().Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhaseRunning))
+		})
+
+		It("succeeds once the job succeeds, keeping the job", func() {
+			job.Status.Succeeded = 1
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			_, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			restore := getRestore()
+			Expect(restore.Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhaseSucceeded))
+			Expect(restore.Status.CompletionTime.Time).To(BeTemporally("==", now))
+			Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, greenplumv1.GreenplumRestoreConditionComplete)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Normal RestoreSucceeded restored backup 20200101020000 into database sales of GreenplumCluster my-greenplum")))
+			Expect(reactiveClient.Get(ctx, jobKey, &batchv1.Job{})).To(Succeed())
+		})
+
+		It("fails with the error that gprestore reported once the job fails", func() {
+			job.Status.Failed = 1
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "recover-gprestore-job-abcde", Labels: map[string]string{"job-name": jobKey.Name}},
+				Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "gprestore",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						Message: "error=Encountered 2 errors during metadata restore\n",
+					}},
+				}}},
+			})).To(Succeed())
+			expectFailed("JobFailed", "gprestore job recover-gprestore-job failed: Encountered 2 errors during metadata restore; check its logs")
+			Expect(reactiveClient.Get(ctx, jobKey, &batchv1.Job{})).To(Succeed())
+		})
+
+		It("fails when the job fails without reporting an error", func() {
+			job.Status.Failed = 1
+			Expect(reactiveClient.Create(ctx, job)).To(Succeed())
+			expectFailed("JobFailed", "gprestore job recover-gprestore-job failed; check its logs")
+		})
+
+		It("fails when the job was deleted", func() {
+			expectFailed("JobDeleted", "gprestore job recover-gprestore-job was deleted before it finished")
+		})
+	})
+
+	When("the restore has finished", func() {
+		BeforeEach(func() {
+			greenplumRestore.Status.Phase = greenplumv1.GreenplumRestorePhaseFailed
+			Expect(reactiveClient.Create(ctx, greenplumRestore)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, greenplumCluster)).To(Succeed())
+			Expect(reactiveClient.Create(ctx, credentials)).To(Succeed())
+		})
+
+		It("does not restore again", func() {
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+			Expect(apierrs.IsNotFound(reactiveClient.Get(ctx, jobKey, &batchv1.Job{}))).To(BeTrue())
+			Expect(getRestore().Status.Phase).To(Equal(greenplumv1.GreenplumRestorePhaseFailed))
+		})
+	})
+})
@@ -0,0 +1,61 @@
+package addmirrorsjob
+
+import (
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GenerateJob returns a job that runs gpaddmirrors on the master at hostname, adding a mirror on
+// segment-b-N in mirrorDomain for the primary on segment-a-N
+func GenerateJob(image, hostname, mirrorDomain string) (job batchv1.Job) {
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	addMirrorsPod := &job.Spec.Template.Spec
+	addMirrorsPod.RestartPolicy = corev1.RestartPolicyNever
+
+	addMirrorsPod.Volumes = []corev1.Volume{
+		{
+			Name: "ssh-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  "ssh-secrets",
+					DefaultMode: heapvalue.NewInt32(0444),
+				},
+			},
+		},
+	}
+	addMirrorsPod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+	addMirrorsPod.Containers = []corev1.Container{
+		{
+			Name:  "gpaddmirrors",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gpaddmirrors_job.sh",
+			},
+			Env: []corev1.EnvVar{
+				{
+					Name:  "GPADDMIRRORS_HOST",
+					Value: hostname,
+				},
+				{
+					Name:  "MIRROR_DOMAIN",
+					Value: mirrorDomain,
+				},
+			},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ssh-key",
+					MountPath: "/etc/ssh-key",
+				},
+			},
+		},
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+package addmirrorsjob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("GenerateJob", func() {
+	It("sets properties on the job", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0.agent.default.svc.cluster.local", "agent.default.svc.cluster.local")
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		addMirrorsPod := job.Spec.Template.Spec
+		Expect(addMirrorsPod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+
+		sshSecretVolume := addMirrorsPod.Volumes[0]
+		Expect(sshSecretVolume.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolume.VolumeSource.Secret.SecretName).To(Equal("ssh-secrets"))
+		Expect(sshSecretVolume.VolumeSource.Secret.DefaultMode).To(gstruct.PointTo(Equal(int32(0444))))
+
+		Expect(addMirrorsPod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+		addMirrorsContainer := addMirrorsPod.Containers[0]
+		Expect(addMirrorsContainer.Name).To(Equal("gpaddmirrors"))
+		Expect(addMirrorsContainer.Env).To(ConsistOf(
+			corev1.EnvVar{
+				Name:  "GPADDMIRRORS_HOST",
+				Value: "master-0.agent.default.svc.cluster.local",
+			},
+			corev1.EnvVar{
+				Name:  "MIRROR_DOMAIN",
+				Value: "agent.default.svc.cluster.local",
+			},
+		))
+		Expect(addMirrorsContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(addMirrorsContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(addMirrorsContainer.Command).To(Equal([]string{
+			"/home/gpadmin/tools/gpaddmirrors_job.sh",
+		}))
+
+		sshSecretVolumeMount := addMirrorsContainer.VolumeMounts[0]
+		Expect(sshSecretVolumeMount.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolumeMount.MountPath).To(Equal("/etc/ssh-key"))
+	})
+})
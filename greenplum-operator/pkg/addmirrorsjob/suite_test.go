@@ -0,0 +1,13 @@
+package addmirrorsjob
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAddmirrorsjob(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "addmirrorsjob Suite")
+}
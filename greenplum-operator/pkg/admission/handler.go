@@ -1,6 +1,7 @@
 package admission
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -30,6 +31,7 @@ func (h *Handler) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ready", h.HandleReady)
 	mux.HandleFunc("/validate", h.HandleValidate)
+	mux.HandleFunc("/mutate", h.HandleMutate)
 	return mux
 }
 
@@ -41,8 +43,21 @@ func (h *Handler) HandleReady(out http.ResponseWriter, req *http.Request) {
 }
 
 func (h *Handler) HandleValidate(out http.ResponseWriter, req *http.Request) {
+	handleReview(out, req, "/validate", h.validate)
+}
+
+func (h *Handler) HandleMutate(out http.ResponseWriter, req *http.Request) {
+	handleReview(out, req, "/mutate", h.mutate)
+}
+
+// reviewFunc decides an admission request whose kind is reqGVK
+type reviewFunc func(ctx context.Context, reqGVK schema.GroupVersionKind, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse
+
+// handleReview decodes the AdmissionReview in req, has review decide it, and writes the response, logging
+// the request and its outcome under path
+func handleReview(out http.ResponseWriter, req *http.Request, path string, review reviewFunc) {
 	log := Log
-	defer func() { log.Info("/validate") }()
+	defer func() { log.Info(path) }()
 
 	ctx := req.Context()
 
@@ -81,63 +96,135 @@ func (h *Handler) HandleValidate(out http.ResponseWriter, req *http.Request) {
 			return
 		}
 
-		switch reqGVK {
-		case greenplumv1.GroupVersion.WithKind("GreenplumCluster"):
-			var oldGreenplum, newGreenplum greenplumv1.GreenplumCluster
-			if err := json.Unmarshal(reviewRequest.Request.Object.Raw, &newGreenplum); err != nil {
-				response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumCluster: " + err.Error()}
+		response = review(ctx, reqGVK, reviewRequest.Request)
+		response.UID = reviewRequest.Request.UID
+		return
+	}()
+
+	log = log.WithValues("Allowed", reviewResponse.Response.Allowed)
+	if reviewResponse.Response.Patch != nil {
+		log = log.WithValues("Patched", true)
+	}
+	if reviewResponse.Response.Result != nil && reviewResponse.Response.Result.Message != "" {
+		log = log.WithValues("Message", reviewResponse.Response.Result.Message)
+	}
+
+	outBytes, _ := json.Marshal(reviewResponse)
+	_, err = out.Write(outBytes)
+	if err != nil {
+		Log.Error(err, "responding to admission review")
+	}
+}
+
+// validate decides whether a GreenplumCluster, GreenplumPXFService, GreenplumBackup or GreenplumBackupSchedule
+// may be created or updated
+func (h *Handler) validate(ctx context.Context, reqGVK schema.GroupVersionKind, request *admissionv1beta1.AdmissionRequest) (response *admissionv1beta1.AdmissionResponse) {
+	response = &admissionv1beta1.AdmissionResponse{}
+	switch reqGVK {
+	case greenplumv1.GroupVersion.WithKind("GreenplumCluster"):
+		var oldGreenplum, newGreenplum greenplumv1.GreenplumCluster
+		if err := json.Unmarshal(request.Object.Raw, &newGreenplum); err != nil {
+			response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumCluster: " + err.Error()}
+			return
+		}
+		op := request.Operation
+		switch op {
+		case admissionv1beta1.Create:
+			response.Allowed, response.Result = h.validateCreateGreenplumCluster(ctx, newGreenplum)
+		case admissionv1beta1.Update:
+			if err := json.Unmarshal(request.OldObject.Raw, &oldGreenplum); err != nil {
+				response.Result = &metav1.Status{Message: "failed to unmarshal Request.OldObject into GreenplumCluster: " + err.Error()}
 				return
 			}
-			op := reviewRequest.Request.Operation
-			switch op {
-			case admissionv1beta1.Create:
-				response.Allowed, response.Result = h.validateCreateGreenplumCluster(ctx, newGreenplum)
-			case admissionv1beta1.Update:
-				if err := json.Unmarshal(reviewRequest.Request.OldObject.Raw, &oldGreenplum); err != nil {
-					response.Result = &metav1.Status{Message: "failed to unmarshal Request.OldObject into GreenplumCluster: " + err.Error()}
-					return
-				}
-				response.Allowed, response.Result = h.validateUpdateGreenplumCluster(ctx, oldGreenplum, newGreenplum)
-			default:
-				response.Allowed = false
-				response.Result = &metav1.Status{Message: "unexpected operation for validation: " + string(op)}
-			}
-		case greenplumv1beta1.GroupVersion.WithKind("GreenplumPXFService"):
-			op := reviewRequest.Request.Operation
-			var oldPXF, newPXF greenplumv1beta1.GreenplumPXFService
-			if err := json.Unmarshal(reviewRequest.Request.Object.Raw, &newPXF); err != nil {
-				response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumPXFService: " + err.Error()}
+			response.Allowed, response.Result = h.validateUpdateGreenplumCluster(ctx, oldGreenplum, newGreenplum)
+		default:
+			response.Allowed = false
+			response.Result = &metav1.Status{Message: "unexpected operation for validation: " + string(op)}
+		}
+	case greenplumv1beta1.GroupVersion.WithKind("GreenplumPXFService"):
+		op := request.Operation
+		var oldPXF, newPXF greenplumv1beta1.GreenplumPXFService
+		if err := json.Unmarshal(request.Object.Raw, &newPXF); err != nil {
+			response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumPXFService: " + err.Error()}
+			return
+		}
+		switch op {
+		case admissionv1beta1.Create:
+			response.Allowed, response.Result = h.validateGreenplumPXFService(ctx, nil, &newPXF)
+		case admissionv1beta1.Update:
+			if err := json.Unmarshal(request.OldObject.Raw, &oldPXF); err != nil {
+				response.Result = &metav1.Status{Message: "failed to unmarshal Request.OldObject into GreenplumPXFService: " + err.Error()}
 				return
 			}
-			switch op {
-			case admissionv1beta1.Create:
-				response.Allowed, response.Result = h.validateGreenplumPXFService(ctx, nil, &newPXF)
-			case admissionv1beta1.Update:
-				if err := json.Unmarshal(reviewRequest.Request.OldObject.Raw, &oldPXF); err != nil {
-					response.Result = &metav1.Status{Message: "failed to unmarshal Request.OldObject into GreenplumPXFService: " + err.Error()}
-					return
-				}
-				response.Allowed, response.Result = h.validateGreenplumPXFService(ctx, &oldPXF, &newPXF)
-			default:
-				response.Allowed = false
-				response.Result = &metav1.Status{Message: "unexpected operation for validation: " + string(op)}
+			response.Allowed, response.Result = h.validateGreenplumPXFService(ctx, &oldPXF, &newPXF)
+		default:
+			response.Allowed = false
+			response.Result = &metav1.Status{Message: "unexpected operation for validation: " + string(op)}
+		}
+	case greenplumv1.GroupVersion.WithKind("GreenplumBackup"):
+		var oldBackup, newBackup greenplumv1.GreenplumBackup
+		if err := json.Unmarshal(request.Object.Raw, &newBackup); err != nil {
+			response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumBackup: " + err.Error()}
+			return
+		}
+		switch op := request.Operation; op {
+		case admissionv1beta1.Create:
+			response.Allowed, response.Result = h.validateGreenplumBackup(nil, &newBackup)
+		case admissionv1beta1.Update:
+			if err := json.Unmarshal(request.OldObject.Raw, &oldBackup); err != nil {
+				response.Result = &metav1.Status{Message: "failed to unmarshal Request.OldObject into GreenplumBackup: " + err.Error()}
+				return
 			}
+			response.Allowed, response.Result = h.validateGreenplumBackup(&oldBackup, &newBackup)
 		default:
 			response.Allowed = false
-			response.Result = &metav1.Status{Message: "unexpected validation request for object: " + reviewRequest.Request.Kind.String()}
+			response.Result = &metav1.Status{Message: "unexpected operation for validation: " + string(op)}
+		}
+	case greenplumv1.GroupVersion.WithKind("GreenplumBackupSchedule"):
+		var newSchedule greenplumv1.GreenplumBackupSchedule
+		if err := json.Unmarshal(request.Object.Raw, &newSchedule); err != nil {
+			response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumBackupSchedule: " + err.Error()}
+			return
 		}
+		switch op := request.Operation; op {
+		case admissionv1beta1.Create, admissionv1beta1.Update:
+			response.Allowed, response.Result = h.validateGreenplumBackupSchedule(&newSchedule)
+		default:
+			response.Allowed = false
+			response.Result = &metav1.Status{Message: "unexpected operation for validation: " + string(op)}
+		}
+	default:
+		response.Allowed = false
+		response.Result = &metav1.Status{Message: "unexpected validation request for object: " + request.Kind.String()}
+	}
 
-		return
-	}()
+	return
+}
 
-	log = log.WithValues("Allowed", reviewResponse.Response.Allowed)
-	if reviewResponse.Response.Result != nil && reviewResponse.Response.Result.Message != "" {
-		log = log.WithValues("Message", reviewResponse.Response.Result.Message)
+// mutate returns the patch that sets the defaults of a GreenplumCluster being created
+func (h *Handler) mutate(ctx context.Context, reqGVK schema.GroupVersionKind, request *admissionv1beta1.AdmissionRequest) (response *admissionv1beta1.AdmissionResponse) {
+	response = &admissionv1beta1.AdmissionResponse{}
+	if reqGVK != greenplumv1.GroupVersion.WithKind("GreenplumCluster") || request.Operation != admissionv1beta1.Create {
+		response.Result = &metav1.Status{Message: "unexpected mutation request for object: " + request.Kind.String() +
+			", operation: " + string(request.Operation)}
+		return
 	}
 
-	outBytes, _ := json.Marshal(reviewResponse)
-	_, err = out.Write(outBytes)
+	var newGreenplum greenplumv1.GreenplumCluster
+	if err := json.Unmarshal(request.Object.Raw, &newGreenplum); err != nil {
+		response.Result = &metav1.Status{Message: "failed to unmarshal Request.Object into GreenplumCluster: " + err.Error()}
+		return
+	}
+	patch, err := h.mutateCreateGreenplumCluster(ctx, newGreenplum)
 	if err != nil {
-		Log.Error(err, "responding to admission review")
+		response.Result = &metav1.Status{Message: err.Error()}
+		return
+	}
+	response.Allowed = true
+	if patch != nil {
+		patchType := admissionv1beta1.PatchTypeJSONPatch
+		response.PatchType = &patchType
+		response.Patch = patch
 	}
+	return
 }
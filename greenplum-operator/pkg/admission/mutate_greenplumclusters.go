@@ -0,0 +1,91 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// mutateCreateGreenplumCluster returns a JSON patch that fills in values a new GreenplumCluster
+// leaves unset from the GreenplumClusterDefaults named "default", if there is one.
+// A nil patch means there is nothing to change.
+func (h *Handler) mutateCreateGreenplumCluster(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) ([]byte, error) {
+	var defaults greenplumv1.GreenplumClusterDefaults
+	defaultsKey := types.NamespacedName{Name: greenplumv1.GreenplumClusterDefaultsName}
+	if err := h.KubeClient.Get(ctx, defaultsKey, &defaults); err != nil {
+		// Treat an uninstalled GreenplumClusterDefaults CRD the same as no defaults
+		if apierrs.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting GreenplumClusterDefaults: %w", err)
+	}
+
+	mergedSpec := newGreenplum.Spec.DeepCopy()
+	applyGreenplumClusterDefaults(mergedSpec, &defaults.Spec)
+
+	originalSpecJSON, err := json.Marshal(newGreenplum.Spec)
+	if err != nil {
+		return nil, err
+	}
+	mergedSpecJSON, err := json.Marshal(mergedSpec)
+	if err != nil {
+		return nil, err
+	}
+	if string(originalSpecJSON) == string(mergedSpecJSON) {
+		return nil, nil
+	}
+
+	return json.Marshal([]jsonPatchOperation{
+		{Op: "replace", Path: "/spec", Value: mergedSpec},
+	})
+}
+
+func applyGreenplumClusterDefaults(spec *greenplumv1.GreenplumClusterSpec, defaults *greenplumv1.GreenplumClusterDefaultsSpec) {
+	applyGreenplumPodDefaults(&spec.MasterAndStandby.GreenplumPodSpec, &defaults.MasterAndStandby)
+	applyGreenplumPodDefaults(&spec.Segments.GreenplumPodSpec, &defaults.Segments)
+
+	if spec.Config.DataDirectoryUmask == "" {
+		spec.Config.DataDirectoryUmask = defaults.Config.DataDirectoryUmask
+	}
+	for name, value := range defaults.Config.GUCs {
+		if _, ok := spec.Config.GUCs[name]; ok {
+			continue
+		}
+		if spec.Config.GUCs == nil {
+			spec.Config.GUCs = make(map[string]string)
+		}
+		spec.Config.GUCs[name] = value
+	}
+}
+
+func applyGreenplumPodDefaults(podSpec *greenplumv1.GreenplumPodSpec, defaults *greenplumv1.GreenplumClusterDefaultsPodSpec) {
+	if podSpec.Memory.IsZero() {
+		podSpec.Memory = defaults.Memory.DeepCopy()
+	}
+	if podSpec.CPU.IsZero() {
+		podSpec.CPU = defaults.CPU.DeepCopy()
+	}
+	if podSpec.StorageClassName == "" {
+		podSpec.StorageClassName = defaults.StorageClassName
+	}
+	if podSpec.Storage.IsZero() {
+		podSpec.Storage = defaults.Storage.DeepCopy()
+	}
+	if podSpec.WorkerSelector == nil && defaults.WorkerSelector != nil {
+		podSpec.WorkerSelector = make(map[string]string, len(defaults.WorkerSelector))
+		for key, value := range defaults.WorkerSelector {
+			podSpec.WorkerSelector[key] = value
+		}
+	}
+}
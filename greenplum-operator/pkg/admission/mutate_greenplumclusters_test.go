@@ -0,0 +1,193 @@
+package admission_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/admission"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/testing"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Mutating GreenplumClusters on create", func() {
+	var (
+		subject        *admission.Handler
+		reactiveClient *reactive.Client
+		newGreenplum   *greenplumv1.GreenplumCluster
+		defaults       *greenplumv1.GreenplumClusterDefaults
+	)
+
+	BeforeEach(func() {
+		reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		subject = &admission.Handler{KubeClient: reactiveClient}
+		admission.Log = gplog.ForTest(gbytes.NewBuffer())
+
+		newGreenplum = exampleGreenplum.DeepCopy()
+		newGreenplum.Status = greenplumv1.GreenplumClusterStatus{}
+		newGreenplum.Spec.MasterAndStandby.Memory = resource.Quantity{}
+		newGreenplum.Spec.MasterAndStandby.StorageClassName = ""
+		newGreenplum.Spec.Segments.CPU = resource.Quantity{}
+		newGreenplum.Spec.Config.GUCs = map[string]string{"gp_vmem_protect_limit": "4096"}
+
+		defaults = &greenplumv1.GreenplumClusterDefaults{
+			ObjectMeta: metav1.ObjectMeta{Name: greenplumv1.GreenplumClusterDefaultsName},
+			Spec: greenplumv1.GreenplumClusterDefaultsSpec{
+				MasterAndStandby: greenplumv1.GreenplumClusterDefaultsPodSpec{
+					Memory:           resource.MustParse("4G"),
+					CPU:              resource.MustParse("2.0"),
+					StorageClassName: "fast",
+					WorkerSelector:   map[string]string{"role": "master"},
+				},
+				Segments: greenplumv1.GreenplumClusterDefaultsPodSpec{
+					Memory: resource.MustParse("8G"),
+					CPU:    resource.MustParse("4.0"),
+				},
+				Config: greenplumv1.GreenplumConfigSpec{
+					GUCs: map[string]string{
+						"gp_vmem_protect_limit": "8192",
+						"optimizer":             "off",
+					},
+					DataDirectoryUmask: "0077",
+				},
+			},
+		}
+	})
+
+	var outputReview admissionv1beta1.AdmissionReview
+	JustBeforeEach(func() {
+		outputReview = postMutateReview(subject.Handler(), newGreenplum)
+	})
+
+	mutatedGreenplum := func() *greenplumv1.GreenplumCluster {
+		Expect(outputReview.Response.PatchType).NotTo(BeNil())
+		Expect(*outputReview.Response.PatchType).To(Equal(admissionv1beta1.PatchTypeJSONPatch))
+		var patch []struct {
+			Op    string                           `json:"op"`
+			Path  string                           `json:"path"`
+			Value greenplumv1.GreenplumClusterSpec `json:"value"`
+		}
+		Expect(json.Unmarshal(outputReview.Response.Patch, &patch)).To(Succeed())
+		Expect(patch).To(HaveLen(1))
+		Expect(patch[0].Op).To(Equal("replace"))
+		Expect(patch[0].Path).To(Equal("/spec"))
+		mutated := newGreenplum.DeepCopy()
+		mutated.Spec = patch[0].Value
+		return mutated
+	}
+
+	When("there is a default GreenplumClusterDefaults", func() {
+		BeforeEach(func() {
+			Expect(reactiveClient.Create(context.Background(), defaults)).To(Succeed())
+		})
+		It("allows the request", func() {
+			Expect(outputReview.Response.UID).To(BeEquivalentTo("my-gp-instance-uid"))
+			Expect(outputReview.Response.Allowed).To(BeTrue())
+		})
+		It("merges the defaults into unset values", func() {
+			mutated := mutatedGreenplum()
+			Expect(mutated.Spec.MasterAndStandby.Memory.String()).To(Equal("4G"))
+			Expect(mutated.Spec.MasterAndStandby.StorageClassName).To(Equal("fast"))
+			Expect(mutated.Spec.MasterAndStandby.WorkerSelector).To(Equal(map[string]string{"role": "master"}))
+			Expect(mutated.Spec.Segments.CPU.String()).To(Equal("4"))
+			Expect(mutated.Spec.Config.GUCs).To(HaveKeyWithValue("optimizer", "off"))
+			Expect(mutated.Spec.Config.DataDirectoryUmask).To(Equal("0077"))
+		})
+		It("keeps explicit values", func() {
+			mutated := mutatedGreenplum()
+			Expect(mutated.Spec.MasterAndStandby.CPU.String()).To(Equal("1"))
+			Expect(mutated.Spec.MasterAndStandby.Storage.String()).To(Equal("10G"))
+			Expect(mutated.Spec.Segments.Memory.String()).To(Equal("1G"))
+			Expect(mutated.Spec.Segments.StorageClassName).To(Equal("standard"))
+			Expect(mutated.Spec.Segments.PrimarySegmentCount).To(Equal(int32(5)))
+			Expect(mutated.Spec.Config.GUCs).To(HaveKeyWithValue("gp_vmem_protect_limit", "4096"))
+		})
+
+		When("the GreenplumCluster already sets every defaulted value", func() {
+			BeforeEach(func() {
+				newGreenplum = exampleGreenplum.DeepCopy()
+				// the defaults were created by the outer BeforeEach, so update the stored object
+				var storedDefaults greenplumv1.GreenplumClusterDefaults
+				Expect(reactiveClient.Get(context.Background(), types.NamespacedName{Name: defaults.Name}, &storedDefaults)).To(Succeed())
+				storedDefaults.Spec.MasterAndStandby.WorkerSelector = nil
+				storedDefaults.Spec.Config = greenplumv1.GreenplumConfigSpec{}
+				Expect(reactiveClient.Update(context.Background(), &storedDefaults)).To(Succeed())
+			})
+			It("allows the request without a patch", func() {
+				Expect(outputReview.Response.Allowed).To(BeTrue())
+				Expect(outputReview.Response.PatchType).To(BeNil())
+				Expect(outputReview.Response.Patch).To(BeNil())
+			})
+		})
+	})
+
+	When("GreenplumClusterDefaults are not named default", func() {
+		BeforeEach(func() {
+			defaults.Name = "not-default"
+			Expect(reactiveClient.Create(context.Background(), defaults)).To(Succeed())
+		})
+		It("allows the request without a patch", func() {
+			Expect(outputReview.Response.Allowed).To(BeTrue())
+			Expect(outputReview.Response.Patch).To(BeNil())
+		})
+	})
+
+	When("there are no GreenplumClusterDefaults", func() {
+		It("allows the request without a patch", func() {
+			Expect(outputReview.Response.Allowed).To(BeTrue())
+			Expect(outputReview.Response.Patch).To(BeNil())
+		})
+	})
+
+	When("getting the GreenplumClusterDefaults fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("get", "greenplumclusterdefaultses", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("disallows the request", func() {
+			Expect(outputReview.Response.Allowed).To(BeFalse())
+			Expect(outputReview.Response.Result.Message).To(Equal("getting GreenplumClusterDefaults: injected error"))
+		})
+	})
+
+	When("the request is an update", func() {
+		JustBeforeEach(func() {
+			outputReview = postMutateReview(subject.Handler(), newGreenplum, exampleGreenplum.DeepCopy())
+		})
+		It("disallows the request", func() {
+			Expect(outputReview.Response.Allowed).To(BeFalse())
+			Expect(outputReview.Response.Result.Message).To(Equal(
+				"unexpected mutation request for object: greenplum.pivotal.io/v1, Kind=GreenplumCluster, operation: UPDATE"))
+		})
+	})
+})
+
+func postMutateReview(handler http.Handler, newObj runtime.Object, oldObj ...runtime.Object) (outputReview admissionv1beta1.AdmissionReview) {
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	rb := SampleAdmissionReviewRequest().NewObj(newObj)
+	for _, o := range oldObj {
+		rb.OldObj(o)
+	}
+	resp, err := http.Post(srv.URL+"/mutate", "application/json", marshal(rb.Build()))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	unmarshal(resp.Body, &outputReview)
+	return
+}
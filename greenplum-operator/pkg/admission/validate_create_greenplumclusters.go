@@ -3,9 +3,16 @@ package admission
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/pghba"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -42,6 +49,14 @@ func (h *Handler) validateCreateGreenplumCluster(ctx context.Context, newGreenpl
 	if result != nil {
 		return
 	}
+	result = validateAutoFailover(newGreenplum)
+	if result != nil {
+		return
+	}
+	result = validateAutoPrimarySegmentCount(newGreenplum)
+	if result != nil {
+		return
+	}
 	result = h.validatePrimarySegmentCount(ctx, newGreenplum)
 	if result != nil {
 		return
@@ -59,6 +74,14 @@ func (h *Handler) validateCreateGreenplumCluster(ctx context.Context, newGreenpl
 	if result != nil {
 		return
 	}
+	result = validateScheduling(newGreenplum, newGreenplum.Spec.MasterAndStandby.GreenplumPodSpec, "masterAndStandby")
+	if result != nil {
+		return
+	}
+	result = validateScheduling(newGreenplum, newGreenplum.Spec.Segments.GreenplumPodSpec, "segments")
+	if result != nil {
+		return
+	}
 
 	result = validateResourceQuantity(newGreenplum.Spec.MasterAndStandby.CPU, "masterAndStandby", "cpu")
 	if result != nil {
@@ -78,6 +101,15 @@ func (h *Handler) validateCreateGreenplumCluster(ctx context.Context, newGreenpl
 		return
 	}
 
+	result = validateResources(newGreenplum.Spec.MasterAndStandby.GreenplumPodSpec, "masterAndStandby")
+	if result != nil {
+		return
+	}
+	result = validateResources(newGreenplum.Spec.Segments.GreenplumPodSpec, "segments")
+	if result != nil {
+		return
+	}
+
 	result = validateResourceQuantity(newGreenplum.Spec.MasterAndStandby.Storage, "masterAndStandby", "storage")
 	if result != nil {
 		return
@@ -87,10 +119,435 @@ func (h *Handler) validateCreateGreenplumCluster(ctx context.Context, newGreenpl
 		return
 	}
 
+	result = validateGpadminHome(newGreenplum.Spec.MasterAndStandby.GpadminHome)
+	if result != nil {
+		return
+	}
+
+	result = validateTempTablespace(newGreenplum.Spec.TempTablespace)
+	if result != nil {
+		return
+	}
+
+	result = validateSpillVolume(newGreenplum.Spec)
+	if result != nil {
+		return
+	}
+
+	result = validateSchedulerName(newGreenplum.Spec.SchedulerName)
+	if result != nil {
+		return
+	}
+
+	result = h.validateCABundle(ctx, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	result = h.validateTLS(ctx, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	result = h.validateLDAP(ctx, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	result = h.validateEntrypointScript(ctx, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	result = h.validateMonitoring(ctx, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	result = h.validateSysctls(ctx, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	result = validateGUCs(newGreenplum.Spec.Config.GUCs)
+	if result != nil {
+		return
+	}
+
+	result = validateDataDirectoryUmask(newGreenplum.Spec.Config.DataDirectoryUmask)
+	if result != nil {
+		return
+	}
+
+	result = validateArrayName(newGreenplum.Spec.Config.ArrayName)
+	if result != nil {
+		return
+	}
+
+	result = validateRoleConnectionLimits(newGreenplum.Spec.Config.RoleConnectionLimits)
+	if result != nil {
+		return
+	}
+
+	result = validateRoleSettings(newGreenplum.Spec.Config.RoleSettings)
+	if result != nil {
+		return
+	}
+
+	result = validateExtensions(newGreenplum.Spec.Config.Extensions)
+	if result != nil {
+		return
+	}
+
+	result = validatePgHbaEntries(newGreenplum.Spec.Config)
+	if result != nil {
+		return
+	}
+
+	result = validateResourceQueues(newGreenplum.Spec.Config)
+	if result != nil {
+		return
+	}
+
+	result = validateAdminPasswordRotationInterval(newGreenplum.Spec.MasterAndStandby.AdminPasswordRotationInterval)
+	if result != nil {
+		return
+	}
+
+	result = validateInterconnectTLS(newGreenplum.Spec.InterconnectTLS)
+	if result != nil {
+		return
+	}
+
+	result = validateDisasterRecovery(newGreenplum)
+	if result != nil {
+		return
+	}
+
 	allowed = true
 	return
 }
 
+func validateGpadminHome(gpadminHome *greenplumv1.GreenplumGpadminHomeSpec) (result *metav1.Status) {
+	if gpadminHome == nil {
+		return
+	}
+	if gpadminHome.StorageClassName == "" {
+		result = &metav1.Status{Message: "masterAndStandby gpadminHome storageClassName must be specified"}
+		return
+	}
+	if gpadminHome.Storage.Sign() != 1 {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid masterAndStandby gpadminHome storage value: "%s": must be greater than 0`, gpadminHome.Storage.String())}
+	}
+	return
+}
+
+func validateTempTablespace(tempTablespace *greenplumv1.GreenplumTempTablespaceSpec) (result *metav1.Status) {
+	if tempTablespace == nil {
+		return
+	}
+	if tempTablespace.StorageClassName == "" {
+		result = &metav1.Status{Message: "tempTablespace storageClassName must be specified"}
+		return
+	}
+	if tempTablespace.Storage.Sign() != 1 {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid tempTablespace storage value: "%s": must be greater than 0`, tempTablespace.Storage.String())}
+	}
+	return
+}
+
+func validateSpillVolume(spec greenplumv1.GreenplumClusterSpec) (result *metav1.Status) {
+	if spec.SpillVolume == nil {
+		return
+	}
+	if spec.TempTablespace != nil {
+		result = &metav1.Status{Message: "spillVolume and tempTablespace cannot both be specified"}
+		return
+	}
+	if spec.SpillVolume.SizeLimit.Sign() != 1 {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid spillVolume sizeLimit value: "%s": must be greater than 0`, spec.SpillVolume.SizeLimit.String())}
+		return
+	}
+	if spec.SpillVolume.Medium != corev1.StorageMediumDefault && spec.SpillVolume.Medium != corev1.StorageMediumMemory {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid spillVolume medium value: "%s": must be empty or Memory`, spec.SpillVolume.Medium)}
+	}
+	return
+}
+
+// gpinitsystem sources its config with bash, so the array name is limited to characters
+// that are safe inside double quotes
+var arrayNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+
+func validateArrayName(arrayName string) (result *metav1.Status) {
+	if arrayName == "" {
+		return
+	}
+	if !arrayNamePattern.MatchString(arrayName) {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid config arrayName value: "%s": must be at most 64 letters, digits, spaces, underscores, periods or hyphens, starting with a letter or digit`, arrayName)}
+	}
+	return
+}
+
+// Role names are limited to unquoted identifiers so that they can be embedded in ALTER ROLE statements
+var roleNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+func validateRoleConnectionLimits(limits map[string]int32) (result *metav1.Status) {
+	roles := make([]string, 0, len(limits))
+	for role := range limits {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		if !roleNamePattern.MatchString(role) {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.roleConnectionLimits: invalid role name "%s": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`, role)}
+			return
+		}
+		if role == "gpadmin" {
+			result = &metav1.Status{Message: "config.roleConnectionLimits: gpadmin cannot be limited because the operator connects as gpadmin"}
+			return
+		}
+		if limits[role] < -1 {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.roleConnectionLimits: invalid limit for %s: %d: must be -1 for no limit, or 0 or more`, role, limits[role])}
+			return
+		}
+	}
+	return
+}
+
+var (
+	roleSettingNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*(?:\.[a-z_][a-z0-9_]*)?$`)
+	// A comma-separated list of bare words or numbers, "quoted identifiers" and 'quoted strings', e.g. "$user", public
+	roleSettingValuePattern = regexp.MustCompile(`^\s*(?:[A-Za-z0-9_.+-]+|"(?:[^"\x00-\x1f]|"")+"|'(?:[^'\x00-\x1f]|'')*')` +
+		`(?:\s*,\s*(?:[A-Za-z0-9_.+-]+|"(?:[^"\x00-\x1f]|"")+"|'(?:[^'\x00-\x1f]|'')*'))*\s*$`)
+)
+
+func validateRoleSettings(roleSettings map[string]map[string]string) (result *metav1.Status) {
+	roles := make([]string, 0, len(roleSettings))
+	for role := range roleSettings {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		if !roleNamePattern.MatchString(role) {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.roleSettings: invalid role name "%s": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`, role)}
+			return
+		}
+		parameters := make([]string, 0, len(roleSettings[role]))
+		for parameter := range roleSettings[role] {
+			parameters = append(parameters, parameter)
+		}
+		sort.Strings(parameters)
+		for _, parameter := range parameters {
+			if !roleSettingNamePattern.MatchString(parameter) {
+				result = &metav1.Status{Message: fmt.Sprintf(`config.roleSettings: invalid parameter name for %s: "%s"`, role, parameter)}
+				return
+			}
+			value := roleSettings[role][parameter]
+			if !roleSettingValuePattern.MatchString(value) {
+				result = &metav1.Status{Message: fmt.Sprintf(`config.roleSettings: invalid value for %s %s: "%s": must be a comma-separated list of words, numbers, "quoted identifiers" or 'quoted strings'`, role, parameter, value)}
+				return
+			}
+		}
+	}
+	return
+}
+
+// allowedExtensions are the extensions that may be listed in config.extensions: those shipped with Greenplum
+// that can be created without preloading a library or any other setup. pg_stat_statements has its own setting
+var allowedExtensions = []string{
+	"citext",
+	"dblink",
+	"fuzzystrmatch",
+	"hstore",
+	"ltree",
+	"pg_trgm",
+	"pgcrypto",
+	"postgres_fdw",
+	"tablefunc",
+	"uuid-ossp",
+}
+
+// Resource queue memory limits are per segment, and must be given with a unit
+var resourceQueueMemoryLimitPattern = regexp.MustCompile(`^[1-9][0-9]*(kB|MB|GB)$`)
+
+// validateResourceQueues checks that resource queues are only used with the queue resource manager, and resource
+// group GUCs only with resource groups. Queue and role names must be unquoted identifiers, since they are embedded in
+// the statements run when the cluster is initialized
+func validateResourceQueues(config greenplumv1.GreenplumConfigSpec) (result *metav1.Status) {
+	if configmap.ResourceGroupsEnabled(config.GUCs) {
+		if len(config.ResourceQueues) > 0 {
+			result = &metav1.Status{Message: "config.resourceQueues requires config.gucs gp_resource_manager to be queue"}
+			return
+		}
+		if _, ok := config.GUCs["gp_resqueue_priority"]; ok {
+			result = &metav1.Status{Message: "config.gucs: gp_resqueue_priority requires gp_resource_manager to be queue"}
+		}
+		return
+	}
+	for _, name := range []string{"gp_resource_group_cpu_limit", "gp_resource_group_memory_limit"} {
+		if _, ok := config.GUCs[name]; ok {
+			result = &metav1.Status{Message: fmt.Sprintf("config.gucs: %s cannot be set when gp_resource_manager is queue", name)}
+			return
+		}
+	}
+
+	queues := map[string]bool{}
+	roles := map[string]string{}
+	for _, queue := range config.ResourceQueues {
+		if !roleNamePattern.MatchString(queue.Name) {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: invalid queue name "%s": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`, queue.Name)}
+			return
+		}
+		if queue.Name == "pg_default" {
+			result = &metav1.Status{Message: "config.resourceQueues: pg_default is created by Greenplum and cannot be listed"}
+			return
+		}
+		if queues[queue.Name] {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: queue "%s" is listed more than once`, queue.Name)}
+			return
+		}
+		queues[queue.Name] = true
+		if queue.ActiveStatements < 1 {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: invalid activeStatements for %s: %d: must be at least 1`, queue.Name, queue.ActiveStatements)}
+			return
+		}
+		if queue.MemoryLimit != "" && !resourceQueueMemoryLimitPattern.MatchString(queue.MemoryLimit) {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: invalid memoryLimit for %s: "%s": must be a positive integer followed by a unit of kB, MB or GB`, queue.Name, queue.MemoryLimit)}
+			return
+		}
+		switch queue.Priority {
+		case "", "MIN", "LOW", "MEDIUM", "HIGH", "MAX":
+		default:
+			result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: invalid priority for %s: "%s": must be MIN, LOW, MEDIUM, HIGH or MAX`, queue.Name, queue.Priority)}
+			return
+		}
+		for _, role := range queue.Roles {
+			if !roleNamePattern.MatchString(role) {
+				result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: invalid role name "%s": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`, role)}
+				return
+			}
+			if role == "gpadmin" {
+				result = &metav1.Status{Message: "config.resourceQueues: gpadmin cannot be assigned to a resource queue because superusers are not limited by them"}
+				return
+			}
+			if otherQueue, ok := roles[role]; ok {
+				result = &metav1.Status{Message: fmt.Sprintf(`config.resourceQueues: role %s is assigned to both %s and %s`, role, otherQueue, queue.Name)}
+				return
+			}
+			roles[role] = queue.Name
+		}
+	}
+	return
+}
+
+func validateExtensions(extensions []string) (result *metav1.Status) {
+	seen := map[string]bool{}
+	for _, extension := range extensions {
+		if !isAllowedExtension(extension) {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.extensions: extension "%s" is not allowed: must be one of %s`, extension, strings.Join(allowedExtensions, ", "))}
+			return
+		}
+		if seen[extension] {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.extensions: extension "%s" is listed more than once`, extension)}
+			return
+		}
+		seen[extension] = true
+	}
+	return
+}
+
+func validatePgHbaEntries(config greenplumv1.GreenplumConfigSpec) (result *metav1.Status) {
+	for i, entry := range config.PgHbaEntries {
+		if err := pghba.Validate(entry, config.AllowInsecureAuth); err != nil {
+			result = &metav1.Status{Message: fmt.Sprintf("config.pgHbaEntries[%d]: %s", i, err.Error())}
+			return
+		}
+	}
+	return
+}
+
+func isAllowedExtension(extension string) bool {
+	for _, allowed := range allowedExtensions {
+		if extension == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func validateDataDirectoryUmask(umask string) (result *metav1.Status) {
+	if umask == "" {
+		return
+	}
+	value, err := strconv.ParseUint(umask, 8, 32)
+	if err != nil || value > 0777 {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid config dataDirectoryUmask value: "%s": must be an octal umask, e.g. 0077`, umask)}
+		return
+	}
+	if value&0700 != 0 {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid config dataDirectoryUmask value: "%s": must not remove any permissions from the owner`, umask)}
+	}
+	return
+}
+
+// Rotating more often than this would leave clients little time to pick up the new password
+const minAdminPasswordRotationInterval = time.Hour
+
+func validateAdminPasswordRotationInterval(interval *metav1.Duration) (result *metav1.Status) {
+	if interval == nil {
+		return
+	}
+	if interval.Duration < minAdminPasswordRotationInterval {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid masterAndStandby adminPasswordRotationInterval value: "%s": must be at least %s`, interval.Duration, minAdminPasswordRotationInterval)}
+	}
+	return
+}
+
+// Pods only pick up reissued certificates when they restart, so certificates should last well beyond that
+const minInterconnectCertificateValidity = 24 * time.Hour
+
+func validateInterconnectTLS(interconnectTLS *greenplumv1.GreenplumInterconnectTLSSpec) (result *metav1.Status) {
+	if interconnectTLS == nil || interconnectTLS.CertificateValidity == nil {
+		return
+	}
+	if validity := interconnectTLS.CertificateValidity.Duration; validity < minInterconnectCertificateValidity {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid interconnectTLS certificateValidity value: "%s": must be at least %s`, validity, minInterconnectCertificateValidity)}
+	}
+	return
+}
+
+// Each replication copies every database, so it should not run back to back
+const minReplicationInterval = 10 * time.Minute
+
+func validateDisasterRecovery(newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	disasterRecovery := newGreenplum.Spec.DisasterRecovery
+	if disasterRecovery == nil {
+		return
+	}
+	if disasterRecovery.PrimaryNamespace == "" && disasterRecovery.PrimaryHost == "" {
+		result = &metav1.Status{Message: "disasterRecovery requires one of primaryNamespace and primaryHost"}
+		return
+	}
+	if disasterRecovery.PrimaryNamespace != "" && disasterRecovery.PrimaryHost != "" {
+		result = &metav1.Status{Message: "disasterRecovery primaryNamespace and primaryHost cannot be used together"}
+		return
+	}
+	if disasterRecovery.PrimaryNamespace != "" && disasterRecovery.PrimaryNamespace == newGreenplum.Namespace {
+		result = &metav1.Status{Message: "disasterRecovery primaryNamespace must be a different namespace than the GreenplumCluster"}
+		return
+	}
+	if port := disasterRecovery.PrimaryPort; port < 0 || port > 65535 {
+		result = &metav1.Status{Message: fmt.Sprintf("invalid disasterRecovery primaryPort %d: must be between 1 and 65535", port)}
+		return
+	}
+	if interval := disasterRecovery.ReplicationInterval; interval != nil && interval.Duration < minReplicationInterval {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid disasterRecovery replicationInterval value: "%s": must be at least %s`, interval.Duration, minReplicationInterval)}
+	}
+	return
+}
+
 func (h *Handler) validateUniqueCluster(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
 	exists, err := h.clusterExistsInNamespace(ctx, newGreenplum)
 	if exists || err != nil {
@@ -125,7 +582,24 @@ func (h *Handler) validatePvcGreenplumVersion(ctx context.Context, newGreenplum
 	return
 }
 
+func validateAutoPrimarySegmentCount(newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	if newGreenplum.Spec.Segments.AutoPrimarySegmentCount == "yes" {
+		if newGreenplum.Spec.Segments.PrimarySegmentCount != 0 {
+			result = &metav1.Status{Message: "primarySegmentCount cannot be set when autoPrimarySegmentCount is yes"}
+		}
+		return
+	}
+	if newGreenplum.Spec.Segments.PrimarySegmentCount < 1 {
+		result = &metav1.Status{Message: "primarySegmentCount must be at least 1 unless autoPrimarySegmentCount is yes"}
+	}
+	return
+}
+
 func (h *Handler) validatePrimarySegmentCount(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	if newGreenplum.Spec.Segments.AutoPrimarySegmentCount == "yes" {
+		// the count is not known until the cluster is reconciled
+		return
+	}
 	pvcList, err := h.getGreenplumPVCs(ctx, newGreenplum, "segment-a")
 	if err != nil {
 		result = &metav1.Status{Message: err.Error()}
@@ -141,6 +615,14 @@ func (h *Handler) validatePrimarySegmentCount(ctx context.Context, newGreenplum
 	return
 }
 
+// validateAutoFailover checks that there is a standby to promote
+func validateAutoFailover(newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	if newGreenplum.Spec.MasterAndStandby.AutoFailover && !strings.EqualFold(newGreenplum.Spec.MasterAndStandby.Standby, "yes") {
+		result = &metav1.Status{Message: `autoFailover requires standby to be set to "yes"`}
+	}
+	return
+}
+
 func (h *Handler) validateAntiAffinity(newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
 	if newGreenplum.Spec.MasterAndStandby.Standby == "no" {
 		if newGreenplum.Spec.MasterAndStandby.AntiAffinity != "no" || newGreenplum.Spec.Segments.AntiAffinity != "no" {
@@ -221,8 +703,8 @@ func (h *Handler) validateMirrors(ctx context.Context, newGreenplum greenplumv1.
 		return
 	}
 	previousMirrorCount := len(mirrorPvcList.Items)
-	if (previousMirrorCount == 0 && newGreenplum.Spec.Segments.Mirrors == "yes") ||
-		(previousMirrorCount > 0 && newGreenplum.Spec.Segments.Mirrors == "no") {
+	// A cluster without mirrors may come back with them; the operator adds them once it is Running
+	if previousMirrorCount > 0 && newGreenplum.Spec.Segments.Mirrors == "no" {
 		result = &metav1.Status{
 			Message: generateLongPVCErrStr("Greenplum", newGreenplum.Name, previousMirrorCount, "mirrors", "segments.mirrors", "changed"),
 		}
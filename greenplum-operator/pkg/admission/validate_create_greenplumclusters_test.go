@@ -1,8 +1,11 @@
 package admission_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -13,6 +16,8 @@ import (
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/controllers/greenplumcluster"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/admission"
+	cabundletesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle/testing"
+	clienttlstesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls/testing"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
@@ -309,17 +314,14 @@ var _ = Describe("validateCreateGreenplumCluster", func() {
 				})))
 				Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
 			})
-			It("disallows requests that change mirrors", func() {
+			It("allows requests that enable mirrors", func() {
 				newGreenplum.Spec.Segments.Mirrors = "yes"
 
 				outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
 
-				Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
-				expectedMessage := "my-gp-instance has PVCs for 0 mirrors. segments.mirrors cannot be changed without first deleting PVCs. This will result in a new, empty Greenplum cluster"
-				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
-					"Message": Equal(expectedMessage),
-				})))
-				Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+				Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+				Expect(outputReview.Response.Result).To(BeNil())
+				Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
 			})
 			It("allows requests that change the value for standby without changing its meaning", func() {
 				newGreenplum.Spec.MasterAndStandby.Standby = "NO"
@@ -397,6 +399,33 @@ var _ = Describe("validateCreateGreenplumCluster", func() {
 		})
 	})
 
+	When("autoFailover is enabled", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			newGreenplum = exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.MasterAndStandby.AutoFailover = true
+		})
+		It("allows the request when there is a standby", func() {
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+		It("rejects the request when there is no standby", func() {
+			newGreenplum.Spec.MasterAndStandby.Standby = "no"
+			newGreenplum.Spec.MasterAndStandby.AntiAffinity = "no"
+			newGreenplum.Spec.Segments.AntiAffinity = "no"
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(`autoFailover requires standby to be set to "yes"`),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(`autoFailover requires standby to be set to "yes"`))
+		})
+	})
+
 	When("standby=no", func() {
 		var newGreenplum *greenplumv1.GreenplumCluster
 		BeforeEach(func() {
@@ -558,6 +587,120 @@ var _ = Describe("validateCreateGreenplumCluster", func() {
 		})
 	})
 
+	Describe("nodeSelector, tolerations and affinity", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			newGreenplum = exampleGreenplum.DeepCopy()
+		})
+		expectDisallowed := func(expectedMessage string) {
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		}
+		sameNodeAs := func(podType string) *corev1.Affinity {
+			return &corev1.Affinity{
+				PodAffinity: &corev1.PodAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+						{
+							LabelSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{"greenplum-cluster": "my-gp-instance", "type": podType},
+							},
+							TopologyKey: "kubernetes.io/hostname",
+						},
+					},
+				},
+			}
+		}
+
+		It("allows them when they agree with workerSelector and antiAffinity", func() {
+			newGreenplum.Spec.Segments.WorkerSelector = map[string]string{"pool": "greenplum"}
+			newGreenplum.Spec.Segments.NodeSelector = map[string]string{"pool": "greenplum", "disk": "nvme"}
+			newGreenplum.Spec.Segments.Tolerations = []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "greenplum", Effect: corev1.TaintEffectNoSchedule},
+			}
+			newGreenplum.Spec.Segments.Affinity = &corev1.Affinity{
+				PodAffinity: &corev1.PodAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+						{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "etl"}},
+							TopologyKey:   "kubernetes.io/hostname",
+						},
+					},
+				},
+			}
+			newGreenplum.Spec.MasterAndStandby.Affinity = sameNodeAs("segment-a")
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		})
+
+		It("rejects a nodeSelector that conflicts with workerSelector", func() {
+			newGreenplum.Spec.MasterAndStandby.WorkerSelector = map[string]string{"pool": "greenplum"}
+			newGreenplum.Spec.MasterAndStandby.NodeSelector = map[string]string{"pool": "etl"}
+			expectDisallowed("masterAndStandby nodeSelector pool=etl conflicts with workerSelector pool=greenplum")
+		})
+
+		It("rejects a nodeSelector on the node labels the operator sets for antiAffinity", func() {
+			newGreenplum.Spec.Segments.NodeSelector = map[string]string{"greenplum-affinity-test-ns-segment": "a"}
+			expectDisallowed("segments nodeSelector cannot use greenplum-affinity-test-ns-segment: the operator labels nodes with greenplum-affinity-* for antiAffinity")
+		})
+
+		It("rejects node affinity on the node labels the operator sets for antiAffinity", func() {
+			newGreenplum.Spec.Segments.Affinity = &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{
+								{Key: "greenplum-affinity-test-ns-segment", Operator: corev1.NodeSelectorOpIn, Values: []string{"b"}},
+							}},
+						},
+					},
+				},
+			}
+			expectDisallowed("segments affinity cannot use greenplum-affinity-test-ns-segment: the operator labels nodes with greenplum-affinity-* for antiAffinity")
+		})
+
+		DescribeTable("rejects segments pod affinity that puts mirrors on the node of their primaries",
+			func(podType string) {
+				newGreenplum.Spec.Segments.Affinity = sameNodeAs(podType)
+				expectDisallowed("segments affinity cannot require segments pods to be on the same node as " + podType + " pods: the operator keeps them on different nodes")
+			},
+			Entry("selecting primaries", "segment-a"),
+			Entry("selecting mirrors", "segment-b"),
+		)
+
+		It("rejects segments pod affinity that puts mirrors on the node of their primaries without antiAffinity", func() {
+			newGreenplum.Spec.MasterAndStandby.AntiAffinity = "no"
+			newGreenplum.Spec.Segments.AntiAffinity = "no"
+			newGreenplum.Spec.Segments.Affinity = sameNodeAs("segment-a")
+			expectDisallowed("segments affinity cannot require segments pods to be on the same node as segment-a pods: the operator keeps them on different nodes")
+		})
+
+		It("allows segments pod affinity on the same segment pods in another zone", func() {
+			newGreenplum.Spec.Segments.Affinity = sameNodeAs("segment-a")
+			newGreenplum.Spec.Segments.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey = "topology.kubernetes.io/zone"
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+		})
+
+		It("rejects masterAndStandby pod affinity that puts the standby on the node of the master with antiAffinity", func() {
+			newGreenplum.Spec.MasterAndStandby.Affinity = sameNodeAs("master")
+			expectDisallowed("masterAndStandby affinity cannot require masterAndStandby pods to be on the same node as master pods: the operator keeps them on different nodes")
+		})
+
+		It("allows masterAndStandby pod affinity on the master pods without antiAffinity", func() {
+			newGreenplum.Spec.MasterAndStandby.AntiAffinity = "no"
+			newGreenplum.Spec.Segments.AntiAffinity = "no"
+			newGreenplum.Spec.MasterAndStandby.Affinity = sameNodeAs("master")
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+		})
+	})
+
 	When("masterAndStandby cpu < 0", func() {
 		It("rejects the request", func() {
 			newGreenplum := exampleGreenplum.DeepCopy()
@@ -670,6 +813,61 @@ var _ = Describe("validateCreateGreenplumCluster", func() {
 		Entry("memory = 1", resource.MustParse("1")),
 	)
 
+	DescribeTable("rejects resource requests greater than their limits",
+		func(typ string, resources corev1.ResourceRequirements, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			if typ == "masterAndStandby" {
+				newGreenplum.Spec.MasterAndStandby.Resources = resources
+			} else {
+				newGreenplum.Spec.Segments.Resources = resources
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("a memory request above the default memory limit", "masterAndStandby",
+			corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2G")}},
+			"masterAndStandby resources.requests.memory 2G must be less than or equal to its limit 1G"),
+		Entry("a cpu request above the default cpu limit", "segments",
+			corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m")}},
+			"segments resources.requests.cpu 1500m must be less than or equal to its limit 1"),
+		Entry("a request above its explicit limit", "segments",
+			corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("768Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+			"segments resources.requests.memory 768Mi must be less than or equal to its limit 512Mi"),
+		Entry("a negative request", "segments",
+			corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("-1")}},
+			`invalid segments resources.requests.cpu value: "-1": must be greater than or equal to 0`),
+	)
+
+	DescribeTable("allows resource requests within their limits",
+		func(resources corev1.ResourceRequirements) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.Resources = resources
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		},
+		Entry("requests below the default limits", corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512M"), corev1.ResourceCPU: resource.MustParse("500m")},
+		}),
+		Entry("requests equal to the default limits", corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1G"), corev1.ResourceCPU: resource.MustParse("1")},
+		}),
+		Entry("a request within a raised limit", corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2G")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4G")},
+		}),
+		Entry("a request for a resource without a limit", corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("10Gi")},
+		}),
+	)
+
 	When("masterAndStandby storage < 0", func() {
 		It("rejects the request", func() {
 			newGreenplum := exampleGreenplum.DeepCopy()
@@ -725,6 +923,1130 @@ var _ = Describe("validateCreateGreenplumCluster", func() {
 		Entry("storage = 0", resource.MustParse("0")),
 		Entry("storage = 1", resource.MustParse("1")),
 	)
+
+	When("masterAndStandby gpadminHome is set", func() {
+		It("allows a valid gpadminHome", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.MasterAndStandby.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+				StorageClassName: "standard",
+				Storage:          resource.MustParse("1G"),
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		})
+		It("rejects a gpadminHome without a storageClassName", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.MasterAndStandby.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+				Storage: resource.MustParse("1G"),
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := "masterAndStandby gpadminHome storageClassName must be specified"
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		})
+		DescribeTable("rejects gpadminHome storage values <= 0",
+			func(storageValue resource.Quantity, expectedMessage string) {
+				newGreenplum := exampleGreenplum.DeepCopy()
+				newGreenplum.Spec.MasterAndStandby.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+					StorageClassName: "standard",
+					Storage:          storageValue,
+				}
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+				Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+				Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Message": Equal(expectedMessage),
+				})))
+			},
+			Entry("storage = 0", resource.MustParse("0"),
+				`invalid masterAndStandby gpadminHome storage value: "0": must be greater than 0`),
+			Entry("storage = -1", resource.MustParse("-1"),
+				`invalid masterAndStandby gpadminHome storage value: "-1": must be greater than 0`),
+		)
+	})
+
+	When("tempTablespace is set", func() {
+		It("allows a valid tempTablespace", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+				StorageClassName: "local-ssd",
+				Storage:          resource.MustParse("100G"),
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		})
+		It("rejects a tempTablespace without a storageClassName", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+				Storage: resource.MustParse("100G"),
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := "tempTablespace storageClassName must be specified"
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		})
+		DescribeTable("rejects tempTablespace storage values <= 0",
+			func(storageValue resource.Quantity, expectedMessage string) {
+				newGreenplum := exampleGreenplum.DeepCopy()
+				newGreenplum.Spec.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+					StorageClassName: "local-ssd",
+					Storage:          storageValue,
+				}
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+				Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+				Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Message": Equal(expectedMessage),
+				})))
+			},
+			Entry("storage = 0", resource.MustParse("0"),
+				`invalid tempTablespace storage value: "0": must be greater than 0`),
+			Entry("storage = -1", resource.MustParse("-1"),
+				`invalid tempTablespace storage value: "-1": must be greater than 0`),
+		)
+	})
+
+	When("spillVolume is set", func() {
+		It("allows a valid spillVolume", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.SpillVolume = &greenplumv1.GreenplumSpillVolumeSpec{
+				SizeLimit: resource.MustParse("50Gi"),
+				Medium:    corev1.StorageMediumMemory,
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		})
+		DescribeTable("rejects an invalid spillVolume",
+			func(spillVolume greenplumv1.GreenplumSpillVolumeSpec, expectedMessage string) {
+				newGreenplum := exampleGreenplum.DeepCopy()
+				newGreenplum.Spec.SpillVolume = &spillVolume
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+				Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+				Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Message": Equal(expectedMessage),
+				})))
+			},
+			Entry("sizeLimit = 0", greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("0")},
+				`invalid spillVolume sizeLimit value: "0": must be greater than 0`),
+			Entry("unknown medium", greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("50Gi"), Medium: "HugePages"},
+				`invalid spillVolume medium value: "HugePages": must be empty or Memory`),
+		)
+		It("rejects a spillVolume together with a tempTablespace", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.SpillVolume = &greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("50Gi")}
+			newGreenplum.Spec.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+				StorageClassName: "local-ssd",
+				Storage:          resource.MustParse("100G"),
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry("spillVolume and tempTablespace cannot both be specified"))
+		})
+	})
+
+	DescribeTable("allows supported gucs with valid values",
+		func(name, value string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.GUCs = map[string]string{name: value}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		},
+		Entry("gp_workfile_limit_per_query in kB", "gp_workfile_limit_per_query", "0"),
+		Entry("gp_workfile_limit_per_query with unit", "gp_workfile_limit_per_query", "10GB"),
+		Entry("gp_workfile_limit_per_segment in kB", "gp_workfile_limit_per_segment", "1048576"),
+		Entry("gp_workfile_limit_per_segment with unit", "gp_workfile_limit_per_segment", "500MB"),
+		Entry("gp_workfile_limit_files_per_query", "gp_workfile_limit_files_per_query", "100000"),
+		Entry("gp_resource_group_cpu_limit", "gp_resource_group_cpu_limit", "0.9"),
+		Entry("gp_resource_group_memory_limit at the minimum", "gp_resource_group_memory_limit", "0.1"),
+		Entry("gp_resource_group_memory_limit at the maximum", "gp_resource_group_memory_limit", "1.0"),
+		Entry("optimizer off", "optimizer", "off"),
+		Entry("optimizer on", "optimizer", "on"),
+		Entry("gp_interconnect_type udpifc", "gp_interconnect_type", "udpifc"),
+		Entry("gp_interconnect_type TCP", "gp_interconnect_type", "TCP"),
+		Entry("checkpoint_completion_target", "checkpoint_completion_target", "0.9"),
+		Entry("bgwriter_delay in ms", "bgwriter_delay", "200"),
+		Entry("bgwriter_delay with unit", "bgwriter_delay", "1s"),
+		Entry("bgwriter_lru_maxpages", "bgwriter_lru_maxpages", "1000"),
+		Entry("bgwriter_lru_multiplier", "bgwriter_lru_multiplier", "2.5"),
+		Entry("gp_enable_global_deadlock_detector on", "gp_enable_global_deadlock_detector", "on"),
+		Entry("gp_enable_global_deadlock_detector false", "gp_enable_global_deadlock_detector", "false"),
+		Entry("gp_enable_global_deadlock_detector TRUE", "gp_enable_global_deadlock_detector", "TRUE"),
+		Entry("gp_max_slices unlimited", "gp_max_slices", "0"),
+		Entry("gp_max_slices", "gp_max_slices", "100"),
+		Entry("statement_mem in kB", "statement_mem", "128000"),
+		Entry("statement_mem with unit", "statement_mem", "1GB"),
+		Entry("max_statement_mem with unit", "max_statement_mem", "8GB"),
+		Entry("gp_segment_connect_timeout in seconds", "gp_segment_connect_timeout", "600"),
+		Entry("gp_segment_connect_timeout with unit", "gp_segment_connect_timeout", "10min"),
+		Entry("gp_interconnect_setup_timeout at the maximum", "gp_interconnect_setup_timeout", "2h"),
+		Entry("gp_interconnect_transmit_timeout with unit", "gp_interconnect_transmit_timeout", "600s"),
+		Entry("gp_interconnect_min_retries_before_timeout", "gp_interconnect_min_retries_before_timeout", "200"),
+		Entry("gp_fts_probe_interval in seconds", "gp_fts_probe_interval", "30"),
+		Entry("gp_fts_probe_interval at the maximum", "gp_fts_probe_interval", "1h"),
+		Entry("gp_fts_probe_timeout with unit", "gp_fts_probe_timeout", "10s"),
+		Entry("gp_fts_probe_retries of zero", "gp_fts_probe_retries", "0"),
+		Entry("gp_fts_probe_retries", "gp_fts_probe_retries", "10"),
+		Entry("arbitrary guc", "log_min_duration_statement", "5s"),
+		Entry("extension placeholder guc", "pxf.enable_filter_pushdown", "on"),
+	)
+
+	It("allows statement_mem above its default maximum when max_statement_mem is raised", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"statement_mem": "3GB", "max_statement_mem": "4GB"}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	DescribeTable("rejects statement_mem larger than max_statement_mem",
+		func(gucs map[string]string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.GUCs = gucs
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			expectedMessage := "config.gucs: statement_mem cannot be larger than max_statement_mem, which defaults to 2000MB"
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("with max_statement_mem set", map[string]string{"statement_mem": "1GB", "max_statement_mem": "512MB"}),
+		Entry("with the default max_statement_mem", map[string]string{"statement_mem": "3GB"}),
+	)
+
+	DescribeTable("rejects invalid gucs",
+		func(name, value, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.GUCs = map[string]string{name: value}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("guc name that is not an identifier", "max_connections; DROP", "100",
+			`config.gucs: "max_connections; DROP" is not a valid GUC name`),
+		Entry("guc name starting with a digit", "1optimizer", "on",
+			`config.gucs: "1optimizer" is not a valid GUC name`),
+		Entry("guc set by the operator", "port", "6000",
+			"config.gucs: port is set by the operator and cannot be set in config.gucs"),
+		Entry("guc set by the operator in upper case", "SSL", "off",
+			"config.gucs: SSL is set by the operator and cannot be set in config.gucs"),
+		Entry("guc value with a newline", "log_line_prefix", "%m\nfsync = off",
+			"config.gucs: invalid value for log_line_prefix: \"%m\nfsync = off\": must be a single line"),
+		Entry("negative gp_workfile_limit_per_query", "gp_workfile_limit_per_query", "-1",
+			`config.gucs: invalid value for gp_workfile_limit_per_query: "-1": must be a non-negative integer, optionally followed by a unit of kB, MB, GB or TB`),
+		Entry("gp_workfile_limit_per_segment with unknown unit", "gp_workfile_limit_per_segment", "10XB",
+			`config.gucs: invalid value for gp_workfile_limit_per_segment: "10XB": must be a non-negative integer, optionally followed by a unit of kB, MB, GB or TB`),
+		Entry("gp_workfile_limit_files_per_query with unit", "gp_workfile_limit_files_per_query", "10GB",
+			`config.gucs: invalid value for gp_workfile_limit_files_per_query: "10GB": must be a non-negative integer`),
+		Entry("negative gp_workfile_limit_files_per_query", "gp_workfile_limit_files_per_query", "-5",
+			`config.gucs: invalid value for gp_workfile_limit_files_per_query: "-5": must be a non-negative integer`),
+		Entry("gp_resource_group_cpu_limit above 1.0", "gp_resource_group_cpu_limit", "1.5",
+			`config.gucs: invalid value for gp_resource_group_cpu_limit: "1.5": must be a fraction between 0.1 and 1.0`),
+		Entry("gp_resource_group_cpu_limit as a percentage", "gp_resource_group_cpu_limit", "90%",
+			`config.gucs: invalid value for gp_resource_group_cpu_limit: "90%": must be a fraction between 0.1 and 1.0`),
+		Entry("gp_resource_group_memory_limit below 0.1", "gp_resource_group_memory_limit", "0.05",
+			`config.gucs: invalid value for gp_resource_group_memory_limit: "0.05": must be a fraction between 0.1 and 1.0`),
+		Entry("optimizer not on or off", "optimizer", "legacy",
+			`config.gucs: invalid value for optimizer: "legacy": must be on or off`),
+		Entry("gp_interconnect_type udp", "gp_interconnect_type", "udp",
+			`config.gucs: invalid value for gp_interconnect_type: "udp": must be udpifc or tcp`),
+		Entry("gp_interconnect_type proxy", "gp_interconnect_type", "proxy",
+			`config.gucs: invalid value for gp_interconnect_type: "proxy": must be udpifc or tcp`),
+		Entry("checkpoint_completion_target above 1.0", "checkpoint_completion_target", "1.5",
+			`config.gucs: invalid value for checkpoint_completion_target: "1.5": must be a number between 0.0 and 1.0`),
+		Entry("bgwriter_delay below 10ms", "bgwriter_delay", "5ms",
+			`config.gucs: invalid value for bgwriter_delay: "5ms": must be between 10ms and 10s, e.g. 200ms`),
+		Entry("bgwriter_delay above 10s", "bgwriter_delay", "11s",
+			`config.gucs: invalid value for bgwriter_delay: "11s": must be between 10ms and 10s, e.g. 200ms`),
+		Entry("bgwriter_delay with unknown unit", "bgwriter_delay", "1min",
+			`config.gucs: invalid value for bgwriter_delay: "1min": must be between 10ms and 10s, e.g. 200ms`),
+		Entry("bgwriter_lru_maxpages above 1000", "bgwriter_lru_maxpages", "1001",
+			`config.gucs: invalid value for bgwriter_lru_maxpages: "1001": must be an integer between 0 and 1000`),
+		Entry("negative bgwriter_lru_multiplier", "bgwriter_lru_multiplier", "-1",
+			`config.gucs: invalid value for bgwriter_lru_multiplier: "-1": must be a number between 0 and 10`),
+		Entry("gp_enable_global_deadlock_detector not a boolean", "gp_enable_global_deadlock_detector", "enabled",
+			`config.gucs: invalid value for gp_enable_global_deadlock_detector: "enabled": must be on, off, true or false`),
+		Entry("gp_enable_global_deadlock_detector as a number", "gp_enable_global_deadlock_detector", "1",
+			`config.gucs: invalid value for gp_enable_global_deadlock_detector: "1": must be on, off, true or false`),
+		Entry("negative gp_max_slices", "gp_max_slices", "-1",
+			`config.gucs: invalid value for gp_max_slices: "-1": must be a non-negative integer`),
+		Entry("statement_mem with unknown unit", "statement_mem", "1XB",
+			`config.gucs: invalid value for statement_mem: "1XB": must be a non-negative integer, optionally followed by a unit of kB, MB, GB or TB`),
+		Entry("statement_mem below 1000kB", "statement_mem", "999kB",
+			`config.gucs: invalid value for statement_mem: "999kB": must be at least 1000kB`),
+		Entry("max_statement_mem below 32MB", "max_statement_mem", "16MB",
+			`config.gucs: invalid value for max_statement_mem: "16MB": must be at least 32MB`),
+		Entry("gp_segment_connect_timeout of zero", "gp_segment_connect_timeout", "0",
+			`config.gucs: invalid value for gp_segment_connect_timeout: "0": must be between 1s and 86400s, in seconds unless a unit of s, min, h or d is given`),
+		Entry("gp_segment_connect_timeout in ms", "gp_segment_connect_timeout", "500ms",
+			`config.gucs: invalid value for gp_segment_connect_timeout: "500ms": must be between 1s and 86400s, in seconds unless a unit of s, min, h or d is given`),
+		Entry("gp_interconnect_setup_timeout above 2h", "gp_interconnect_setup_timeout", "3h",
+			`config.gucs: invalid value for gp_interconnect_setup_timeout: "3h": must be between 1s and 7200s, in seconds unless a unit of s, min, h or d is given`),
+		Entry("gp_interconnect_transmit_timeout not a duration", "gp_interconnect_transmit_timeout", "soon",
+			`config.gucs: invalid value for gp_interconnect_transmit_timeout: "soon": must be between 1s and 7200s, in seconds unless a unit of s, min, h or d is given`),
+		Entry("gp_interconnect_min_retries_before_timeout above 4096", "gp_interconnect_min_retries_before_timeout", "5000",
+			`config.gucs: invalid value for gp_interconnect_min_retries_before_timeout: "5000": must be an integer between 1 and 4096`),
+		Entry("gp_fts_probe_interval below 10s", "gp_fts_probe_interval", "5",
+			`config.gucs: invalid value for gp_fts_probe_interval: "5": must be between 10s and 3600s, in seconds unless a unit of s, min, h or d is given`),
+		Entry("gp_fts_probe_timeout of zero", "gp_fts_probe_timeout", "0",
+			`config.gucs: invalid value for gp_fts_probe_timeout: "0": must be between 1s and 3600s, in seconds unless a unit of s, min, h or d is given`),
+		Entry("gp_fts_probe_retries above 100", "gp_fts_probe_retries", "101",
+			`config.gucs: invalid value for gp_fts_probe_retries: "101": must be an integer between 0 and 100`),
+		Entry("negative gp_fts_probe_retries", "gp_fts_probe_retries", "-1",
+			`config.gucs: invalid value for gp_fts_probe_retries: "-1": must be an integer between 0 and 100`),
+	)
+
+	It("allows gp_fts_probe_timeout above its default interval when gp_fts_probe_interval is raised", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"gp_fts_probe_interval": "5min", "gp_fts_probe_timeout": "2min"}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	DescribeTable("rejects gp_fts_probe_timeout not less than gp_fts_probe_interval",
+		func(gucs map[string]string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.GUCs = gucs
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			expectedMessage := "config.gucs: gp_fts_probe_timeout must be less than gp_fts_probe_interval, which defaults to 60s"
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("with gp_fts_probe_interval set", map[string]string{"gp_fts_probe_interval": "30", "gp_fts_probe_timeout": "30s"}),
+		Entry("with the default gp_fts_probe_interval", map[string]string{"gp_fts_probe_timeout": "2min"}),
+	)
+
+	It("allows a valid schedulerName", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.SchedulerName = "volcano"
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("rejects a schedulerName that is not a valid name", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.SchedulerName = "Volcano_Scheduler"
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": HavePrefix(`invalid schedulerName value: "Volcano_Scheduler": a lowercase RFC 1123 subdomain must consist of`),
+		})))
+	})
+
+	When("sysctls are specified", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Annotations: map[string]string{greenplumv1.AllowedUnsafeSysctlsAnnotation: "kernel.sem, kernel.shm*"},
+				},
+			}
+			Expect(subject.KubeClient.Create(context.Background(), namespace)).To(Succeed())
+			newGreenplum = exampleGreenplum.DeepCopy()
+		})
+
+		It("allows safe sysctls", func() {
+			newGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "net.ipv4.ip_local_port_range", Value: "10000 65535"}}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("allows unsafe sysctls that the namespace allows", func() {
+			newGreenplum.Spec.Sysctls = []corev1.Sysctl{
+				{Name: "kernel.sem", Value: "500 2048000 200 40960"},
+				{Name: "kernel.shmmax", Value: "68719476736"},
+			}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects unsafe sysctls that the namespace does not allow", func() {
+			newGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "vm.overcommit_memory", Value: "2"}}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := "sysctl vm.overcommit_memory is unsafe and is not allowed in namespace test-ns; " +
+				"add it to the greenplum.pivotal.io/allowed-unsafe-sysctls annotation of the namespace to allow it"
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		})
+
+		It("rejects an unsafe sysctl that only shares a prefix with an allowed sysctl", func() {
+			newGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "kernel.semmni", Value: "4096"}}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(HavePrefix("sysctl kernel.semmni is unsafe"))
+		})
+	})
+
+	When("an unsafe sysctl is specified and the namespace cannot be read", func() {
+		BeforeEach(func() {
+			reactiveClient := reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+			reactiveClient.PrependReactor("get", "namespaces", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, errors.New("injected error")
+			})
+			subject.KubeClient = reactiveClient
+		})
+		It("rejects the request", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "kernel.sem", Value: "500 2048000 200 40960"}}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal("could not get namespace test-ns to check allowed unsafe sysctls: injected error"))
+		})
+	})
+
+	When("a caBundle is specified", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "corporate-ca"},
+				Data: map[string]string{
+					"ca.crt":      string(cabundletesting.SelfSignedCAPEM("corporate-root-ca")),
+					"invalid.crt": "not a certificate",
+				},
+			}
+			Expect(subject.KubeClient.Create(context.Background(), configMap)).To(Succeed())
+			newGreenplum = exampleGreenplum.DeepCopy()
+		})
+
+		It("allows a ConfigMap holding a valid bundle", func() {
+			newGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects a missing configMapName", func() {
+			newGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal("caBundle configMapName must be specified"))
+		})
+
+		It("rejects a ConfigMap that does not exist", func() {
+			newGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "missing-ca"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`invalid caBundle: ConfigMap "missing-ca" not found`))
+		})
+
+		It("rejects a key that does not hold a certificate", func() {
+			newGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca", Key: "invalid.crt"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := `invalid caBundle: ConfigMap "corporate-ca" key "invalid.crt": no PEM-encoded certificates found`
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result.Message).To(Equal(expectedMessage))
+		})
+	})
+
+	When("tls is specified", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{"greenplum.test-ns.svc.cluster.local"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+			otherCertPEM, otherKeyPEM := clienttlstesting.ServerCertificatePEM([]string{"greenplum.example.com"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+			for _, secret := range []*corev1.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "greenplum-server-tls"},
+					Data:       map[string][]byte{"server.crt": certPEM, "server.key": keyPEM},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "other-server-tls"},
+					Data:       map[string][]byte{"server.crt": otherCertPEM, "server.key": otherKeyPEM},
+				},
+			} {
+				Expect(subject.KubeClient.Create(context.Background(), secret)).To(Succeed())
+			}
+			newGreenplum = exampleGreenplum.DeepCopy()
+		})
+
+		It("allows a Secret holding a certificate for the master Service", func() {
+			newGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects a missing secretName", func() {
+			newGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal("tls secretName must be specified"))
+		})
+
+		It("rejects a Secret that does not exist", func() {
+			newGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "missing-tls"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`invalid tls: Secret "missing-tls" not found`))
+		})
+
+		It("rejects a certificate for another name", func() {
+			newGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "other-server-tls"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := `invalid tls: Secret "other-server-tls": certificate is not valid for greenplum.test-ns.svc.cluster.local`
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result.Message).To(Equal(expectedMessage))
+		})
+	})
+
+	When("auth.ldap is specified", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "ldap-bind"},
+				Data:       map[string][]byte{"password": []byte("s3cret")},
+			}
+			Expect(subject.KubeClient.Create(context.Background(), secret)).To(Succeed())
+			newGreenplum = exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Auth = &greenplumv1.GreenplumAuthSpec{LDAP: &greenplumv1.GreenplumLDAPSpec{
+				ServerURL:          "ldap://ldap.example.com",
+				Mode:               "searchBind",
+				BaseDN:             "ou=people,dc=example,dc=com",
+				SearchBindDN:       "cn=greenplum,dc=example,dc=com",
+				SearchBindPassword: &greenplumv1.GreenplumLDAPBindPasswordSpec{SecretName: "ldap-bind"},
+				Roles:              []string{"analyst"},
+				CreateMissingRoles: true,
+			}}
+		})
+
+		It("allows a valid searchBind configuration", func() {
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects fields that the mode requires but are missing", func() {
+			newGreenplum.Spec.Auth.LDAP.BaseDN = ""
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := "invalid auth.ldap: baseDN is required in searchBind mode"
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result.Message).To(Equal(expectedMessage))
+		})
+
+		It("rejects invalid role names", func() {
+			newGreenplum.Spec.Auth.LDAP.Roles = []string{"Analyst"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`auth.ldap.roles: invalid role name "Analyst": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`))
+		})
+
+		It("rejects pg_hba.conf keywords as roles", func() {
+			newGreenplum.Spec.Auth.LDAP.Roles = []string{"all"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`auth.ldap.roles: "all" is a keyword in pg_hba.conf`))
+		})
+
+		It("rejects gpadmin", func() {
+			newGreenplum.Spec.Auth.LDAP.Roles = []string{"analyst", "gpadmin"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal("auth.ldap.roles: gpadmin cannot authenticate with LDAP because the operator connects as gpadmin"))
+		})
+
+		It("rejects a searchBindPassword Secret that does not exist", func() {
+			newGreenplum.Spec.Auth.LDAP.SearchBindPassword.SecretName = "missing-ldap-bind"
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`invalid auth.ldap searchBindPassword: Secret "missing-ldap-bind" not found`))
+		})
+	})
+
+	When("an entrypointScript is specified", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-entrypoint"},
+				Data: map[string]string{
+					"entrypoint.sh": "#!/bin/bash\nmount-extra-storage\n",
+					"invalid.sh":    "mount-extra-storage\n",
+				},
+			}
+			Expect(subject.KubeClient.Create(context.Background(), configMap)).To(Succeed())
+			newGreenplum = exampleGreenplum.DeepCopy()
+		})
+
+		It("allows a ConfigMap holding a valid script", func() {
+			newGreenplum.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects a missing configMapName", func() {
+			newGreenplum.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal("entrypointScript configMapName must be specified"))
+		})
+
+		It("rejects a ConfigMap that does not exist", func() {
+			newGreenplum.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "missing-entrypoint"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`invalid entrypointScript: ConfigMap "missing-entrypoint" not found`))
+		})
+
+		It("rejects a key that does not hold a script", func() {
+			newGreenplum.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint", Key: "invalid.sh"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := `invalid entrypointScript: ConfigMap "my-entrypoint" key "invalid.sh": the script must start with a #! line, e.g. #!/bin/bash`
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result.Message).To(Equal(expectedMessage))
+		})
+	})
+
+	When("monitoring.prometheus customQueries are specified", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-queries"},
+				Data: map[string]string{
+					"queries.yaml":  "pg_database_size:\n  query: SELECT pg_database_size('postgres') AS bytes\n",
+					"reserved.yaml": "greenplum_mine:\n  query: SELECT 1\n",
+				},
+			}
+			Expect(subject.KubeClient.Create(context.Background(), configMap)).To(Succeed())
+			newGreenplum = exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+				Prometheus: &greenplumv1.GreenplumPrometheusSpec{Enabled: true},
+			}
+		})
+
+		It("allows a ConfigMap holding valid queries", func() {
+			newGreenplum.Spec.Monitoring.Prometheus.CustomQueries = &greenplumv1.GreenplumCustomQueriesSpec{ConfigMapName: "my-queries"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects a missing configMapName", func() {
+			newGreenplum.Spec.Monitoring.Prometheus.CustomQueries = &greenplumv1.GreenplumCustomQueriesSpec{}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal("monitoring.prometheus.customQueries configMapName must be specified"))
+		})
+
+		It("rejects a ConfigMap that does not exist", func() {
+			newGreenplum.Spec.Monitoring.Prometheus.CustomQueries = &greenplumv1.GreenplumCustomQueriesSpec{ConfigMapName: "missing-queries"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`invalid monitoring.prometheus.customQueries: ConfigMap "missing-queries" not found`))
+		})
+
+		It("rejects queries with a reserved name", func() {
+			newGreenplum.Spec.Monitoring.Prometheus.CustomQueries = &greenplumv1.GreenplumCustomQueriesSpec{ConfigMapName: "my-queries", Key: "reserved.yaml"}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := `invalid monitoring.prometheus.customQueries: ConfigMap "my-queries" key "reserved.yaml": query name "greenplum_mine" must not start with greenplum_, which is reserved for the built-in queries`
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result.Message).To(Equal(expectedMessage))
+		})
+	})
+
+	When("monitoring.perfCheck is set", func() {
+		var newGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			newGreenplum = exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+				PerfCheck: &greenplumv1.GreenplumPerfCheckSpec{MinDiskWriteMBps: 200},
+			}
+		})
+
+		It("allows a threshold", func() {
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		})
+
+		It("rejects a perfCheck without thresholds", func() {
+			newGreenplum.Spec.Monitoring.PerfCheck.MinDiskWriteMBps = 0
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := "invalid monitoring.perfCheck: at least one of minDiskWriteMBps, minDiskReadMBps and minNetworkMBps must be specified"
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result.Message).To(Equal(expectedMessage))
+		})
+
+		It("rejects an interval shorter than an hour", func() {
+			newGreenplum.Spec.Monitoring.PerfCheck.Interval = &metav1.Duration{Duration: 10 * time.Minute}
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(outputReview.Response.Result.Message).To(Equal(`invalid monitoring.perfCheck: invalid interval value: "10m0s": must be at least 1h0m0s`))
+		})
+	})
+
+	DescribeTable("allows valid dataDirectoryUmask values",
+		func(umask string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.DataDirectoryUmask = umask
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		},
+		Entry("unset", ""),
+		Entry("0077", "0077"),
+		Entry("027", "027"),
+	)
+
+	DescribeTable("rejects invalid dataDirectoryUmask values",
+		func(umask, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.DataDirectoryUmask = umask
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("not octal", "0089",
+			`invalid config dataDirectoryUmask value: "0089": must be an octal umask, e.g. 0077`),
+		Entry("too large", "1777",
+			`invalid config dataDirectoryUmask value: "1777": must be an octal umask, e.g. 0077`),
+		Entry("masks owner permissions", "0277",
+			`invalid config dataDirectoryUmask value: "0277": must not remove any permissions from the owner`),
+	)
+
+	DescribeTable("allows valid arrayName values",
+		func(arrayName string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.ArrayName = arrayName
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		},
+		Entry("unset", ""),
+		Entry("with spaces", "Analytics Warehouse"),
+		Entry("with punctuation", "gp_prod-6.x"),
+	)
+
+	DescribeTable("rejects invalid arrayName values",
+		func(arrayName string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.ArrayName = arrayName
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			expectedMessage := fmt.Sprintf(`invalid config arrayName value: "%s": must be at most 64 letters, digits, spaces, underscores, periods or hyphens, starting with a letter or digit`, arrayName)
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("starting with a space", " warehouse"),
+		Entry("containing a quote", `my "array"`),
+		Entry("containing a shell expansion", "array$(id)"),
+		Entry("too long", strings.Repeat("a", 65)),
+	)
+
+	It("allows valid roleConnectionLimits", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": 10, "etl_user": 0, "_reporting": -1}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	DescribeTable("rejects invalid roleConnectionLimits",
+		func(limits map[string]int32, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.RoleConnectionLimits = limits
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("uppercase role", map[string]int32{"Analyst": 10},
+			`config.roleConnectionLimits: invalid role name "Analyst": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`),
+		Entry("role with a quote", map[string]int32{`analyst"; DROP ROLE x; --`: 10},
+			`config.roleConnectionLimits: invalid role name "analyst"; DROP ROLE x; --": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`),
+		Entry("role starting with a digit", map[string]int32{"1analyst": 10},
+			`config.roleConnectionLimits: invalid role name "1analyst": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`),
+		Entry("gpadmin", map[string]int32{"gpadmin": 10},
+			"config.roleConnectionLimits: gpadmin cannot be limited because the operator connects as gpadmin"),
+		Entry("limit below -1", map[string]int32{"analyst": -2},
+			"config.roleConnectionLimits: invalid limit for analyst: -2: must be -1 for no limit, or 0 or more"),
+	)
+
+	It("allows allowlisted extensions", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.Extensions = []string{"pgcrypto", "uuid-ossp", "hstore"}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	const allowedExtensionsList = "citext, dblink, fuzzystrmatch, hstore, ltree, pg_trgm, pgcrypto, postgres_fdw, tablefunc, uuid-ossp"
+	DescribeTable("rejects disallowed extensions",
+		func(extensions []string, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.Extensions = extensions
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("unknown extension", []string{"pgcrypto", "plpythonu"},
+			`config.extensions: extension "plpythonu" is not allowed: must be one of `+allowedExtensionsList),
+		Entry("extension that needs preloading", []string{"pg_stat_statements"},
+			`config.extensions: extension "pg_stat_statements" is not allowed: must be one of `+allowedExtensionsList),
+		Entry("different case", []string{"PGCRYPTO"},
+			`config.extensions: extension "PGCRYPTO" is not allowed: must be one of `+allowedExtensionsList),
+		Entry("injected statement", []string{`pgcrypto"; DROP DATABASE gpadmin; --`},
+			`config.extensions: extension "pgcrypto"; DROP DATABASE gpadmin; --" is not allowed: must be one of `+allowedExtensionsList),
+		Entry("duplicate extension", []string{"pgcrypto", "hstore", "pgcrypto"},
+			`config.extensions: extension "pgcrypto" is listed more than once`),
+	)
+
+	It("allows valid pgHbaEntries", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgHbaEntries = []string{
+			"hostssl all app_user 10.0.0.0/16 md5",
+			"host all all 127.0.0.1/32 trust",
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows trust from other addresses with allowInsecureAuth", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgHbaEntries = []string{"host all all 10.0.0.0/8 trust"}
+		newGreenplum.Spec.Config.AllowInsecureAuth = true
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+	})
+
+	DescribeTable("rejects invalid pgHbaEntries",
+		func(entries []string, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.PgHbaEntries = entries
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("missing method", []string{"host all app_user 10.0.0.0/16 md5", "host all all 10.0.0.0/8"},
+			"config.pgHbaEntries[1]: must have a connection type, database, user, address and authentication method"),
+		Entry("unknown method", []string{"host all all 10.0.0.0/8 scram-sha-256"},
+			`config.pgHbaEntries[0]: unknown authentication method "scram-sha-256"`),
+		Entry("injected line", []string{"host all all 10.0.0.0/8 md5\nhost all all 0.0.0.0/0 trust"},
+			"config.pgHbaEntries[0]: must be a single line"),
+		Entry("trust from a network", []string{"host all all 0.0.0.0/0 trust"},
+			"config.pgHbaEntries[0]: trust is only allowed from loopback addresses unless allowInsecureAuth is set"),
+	)
+
+	It("allows valid roleSettings", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.RoleSettings = map[string]map[string]string{
+			"gpadmin": {
+				"search_path":        `"$user", public, "Sales Data"`,
+				"statement_timeout":  "5min",
+				"datestyle":          "'ISO, MDY'",
+				"gp_resource_group":  "default_group",
+				"myapp.tenant":       "'it''s'",
+				"random_page_cost":   "1.5",
+				"optimizer":          "off",
+				"work_mem":           "64MB",
+				"extra_float_digits": "-3",
+			},
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	DescribeTable("rejects invalid roleSettings",
+		func(roleSettings map[string]map[string]string, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.RoleSettings = roleSettings
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("uppercase role", map[string]map[string]string{"Analyst": {"search_path": "public"}},
+			`config.roleSettings: invalid role name "Analyst": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`),
+		Entry("role with a quote", map[string]map[string]string{`gpadmin" SET x = 1; --`: {"search_path": "public"}},
+			`config.roleSettings: invalid role name "gpadmin" SET x = 1; --": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`),
+		Entry("parameter with a space", map[string]map[string]string{"gpadmin": {"search path": "public"}},
+			`config.roleSettings: invalid parameter name for gpadmin: "search path"`),
+		Entry("parameter with an injected statement", map[string]map[string]string{"gpadmin": {"search_path = public; DROP ROLE x; SET x": "1"}},
+			`config.roleSettings: invalid parameter name for gpadmin: "search_path = public; DROP ROLE x; SET x"`),
+		Entry("empty value", map[string]map[string]string{"gpadmin": {"search_path": ""}},
+			`config.roleSettings: invalid value for gpadmin search_path: "": must be a comma-separated list of words, numbers, "quoted identifiers" or 'quoted strings'`),
+		Entry("value with an injected statement", map[string]map[string]string{"gpadmin": {"search_path": "public; DROP ROLE x"}},
+			`config.roleSettings: invalid value for gpadmin search_path: "public; DROP ROLE x": must be a comma-separated list of words, numbers, "quoted identifiers" or 'quoted strings'`),
+		Entry("unterminated quote", map[string]map[string]string{"gpadmin": {"datestyle": "'ISO, MDY"}},
+			`config.roleSettings: invalid value for gpadmin datestyle: "'ISO, MDY": must be a comma-separated list of words, numbers, "quoted identifiers" or 'quoted strings'`),
+		Entry("trailing comma", map[string]map[string]string{"gpadmin": {"search_path": "sales,"}},
+			`config.roleSettings: invalid value for gpadmin search_path: "sales,": must be a comma-separated list of words, numbers, "quoted identifiers" or 'quoted strings'`),
+		Entry("newline", map[string]map[string]string{"gpadmin": {"search_path": "'sales\npublic'"}},
+			"config.roleSettings: invalid value for gpadmin search_path: \"'sales\npublic'\": must be a comma-separated list of words, numbers, \"quoted identifiers\" or 'quoted strings'"),
+	)
+
+	It("allows resource queues with the queue resource manager", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"gp_resource_manager": "queue", "gp_resqueue_priority": "on"}
+		newGreenplum.Spec.Config.ResourceQueues = []greenplumv1.GreenplumResourceQueueSpec{
+			{Name: "reporting", ActiveStatements: 5, MemoryLimit: "2GB", Priority: "LOW", Roles: []string{"analyst"}},
+			{Name: "etl", ActiveStatements: 2, Roles: []string{"loader"}},
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	DescribeTable("rejects resource queues combined with resource groups, or invalid resource queues",
+		func(gucs map[string]string, resourceQueues []greenplumv1.GreenplumResourceQueueSpec, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Config.GUCs = gucs
+			newGreenplum.Spec.Config.ResourceQueues = resourceQueues
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("queues with the default resource manager", nil,
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "reporting", ActiveStatements: 5}},
+			"config.resourceQueues requires config.gucs gp_resource_manager to be queue"),
+		Entry("queues with resource groups", map[string]string{"gp_resource_manager": "group"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "reporting", ActiveStatements: 5}},
+			"config.resourceQueues requires config.gucs gp_resource_manager to be queue"),
+		Entry("queue priority with resource groups", map[string]string{"gp_resqueue_priority": "on"}, nil,
+			"config.gucs: gp_resqueue_priority requires gp_resource_manager to be queue"),
+		Entry("resource group limits with resource queues", map[string]string{"gp_resource_manager": "queue", "gp_resource_group_memory_limit": "0.8"}, nil,
+			"config.gucs: gp_resource_group_memory_limit cannot be set when gp_resource_manager is queue"),
+		Entry("invalid resource manager", map[string]string{"gp_resource_manager": "none"}, nil,
+			`config.gucs: invalid value for gp_resource_manager: "none": must be group or queue`),
+		Entry("queue name with a quote", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: `etl" WITH (ACTIVE_STATEMENTS=1); --`, ActiveStatements: 5}},
+			`config.resourceQueues: invalid queue name "etl" WITH (ACTIVE_STATEMENTS=1); --": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`),
+		Entry("the default queue", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "pg_default", ActiveStatements: 5}},
+			"config.resourceQueues: pg_default is created by Greenplum and cannot be listed"),
+		Entry("duplicate queue", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "etl", ActiveStatements: 5}, {Name: "etl", ActiveStatements: 1}},
+			`config.resourceQueues: queue "etl" is listed more than once`),
+		Entry("no active statements", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "etl"}},
+			"config.resourceQueues: invalid activeStatements for etl: 0: must be at least 1"),
+		Entry("memory limit without a unit", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "etl", ActiveStatements: 5, MemoryLimit: "2048"}},
+			`config.resourceQueues: invalid memoryLimit for etl: "2048": must be a positive integer followed by a unit of kB, MB or GB`),
+		Entry("invalid priority", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "etl", ActiveStatements: 5, Priority: "URGENT"}},
+			`config.resourceQueues: invalid priority for etl: "URGENT": must be MIN, LOW, MEDIUM, HIGH or MAX`),
+		Entry("gpadmin in a queue", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{{Name: "etl", ActiveStatements: 5, Roles: []string{"gpadmin"}}},
+			"config.resourceQueues: gpadmin cannot be assigned to a resource queue because superusers are not limited by them"),
+		Entry("role in two queues", map[string]string{"gp_resource_manager": "queue"},
+			[]greenplumv1.GreenplumResourceQueueSpec{
+				{Name: "etl", ActiveStatements: 5, Roles: []string{"loader"}},
+				{Name: "reporting", ActiveStatements: 5, Roles: []string{"loader"}},
+			},
+			"config.resourceQueues: role loader is assigned to both etl and reporting"),
+	)
+
+	When("autoPrimarySegmentCount is yes", func() {
+		It("allows a cluster without a primarySegmentCount", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "Yes"
+			newGreenplum.Spec.Segments.PrimarySegmentCount = 0
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+			Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+			Expect(outputReview.Response.Result).To(BeNil())
+		})
+		It("rejects a cluster that also sets primarySegmentCount", func() {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "yes"
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+
+			expectedMessage := "primarySegmentCount cannot be set when autoPrimarySegmentCount is yes"
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		})
+	})
+
+	It("rejects a cluster without a primarySegmentCount when autoPrimarySegmentCount is not yes", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.PrimarySegmentCount = 0
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+
+		expectedMessage := "primarySegmentCount must be at least 1 unless autoPrimarySegmentCount is yes"
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+	})
+
+	It("allows an adminPasswordRotationInterval of at least an hour", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.AdminPasswordRotationInterval = &metav1.Duration{Duration: 720 * time.Hour}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("rejects an adminPasswordRotationInterval shorter than an hour", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.AdminPasswordRotationInterval = &metav1.Duration{Duration: 30 * time.Minute}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+
+		expectedMessage := `invalid masterAndStandby adminPasswordRotationInterval value: "30m0s": must be at least 1h0m0s`
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+	})
+
+	It("allows interconnectTLS with a certificate validity", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{
+			CertificateValidity: &metav1.Duration{Duration: 720 * time.Hour},
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("rejects an interconnectTLS certificateValidity shorter than a day", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{
+			CertificateValidity: &metav1.Duration{Duration: 12 * time.Hour},
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+
+		expectedMessage := `invalid interconnectTLS certificateValidity value: "12h0m0s": must be at least 24h0m0s`
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+	})
+
+	It("allows a disaster recovery standby of a cluster in another namespace", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{
+			PrimaryNamespace:    "primary-ns",
+			ReplicationInterval: &metav1.Duration{Duration: 30 * time.Minute},
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedGreenplumClusterEntry())
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows a disaster recovery standby of a cluster outside the Kubernetes cluster", func() {
+		newGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{
+			PrimaryHost:           "primary.example.com",
+			PrimaryPort:           6432,
+			CredentialsSecretName: "primary-credentials",
+		}
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "did not match expected allowed value")
+	})
+
+	DescribeTable("rejects invalid disasterRecovery settings",
+		func(disasterRecovery greenplumv1.GreenplumDisasterRecoverySpec, expectedMessage string) {
+			newGreenplum := exampleGreenplum.DeepCopy()
+			newGreenplum.Spec.DisasterRecovery = &disasterRecovery
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, nil)
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "did not match expected allowed value")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedGreenplumClusterEntry(expectedMessage))
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		},
+		Entry("no primary", greenplumv1.GreenplumDisasterRecoverySpec{},
+			"disasterRecovery requires one of primaryNamespace and primaryHost"),
+		Entry("both primaryNamespace and primaryHost", greenplumv1.GreenplumDisasterRecoverySpec{
+			PrimaryNamespace: "primary-ns",
+			PrimaryHost:      "primary.example.com",
+		}, "disasterRecovery primaryNamespace and primaryHost cannot be used together"),
+		Entry("primaryPort out of range", greenplumv1.GreenplumDisasterRecoverySpec{
+			PrimaryHost: "primary.example.com",
+			PrimaryPort: 70000,
+		}, "invalid disasterRecovery primaryPort 70000: must be between 1 and 65535"),
+		Entry("primaryNamespace is its own namespace", greenplumv1.GreenplumDisasterRecoverySpec{PrimaryNamespace: "test-ns"},
+			"disasterRecovery primaryNamespace must be a different namespace than the GreenplumCluster"),
+		Entry("replicationInterval too short", greenplumv1.GreenplumDisasterRecoverySpec{
+			PrimaryNamespace:    "primary-ns",
+			ReplicationInterval: &metav1.Duration{Duration: time.Minute},
+		}, `invalid disasterRecovery replicationInterval value: "1m0s": must be at least 10m0s`),
+	)
 })
 
 func generateGPDBLabels(additionalLabels map[string]string) map[string]string {
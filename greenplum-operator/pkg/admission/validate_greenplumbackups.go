@@ -0,0 +1,26 @@
+package admission
+
+import (
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateGreenplumBackup checks the gpbackup options of a GreenplumBackup, e.g. its table and schema filters.
+// The operator records status by updating the whole object, so an update that leaves the spec alone is allowed
+func (h *Handler) validateGreenplumBackup(oldBackup, newBackup *greenplumv1.GreenplumBackup) (allowed bool, result *metav1.Status) {
+	if oldBackup != nil && equality.Semantic.DeepEqual(oldBackup.Spec, newBackup.Spec) {
+		allowed = true
+		return
+	}
+	if err := backupjob.ValidateBackupSpec(newBackup.Spec); err != nil {
+		result = &metav1.Status{Message: fmt.Sprintf("invalid GreenplumBackup: %s", err)}
+		return
+	}
+
+	allowed = true
+	return
+}
@@ -0,0 +1,77 @@
+package admission_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/admission"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("validateGreenplumBackup", func() {
+	var (
+		subject admission.Handler
+		backup  *greenplumv1.GreenplumBackup
+	)
+	BeforeEach(func() {
+		reactiveClient := reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		subject = admission.Handler{
+			KubeClient: reactiveClient,
+		}
+		admission.Log = gplog.ForTest(gbytes.NewBuffer())
+		backup = &greenplumv1.GreenplumBackup{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "GreenplumBackup",
+				APIVersion: "greenplum.pivotal.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "test-ns"},
+			Spec: greenplumv1.GreenplumBackupSpec{
+				ClusterName: "my-greenplum",
+				GreenplumBackupOptions: greenplumv1.GreenplumBackupOptions{
+					BackupDir:     "/backups",
+					ExcludeTables: []string{"public.scratch"},
+				},
+			},
+		}
+	})
+
+	It("allows valid table filters", func() {
+		outputReview := postValidateReview(subject.Handler(), backup, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue())
+	})
+
+	It("rejects a table filter that is not schema-qualified", func() {
+		backup.Spec.IncludeTables = nil
+		backup.Spec.ExcludeTables = []string{"scratch"}
+		outputReview := postValidateReview(subject.Handler(), backup, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+		Expect(outputReview.Response.Result.Message).To(Equal(`invalid GreenplumBackup: invalid excludeTables entry "scratch": must be in the form <schema>.<table>`))
+	})
+
+	It("rejects includeTables together with excludeTables", func() {
+		backup.Spec.IncludeTables = []string{"public.orders"}
+		outputReview := postValidateReview(subject.Handler(), backup, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+		Expect(outputReview.Response.Result.Message).To(Equal("invalid GreenplumBackup: includeTables and excludeTables cannot be used together"))
+	})
+
+	It("rejects an update that makes the filters invalid", func() {
+		oldBackup := backup.DeepCopy()
+		backup.Spec.ExcludeTables = []string{"public.my scratch"}
+		outputReview := postValidateReview(subject.Handler(), backup, oldBackup)
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+	})
+
+	It("allows an update that leaves the spec alone, so that status can be recorded", func() {
+		backup.Spec.ExcludeTables = []string{"scratch"}
+		oldBackup := backup.DeepCopy()
+		backup.Status.Phase = greenplumv1.GreenplumBackupPhaseFailed
+		outputReview := postValidateReview(subject.Handler(), backup, oldBackup)
+		Expect(outputReview.Response.Allowed).To(BeTrue())
+	})
+})
@@ -0,0 +1,24 @@
+package admission
+
+import (
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/backupjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (h *Handler) validateGreenplumBackupSchedule(newSchedule *greenplumv1.GreenplumBackupSchedule) (allowed bool, result *metav1.Status) {
+	if _, err := cron.Parse(newSchedule.Spec.Schedule); err != nil {
+		result = &metav1.Status{Message: fmt.Sprintf("invalid schedule %q: %s", newSchedule.Spec.Schedule, err)}
+		return
+	}
+	if err := backupjob.ValidateBackupSpec(newSchedule.Spec.Template); err != nil {
+		result = &metav1.Status{Message: fmt.Sprintf("invalid template: %s", err)}
+		return
+	}
+
+	allowed = true
+	return
+}
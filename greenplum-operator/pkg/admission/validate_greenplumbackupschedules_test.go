@@ -0,0 +1,83 @@
+package admission_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/admission"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
+	. "github.com/pivotal/greenplum-for-kubernetes/pkg/gplog/testing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("validateGreenplumBackupSchedule", func() {
+	var (
+		subject  admission.Handler
+		logBuf   *gbytes.Buffer
+		schedule *greenplumv1.GreenplumBackupSchedule
+	)
+	BeforeEach(func() {
+		reactiveClient := reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		subject = admission.Handler{
+			KubeClient: reactiveClient,
+		}
+		logBuf = gbytes.NewBuffer()
+		admission.Log = gplog.ForTest(logBuf)
+		schedule = &greenplumv1.GreenplumBackupSchedule{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "GreenplumBackupSchedule",
+				APIVersion: "greenplum.pivotal.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "test-ns"},
+			Spec: greenplumv1.GreenplumBackupScheduleSpec{
+				Schedule: "0 2 * * *",
+				Template: greenplumv1.GreenplumBackupSpec{
+					ClusterName:            "my-greenplum",
+					GreenplumBackupOptions: greenplumv1.GreenplumBackupOptions{BackupDir: "/backups"},
+				},
+			},
+		}
+	})
+
+	It("allows a valid schedule", func() {
+		outputReview := postValidateReview(subject.Handler(), schedule, nil)
+		Expect(outputReview.Response.Allowed).To(BeTrue())
+		Expect(DecodeLogs(logBuf)).To(ContainLogEntry(Keys{
+			"msg":       Equal("/validate"),
+			"GVK":       Equal("greenplum.pivotal.io/v1, Kind=GreenplumBackupSchedule"),
+			"Name":      Equal("nightly"),
+			"Operation": Equal("CREATE"),
+			"Allowed":   BeTrue(),
+		}))
+	})
+
+	It("rejects an invalid cron expression", func() {
+		schedule.Spec.Schedule = "0 2 * *"
+		outputReview := postValidateReview(subject.Handler(), schedule, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(`invalid schedule "0 2 * *": expected 5 fields (minute hour day-of-month month day-of-week), found 4 in "0 2 * *"`),
+		})))
+	})
+
+	It("rejects an invalid cron expression on update", func() {
+		oldSchedule := schedule.DeepCopy()
+		schedule.Spec.Schedule = "0 24 * * *"
+		outputReview := postValidateReview(subject.Handler(), schedule, oldSchedule)
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+		Expect(outputReview.Response.Result.Message).To(Equal(`invalid schedule "0 24 * * *": value 24 out of range 0-23 in hour field "24"`))
+	})
+
+	It("rejects invalid gpbackup options in the template", func() {
+		schedule.Spec.Template.CompressionLevel = 5
+		schedule.Spec.Template.NoCompression = true
+		outputReview := postValidateReview(subject.Handler(), schedule, nil)
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+		Expect(outputReview.Response.Result.Message).To(Equal("invalid template: compressionLevel and noCompression cannot be used together"))
+	})
+})
@@ -0,0 +1,321 @@
+package admission
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type gucValidator func(value string) error
+
+// knownGUCs lists the GUCs in spec.config.gucs whose values are validated. Any other GUC with a valid name
+// is passed to Greenplum as given, which reports invalid values in the logs
+var knownGUCs = map[string]gucValidator{
+	"gp_workfile_limit_per_query":        validateMemoryGUC,
+	"gp_workfile_limit_per_segment":      validateMemoryGUC,
+	"gp_workfile_limit_files_per_query":  validateNonNegativeIntegerGUC,
+	"gp_resource_group_cpu_limit":        validateResourceGroupLimitGUC,
+	"gp_resource_group_memory_limit":     validateResourceGroupLimitGUC,
+	"gp_resource_manager":                validateResourceManagerGUC,
+	"gp_resqueue_priority":               validateBooleanGUC,
+	"optimizer":                          validateOnOffGUC,
+	"gp_interconnect_type":               validateInterconnectTypeGUC,
+	"checkpoint_completion_target":       validateFractionGUC,
+	"bgwriter_delay":                     validateBgwriterDelayGUC,
+	"bgwriter_lru_maxpages":              validateBgwriterLRUMaxPagesGUC,
+	"bgwriter_lru_multiplier":            validateBgwriterLRUMultiplierGUC,
+	"gp_enable_global_deadlock_detector": validateBooleanGUC,
+	"gp_max_slices":                      validateNonNegativeIntegerGUC,
+	"statement_mem":                      validateStatementMemGUC,
+	"max_statement_mem":                  validateMaxStatementMemGUC,
+	// Timeouts for flaky networks between the master and the segments
+	"gp_segment_connect_timeout":                 validateSecondsGUC(1, 24*60*60),
+	"gp_interconnect_setup_timeout":              validateSecondsGUC(1, 2*60*60),
+	"gp_interconnect_transmit_timeout":           validateSecondsGUC(1, 2*60*60),
+	"gp_interconnect_min_retries_before_timeout": validateIntegerRangeGUC(1, 4096),
+	// Fault detection: how often FTS probes the segments, and how long and how many times it waits for a segment
+	// before marking it down and failing over to its mirror
+	"gp_fts_probe_interval": validateSecondsGUC(10, 60*60),
+	"gp_fts_probe_timeout":  validateSecondsGUC(1, 60*60),
+	"gp_fts_probe_retries":  validateIntegerRangeGUC(0, 100),
+}
+
+// Greenplum's default max_statement_mem, which caps statement_mem when max_statement_mem is not set
+const defaultMaxStatementMemKB = 2000 * 1024
+
+// Greenplum's default gp_fts_probe_interval, which bounds gp_fts_probe_timeout when gp_fts_probe_interval is not set
+const defaultFTSProbeIntervalSeconds = 60
+
+func validateGUCs(gucs map[string]string) (result *metav1.Status) {
+	names := make([]string, 0, len(gucs))
+	for name := range gucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !gucName.MatchString(name) {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.gucs: "%s" is not a valid GUC name`, name)}
+			return
+		}
+		if configmap.OperatorGUCs[strings.ToLower(name)] {
+			result = &metav1.Status{Message: fmt.Sprintf("config.gucs: %s is set by the operator and cannot be set in config.gucs", name)}
+			return
+		}
+		validator, ok := knownGUCs[name]
+		if !ok {
+			validator = validateSingleLineGUC
+		}
+		if err := validator(gucs[name]); err != nil {
+			result = &metav1.Status{Message: fmt.Sprintf(`config.gucs: invalid value for %s: "%s": %s`, name, gucs[name], err.Error())}
+			return
+		}
+	}
+
+	// Queries fail to start when statement_mem is larger than max_statement_mem
+	if statementMem, ok := gucs["statement_mem"]; ok {
+		maxStatementMemKB := int64(defaultMaxStatementMemKB)
+		if maxStatementMem, ok := gucs["max_statement_mem"]; ok {
+			maxStatementMemKB, _ = memoryGUCKilobytes(maxStatementMem)
+		}
+		if statementMemKB, _ := memoryGUCKilobytes(statementMem); statementMemKB > maxStatementMemKB {
+			result = &metav1.Status{Message: "config.gucs: statement_mem cannot be larger than max_statement_mem, which defaults to 2000MB"}
+			return
+		}
+	}
+
+	// A probe that outlasts the interval delays the next one, so failures are detected later than configured
+	if ftsProbeTimeout, ok := gucs["gp_fts_probe_timeout"]; ok {
+		ftsProbeIntervalSeconds := int64(defaultFTSProbeIntervalSeconds)
+		if ftsProbeInterval, ok := gucs["gp_fts_probe_interval"]; ok {
+			ftsProbeIntervalSeconds, _ = secondsGUCSeconds(ftsProbeInterval)
+		}
+		if ftsProbeTimeoutSeconds, _ := secondsGUCSeconds(ftsProbeTimeout); ftsProbeTimeoutSeconds >= ftsProbeIntervalSeconds {
+			result = &metav1.Status{Message: "config.gucs: gp_fts_probe_timeout must be less than gp_fts_probe_interval, which defaults to 60s"}
+			return
+		}
+	}
+	return
+}
+
+// GUC names are identifiers, with a prefix for the placeholder GUCs of extensions, e.g. pxf.enable_filter_pushdown
+var gucName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Values are written to postgresql.conf, one line per GUC
+func validateSingleLineGUC(value string) error {
+	if strings.ContainsAny(value, "\n\r\x00") {
+		return fmt.Errorf("must be a single line")
+	}
+	return nil
+}
+
+// Memory GUCs are in kB unless a unit is given, e.g. 10GB
+var memoryGUCValue = regexp.MustCompile(`^[0-9]+(kB|MB|GB|TB)?$`)
+
+func validateMemoryGUC(value string) error {
+	if !memoryGUCValue.MatchString(value) {
+		return fmt.Errorf("must be a non-negative integer, optionally followed by a unit of kB, MB, GB or TB")
+	}
+	return nil
+}
+
+// memoryGUCKilobytes converts a memory GUC value to kB
+func memoryGUCKilobytes(value string) (int64, error) {
+	match := memoryGUCValue.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("must be a non-negative integer, optionally followed by a unit of kB, MB, GB or TB")
+	}
+	kilobytes, err := strconv.ParseInt(strings.TrimSuffix(value, match[1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("must be a non-negative integer, optionally followed by a unit of kB, MB, GB or TB")
+	}
+	switch match[1] {
+	case "MB":
+		kilobytes *= 1024
+	case "GB":
+		kilobytes *= 1024 * 1024
+	case "TB":
+		kilobytes *= 1024 * 1024 * 1024
+	}
+	return kilobytes, nil
+}
+
+// statement_mem is per query per segment, and Greenplum rejects values below 1000kB
+func validateStatementMemGUC(value string) error {
+	kilobytes, err := memoryGUCKilobytes(value)
+	if err != nil {
+		return err
+	}
+	if kilobytes < 1000 {
+		return fmt.Errorf("must be at least 1000kB")
+	}
+	return nil
+}
+
+// Greenplum rejects max_statement_mem values below 32MB
+func validateMaxStatementMemGUC(value string) error {
+	kilobytes, err := memoryGUCKilobytes(value)
+	if err != nil {
+		return err
+	}
+	if kilobytes < 32*1024 {
+		return fmt.Errorf("must be at least 32MB")
+	}
+	return nil
+}
+
+func validateNonNegativeIntegerGUC(value string) error {
+	if i, err := strconv.ParseInt(value, 10, 32); err != nil || i < 0 {
+		return fmt.Errorf("must be a non-negative integer")
+	}
+	return nil
+}
+
+// Greenplum accepts resource group limits between 0.1 and 1.0 of the segment host's resources
+func validateResourceGroupLimitGUC(value string) error {
+	if f, err := strconv.ParseFloat(value, 64); err != nil || f < 0.1 || f > 1.0 {
+		return fmt.Errorf("must be a fraction between 0.1 and 1.0")
+	}
+	return nil
+}
+
+func validateResourceManagerGUC(value string) error {
+	if value != "group" && value != "queue" {
+		return fmt.Errorf("must be group or queue")
+	}
+	return nil
+}
+
+func validateOnOffGUC(value string) error {
+	if value != "on" && value != "off" {
+		return fmt.Errorf("must be on or off")
+	}
+	return nil
+}
+
+func validateBooleanGUC(value string) error {
+	for _, boolean := range []string{"on", "off", "true", "false"} {
+		if strings.EqualFold(value, boolean) {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be on, off, true or false")
+}
+
+func validateFractionGUC(value string) error {
+	if f, err := strconv.ParseFloat(value, 64); err != nil || f < 0 || f > 1.0 {
+		return fmt.Errorf("must be a number between 0.0 and 1.0")
+	}
+	return nil
+}
+
+// bgwriter_delay is in milliseconds unless a unit is given
+var bgwriterDelayValue = regexp.MustCompile(`^([0-9]+)(ms|s)?$`)
+
+func validateBgwriterDelayGUC(value string) error {
+	errOutOfRange := fmt.Errorf("must be between 10ms and 10s, e.g. 200ms")
+	match := bgwriterDelayValue.FindStringSubmatch(value)
+	if match == nil {
+		return errOutOfRange
+	}
+	delay, err := strconv.ParseInt(match[1], 10, 32)
+	if err != nil {
+		return errOutOfRange
+	}
+	if match[2] == "s" {
+		delay *= 1000
+	}
+	if delay < 10 || delay > 10000 {
+		return errOutOfRange
+	}
+	return nil
+}
+
+// Timeout GUCs are in seconds unless a unit is given, e.g. 3min
+var secondsGUCValue = regexp.MustCompile(`^([0-9]+)(s|min|h|d)?$`)
+
+// secondsGUCSeconds converts a timeout GUC value to seconds
+func secondsGUCSeconds(value string) (int64, error) {
+	match := secondsGUCValue.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid value")
+	}
+	seconds, err := strconv.ParseInt(match[1], 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	switch match[2] {
+	case "min":
+		seconds *= 60
+	case "h":
+		seconds *= 60 * 60
+	case "d":
+		seconds *= 24 * 60 * 60
+	}
+	return seconds, nil
+}
+
+// validateSecondsGUC accepts timeouts between min and max seconds
+func validateSecondsGUC(min, max int64) gucValidator {
+	return func(value string) error {
+		seconds, err := secondsGUCSeconds(value)
+		if err != nil || seconds < min || seconds > max {
+			return fmt.Errorf("must be between %ds and %ds, in seconds unless a unit of s, min, h or d is given", min, max)
+		}
+		return nil
+	}
+}
+
+func validateIntegerRangeGUC(min, max int64) gucValidator {
+	return func(value string) error {
+		if i, err := strconv.ParseInt(value, 10, 32); err != nil || i < min || i > max {
+			return fmt.Errorf("must be an integer between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+func validateBgwriterLRUMaxPagesGUC(value string) error {
+	if i, err := strconv.ParseInt(value, 10, 32); err != nil || i < 0 || i > 1000 {
+		return fmt.Errorf("must be an integer between 0 and 1000")
+	}
+	return nil
+}
+
+func validateBgwriterLRUMultiplierGUC(value string) error {
+	if f, err := strconv.ParseFloat(value, 64); err != nil || f < 0 || f > 10 {
+		return fmt.Errorf("must be a number between 0 and 10")
+	}
+	return nil
+}
+
+// The proxy interconnect needs gp_interconnect_proxy_addresses for every segment, which the operator does not manage
+func validateInterconnectTypeGUC(value string) error {
+	if !strings.EqualFold(value, "udpifc") && !strings.EqualFold(value, "tcp") {
+		return fmt.Errorf("must be udpifc or tcp")
+	}
+	return nil
+}
+
+// changedInitOnlyGUC returns the first GUC, by name, that can only be set when the cluster is initialized
+// and is set, changed or removed by the update, or "" if there is none
+func changedInitOnlyGUC(newGUCs, oldGUCs map[string]string) string {
+	var names []string
+	for name := range configmap.InitOnlyGUCs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		newValue, newOK := newGUCs[name]
+		oldValue, oldOK := oldGUCs[name]
+		if newOK != oldOK || newValue != oldValue {
+			return name
+		}
+	}
+	return ""
+}
@@ -3,12 +3,26 @@ package admission
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/entrypointscript"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/exporter"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/ldapauth"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/perfcheckjob"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const MaxLabelLen = 63
@@ -23,6 +37,267 @@ func validateWorkerSelector(workerSelector map[string]string, typ string) (resul
 	return
 }
 
+// validateScheduling checks that the nodeSelector and affinity of the master and standby or of the segments do not
+// contradict workerSelector, or the affinity that the operator sets to keep pods apart
+func validateScheduling(newGreenplum greenplumv1.GreenplumCluster, gpPodSpec greenplumv1.GreenplumPodSpec, typ string) (result *metav1.Status) {
+	for key, value := range gpPodSpec.NodeSelector {
+		if workerSelectorValue, ok := gpPodSpec.WorkerSelector[key]; ok && workerSelectorValue != value {
+			result = &metav1.Status{Message: fmt.Sprintf(`%s nodeSelector %s=%s conflicts with workerSelector %s=%s`, typ, key, value, key, workerSelectorValue)}
+			return
+		}
+		if strings.HasPrefix(key, operatorNodeLabelPrefix) {
+			result = &metav1.Status{Message: fmt.Sprintf(`%s nodeSelector cannot use %s: the operator labels nodes with %s* for antiAffinity`, typ, key, operatorNodeLabelPrefix)}
+			return
+		}
+	}
+
+	affinity := gpPodSpec.Affinity
+	if affinity == nil {
+		return
+	}
+	if nodeAffinity := affinity.NodeAffinity; nodeAffinity != nil {
+		var terms []corev1.NodeSelectorTerm
+		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+			terms = append(terms, nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms...)
+		}
+		for _, preferred := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, preferred.Preference)
+		}
+		for _, term := range terms {
+			for _, requirement := range term.MatchExpressions {
+				if strings.HasPrefix(requirement.Key, operatorNodeLabelPrefix) {
+					result = &metav1.Status{Message: fmt.Sprintf(`%s affinity cannot use %s: the operator labels nodes with %s* for antiAffinity`, typ, requirement.Key, operatorNodeLabelPrefix)}
+					return
+				}
+			}
+		}
+	}
+
+	// The pods that the operator keeps on different nodes: the master and standby when antiAffinity is set,
+	// and a primary and its mirror, which the operator moves apart even without antiAffinity
+	var separatedPodLabels []map[string]string
+	if typ == "masterAndStandby" {
+		if strings.EqualFold(newGreenplum.Spec.MasterAndStandby.Standby, "yes") && strings.EqualFold(gpPodSpec.AntiAffinity, "yes") {
+			separatedPodLabels = append(separatedPodLabels, greenplumPodLabels(newGreenplum.Name, "master"))
+		}
+	} else if strings.EqualFold(newGreenplum.Spec.Segments.Mirrors, "yes") {
+		separatedPodLabels = append(separatedPodLabels,
+			greenplumPodLabels(newGreenplum.Name, "segment-a"), greenplumPodLabels(newGreenplum.Name, "segment-b"))
+	}
+	if affinity.PodAffinity == nil || len(separatedPodLabels) == 0 {
+		return
+	}
+	for _, term := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey != corev1.LabelHostname || !podAffinityTermInNamespace(term, newGreenplum.Namespace) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+		if err != nil {
+			result = &metav1.Status{Message: fmt.Sprintf("invalid %s affinity podAffinity labelSelector: %s", typ, err.Error())}
+			return
+		}
+		for _, podLabels := range separatedPodLabels {
+			if selector.Matches(labels.Set(podLabels)) {
+				result = &metav1.Status{Message: fmt.Sprintf("%s affinity cannot require %s pods to be on the same node as %s pods: "+
+					"the operator keeps them on different nodes", typ, typ, podLabels["type"])}
+				return
+			}
+		}
+	}
+	return
+}
+
+// The prefix of the node labels that the operator sets for antiAffinity
+const operatorNodeLabelPrefix = "greenplum-affinity-"
+
+func greenplumPodLabels(clusterName, typ string) map[string]string {
+	return map[string]string{
+		"app":               greenplumv1.AppName,
+		"type":              typ,
+		"greenplum-cluster": clusterName,
+	}
+}
+
+// podAffinityTermInNamespace reports whether term may select pods in namespace. A namespaceSelector is assumed to
+// select it
+func podAffinityTermInNamespace(term corev1.PodAffinityTerm, namespace string) bool {
+	if len(term.Namespaces) == 0 || term.NamespaceSelector != nil {
+		return true
+	}
+	for _, termNamespace := range term.Namespaces {
+		if termNamespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// The API server requires a pod's schedulerName to be a DNS-1123 subdomain
+func validateSchedulerName(schedulerName string) (result *metav1.Status) {
+	if schedulerName == "" {
+		return
+	}
+	if errs := validation.IsDNS1123Subdomain(schedulerName); len(errs) > 0 {
+		result = &metav1.Status{Message: fmt.Sprintf(`invalid schedulerName value: "%s": %s`, schedulerName, strings.Join(errs, "; "))}
+	}
+	return
+}
+
+// safeSysctls are the namespaced sysctls that the kubelet allows by default
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+}
+
+func (h *Handler) validateSysctls(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	var namespace *corev1.Namespace
+	for _, sysctl := range newGreenplum.Spec.Sysctls {
+		if safeSysctls[sysctl.Name] {
+			continue
+		}
+		if namespace == nil {
+			namespace = &corev1.Namespace{}
+			if err := h.KubeClient.Get(ctx, types.NamespacedName{Name: newGreenplum.Namespace}, namespace); err != nil {
+				result = &metav1.Status{Message: fmt.Sprintf("could not get namespace %s to check allowed unsafe sysctls: %s", newGreenplum.Namespace, err.Error())}
+				return
+			}
+		}
+		if !unsafeSysctlAllowed(sysctl.Name, namespace.Annotations[greenplumv1.AllowedUnsafeSysctlsAnnotation]) {
+			result = &metav1.Status{Message: fmt.Sprintf("sysctl %s is unsafe and is not allowed in namespace %s; add it to the %s annotation of the namespace to allow it",
+				sysctl.Name, newGreenplum.Namespace, greenplumv1.AllowedUnsafeSysctlsAnnotation)}
+			return
+		}
+	}
+	return
+}
+
+func (h *Handler) validateTLS(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	tlsSpec := newGreenplum.Spec.TLS
+	if tlsSpec == nil {
+		return
+	}
+	if tlsSpec.SecretName == "" {
+		result = &metav1.Status{Message: "tls secretName must be specified"}
+		return
+	}
+	if err := clienttls.ValidateSecret(ctx, h.KubeClient, newGreenplum.Namespace, *tlsSpec, time.Now()); err != nil {
+		result = &metav1.Status{Message: "invalid tls: " + err.Error()}
+	}
+	return
+}
+
+// pg_hba.conf reads these as keywords in the user field, not as role names
+var pgHbaUserKeywords = map[string]bool{
+	"all":         true,
+	"sameuser":    true,
+	"samerole":    true,
+	"replication": true,
+}
+
+func (h *Handler) validateLDAP(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	if newGreenplum.Spec.Auth == nil || newGreenplum.Spec.Auth.LDAP == nil {
+		return
+	}
+	ldapSpec := *newGreenplum.Spec.Auth.LDAP
+	if err := ldapauth.Validate(ldapSpec); err != nil {
+		result = &metav1.Status{Message: "invalid auth.ldap: " + err.Error()}
+		return
+	}
+	for _, role := range ldapSpec.Roles {
+		if !roleNamePattern.MatchString(role) {
+			result = &metav1.Status{Message: fmt.Sprintf(`auth.ldap.roles: invalid role name "%s": must be at most 63 lowercase letters, digits or underscores, starting with a letter or underscore`, role)}
+			return
+		}
+		if pgHbaUserKeywords[role] {
+			result = &metav1.Status{Message: fmt.Sprintf(`auth.ldap.roles: "%s" is a keyword in pg_hba.conf`, role)}
+			return
+		}
+		if role == "gpadmin" {
+			result = &metav1.Status{Message: "auth.ldap.roles: gpadmin cannot authenticate with LDAP because the operator connects as gpadmin"}
+			return
+		}
+	}
+	if _, err := ldapauth.GetBindPassword(ctx, h.KubeClient, newGreenplum.Namespace, ldapSpec); err != nil {
+		result = &metav1.Status{Message: "invalid auth.ldap searchBindPassword: " + err.Error()}
+	}
+	return
+}
+
+func (h *Handler) validateCABundle(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	caBundle := newGreenplum.Spec.CABundle
+	if caBundle == nil {
+		return
+	}
+	if caBundle.ConfigMapName == "" {
+		result = &metav1.Status{Message: "caBundle configMapName must be specified"}
+		return
+	}
+	if err := cabundle.ValidateConfigMap(ctx, h.KubeClient, newGreenplum.Namespace, *caBundle); err != nil {
+		result = &metav1.Status{Message: "invalid caBundle: " + err.Error()}
+	}
+	return
+}
+
+func (h *Handler) validateEntrypointScript(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	entrypointScript := newGreenplum.Spec.EntrypointScript
+	if entrypointScript == nil {
+		return
+	}
+	if entrypointScript.ConfigMapName == "" {
+		result = &metav1.Status{Message: "entrypointScript configMapName must be specified"}
+		return
+	}
+	if err := entrypointscript.ValidateConfigMap(ctx, h.KubeClient, newGreenplum.Namespace, *entrypointScript); err != nil {
+		result = &metav1.Status{Message: "invalid entrypointScript: " + err.Error()}
+	}
+	return
+}
+
+func (h *Handler) validateMonitoring(ctx context.Context, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	monitoring := newGreenplum.Spec.Monitoring
+	if monitoring == nil {
+		return
+	}
+	if monitoring.PerfCheck != nil {
+		if err := perfcheckjob.ValidateSpec(*monitoring.PerfCheck); err != nil {
+			result = &metav1.Status{Message: "invalid monitoring.perfCheck: " + err.Error()}
+			return
+		}
+	}
+	if monitoring.Prometheus == nil || monitoring.Prometheus.CustomQueries == nil {
+		return
+	}
+	customQueries := monitoring.Prometheus.CustomQueries
+	if customQueries.ConfigMapName == "" {
+		result = &metav1.Status{Message: "monitoring.prometheus.customQueries configMapName must be specified"}
+		return
+	}
+	if err := exporter.ValidateCustomQueries(ctx, h.KubeClient, newGreenplum.Namespace, *customQueries); err != nil {
+		result = &metav1.Status{Message: "invalid monitoring.prometheus.customQueries: " + err.Error()}
+	}
+	return
+}
+
+func unsafeSysctlAllowed(name, allowedUnsafeSysctls string) bool {
+	for _, allowed := range strings.Split(allowedUnsafeSysctls, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "" {
+			continue
+		}
+		if strings.HasSuffix(allowed, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(allowed, "*")) {
+				return true
+			}
+		} else if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
 func validateResourceQuantity(quantity resource.Quantity, typ, field string) (result *metav1.Status) {
 	if quantity.Sign() == -1 {
 		result = &metav1.Status{Message: fmt.Sprintf(`invalid %s %s value: "%s": must be greater than or equal to 0`, typ, field, quantity.String())}
@@ -30,6 +305,30 @@ func validateResourceQuantity(quantity resource.Quantity, typ, field string) (re
 	return
 }
 
+// validateResources checks that no request in the resources of the master and standby or of the segments is
+// greater than its limit, counting the limits that default to memory and cpu
+func validateResources(gpPodSpec greenplumv1.GreenplumPodSpec, typ string) (result *metav1.Status) {
+	resources := sset.Resources(gpPodSpec)
+	var names []string
+	for name := range resources.Requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request := resources.Requests[corev1.ResourceName(name)]
+		if request.Sign() == -1 {
+			result = &metav1.Status{Message: fmt.Sprintf(`invalid %s resources.requests.%s value: "%s": must be greater than or equal to 0`, typ, name, request.String())}
+			return
+		}
+		limit, ok := resources.Limits[corev1.ResourceName(name)]
+		if ok && request.Cmp(limit) > 0 {
+			result = &metav1.Status{Message: fmt.Sprintf("%s resources.requests.%s %s must be less than or equal to its limit %s", typ, name, request.String(), limit.String())}
+			return
+		}
+	}
+	return
+}
+
 func (h *Handler) validateStorageHelper(pvcList *corev1.PersistentVolumeClaimList, newStorage resource.Quantity, newStorageClassName, parentObjectType string) (result *metav1.Status) {
 	if len(pvcList.Items) > 0 {
 		pvc := &pvcList.Items[0]
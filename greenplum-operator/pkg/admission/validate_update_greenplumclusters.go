@@ -8,11 +8,14 @@ import (
 
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const UpgradeClusterHelpMsg = "Cannot update greenplumCluster instance -- operator only supports updates to clusters " +
@@ -33,6 +36,11 @@ func (h *Handler) validateUpdateGreenplumCluster(ctx context.Context, oldGreenpl
 		return
 	}
 
+	result = validateAutoFailover(newGreenplum)
+	if result != nil {
+		return
+	}
+
 	if newGreenplum.Spec.MasterAndStandby.HostBasedAuthentication != oldGreenplum.Spec.MasterAndStandby.HostBasedAuthentication {
 		result = &metav1.Status{Message: "hostBasedAuthentication cannot be changed after the cluster has been created"}
 		return
@@ -63,13 +71,40 @@ func (h *Handler) validateUpdateGreenplumCluster(ctx context.Context, oldGreenpl
 	}
 
 	if strings.ToLower(newGreenplum.Spec.Segments.Mirrors) != strings.ToLower(oldGreenplum.Spec.Segments.Mirrors) {
-		result = &metav1.Status{Message: "mirrors cannot be changed after the cluster has been created"}
+		if strings.ToLower(newGreenplum.Spec.Segments.Mirrors) != "yes" {
+			result = &metav1.Status{Message: "mirrors cannot be disabled after the cluster has been created"}
+			return
+		}
+		result = h.validateEnableMirrors(ctx, oldGreenplum, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	// nodeSelector, tolerations and affinity may change, but must still agree with workerSelector and antiAffinity
+	result = validateScheduling(newGreenplum, newGreenplum.Spec.MasterAndStandby.GreenplumPodSpec, "masterAndStandby")
+	if result != nil {
+		return
+	}
+	result = validateScheduling(newGreenplum, newGreenplum.Spec.Segments.GreenplumPodSpec, "segments")
+	if result != nil {
 		return
 	}
 
-	if newGreenplum.Spec.MasterAndStandby.Storage != oldGreenplum.Spec.MasterAndStandby.Storage ||
-		newGreenplum.Spec.Segments.Storage != oldGreenplum.Spec.Segments.Storage {
-		result = &metav1.Status{Message: "storage cannot be changed after the cluster has been created"}
+	// Unlike memory and cpu, resources may change; the statefulsets then restart the pods one at a time
+	result = validateResources(newGreenplum.Spec.MasterAndStandby.GreenplumPodSpec, "masterAndStandby")
+	if result != nil {
+		return
+	}
+	result = validateResources(newGreenplum.Spec.Segments.GreenplumPodSpec, "segments")
+	if result != nil {
+		return
+	}
+
+	// The operator grows the existing PVCs when storage is increased; a PVC cannot shrink
+	if newGreenplum.Spec.MasterAndStandby.Storage.Cmp(oldGreenplum.Spec.MasterAndStandby.Storage) < 0 ||
+		newGreenplum.Spec.Segments.Storage.Cmp(oldGreenplum.Spec.Segments.Storage) < 0 {
+		result = &metav1.Status{Message: "storage cannot be decreased after the cluster has been created"}
 		return
 	}
 
@@ -79,7 +114,34 @@ func (h *Handler) validateUpdateGreenplumCluster(ctx context.Context, oldGreenpl
 		return
 	}
 
-	if newGreenplum.Spec.Segments.PrimarySegmentCount < oldGreenplum.Spec.Segments.PrimarySegmentCount {
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.MasterAndStandby.GpadminHome, oldGreenplum.Spec.MasterAndStandby.GpadminHome) {
+		result = &metav1.Status{Message: "gpadminHome cannot be changed after the cluster has been created"}
+		return
+	}
+
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.TempTablespace, oldGreenplum.Spec.TempTablespace) {
+		result = &metav1.Status{Message: "tempTablespace cannot be changed after the cluster has been created"}
+		return
+	}
+
+	// The temp tablespace is only created when the cluster is initialized
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.SpillVolume, oldGreenplum.Spec.SpillVolume) {
+		result = &metav1.Status{Message: "spillVolume cannot be changed after the cluster has been created"}
+		return
+	}
+
+	// The ssl GUCs are only set when the cluster is initialized; the certificate validity may change
+	if (newGreenplum.Spec.InterconnectTLS == nil) != (oldGreenplum.Spec.InterconnectTLS == nil) {
+		result = &metav1.Status{Message: "interconnectTLS cannot be enabled or disabled after the cluster has been created"}
+		return
+	}
+
+	result = validateAutoPrimarySegmentCountUpdate(oldGreenplum, newGreenplum)
+	if result != nil {
+		return
+	}
+
+	if newGreenplum.Spec.Segments.PrimarySegmentCount < currentPrimarySegmentCount(oldGreenplum) {
 		result = &metav1.Status{Message: "primarySegmentCount cannot be decreased after the cluster has been created"}
 		return
 	}
@@ -94,12 +156,198 @@ func (h *Handler) validateUpdateGreenplumCluster(ctx context.Context, oldGreenpl
 		return
 	}
 
+	// Other GUCs may change: the operator applies them with gpconfig, and restarts the pods for those that need it
+	if name := changedInitOnlyGUC(newGreenplum.Spec.Config.GUCs, oldGreenplum.Spec.Config.GUCs); name != "" {
+		result = &metav1.Status{Message: fmt.Sprintf("config.gucs: %s cannot be changed after the cluster has been created", name)}
+		return
+	}
+
+	result = validateGUCs(newGreenplum.Spec.Config.GUCs)
+	if result != nil {
+		return
+	}
+
+	if newGreenplum.Spec.SchedulerName != oldGreenplum.Spec.SchedulerName {
+		result = &metav1.Status{Message: "schedulerName cannot be changed after the cluster has been created"}
+		return
+	}
+
+	// A namespace may stop allowing an unsafe sysctl; only block updates that change the sysctls
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.Sysctls, oldGreenplum.Spec.Sysctls) {
+		result = h.validateSysctls(ctx, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	// The operator does not turn ssl off on the master again
+	if oldGreenplum.Spec.TLS != nil && newGreenplum.Spec.TLS == nil {
+		result = &metav1.Status{Message: "tls cannot be disabled after it has been enabled"}
+		return
+	}
+
+	// A renewed certificate in an unchanged Secret reference is checked by the operator when it installs it
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.TLS, oldGreenplum.Spec.TLS) {
+		result = h.validateTLS(ctx, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.Auth, oldGreenplum.Spec.Auth) {
+		result = h.validateLDAP(ctx, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	// The bundle in an unchanged ConfigMap reference was validated when it was set
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.CABundle, oldGreenplum.Spec.CABundle) {
+		result = h.validateCABundle(ctx, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.EntrypointScript, oldGreenplum.Spec.EntrypointScript) {
+		result = h.validateEntrypointScript(ctx, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.Monitoring, oldGreenplum.Spec.Monitoring) {
+		result = h.validateMonitoring(ctx, newGreenplum)
+		if result != nil {
+			return
+		}
+	}
+
+	if newGreenplum.Spec.Config.DataDirectoryUmask != oldGreenplum.Spec.Config.DataDirectoryUmask {
+		result = &metav1.Status{Message: "config.dataDirectoryUmask cannot be changed after the cluster has been created"}
+		return
+	}
+
+	if newGreenplum.Spec.Config.ArrayName != oldGreenplum.Spec.Config.ArrayName {
+		result = &metav1.Status{Message: "config.arrayName cannot be changed after the cluster has been created"}
+		return
+	}
+
+	// An unset pgStatStatements is the same as no
+	if strings.EqualFold(newGreenplum.Spec.Config.PgStatStatements, "yes") != strings.EqualFold(oldGreenplum.Spec.Config.PgStatStatements, "yes") {
+		result = &metav1.Status{Message: "config.pgStatStatements cannot be changed after the cluster has been created"}
+		return
+	}
+
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.Config.RoleSettings, oldGreenplum.Spec.Config.RoleSettings) {
+		result = &metav1.Status{Message: "config.roleSettings cannot be changed after the cluster has been created"}
+		return
+	}
+
+	if !equality.Semantic.DeepEqual(newGreenplum.Spec.Config.ResourceQueues, oldGreenplum.Spec.Config.ResourceQueues) {
+		result = &metav1.Status{Message: "config.resourceQueues cannot be changed after the cluster has been created"}
+		return
+	}
+
+	result = validateRoleConnectionLimits(newGreenplum.Spec.Config.RoleConnectionLimits)
+	if result != nil {
+		return
+	}
+
+	// Extensions may be added later; the operator creates them
+	result = validateExtensions(newGreenplum.Spec.Config.Extensions)
+	if result != nil {
+		return
+	}
+
+	// pg_hba entries may change at any time; the operator reloads the configuration
+	result = validatePgHbaEntries(newGreenplum.Spec.Config)
+	if result != nil {
+		return
+	}
+
+	result = validateAdminPasswordRotationInterval(newGreenplum.Spec.MasterAndStandby.AdminPasswordRotationInterval)
+	if result != nil {
+		return
+	}
+
+	result = validateInterconnectTLS(newGreenplum.Spec.InterconnectTLS)
+	if result != nil {
+		return
+	}
+
+	result = validateDisasterRecovery(newGreenplum)
+	if result != nil {
+		return
+	}
+
+	// A promoted standby may stop being a standby, but the next copy would overwrite the writes made since the failover
+	if oldGreenplum.Spec.DisasterRecovery != nil && oldGreenplum.Spec.DisasterRecovery.Promote &&
+		newGreenplum.Spec.DisasterRecovery != nil && !newGreenplum.Spec.DisasterRecovery.Promote {
+		result = &metav1.Status{Message: "disasterRecovery promote cannot be unset after the cluster has been promoted"}
+		return
+	}
+
 	allowed = true
 	return
 }
 
+// validateEnableMirrors checks that mirrors can be added to a cluster created without them. Each mirror must be
+// on a different node than its primary, so there must be at least two nodes for segments.
+func (h *Handler) validateEnableMirrors(ctx context.Context, oldGreenplum, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	if oldGreenplum.Status.Phase != greenplumv1.GreenplumClusterPhaseRunning {
+		result = &metav1.Status{Message: "mirrors can only be enabled when the cluster is Running"}
+		return
+	}
+
+	var nodeList corev1.NodeList
+	if err := h.KubeClient.List(ctx, &nodeList, client.MatchingLabels(sset.NodeSelector(newGreenplum.Spec.Segments.GreenplumPodSpec))); err != nil {
+		result = &metav1.Status{Message: "failed to list nodes for segments: " + err.Error()}
+		return
+	}
+	schedulableNodes := 0
+	for _, node := range nodeList.Items {
+		if sset.IsNodeSchedulable(node) {
+			schedulableNodes++
+		}
+	}
+	if schedulableNodes < 2 {
+		result = &metav1.Status{Message: fmt.Sprintf("enabling mirrors requires at least 2 schedulable nodes for segments, "+
+			"so that each mirror is on a different node than its primary; found %d", schedulableNodes)}
+		return
+	}
+	return
+}
+
+// validateAutoPrimarySegmentCountUpdate only lets an auto-sized cluster switch to an explicit primarySegmentCount, which
+// is how it is expanded. The operator does not resolve the count again, so the switch back is not allowed.
+func validateAutoPrimarySegmentCountUpdate(oldGreenplum, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
+	oldAuto := strings.ToLower(oldGreenplum.Spec.Segments.AutoPrimarySegmentCount) == "yes"
+	newAuto := strings.ToLower(newGreenplum.Spec.Segments.AutoPrimarySegmentCount) == "yes"
+	if newAuto {
+		if !oldAuto {
+			result = &metav1.Status{Message: "autoPrimarySegmentCount cannot be enabled after the cluster has been created"}
+		} else if newGreenplum.Spec.Segments.PrimarySegmentCount != 0 {
+			result = &metav1.Status{Message: "primarySegmentCount cannot be set when autoPrimarySegmentCount is yes; set autoPrimarySegmentCount to no to change it"}
+		}
+		return
+	}
+	if oldAuto && oldGreenplum.Status.PrimarySegmentCount == 0 {
+		result = &metav1.Status{Message: "autoPrimarySegmentCount cannot be disabled before the operator has resolved primarySegmentCount"}
+	}
+	return
+}
+
+// currentPrimarySegmentCount is the count in status for an auto-sized cluster, since its spec leaves it unset
+func currentPrimarySegmentCount(greenplum greenplumv1.GreenplumCluster) int32 {
+	if strings.ToLower(greenplum.Spec.Segments.AutoPrimarySegmentCount) == "yes" {
+		return greenplum.Status.PrimarySegmentCount
+	}
+	return greenplum.Spec.Segments.PrimarySegmentCount
+}
+
 func (h *Handler) validateExpand(ctx context.Context, oldGreenplum, newGreenplum greenplumv1.GreenplumCluster) (result *metav1.Status) {
-	if newGreenplum.Spec.Segments.PrimarySegmentCount > oldGreenplum.Spec.Segments.PrimarySegmentCount {
+	if newGreenplum.Spec.Segments.PrimarySegmentCount > currentPrimarySegmentCount(oldGreenplum) {
 		// TODO: Actually query the gpdb status server (once it's implemented)
 		if oldGreenplum.Status.Phase != greenplumv1.GreenplumClusterPhaseRunning {
 			result = &metav1.Status{Message: "updates only supported when cluster is Running"}
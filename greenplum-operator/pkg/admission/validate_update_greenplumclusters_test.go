@@ -1,7 +1,9 @@
 package admission_test
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -11,6 +13,8 @@ import (
 	"github.com/onsi/gomega/types"
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/admission"
+	cabundletesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle/testing"
+	clienttlstesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls/testing"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/executor/fake"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/gpexpandjob"
 	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
@@ -18,7 +22,9 @@ import (
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/gplog"
 	. "github.com/pivotal/greenplum-for-kubernetes/pkg/gplog/testing"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/testing"
 	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -111,7 +117,7 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		})
 		When("there is a gpexpand job with status Completed", func() {
 			BeforeEach(func() {
-				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2)
+				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2, false)
 				job.Status = batchv1.JobStatus{
 					Active:    0,
 					Succeeded: 1,
@@ -125,7 +131,7 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		})
 		When("there is a gpexpand job with status Failed", func() {
 			BeforeEach(func() {
-				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2)
+				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2, false)
 				job.Status = batchv1.JobStatus{
 					Active:    0,
 					Succeeded: 0,
@@ -140,7 +146,7 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		})
 		When("there is a gpexpand job that is still running", func() {
 			BeforeEach(func() {
-				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2)
+				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2, false)
 				job.Status = batchv1.JobStatus{
 					Active:    1,
 					Succeeded: 0,
@@ -155,7 +161,7 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		})
 		When("there's a job with uninitialized status", func() {
 			BeforeEach(func() {
-				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2)
+				job = gpexpandjob.GenerateJob("blah", "some-hostname", 2, false)
 				job.Status = batchv1.JobStatus{
 					Active:    0,
 					Succeeded: 0,
@@ -222,6 +228,102 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
 	})
 
+	It("disallows requests that enable autoPrimarySegmentCount", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "yes"
+		newGreenplum.Spec.Segments.PrimarySegmentCount = 0
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse())
+		const expectedMessage = "autoPrimarySegmentCount cannot be enabled after the cluster has been created"
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	When("autoPrimarySegmentCount is yes", func() {
+		var oldGreenplum *greenplumv1.GreenplumCluster
+		BeforeEach(func() {
+			oldGreenplum = exampleGreenplum.DeepCopy()
+			oldGreenplum.Spec.Segments.AutoPrimarySegmentCount = "yes"
+			oldGreenplum.Spec.Segments.PrimarySegmentCount = 0
+			oldGreenplum.Status.PrimarySegmentCount = 3
+		})
+
+		It("disallows requests that set primarySegmentCount", func() {
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.PrimarySegmentCount = 8
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse())
+			const expectedMessage = "primarySegmentCount cannot be set when autoPrimarySegmentCount is yes; set autoPrimarySegmentCount to no to change it"
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+		})
+
+		It("allows switching to the resolved count", func() {
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "no"
+			newGreenplum.Spec.Segments.PrimarySegmentCount = 3
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeTrue(), "%#v", outputReview.Response.Result)
+		})
+
+		It("checks switching to a higher count as an expansion", func() {
+			oldGreenplum.Status.Phase = greenplumv1.GreenplumClusterPhasePending
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "no"
+			newGreenplum.Spec.Segments.PrimarySegmentCount = 5
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse())
+			const expectedMessage = "updates only supported when cluster is Running"
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+		})
+
+		It("disallows switching to a count below the resolved count", func() {
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "no"
+			newGreenplum.Spec.Segments.PrimarySegmentCount = 2
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse())
+			const expectedMessage = "primarySegmentCount cannot be decreased after the cluster has been created"
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+		})
+
+		It("disallows switching before the count is resolved", func() {
+			oldGreenplum.Status.PrimarySegmentCount = 0
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.AutoPrimarySegmentCount = "no"
+			newGreenplum.Spec.Segments.PrimarySegmentCount = 3
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse())
+			const expectedMessage = "autoPrimarySegmentCount cannot be disabled before the operator has resolved primarySegmentCount"
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal(expectedMessage),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+		})
+	})
+
 	It("disallows update requests that update an old greenplum instance", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.Segments.PrimarySegmentCount = 1
@@ -296,6 +398,37 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("Memory reservation cannot be changed after the cluster has been created"))
 	})
 
+	It("allows requests that change resources", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.Resources = corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2G")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4G")},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("disallows requests that change resources to a request greater than its limit", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.Resources = corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := "masterAndStandby resources.requests.cpu 2 must be less than or equal to its limit 1"
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
 	It("disallows requests that change standby", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.MasterAndStandby.Standby = "no"
@@ -311,6 +444,33 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("standby value cannot be changed after the cluster has been created"))
 	})
 
+	It("allows requests that enable autoFailover when there is a standby", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.AutoFailover = true
+		newGreenplum.Spec.MasterAndStandby.FailoverTimeoutSeconds = 60
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("disallows requests that enable autoFailover without a standby", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.MasterAndStandby.Standby = "no"
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.AutoFailover = true
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(`autoFailover requires standby to be set to "yes"`),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(`autoFailover requires standby to be set to "yes"`))
+	})
+
 	It("disallows requests that change hostBasedAuthentication", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.MasterAndStandby.HostBasedAuthentication = "initial value"
@@ -364,6 +524,53 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("workerSelector cannot be changed after the cluster has been created"))
 	})
 
+	It("allows requests that change segments nodeSelector, tolerations and affinity", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.NodeSelector = map[string]string{"disk": "nvme"}
+		newGreenplum.Spec.Segments.Tolerations = []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		}
+		newGreenplum.Spec.Segments.Affinity = &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+					{Weight: 50, Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"nvme"}},
+					}}},
+				},
+			},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("disallows requests that set segments pod affinity putting mirrors on the node of their primaries", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.Affinity = &corev1.Affinity{
+			PodAffinity: &corev1.PodAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+					{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"type": "segment-a"}},
+						TopologyKey:   "kubernetes.io/hostname",
+					},
+				},
+			},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := "segments affinity cannot require segments pods to be on the same node as segment-a pods: the operator keeps them on different nodes"
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
 	It("disallows requests that change masterAndStandby antiAffinity", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.MasterAndStandby.AntiAffinity = "no"
@@ -394,22 +601,102 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("antiAffinity cannot be changed after the cluster has been created"))
 	})
 
-	It("disallows requests that change segments mirrors", func() {
-		oldGreenplum := exampleGreenplum.DeepCopy()
-		oldGreenplum.Spec.Segments.Mirrors = "no"
-		newGreenplum := oldGreenplum.DeepCopy()
-		newGreenplum.Spec.Segments.Mirrors = "yes"
+	When("enabling segments mirrors", func() {
+		var (
+			reactiveClient *reactive.Client
+			oldGreenplum   *greenplumv1.GreenplumCluster
+			newGreenplum   *greenplumv1.GreenplumCluster
+		)
+		createNode := func(name string, modify func(node *corev1.Node)) {
+			node := corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"worker": "gpdb-segments"}},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{
+						{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+					},
+				},
+			}
+			if modify != nil {
+				modify(&node)
+			}
+			Expect(reactiveClient.Create(nil, &node)).To(Succeed())
+		}
+		BeforeEach(func() {
+			reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+			subject.KubeClient = reactiveClient
+			oldGreenplum = exampleGreenplum.DeepCopy()
+			oldGreenplum.Spec.Segments.Mirrors = "no"
+			oldGreenplum.Spec.Segments.WorkerSelector = map[string]string{"worker": "gpdb-segments"}
+			newGreenplum = oldGreenplum.DeepCopy()
+			newGreenplum.Spec.Segments.Mirrors = "yes"
+			createNode("node-1", nil)
+		})
+		When("there are at least 2 schedulable segment nodes", func() {
+			BeforeEach(func() {
+				createNode("node-2", nil)
+			})
+			It("allows the request", func() {
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
 
-		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+				Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+				Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+			})
+			It("disallows the request when the cluster is not Running", func() {
+				oldGreenplum.Status.Phase = greenplumv1.GreenplumClusterPhaseExpanding
 
-		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
-		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
-			"Message": Equal("mirrors cannot be changed after the cluster has been created"),
-		})))
-		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("mirrors cannot be changed after the cluster has been created"))
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+				Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Message": Equal("mirrors can only be enabled when the cluster is Running"),
+				})))
+				Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("mirrors can only be enabled when the cluster is Running"))
+			})
+		})
+		When("there are fewer than 2 schedulable segment nodes", func() {
+			BeforeEach(func() {
+				createNode("cordoned", func(node *corev1.Node) {
+					node.Spec.Unschedulable = true
+				})
+				createNode("tainted", func(node *corev1.Node) {
+					node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "master", Effect: corev1.TaintEffectNoSchedule}}
+				})
+				createNode("not-ready", func(node *corev1.Node) {
+					node.Status.Conditions[0].Status = corev1.ConditionFalse
+				})
+				node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other-worker"}}
+				Expect(reactiveClient.Create(nil, &node)).To(Succeed())
+			})
+			It("disallows the request", func() {
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+				expectedMessage := "enabling mirrors requires at least 2 schedulable nodes for segments, " +
+					"so that each mirror is on a different node than its primary; found 1"
+				Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Message": Equal(expectedMessage),
+				})))
+				Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+			})
+		})
+		When("listing nodes fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("list", "nodes", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("injected error")
+				})
+			})
+			It("disallows the request", func() {
+				outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+				Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+				Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+					"Message": Equal("failed to list nodes for segments: injected error"),
+				})))
+			})
+		})
 	})
 
-	It("disallows requests that change segments mirrors", func() {
+	It("disallows requests that disable segments mirrors", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.Segments.Mirrors = "yes"
 		newGreenplum := oldGreenplum.DeepCopy()
@@ -419,9 +706,9 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 
 		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
 		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
-			"Message": Equal("mirrors cannot be changed after the cluster has been created"),
+			"Message": Equal("mirrors cannot be disabled after the cluster has been created"),
 		})))
-		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("mirrors cannot be changed after the cluster has been created"))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("mirrors cannot be disabled after the cluster has been created"))
 	})
 
 	DescribeTable("allows requests that only change the case of segments mirrors",
@@ -440,7 +727,7 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Entry("NO -> no", "NO", "no"),
 	)
 
-	It("disallows requests that change masterAndStandby storage", func() {
+	It("allows requests that increase masterAndStandby storage", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.MasterAndStandby.Storage = resource.MustParse("10G")
 		newGreenplum := oldGreenplum.DeepCopy()
@@ -448,26 +735,61 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 
 		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
 
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("allows requests that increase segments storage", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Segments.Storage = resource.MustParse("10G")
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.Storage = resource.MustParse("20G")
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("allows requests that express the same storage differently", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Segments.Storage = resource.MustParse("10G")
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Segments.Storage = resource.MustParse("10000M")
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that decrease masterAndStandby storage", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.MasterAndStandby.Storage = resource.MustParse("20G")
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.Storage = resource.MustParse("10G")
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
 		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
 		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
-			"Message": Equal("storage cannot be changed after the cluster has been created"),
+			"Message": Equal("storage cannot be decreased after the cluster has been created"),
 		})))
-		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("storage cannot be changed after the cluster has been created"))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("storage cannot be decreased after the cluster has been created"))
 	})
 
-	It("disallows requests that change segments storage", func() {
+	It("disallows requests that decrease segments storage", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
-		oldGreenplum.Spec.Segments.Storage = resource.MustParse("10G")
+		oldGreenplum.Spec.Segments.Storage = resource.MustParse("20G")
 		newGreenplum := oldGreenplum.DeepCopy()
-		newGreenplum.Spec.Segments.Storage = resource.MustParse("20G")
+		newGreenplum.Spec.Segments.Storage = resource.MustParse("10G")
 
 		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
 
 		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
 		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
-			"Message": Equal("storage cannot be changed after the cluster has been created"),
+			"Message": Equal("storage cannot be decreased after the cluster has been created"),
 		})))
-		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("storage cannot be changed after the cluster has been created"))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("storage cannot be decreased after the cluster has been created"))
 	})
 
 	It("disallows requests that change masterAndStandby storageClassName", func() {
@@ -500,6 +822,715 @@ var _ = Describe("validateUpdateGreenplumCluster", func() {
 		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("storageClassName cannot be changed after the cluster has been created"))
 	})
 
+	DescribeTable("disallows requests that change masterAndStandby gpadminHome",
+		func(oldGpadminHome, newGpadminHome *greenplumv1.GreenplumGpadminHomeSpec) {
+			oldGreenplum := exampleGreenplum.DeepCopy()
+			oldGreenplum.Spec.MasterAndStandby.GpadminHome = oldGpadminHome
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.MasterAndStandby.GpadminHome = newGpadminHome
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal("gpadminHome cannot be changed after the cluster has been created"),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("gpadminHome cannot be changed after the cluster has been created"))
+		},
+		Entry("adding gpadminHome", nil,
+			&greenplumv1.GreenplumGpadminHomeSpec{StorageClassName: "standard", Storage: resource.MustParse("1G")}),
+		Entry("removing gpadminHome",
+			&greenplumv1.GreenplumGpadminHomeSpec{StorageClassName: "standard", Storage: resource.MustParse("1G")}, nil),
+		Entry("changing gpadminHome storage",
+			&greenplumv1.GreenplumGpadminHomeSpec{StorageClassName: "standard", Storage: resource.MustParse("1G")},
+			&greenplumv1.GreenplumGpadminHomeSpec{StorageClassName: "standard", Storage: resource.MustParse("2G")}),
+	)
+
+	DescribeTable("disallows requests that change tempTablespace",
+		func(oldTempTablespace, newTempTablespace *greenplumv1.GreenplumTempTablespaceSpec) {
+			oldGreenplum := exampleGreenplum.DeepCopy()
+			oldGreenplum.Spec.TempTablespace = oldTempTablespace
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.TempTablespace = newTempTablespace
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal("tempTablespace cannot be changed after the cluster has been created"),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("tempTablespace cannot be changed after the cluster has been created"))
+		},
+		Entry("adding tempTablespace", nil,
+			&greenplumv1.GreenplumTempTablespaceSpec{StorageClassName: "local-ssd", Storage: resource.MustParse("100G")}),
+		Entry("removing tempTablespace",
+			&greenplumv1.GreenplumTempTablespaceSpec{StorageClassName: "local-ssd", Storage: resource.MustParse("100G")}, nil),
+		Entry("changing tempTablespace storageClassName",
+			&greenplumv1.GreenplumTempTablespaceSpec{StorageClassName: "local-ssd", Storage: resource.MustParse("100G")},
+			&greenplumv1.GreenplumTempTablespaceSpec{StorageClassName: "standard", Storage: resource.MustParse("100G")}),
+	)
+
+	DescribeTable("disallows requests that change spillVolume",
+		func(oldSpillVolume, newSpillVolume *greenplumv1.GreenplumSpillVolumeSpec) {
+			oldGreenplum := exampleGreenplum.DeepCopy()
+			oldGreenplum.Spec.SpillVolume = oldSpillVolume
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.SpillVolume = newSpillVolume
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("spillVolume cannot be changed after the cluster has been created"))
+		},
+		Entry("adding spillVolume", nil,
+			&greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("50Gi")}),
+		Entry("removing spillVolume",
+			&greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("50Gi")}, nil),
+		Entry("changing spillVolume sizeLimit",
+			&greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("50Gi")},
+			&greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("100Gi")}),
+	)
+
+	DescribeTable("disallows requests that enable or disable interconnectTLS",
+		func(oldInterconnectTLS, newInterconnectTLS *greenplumv1.GreenplumInterconnectTLSSpec) {
+			oldGreenplum := exampleGreenplum.DeepCopy()
+			oldGreenplum.Spec.InterconnectTLS = oldInterconnectTLS
+			newGreenplum := oldGreenplum.DeepCopy()
+			newGreenplum.Spec.InterconnectTLS = newInterconnectTLS
+
+			outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+			Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+			Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Message": Equal("interconnectTLS cannot be enabled or disabled after the cluster has been created"),
+			})))
+			Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("interconnectTLS cannot be enabled or disabled after the cluster has been created"))
+		},
+		Entry("enabling interconnectTLS", nil, &greenplumv1.GreenplumInterconnectTLSSpec{}),
+		Entry("disabling interconnectTLS", &greenplumv1.GreenplumInterconnectTLSSpec{}, nil),
+	)
+
+	It("allows requests that change the interconnect certificate validity", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.InterconnectTLS.CertificateValidity = &metav1.Duration{Duration: 720 * time.Hour}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("disallows requests that change gp_resource_manager", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"gp_workfile_limit_per_query": "10GB"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["gp_resource_manager"] = "queue"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := "config.gucs: gp_resource_manager cannot be changed after the cluster has been created"
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows requests that change gucs applied with a reload", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"gp_workfile_limit_per_query": "10GB"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["gp_workfile_limit_per_query"] = "20GB"
+		newGreenplum.Spec.Config.GUCs["log_min_duration_statement"] = "5s"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows requests that change gp_enable_global_deadlock_detector, which requires a restart", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"gp_enable_global_deadlock_detector": "off"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["gp_enable_global_deadlock_detector"] = "on"
+		newGreenplum.Spec.Config.GUCs["max_connections"] = "500"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows requests that change the optimizer guc", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"gp_workfile_limit_per_query": "10GB"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["optimizer"] = "off"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows requests that change the checkpoint and background writer gucs", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"checkpoint_completion_target": "0.5"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["checkpoint_completion_target"] = "0.9"
+		newGreenplum.Spec.Config.GUCs["bgwriter_delay"] = "100ms"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows requests that change the slice and statement memory gucs", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"statement_mem": "125MB"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["statement_mem"] = "256MB"
+		newGreenplum.Spec.Config.GUCs["max_statement_mem"] = "4GB"
+		newGreenplum.Spec.Config.GUCs["gp_max_slices"] = "50"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("allows requests that change the network timeout gucs", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"gp_segment_connect_timeout": "3min"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["gp_segment_connect_timeout"] = "10min"
+		newGreenplum.Spec.Config.GUCs["gp_interconnect_transmit_timeout"] = "600"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("disallows requests that change the optimizer guc to an invalid value", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"optimizer": "on"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs["optimizer"] = "legacy"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := `config.gucs: invalid value for optimizer: "legacy": must be on or off`
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("disallows requests that change schedulerName", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.SchedulerName = "volcano"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("schedulerName cannot be changed after the cluster has been created"),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("schedulerName cannot be changed after the cluster has been created"))
+	})
+
+	It("allows requests that change sysctls to safe sysctls", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "net.ipv4.tcp_syncookies", Value: "1"}}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("disallows requests that add unsafe sysctls the namespace does not allow", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		Expect(subject.KubeClient.Create(context.Background(), namespace)).To(Succeed())
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "kernel.sem", Value: "500 2048000 200 40960"}}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := "sysctl kernel.sem is unsafe and is not allowed in namespace test-ns; " +
+			"add it to the greenplum.pivotal.io/allowed-unsafe-sysctls annotation of the namespace to allow it"
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows requests that keep unsafe sysctls the namespace no longer allows", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		Expect(subject.KubeClient.Create(context.Background(), namespace)).To(Succeed())
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Sysctls = []corev1.Sysctl{{Name: "kernel.sem", Value: "500 2048000 200 40960"}}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("allows requests that enable tls with a valid Secret", func() {
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{"greenplum.test-ns.svc.cluster.local"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "greenplum-server-tls"},
+			Data:       map[string][]byte{"server.crt": certPEM, "server.key": keyPEM},
+		}
+		Expect(subject.KubeClient.Create(context.Background(), secret)).To(Succeed())
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("disallows requests that enable tls with a Secret that does not exist", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "missing-tls"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(`invalid tls: Secret "missing-tls" not found`))
+	})
+
+	It("allows requests that leave tls unchanged without checking the Secret again", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "deleted-tls"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that disable tls", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.TLS = nil
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("tls cannot be disabled after it has been enabled"))
+	})
+
+	It("disallows requests that enable auth.ldap with an invalid configuration", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Auth = &greenplumv1.GreenplumAuthSpec{LDAP: &greenplumv1.GreenplumLDAPSpec{
+			ServerURL: "ldaps://ldap.example.com",
+			Mode:      "simpleBind",
+		}}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("invalid auth.ldap: serverURL: ldaps is not supported by Greenplum 6; use ldap:// with tls.startTLS"))
+	})
+
+	It("allows requests that disable auth.ldap", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Auth = &greenplumv1.GreenplumAuthSpec{LDAP: &greenplumv1.GreenplumLDAPSpec{
+			ServerURL:      "ldap://ldap.example.com",
+			Mode:           "simpleBind",
+			BindDNTemplate: "uid=$username,ou=people,dc=example,dc=com",
+		}}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Auth = nil
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("allows requests that add a valid caBundle", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "corporate-ca"},
+			Data:       map[string]string{"ca.crt": string(cabundletesting.SelfSignedCAPEM("corporate-root-ca"))},
+		}
+		Expect(subject.KubeClient.Create(context.Background(), configMap)).To(Succeed())
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainAllowedEntry())
+	})
+
+	It("disallows requests that change the caBundle to a ConfigMap that does not exist", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "missing-ca"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := `invalid caBundle: ConfigMap "missing-ca" not found`
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows requests that keep a caBundle whose ConfigMap has since been removed", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that change the entrypointScript to a key that does not hold a script", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-entrypoint"},
+			Data:       map[string]string{"entrypoint.sh": "mount-extra-storage\n"},
+		}
+		Expect(subject.KubeClient.Create(context.Background(), configMap)).To(Succeed())
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := `invalid entrypointScript: ConfigMap "my-entrypoint" key "entrypoint.sh": the script must start with a #! line, e.g. #!/bin/bash`
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows requests that keep an entrypointScript whose ConfigMap has since been removed", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that enable monitoring with customQueries in a ConfigMap that does not exist", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+			Prometheus: &greenplumv1.GreenplumPrometheusSpec{
+				Enabled:       true,
+				CustomQueries: &greenplumv1.GreenplumCustomQueriesSpec{ConfigMapName: "my-queries"},
+			},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := `invalid monitoring.prometheus.customQueries: ConfigMap "my-queries" not found`
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows requests that enable monitoring without customQueries", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+			Prometheus: &greenplumv1.GreenplumPrometheusSpec{Enabled: true, Segments: &greenplumv1.GreenplumPrometheusSegmentsSpec{Primary: true}},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that set negative perfCheck thresholds", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+			PerfCheck: &greenplumv1.GreenplumPerfCheckSpec{MinDiskReadMBps: 300, MinNetworkMBps: -5},
+		}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := `invalid monitoring.perfCheck: invalid minNetworkMBps value: "-5": must be greater than 0`
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("disallows requests that change config dataDirectoryUmask", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.DataDirectoryUmask = "0077"
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.DataDirectoryUmask = "0027"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.dataDirectoryUmask cannot be changed after the cluster has been created"),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("config.dataDirectoryUmask cannot be changed after the cluster has been created"))
+	})
+
+	It("disallows requests that change config arrayName", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.ArrayName = "Analytics Warehouse"
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.ArrayName = "Reporting Warehouse"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.arrayName cannot be changed after the cluster has been created"),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("config.arrayName cannot be changed after the cluster has been created"))
+	})
+
+	It("disallows requests that enable config pgStatStatements", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgStatStatements = "yes"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.pgStatStatements cannot be changed after the cluster has been created"),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("config.pgStatStatements cannot be changed after the cluster has been created"))
+	})
+
+	It("disallows requests that disable config pgStatStatements", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.PgStatStatements = "yes"
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgStatStatements = "no"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.pgStatStatements cannot be changed after the cluster has been created"),
+		})))
+	})
+
+	It("allows requests that only change the case or default of config pgStatStatements", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgStatStatements = "NO"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that change config roleSettings", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.RoleSettings = map[string]map[string]string{"gpadmin": {"search_path": "public"}}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.RoleSettings["gpadmin"]["search_path"] = "sales, public"
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.roleSettings cannot be changed after the cluster has been created"),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("config.roleSettings cannot be changed after the cluster has been created"))
+	})
+
+	It("disallows requests that change config resourceQueues", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.GUCs = map[string]string{"gp_resource_manager": "queue"}
+		oldGreenplum.Spec.Config.ResourceQueues = []greenplumv1.GreenplumResourceQueueSpec{{Name: "etl", ActiveStatements: 2}}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.ResourceQueues[0].ActiveStatements = 4
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.resourceQueues cannot be changed after the cluster has been created"),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("config.resourceQueues cannot be changed after the cluster has been created"))
+	})
+
+	It("allows requests that add config extensions", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.Extensions = []string{"pgcrypto"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.Extensions = []string{"pgcrypto", "hstore"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that add a disallowed extension", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.Extensions = []string{"plpythonu"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": HavePrefix(`config.extensions: extension "plpythonu" is not allowed`),
+		})))
+	})
+
+	It("allows requests that change config pgHbaEntries", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.PgHbaEntries = []string{"host all app_user 10.0.0.0/16 md5"}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgHbaEntries = []string{"hostssl all app_user 10.0.0.0/16 cert"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that add an insecure pgHbaEntry", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.PgHbaEntries = []string{"host all all 10.0.0.0/8 trust"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry("config.pgHbaEntries[0]: trust is only allowed from loopback addresses unless allowInsecureAuth is set"))
+	})
+
+	It("allows requests that change config roleConnectionLimits", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": 10}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": 20, "etl_user": 5}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+		Expect(outputReview.Response.Result).To(BeNil())
+	})
+
+	It("disallows requests that set an invalid role connection limit", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.Config.RoleConnectionLimits = map[string]int32{"analyst": -5}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal("config.roleConnectionLimits: invalid limit for analyst: -5: must be -1 for no limit, or 0 or more"),
+		})))
+	})
+
+	It("allows requests that change adminPasswordRotationInterval", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.AdminPasswordRotationInterval = &metav1.Duration{Duration: 24 * time.Hour}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that set adminPasswordRotationInterval shorter than an hour", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.MasterAndStandby.AdminPasswordRotationInterval = &metav1.Duration{Duration: time.Minute}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := `invalid masterAndStandby adminPasswordRotationInterval value: "1m0s": must be at least 1h0m0s`
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(outputReview.Response.Result).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Message": Equal(expectedMessage),
+		})))
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows requests that make the cluster a disaster recovery standby", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{PrimaryNamespace: "primary-ns"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
+	It("disallows requests that make the cluster a disaster recovery standby of itself", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{PrimaryNamespace: "test-ns"}
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := "disasterRecovery primaryNamespace must be a different namespace than the GreenplumCluster"
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("disallows requests that unset disasterRecovery promote", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{PrimaryNamespace: "primary-ns", Promote: true}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.DisasterRecovery.Promote = false
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		expectedMessage := "disasterRecovery promote cannot be unset after the cluster has been promoted"
+		Expect(outputReview.Response.Allowed).To(BeFalse(), "should not be allowed")
+		Expect(DecodeLogs(logBuf)).To(ContainDisallowedEntry(expectedMessage))
+	})
+
+	It("allows a promoted standby to stop being a standby", func() {
+		oldGreenplum := exampleGreenplum.DeepCopy()
+		oldGreenplum.Spec.DisasterRecovery = &greenplumv1.GreenplumDisasterRecoverySpec{PrimaryNamespace: "primary-ns", Promote: true}
+		newGreenplum := oldGreenplum.DeepCopy()
+		newGreenplum.Spec.DisasterRecovery = nil
+
+		outputReview := postValidateReview(subject.Handler(), newGreenplum, oldGreenplum)
+
+		Expect(outputReview.Response.Allowed).To(BeTrue(), "should be allowed")
+	})
+
 	It("disallows requests that change pxf serviceName", func() {
 		oldGreenplum := exampleGreenplum.DeepCopy()
 		oldGreenplum.Spec.PXF.ServiceName = "foo"
@@ -19,8 +19,9 @@ import (
 )
 
 const (
-	WebhookConfigName = "greenplum-validating-webhook-config"
-	ServiceName       = "greenplum-validating-webhook-service"
+	WebhookConfigName         = "greenplum-validating-webhook-config"
+	MutatingWebhookConfigName = "greenplum-mutating-webhook-config"
+	ServiceName               = "greenplum-validating-webhook-service"
 )
 
 type ValidatingWebhook interface {
@@ -54,6 +55,12 @@ func (w *Webhook) Run(ctx context.Context) error {
 		return fmt.Errorf("creating ValidatingWebhookConfiguration: %w", err)
 	}
 
+	err = w.ReconcileMutatingWebhookConfiguration(context.Background(), signedCertPEM)
+	if err != nil {
+		Log.Error(err, "Error creating MutatingWebhookConfiguration")
+		return fmt.Errorf("creating MutatingWebhookConfiguration: %w", err)
+	}
+
 	err = w.Server.Start(ctx.Done(), *signedCertX509, ":https", w.Handler)
 	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("validating admission webhook server start failed: %w", err)
@@ -160,6 +167,83 @@ func (w *Webhook) ModifyWebhookConfiguration(webhookConfig *admissionregistratio
 						Resources:   []string{"greenplumpxfservices"},
 					},
 				},
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{"greenplum.pivotal.io"},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"greenplumbackupschedules"},
+					},
+				},
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{"greenplum.pivotal.io"},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"greenplumbackups"},
+					},
+				},
+			},
+			FailurePolicy:           &fail,
+			SideEffects:             &sideEffectClassNone,
+			AdmissionReviewVersions: []string{"v1beta1"},
+		},
+	}
+}
+
+// ReconcileMutatingWebhookConfiguration registers the /mutate endpoint, which merges the
+// GreenplumClusterDefaults into new GreenplumClusters. It is served by the same Service as
+// the ValidatingWebhookConfiguration, so it must be reconciled after that one.
+func (w *Webhook) ReconcileMutatingWebhookConfiguration(ctx context.Context, signedCert []byte) error {
+	webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: MutatingWebhookConfigName,
+		},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, w.KubeClient, webhookConfig, func() error {
+		w.ModifyMutatingWebhookConfiguration(webhookConfig, signedCert)
+		if err := controllerutil.SetControllerReference(w.WebhookCfgOwner, webhookConfig, scheme.Scheme); err != nil {
+			return errors.Wrap(err, "couldn't set OwnerReferences on MutatingWebhookConfig")
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create MutatingWebhookConfiguration")
+	}
+	if result != controllerutil.OperationResultNone {
+		Log.Info("MutatingWebhookConfiguration: " + string(result))
+	}
+	return nil
+}
+
+func (w *Webhook) ModifyMutatingWebhookConfiguration(webhookConfig *admissionregistrationv1.MutatingWebhookConfiguration, signedCertBundle []byte) {
+	fail := admissionregistrationv1.Fail
+	sideEffectClassNone := admissionregistrationv1.SideEffectClassNone
+
+	if webhookConfig.Labels == nil {
+		webhookConfig.Labels = make(map[string]string)
+	}
+	webhookConfig.Labels["app"] = "greenplum-operator"
+	webhookConfig.Webhooks = []admissionregistrationv1.MutatingWebhook{
+		{
+			Name: "defaults.greenplum.pivotal.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: w.Namespace,
+					Name:      ServiceName + w.NameSuffix,
+					Path:      heapvalue.NewString("/mutate"),
+				},
+				CABundle: signedCertBundle,
+			},
+			Rules: []admissionregistrationv1.RuleWithOperations{
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{"greenplum.pivotal.io"},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"greenplumclusters"},
+					},
+				},
 			},
 			FailurePolicy:           &fail,
 			SideEffects:             &sideEffectClassNone,
@@ -126,6 +126,28 @@ var _ = Describe("Validating Admission Webhook", func() {
 				cancel()
 				Eventually(logBuf).Should(gbytes.Say("shutting down greenplum validating admission webhook server"))
 			})
+
+			It("Creates mutatingwebhookconfiguration", func() {
+				Expect(subject.Run(nil)).To(Succeed())
+				var webhookConfig admissionregistrationv1.MutatingWebhookConfiguration
+				webhookKey := types.NamespacedName{Name: admission.MutatingWebhookConfigName}
+				Expect(reactiveClient.Get(nil, webhookKey, &webhookConfig)).To(Succeed())
+				Expect(webhookConfig.Webhooks[0].ClientConfig.CABundle).To(Equal(cg.waitStub.returnedCert))
+			})
+		})
+
+		When("ReconcileMutatingWebhookConfiguration fails", func() {
+			BeforeEach(func() {
+				reactiveClient.PrependReactor("create", "mutatingwebhookconfigurations", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+					return true, nil, errors.New("intentional failure")
+				})
+			})
+			It("returns an error", func() {
+				err := subject.Run(nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(MatchRegexp(`creating MutatingWebhookConfiguration: [^"]*: intentional failure`))
+				Expect(logBuf).NotTo(gbytes.Say("shutting down greenplum validating admission webhook server"))
+			})
 		})
 
 		When("GenerateAndSignTLSCertificate fails", func() {
@@ -374,7 +396,7 @@ var _ = Describe("Validating Admission Webhook", func() {
 			Expect(validatingWebhook.ClientConfig.Service.Namespace).To(Equal("test-ns"))
 			Expect(*validatingWebhook.ClientConfig.Service.Path).To(Equal("/validate"))
 			Expect(validatingWebhook.ClientConfig.CABundle).To(Equal(certBytes))
-			Expect(validatingWebhook.Rules).To(HaveLen(2))
+			Expect(validatingWebhook.Rules).To(HaveLen(4))
 			Expect(validatingWebhook.Rules[0].Operations).To(Equal([]admissionregistrationv1.OperationType{"CREATE", "UPDATE"}))
 			Expect(validatingWebhook.Rules[0].APIGroups[0]).To(Equal("greenplum.pivotal.io"))
 			Expect(validatingWebhook.Rules[0].APIVersions[0]).To(Equal("v1"))
@@ -383,6 +405,14 @@ var _ = Describe("Validating Admission Webhook", func() {
 			Expect(validatingWebhook.Rules[1].APIGroups[0]).To(Equal("greenplum.pivotal.io"))
 			Expect(validatingWebhook.Rules[1].APIVersions[0]).To(Equal("v1beta1"))
 			Expect(validatingWebhook.Rules[1].Resources[0]).To(Equal("greenplumpxfservices"))
+			Expect(validatingWebhook.Rules[2].Operations).To(Equal([]admissionregistrationv1.OperationType{"CREATE", "UPDATE"}))
+			Expect(validatingWebhook.Rules[2].APIGroups[0]).To(Equal("greenplum.pivotal.io"))
+			Expect(validatingWebhook.Rules[2].APIVersions[0]).To(Equal("v1"))
+			Expect(validatingWebhook.Rules[2].Resources[0]).To(Equal("greenplumbackupschedules"))
+			Expect(validatingWebhook.Rules[3].Operations).To(Equal([]admissionregistrationv1.OperationType{"CREATE", "UPDATE"}))
+			Expect(validatingWebhook.Rules[3].APIGroups[0]).To(Equal("greenplum.pivotal.io"))
+			Expect(validatingWebhook.Rules[3].APIVersions[0]).To(Equal("v1"))
+			Expect(validatingWebhook.Rules[3].Resources[0]).To(Equal("greenplumbackups"))
 			Expect(*validatingWebhook.FailurePolicy).To(Equal(admissionregistrationv1.Fail))
 		})
 
@@ -405,6 +435,72 @@ var _ = Describe("Validating Admission Webhook", func() {
 		})
 	})
 
+	Describe("ReconcileMutatingWebhookConfiguration", func() {
+		When("all is good", func() {
+			It("creates a MutatingWebhookConfiguration", func() {
+				Expect(subject.ReconcileMutatingWebhookConfiguration(nil, []byte("signed cert"))).To(Succeed())
+				var webhookConfig admissionregistrationv1.MutatingWebhookConfiguration
+				webhookKey := types.NamespacedName{Name: admission.MutatingWebhookConfigName}
+				Expect(reactiveClient.Get(nil, webhookKey, &webhookConfig)).To(Succeed())
+				Expect(webhookConfig.Webhooks[0].ClientConfig.CABundle).To(Equal([]byte("signed cert")))
+				Expect(metav1.IsControlledBy(&webhookConfig, fakeOwnerCRD)).To(BeTrue())
+			})
+		})
+
+		When("the MutatingWebhookConfiguration already exists", func() {
+			BeforeEach(func() {
+				Expect(subject.ReconcileMutatingWebhookConfiguration(nil, []byte("old cert"))).To(Succeed())
+			})
+			It("updates the caBundle and service name", func() {
+				subject.NameSuffix = "-new"
+				Expect(subject.ReconcileMutatingWebhookConfiguration(nil, []byte("new cert"))).To(Succeed())
+				var webhookConfig admissionregistrationv1.MutatingWebhookConfiguration
+				webhookKey := types.NamespacedName{Name: admission.MutatingWebhookConfigName}
+				Expect(reactiveClient.Get(nil, webhookKey, &webhookConfig)).To(Succeed())
+				Expect(webhookConfig.Webhooks[0].ClientConfig.CABundle).To(Equal([]byte("new cert")))
+				Expect(webhookConfig.Webhooks[0].ClientConfig.Service.Name).To(Equal(admission.ServiceName + "-new"))
+			})
+		})
+
+		When("setting the mutatingwebhookconfiguration owner reference fails", func() {
+			BeforeEach(func() {
+				subject.WebhookCfgOwner = nil
+			})
+			It("returns an error", func() {
+				err := subject.ReconcileMutatingWebhookConfiguration(nil, []byte("signed cert"))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(MatchRegexp("couldn't set OwnerReferences on MutatingWebhookConfig.*"))
+			})
+		})
+	})
+
+	Describe("ModifyMutatingWebhookConfiguration", func() {
+		It("returns valid WebhookConfiguration", func() {
+			certBytes := []byte("some cert bytes")
+			mutatingWebhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: admission.MutatingWebhookConfigName,
+				},
+			}
+			subject.ModifyMutatingWebhookConfiguration(mutatingWebhookConfig, certBytes)
+
+			Expect(mutatingWebhookConfig.Labels).To(Equal(map[string]string{"app": "greenplum-operator"}))
+			Expect(mutatingWebhookConfig.Webhooks).To(HaveLen(1))
+			mutatingWebhook := mutatingWebhookConfig.Webhooks[0]
+			Expect(mutatingWebhook.Name).To(Equal("defaults.greenplum.pivotal.io"))
+			Expect(mutatingWebhook.ClientConfig.Service.Name).To(Equal(serviceName))
+			Expect(mutatingWebhook.ClientConfig.Service.Namespace).To(Equal("test-ns"))
+			Expect(*mutatingWebhook.ClientConfig.Service.Path).To(Equal("/mutate"))
+			Expect(mutatingWebhook.ClientConfig.CABundle).To(Equal(certBytes))
+			Expect(mutatingWebhook.Rules).To(HaveLen(1))
+			Expect(mutatingWebhook.Rules[0].Operations).To(Equal([]admissionregistrationv1.OperationType{"CREATE"}))
+			Expect(mutatingWebhook.Rules[0].APIGroups[0]).To(Equal("greenplum.pivotal.io"))
+			Expect(mutatingWebhook.Rules[0].APIVersions[0]).To(Equal("v1"))
+			Expect(mutatingWebhook.Rules[0].Resources[0]).To(Equal("greenplumclusters"))
+			Expect(*mutatingWebhook.FailurePolicy).To(Equal(admissionregistrationv1.Fail))
+		})
+	})
+
 	Describe("CreateSVCForValidatingWebhookConfiguration", func() {
 		It("returns a valid svc configuration for validating webhook", func() {
 			subject.Namespace = "another-namespace"
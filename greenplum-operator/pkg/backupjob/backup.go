@@ -0,0 +1,55 @@
+package backupjob
+
+import (
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// GenerateGreenplumBackupJob returns a job that runs gpbackup on the master at hostname for a GreenplumBackup. A backup
+// to S3 is written with gpbackup_s3_plugin, with the S3 credentials passed from their Secret in the environment;
+// gpbackup_job.sh adds them to the plugin configuration. A backup to backupDir is written there by gpbackup itself
+func GenerateGreenplumBackupJob(image, hostname string, spec greenplumv1.GreenplumBackupSpec) batchv1.Job {
+	job := GenerateBackupJob(image, hostname, BackupSpecOptions(spec))
+	if spec.S3 != nil {
+		backupContainer := &job.Spec.Template.Spec.Containers[0]
+		backupContainer.Env = append(backupContainer.Env, S3CredentialsEnv(spec.S3.Credentials)...)
+	}
+	return job
+}
+
+// BackupSpecOptions are the gpbackup options for a GreenplumBackup: the options of its spec, with metadataOnly
+// and dataOnly set by the mode, and the plugin set to write to the S3 destination when there is one
+func BackupSpecOptions(spec greenplumv1.GreenplumBackupSpec) greenplumv1.GreenplumBackupOptions {
+	options := *spec.GreenplumBackupOptions.DeepCopy()
+	options.MetadataOnly = spec.Mode == greenplumv1.GreenplumBackupModeMetadataOnly
+	options.DataOnly = spec.Mode == greenplumv1.GreenplumBackupModeDataOnly
+	if spec.S3 != nil {
+		options.Plugin = S3PluginSpec(*spec.S3)
+	}
+	return options
+}
+
+// ValidateBackupSpec checks the destination of a GreenplumBackup, and its gpbackup options as they are rendered for it
+func ValidateBackupSpec(spec greenplumv1.GreenplumBackupSpec) error {
+	if spec.S3 == nil && spec.BackupDir == "" {
+		return fmt.Errorf("one of s3 and backupDir must be set")
+	}
+	if spec.S3 != nil && spec.BackupDir != "" {
+		return fmt.Errorf("s3 and backupDir cannot be used together")
+	}
+	if spec.Retention != nil && spec.S3 == nil {
+		return fmt.Errorf("retention requires s3")
+	}
+	if spec.Plugin != nil {
+		return fmt.Errorf("plugin cannot be set: a backup to s3 is written with gpbackup_s3_plugin")
+	}
+	if spec.MetadataOnly {
+		return fmt.Errorf("metadataOnly cannot be set: use mode metadata-only")
+	}
+	if spec.DataOnly {
+		return fmt.Errorf("dataOnly cannot be set: use mode data-only")
+	}
+	return ValidateBackupOptions(BackupSpecOptions(spec))
+}
@@ -0,0 +1,353 @@
+package backupjob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("GenerateGreenplumBackupJob", func() {
+	var spec greenplumv1.GreenplumBackupSpec
+	BeforeEach(func() {
+		spec = greenplumv1.GreenplumBackupSpec{
+			ClusterName: "my-greenplum",
+			Mode:        greenplumv1.GreenplumBackupModeFull,
+			S3: &greenplumv1.GreenplumBackupS3Spec{
+				Bucket:      "backups",
+				Prefix:      "greenplum/prod",
+				Region:      "us-east-1",
+				Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+			},
+		}
+	})
+
+	It("backs up to S3 with gpbackup_s3_plugin", func() {
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		backupContainer := job.Spec.Template.Spec.Containers[0]
+		Expect(backupContainer.Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+		Expect(backupContainer.Env).To(ContainElement(corev1.EnvVar{
+			Name: "GPBACKUP_PLUGIN_CONFIG",
+			Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+				"options:\n" +
+				"  bucket: backups\n" +
+				"  folder: greenplum/prod\n" +
+				"  region: us-east-1\n",
+		}))
+	})
+
+	It("writes a single data file per segment to S3 when requested", func() {
+		spec.SingleDataFile = true
+		spec.CopyQueueSize = 8
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--single-data-file",
+			"--copy-queue-size", "8",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("backs up each leaf partition separately when requested", func() {
+		spec.LeafPartitionData = true
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--leaf-partition-data",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("compresses the data files at the requested level", func() {
+		spec.CompressionLevel = 6
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--compression-level", "6",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("leaves the data files uncompressed when requested", func() {
+		spec.NoCompression = true
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--no-compression",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("backs up the optimizer statistics when requested", func() {
+		spec.WithStats = true
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--with-stats",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("backs up only the schemas of a tenant when requested", func() {
+		spec.IncludeSchemas = []string{"tenant_a", "tenant_b"}
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--include-schema", "tenant_a",
+			"--include-schema", "tenant_b",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("leaves out the excluded schemas when requested", func() {
+		spec.ExcludeSchemas = []string{"scratch"}
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--exclude-schema", "scratch",
+			"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+		}))
+	})
+
+	It("encrypts the backup in S3 with the key from the Secret", func() {
+		spec.S3.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key", Key: "aes.key"}
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+			Name: "GPBACKUP_PLUGIN_CONFIG",
+			Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+				"options:\n" +
+				"  bucket: backups\n" +
+				"  encryption_mode: server-side\n" +
+				"  folder: greenplum/prod\n" +
+				"  region: us-east-1\n",
+		}))
+		backupPod := job.Spec.Template.Spec
+		Expect(backupPod.Volumes).To(ContainElement(encryptionKeyVolume(*spec.S3.Encryption)))
+		Expect(backupPod.Volumes).To(ContainElement(HaveField("Secret.Items", Equal([]corev1.KeyToPath{{Key: "aes.key", Path: "encryption.key"}}))))
+		Expect(backupPod.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      "encryption-key",
+			MountPath: "/etc/gpbackup-encryption",
+			ReadOnly:  true,
+		}))
+	})
+
+	It("passes the credentials from the Secret", func() {
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElements(
+			secretEnvVar("GPBACKUP_S3_ACCESS_KEY_ID", "s3-creds", "aws_access_key_id"),
+			secretEnvVar("GPBACKUP_S3_SECRET_ACCESS_KEY", "s3-creds", "aws_secret_access_key"),
+		))
+	})
+
+	It("reads the credentials from the given keys of the Secret", func() {
+		spec.S3.Credentials.AccessKeyIDKey = "id"
+		spec.S3.Credentials.SecretAccessKeyKey = "secret"
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElements(
+			secretEnvVar("GPBACKUP_S3_ACCESS_KEY_ID", "s3-creds", "id"),
+			secretEnvVar("GPBACKUP_S3_SECRET_ACCESS_KEY", "s3-creds", "secret"),
+		))
+	})
+
+	It("writes to the backup directory without S3 credentials", func() {
+		spec.S3 = nil
+		spec.BackupDir = "/backups"
+		job := GenerateGreenplumBackupJob("greenplum-for-kubernetes:magic", "master-0", spec)
+		backupContainer := job.Spec.Template.Spec.Containers[0]
+		Expect(backupContainer.Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--backup-dir", "/backups",
+		}))
+		for _, env := range backupContainer.Env {
+			Expect(env.Name).NotTo(HavePrefix("GPBACKUP_S3_"))
+			Expect(env.Name).NotTo(Equal("GPBACKUP_PLUGIN_CONFIG"))
+		}
+	})
+})
+
+var _ = Describe("BackupSpecOptions", func() {
+	It("passes the endpoint to the plugin when one is given", func() {
+		options := BackupSpecOptions(greenplumv1.GreenplumBackupSpec{
+			S3: &greenplumv1.GreenplumBackupS3Spec{Endpoint: "https://minio.example.com"},
+		})
+		Expect(options.Plugin.Options).To(HaveKeyWithValue("endpoint", "https://minio.example.com"))
+	})
+
+	It("does not pass an endpoint by default", func() {
+		options := BackupSpecOptions(greenplumv1.GreenplumBackupSpec{S3: &greenplumv1.GreenplumBackupS3Spec{}})
+		Expect(options.Plugin.Options).NotTo(HaveKey("endpoint"))
+	})
+
+	It("does not use a plugin for a backup to a backup directory", func() {
+		options := BackupSpecOptions(greenplumv1.GreenplumBackupSpec{
+			GreenplumBackupOptions: greenplumv1.GreenplumBackupOptions{BackupDir: "/backups"},
+		})
+		Expect(options.Plugin).To(BeNil())
+		Expect(options.BackupDir).To(Equal("/backups"))
+	})
+
+	It("backs up the requested database", func() {
+		options := BackupSpecOptions(greenplumv1.GreenplumBackupSpec{
+			GreenplumBackupOptions: greenplumv1.GreenplumBackupOptions{Database: "sales"},
+		})
+		Expect(options.Database).To(Equal("sales"))
+	})
+
+	It("backs up only the schema in metadata-only mode", func() {
+		Expect(BackupSpecOptions(greenplumv1.GreenplumBackupSpec{Mode: greenplumv1.GreenplumBackupModeMetadataOnly}).MetadataOnly).To(BeTrue())
+		Expect(BackupSpecOptions(greenplumv1.GreenplumBackupSpec{Mode: greenplumv1.GreenplumBackupModeFull}).MetadataOnly).To(BeFalse())
+	})
+
+	It("backs up only the table data in data-only mode", func() {
+		options := BackupSpecOptions(greenplumv1.GreenplumBackupSpec{Mode: greenplumv1.GreenplumBackupModeDataOnly})
+		Expect(options.DataOnly).To(BeTrue())
+		Expect(options.MetadataOnly).To(BeFalse())
+		Expect(BackupSpecOptions(greenplumv1.GreenplumBackupSpec{Mode: greenplumv1.GreenplumBackupModeFull}).DataOnly).To(BeFalse())
+	})
+
+	It("passes the other gpbackup options of the spec through", func() {
+		spec := greenplumv1.GreenplumBackupSpec{
+			S3: &greenplumv1.GreenplumBackupS3Spec{Bucket: "backups"},
+			GreenplumBackupOptions: greenplumv1.GreenplumBackupOptions{
+				Database:         "sales",
+				ExcludeTables:    []string{"public.scratch"},
+				SingleDataFile:   true,
+				CopyQueueSize:    4,
+				CompressionLevel: 3,
+				WithStats:        true,
+				PluginImage:      "registry.example.com/gpbackup:1.0",
+				Verify:           true,
+				RestoreJobs:      2,
+				CABundle:         &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "s3-ca"},
+			},
+		}
+		options := BackupSpecOptions(spec)
+		Expect(options.Plugin).NotTo(BeNil())
+		options.Plugin = nil
+		Expect(options).To(Equal(spec.GreenplumBackupOptions))
+	})
+})
+
+var _ = Describe("ValidateBackupSpec", func() {
+	var spec greenplumv1.GreenplumBackupSpec
+	BeforeEach(func() {
+		spec = greenplumv1.GreenplumBackupSpec{
+			ClusterName: "my-greenplum",
+			Mode:        greenplumv1.GreenplumBackupModeFull,
+			S3: &greenplumv1.GreenplumBackupS3Spec{
+				Bucket:      "backups",
+				Prefix:      "greenplum/prod",
+				Region:      "us-east-1",
+				Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+			},
+		}
+	})
+
+	It("accepts a spec without options", func() {
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+	})
+
+	It("validates the gpbackup options", func() {
+		spec.CopyQueueSize = 4
+		Expect(ValidateBackupSpec(spec)).To(MatchError("copyQueueSize requires singleDataFile"))
+	})
+
+	It("validates the range of the copy queue size", func() {
+		spec.SingleDataFile = true
+		spec.CopyQueueSize = 1001
+		Expect(ValidateBackupSpec(spec)).To(MatchError("invalid copyQueueSize 1001: must be between 1 and 1000"))
+	})
+
+	It("validates the options against the mode", func() {
+		spec.Mode = greenplumv1.GreenplumBackupModeMetadataOnly
+		spec.LeafPartitionData = true
+		Expect(ValidateBackupSpec(spec)).To(MatchError("metadataOnly and leafPartitionData cannot be used together"))
+	})
+
+	It("validates the schema names", func() {
+		spec.IncludeSchemas = []string{"tenant.a"}
+		Expect(ValidateBackupSpec(spec)).To(MatchError(`invalid includeSchemas entry "tenant.a": must be a schema name of at most 63 bytes, without dots or whitespace`))
+	})
+
+	It("rejects included and excluded schemas together", func() {
+		spec.IncludeSchemas = []string{"tenant_a"}
+		spec.ExcludeSchemas = []string{"scratch"}
+		Expect(ValidateBackupSpec(spec)).To(MatchError("includeSchemas and excludeSchemas cannot be used together"))
+	})
+
+	It("validates the encryption of the S3 destination", func() {
+		spec.S3.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "sideways", SecretName: "backup-key"}
+		Expect(ValidateBackupSpec(spec)).To(MatchError(`invalid encryption mode "sideways": must be server-side or client-side`))
+	})
+
+	It("rejects a compression level together with noCompression", func() {
+		spec.CompressionLevel = 6
+		spec.NoCompression = true
+		Expect(ValidateBackupSpec(spec)).To(MatchError("compressionLevel and noCompression cannot be used together"))
+	})
+
+	It("validates the range of the compression level", func() {
+		spec.CompressionLevel = 10
+		Expect(ValidateBackupSpec(spec)).To(MatchError("invalid compressionLevel 10: must be between 1 and 9"))
+	})
+
+	It("rejects a plugin, which the S3 destination sets", func() {
+		spec.Plugin = &greenplumv1.GreenplumBackupPluginSpec{ExecutablePath: "/usr/local/bin/my_plugin"}
+		Expect(ValidateBackupSpec(spec)).To(MatchError("plugin cannot be set: a backup to s3 is written with gpbackup_s3_plugin"))
+	})
+
+	It("rejects metadataOnly, which the mode sets", func() {
+		spec.MetadataOnly = true
+		Expect(ValidateBackupSpec(spec)).To(MatchError("metadataOnly cannot be set: use mode metadata-only"))
+	})
+
+	It("rejects dataOnly, which the mode sets", func() {
+		spec.DataOnly = true
+		Expect(ValidateBackupSpec(spec)).To(MatchError("dataOnly cannot be set: use mode data-only"))
+	})
+
+	It("validates the options against data-only mode", func() {
+		spec.Mode = greenplumv1.GreenplumBackupModeDataOnly
+		Expect(ValidateBackupSpec(spec)).To(Succeed())
+		spec.WithStats = true
+		Expect(ValidateBackupSpec(spec)).To(MatchError("dataOnly and withStats cannot be used together"))
+	})
+
+	It("rejects a backup directory together with S3", func() {
+		spec.BackupDir = "/backups"
+		Expect(ValidateBackupSpec(spec)).To(MatchError("s3 and backupDir cannot be used together"))
+	})
+
+	It("requires a destination", func() {
+		spec.S3 = nil
+		Expect(ValidateBackupSpec(spec)).To(MatchError("one of s3 and backupDir must be set"))
+	})
+
+	When("the backup is written to a backup directory", func() {
+		BeforeEach(func() {
+			spec.S3 = nil
+			spec.BackupDir = "/backups"
+		})
+
+		It("accepts it", func() {
+			Expect(ValidateBackupSpec(spec)).To(Succeed())
+		})
+
+		It("rejects a retention policy, which only prunes S3 folders", func() {
+			maxCount := int32(3)
+			spec.Retention = &greenplumv1.GreenplumBackupRetentionSpec{MaxCount: &maxCount}
+			Expect(ValidateBackupSpec(spec)).To(MatchError("retention requires s3"))
+		})
+	})
+})
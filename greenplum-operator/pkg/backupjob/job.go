@@ -0,0 +1,487 @@
+package backupjob
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultDatabase       = "gpadmin"
+	defaultVerifyDatabase = "gpbackup_verify"
+	maxCopyQueueSize      = 1000
+	maxCompressionLevel   = 9
+	maxRestoreJobs        = 64
+	backupDirVolumeName   = "backup-dir"
+	maxSchemaNameLength   = 63
+
+	// PluginConfigPath is where gpbackup_job.sh writes the plugin configuration on the master, and where
+	// gpbackup copies it to on the segment hosts. It is removed from the master once the job finishes
+	PluginConfigPath = "/tmp/gpbackup_plugin_config.yaml"
+
+	// EncryptionKeyMountPath is where the encryption key is mounted in the backup and restore jobs. The job
+	// scripts add the key, base64-encoded, to the plugin configuration as the encryption_key option
+	EncryptionKeyMountPath = "/etc/gpbackup-encryption/encryption.key"
+
+	DefaultEncryptionKey     = "encryption.key"
+	encryptionKeyVolumeName  = "encryption-key"
+	encryptionKeyFileName    = "encryption.key"
+	encryptionKeyLength      = 32
+	encryptionModeServerSide = "server-side"
+	encryptionModeClientSide = "client-side"
+	encryptionModeOptionName = "encryption_mode"
+	encryptionKeyOptionName  = "encryption_key"
+)
+
+// plugin option names become YAML keys in the plugin configuration
+var pluginOptionNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// gpbackup expects table filters to be schema-qualified
+var tableFilterRegexp = regexp.MustCompile(`^[^.\s]+\.[^.\s]+$`)
+
+// schema filters are plain schema names, matched by gpbackup as given
+var schemaFilterRegexp = regexp.MustCompile(`^[^.\s]+$`)
+
+// The backup job drops the verify database before and after restoring into it, so it must be one the operator owns:
+// the default, or the default followed by an underscore and a suffix. No system database or other database matches
+var verifyDatabaseRegexp = regexp.MustCompile(`^` + defaultVerifyDatabase + `(?:_[a-z0-9_]+)?$`)
+
+// imageRefRegexp follows the docker reference grammar: [registry[:port]/]repository[:tag][@digest]
+var imageRefRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9]+(?:[.-][a-zA-Z0-9]+)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(?:@sha256:[a-f0-9]{64})?$`)
+
+func GenerateBackupJob(image, hostname string, options greenplumv1.GreenplumBackupOptions) (job batchv1.Job) {
+	if options.PluginImage != "" {
+		image = options.PluginImage
+	}
+
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	backupPod := &job.Spec.Template.Spec
+	backupPod.RestartPolicy = corev1.RestartPolicyNever
+
+	backupPod.Volumes = []corev1.Volume{
+		{
+			Name: "ssh-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  "ssh-secrets",
+					DefaultMode: heapvalue.NewInt32(0444),
+				},
+			},
+		},
+	}
+	backupPod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+	backupPod.Containers = []corev1.Container{
+		{
+			Name:  "gpbackup",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gpbackup_job.sh",
+			},
+			Args:            gpbackupArgs(options),
+			Env:             gpbackupEnv(hostname, options),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ssh-key",
+					ReadOnly:  false,
+					MountPath: "/etc/ssh-key",
+				},
+			},
+		},
+	}
+
+	if options.BackupDirVolume != nil {
+		backupPod.Volumes = append(backupPod.Volumes, corev1.Volume{
+			Name:         backupDirVolumeName,
+			VolumeSource: *options.BackupDirVolume.DeepCopy(),
+		})
+		backupContainer := &backupPod.Containers[0]
+		backupContainer.VolumeMounts = append(backupContainer.VolumeMounts, corev1.VolumeMount{
+			Name:      backupDirVolumeName,
+			MountPath: options.BackupDir,
+		})
+	}
+	if options.CABundle != nil {
+		backupPod.Volumes = append(backupPod.Volumes, cabundle.Volume(*options.CABundle))
+		backupContainer := &backupPod.Containers[0]
+		backupContainer.VolumeMounts = append(backupContainer.VolumeMounts, cabundle.VolumeMount())
+	}
+	if options.Plugin != nil && options.Plugin.Encryption != nil {
+		backupPod.Volumes = append(backupPod.Volumes, encryptionKeyVolume(*options.Plugin.Encryption))
+		backupContainer := &backupPod.Containers[0]
+		backupContainer.VolumeMounts = append(backupContainer.VolumeMounts, encryptionKeyVolumeMount())
+	}
+
+	return
+}
+
+func encryptionKey(spec greenplumv1.GreenplumBackupEncryptionSpec) string {
+	if spec.Key == "" {
+		return DefaultEncryptionKey
+	}
+	return spec.Key
+}
+
+// encryptionKeyVolume projects just the encryption key from its Secret
+func encryptionKeyVolume(spec greenplumv1.GreenplumBackupEncryptionSpec) corev1.Volume {
+	return corev1.Volume{
+		Name: encryptionKeyVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: spec.SecretName,
+				Items: []corev1.KeyToPath{
+					{
+						Key:  encryptionKey(spec),
+						Path: encryptionKeyFileName,
+					},
+				},
+				DefaultMode: heapvalue.NewInt32(0444),
+			},
+		},
+	}
+}
+
+// encryptionKeyVolumeMount mounts the encryption key at EncryptionKeyMountPath
+func encryptionKeyVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      encryptionKeyVolumeName,
+		MountPath: path.Dir(EncryptionKeyMountPath),
+		ReadOnly:  true,
+	}
+}
+
+// PluginConfig renders the gpbackup plugin configuration file, without the encryption key,
+// which the job scripts read from the mounted Secret
+func PluginConfig(plugin greenplumv1.GreenplumBackupPluginSpec) (string, error) {
+	options := map[string]string{}
+	for name, value := range plugin.Options {
+		options[name] = value
+	}
+	if plugin.Encryption != nil {
+		options[encryptionModeOptionName] = plugin.Encryption.Mode
+	}
+	config := struct {
+		ExecutablePath string            `json:"executablepath"`
+		Options        map[string]string `json:"options,omitempty"`
+	}{
+		ExecutablePath: plugin.ExecutablePath,
+		Options:        options,
+	}
+	rendered, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("rendering plugin config: %w", err)
+	}
+	return string(rendered), nil
+}
+
+func gpbackupArgs(options greenplumv1.GreenplumBackupOptions) []string {
+	database := options.Database
+	if database == "" {
+		database = defaultDatabase
+	}
+	args := []string{"--dbname", database}
+	for _, table := range options.IncludeTables {
+		args = append(args, "--include-table", table)
+	}
+	for _, table := range options.ExcludeTables {
+		args = append(args, "--exclude-table", table)
+	}
+	for _, schema := range options.IncludeSchemas {
+		args = append(args, "--include-schema", schema)
+	}
+	for _, schema := range options.ExcludeSchemas {
+		args = append(args, "--exclude-schema", schema)
+	}
+	if options.SingleDataFile {
+		args = append(args, "--single-data-file")
+	}
+	if options.CopyQueueSize != 0 {
+		args = append(args, "--copy-queue-size", strconv.Itoa(int(options.CopyQueueSize)))
+	}
+	if options.CompressionLevel != 0 {
+		args = append(args, "--compression-level", strconv.Itoa(int(options.CompressionLevel)))
+	}
+	if options.NoCompression {
+		args = append(args, "--no-compression")
+	}
+	if options.LeafPartitionData {
+		args = append(args, "--leaf-partition-data")
+	}
+	if options.WithStats {
+		args = append(args, "--with-stats")
+	}
+	if options.MetadataOnly {
+		args = append(args, "--metadata-only")
+	}
+	if options.DataOnly {
+		args = append(args, "--data-only")
+	}
+	if options.BackupDir != "" {
+		args = append(args, "--backup-dir", options.BackupDir)
+	}
+	if options.Plugin != nil {
+		args = append(args, "--plugin-config", PluginConfigPath)
+	}
+	return args
+}
+
+func gpbackupEnv(hostname string, options greenplumv1.GreenplumBackupOptions) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{
+			Name:      "GPBACKUP_HOST",
+			Value:     hostname,
+			ValueFrom: nil,
+		},
+	}
+	if options.Plugin != nil {
+		// ValidateBackupOptions rejects plugin options that could not be rendered
+		pluginConfig, _ := PluginConfig(*options.Plugin)
+		env = append(env, corev1.EnvVar{Name: "GPBACKUP_PLUGIN_CONFIG", Value: pluginConfig})
+	}
+	if options.Verify {
+		verifyDatabase := options.VerifyDatabase
+		if verifyDatabase == "" {
+			verifyDatabase = defaultVerifyDatabase
+		}
+		env = append(env, corev1.EnvVar{Name: "GPBACKUP_VERIFY_DATABASE", Value: verifyDatabase})
+		// gprestore has to be told where to find a backup written outside the data directories
+		if options.BackupDir != "" {
+			env = append(env, corev1.EnvVar{Name: "GPBACKUP_BACKUP_DIR", Value: options.BackupDir})
+		}
+		if options.RestoreJobs != 0 {
+			env = append(env, corev1.EnvVar{Name: "GPBACKUP_RESTORE_JOBS", Value: strconv.Itoa(int(options.RestoreJobs))})
+		}
+		if options.WithStats {
+			env = append(env, corev1.EnvVar{Name: "GPBACKUP_RESTORE_WITH_STATS", Value: "true"})
+		}
+	}
+	return env
+}
+
+func ValidateBackupOptions(options greenplumv1.GreenplumBackupOptions) error {
+	if len(options.IncludeTables) > 0 && len(options.ExcludeTables) > 0 {
+		return fmt.Errorf("includeTables and excludeTables cannot be used together")
+	}
+	if len(options.IncludeSchemas) > 0 {
+		if len(options.ExcludeSchemas) > 0 {
+			return fmt.Errorf("includeSchemas and excludeSchemas cannot be used together")
+		}
+		if len(options.IncludeTables) > 0 {
+			return fmt.Errorf("includeSchemas and includeTables cannot be used together")
+		}
+	}
+	if len(options.ExcludeSchemas) > 0 && (len(options.IncludeTables) > 0 || len(options.ExcludeTables) > 0) {
+		return fmt.Errorf("excludeSchemas cannot be combined with includeTables or excludeTables")
+	}
+	if options.VerifyDatabase != "" {
+		if !options.Verify {
+			return fmt.Errorf("verifyDatabase requires verify")
+		}
+		if !verifyDatabaseRegexp.MatchString(options.VerifyDatabase) {
+			return fmt.Errorf("invalid verifyDatabase %q: must be %s or start with %s_, followed by lowercase letters, digits and underscores",
+				options.VerifyDatabase, defaultVerifyDatabase, defaultVerifyDatabase)
+		}
+		database := options.Database
+		if database == "" {
+			database = defaultDatabase
+		}
+		if options.VerifyDatabase == database {
+			return fmt.Errorf("verifyDatabase cannot be the database being backed up")
+		}
+	}
+	if options.RestoreJobs != 0 {
+		if !options.Verify {
+			return fmt.Errorf("restoreJobs requires verify")
+		}
+		if options.RestoreJobs < 1 || options.RestoreJobs > maxRestoreJobs {
+			return fmt.Errorf("invalid restoreJobs %d: must be between 1 and %d", options.RestoreJobs, maxRestoreJobs)
+		}
+		// gprestore reads the single data file of each segment in order, with one connection
+		if options.RestoreJobs > 1 && options.SingleDataFile {
+			return fmt.Errorf("restoreJobs greater than 1 cannot be used with singleDataFile")
+		}
+	}
+	if options.CopyQueueSize != 0 {
+		if options.CopyQueueSize < 1 || options.CopyQueueSize > maxCopyQueueSize {
+			return fmt.Errorf("invalid copyQueueSize %d: must be between 1 and %d", options.CopyQueueSize, maxCopyQueueSize)
+		}
+		if !options.SingleDataFile {
+			return fmt.Errorf("copyQueueSize requires singleDataFile")
+		}
+	}
+	if options.CompressionLevel != 0 {
+		if options.CompressionLevel < 1 || options.CompressionLevel > maxCompressionLevel {
+			return fmt.Errorf("invalid compressionLevel %d: must be between 1 and %d", options.CompressionLevel, maxCompressionLevel)
+		}
+		if options.NoCompression {
+			return fmt.Errorf("compressionLevel and noCompression cannot be used together")
+		}
+	}
+	if options.MetadataOnly {
+		if options.DataOnly {
+			return fmt.Errorf("metadataOnly and dataOnly cannot be used together")
+		}
+		if options.SingleDataFile {
+			return fmt.Errorf("metadataOnly and singleDataFile cannot be used together")
+		}
+		if options.LeafPartitionData {
+			return fmt.Errorf("metadataOnly and leafPartitionData cannot be used together")
+		}
+	}
+	if options.DataOnly && options.WithStats {
+		return fmt.Errorf("dataOnly and withStats cannot be used together")
+	}
+	if options.DataOnly && options.Verify {
+		return fmt.Errorf("dataOnly and verify cannot be used together")
+	}
+	if options.PluginImage != "" && !imageRefRegexp.MatchString(options.PluginImage) {
+		return fmt.Errorf(`invalid pluginImage "%s": must be an image reference, e.g. registry.example.com/gpbackup-plugins:1.0`, options.PluginImage)
+	}
+	if options.BackupDir != "" && (!path.IsAbs(options.BackupDir) || path.Clean(options.BackupDir) != options.BackupDir) {
+		return fmt.Errorf(`invalid backupDir "%s": must be an absolute path`, options.BackupDir)
+	}
+	if options.BackupDirVolume != nil && options.BackupDir == "" {
+		return fmt.Errorf("backupDirVolume requires backupDir")
+	}
+	if options.BackupDir != "" && options.Plugin != nil {
+		return fmt.Errorf("backupDir and plugin cannot be used together: a backup is written either to a directory or through a storage plugin")
+	}
+	if options.CABundle != nil && options.CABundle.ConfigMapName == "" {
+		return fmt.Errorf("caBundle configMapName must be specified")
+	}
+	if options.Plugin != nil {
+		if err := validatePlugin(*options.Plugin); err != nil {
+			return err
+		}
+	}
+	if err := validateTableFilters(options.IncludeTables, "includeTables"); err != nil {
+		return err
+	}
+	if err := validateTableFilters(options.ExcludeTables, "excludeTables"); err != nil {
+		return err
+	}
+	if err := validateSchemaFilters(options.IncludeSchemas, "includeSchemas"); err != nil {
+		return err
+	}
+	return validateSchemaFilters(options.ExcludeSchemas, "excludeSchemas")
+}
+
+func validatePlugin(plugin greenplumv1.GreenplumBackupPluginSpec) error {
+	if !path.IsAbs(plugin.ExecutablePath) || path.Clean(plugin.ExecutablePath) != plugin.ExecutablePath {
+		return fmt.Errorf(`invalid plugin executablePath "%s": must be an absolute path`, plugin.ExecutablePath)
+	}
+	for name := range plugin.Options {
+		if !pluginOptionNameRegexp.MatchString(name) {
+			return fmt.Errorf(`invalid plugin option "%s": must only contain letters, digits and underscores`, name)
+		}
+		if plugin.Encryption != nil && (name == encryptionModeOptionName || name == encryptionKeyOptionName) {
+			return fmt.Errorf("plugin option %s is set by encryption and cannot be set in options", name)
+		}
+	}
+	if encryption := plugin.Encryption; encryption != nil {
+		if encryption.Mode != encryptionModeServerSide && encryption.Mode != encryptionModeClientSide {
+			return fmt.Errorf(`invalid encryption mode "%s": must be %s or %s`, encryption.Mode, encryptionModeServerSide, encryptionModeClientSide)
+		}
+		if encryption.SecretName == "" {
+			return fmt.Errorf("encryption secretName must be specified")
+		}
+	}
+	return nil
+}
+
+// ValidateEncryptionSecret checks that the Secret referenced by spec exists in namespace and holds a 256-bit key
+func ValidateEncryptionSecret(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumBackupEncryptionSpec) error {
+	var secret corev1.Secret
+	secretKey := types.NamespacedName{Namespace: namespace, Name: spec.SecretName}
+	if err := reader.Get(ctx, secretKey, &secret); err != nil {
+		if apierrs.IsNotFound(err) {
+			return fmt.Errorf(`Secret "%s" not found`, spec.SecretName)
+		}
+		return fmt.Errorf(`getting Secret "%s": %w`, spec.SecretName, err)
+	}
+	key, ok := secret.Data[encryptionKey(spec)]
+	if !ok {
+		return fmt.Errorf(`Secret "%s" has no key "%s"`, spec.SecretName, encryptionKey(spec))
+	}
+	if len(key) != encryptionKeyLength {
+		return fmt.Errorf(`Secret "%s" key "%s": must be a 256-bit key of %d bytes, not %d bytes`, spec.SecretName, encryptionKey(spec), encryptionKeyLength, len(key))
+	}
+	return nil
+}
+
+func validateTableFilters(tables []string, field string) error {
+	for _, table := range tables {
+		if !tableFilterRegexp.MatchString(table) {
+			return fmt.Errorf(`invalid %s entry "%s": must be in the form <schema>.<table>`, field, table)
+		}
+	}
+	return nil
+}
+
+func validateSchemaFilters(schemas []string, field string) error {
+	for _, schema := range schemas {
+		if !schemaFilterRegexp.MatchString(schema) || len(schema) > maxSchemaNameLength {
+			return fmt.Errorf(`invalid %s entry "%s": must be a schema name of at most %d bytes, without dots or whitespace`, field, schema, maxSchemaNameLength)
+		}
+	}
+	return nil
+}
+
+// VerificationStatus reads the verification result that gpbackup_job.sh writes to the
+// termination message of the gpbackup container. It returns nil if verification has not run.
+func VerificationStatus(pod corev1.Pod) *greenplumv1.GreenplumBackupVerificationStatus {
+	fields := terminationFields(pod, "gpbackup")
+	verification, ok := fields["verification"]
+	if !ok {
+		return nil
+	}
+	return &greenplumv1.GreenplumBackupVerificationStatus{
+		Timestamp: fields["timestamp"],
+		Succeeded: verification == "succeeded",
+		Message:   fields["message"],
+	}
+}
+
+// BackupTimestamp reads the timestamp key of the backup that gpbackup_job.sh writes to the termination
+// message of the gpbackup container once gpbackup succeeds. It returns "" if there is none.
+func BackupTimestamp(pod corev1.Pod) string {
+	return terminationFields(pod, "gpbackup")["timestamp"]
+}
+
+// terminationFields parses the key=value lines of the termination message of the named container.
+// It returns nil while the container has not terminated
+func terminationFields(pod corev1.Pod, containerName string) map[string]string {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name != containerName || containerStatus.State.Terminated == nil {
+			continue
+		}
+		fields := map[string]string{}
+		for _, line := range strings.Split(containerStatus.State.Terminated.Message, "\n") {
+			if key, value, ok := strings.Cut(line, "="); ok {
+				fields[key] = value
+			}
+		}
+		return fields
+	}
+	return nil
+}
@@ -0,0 +1,859 @@
+package backupjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("GenerateBackupJob", func() {
+	It("sets properties on the job", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0.agent.default.svc.cluster.local", greenplumv1.GreenplumBackupOptions{})
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		backupPod := job.Spec.Template.Spec
+		Expect(backupPod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+
+		sshSecretVolume := backupPod.Volumes[0]
+		Expect(sshSecretVolume.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolume.VolumeSource.Secret.SecretName).To(Equal("ssh-secrets"))
+		Expect(sshSecretVolume.VolumeSource.Secret.DefaultMode).To(gstruct.PointTo(Equal(int32(0444))))
+
+		Expect(backupPod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+		backupContainer := backupPod.Containers[0]
+		Expect(backupContainer.Name).To(Equal("gpbackup"))
+		Expect(backupContainer.Env[0].Name).To(Equal("GPBACKUP_HOST"))
+		Expect(backupContainer.Env[0].Value).To(Equal("master-0.agent.default.svc.cluster.local"))
+		Expect(backupContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(backupContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(backupContainer.Command).To(Equal([]string{
+			"/home/gpadmin/tools/gpbackup_job.sh",
+		}))
+		Expect(backupContainer.Args).To(Equal([]string{"--dbname", "gpadmin"}))
+
+		sshSecretVolumeMount := backupContainer.VolumeMounts[0]
+		Expect(sshSecretVolumeMount.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolumeMount.MountPath).To(Equal("/etc/ssh-key"))
+	})
+
+	It("runs the backup in the plugin image when one is given", func() {
+		options := greenplumv1.GreenplumBackupOptions{PluginImage: "registry.example.com/gpbackup-plugins:1.0"}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.example.com/gpbackup-plugins:1.0"))
+	})
+
+	It("backs up the requested database", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{Database: "sales"})
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"--dbname", "sales"}))
+	})
+
+	It("passes include table filters to gpbackup", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			IncludeTables: []string{"public.orders", "sales.customers"},
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--include-table", "public.orders",
+			"--include-table", "sales.customers",
+		}))
+	})
+
+	It("passes exclude table filters to gpbackup", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			ExcludeTables: []string{"public.scratch"},
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--exclude-table", "public.scratch",
+		}))
+	})
+
+	It("passes include schema filters to gpbackup", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			IncludeSchemas: []string{"tenant_a", "tenant_b"},
+			ExcludeTables:  []string{"tenant_a.audit_log"},
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--exclude-table", "tenant_a.audit_log",
+			"--include-schema", "tenant_a",
+			"--include-schema", "tenant_b",
+		}))
+	})
+
+	It("passes exclude schema filters to gpbackup", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			ExcludeSchemas: []string{"scratch"},
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--exclude-schema", "scratch",
+		}))
+	})
+
+	It("passes --single-data-file to gpbackup when requested", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			IncludeTables:  []string{"public.orders"},
+			SingleDataFile: true,
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--include-table", "public.orders",
+			"--single-data-file",
+		}))
+	})
+
+	It("passes --copy-queue-size to gpbackup when requested", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			SingleDataFile: true,
+			CopyQueueSize:  8,
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--single-data-file",
+			"--copy-queue-size", "8",
+		}))
+	})
+
+	It("passes --compression-level to gpbackup when requested", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{CompressionLevel: 6})
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--compression-level", "6",
+		}))
+	})
+
+	It("passes --no-compression to gpbackup when requested", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			SingleDataFile: true,
+			NoCompression:  true,
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--single-data-file",
+			"--no-compression",
+		}))
+	})
+
+	It("passes --leaf-partition-data to gpbackup when requested", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			IncludeTables:     []string{"public.sales"},
+			LeafPartitionData: true,
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--include-table", "public.sales",
+			"--leaf-partition-data",
+		}))
+	})
+
+	It("passes --with-stats to gpbackup when requested", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{WithStats: true})
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--with-stats",
+		}))
+	})
+
+	It("passes --metadata-only to gpbackup when requested", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{MetadataOnly: true})
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--metadata-only",
+		}))
+	})
+
+	It("passes --data-only to gpbackup when requested", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{DataOnly: true})
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--dbname", "gpadmin",
+			"--data-only",
+		}))
+	})
+
+	It("does not verify the backup by default", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(HaveLen(1))
+	})
+
+	It("verifies the backup into the default scratch database", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{Verify: true})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "GPBACKUP_VERIFY_DATABASE", Value: "gpbackup_verify"}))
+	})
+
+	It("verifies the backup into the requested scratch database", func() {
+		options := greenplumv1.GreenplumBackupOptions{Verify: true, VerifyDatabase: "gpbackup_verify_scratch"}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "GPBACKUP_VERIFY_DATABASE", Value: "gpbackup_verify_scratch"}))
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"--dbname", "gpadmin"}))
+	})
+
+	It("restores with the requested number of parallel jobs when verifying", func() {
+		options := greenplumv1.GreenplumBackupOptions{Verify: true, RestoreJobs: 8}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "GPBACKUP_RESTORE_JOBS", Value: "8"}))
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"--dbname", "gpadmin"}))
+	})
+
+	It("restores the statistics when verifying a backup with statistics", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{Verify: true, WithStats: true})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "GPBACKUP_RESTORE_WITH_STATS", Value: "true"}))
+	})
+
+	It("does not restore statistics the backup does not have", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{Verify: true})
+		for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+			Expect(env.Name).NotTo(Equal("GPBACKUP_RESTORE_WITH_STATS"))
+		}
+	})
+
+	It("leaves the number of restore jobs to gprestore by default", func() {
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumBackupOptions{Verify: true})
+		for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+			Expect(env.Name).NotTo(Equal("GPBACKUP_RESTORE_JOBS"))
+		}
+	})
+
+	When("a backup directory is requested", func() {
+		var options greenplumv1.GreenplumBackupOptions
+		BeforeEach(func() {
+			options = greenplumv1.GreenplumBackupOptions{BackupDir: "/backups"}
+		})
+
+		It("passes --backup-dir to gpbackup", func() {
+			job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+				"--dbname", "gpadmin",
+				"--backup-dir", "/backups",
+			}))
+		})
+
+		It("does not add a volume when none is given", func() {
+			job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+			Expect(job.Spec.Template.Spec.Volumes).To(HaveLen(1))
+			Expect(job.Spec.Template.Spec.Containers[0].VolumeMounts).To(HaveLen(1))
+		})
+
+		It("mounts the backup directory volume at the backup directory", func() {
+			options.BackupDirVolume = &corev1.VolumeSource{
+				NFS: &corev1.NFSVolumeSource{Server: "nfs.example.com", Path: "/exports/greenplum"},
+			}
+			job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+			backupPod := job.Spec.Template.Spec
+			Expect(backupPod.Volumes).To(HaveLen(2))
+			Expect(backupPod.Volumes[1].Name).To(Equal("backup-dir"))
+			Expect(backupPod.Volumes[1].VolumeSource.NFS).To(Equal(options.BackupDirVolume.NFS))
+			Expect(backupPod.Containers[0].VolumeMounts).To(HaveLen(2))
+			Expect(backupPod.Containers[0].VolumeMounts[1]).To(Equal(corev1.VolumeMount{
+				Name:      "backup-dir",
+				MountPath: "/backups",
+			}))
+		})
+
+		It("tells verification where to find the backup", func() {
+			options.Verify = true
+			job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+				corev1.EnvVar{Name: "GPBACKUP_BACKUP_DIR", Value: "/backups"}))
+		})
+	})
+
+	It("mounts a CA bundle into the trust store when one is given", func() {
+		options := greenplumv1.GreenplumBackupOptions{
+			CABundle: &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca", Key: "bundle.pem"},
+		}
+		job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+		backupPod := job.Spec.Template.Spec
+		Expect(backupPod.Volumes).To(HaveLen(2))
+		Expect(backupPod.Volumes[1].Name).To(Equal("ca-bundle"))
+		Expect(backupPod.Volumes[1].ConfigMap.Name).To(Equal("corporate-ca"))
+		Expect(backupPod.Volumes[1].ConfigMap.Items).To(Equal([]corev1.KeyToPath{{Key: "bundle.pem", Path: "greenplum-ca-bundle.crt"}}))
+		Expect(backupPod.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      "ca-bundle",
+			MountPath: "/etc/ssl/certs/greenplum-ca-bundle.crt",
+			SubPath:   "greenplum-ca-bundle.crt",
+			ReadOnly:  true,
+		}))
+	})
+
+	When("a storage plugin is given", func() {
+		var options greenplumv1.GreenplumBackupOptions
+		BeforeEach(func() {
+			options = greenplumv1.GreenplumBackupOptions{
+				Plugin: &greenplumv1.GreenplumBackupPluginSpec{
+					ExecutablePath: "/usr/local/greenplum-db/bin/gpbackup_s3_plugin",
+					Options:        map[string]string{"bucket": "backups", "folder": "greenplum/prod"},
+				},
+			}
+		})
+
+		It("passes the plugin config to gpbackup", func() {
+			job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+				"--dbname", "gpadmin",
+				"--plugin-config", "/tmp/gpbackup_plugin_config.yaml",
+			}))
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name: "GPBACKUP_PLUGIN_CONFIG",
+				Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+					"options:\n" +
+					"  bucket: backups\n" +
+					"  folder: greenplum/prod\n",
+			}))
+			Expect(job.Spec.Template.Spec.Volumes).To(HaveLen(1))
+		})
+
+		When("encryption is given", func() {
+			BeforeEach(func() {
+				options.Plugin.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key"}
+			})
+
+			It("adds the encryption mode to the plugin config", func() {
+				job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+				Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+					Name: "GPBACKUP_PLUGIN_CONFIG",
+					Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+						"options:\n" +
+						"  bucket: backups\n" +
+						"  encryption_mode: server-side\n" +
+						"  folder: greenplum/prod\n",
+				}))
+			})
+
+			It("mounts the key from the Secret", func() {
+				job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+				backupPod := job.Spec.Template.Spec
+				Expect(backupPod.Volumes).To(HaveLen(2))
+				Expect(backupPod.Volumes[1].Name).To(Equal("encryption-key"))
+				Expect(backupPod.Volumes[1].Secret.SecretName).To(Equal("backup-key"))
+				Expect(backupPod.Volumes[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "encryption.key", Path: "encryption.key"}}))
+				Expect(backupPod.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+					Name:      "encryption-key",
+					MountPath: "/etc/gpbackup-encryption",
+					ReadOnly:  true,
+				}))
+			})
+
+			It("projects the given key of the Secret", func() {
+				options.Plugin.Encryption.Key = "aes.key"
+				job := GenerateBackupJob("greenplum-for-kubernetes:magic", "master-0", options)
+				Expect(job.Spec.Template.Spec.Volumes[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "aes.key", Path: "encryption.key"}}))
+			})
+		})
+	})
+})
+
+var _ = Describe("VerificationStatus", func() {
+	terminatedPod := func(message string) corev1.Pod {
+		return corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "gpbackup",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{Message: message},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	It("records a successful verification", func() {
+		pod := terminatedPod("verification=succeeded\ntimestamp=20200102030405\n")
+		Expect(VerificationStatus(pod)).To(Equal(&greenplumv1.GreenplumBackupVerificationStatus{
+			Timestamp: "20200102030405",
+			Succeeded: true,
+		}))
+	})
+
+	It("records a failed verification", func() {
+		pod := terminatedPod("verification=failed\ntimestamp=20200102030405\nmessage=gprestore into gpbackup_verify failed\n")
+		Expect(VerificationStatus(pod)).To(Equal(&greenplumv1.GreenplumBackupVerificationStatus{
+			Timestamp: "20200102030405",
+			Succeeded: false,
+			Message:   "gprestore into gpbackup_verify failed",
+		}))
+	})
+
+	It("returns nil when verification did not run", func() {
+		Expect(VerificationStatus(terminatedPod(""))).To(BeNil())
+	})
+
+	It("returns nil while the gpbackup container is running", func() {
+		pod := corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  "gpbackup",
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					},
+				},
+			},
+		}
+		Expect(VerificationStatus(pod)).To(BeNil())
+	})
+})
+
+var _ = Describe("BackupTimestamp", func() {
+	It("reads the timestamp from the termination message", func() {
+		pod := corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "gpbackup",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{Message: "timestamp=20200102030405\n"},
+						},
+					},
+				},
+			},
+		}
+		Expect(BackupTimestamp(pod)).To(Equal("20200102030405"))
+	})
+
+	It("returns empty while the gpbackup container is running", func() {
+		pod := corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  "gpbackup",
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					},
+				},
+			},
+		}
+		Expect(BackupTimestamp(pod)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("ValidateBackupOptions", func() {
+	It("accepts empty options", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{})).To(Succeed())
+	})
+
+	It("accepts schema-qualified table filters", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			IncludeTables: []string{"public.orders", "Sales.Customers_2020"},
+		})).To(Succeed())
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			ExcludeTables: []string{"public.orders"},
+		})).To(Succeed())
+	})
+
+	It("rejects include and exclude table filters together", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			IncludeTables: []string{"public.orders"},
+			ExcludeTables: []string{"public.scratch"},
+		})
+		Expect(err).To(MatchError("includeTables and excludeTables cannot be used together"))
+	})
+
+	It("accepts schema filters", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			IncludeSchemas: []string{"tenant_a", "Tenant-B"},
+			ExcludeTables:  []string{"tenant_a.audit_log"},
+		})).To(Succeed())
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			ExcludeSchemas: []string{"scratch"},
+		})).To(Succeed())
+	})
+
+	It("rejects include and exclude schema filters together", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			IncludeSchemas: []string{"tenant_a"},
+			ExcludeSchemas: []string{"scratch"},
+		})
+		Expect(err).To(MatchError("includeSchemas and excludeSchemas cannot be used together"))
+	})
+
+	It("rejects include schema filters with include table filters", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			IncludeSchemas: []string{"tenant_a"},
+			IncludeTables:  []string{"public.orders"},
+		})
+		Expect(err).To(MatchError("includeSchemas and includeTables cannot be used together"))
+	})
+
+	DescribeTable("rejects exclude schema filters with table filters",
+		func(options greenplumv1.GreenplumBackupOptions) {
+			options.ExcludeSchemas = []string{"scratch"}
+			err := ValidateBackupOptions(options)
+			Expect(err).To(MatchError("excludeSchemas cannot be combined with includeTables or excludeTables"))
+		},
+		Entry("include tables", greenplumv1.GreenplumBackupOptions{IncludeTables: []string{"public.orders"}}),
+		Entry("exclude tables", greenplumv1.GreenplumBackupOptions{ExcludeTables: []string{"public.orders"}}),
+	)
+
+	It("accepts a copy queue size with a single data file", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			SingleDataFile: true,
+			CopyQueueSize:  1000,
+		})).To(Succeed())
+	})
+
+	It("rejects verifying a single data file with parallel restore jobs", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			SingleDataFile: true,
+			Verify:         true,
+			RestoreJobs:    4,
+		})
+		Expect(err).To(MatchError("restoreJobs greater than 1 cannot be used with singleDataFile"))
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			SingleDataFile: true,
+			Verify:         true,
+			RestoreJobs:    1,
+		})).To(Succeed())
+	})
+
+	It("rejects a copy queue size without a single data file", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{CopyQueueSize: 4})
+		Expect(err).To(MatchError("copyQueueSize requires singleDataFile"))
+	})
+
+	DescribeTable("rejects a copy queue size out of range",
+		func(copyQueueSize int32, expectedMessage string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+				SingleDataFile: true,
+				CopyQueueSize:  copyQueueSize,
+			})
+			Expect(err).To(MatchError(expectedMessage))
+		},
+		Entry("negative", int32(-1), "invalid copyQueueSize -1: must be between 1 and 1000"),
+		Entry("too large", int32(1001), "invalid copyQueueSize 1001: must be between 1 and 1000"),
+	)
+
+	It("accepts a compression level or no compression", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{CompressionLevel: 9})).To(Succeed())
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{NoCompression: true})).To(Succeed())
+	})
+
+	It("rejects a compression level with no compression", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{CompressionLevel: 1, NoCompression: true})
+		Expect(err).To(MatchError("compressionLevel and noCompression cannot be used together"))
+	})
+
+	DescribeTable("rejects a compression level out of range",
+		func(compressionLevel int32, expectedMessage string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{CompressionLevel: compressionLevel})
+			Expect(err).To(MatchError(expectedMessage))
+		},
+		Entry("negative", int32(-1), "invalid compressionLevel -1: must be between 1 and 9"),
+		Entry("too large", int32(10), "invalid compressionLevel 10: must be between 1 and 9"),
+	)
+
+	It("accepts metadataOnly or dataOnly on their own", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{MetadataOnly: true, Verify: true})).To(Succeed())
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{DataOnly: true, SingleDataFile: true})).To(Succeed())
+	})
+
+	It("rejects metadataOnly and dataOnly together", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{MetadataOnly: true, DataOnly: true})
+		Expect(err).To(MatchError("metadataOnly and dataOnly cannot be used together"))
+	})
+
+	It("rejects metadataOnly with a single data file", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{MetadataOnly: true, SingleDataFile: true})
+		Expect(err).To(MatchError("metadataOnly and singleDataFile cannot be used together"))
+	})
+
+	It("accepts leafPartitionData with a single data file or dataOnly", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{LeafPartitionData: true, SingleDataFile: true})).To(Succeed())
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{LeafPartitionData: true, DataOnly: true})).To(Succeed())
+	})
+
+	It("rejects metadataOnly with leafPartitionData", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{MetadataOnly: true, LeafPartitionData: true})
+		Expect(err).To(MatchError("metadataOnly and leafPartitionData cannot be used together"))
+	})
+
+	It("accepts withStats with metadataOnly and verify", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{WithStats: true, MetadataOnly: true, Verify: true})).To(Succeed())
+	})
+
+	It("rejects withStats with dataOnly", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{DataOnly: true, WithStats: true})
+		Expect(err).To(MatchError("dataOnly and withStats cannot be used together"))
+	})
+
+	It("rejects verifying a dataOnly backup", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{DataOnly: true, Verify: true})
+		Expect(err).To(MatchError("dataOnly and verify cannot be used together"))
+	})
+
+	It("accepts a verify database when verify is set", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			Verify:         true,
+			VerifyDatabase: "gpbackup_verify_scratch",
+		})).To(Succeed())
+	})
+
+	It("rejects a verify database without verify", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{VerifyDatabase: "gpbackup_verify_scratch"})
+		Expect(err).To(MatchError("verifyDatabase requires verify"))
+	})
+
+	It("accepts restore jobs when verify is set", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{Verify: true, RestoreJobs: 64})).To(Succeed())
+	})
+
+	It("rejects restore jobs without verify", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{RestoreJobs: 4})
+		Expect(err).To(MatchError("restoreJobs requires verify"))
+	})
+
+	DescribeTable("rejects restore jobs out of range",
+		func(restoreJobs int32, expectedMessage string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{Verify: true, RestoreJobs: restoreJobs})
+			Expect(err).To(MatchError(expectedMessage))
+		},
+		Entry("negative", int32(-1), "invalid restoreJobs -1: must be between 1 and 64"),
+		Entry("too large", int32(65), "invalid restoreJobs 65: must be between 1 and 64"),
+	)
+
+	It("rejects verifying into the database being backed up", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			Database:       "gpbackup_verify_sales",
+			Verify:         true,
+			VerifyDatabase: "gpbackup_verify_sales",
+		})
+		Expect(err).To(MatchError("verifyDatabase cannot be the database being backed up"))
+	})
+
+	DescribeTable("rejects a verify database the operator does not own, since the job drops it",
+		func(verifyDatabase string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{Verify: true, VerifyDatabase: verifyDatabase})
+			Expect(err).To(MatchError(fmt.Sprintf("invalid verifyDatabase %q: must be gpbackup_verify or start with gpbackup_verify_, "+
+				"followed by lowercase letters, digits and underscores", verifyDatabase)))
+		},
+		Entry("the default database", "gpadmin"),
+		Entry("postgres", "postgres"),
+		Entry("a template database", "template1"),
+		Entry("gpperfmon", "gpperfmon"),
+		Entry("a user database", "sales"),
+		Entry("a prefix without an underscore", "gpbackup_verifysales"),
+		Entry("an empty suffix", "gpbackup_verify_"),
+		Entry("uppercase", "gpbackup_verify_Sales"),
+		Entry("shell metacharacters", "gpbackup_verify_x; dropdb sales"),
+	)
+
+	It("accepts the default verify database", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{Verify: true, VerifyDatabase: "gpbackup_verify"})).To(Succeed())
+	})
+
+	DescribeTable("accepts plugin image references",
+		func(image string) {
+			Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{PluginImage: image})).To(Succeed())
+		},
+		Entry("repository only", "gpbackup-plugins"),
+		Entry("with tag", "gpbackup-plugins:1.0"),
+		Entry("with registry and port", "registry.example.com:5000/greenplum/gpbackup-plugins:1.0"),
+		Entry("with digest", "gpbackup-plugins@sha256:"+strings.Repeat("a", 64)),
+	)
+
+	DescribeTable("rejects malformed plugin image references",
+		func(image string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{PluginImage: image})
+			Expect(err).To(MatchError(`invalid pluginImage "` + image + `": must be an image reference, e.g. registry.example.com/gpbackup-plugins:1.0`))
+		},
+		Entry("uppercase repository", "GPBackup-Plugins"),
+		Entry("whitespace", "gpbackup plugins:1.0"),
+		Entry("empty tag", "gpbackup-plugins:"),
+		Entry("short digest", "gpbackup-plugins@sha256:abc"),
+	)
+
+	It("accepts an absolute backup directory", func() {
+		Expect(ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{BackupDir: "/backups/greenplum"})).To(Succeed())
+	})
+
+	DescribeTable("rejects a backup directory that is not an absolute path",
+		func(backupDir string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{BackupDir: backupDir})
+			Expect(err).To(MatchError(`invalid backupDir "` + backupDir + `": must be an absolute path`))
+		},
+		Entry("relative", "backups"),
+		Entry("dot relative", "./backups"),
+		Entry("parent reference", "/backups/../etc"),
+		Entry("trailing slash", "/backups/"),
+	)
+
+	It("rejects a backup directory volume without a backup directory", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			BackupDirVolume: &corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		Expect(err).To(MatchError("backupDirVolume requires backupDir"))
+	})
+
+	It("rejects a backup directory together with a storage plugin", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			BackupDir: "/backups",
+			Plugin:    &greenplumv1.GreenplumBackupPluginSpec{ExecutablePath: "/usr/local/greenplum-db/bin/gpbackup_s3_plugin"},
+		})
+		Expect(err).To(MatchError("backupDir and plugin cannot be used together: a backup is written either to a directory or through a storage plugin"))
+	})
+
+	It("rejects a CA bundle without a ConfigMap name", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			CABundle: &greenplumv1.GreenplumCABundleSpec{},
+		})
+		Expect(err).To(MatchError("caBundle configMapName must be specified"))
+	})
+
+	DescribeTable("rejects malformed table filters",
+		func(table string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{IncludeTables: []string{table}})
+			Expect(err).To(MatchError(`invalid includeTables entry "` + table + `": must be in the form <schema>.<table>`))
+			err = ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{ExcludeTables: []string{table}})
+			Expect(err).To(MatchError(`invalid excludeTables entry "` + table + `": must be in the form <schema>.<table>`))
+		},
+		Entry("no schema", "orders"),
+		Entry("empty schema", ".orders"),
+		Entry("empty table", "public."),
+		Entry("too many parts", "db.public.orders"),
+		Entry("whitespace", "public.my orders"),
+		Entry("empty", ""),
+	)
+
+	DescribeTable("rejects malformed schema filters",
+		func(schema string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{IncludeSchemas: []string{schema}})
+			Expect(err).To(MatchError(`invalid includeSchemas entry "` + schema + `": must be a schema name of at most 63 bytes, without dots or whitespace`))
+			err = ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{ExcludeSchemas: []string{schema}})
+			Expect(err).To(MatchError(`invalid excludeSchemas entry "` + schema + `": must be a schema name of at most 63 bytes, without dots or whitespace`))
+		},
+		Entry("schema-qualified", "public.orders"),
+		Entry("whitespace", "tenant a"),
+		Entry("empty", ""),
+		Entry("too long", strings.Repeat("s", 64)),
+	)
+
+	It("accepts an encrypted storage plugin", func() {
+		err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{
+			Plugin: &greenplumv1.GreenplumBackupPluginSpec{
+				ExecutablePath: "/usr/local/greenplum-db/bin/gpbackup_s3_plugin",
+				Options:        map[string]string{"bucket": "backups"},
+				Encryption:     &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "client-side", SecretName: "backup-key"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	DescribeTable("rejects invalid storage plugins",
+		func(plugin greenplumv1.GreenplumBackupPluginSpec, expectedErr string) {
+			err := ValidateBackupOptions(greenplumv1.GreenplumBackupOptions{Plugin: &plugin})
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("relative executable path",
+			greenplumv1.GreenplumBackupPluginSpec{ExecutablePath: "gpbackup_s3_plugin"},
+			`invalid plugin executablePath "gpbackup_s3_plugin": must be an absolute path`),
+		Entry("option name that is not a YAML key",
+			greenplumv1.GreenplumBackupPluginSpec{ExecutablePath: "/usr/local/bin/plugin", Options: map[string]string{"bucket: x": "y"}},
+			`invalid plugin option "bucket: x": must only contain letters, digits and underscores`),
+		Entry("encryption option set by hand",
+			greenplumv1.GreenplumBackupPluginSpec{
+				ExecutablePath: "/usr/local/bin/plugin",
+				Options:        map[string]string{"encryption_key": "c2VjcmV0"},
+				Encryption:     &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key"},
+			},
+			"plugin option encryption_key is set by encryption and cannot be set in options"),
+		Entry("unknown encryption mode",
+			greenplumv1.GreenplumBackupPluginSpec{
+				ExecutablePath: "/usr/local/bin/plugin",
+				Encryption:     &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "aes", SecretName: "backup-key"},
+			},
+			`invalid encryption mode "aes": must be server-side or client-side`),
+		Entry("encryption without a Secret",
+			greenplumv1.GreenplumBackupPluginSpec{
+				ExecutablePath: "/usr/local/bin/plugin",
+				Encryption:     &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side"},
+			},
+			"encryption secretName must be specified"),
+	)
+})
+
+var _ = Describe("ValidateEncryptionSecret", func() {
+	var (
+		ctx            context.Context
+		reactiveClient *reactive.Client
+		spec           greenplumv1.GreenplumBackupEncryptionSpec
+		secret         *corev1.Secret
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		spec = greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key"}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "backup-key"},
+			Data:       map[string][]byte{"encryption.key": []byte(strings.Repeat("k", 32))},
+		}
+	})
+	JustBeforeEach(func() {
+		if secret != nil {
+			Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+		}
+	})
+
+	It("accepts a Secret holding a 256-bit key", func() {
+		Expect(ValidateEncryptionSecret(ctx, reactiveClient, "test-ns", spec)).To(Succeed())
+	})
+	When("the Secret does not exist", func() {
+		BeforeEach(func() {
+			secret = nil
+		})
+		It("returns an error", func() {
+			Expect(ValidateEncryptionSecret(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`Secret "backup-key" not found`))
+		})
+	})
+	When("getting the Secret fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("get", "secrets", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(ValidateEncryptionSecret(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`getting Secret "backup-key": injected error`))
+		})
+	})
+	When("the Secret does not have the key", func() {
+		BeforeEach(func() {
+			spec.Key = "aes.key"
+		})
+		It("returns an error", func() {
+			Expect(ValidateEncryptionSecret(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`Secret "backup-key" has no key "aes.key"`))
+		})
+	})
+	When("the key is not 256 bits", func() {
+		BeforeEach(func() {
+			secret.Data["encryption.key"] = []byte("too short")
+		})
+		It("returns an error", func() {
+			Expect(ValidateEncryptionSecret(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`Secret "backup-key" key "encryption.key": must be a 256-bit key of 32 bytes, not 9 bytes`))
+		})
+	})
+})
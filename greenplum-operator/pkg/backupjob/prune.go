@@ -0,0 +1,113 @@
+package backupjob
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// gpbackup timestamp keys are the local time of the master when the backup started
+const timestampLayout = "20060102150405"
+
+// gpbackup_s3_plugin keeps each backup under <folder>/backups/<date>/<timestamp>/
+var backupObjectRegexp = regexp.MustCompile(`(?:^|/)backups/[0-9]{8}/([0-9]{14})/`)
+
+// GeneratePruneJob returns a job that deletes the backups with the given timestamps from the S3 folder of spec, which
+// must have one, with gpbackup_s3_plugin. It runs the plugin in the job itself, so it needs neither the master nor gpbackup
+func GeneratePruneJob(image string, spec greenplumv1.GreenplumBackupSpec, timestamps []string) (job batchv1.Job) {
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	prunePod := &job.Spec.Template.Spec
+	prunePod.RestartPolicy = corev1.RestartPolicyNever
+	prunePod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+
+	// deleting the objects of a backup does not need its encryption key, so the plugin config is rendered
+	// without encryption, which cannot fail
+	plugin := S3PluginSpec(*spec.S3)
+	plugin.Encryption = nil
+	pluginConfig, _ := PluginConfig(*plugin)
+	prunePod.Containers = []corev1.Container{
+		{
+			Name:  "gpbackup-prune",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gpbackup_prune_job.sh",
+			},
+			Args: timestamps,
+			Env: append([]corev1.EnvVar{
+				{Name: "GPBACKUP_PLUGIN_CONFIG", Value: pluginConfig},
+			}, S3CredentialsEnv(spec.S3.Credentials)...),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+		},
+	}
+	return
+}
+
+// PrunedBackups reads the timestamps of the backups that gpbackup_prune_job.sh deleted, which it writes to
+// the termination message of the gpbackup-prune container, also when it fails part way
+func PrunedBackups(pod corev1.Pod) []string {
+	pruned := terminationFields(pod, "gpbackup-prune")["pruned"]
+	if pruned == "" {
+		return nil
+	}
+	return strings.Split(pruned, ",")
+}
+
+// ListBackupTimestamps returns the timestamps of the backups in the S3 folder of s3, newest first
+func ListBackupTimestamps(lister S3Lister, s3 greenplumv1.GreenplumBackupS3Spec) ([]string, error) {
+	prefix := path.Join(strings.Trim(s3.Prefix, "/"), "backups") + "/"
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	found := map[string]bool{}
+	for object := range lister.ListObjects(s3.Bucket, prefix, true, doneCh) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("listing s3://%s/%s: %w", s3.Bucket, prefix, object.Err)
+		}
+		if match := backupObjectRegexp.FindStringSubmatch(object.Key); match != nil {
+			found[match[1]] = true
+		}
+	}
+
+	timestamps := make([]string, 0, len(found))
+	for timestamp := range found {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(timestamps)))
+	return timestamps, nil
+}
+
+// ExpiredBackups returns the timestamps, given newest first, of the backups that are past the retention policy
+// at now. The backup with timestamp current is never expired
+func ExpiredBackups(timestamps []string, current string, retention greenplumv1.GreenplumBackupRetentionSpec, now time.Time) []string {
+	var expired []string
+	for i, timestamp := range timestamps {
+		if timestamp == current {
+			continue
+		}
+		if retention.MaxCount != nil && i >= int(*retention.MaxCount) {
+			expired = append(expired, timestamp)
+			continue
+		}
+		if retention.MaxAge != nil {
+			// the Greenplum image sets no time zone, so the master's local time is UTC
+			backupTime, err := time.ParseInLocation(timestampLayout, timestamp, time.UTC)
+			if err == nil && now.Sub(backupTime) > retention.MaxAge.Duration {
+				expired = append(expired, timestamp)
+			}
+		}
+	}
+	return expired
+}
@@ -0,0 +1,169 @@
+package backupjob
+
+import (
+	"errors"
+	"time"
+
+	"github.com/minio/minio-go/v6"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("GeneratePruneJob", func() {
+	var spec greenplumv1.GreenplumBackupSpec
+	BeforeEach(func() {
+		spec = greenplumv1.GreenplumBackupSpec{
+			S3: &greenplumv1.GreenplumBackupS3Spec{
+				Bucket:      "backups",
+				Prefix:      "greenplum/prod",
+				Region:      "us-east-1",
+				Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+			},
+		}
+	})
+
+	It("sets properties on the job", func() {
+		job := GeneratePruneJob("greenplum-for-kubernetes:magic", spec, []string{"20200101000000", "20191231000000"})
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		prunePod := job.Spec.Template.Spec
+		Expect(prunePod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(prunePod.Volumes).To(BeEmpty())
+		Expect(prunePod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+
+		pruneContainer := prunePod.Containers[0]
+		Expect(pruneContainer.Name).To(Equal("gpbackup-prune"))
+		Expect(pruneContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(pruneContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(pruneContainer.Command).To(Equal([]string{"/home/gpadmin/tools/gpbackup_prune_job.sh"}))
+		Expect(pruneContainer.Args).To(Equal([]string{"20200101000000", "20191231000000"}))
+	})
+
+	It("passes the plugin config and the credentials", func() {
+		spec.S3.Endpoint = "https://minio.example.com"
+		// deleting an encrypted backup does not need its key
+		spec.S3.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "server-side", SecretName: "backup-key"}
+		spec.S3.Credentials.AccessKeyIDKey = "id"
+		job := GeneratePruneJob("greenplum-for-kubernetes:magic", spec, []string{"20200101000000"})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{
+				Name: "GPBACKUP_PLUGIN_CONFIG",
+				Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+					"options:\n" +
+					"  bucket: backups\n" +
+					"  endpoint: https://minio.example.com\n" +
+					"  folder: greenplum/prod\n" +
+					"  region: us-east-1\n",
+			},
+			secretEnvVar("GPBACKUP_S3_ACCESS_KEY_ID", "s3-creds", "id"),
+			secretEnvVar("GPBACKUP_S3_SECRET_ACCESS_KEY", "s3-creds", "aws_secret_access_key"),
+		}))
+	})
+})
+
+var _ = Describe("PrunedBackups", func() {
+	terminatedPod := func(message string) corev1.Pod {
+		return corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "gpbackup-prune",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{Message: message},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	It("reads the deleted backups from the termination message", func() {
+		Expect(PrunedBackups(terminatedPod("pruned=20200101000000,20191231000000\n"))).
+			To(Equal([]string{"20200101000000", "20191231000000"}))
+	})
+
+	It("returns nil when no backup was deleted", func() {
+		Expect(PrunedBackups(terminatedPod("pruned=\n"))).To(BeNil())
+	})
+
+	It("returns nil while the prune container is running", func() {
+		pod := corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  "gpbackup-prune",
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					},
+				},
+			},
+		}
+		Expect(PrunedBackups(pod)).To(BeNil())
+	})
+})
+
+var _ = Describe("ListBackupTimestamps", func() {
+	var (
+		lister *fakeS3Lister
+		s3     greenplumv1.GreenplumBackupS3Spec
+	)
+	BeforeEach(func() {
+		lister = &fakeS3Lister{}
+		s3 = greenplumv1.GreenplumBackupS3Spec{Bucket: "backups", Prefix: "greenplum/prod"}
+	})
+
+	It("returns the timestamps of the backups in the folder, newest first", func() {
+		lister.objects = []minio.ObjectInfo{
+			{Key: "greenplum/prod/backups/20191231/20191231000000/gpbackup_20191231000000_metadata.sql"},
+			{Key: "greenplum/prod/backups/20200102/20200102030405/gpbackup_20200102030405_report"},
+			{Key: "greenplum/prod/backups/20191231/20191231000000/gpbackup_20191231000000_config.yaml"},
+			{Key: "greenplum/prod/backups/20200101/20200101000000/gpbackup_0_20200101000000.gz"},
+			{Key: "greenplum/prod/backups/stray-object"},
+		}
+		Expect(ListBackupTimestamps(lister, s3)).To(Equal([]string{"20200102030405", "20200101000000", "20191231000000"}))
+		Expect(lister.bucket).To(Equal("backups"))
+		Expect(lister.prefix).To(Equal("greenplum/prod/backups/"))
+	})
+
+	It("returns an error when listing fails", func() {
+		lister.objects = []minio.ObjectInfo{{Err: errors.New("injected error")}}
+		_, err := ListBackupTimestamps(lister, s3)
+		Expect(err).To(MatchError("listing s3://backups/greenplum/prod/backups/: injected error"))
+	})
+})
+
+var _ = Describe("ExpiredBackups", func() {
+	var (
+		timestamps = []string{"20200110000000", "20200109000000", "20200105000000", "20200101000000"}
+		now        = time.Date(2020, 1, 10, 0, 0, 1, 0, time.UTC)
+		maxCount   = func(count int32) *int32 { return &count }
+		maxAge     = func(duration time.Duration) *metav1.Duration { return &metav1.Duration{Duration: duration} }
+	)
+
+	It("expires nothing without limits", func() {
+		Expect(ExpiredBackups(timestamps, "20200110000000", greenplumv1.GreenplumBackupRetentionSpec{}, now)).To(BeEmpty())
+	})
+
+	It("keeps the most recent backups up to maxCount", func() {
+		retention := greenplumv1.GreenplumBackupRetentionSpec{MaxCount: maxCount(2)}
+		Expect(ExpiredBackups(timestamps, "20200110000000", retention, now)).To(Equal([]string{"20200105000000", "20200101000000"}))
+	})
+
+	It("expires backups older than maxAge", func() {
+		retention := greenplumv1.GreenplumBackupRetentionSpec{MaxAge: maxAge(7 * 24 * time.Hour)}
+		Expect(ExpiredBackups(timestamps, "20200110000000", retention, now)).To(Equal([]string{"20200101000000"}))
+	})
+
+	It("expires backups past either limit", func() {
+		retention := greenplumv1.GreenplumBackupRetentionSpec{MaxCount: maxCount(3), MaxAge: maxAge(48 * time.Hour)}
+		Expect(ExpiredBackups(timestamps, "20200110000000", retention, now)).To(Equal([]string{"20200105000000", "20200101000000"}))
+	})
+
+	It("never expires the current backup", func() {
+		retention := greenplumv1.GreenplumBackupRetentionSpec{MaxCount: maxCount(1), MaxAge: maxAge(time.Second)}
+		Expect(ExpiredBackups(timestamps, "20200105000000", retention, now)).To(Equal([]string{"20200109000000", "20200101000000"}))
+	})
+})
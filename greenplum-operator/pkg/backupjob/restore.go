@@ -0,0 +1,218 @@
+package backupjob
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RestorePluginConfigPath is where gprestore_job.sh writes the plugin configuration on the master. It differs
+// from PluginConfigPath so that a restore does not clobber the configuration of a backup running at the same time
+const RestorePluginConfigPath = "/tmp/gprestore_plugin_config.yaml"
+
+// ErrBackupNotFound is returned when the S3 folder holds no backup with the requested timestamp
+var ErrBackupNotFound = errors.New("backup not found")
+
+// gpbackup names the data files of each segment gpbackup_<content>_<timestamp>[_<oid>][.gz], while the
+// metadata files of the master are named gpbackup_<timestamp>_<kind>
+var dataFileRegexp = regexp.MustCompile(`^gpbackup_([0-9]+)_([0-9]{14})(?:[_.]|$)`)
+
+// GenerateS3RestoreJob returns a job that runs gprestore on the master at hostname, restoring the backup from S3
+// with gpbackup_s3_plugin. databaseExists says whether the cluster already has the database restored into; it is
+// dropped first, or only the restored schemas or tables are, when spec.allowOverwrite is set
+func GenerateS3RestoreJob(image, hostname string, spec greenplumv1.GreenplumRestoreSpec, databaseExists bool) (job batchv1.Job) {
+	if spec.PluginImage != "" {
+		image = spec.PluginImage
+	}
+
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	restorePod := &job.Spec.Template.Spec
+	restorePod.RestartPolicy = corev1.RestartPolicyNever
+	restorePod.Volumes = []corev1.Volume{
+		{
+			Name: "ssh-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  "ssh-secrets",
+					DefaultMode: heapvalue.NewInt32(0444),
+				},
+			},
+		},
+	}
+	restorePod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+	restorePod.Containers = []corev1.Container{
+		{
+			Name:  "gprestore",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gprestore_job.sh",
+			},
+			Args:            gprestoreArgs(spec, databaseExists),
+			Env:             gprestoreEnv(hostname, spec, databaseExists),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ssh-key",
+					ReadOnly:  false,
+					MountPath: "/etc/ssh-key",
+				},
+			},
+		},
+	}
+	if spec.CABundle != nil {
+		restorePod.Volumes = append(restorePod.Volumes, cabundle.Volume(*spec.CABundle))
+		restoreContainer := &restorePod.Containers[0]
+		restoreContainer.VolumeMounts = append(restoreContainer.VolumeMounts, cabundle.VolumeMount())
+	}
+	if spec.S3.Encryption != nil {
+		restorePod.Volumes = append(restorePod.Volumes, encryptionKeyVolume(*spec.S3.Encryption))
+		restoreContainer := &restorePod.Containers[0]
+		restoreContainer.VolumeMounts = append(restoreContainer.VolumeMounts, encryptionKeyVolumeMount())
+	}
+	return
+}
+
+// RestoreDatabase is the database that a GreenplumRestore restores into
+func RestoreDatabase(spec greenplumv1.GreenplumRestoreSpec) string {
+	if spec.Database == "" {
+		return defaultDatabase
+	}
+	return spec.Database
+}
+
+func gprestoreArgs(spec greenplumv1.GreenplumRestoreSpec, databaseExists bool) []string {
+	args := []string{
+		"--timestamp", spec.Timestamp,
+		"--redirect-db", RestoreDatabase(spec),
+		"--plugin-config", RestorePluginConfigPath,
+	}
+	if !databaseExists || dropsDatabase(spec) {
+		args = append(args, "--create-db")
+	}
+	for _, schema := range spec.IncludeSchemas {
+		args = append(args, "--include-schema", schema)
+	}
+	for _, table := range spec.IncludeTables {
+		args = append(args, "--include-table", table)
+	}
+	if spec.WithStats {
+		args = append(args, "--with-stats")
+	}
+	if spec.Jobs != 0 {
+		args = append(args, "--jobs", strconv.Itoa(int(spec.Jobs)))
+	}
+	if spec.OnErrorContinue {
+		args = append(args, "--on-error-continue")
+	}
+	return args
+}
+
+func gprestoreEnv(hostname string, spec greenplumv1.GreenplumRestoreSpec, databaseExists bool) []corev1.EnvVar {
+	// ValidateRestoreSpec checks the plugin, so rendering its config cannot fail
+	pluginConfig, _ := PluginConfig(*S3PluginSpec(spec.S3))
+	env := []corev1.EnvVar{
+		{Name: "GPBACKUP_HOST", Value: hostname},
+		{Name: "GPBACKUP_PLUGIN_CONFIG", Value: pluginConfig},
+	}
+	env = append(env, S3CredentialsEnv(spec.S3.Credentials)...)
+	env = append(env, corev1.EnvVar{Name: "GPRESTORE_DATABASE", Value: RestoreDatabase(spec)})
+	if !databaseExists || !spec.AllowOverwrite {
+		return env
+	}
+	if dropsDatabase(spec) {
+		return append(env, corev1.EnvVar{Name: "GPRESTORE_DROP_DATABASE", Value: "true"})
+	}
+	return append(env, corev1.EnvVar{Name: "GPRESTORE_DROP_SQL", Value: dropSQL(spec)})
+}
+
+// dropsDatabase is true when an overwrite replaces the whole database
+func dropsDatabase(spec greenplumv1.GreenplumRestoreSpec) bool {
+	return spec.AllowOverwrite && len(spec.IncludeSchemas) == 0 && len(spec.IncludeTables) == 0
+}
+
+// dropSQL drops the schemas or tables that a filtered restore overwrites, leaving the rest of the database alone
+func dropSQL(spec greenplumv1.GreenplumRestoreSpec) string {
+	var statements []string
+	for _, schema := range spec.IncludeSchemas {
+		statements = append(statements, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE;", quoteIdentifier(schema)))
+	}
+	for _, table := range spec.IncludeTables {
+		// ValidateRestoreSpec checks that tables are in the form <schema>.<table>
+		schema, name, _ := strings.Cut(table, ".")
+		statements = append(statements, fmt.Sprintf("DROP TABLE IF EXISTS %s.%s CASCADE;", quoteIdentifier(schema), quoteIdentifier(name)))
+	}
+	return strings.Join(statements, " ")
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func ValidateRestoreSpec(spec greenplumv1.GreenplumRestoreSpec) error {
+	if len(spec.IncludeSchemas) > 0 && len(spec.IncludeTables) > 0 {
+		return fmt.Errorf("includeSchemas and includeTables cannot be used together")
+	}
+	if spec.PluginImage != "" && !imageRefRegexp.MatchString(spec.PluginImage) {
+		return fmt.Errorf(`invalid pluginImage "%s": must be an image reference, e.g. registry.example.com/gpbackup-plugins:1.0`, spec.PluginImage)
+	}
+	if spec.Jobs != 0 && (spec.Jobs < 1 || spec.Jobs > maxRestoreJobs) {
+		return fmt.Errorf("invalid jobs %d: must be between 1 and %d", spec.Jobs, maxRestoreJobs)
+	}
+	if err := validatePlugin(*S3PluginSpec(spec.S3)); err != nil {
+		return err
+	}
+	if spec.CABundle != nil && spec.CABundle.ConfigMapName == "" {
+		return fmt.Errorf("caBundle configMapName must be specified")
+	}
+	if err := validateTableFilters(spec.IncludeTables, "includeTables"); err != nil {
+		return err
+	}
+	return validateSchemaFilters(spec.IncludeSchemas, "includeSchemas")
+}
+
+// BackupSegmentCount counts the segments that the backup with timestamp holds data files for. It returns 0 for a
+// backup without table data, such as a metadata-only backup, and ErrBackupNotFound if the backup is not in S3
+func BackupSegmentCount(lister S3Lister, s3 greenplumv1.GreenplumBackupS3Spec, timestamp string) (int, error) {
+	if len(timestamp) < 8 {
+		return 0, fmt.Errorf("invalid backup timestamp %q", timestamp)
+	}
+	prefix := path.Join(strings.Trim(s3.Prefix, "/"), "backups", timestamp[:8], timestamp) + "/"
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	found := false
+	contents := map[string]bool{}
+	for object := range lister.ListObjects(s3.Bucket, prefix, true, doneCh) {
+		if object.Err != nil {
+			return 0, fmt.Errorf("listing s3://%s/%s: %w", s3.Bucket, prefix, object.Err)
+		}
+		found = true
+		if match := dataFileRegexp.FindStringSubmatch(path.Base(object.Key)); match != nil && match[2] == timestamp {
+			contents[match[1]] = true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("%w: no objects under s3://%s/%s", ErrBackupNotFound, s3.Bucket, prefix)
+	}
+	return len(contents), nil
+}
+
+// RestoreError reads the last error that gprestore logged, which gprestore_job.sh writes to the termination
+// message of the gprestore container when the restore fails. It returns "" if there is none
+func RestoreError(pod corev1.Pod) string {
+	return terminationFields(pod, "gprestore")["error"]
+}
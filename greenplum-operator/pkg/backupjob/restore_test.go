@@ -0,0 +1,308 @@
+package backupjob
+
+import (
+	"errors"
+
+	"github.com/minio/minio-go/v6"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("GenerateS3RestoreJob", func() {
+	var spec greenplumv1.GreenplumRestoreSpec
+	BeforeEach(func() {
+		spec = greenplumv1.GreenplumRestoreSpec{
+			ClusterName: "my-greenplum",
+			Timestamp:   "20200102030405",
+			S3: greenplumv1.GreenplumBackupS3Spec{
+				Bucket:      "backups",
+				Prefix:      "greenplum/prod",
+				Region:      "us-east-1",
+				Credentials: greenplumv1.GreenplumBackupS3CredentialsSpec{SecretName: "s3-creds"},
+			},
+		}
+	})
+
+	It("sets properties on the job", func() {
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		restorePod := job.Spec.Template.Spec
+		Expect(restorePod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(restorePod.Volumes).To(HaveLen(1))
+		Expect(restorePod.Volumes[0].Secret.SecretName).To(Equal("ssh-secrets"))
+		Expect(restorePod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+
+		restoreContainer := restorePod.Containers[0]
+		Expect(restoreContainer.Name).To(Equal("gprestore"))
+		Expect(restoreContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(restoreContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(restoreContainer.Command).To(Equal([]string{"/home/gpadmin/tools/gprestore_job.sh"}))
+		Expect(restoreContainer.VolumeMounts[0].MountPath).To(Equal("/etc/ssh-key"))
+	})
+
+	It("runs in the plugin image when one is given", func() {
+		spec.PluginImage = "registry.example.com/gpbackup-plugins:1.0"
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.example.com/gpbackup-plugins:1.0"))
+	})
+
+	It("mounts a CA bundle into the trust store when one is given", func() {
+		spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "s3-ca", Key: "bundle.pem"}
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		restorePod := job.Spec.Template.Spec
+		Expect(restorePod.Volumes).To(ContainElement(cabundle.Volume(*spec.CABundle)))
+		Expect(restorePod.Containers[0].VolumeMounts).To(ContainElement(cabundle.VolumeMount()))
+		Expect(cabundle.VolumeMount().MountPath).To(Equal("/etc/ssl/certs/greenplum-ca-bundle.crt"))
+	})
+
+	It("restores an encrypted backup with the key from the Secret", func() {
+		spec.S3.Encryption = &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "client-side", SecretName: "backup-key", Key: "aes.key"}
+		Expect(ValidateRestoreSpec(spec)).To(Succeed())
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+			Name: "GPBACKUP_PLUGIN_CONFIG",
+			Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+				"options:\n" +
+				"  bucket: backups\n" +
+				"  encryption_mode: client-side\n" +
+				"  folder: greenplum/prod\n" +
+				"  region: us-east-1\n",
+		}))
+		restorePod := job.Spec.Template.Spec
+		Expect(restorePod.Volumes).To(ContainElement(encryptionKeyVolume(*spec.S3.Encryption)))
+		Expect(restorePod.Volumes).To(ContainElement(HaveField("Secret.Items", Equal([]corev1.KeyToPath{{Key: "aes.key", Path: "encryption.key"}}))))
+		Expect(restorePod.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      "encryption-key",
+			MountPath: "/etc/gpbackup-encryption",
+			ReadOnly:  true,
+		}))
+	})
+
+	It("restores the backup into a new gpadmin database by default", func() {
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--timestamp", "20200102030405",
+			"--redirect-db", "gpadmin",
+			"--plugin-config", "/tmp/gprestore_plugin_config.yaml",
+			"--create-db",
+		}))
+	})
+
+	It("passes the master, the plugin config and the credentials", func() {
+		spec.Database = "sales"
+		spec.S3.Credentials.AccessKeyIDKey = "id"
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0.agent.test-ns.svc.cluster.local", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "GPBACKUP_HOST", Value: "master-0.agent.test-ns.svc.cluster.local"},
+			{
+				Name: "GPBACKUP_PLUGIN_CONFIG",
+				Value: "executablepath: /usr/local/greenplum-db/bin/gpbackup_s3_plugin\n" +
+					"options:\n" +
+					"  bucket: backups\n" +
+					"  folder: greenplum/prod\n" +
+					"  region: us-east-1\n",
+			},
+			secretEnvVar("GPBACKUP_S3_ACCESS_KEY_ID", "s3-creds", "id"),
+			secretEnvVar("GPBACKUP_S3_SECRET_ACCESS_KEY", "s3-creds", "aws_secret_access_key"),
+			{Name: "GPRESTORE_DATABASE", Value: "sales"},
+		}))
+	})
+
+	It("restores only the given schemas, continuing past errors when asked to", func() {
+		spec.IncludeSchemas = []string{"tenant_a", "tenant_b"}
+		spec.OnErrorContinue = true
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, true)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--timestamp", "20200102030405",
+			"--redirect-db", "gpadmin",
+			"--plugin-config", "/tmp/gprestore_plugin_config.yaml",
+			"--include-schema", "tenant_a",
+			"--include-schema", "tenant_b",
+			"--on-error-continue",
+		}))
+	})
+
+	It("restores only the given tables", func() {
+		spec.IncludeTables = []string{"public.orders"}
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(ContainElements("--include-table", "public.orders"))
+	})
+
+	It("restores the optimizer statistics when requested", func() {
+		spec.WithStats = true
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--timestamp", "20200102030405",
+			"--redirect-db", "gpadmin",
+			"--plugin-config", "/tmp/gprestore_plugin_config.yaml",
+			"--create-db",
+			"--with-stats",
+		}))
+	})
+
+	It("restores with the requested number of parallel jobs", func() {
+		spec.Jobs = 8
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--timestamp", "20200102030405",
+			"--redirect-db", "gpadmin",
+			"--plugin-config", "/tmp/gprestore_plugin_config.yaml",
+			"--create-db",
+			"--jobs", "8",
+		}))
+	})
+
+	It("drops nothing when the database does not exist", func() {
+		spec.AllowOverwrite = true
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, false)
+		for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+			Expect(env.Name).NotTo(HavePrefix("GPRESTORE_DROP"))
+		}
+	})
+
+	It("drops and recreates an existing database to overwrite it", func() {
+		spec.AllowOverwrite = true
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, true)
+		restoreContainer := job.Spec.Template.Spec.Containers[0]
+		Expect(restoreContainer.Env).To(ContainElement(corev1.EnvVar{Name: "GPRESTORE_DROP_DATABASE", Value: "true"}))
+		Expect(restoreContainer.Args).To(ContainElement("--create-db"))
+	})
+
+	It("drops only the restored schemas to overwrite them", func() {
+		spec.AllowOverwrite = true
+		spec.IncludeSchemas = []string{"tenant_a", `odd"name`}
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, true)
+		restoreContainer := job.Spec.Template.Spec.Containers[0]
+		Expect(restoreContainer.Env).To(ContainElement(corev1.EnvVar{
+			Name:  "GPRESTORE_DROP_SQL",
+			Value: `DROP SCHEMA IF EXISTS "tenant_a" CASCADE; DROP SCHEMA IF EXISTS "odd""name" CASCADE;`,
+		}))
+		Expect(restoreContainer.Args).NotTo(ContainElement("--create-db"))
+	})
+
+	It("drops only the restored tables to overwrite them", func() {
+		spec.AllowOverwrite = true
+		spec.IncludeTables = []string{"public.orders"}
+		job := GenerateS3RestoreJob("greenplum-for-kubernetes:magic", "master-0", spec, true)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+			Name:  "GPRESTORE_DROP_SQL",
+			Value: `DROP TABLE IF EXISTS "public"."orders" CASCADE;`,
+		}))
+	})
+})
+
+var _ = Describe("ValidateRestoreSpec", func() {
+	It("accepts schema or table filters", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{IncludeSchemas: []string{"tenant_a"}})).To(Succeed())
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{IncludeTables: []string{"public.orders"}})).To(Succeed())
+	})
+
+	It("rejects schema and table filters together", func() {
+		err := ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{
+			IncludeSchemas: []string{"tenant_a"},
+			IncludeTables:  []string{"public.orders"},
+		})
+		Expect(err).To(MatchError("includeSchemas and includeTables cannot be used together"))
+	})
+
+	It("rejects malformed filters", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{IncludeTables: []string{"orders"}})).
+			To(MatchError(`invalid includeTables entry "orders": must be in the form <schema>.<table>`))
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{IncludeSchemas: []string{"a.b"}})).
+			To(MatchError(`invalid includeSchemas entry "a.b": must be a schema name of at most 63 bytes, without dots or whitespace`))
+	})
+
+	It("accepts a plugin image reference", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{PluginImage: "registry.example.com:5000/gpbackup-plugins:1.0"})).To(Succeed())
+	})
+
+	It("accepts a number of parallel jobs", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{Jobs: 64})).To(Succeed())
+	})
+
+	It("validates the range of the number of parallel jobs", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{Jobs: 65})).To(MatchError("invalid jobs 65: must be between 1 and 64"))
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{Jobs: -1})).To(MatchError("invalid jobs -1: must be between 1 and 64"))
+	})
+
+	It("validates the encryption of the backup", func() {
+		spec := greenplumv1.GreenplumRestoreSpec{S3: greenplumv1.GreenplumBackupS3Spec{
+			Encryption: &greenplumv1.GreenplumBackupEncryptionSpec{Mode: "client-side"},
+		}}
+		Expect(ValidateRestoreSpec(spec)).To(MatchError("encryption secretName must be specified"))
+	})
+
+	It("requires the ConfigMap of a CA bundle", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{CABundle: &greenplumv1.GreenplumCABundleSpec{}})).
+			To(MatchError("caBundle configMapName must be specified"))
+	})
+
+	It("rejects a malformed plugin image", func() {
+		Expect(ValidateRestoreSpec(greenplumv1.GreenplumRestoreSpec{PluginImage: "Registry/Plugins:latest!"})).
+			To(MatchError(`invalid pluginImage "Registry/Plugins:latest!": must be an image reference, e.g. registry.example.com/gpbackup-plugins:1.0`))
+	})
+})
+
+var _ = Describe("BackupSegmentCount", func() {
+	var (
+		lister *fakeS3Lister
+		s3     greenplumv1.GreenplumBackupS3Spec
+	)
+	BeforeEach(func() {
+		lister = &fakeS3Lister{}
+		s3 = greenplumv1.GreenplumBackupS3Spec{Bucket: "backups", Prefix: "greenplum/prod"}
+	})
+
+	It("counts the segments with data files", func() {
+		dir := "greenplum/prod/backups/20200102/20200102030405/"
+		lister.objects = []minio.ObjectInfo{
+			{Key: dir + "gpbackup_20200102030405_config.yaml"},
+			{Key: dir + "gpbackup_20200102030405_metadata.sql"},
+			{Key: dir + "gpbackup_0_20200102030405_16384.gz"},
+			{Key: dir + "gpbackup_0_20200102030405_16390.gz"},
+			{Key: dir + "gpbackup_1_20200102030405_16384.gz"},
+			{Key: dir + "gpbackup_2_20200102030405"},
+		}
+		Expect(BackupSegmentCount(lister, s3, "20200102030405")).To(Equal(3))
+		Expect(lister.bucket).To(Equal("backups"))
+		Expect(lister.prefix).To(Equal(dir))
+	})
+
+	It("returns 0 for a backup without data files", func() {
+		lister.objects = []minio.ObjectInfo{{Key: "greenplum/prod/backups/20200102/20200102030405/gpbackup_20200102030405_metadata.sql"}}
+		Expect(BackupSegmentCount(lister, s3, "20200102030405")).To(Equal(0))
+	})
+
+	It("returns ErrBackupNotFound when the backup is not in S3", func() {
+		_, err := BackupSegmentCount(lister, s3, "20200102030405")
+		Expect(errors.Is(err, ErrBackupNotFound)).To(BeTrue())
+		Expect(err).To(MatchError("backup not found: no objects under s3://backups/greenplum/prod/backups/20200102/20200102030405/"))
+	})
+
+	It("returns an error when listing fails", func() {
+		lister.objects = []minio.ObjectInfo{{Err: errors.New("injected error")}}
+		_, err := BackupSegmentCount(lister, s3, "20200102030405")
+		Expect(err).To(MatchError("listing s3://backups/greenplum/prod/backups/20200102/20200102030405/: injected error"))
+	})
+})
+
+var _ = Describe("RestoreError", func() {
+	It("reads the error from the termination message", func() {
+		pod := corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "gprestore",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Message: "error=Encountered 2 errors during metadata restore\n",
+			}},
+		}}}}
+		Expect(RestoreError(pod)).To(Equal("Encountered 2 errors during metadata restore"))
+	})
+
+	It("returns empty while the container is running", func() {
+		Expect(RestoreError(corev1.Pod{})).To(BeEmpty())
+	})
+})
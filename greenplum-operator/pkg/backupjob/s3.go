@@ -0,0 +1,116 @@
+package backupjob
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v6"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// S3PluginExecutablePath is where the Greenplum image installs gpbackup_s3_plugin
+	S3PluginExecutablePath = "/usr/local/greenplum-db/bin/gpbackup_s3_plugin"
+
+	DefaultS3AccessKeyIDKey     = "aws_access_key_id"
+	DefaultS3SecretAccessKeyKey = "aws_secret_access_key"
+
+	defaultS3Endpoint = "s3.amazonaws.com"
+)
+
+// S3PluginSpec configures gpbackup_s3_plugin to write to, or read from, the folder of s3, with its encryption.
+// The credentials and the encryption key are left out; the job scripts add them from the environment and the
+// mounted Secret
+func S3PluginSpec(s3 greenplumv1.GreenplumBackupS3Spec) *greenplumv1.GreenplumBackupPluginSpec {
+	pluginOptions := map[string]string{
+		"region": s3.Region,
+		"bucket": s3.Bucket,
+		"folder": s3.Prefix,
+	}
+	if s3.Endpoint != "" {
+		pluginOptions["endpoint"] = s3.Endpoint
+	}
+	return &greenplumv1.GreenplumBackupPluginSpec{
+		ExecutablePath: S3PluginExecutablePath,
+		Options:        pluginOptions,
+		Encryption:     s3.Encryption.DeepCopy(),
+	}
+}
+
+// S3CredentialsEnv passes the S3 credentials from their Secret to the job scripts
+func S3CredentialsEnv(credentials greenplumv1.GreenplumBackupS3CredentialsSpec) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		secretEnvVar("GPBACKUP_S3_ACCESS_KEY_ID", credentials.SecretName, S3AccessKeyIDKey(credentials)),
+		secretEnvVar("GPBACKUP_S3_SECRET_ACCESS_KEY", credentials.SecretName, S3SecretAccessKeyKey(credentials)),
+	}
+}
+
+func S3AccessKeyIDKey(credentials greenplumv1.GreenplumBackupS3CredentialsSpec) string {
+	if credentials.AccessKeyIDKey == "" {
+		return DefaultS3AccessKeyIDKey
+	}
+	return credentials.AccessKeyIDKey
+}
+
+func S3SecretAccessKeyKey(credentials greenplumv1.GreenplumBackupS3CredentialsSpec) string {
+	if credentials.SecretAccessKeyKey == "" {
+		return DefaultS3SecretAccessKeyKey
+	}
+	return credentials.SecretAccessKeyKey
+}
+
+func secretEnvVar(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+// S3Lister lists the objects in a bucket. *minio.Client implements it
+type S3Lister interface {
+	ListObjects(bucketName, objectPrefix string, recursive bool, doneCh <-chan struct{}) <-chan minio.ObjectInfo
+}
+
+var _ S3Lister = &minio.Client{}
+
+// NewS3Lister connects to the object store of s3 with the given credentials. An endpoint given as a URL
+// is reached over http or https according to its scheme, and over https otherwise
+func NewS3Lister(s3 greenplumv1.GreenplumBackupS3Spec, accessKeyID, secretAccessKey string) (S3Lister, error) {
+	endpoint, secure := defaultS3Endpoint, true
+	if s3.Endpoint != "" {
+		endpoint = s3.Endpoint
+		if endpointURL, err := url.Parse(s3.Endpoint); err == nil && endpointURL.Host != "" {
+			endpoint = endpointURL.Host
+			secure = endpointURL.Scheme != "http"
+		}
+	}
+	return minio.NewWithRegion(endpoint, accessKeyID, secretAccessKey, secure, s3.Region)
+}
+
+// BackupSize sums the size of the objects that gpbackup_s3_plugin wrote for the backup with timestamp,
+// which it keeps under <prefix>/backups/<date>/<timestamp>/
+func BackupSize(lister S3Lister, s3 greenplumv1.GreenplumBackupS3Spec, timestamp string) (int64, error) {
+	if len(timestamp) < 8 {
+		return 0, fmt.Errorf("invalid backup timestamp %q", timestamp)
+	}
+	prefix := path.Join(strings.Trim(s3.Prefix, "/"), "backups", timestamp[:8], timestamp) + "/"
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	var size int64
+	for object := range lister.ListObjects(s3.Bucket, prefix, true, doneCh) {
+		if object.Err != nil {
+			return 0, fmt.Errorf("listing s3://%s/%s: %w", s3.Bucket, prefix, object.Err)
+		}
+		size += object.Size
+	}
+	return size, nil
+}
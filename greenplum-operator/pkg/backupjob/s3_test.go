@@ -0,0 +1,55 @@
+package backupjob
+
+import (
+	"errors"
+
+	"github.com/minio/minio-go/v6"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+)
+
+var _ = Describe("BackupSize", func() {
+	var (
+		lister *fakeS3Lister
+		s3     greenplumv1.GreenplumBackupS3Spec
+	)
+	BeforeEach(func() {
+		lister = &fakeS3Lister{}
+		s3 = greenplumv1.GreenplumBackupS3Spec{Bucket: "backups", Prefix: "/greenplum/prod/"}
+	})
+
+	It("sums the size of the objects of the backup", func() {
+		lister.objects = []minio.ObjectInfo{{Size: 100}, {Size: 23}}
+		Expect(BackupSize(lister, s3, "20200102030405")).To(Equal(int64(123)))
+		Expect(lister.bucket).To(Equal("backups"))
+		Expect(lister.prefix).To(Equal("greenplum/prod/backups/20200102/20200102030405/"))
+	})
+
+	It("returns an error when listing fails", func() {
+		lister.objects = []minio.ObjectInfo{{Size: 100}, {Err: errors.New("injected error")}}
+		_, err := BackupSize(lister, s3, "20200102030405")
+		Expect(err).To(MatchError("listing s3://backups/greenplum/prod/backups/20200102/20200102030405/: injected error"))
+	})
+
+	It("rejects a malformed timestamp", func() {
+		_, err := BackupSize(lister, s3, "2020")
+		Expect(err).To(MatchError(`invalid backup timestamp "2020"`))
+	})
+})
+
+type fakeS3Lister struct {
+	objects []minio.ObjectInfo
+	bucket  string
+	prefix  string
+}
+
+func (l *fakeS3Lister) ListObjects(bucketName, objectPrefix string, _ bool, _ <-chan struct{}) <-chan minio.ObjectInfo {
+	l.bucket, l.prefix = bucketName, objectPrefix
+	objectCh := make(chan minio.ObjectInfo, len(l.objects))
+	for _, object := range l.objects {
+		objectCh <- object
+	}
+	close(objectCh)
+	return objectCh
+}
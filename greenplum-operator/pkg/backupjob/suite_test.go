@@ -0,0 +1,13 @@
+package backupjob
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBackupjob(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "backupjob Suite")
+}
@@ -0,0 +1,113 @@
+package cabundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultKey = "ca.crt"
+
+	// MountPath is in the system certificate directory, where TLS clients such as the gpbackup
+	// storage plugins load every certificate file from, alongside the system CAs
+	MountPath = "/etc/ssl/certs/greenplum-ca-bundle.crt"
+
+	volumeName = "ca-bundle"
+	fileName   = "greenplum-ca-bundle.crt"
+)
+
+func key(spec greenplumv1.GreenplumCABundleSpec) string {
+	if spec.Key == "" {
+		return DefaultKey
+	}
+	return spec.Key
+}
+
+// Volume projects the bundle from its ConfigMap into a volume for VolumeMount
+func Volume(spec greenplumv1.GreenplumCABundleSpec) corev1.Volume {
+	return corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: spec.ConfigMapName,
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  key(spec),
+						Path: fileName,
+					},
+				},
+				DefaultMode: heapvalue.NewInt32(0444),
+			},
+		},
+	}
+}
+
+// VolumeMount mounts just the bundle file, so that the rest of the system certificate directory is left in place
+func VolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: MountPath,
+		SubPath:   fileName,
+		ReadOnly:  true,
+	}
+}
+
+// ValidateConfigMap checks that the ConfigMap referenced by spec exists in namespace and holds a valid bundle
+func ValidateConfigMap(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumCABundleSpec) error {
+	var configMap corev1.ConfigMap
+	configMapKey := types.NamespacedName{Namespace: namespace, Name: spec.ConfigMapName}
+	if err := reader.Get(ctx, configMapKey, &configMap); err != nil {
+		if apierrs.IsNotFound(err) {
+			return fmt.Errorf(`ConfigMap "%s" not found`, spec.ConfigMapName)
+		}
+		return fmt.Errorf(`getting ConfigMap "%s": %w`, spec.ConfigMapName, err)
+	}
+	bundle, ok := configMap.Data[key(spec)]
+	if !ok {
+		return fmt.Errorf(`ConfigMap "%s" has no key "%s"`, spec.ConfigMapName, key(spec))
+	}
+	if err := Validate([]byte(bundle)); err != nil {
+		return fmt.Errorf(`ConfigMap "%s" key "%s": %w`, spec.ConfigMapName, key(spec), err)
+	}
+	return nil
+}
+
+// Validate checks that bundle is made up of one or more PEM-encoded X.509 certificates
+func Validate(bundle []byte) error {
+	rest := bundle
+	certificateCount := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected %s PEM block: must only contain certificates", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("invalid certificate: %w", err)
+		}
+		certificateCount++
+	}
+	if certificateCount == 0 {
+		return errors.New("no PEM-encoded certificates found")
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return errors.New("unexpected data after the last certificate")
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package cabundle_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCabundle(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "cabundle Suite")
+}
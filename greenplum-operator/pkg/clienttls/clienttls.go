@@ -0,0 +1,141 @@
+package clienttls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	CertificateKey = "server.crt"
+	PrivateKeyKey  = "server.key"
+	CAKey          = "ca.crt"
+
+	// MountPath is where the Secret is mounted in master pods
+	MountPath = "/etc/greenplum-tls"
+	// InstallDir is on the master data volume. The operator copies the Secret there, since Postgres refuses a key
+	// that is readable by anyone but gpadmin, and the ssl GUCs point at it
+	InstallDir = "/greenplum/tls"
+
+	// ExpiryWarningPeriod is how long before the certificate expires the TLSCertificateExpiring condition turns True
+	ExpiryWarningPeriod = 30 * 24 * time.Hour
+
+	volumeName = "greenplum-tls"
+)
+
+// GUC is a GUC that the operator sets on the master for TLS
+type GUC struct {
+	Name  string
+	Value string
+}
+
+// GUCs lists the GUCs that enable TLS on the master. ssl_ca_file is only set when the Secret has a CA,
+// and interconnect TLS has not already pointed it at the interconnect CA
+func GUCs(withCA bool) []GUC {
+	gucs := []GUC{
+		{Name: "ssl", Value: "on"},
+		{Name: "ssl_cert_file", Value: InstallDir + "/" + CertificateKey},
+		{Name: "ssl_key_file", Value: InstallDir + "/" + PrivateKeyKey},
+	}
+	if withCA {
+		gucs = append(gucs, GUC{Name: "ssl_ca_file", Value: InstallDir + "/" + CAKey})
+	}
+	return gucs
+}
+
+// MasterDNSName is the name of the greenplum Service, which clients connect to the master with
+func MasterDNSName(namespace string) string {
+	return fmt.Sprintf("greenplum.%s.svc.cluster.local", namespace)
+}
+
+// Volume projects the Secret for VolumeMount
+func Volume(spec greenplumv1.GreenplumTLSSpec) corev1.Volume {
+	return corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  spec.SecretName,
+				DefaultMode: heapvalue.NewInt32(0444),
+			},
+		},
+	}
+}
+
+// VolumeMount mounts the Secret, which the operator copies into InstallDir. Kubernetes updates the mounted
+// files when the Secret changes, so the operator picks up a renewed certificate without restarting the pod
+func VolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: MountPath,
+		ReadOnly:  true,
+	}
+}
+
+// GetSecret returns the certificate, key and optional CA in the Secret referenced by spec
+func GetSecret(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumTLSSpec) (certPEM, keyPEM, caPEM []byte, err error) {
+	var secret corev1.Secret
+	secretKey := types.NamespacedName{Namespace: namespace, Name: spec.SecretName}
+	if err := reader.Get(ctx, secretKey, &secret); err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil, nil, nil, fmt.Errorf(`Secret "%s" not found`, spec.SecretName)
+		}
+		return nil, nil, nil, fmt.Errorf(`getting Secret "%s": %w`, spec.SecretName, err)
+	}
+	for _, key := range []string{CertificateKey, PrivateKeyKey} {
+		if _, ok := secret.Data[key]; !ok {
+			return nil, nil, nil, fmt.Errorf(`Secret "%s" has no key "%s"`, spec.SecretName, key)
+		}
+	}
+	return secret.Data[CertificateKey], secret.Data[PrivateKeyKey], secret.Data[CAKey], nil
+}
+
+// ValidateSecret checks that the Secret referenced by spec exists in namespace and holds a certificate
+// that Validate accepts
+func ValidateSecret(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumTLSSpec, now time.Time) error {
+	certPEM, keyPEM, caPEM, err := GetSecret(ctx, reader, namespace, spec)
+	if err != nil {
+		return err
+	}
+	if _, err := Validate(certPEM, keyPEM, caPEM, MasterDNSName(namespace), now); err != nil {
+		return fmt.Errorf(`Secret "%s": %w`, spec.SecretName, err)
+	}
+	return nil
+}
+
+// Validate checks that certPEM and keyPEM are a matching certificate and key, that the certificate is valid at now
+// for dnsName, and that caPEM, if any, holds certificates. It returns when the certificate expires.
+func Validate(certPEM, keyPEM, caPEM []byte, dnsName string, now time.Time) (time.Time, error) {
+	keyPair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid certificate and key: %w", err)
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid certificate: %w", err)
+	}
+	if err := cert.VerifyHostname(dnsName); err != nil {
+		return cert.NotAfter, fmt.Errorf("certificate is not valid for %s", dnsName)
+	}
+	if now.After(cert.NotAfter) {
+		return cert.NotAfter, fmt.Errorf("certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if now.Before(cert.NotBefore) {
+		return cert.NotAfter, fmt.Errorf("certificate is not valid until %s", cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if caPEM != nil {
+		if err := cabundle.Validate(caPEM); err != nil {
+			return cert.NotAfter, fmt.Errorf("invalid %s: %w", CAKey, err)
+		}
+	}
+	return cert.NotAfter, nil
+}
@@ -0,0 +1,13 @@
+package clienttls_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestClienttls(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "clienttls Suite")
+}
@@ -0,0 +1,150 @@
+package clienttls_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	cabundletesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle/testing"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls"
+	clienttlstesting "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls/testing"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const masterDNSName = "greenplum.test-ns.svc.cluster.local"
+
+var _ = Describe("GUCs", func() {
+	It("points the ssl GUCs at the installed certificate and key", func() {
+		Expect(clienttls.GUCs(false)).To(Equal([]clienttls.GUC{
+			{Name: "ssl", Value: "on"},
+			{Name: "ssl_cert_file", Value: "/greenplum/tls/server.crt"},
+			{Name: "ssl_key_file", Value: "/greenplum/tls/server.key"},
+		}))
+	})
+	It("sets ssl_ca_file when there is a CA", func() {
+		Expect(clienttls.GUCs(true)).To(ContainElement(clienttls.GUC{Name: "ssl_ca_file", Value: "/greenplum/tls/ca.crt"}))
+	})
+})
+
+var _ = Describe("Volume", func() {
+	It("projects the Secret", func() {
+		volume := clienttls.Volume(greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"})
+		Expect(volume.Name).To(Equal("greenplum-tls"))
+		Expect(volume.Secret).NotTo(BeNil())
+		Expect(volume.Secret.SecretName).To(Equal("greenplum-server-tls"))
+	})
+	It("is mounted read-only", func() {
+		Expect(clienttls.VolumeMount()).To(Equal(corev1.VolumeMount{
+			Name:      "greenplum-tls",
+			MountPath: "/etc/greenplum-tls",
+			ReadOnly:  true,
+		}))
+	})
+})
+
+var _ = Describe("Validate", func() {
+	var now time.Time
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	It("accepts a certificate for the master Service and returns its expiry", func() {
+		notAfter := now.Add(90 * 24 * time.Hour).Truncate(time.Second)
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{"greenplum", masterDNSName}, now.Add(-time.Hour), notAfter)
+		expiry, err := clienttls.Validate(certPEM, keyPEM, nil, masterDNSName, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expiry).To(BeTemporally("==", notAfter))
+	})
+	It("accepts a wildcard certificate", func() {
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{"*.test-ns.svc.cluster.local"}, now.Add(-time.Hour), now.Add(time.Hour))
+		_, err := clienttls.Validate(certPEM, keyPEM, nil, masterDNSName, now)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("accepts a CA", func() {
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{masterDNSName}, now.Add(-time.Hour), now.Add(time.Hour))
+		_, err := clienttls.Validate(certPEM, keyPEM, cabundletesting.SelfSignedCAPEM("client-ca"), masterDNSName, now)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("rejects a certificate for another name", func() {
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{"greenplum.other-ns.svc.cluster.local"}, now.Add(-time.Hour), now.Add(time.Hour))
+		_, err := clienttls.Validate(certPEM, keyPEM, nil, masterDNSName, now)
+		Expect(err).To(MatchError("certificate is not valid for greenplum.test-ns.svc.cluster.local"))
+	})
+	It("rejects a key that does not match the certificate", func() {
+		certPEM, _ := clienttlstesting.ServerCertificatePEM([]string{masterDNSName}, now.Add(-time.Hour), now.Add(time.Hour))
+		_, otherKeyPEM := clienttlstesting.ServerCertificatePEM([]string{masterDNSName}, now.Add(-time.Hour), now.Add(time.Hour))
+		_, err := clienttls.Validate(certPEM, otherKeyPEM, nil, masterDNSName, now)
+		Expect(err).To(MatchError(ContainSubstring("invalid certificate and key: ")))
+	})
+	It("rejects an expired certificate", func() {
+		notAfter := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{masterDNSName}, notAfter.Add(-time.Hour), notAfter)
+		expiry, err := clienttls.Validate(certPEM, keyPEM, nil, masterDNSName, now)
+		Expect(err).To(MatchError("certificate expired at 2020-01-01T00:00:00Z"))
+		Expect(expiry).To(BeTemporally("==", notAfter))
+	})
+	It("rejects an invalid CA", func() {
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{masterDNSName}, now.Add(-time.Hour), now.Add(time.Hour))
+		_, err := clienttls.Validate(certPEM, keyPEM, []byte("not a certificate"), masterDNSName, now)
+		Expect(err).To(MatchError("invalid ca.crt: no PEM-encoded certificates found"))
+	})
+})
+
+var _ = Describe("ValidateSecret", func() {
+	var (
+		ctx            context.Context
+		reactiveClient *reactive.Client
+		spec           greenplumv1.GreenplumTLSSpec
+		secret         *corev1.Secret
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		spec = greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"}
+		certPEM, keyPEM := clienttlstesting.ServerCertificatePEM([]string{masterDNSName}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "greenplum-server-tls"},
+			Data:       map[string][]byte{"server.crt": certPEM, "server.key": keyPEM},
+		}
+	})
+	JustBeforeEach(func() {
+		if secret != nil {
+			Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+		}
+	})
+
+	It("accepts a Secret holding a valid certificate", func() {
+		Expect(clienttls.ValidateSecret(ctx, reactiveClient, "test-ns", spec, time.Now())).To(Succeed())
+	})
+	When("the Secret does not exist", func() {
+		BeforeEach(func() {
+			secret = nil
+		})
+		It("returns an error", func() {
+			Expect(clienttls.ValidateSecret(ctx, reactiveClient, "test-ns", spec, time.Now())).To(MatchError(`Secret "greenplum-server-tls" not found`))
+		})
+	})
+	When("the Secret has no key", func() {
+		BeforeEach(func() {
+			delete(secret.Data, "server.key")
+		})
+		It("returns an error", func() {
+			Expect(clienttls.ValidateSecret(ctx, reactiveClient, "test-ns", spec, time.Now())).To(MatchError(`Secret "greenplum-server-tls" has no key "server.key"`))
+		})
+	})
+	When("the certificate is for another name", func() {
+		BeforeEach(func() {
+			secret.Data["server.crt"], secret.Data["server.key"] = clienttlstesting.ServerCertificatePEM(
+				[]string{"greenplum.other-ns.svc.cluster.local"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		})
+		It("returns an error", func() {
+			Expect(clienttls.ValidateSecret(ctx, reactiveClient, "test-ns", spec, time.Now())).To(MatchError(`Secret "greenplum-server-tls": certificate is not valid for greenplum.test-ns.svc.cluster.local`))
+		})
+	})
+})
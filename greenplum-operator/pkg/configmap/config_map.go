@@ -2,6 +2,8 @@ package configmap
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
@@ -15,18 +17,81 @@ const (
 	HostBasedAuthentication = "hostBasedAuthentication"
 	GUCs                    = "GUCs"
 	PXFServiceName          = "pxfServiceName"
+	DataDirectoryUmask      = "dataDirectoryUmask"
+	TempTablespace          = "tempTablespace"
+	ArrayName               = "arrayName"
+	PgStatStatements        = "pgStatStatements"
+	RoleSettings            = "roleSettings"
+	Extensions              = "extensions"
+	ResourceQueues          = "resourceQueues"
+	FencedMaster            = "fencedMaster"
 )
 
+// TempTablespaceName is the tablespace created in spec.tempTablespace when the cluster is initialized
+const TempTablespaceName = "temp_tablespace"
+
+// Resource groups are enabled unless gp_resource_manager is set to queue
+var defaultGUCs = []struct{ name, value string }{
+	{"gp_resource_manager", "group"},
+	{"gp_resource_group_memory_limit", "1.0"},
+}
+
+// interconnectTLSGUCs point the server at the pod certificate, which the container copies into the default
+// libpq client location, so the master also presents it when it connects to the segments
+var interconnectTLSGUCs = []string{
+	"ssl = on",
+	"ssl_cert_file = '/home/gpadmin/.postgresql/postgresql.crt'",
+	"ssl_key_file = '/home/gpadmin/.postgresql/postgresql.key'",
+	"ssl_ca_file = '/home/gpadmin/.postgresql/root.crt'",
+}
+
+// RestartGUCs only take effect when Greenplum is restarted. The operator writes changes to them in spec.config.gucs
+// with gpconfig and then restarts the pods; it applies changes to any other GUC with gpconfig and a configuration reload.
+var RestartGUCs = map[string]bool{
+	"gp_enable_global_deadlock_detector": true,
+	"gp_interconnect_type":               true,
+	"gp_resource_group_cpu_limit":        true,
+	"gp_resource_group_memory_limit":     true,
+	"gp_resqueue_priority":               true,
+	"gp_vmem_protect_limit":              true,
+	"gp_workfile_limit_per_segment":      true,
+	"max_connections":                    true,
+	"max_locks_per_transaction":          true,
+	"max_prepared_transactions":          true,
+	"max_resource_queues":                true,
+	"shared_buffers":                     true,
+	"wal_buffers":                        true,
+}
+
+// InitOnlyGUCs cannot be changed in spec.config.gucs after the cluster has been created:
+// the resource groups or queues were created for the resource manager chosen at init
+var InitOnlyGUCs = map[string]bool{
+	"gp_resource_manager": true,
+}
+
+// OperatorGUCs are set by the operator itself, from other fields of the spec, and cannot be set in spec.config.gucs
+var OperatorGUCs = map[string]bool{
+	"listen_addresses":         true,
+	"port":                     true,
+	"shared_preload_libraries": true,
+	"ssl":                      true,
+	"ssl_ca_file":              true,
+	"ssl_cert_file":            true,
+	"ssl_key_file":             true,
+	"temp_tablespaces":         true,
+}
+
 func ModifyConfigMap(cluster *greenplumv1.GreenplumCluster, config *corev1.ConfigMap) {
 	segmentCount := cluster.Spec.Segments.PrimarySegmentCount
 	mirrors := cluster.Spec.Segments.Mirrors == "yes"
 	standby := cluster.Spec.MasterAndStandby.Standby == "yes"
 
-	gucsList := []string{
-		"gp_resource_manager = group",
-		"gp_resource_group_memory_limit = 1.0",
+	var tempTablespaceLocation string
+	if hasTempTablespace(cluster) {
+		tempTablespaceLocation = greenplumv1.TempTablespaceLocation
 	}
-	gucs := strings.Join(gucsList, "\n")
+	pgStatStatements := strings.EqualFold(cluster.Spec.Config.PgStatStatements, "yes")
+	gucs := strings.Join(gucLines(cluster), "\n")
 
 	labels := map[string]string{
 		"app":               greenplumv1.AppName,
@@ -45,5 +110,130 @@ func ModifyConfigMap(cluster *greenplumv1.GreenplumCluster, config *corev1.Confi
 		HostBasedAuthentication: cluster.Spec.MasterAndStandby.HostBasedAuthentication,
 		GUCs:                    gucs,
 		PXFServiceName:          cluster.Spec.PXF.ServiceName,
+		DataDirectoryUmask:      cluster.Spec.Config.DataDirectoryUmask,
+		TempTablespace:          tempTablespaceLocation,
+		ArrayName:               cluster.Spec.Config.ArrayName,
+		PgStatStatements:        fmt.Sprint(pgStatStatements),
+		RoleSettings:            strings.Join(RoleSettingStatements(cluster.Spec.Config.RoleSettings), "\n"),
+		Extensions:              strings.Join(cluster.Spec.Config.Extensions, "\n"),
+		ResourceQueues:          strings.Join(ResourceQueueStatements(cluster.Spec.Config.ResourceQueues), "\n"),
+		FencedMaster:            cluster.Status.FencedMaster,
+	}
+}
+
+// hasTempTablespace reports whether the pods have a volume for the temp tablespace, either a PV or the spill volume
+func hasTempTablespace(cluster *greenplumv1.GreenplumCluster) bool {
+	return cluster.Spec.TempTablespace != nil || cluster.Spec.SpillVolume != nil
+}
+
+// gucLines renders the GUCs set when the cluster is initialized as postgresql.conf lines
+func gucLines(cluster *greenplumv1.GreenplumCluster) []string {
+	var gucsList []string
+	for _, defaultGUC := range defaultGUCs {
+		// user-specified GUCs take the place of our defaults
+		if _, ok := cluster.Spec.Config.GUCs[defaultGUC.name]; ok {
+			continue
+		}
+		if strings.HasPrefix(defaultGUC.name, "gp_resource_group_") && !ResourceGroupsEnabled(cluster.Spec.Config.GUCs) {
+			continue
+		}
+		gucsList = append(gucsList, defaultGUC.name+" = "+defaultGUC.value)
+	}
+	if hasTempTablespace(cluster) {
+		gucsList = append(gucsList, "temp_tablespaces = "+TempTablespaceName)
+	}
+	if strings.EqualFold(cluster.Spec.Config.PgStatStatements, "yes") {
+		gucsList = append(gucsList, "shared_preload_libraries = pg_stat_statements")
+	}
+	if cluster.Spec.InterconnectTLS != nil {
+		gucsList = append(gucsList, interconnectTLSGUCs...)
+	}
+	return append(gucsList, formatGUCs(cluster.Spec.Config.GUCs)...)
+}
+
+// GUCNames lists the GUCs that the operator sets for cluster, sorted by name
+func GUCNames(cluster *greenplumv1.GreenplumCluster) []string {
+	var names []string
+	for _, line := range gucLines(cluster) {
+		names = append(names, strings.SplitN(line, " = ", 2)[0])
+	}
+	sort.Strings(names)
+	return names
+}
+
+var unquotedGUCValue = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// formatGUCs renders user-specified GUCs as postgresql.conf lines, sorted by name
+// so that the configmap does not change between reconciles.
+func formatGUCs(gucs map[string]string) []string {
+	names := make([]string, 0, len(gucs))
+	for name := range gucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, name+" = "+FormatGUCValue(gucs[name]))
+	}
+	return lines
+}
+
+// FormatGUCValue renders a GUC value the way postgresql.conf and gpconfig expect it: quoted,
+// with embedded quotes doubled, unless it is a plain word or number
+func FormatGUCValue(value string) string {
+	if unquotedGUCValue.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// RoleSettingStatements renders spec.config.roleSettings as ALTER ROLE ... SET statements, sorted by
+// role and then parameter. Role names, parameter names and values are validated by the admission webhook,
+// so values are passed through as written, e.g. a search_path of "$user", public
+func RoleSettingStatements(roleSettings map[string]map[string]string) []string {
+	roles := make([]string, 0, len(roleSettings))
+	for role := range roleSettings {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	var statements []string
+	for _, role := range roles {
+		parameters := make([]string, 0, len(roleSettings[role]))
+		for parameter := range roleSettings[role] {
+			parameters = append(parameters, parameter)
+		}
+		sort.Strings(parameters)
+		for _, parameter := range parameters {
+			statements = append(statements, fmt.Sprintf(`ALTER ROLE "%s" SET %s = %s`, role, parameter, roleSettings[role][parameter]))
+		}
+	}
+	return statements
+}
+
+// ResourceGroupsEnabled reports whether gucs leave resource groups as the resource manager, rather than resource queues
+func ResourceGroupsEnabled(gucs map[string]string) bool {
+	resourceManager, ok := gucs["gp_resource_manager"]
+	return !ok || !strings.EqualFold(resourceManager, "queue")
+}
+
+// ResourceQueueStatements renders spec.config.resourceQueues as CREATE RESOURCE QUEUE statements, each followed by
+// CREATE ROLE statements for the roles in the queue. Names and limits are validated by the admission webhook
+func ResourceQueueStatements(resourceQueues []greenplumv1.GreenplumResourceQueueSpec) []string {
+	var statements []string
+	for _, queue := range resourceQueues {
+		options := []string{fmt.Sprintf("ACTIVE_STATEMENTS=%d", queue.ActiveStatements)}
+		if queue.MemoryLimit != "" {
+			options = append(options, fmt.Sprintf("MEMORY_LIMIT='%s'", queue.MemoryLimit))
+		}
+		if queue.Priority != "" {
+			options = append(options, "PRIORITY="+queue.Priority)
+		}
+		statements = append(statements, fmt.Sprintf(`CREATE RESOURCE QUEUE "%s" WITH (%s)`, queue.Name, strings.Join(options, ", ")))
+		for _, role := range queue.Roles {
+			statements = append(statements, fmt.Sprintf(`CREATE ROLE "%s" RESOURCE QUEUE "%s"`, role, queue.Name))
+		}
 	}
+	return statements
 }
@@ -72,4 +72,262 @@ var _ = Describe("GreenplumCluster configmap spec", func() {
 		Expect(configMap.ObjectMeta.Labels["greenplum-cluster"]).To(Equal("my-test-cluster-name"))
 
 	})
+	It("leaves dataDirectoryUmask empty by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.DataDirectoryUmask, ""))
+	})
+	It("leaves arrayName empty by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.ArrayName, ""))
+	})
+	It("leaves fencedMaster empty by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.FencedMaster, ""))
+	})
+	When("autoFailover has fenced a master", func() {
+		BeforeEach(func() {
+			cluster.Status.FencedMaster = "master-0"
+		})
+		It("sets fencedMaster, so that the pod replacing it does not start Greenplum", func() {
+			Expect(configMap.Data[configmap.FencedMaster]).To(Equal("master-0"))
+		})
+	})
+	It("does not preload pg_stat_statements by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.PgStatStatements, "false"))
+		Expect(configMap.Data[configmap.GUCs]).NotTo(ContainSubstring("shared_preload_libraries"))
+	})
+	When("pgStatStatements is enabled", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.PgStatStatements = "yes"
+			cluster.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+		})
+		It("sets pgStatStatements", func() {
+			Expect(configMap.Data[configmap.PgStatStatements]).To(Equal("true"))
+		})
+		It("preloads pg_stat_statements at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = group\n" +
+				"gp_resource_group_memory_limit = 1.0\n" +
+				"shared_preload_libraries = pg_stat_statements\n" +
+				"optimizer = off"))
+		})
+	})
+	When("interconnectTLS is enabled", func() {
+		BeforeEach(func() {
+			cluster.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{}
+		})
+		It("enables SSL with the pod certificate at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = group\n" +
+				"gp_resource_group_memory_limit = 1.0\n" +
+				"ssl = on\n" +
+				"ssl_cert_file = '/home/gpadmin/.postgresql/postgresql.crt'\n" +
+				"ssl_key_file = '/home/gpadmin/.postgresql/postgresql.key'\n" +
+				"ssl_ca_file = '/home/gpadmin/.postgresql/root.crt'"))
+		})
+	})
+	It("has no role settings by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.RoleSettings, ""))
+	})
+	When("roleSettings are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.RoleSettings = map[string]map[string]string{
+				"gpadmin": {"search_path": `"$user", public`},
+				"analyst": {"statement_timeout": "5min", "search_path": "sales, public"},
+			}
+		})
+		It("generates ALTER ROLE SET statements sorted by role and parameter", func() {
+			Expect(configMap.Data[configmap.RoleSettings]).To(Equal(`ALTER ROLE "analyst" SET search_path = sales, public` + "\n" +
+				`ALTER ROLE "analyst" SET statement_timeout = 5min` + "\n" +
+				`ALTER ROLE "gpadmin" SET search_path = "$user", public`))
+		})
+	})
+	It("has no resource queues by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.ResourceQueues, ""))
+	})
+	When("resource queues are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{"gp_resource_manager": "queue", "gp_resqueue_priority": "on"}
+			cluster.Spec.Config.ResourceQueues = []greenplumv1.GreenplumResourceQueueSpec{
+				{Name: "reporting", ActiveStatements: 5, MemoryLimit: "2GB", Priority: "LOW", Roles: []string{"analyst", "dashboard"}},
+				{Name: "etl", ActiveStatements: 2},
+			}
+		})
+		It("generates CREATE RESOURCE QUEUE statements, each followed by its roles", func() {
+			Expect(configMap.Data[configmap.ResourceQueues]).To(Equal(
+				`CREATE RESOURCE QUEUE "reporting" WITH (ACTIVE_STATEMENTS=5, MEMORY_LIMIT='2GB', PRIORITY=LOW)` + "\n" +
+					`CREATE ROLE "analyst" RESOURCE QUEUE "reporting"` + "\n" +
+					`CREATE ROLE "dashboard" RESOURCE QUEUE "reporting"` + "\n" +
+					`CREATE RESOURCE QUEUE "etl" WITH (ACTIVE_STATEMENTS=2)`))
+		})
+		It("does not set the default resource group memory limit", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = queue\n" +
+				"gp_resqueue_priority = on"))
+		})
+	})
+	It("has no extensions by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.Extensions, ""))
+	})
+	When("extensions are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.Extensions = []string{"pgcrypto", "uuid-ossp"}
+		})
+		It("lists them one per line", func() {
+			Expect(configMap.Data[configmap.Extensions]).To(Equal("pgcrypto\nuuid-ossp"))
+		})
+	})
+	When("arrayName is configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.ArrayName = "Analytics Warehouse"
+		})
+		It("sets arrayName", func() {
+			Expect(configMap.Data[configmap.ArrayName]).To(Equal("Analytics Warehouse"))
+		})
+	})
+	When("dataDirectoryUmask is configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.DataDirectoryUmask = "0027"
+		})
+		It("sets dataDirectoryUmask", func() {
+			Expect(configMap.Data[configmap.DataDirectoryUmask]).To(Equal("0027"))
+		})
+	})
+	It("leaves tempTablespace empty by default", func() {
+		Expect(configMap.Data).To(HaveKeyWithValue(configmap.TempTablespace, ""))
+		Expect(configMap.Data[configmap.GUCs]).NotTo(ContainSubstring("temp_tablespaces"))
+	})
+	When("a temp tablespace is configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+				StorageClassName: "local-ssd",
+				Storage:          resource.MustParse("100G"),
+			}
+			cluster.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+		})
+		It("sets the tempTablespace location", func() {
+			Expect(configMap.Data[configmap.TempTablespace]).To(Equal("/greenplum-temp"))
+		})
+		It("sets temp_tablespaces at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = group\n" +
+				"gp_resource_group_memory_limit = 1.0\n" +
+				"temp_tablespaces = temp_tablespace\n" +
+				"optimizer = off"))
+		})
+	})
+	When("a spill volume is configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.SpillVolume = &greenplumv1.GreenplumSpillVolumeSpec{
+				SizeLimit: resource.MustParse("50Gi"),
+			}
+		})
+		It("sets the tempTablespace location", func() {
+			Expect(configMap.Data[configmap.TempTablespace]).To(Equal("/greenplum-temp"))
+		})
+		It("sets temp_tablespaces at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(ContainSubstring("temp_tablespaces = temp_tablespace"))
+		})
+	})
+	When("gucs are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{
+				"gp_workfile_limit_per_segment":     "100GB",
+				"gp_workfile_limit_per_query":       "10GB",
+				"gp_workfile_limit_files_per_query": "100000",
+			}
+		})
+		It("appends them to the default GUCs, sorted by name", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = group\n" +
+				"gp_resource_group_memory_limit = 1.0\n" +
+				"gp_workfile_limit_files_per_query = 100000\n" +
+				"gp_workfile_limit_per_query = 10GB\n" +
+				"gp_workfile_limit_per_segment = 100GB"))
+		})
+	})
+	When("resource group gucs are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{
+				"gp_resource_group_cpu_limit":    "0.8",
+				"gp_resource_group_memory_limit": "0.7",
+			}
+		})
+		It("replaces the default resource group memory limit", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = group\n" +
+				"gp_resource_group_cpu_limit = 0.8\n" +
+				"gp_resource_group_memory_limit = 0.7"))
+		})
+	})
+	When("the optimizer guc is configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{"optimizer": "off"}
+		})
+		It("is set at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(HaveSuffix("\noptimizer = off"))
+		})
+	})
+	When("the global deadlock detector is enabled", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{"gp_enable_global_deadlock_detector": "on"}
+		})
+		It("is set at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(HaveSuffix("\ngp_enable_global_deadlock_detector = on"))
+		})
+	})
+	When("the interconnect type is configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{"gp_interconnect_type": "tcp"}
+		})
+		It("is set at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(Equal("gp_resource_manager = group\n" +
+				"gp_resource_group_memory_limit = 1.0\n" +
+				"gp_interconnect_type = tcp"))
+		})
+	})
+	When("checkpoint and background writer gucs are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{
+				"checkpoint_completion_target": "0.9",
+				"bgwriter_delay":               "100ms",
+			}
+		})
+		It("sets them at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(HaveSuffix("\nbgwriter_delay = 100ms\n" +
+				"checkpoint_completion_target = 0.9"))
+		})
+	})
+	When("network timeout gucs are configured", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{
+				"gp_segment_connect_timeout":    "10min",
+				"gp_interconnect_setup_timeout": "3600",
+			}
+		})
+		It("sets them at init", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(HaveSuffix("\ngp_interconnect_setup_timeout = 3600\n" +
+				"gp_segment_connect_timeout = 10min"))
+		})
+	})
+	When("a guc value is not a simple token", func() {
+		BeforeEach(func() {
+			cluster.Spec.Config.GUCs = map[string]string{
+				"search_path": "my_schema, 'public'",
+			}
+		})
+		It("quotes the value", func() {
+			Expect(configMap.Data[configmap.GUCs]).To(HaveSuffix("\nsearch_path = 'my_schema, ''public'''"))
+		})
+	})
+})
+
+var _ = Describe("GUCNames", func() {
+	var cluster *greenplumv1.GreenplumCluster
+	BeforeEach(func() {
+		cluster = &greenplumv1.GreenplumCluster{}
+	})
+	It("lists the default GUCs", func() {
+		Expect(configmap.GUCNames(cluster)).To(Equal([]string{"gp_resource_group_memory_limit", "gp_resource_manager"}))
+	})
+	It("lists the GUCs from the spec, sorted by name", func() {
+		cluster.Spec.Config.GUCs = map[string]string{"optimizer": "off", "gp_resource_manager": "queue"}
+		cluster.Spec.Config.PgStatStatements = "yes"
+		Expect(configmap.GUCNames(cluster)).To(Equal([]string{
+			"gp_resource_manager",
+			"optimizer",
+			"shared_preload_libraries",
+		}))
+	})
 })
@@ -0,0 +1,34 @@
+package configmap
+
+import (
+	"fmt"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ConnectionHost     = "host"
+	ConnectionPort     = "port"
+	ConnectionDatabase = "database"
+)
+
+// ModifyConnectionConfigMap fills in the connection details that service discovery tools need to reach the cluster
+func ModifyConnectionConfigMap(cluster *greenplumv1.GreenplumCluster, config *corev1.ConfigMap) {
+	labels := map[string]string{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": cluster.Name,
+	}
+	if config.Labels == nil {
+		config.Labels = make(map[string]string)
+	}
+	for key, value := range labels {
+		config.Labels[key] = value
+	}
+	config.Data = map[string]string{
+		ConnectionHost:     fmt.Sprintf("greenplum.%s.svc.cluster.local", cluster.Namespace),
+		ConnectionPort:     "5432",
+		ConnectionDatabase: "gpadmin",
+		SegmentCount:       fmt.Sprint(cluster.Spec.Segments.PrimarySegmentCount),
+	}
+}
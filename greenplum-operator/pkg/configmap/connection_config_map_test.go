@@ -0,0 +1,63 @@
+package configmap_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("GreenplumCluster connection configmap", func() {
+	var (
+		configMap *corev1.ConfigMap
+		cluster   *greenplumv1.GreenplumCluster
+	)
+	BeforeEach(func() {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "greenplum-connection",
+				Namespace: "test-ns",
+			},
+			Data: map[string]string{
+				"stale": "data",
+			},
+		}
+		cluster = &greenplumv1.GreenplumCluster{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "my-test-cluster-name",
+				Namespace: "test-ns",
+			},
+			Spec: greenplumv1.GreenplumClusterSpec{
+				Segments: greenplumv1.GreenplumSegmentsSpec{
+					PrimarySegmentCount: 6,
+				},
+			},
+		}
+	})
+	JustBeforeEach(func() {
+		configmap.ModifyConnectionConfigMap(cluster, configMap)
+	})
+	It("fills in the connection details", func() {
+		Expect(configMap.Data).To(Equal(map[string]string{
+			configmap.ConnectionHost:     "greenplum.test-ns.svc.cluster.local",
+			configmap.ConnectionPort:     "5432",
+			configmap.ConnectionDatabase: "gpadmin",
+			configmap.SegmentCount:       "6",
+		}))
+	})
+	It("labels the configmap", func() {
+		Expect(configMap.Labels["app"]).To(Equal("greenplum"))
+		Expect(configMap.Labels["greenplum-cluster"]).To(Equal("my-test-cluster-name"))
+	})
+	When("the configmap already has labels", func() {
+		BeforeEach(func() {
+			configMap.Labels = map[string]string{"discovery": "enabled"}
+		})
+		It("keeps them", func() {
+			Expect(configMap.Labels).To(HaveKeyWithValue("discovery", "enabled"))
+			Expect(configMap.Labels).To(HaveKeyWithValue("app", "greenplum"))
+		})
+	})
+})
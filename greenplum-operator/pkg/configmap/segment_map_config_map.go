@@ -0,0 +1,84 @@
+package configmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const SegmentMapConfigMapName = "greenplum-segment-map"
+
+// SegmentConfigurationQuery lists the segments the same way gpstate sees them, one per line,
+// with columns in the order expected by ParseSegmentConfiguration
+const SegmentConfigurationQuery = "SELECT dbid, content, role, preferred_role, status, hostname, address, port, datadir FROM gp_segment_configuration ORDER BY dbid"
+
+// Segment is one row of gp_segment_configuration. Hostname is the name of the pod running the segment.
+type Segment struct {
+	DBID          int
+	Content       int
+	Role          string
+	PreferredRole string
+	Status        string
+	Hostname      string
+	Address       string
+	Port          int
+	DataDir       string
+}
+
+// ParseSegmentConfiguration parses the unaligned, tuples-only (psql -tA) output of SegmentConfigurationQuery
+func ParseSegmentConfiguration(output string) ([]Segment, error) {
+	var segments []Segment
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 9 {
+			return nil, fmt.Errorf("unexpected segment configuration row: %q", line)
+		}
+		var ints [3]int
+		for i, field := range []string{fields[0], fields[1], fields[7]} {
+			value, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected segment configuration row: %q", line)
+			}
+			ints[i] = value
+		}
+		segments = append(segments, Segment{
+			DBID:          ints[0],
+			Content:       ints[1],
+			Role:          fields[2],
+			PreferredRole: fields[3],
+			Status:        fields[4],
+			Hostname:      fields[5],
+			Address:       fields[6],
+			Port:          ints[2],
+			DataDir:       fields[8],
+		})
+	}
+	return segments, nil
+}
+
+// ModifySegmentMapConfigMap records which pod hosts each segment, keyed by dbid
+func ModifySegmentMapConfigMap(cluster *greenplumv1.GreenplumCluster, segments []Segment, config *corev1.ConfigMap) {
+	labels := map[string]string{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": cluster.Name,
+	}
+	if config.Labels == nil {
+		config.Labels = make(map[string]string)
+	}
+	for key, value := range labels {
+		config.Labels[key] = value
+	}
+	config.Data = make(map[string]string, len(segments))
+	for _, segment := range segments {
+		config.Data[fmt.Sprintf("dbid-%d", segment.DBID)] = fmt.Sprintf(
+			"content=%d role=%s preferredRole=%s status=%s pod=%s host=%s port=%d",
+			segment.Content, segment.Role, segment.PreferredRole, segment.Status,
+			segment.Hostname, segment.Address, segment.Port)
+	}
+}
@@ -0,0 +1,76 @@
+package configmap_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/configmap"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ParseSegmentConfiguration", func() {
+	It("parses each row", func() {
+		segments, err := configmap.ParseSegmentConfiguration(
+			"1|-1|p|p|u|master-0|master-0.agent.test-ns.svc.cluster.local|5432|/greenplum/data\n" +
+				"2|0|m|p|d|segment-a-0|segment-a-0.agent.test-ns.svc.cluster.local|40000|/greenplum/data\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(segments).To(Equal([]configmap.Segment{
+			{DBID: 1, Content: -1, Role: "p", PreferredRole: "p", Status: "u", Hostname: "master-0", Address: "master-0.agent.test-ns.svc.cluster.local", Port: 5432, DataDir: "/greenplum/data"},
+			{DBID: 2, Content: 0, Role: "m", PreferredRole: "p", Status: "d", Hostname: "segment-a-0", Address: "segment-a-0.agent.test-ns.svc.cluster.local", Port: 40000, DataDir: "/greenplum/data"},
+		}))
+	})
+	It("returns no segments for empty output", func() {
+		segments, err := configmap.ParseSegmentConfiguration("\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(segments).To(BeEmpty())
+	})
+	It("fails on a row with the wrong number of columns", func() {
+		_, err := configmap.ParseSegmentConfiguration("1|-1|p|p|u|master-0\n")
+		Expect(err).To(MatchError(`unexpected segment configuration row: "1|-1|p|p|u|master-0"`))
+	})
+	It("fails on a row with a non-numeric port", func() {
+		_, err := configmap.ParseSegmentConfiguration("1|-1|p|p|u|master-0|master-0|port|/greenplum/data\n")
+		Expect(err).To(MatchError(`unexpected segment configuration row: "1|-1|p|p|u|master-0|master-0|port|/greenplum/data"`))
+	})
+})
+
+var _ = Describe("GreenplumCluster segment map configmap", func() {
+	var (
+		configMap *corev1.ConfigMap
+		cluster   *greenplumv1.GreenplumCluster
+	)
+	BeforeEach(func() {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "greenplum-segment-map",
+				Namespace: "test-ns",
+			},
+			Data: map[string]string{
+				"stale": "data",
+			},
+		}
+		cluster = &greenplumv1.GreenplumCluster{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "my-test-cluster-name",
+				Namespace: "test-ns",
+			},
+		}
+	})
+	JustBeforeEach(func() {
+		configmap.ModifySegmentMapConfigMap(cluster, []configmap.Segment{
+			{DBID: 1, Content: -1, Role: "p", PreferredRole: "p", Status: "u", Hostname: "master-0", Address: "master-0.agent", Port: 5432},
+			{DBID: 2, Content: 0, Role: "p", PreferredRole: "p", Status: "u", Hostname: "segment-a-0", Address: "segment-a-0.agent", Port: 40000},
+		}, configMap)
+	})
+	It("maps each dbid to its pod", func() {
+		Expect(configMap.Data).To(Equal(map[string]string{
+			"dbid-1": "content=-1 role=p preferredRole=p status=u pod=master-0 host=master-0.agent port=5432",
+			"dbid-2": "content=0 role=p preferredRole=p status=u pod=segment-a-0 host=segment-a-0.agent port=40000",
+		}))
+	})
+	It("labels the configmap", func() {
+		Expect(configMap.Labels["app"]).To(Equal("greenplum"))
+		Expect(configMap.Labels["greenplum-cluster"]).To(Equal("my-test-cluster-name"))
+	})
+})
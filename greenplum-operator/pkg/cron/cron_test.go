@@ -0,0 +1,60 @@
+package cron
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parse", func() {
+	DescribeTable("rejects invalid expressions",
+		func(spec, expectedErr string) {
+			_, err := Parse(spec)
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("too few fields", "0 2 * *", `expected 5 fields (minute hour day-of-month month day-of-week), found 4 in "0 2 * *"`),
+		Entry("too many fields", "0 0 2 * * *", `expected 5 fields (minute hour day-of-month month day-of-week), found 6 in "0 0 2 * * *"`),
+		Entry("unknown macro", "@fortnightly", `expected 5 fields (minute hour day-of-month month day-of-week), found 1 in "@fortnightly"`),
+		Entry("value out of range", "60 * * * *", `value 60 out of range 0-59 in minute field "60"`),
+		Entry("day of month 0", "0 0 0 * *", `value 0 out of range 1-31 in day of month field "0"`),
+		Entry("not a number", "0 two * * *", `invalid value "two" in hour field "two"`),
+		Entry("unknown name", "0 0 * foo *", `invalid value "foo" in month field "foo"`),
+		Entry("backwards range", "0 5-1 * * *", `invalid range "5-1" in hour field "5-1"`),
+		Entry("zero step", "*/0 * * * *", `invalid step "0" in minute field "*/0"`),
+	)
+})
+
+var _ = Describe("Next", func() {
+	DescribeTable("returns the next matching time",
+		func(spec, from, expected string) {
+			schedule, err := Parse(spec)
+			Expect(err).NotTo(HaveOccurred())
+			fromTime, err := time.Parse(time.RFC3339, from)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(schedule.Next(fromTime).Format(time.RFC3339)).To(Equal(expected))
+		},
+		Entry("every minute", "* * * * *", "2020-01-02T03:04:05Z", "2020-01-02T03:05:00Z"),
+		Entry("later the same day", "30 2 * * *", "2020-01-02T01:00:00Z", "2020-01-02T02:30:00Z"),
+		Entry("the next day", "30 2 * * *", "2020-01-02T02:30:00Z", "2020-01-03T02:30:00Z"),
+		Entry("steps", "*/15 * * * *", "2020-01-02T03:16:00Z", "2020-01-02T03:30:00Z"),
+		Entry("a start value with a step", "5/20 * * * *", "2020-01-02T03:26:00Z", "2020-01-02T03:45:00Z"),
+		Entry("lists and ranges", "0 1,13-14 * * *", "2020-01-02T02:00:00Z", "2020-01-02T13:00:00Z"),
+		Entry("day of week by name", "0 0 * * sat", "2020-01-01T00:00:00Z", "2020-01-04T00:00:00Z"),
+		Entry("7 as Sunday", "0 0 * * 7", "2020-01-01T00:00:00Z", "2020-01-05T00:00:00Z"),
+		Entry("either restricted day field", "0 0 15 * mon", "2020-01-07T00:00:00Z", "2020-01-13T00:00:00Z"),
+		Entry("the next month", "0 0 1 * *", "2020-01-02T00:00:00Z", "2020-02-01T00:00:00Z"),
+		Entry("the next year", "@yearly", "2020-03-01T00:00:00Z", "2021-01-01T00:00:00Z"),
+		Entry("a leap day", "0 0 29 2 *", "2021-01-01T00:00:00Z", "2024-02-29T00:00:00Z"),
+		Entry("@hourly", "@hourly", "2020-01-02T03:04:05Z", "2020-01-02T04:00:00Z"),
+		Entry("@daily", "@daily", "2020-01-02T03:04:05Z", "2020-01-03T00:00:00Z"),
+		Entry("@weekly", "@weekly", "2020-01-02T03:04:05Z", "2020-01-05T00:00:00Z"),
+	)
+
+	It("returns the zero time when nothing matches", func() {
+		schedule, err := Parse("0 0 30 2 *")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Next(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero()).To(BeTrue())
+	})
+})
@@ -0,0 +1,90 @@
+package entrypointscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/instanceconfig"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultKey = "entrypoint.sh"
+
+	volumeName = "entrypoint-script"
+)
+
+func key(spec greenplumv1.GreenplumEntrypointScriptSpec) string {
+	if spec.Key == "" {
+		return DefaultKey
+	}
+	return spec.Key
+}
+
+// Volume projects the script from its ConfigMap into a volume for VolumeMount, executable by gpadmin
+func Volume(spec greenplumv1.GreenplumEntrypointScriptSpec) corev1.Volume {
+	return corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: spec.ConfigMapName,
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  key(spec),
+						Path: instanceconfig.EntrypointScriptFileName,
+					},
+				},
+				DefaultMode: heapvalue.NewInt32(0555),
+			},
+		},
+	}
+}
+
+// VolumeMount mounts the script where the container looks for it when it starts
+func VolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: instanceconfig.EntrypointScriptDir,
+		ReadOnly:  true,
+	}
+}
+
+// ValidateConfigMap checks that the ConfigMap referenced by spec exists in namespace and holds a script
+func ValidateConfigMap(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumEntrypointScriptSpec) error {
+	var configMap corev1.ConfigMap
+	configMapKey := types.NamespacedName{Namespace: namespace, Name: spec.ConfigMapName}
+	if err := reader.Get(ctx, configMapKey, &configMap); err != nil {
+		if apierrs.IsNotFound(err) {
+			return fmt.Errorf(`ConfigMap "%s" not found`, spec.ConfigMapName)
+		}
+		return fmt.Errorf(`getting ConfigMap "%s": %w`, spec.ConfigMapName, err)
+	}
+	script, ok := configMap.Data[key(spec)]
+	if !ok {
+		return fmt.Errorf(`ConfigMap "%s" has no key "%s"`, spec.ConfigMapName, key(spec))
+	}
+	if err := Validate(script); err != nil {
+		return fmt.Errorf(`ConfigMap "%s" key "%s": %w`, spec.ConfigMapName, key(spec), err)
+	}
+	return nil
+}
+
+// Validate checks that script names its interpreter, since the container executes it directly
+func Validate(script string) error {
+	if !strings.HasPrefix(script, "#!") {
+		return errors.New("the script must start with a #! line, e.g. #!/bin/bash")
+	}
+	if strings.Contains(strings.SplitN(script, "\n", 2)[0], "\r") {
+		return errors.New("the #! line must not end with a carriage return")
+	}
+	return nil
+}
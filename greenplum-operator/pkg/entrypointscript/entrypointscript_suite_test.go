@@ -0,0 +1,13 @@
+package entrypointscript_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestEntrypointscript(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "entrypointscript Suite")
+}
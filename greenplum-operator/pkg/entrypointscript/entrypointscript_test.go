@@ -0,0 +1,120 @@
+package entrypointscript_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/entrypointscript"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Volume", func() {
+	It("projects the default key of the ConfigMap as an executable", func() {
+		volume := entrypointscript.Volume(greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint"})
+		Expect(volume.Name).To(Equal("entrypoint-script"))
+		Expect(volume.ConfigMap).NotTo(BeNil())
+		Expect(volume.ConfigMap.Name).To(Equal("my-entrypoint"))
+		Expect(volume.ConfigMap.Items).To(Equal([]corev1.KeyToPath{{Key: "entrypoint.sh", Path: "entrypoint.sh"}}))
+		Expect(volume.ConfigMap.DefaultMode).To(Equal(heapvalue.NewInt32(0555)))
+	})
+	It("projects the given key of the ConfigMap", func() {
+		volume := entrypointscript.Volume(greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint", Key: "setup.sh"})
+		Expect(volume.ConfigMap.Items).To(Equal([]corev1.KeyToPath{{Key: "setup.sh", Path: "entrypoint.sh"}}))
+	})
+})
+
+var _ = Describe("VolumeMount", func() {
+	It("mounts the script directory read-only", func() {
+		Expect(entrypointscript.VolumeMount()).To(Equal(corev1.VolumeMount{
+			Name:      "entrypoint-script",
+			MountPath: "/etc/greenplum-entrypoint/",
+			ReadOnly:  true,
+		}))
+	})
+})
+
+var _ = Describe("Validate", func() {
+	It("accepts a script with an interpreter line", func() {
+		Expect(entrypointscript.Validate("#!/bin/bash\necho hello\n")).To(Succeed())
+	})
+	It("rejects a script without an interpreter line", func() {
+		Expect(entrypointscript.Validate("echo hello\n")).To(MatchError("the script must start with a #! line, e.g. #!/bin/bash"))
+	})
+	It("rejects an empty script", func() {
+		Expect(entrypointscript.Validate("")).To(MatchError("the script must start with a #! line, e.g. #!/bin/bash"))
+	})
+	It("rejects a script with Windows line endings", func() {
+		Expect(entrypointscript.Validate("#!/bin/bash\r\necho hello\r\n")).To(MatchError("the #! line must not end with a carriage return"))
+	})
+})
+
+var _ = Describe("ValidateConfigMap", func() {
+	var (
+		ctx            context.Context
+		reactiveClient *reactive.Client
+		spec           greenplumv1.GreenplumEntrypointScriptSpec
+		configMap      *corev1.ConfigMap
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		spec = greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint"}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-entrypoint"},
+			Data:       map[string]string{"entrypoint.sh": "#!/bin/bash\necho hello\n"},
+		}
+	})
+	JustBeforeEach(func() {
+		if configMap != nil {
+			Expect(reactiveClient.Create(ctx, configMap)).To(Succeed())
+		}
+	})
+
+	It("accepts a ConfigMap holding a valid script", func() {
+		Expect(entrypointscript.ValidateConfigMap(ctx, reactiveClient, "test-ns", spec)).To(Succeed())
+	})
+	When("the ConfigMap does not exist", func() {
+		BeforeEach(func() {
+			configMap = nil
+		})
+		It("returns an error", func() {
+			Expect(entrypointscript.ValidateConfigMap(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`ConfigMap "my-entrypoint" not found`))
+		})
+	})
+	When("getting the ConfigMap fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("get", "configmaps", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(entrypointscript.ValidateConfigMap(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`getting ConfigMap "my-entrypoint": injected error`))
+		})
+	})
+	When("the ConfigMap does not have the key", func() {
+		BeforeEach(func() {
+			spec.Key = "setup.sh"
+		})
+		It("returns an error", func() {
+			Expect(entrypointscript.ValidateConfigMap(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`ConfigMap "my-entrypoint" has no key "setup.sh"`))
+		})
+	})
+	When("the script is not valid", func() {
+		BeforeEach(func() {
+			configMap.Data["entrypoint.sh"] = "echo hello"
+		})
+		It("returns an error", func() {
+			Expect(entrypointscript.ValidateConfigMap(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`ConfigMap "my-entrypoint" key "entrypoint.sh": the script must start with a #! line, e.g. #!/bin/bash`))
+		})
+	})
+})
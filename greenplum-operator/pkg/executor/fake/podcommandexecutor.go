@@ -4,12 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 const DefaultSegmentCount = 1 // Used as the primarySegmentCount of exampleGreenplumCluster
 
+const DefaultGreenplumVersion = "6.0.0"
+
 type PodExec struct {
 	ErrorMsgOnMaster0 string
 	ErrorMsgOnMaster1 string
@@ -17,11 +20,75 @@ type PodExec struct {
 	SegmentCount    string
 	SegmentCountErr error
 
+	SegmentConfiguration    string
+	SegmentConfigurationErr error
+	// SegmentConfigurationQueries counts the queries of gp_segment_configuration
+	SegmentConfigurationQueries int
+
 	ErrorMsgOnCommand string
 	CalledPodName     string
 
 	RecordedCommands []string
 	StdoutResult     string
+
+	ReadOnlyPods []string
+	// ReadOnlyChecks counts the write checks of a data volume
+	ReadOnlyChecks int
+
+	// FullDiskPods report that their data volume has no space left
+	FullDiskPods []string
+
+	// GreenplumVersions overrides DefaultGreenplumVersion for the named pods
+	GreenplumVersions map[string]string
+
+	MirroringStatus    string
+	MirroringStatusErr error
+
+	RoleConnectionLimits    string
+	RoleConnectionLimitsErr error
+
+	AppliedGUCs    string
+	AppliedGUCsErr error
+
+	Extensions    string
+	ExtensionsErr error
+
+	// PgHbaEntries is the operator-managed block of pg_hba.conf, including its markers
+	PgHbaEntries    string
+	PgHbaEntriesErr error
+
+	// GUCValues are reported by SHOW, when set; otherwise SHOW gets StdoutResult
+	GUCValues map[string]string
+
+	// TLSCertificateChanged makes the master pods report that the TLS certificate they installed had changed
+	TLSCertificateChanged bool
+
+	// LDAPProbeErr fails the probe of the LDAP server; probes are recorded in RecordedCommands
+	LDAPProbeErr error
+
+	// MasterInRecovery makes pg_is_in_recovery() report true on the master
+	MasterInRecovery    bool
+	MasterInRecoveryErr error
+
+	// MissingDataDirectoryPods report that their segment data directory does not exist
+	MissingDataDirectoryPods []string
+	// DataDirectoryChecks counts the checks for a segment data directory
+	DataDirectoryChecks int
+
+	// BackupRunning reports a gpbackup process on the master
+	BackupRunning    bool
+	BackupRunningErr error
+
+	// RedistributionProgress is the "completed|total" count of tables in gpexpand.status_detail
+	RedistributionProgress    string
+	RedistributionProgressErr error
+
+	// Databases are the names, one per line, of the databases in pg_database
+	Databases    string
+	DatabasesErr error
+
+	// RecordedStdin holds what was streamed to each command run with ExecuteWithStdin
+	RecordedStdin []string
 }
 
 // TODO: break import cycle so we can make this assertion
@@ -42,6 +109,101 @@ func (f *PodExec) Execute(command []string, namespace, podName string, stdout, s
 		}
 		_, err := io.WriteString(stdout, segCount)
 		return err
+	case isSegmentConfigurationQuery(cmdStr):
+		f.SegmentConfigurationQueries++
+		if f.SegmentConfigurationErr != nil {
+			return f.SegmentConfigurationErr
+		}
+		_, err := io.WriteString(stdout, f.SegmentConfiguration)
+		return err
+	case isReadOnlyCheck(cmdStr):
+		return f.handleReadOnlyCheck(podName, stderr)
+	case isVersionQuery(cmdStr):
+		version, ok := f.GreenplumVersions[podName]
+		if !ok {
+			version = DefaultGreenplumVersion
+		}
+		_, err := fmt.Fprintf(stdout, "postgres (Greenplum Database) %s build commit:0123456789abcdef\n", version)
+		return err
+	case isMirroringStatusQuery(cmdStr):
+		if f.MirroringStatusErr != nil {
+			return f.MirroringStatusErr
+		}
+		_, err := io.WriteString(stdout, f.MirroringStatus)
+		return err
+	case isRoleConnectionLimitsQuery(cmdStr):
+		if f.RoleConnectionLimitsErr != nil {
+			return f.RoleConnectionLimitsErr
+		}
+		_, err := io.WriteString(stdout, f.RoleConnectionLimits)
+		return err
+	case isAppliedGUCsQuery(cmdStr):
+		if f.AppliedGUCsErr != nil {
+			return f.AppliedGUCsErr
+		}
+		_, err := io.WriteString(stdout, f.AppliedGUCs)
+		return err
+	case isExtensionsQuery(cmdStr):
+		if f.ExtensionsErr != nil {
+			return f.ExtensionsErr
+		}
+		_, err := io.WriteString(stdout, f.Extensions)
+		return err
+	case isPgHbaEntriesQuery(cmdStr):
+		if f.PgHbaEntriesErr != nil {
+			return f.PgHbaEntriesErr
+		}
+		_, err := io.WriteString(stdout, f.PgHbaEntries)
+		return err
+	case isShowQuery(cmdStr) && f.GUCValues != nil:
+		_, err := io.WriteString(stdout, f.GUCValues[showQueryName(cmdStr)]+"\n")
+		return err
+	case isTLSInstallCommand(cmdStr):
+		f.CalledPodName = podName
+		f.RecordedCommands = append(f.RecordedCommands, cmdStr)
+		if f.TLSCertificateChanged {
+			_, err := io.WriteString(stdout, "installed\n")
+			return err
+		}
+		return nil
+	case isLDAPProbe(cmdStr):
+		f.CalledPodName = podName
+		f.RecordedCommands = append(f.RecordedCommands, cmdStr)
+		return f.LDAPProbeErr
+	case isRecoveryQuery(cmdStr):
+		if f.MasterInRecoveryErr != nil {
+			return f.MasterInRecoveryErr
+		}
+		if f.MasterInRecovery {
+			_, err := io.WriteString(stdout, "t\n")
+			return err
+		}
+		_, err := io.WriteString(stdout, "f\n")
+		return err
+	case isDataDirectoryCheck(cmdStr):
+		return f.handleDataDirectoryCheck(podName, stdout)
+	case isBackupRunningCheck(cmdStr):
+		if f.BackupRunningErr != nil {
+			return f.BackupRunningErr
+		}
+		if f.BackupRunning {
+			_, err := io.WriteString(stdout, "running\n")
+			return err
+		}
+		_, err := io.WriteString(stdout, "idle\n")
+		return err
+	case isRedistributionProgressQuery(cmdStr):
+		if f.RedistributionProgressErr != nil {
+			return f.RedistributionProgressErr
+		}
+		_, err := io.WriteString(stdout, f.RedistributionProgress)
+		return err
+	case isDatabasesQuery(cmdStr):
+		if f.DatabasesErr != nil {
+			return f.DatabasesErr
+		}
+		_, err := io.WriteString(stdout, f.Databases)
+		return err
 	case f.ErrorMsgOnCommand != "":
 		f.CalledPodName = podName
 		fmt.Fprintf(stderr, f.ErrorMsgOnCommand)
@@ -54,10 +216,89 @@ func (f *PodExec) Execute(command []string, namespace, podName string, stdout, s
 	}
 }
 
+func (f *PodExec) ExecuteWithStdin(command []string, namespace, podName string, stdin io.Reader, stdout, stderr io.Writer) error {
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	f.RecordedStdin = append(f.RecordedStdin, string(input))
+	return f.Execute(command, namespace, podName, stdout, stderr)
+}
+
 func isSegmentCountQuery(cmdStr string) bool {
 	return strings.Contains(cmdStr, "SELECT COUNT(*) FROM gp_segment_configuration")
 }
 
+func isSegmentConfigurationQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "FROM gp_segment_configuration ORDER BY dbid")
+}
+
+func isReadOnlyCheck(cmdStr string) bool {
+	return strings.Contains(cmdStr, "touch /greenplum/.operator-rw-check")
+}
+
+func isVersionQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "postgres --gp-version")
+}
+
+func isMirroringStatusQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "gpstate -e")
+}
+
+func isRoleConnectionLimitsQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "FROM pg_roles")
+}
+
+func isAppliedGUCsQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "FROM pg_settings")
+}
+
+func isExtensionsQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "FROM pg_extension")
+}
+
+func isPgHbaEntriesQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "sed -n '/^# BEGIN greenplum-operator pgHbaEntries$/")
+}
+
+var showQuery = regexp.MustCompile(`-tAc "SHOW ([a-z_.]+)"`)
+
+func isShowQuery(cmdStr string) bool {
+	return showQuery.MatchString(cmdStr)
+}
+
+func showQueryName(cmdStr string) string {
+	return showQuery.FindStringSubmatch(cmdStr)[1]
+}
+
+func isTLSInstallCommand(cmdStr string) bool {
+	return strings.Contains(cmdStr, "/etc/greenplum-tls/")
+}
+
+func isLDAPProbe(cmdStr string) bool {
+	return strings.Contains(cmdStr, "</dev/tcp/")
+}
+
+func isRecoveryQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "SELECT pg_is_in_recovery()")
+}
+
+func isDataDirectoryCheck(cmdStr string) bool {
+	return strings.Contains(cmdStr, "/PG_VERSION ]; then echo present")
+}
+
+func isBackupRunningCheck(cmdStr string) bool {
+	return strings.Contains(cmdStr, "pgrep -x gpbackup")
+}
+
+func isRedistributionProgressQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "FROM gpexpand.status_detail")
+}
+
+func isDatabasesQuery(cmdStr string) bool {
+	return strings.Contains(cmdStr, "SELECT datname FROM pg_database")
+}
+
 func isActiveMasterQuery(cmdStr string) bool {
 	return strings.Contains(cmdStr, "psql -U gpadmin -c 'select * from gp_segment_configuration'")
 }
@@ -71,3 +312,32 @@ func (f *PodExec) handleActiveMasterQuery(command []string, podName string) erro
 
 	return nil
 }
+
+func (f *PodExec) handleReadOnlyCheck(podName string, stderr io.Writer) error {
+	f.ReadOnlyChecks++
+	for _, readOnlyPod := range f.ReadOnlyPods {
+		if podName == readOnlyPod {
+			fmt.Fprintln(stderr, "touch: cannot touch '/greenplum/.operator-rw-check': Read-only file system")
+			return errors.New("command terminated with exit code 1")
+		}
+	}
+	for _, fullDiskPod := range f.FullDiskPods {
+		if podName == fullDiskPod {
+			fmt.Fprintln(stderr, "touch: cannot touch '/greenplum/.operator-rw-check': No space left on device")
+			return errors.New("command terminated with exit code 1")
+		}
+	}
+	return nil
+}
+
+func (f *PodExec) handleDataDirectoryCheck(podName string, stdout io.Writer) error {
+	f.DataDirectoryChecks++
+	for _, missingPod := range f.MissingDataDirectoryPods {
+		if podName == missingPod {
+			_, err := io.WriteString(stdout, "missing\n")
+			return err
+		}
+	}
+	_, err := io.WriteString(stdout, "present\n")
+	return err
+}
@@ -44,6 +44,8 @@ func (s *RealSPDYExecutorUpgrader) NewSPDYExecutor(config *rest.Config, method s
 
 type PodExecInterface interface {
 	Execute(command []string, namespace, podName string, stdout, stderr io.Writer) error
+	// ExecuteWithStdin is Execute with stdin streamed to the command, for input that must not appear in its arguments
+	ExecuteWithStdin(command []string, namespace, podName string, stdin io.Reader, stdout, stderr io.Writer) error
 }
 
 type PodExecRESTClient struct {
@@ -62,7 +64,19 @@ func (p *PodExecRESTClient) Execute(command []string, namespace, podName string,
 	return remoteCommandExecutor.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr})
 }
 
+func (p *PodExecRESTClient) ExecuteWithStdin(command []string, namespace, podName string, stdin io.Reader, stdout, stderr io.Writer) error {
+	remoteCommandExecutor, err := p.executor(namespace, podName, command, true)
+	if err != nil {
+		return err
+	}
+	return remoteCommandExecutor.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+}
+
 func (p *PodExecRESTClient) Executor(namespace, podName string, command []string) (remotecommand.Executor, error) {
+	return p.executor(namespace, podName, command, false)
+}
+
+func (p *PodExecRESTClient) executor(namespace, podName string, command []string, stdin bool) (remotecommand.Executor, error) {
 	url := p.RestClient.Post().
 		Resource("pods").
 		Name(podName).
@@ -70,7 +84,7 @@ func (p *PodExecRESTClient) Executor(namespace, podName string, command []string
 		SubResource("exec").
 		VersionedParams(&corev1.PodExecOptions{
 			Command: command,
-			Stdin:   false,
+			Stdin:   stdin,
 			Stdout:  true,
 			Stderr:  true,
 			TTY:     false,
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/url"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -119,6 +120,27 @@ var _ = Describe("podcommandexecutor", func() {
 		})
 	})
 
+	Context("ExecuteWithStdin", func() {
+		It("streams stdin to the command", func() {
+			stdin := strings.NewReader("input")
+			err := podcommandexecutor.ExecuteWithStdin([]string{"fakeCmd"}, "testNamespace", "testPod", stdin, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			parameters, _ := url.ParseQuery(fakeExecutorUpgrader.URL.RawQuery)
+			Expect(parameters["stdin"]).To(Equal([]string{"true"}))
+			Expect(fakeExecutorUpgrader.Executor.Stdin).To(BeIdenticalTo(stdin))
+		})
+
+		When("Executor fails", func() {
+			BeforeEach(func() {
+				fakeExecutorUpgrader.SPDYError = errors.New("SPDY error")
+			})
+			It("returns an error", func() {
+				err := podcommandexecutor.ExecuteWithStdin(nil, "testNamespace", "testPod", strings.NewReader(""), nil, nil)
+				Expect(err).To(MatchError("SPDY error"))
+			})
+		})
+	})
+
 })
 
 // fake implementation of RemoteExecutorUpgrader interface
@@ -127,6 +149,7 @@ type FakeSPDYExecutorUpgrader struct {
 	URL       *url.URL
 	SPDYError error
 	ExecError error
+	Executor  *FakeCommandExecutor
 }
 
 var _ executor.RemoteExecutorUpgrader = &FakeSPDYExecutorUpgrader{}
@@ -137,17 +160,20 @@ func (s *FakeSPDYExecutorUpgrader) NewSPDYExecutor(config *rest.Config, method s
 	if s.SPDYError != nil {
 		return nil, s.SPDYError
 	}
-	return &FakeCommandExecutor{Err: s.ExecError}, nil
+	s.Executor = &FakeCommandExecutor{Err: s.ExecError}
+	return s.Executor, nil
 }
 
 // fake implementation of Executor interface
 type FakeCommandExecutor struct {
-	Err error
+	Err   error
+	Stdin io.Reader
 }
 
 var _ remotecommand.Executor = &FakeCommandExecutor{}
 
 func (f *FakeCommandExecutor) Stream(options remotecommand.StreamOptions) error {
+	f.Stdin = options.Stdin
 	if f.Err != nil {
 		Expect(io.WriteString(options.Stderr, f.Err.Error())).To(Equal(len(f.Err.Error())))
 		return f.Err
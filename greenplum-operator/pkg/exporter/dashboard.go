@@ -0,0 +1,125 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DashboardConfigMapName is the ConfigMap holding the Grafana dashboard of the cluster
+	DashboardConfigMapName = "greenplum-dashboard"
+	DashboardKey           = "greenplum.json"
+
+	// DashboardLabel marks ConfigMaps for the dashboard sidecar of the Grafana Helm chart to import
+	DashboardLabel = "grafana_dashboard"
+)
+
+type dashboardPanel struct {
+	ID          int               `json:"id"`
+	Title       string            `json:"title"`
+	Type        string            `json:"type"`
+	Datasource  string            `json:"datasource"`
+	GridPos     dashboardGridPos  `json:"gridPos"`
+	Targets     []dashboardTarget `json:"targets"`
+	FieldConfig dashboardFields   `json:"fieldConfig"`
+}
+
+type dashboardGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type dashboardTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type dashboardFields struct {
+	Defaults struct {
+		Unit string `json:"unit"`
+	} `json:"defaults"`
+}
+
+// Dashboard returns the JSON of a Grafana dashboard of the metrics exported for the cluster in namespace, with
+// the panels of the segment exporters if segments is set
+func Dashboard(namespace string, segments bool) string {
+	selector := fmt.Sprintf(`namespace="%s"`, namespace)
+	var panels []dashboardPanel
+	addPanel := func(title, panelType, unit string, targets ...dashboardTarget) {
+		id := len(panels) + 1
+		for i := range targets {
+			targets[i].RefID = string(rune('A' + i))
+		}
+		panel := dashboardPanel{
+			ID:         id,
+			Title:      title,
+			Type:       panelType,
+			Datasource: "${datasource}",
+			GridPos:    dashboardGridPos{H: 8, W: 12, X: (id - 1) % 2 * 12, Y: (id - 1) / 2 * 8},
+			Targets:    targets,
+		}
+		panel.FieldConfig.Defaults.Unit = unit
+		panels = append(panels, panel)
+	}
+	target := func(expr, legendFormat string) dashboardTarget {
+		return dashboardTarget{Expr: expr, LegendFormat: legendFormat}
+	}
+
+	addPanel("Segments", "stat", "none",
+		target(fmt.Sprintf("greenplum_segments_up{%s}", selector), "up"),
+		target(fmt.Sprintf("greenplum_segments_down{%s}", selector), "down"))
+	addPanel("Connections", "timeseries", "none",
+		target(fmt.Sprintf("sum by (state) (greenplum_connections_count{%s})", selector), "{{state}}"))
+	addPanel("Replication lag", "timeseries", "bytes",
+		target(fmt.Sprintf("greenplum_replication_max_lag_bytes{%s}", selector), "max lag"))
+	addPanel("Free disk space", "timeseries", "bytes",
+		target(fmt.Sprintf("greenplum_disk_min_free_bytes{%s}", selector), "fullest segment"))
+	addPanel("Segments down", "table", "none",
+		target(fmt.Sprintf("greenplum_segment_up{%s} == 0", selector), "{{content}} {{role}} {{hostname}}"))
+	addPanel("Free disk space by host", "timeseries", "bytes",
+		target(fmt.Sprintf("greenplum_host_disk_free_bytes{%s}", selector), "{{hostname}}"))
+	if segments {
+		addPanel("Replication lag by segment", "timeseries", "bytes",
+			target(fmt.Sprintf("greenplum_segment_replication_lag_bytes{%s}", selector), "{{pod}} {{application_name}}"))
+		addPanel("Disk usage by host", "timeseries", "bytes",
+			target(fmt.Sprintf("greenplum_segment_disk_used_bytes{%s}", selector), "{{pod}}"))
+	}
+	dashboard := map[string]interface{}{
+		"title":         fmt.Sprintf("Greenplum (%s)", namespace),
+		"tags":          []string{"greenplum"},
+		"timezone":      "browser",
+		"schemaVersion": 36,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+			},
+		},
+		"panels": panels,
+	}
+	dashboardJSON, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		// The dashboard is made of strings, numbers and slices, which always marshal
+		panic(err)
+	}
+	return string(dashboardJSON)
+}
+
+// ModifyDashboardConfigMap stores the dashboard, labeled for the Grafana sidecar to import it
+func ModifyDashboardConfigMap(clusterName string, configMap *corev1.ConfigMap, dashboard string) {
+	labels := generateLabels(clusterName)
+	labels[DashboardLabel] = "1"
+	configMap.Labels = labels
+	configMap.Data = map[string]string{DashboardKey: dashboard}
+}
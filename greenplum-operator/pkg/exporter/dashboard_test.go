@@ -0,0 +1,71 @@
+package exporter_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/exporter"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Dashboard", func() {
+	type target struct {
+		Expr  string `json:"expr"`
+		RefID string `json:"refId"`
+	}
+	type panel struct {
+		Title   string   `json:"title"`
+		Targets []target `json:"targets"`
+	}
+	parseDashboard := func(dashboardJSON string) (title string, panels []panel) {
+		var dashboard struct {
+			Title  string  `json:"title"`
+			Panels []panel `json:"panels"`
+		}
+		Expect(json.Unmarshal([]byte(dashboardJSON), &dashboard)).To(Succeed())
+		return dashboard.Title, dashboard.Panels
+	}
+	panelTitles := func(panels []panel) []string {
+		var titles []string
+		for _, p := range panels {
+			titles = append(titles, p.Title)
+		}
+		return titles
+	}
+
+	It("has panels for the cluster-wide metrics of the namespace", func() {
+		title, panels := parseDashboard(exporter.Dashboard("test-ns", false))
+		Expect(title).To(Equal("Greenplum (test-ns)"))
+		Expect(panelTitles(panels)).To(Equal([]string{
+			"Segments", "Connections", "Replication lag", "Free disk space", "Segments down", "Free disk space by host",
+		}))
+		Expect(panels[0].Targets).To(Equal([]target{
+			{Expr: `greenplum_segments_up{namespace="test-ns"}`, RefID: "A"},
+			{Expr: `greenplum_segments_down{namespace="test-ns"}`, RefID: "B"},
+		}))
+	})
+	It("adds panels for the segment exporters when segments is set", func() {
+		_, panels := parseDashboard(exporter.Dashboard("test-ns", true))
+		Expect(panelTitles(panels)).To(Equal([]string{
+			"Segments", "Connections", "Replication lag", "Free disk space", "Segments down", "Free disk space by host",
+			"Replication lag by segment", "Disk usage by host",
+		}))
+		Expect(panels[7].Targets).To(Equal([]target{
+			{Expr: `greenplum_segment_disk_used_bytes{namespace="test-ns"}`, RefID: "A"},
+		}))
+	})
+})
+
+var _ = Describe("ModifyDashboardConfigMap", func() {
+	It("stores the dashboard with the label of the Grafana sidecar", func() {
+		var configMap corev1.ConfigMap
+		exporter.ModifyDashboardConfigMap("my-greenplum", &configMap, "{}")
+		Expect(configMap.Labels).To(Equal(map[string]string{
+			"app":               "greenplum-exporter",
+			"greenplum-cluster": "my-greenplum",
+			"grafana_dashboard": "1",
+		}))
+		Expect(configMap.Data).To(Equal(map[string]string{"greenplum.json": "{}"}))
+	})
+})
@@ -0,0 +1,391 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Name of the exporter sidecar containers and of the Service that exposes them
+	Name = "greenplum-exporter"
+	// QueriesConfigMapName is the ConfigMap the operator writes the queries of each role to
+	QueriesConfigMapName = "greenplum-exporter-queries"
+	// MasterQueriesKey holds the cluster-wide and custom queries, SegmentQueriesKey those of a single segment
+	MasterQueriesKey  = "master.yaml"
+	SegmentQueriesKey = "segment.yaml"
+
+	DefaultImage            = "quay.io/prometheuscommunity/postgres-exporter:v0.11.1"
+	DefaultCustomQueriesKey = "queries.yaml"
+	Port                    = 9187
+
+	reservedQueryPrefix = "greenplum_"
+	queriesMountPath    = "/etc/greenplum-exporter"
+	queriesVolumeName   = "exporter-queries"
+	secretMountPath     = "/etc/greenplum-connection"
+	secretVolumeName    = "exporter-connection"
+)
+
+// Role is the role of the Greenplum instances whose pods run an exporter
+type Role string
+
+const (
+	RoleMaster  Role = "master"
+	RolePrimary Role = "primary"
+	RoleMirror  Role = "mirror"
+)
+
+// Enabled returns whether the pods of role run the exporter with monitoring
+func Enabled(monitoring *greenplumv1.GreenplumMonitoringSpec, role Role) bool {
+	if monitoring == nil || monitoring.Prometheus == nil || !monitoring.Prometheus.Enabled {
+		return false
+	}
+	segments := monitoring.Prometheus.Segments
+	switch role {
+	case RolePrimary:
+		return segments != nil && segments.Primary
+	case RoleMirror:
+		return segments != nil && segments.Mirror
+	default:
+		return true
+	}
+}
+
+// SegmentsEnabled returns whether the pods of any segment role run the exporter with monitoring
+func SegmentsEnabled(monitoring *greenplumv1.GreenplumMonitoringSpec) bool {
+	return Enabled(monitoring, RolePrimary) || Enabled(monitoring, RoleMirror)
+}
+
+// runScript runs postgres_exporter with the arguments of the script and exits once the queries or the password
+// change, for the kubelet to restart only this container. postgres_exporter reads them on startup, and the kubelet
+// updates the mounted ConfigMap and Secret in place
+const runScript = `checksum() { cat ` + queriesMountPath + `/* ` + secretMountPath + `/* 2>/dev/null | md5sum; }
+started=$(checksum)
+/bin/postgres_exporter "$@" &
+while kill -0 $! 2>/dev/null; do
+  sleep 30
+  [ "$(checksum)" = "$started" ] || exit 0
+done
+exit 1`
+
+const clusterQueries = `greenplum_connections:
+  query: "SELECT COALESCE(state, 'unknown') AS state, count(*) AS count FROM pg_stat_activity GROUP BY 1"
+  metrics:
+    - state:
+        usage: "LABEL"
+        description: "State of the connections"
+    - count:
+        usage: "GAUGE"
+        description: "Number of connections to the master in this state"
+greenplum_segments:
+  query: "SELECT sum(CASE WHEN status = 'u' THEN 1 ELSE 0 END) AS up, sum(CASE WHEN status = 'd' THEN 1 ELSE 0 END) AS down FROM gp_segment_configuration"
+  metrics:
+    - up:
+        usage: "GAUGE"
+        description: "Number of segments, including the master and standby, that are up"
+    - down:
+        usage: "GAUGE"
+        description: "Number of segments, including the master and standby, that are down"
+greenplum_segment:
+  query: "SELECT content::text AS content, role, preferred_role, hostname, CASE WHEN status = 'u' THEN 1 ELSE 0 END AS up FROM gp_segment_configuration"
+  metrics:
+    - content:
+        usage: "LABEL"
+        description: "Content ID of the segment; -1 for the master and standby"
+    - role:
+        usage: "LABEL"
+        description: "Current role of the segment: p for primary, m for mirror"
+    - preferred_role:
+        usage: "LABEL"
+        description: "Role the segment was initialized with"
+    - hostname:
+        usage: "LABEL"
+        description: "Pod of the segment"
+    - up:
+        usage: "GAUGE"
+        description: "Whether the segment is up"
+greenplum_replication:
+  query: "SELECT COALESCE(max(pg_xlog_location_diff(sent_location, replay_location)), 0) AS max_lag_bytes FROM gp_stat_replication"
+  metrics:
+    - max_lag_bytes:
+        usage: "GAUGE"
+        description: "Largest replication lag of a mirror or the standby, in bytes"
+greenplum_disk:
+  query: "SELECT min(dfspace) * 1024 AS min_free_bytes FROM gp_toolkit.gp_disk_free"
+  metrics:
+    - min_free_bytes:
+        usage: "GAUGE"
+        description: "Free space on the fullest segment data volume, in bytes"
+greenplum_host_disk:
+  query: "SELECT dfhostname AS hostname, min(dfspace) * 1024 AS free_bytes FROM gp_toolkit.gp_disk_free GROUP BY dfhostname"
+  metrics:
+    - hostname:
+        usage: "LABEL"
+        description: "Segment host"
+    - free_bytes:
+        usage: "GAUGE"
+        description: "Free space on the fullest segment data volume of the host, in bytes"
+`
+
+// The exporter of a segment connects to it in utility mode, so these only see the segment in the same pod
+const segmentQueries = `greenplum_segment_connections:
+  query: "SELECT COALESCE(state, 'unknown') AS state, count(*) AS count FROM pg_stat_activity GROUP BY 1"
+  metrics:
+    - state:
+        usage: "LABEL"
+        description: "State of the connections"
+    - count:
+        usage: "GAUGE"
+        description: "Number of connections to the segment in this state, including those of the master"
+greenplum_segment_replication:
+  query: "SELECT application_name, pg_xlog_location_diff(sent_location, replay_location) AS lag_bytes FROM pg_stat_replication"
+  metrics:
+    - application_name:
+        usage: "LABEL"
+        description: "Name of the replication connection"
+    - lag_bytes:
+        usage: "GAUGE"
+        description: "Replication lag of the mirror of the segment, in bytes"
+greenplum_segment_disk:
+  query: "SELECT sum(pg_database_size(oid)) AS used_bytes FROM pg_database"
+  metrics:
+    - used_bytes:
+        usage: "GAUGE"
+        description: "Space used by the databases of the segment on the data volume of its host, in bytes"
+`
+
+// MasterQueries returns the queries of the exporter of the master: the cluster-wide queries and customQueries
+func MasterQueries(customQueries string) string {
+	queries := clusterQueries
+	if customQueries != "" {
+		queries += strings.TrimSuffix(customQueries, "\n") + "\n"
+	}
+	return queries
+}
+
+// SegmentQueries returns the queries of the exporter of a segment
+func SegmentQueries() string {
+	return segmentQueries
+}
+
+func customQueriesKey(spec greenplumv1.GreenplumCustomQueriesSpec) string {
+	if spec.Key == "" {
+		return DefaultCustomQueriesKey
+	}
+	return spec.Key
+}
+
+// GetCustomQueries returns the queries in the ConfigMap referenced by spec, or "" if spec is nil
+func GetCustomQueries(ctx context.Context, reader client.Reader, namespace string, spec *greenplumv1.GreenplumCustomQueriesSpec) (string, error) {
+	if spec == nil {
+		return "", nil
+	}
+	var configMap corev1.ConfigMap
+	configMapKey := types.NamespacedName{Namespace: namespace, Name: spec.ConfigMapName}
+	if err := reader.Get(ctx, configMapKey, &configMap); err != nil {
+		if apierrs.IsNotFound(err) {
+			return "", fmt.Errorf(`ConfigMap "%s" not found`, spec.ConfigMapName)
+		}
+		return "", fmt.Errorf(`getting ConfigMap "%s": %w`, spec.ConfigMapName, err)
+	}
+	queries, ok := configMap.Data[customQueriesKey(*spec)]
+	if !ok {
+		return "", fmt.Errorf(`ConfigMap "%s" has no key "%s"`, spec.ConfigMapName, customQueriesKey(*spec))
+	}
+	return queries, nil
+}
+
+// ValidateCustomQueries checks that the ConfigMap referenced by spec holds queries that can be added to the
+// built-in ones
+func ValidateCustomQueries(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumCustomQueriesSpec) error {
+	queries, err := GetCustomQueries(ctx, reader, namespace, &spec)
+	if err != nil {
+		return err
+	}
+	if err := Validate(queries); err != nil {
+		return fmt.Errorf(`ConfigMap "%s" key "%s": %w`, spec.ConfigMapName, customQueriesKey(spec), err)
+	}
+	return nil
+}
+
+// Validate checks that queries is a YAML mapping of query names to queries, and that no name is reserved
+func Validate(queries string) error {
+	var parsed map[string]struct {
+		Query string `json:"query"`
+	}
+	if err := yaml.Unmarshal([]byte(queries), &parsed); err != nil {
+		return fmt.Errorf("invalid queries: %w", err)
+	}
+	if len(parsed) == 0 {
+		return errors.New("no queries")
+	}
+	names := make([]string, 0, len(parsed))
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, reservedQueryPrefix) {
+			return fmt.Errorf(`query name "%s" must not start with %s, which is reserved for the built-in queries`, name, reservedQueryPrefix)
+		}
+		if strings.TrimSpace(parsed[name].Query) == "" {
+			return fmt.Errorf(`query "%s" has no query`, name)
+		}
+	}
+	return nil
+}
+
+func generateLabels(clusterName string) map[string]string {
+	return map[string]string{
+		"app":               Name,
+		"greenplum-cluster": clusterName,
+	}
+}
+
+// ModifyQueriesConfigMap stores the queries of each role
+func ModifyQueriesConfigMap(clusterName string, configMap *corev1.ConfigMap, masterQueries, segmentQueries string) {
+	configMap.Labels = generateLabels(clusterName)
+	configMap.Data = map[string]string{
+		MasterQueriesKey:  masterQueries,
+		SegmentQueriesKey: segmentQueries,
+	}
+}
+
+// Volumes returns the volumes of the queries and the credentials of the exporter. Both are optional, since the
+// operator only writes them once the master is up, which must not hold up the pods
+func Volumes() []corev1.Volume {
+	return []corev1.Volume{
+		{
+			Name: queriesVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: QueriesConfigMapName},
+					DefaultMode:          heapvalue.NewInt32(corev1.ConfigMapVolumeSourceDefaultMode),
+					Optional:             heapvalue.NewBool(true),
+				},
+			},
+		},
+		{
+			Name: secretVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  greenplumv1.ConnectionSecretName,
+					Items:       []corev1.KeyToPath{{Key: corev1.BasicAuthPasswordKey, Path: corev1.BasicAuthPasswordKey}},
+					DefaultMode: heapvalue.NewInt32(corev1.SecretVolumeSourceDefaultMode),
+					Optional:    heapvalue.NewBool(true),
+				},
+			},
+		},
+	}
+}
+
+// ModifySidecar runs the exporter beside the Greenplum instance of role that listens on port, as the user in the
+// greenplum-connection Secret. The exporter of a segment connects in utility mode, which segments require of
+// connections that do not come from the master. It has no readiness probe, so that it cannot make the pod unready
+func ModifySidecar(container *corev1.Container, spec greenplumv1.GreenplumPrometheusSpec, role Role, port int, tls bool) {
+	container.Name = Name
+	container.Image = spec.Image
+	if container.Image == "" {
+		container.Image = DefaultImage
+	}
+	container.ImagePullPolicy = corev1.PullIfNotPresent
+	container.Command = []string{"/bin/sh", "-c", runScript, "postgres_exporter"}
+	queriesKey := SegmentQueriesKey
+	if role == RoleMaster {
+		queriesKey = MasterQueriesKey
+	}
+	// The default metrics of postgres_exporter assume a newer Postgres than Greenplum 6 is based on
+	container.Args = []string{
+		"--disable-default-metrics",
+		"--disable-settings-metrics",
+		"--extend.query-path=" + queriesMountPath + "/" + queriesKey,
+		"--web.listen-address=:" + strconv.Itoa(Port),
+	}
+	sslMode := "disable"
+	if tls {
+		sslMode = "require"
+	}
+	dataSourceURI := fmt.Sprintf("localhost:%d/postgres?sslmode=%s", port, sslMode)
+	if role != RoleMaster {
+		dataSourceURI += "&options=-c%20gp_session_role%3Dutility"
+	}
+	container.Env = []corev1.EnvVar{
+		{
+			Name:  "DATA_SOURCE_URI",
+			Value: dataSourceURI,
+		},
+		{
+			Name: "DATA_SOURCE_USER",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: greenplumv1.ConnectionSecretName},
+					Key:                  corev1.BasicAuthUsernameKey,
+					Optional:             heapvalue.NewBool(true),
+				},
+			},
+		},
+		{
+			Name:  "DATA_SOURCE_PASS_FILE",
+			Value: secretMountPath + "/" + corev1.BasicAuthPasswordKey,
+		},
+	}
+	container.Ports = []corev1.ContainerPort{
+		{
+			Name:          "metrics",
+			ContainerPort: Port,
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+	container.VolumeMounts = []corev1.VolumeMount{
+		{
+			Name:      queriesVolumeName,
+			MountPath: queriesMountPath,
+			ReadOnly:  true,
+		},
+		{
+			Name:      secretVolumeName,
+			MountPath: secretMountPath,
+			ReadOnly:  true,
+		},
+	}
+}
+
+// ModifyService exposes the exporter sidecars on a headless Service, annotated for Prometheus to scrape each pod.
+// It selects every pod of the cluster; those without a metrics port are left out of its endpoints
+func ModifyService(clusterName string, exporterService *corev1.Service) {
+	exporterService.Labels = generateLabels(clusterName)
+	if exporterService.Annotations == nil {
+		exporterService.Annotations = map[string]string{}
+	}
+	exporterService.Annotations["prometheus.io/scrape"] = "true"
+	exporterService.Annotations["prometheus.io/port"] = strconv.Itoa(Port)
+	exporterService.Annotations["prometheus.io/path"] = "/metrics"
+
+	if len(exporterService.Spec.Ports) != 1 {
+		exporterService.Spec.Ports = make([]corev1.ServicePort, 1)
+	}
+	metricsPort := &exporterService.Spec.Ports[0]
+	metricsPort.Name = "metrics"
+	metricsPort.Port = Port
+	metricsPort.Protocol = corev1.ProtocolTCP
+	metricsPort.TargetPort = intstr.FromString("metrics")
+
+	exporterService.Spec.Selector = map[string]string{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": clusterName,
+	}
+	exporterService.Spec.Type = corev1.ServiceTypeClusterIP
+	exporterService.Spec.ClusterIP = corev1.ClusterIPNone
+}
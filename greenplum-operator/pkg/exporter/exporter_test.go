@@ -0,0 +1,331 @@
+package exporter_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/exporter"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/testing"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+const customQueries = `pg_database_size:
+  query: "SELECT datname, pg_database_size(datname) AS bytes FROM pg_database"
+  metrics:
+    - datname:
+        usage: "LABEL"
+    - bytes:
+        usage: "GAUGE"
+`
+
+var _ = Describe("Queries", func() {
+	queryNames := func(queries string) []string {
+		var parsed map[string]interface{}
+		Expect(yaml.Unmarshal([]byte(queries), &parsed)).To(Succeed())
+		var names []string
+		for name := range parsed {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	Describe("MasterQueries", func() {
+		It("has the cluster-wide queries", func() {
+			Expect(queryNames(exporter.MasterQueries(""))).To(ConsistOf(
+				"greenplum_connections", "greenplum_segments", "greenplum_segment", "greenplum_replication",
+				"greenplum_disk", "greenplum_host_disk"))
+		})
+		It("appends the custom queries", func() {
+			queries := exporter.MasterQueries(customQueries)
+			Expect(queries).To(HaveSuffix(customQueries))
+			Expect(queryNames(queries)).To(ContainElement("pg_database_size"))
+		})
+		It("appends custom queries that do not end with a newline", func() {
+			queries := exporter.MasterQueries("my_query:\n  query: SELECT 1")
+			Expect(queries).To(HaveSuffix("my_query:\n  query: SELECT 1\n"))
+			Expect(queryNames(queries)).To(ContainElement("my_query"))
+		})
+		It("is accepted by Validate, except for its reserved names", func() {
+			Expect(exporter.Validate(exporter.MasterQueries(""))).To(MatchError(HavePrefix(`query name "greenplum_connections" must not start with greenplum_`)))
+		})
+	})
+
+	Describe("SegmentQueries", func() {
+		It("has the queries of the local segment", func() {
+			Expect(queryNames(exporter.SegmentQueries())).To(ConsistOf(
+				"greenplum_segment_connections", "greenplum_segment_replication", "greenplum_segment_disk"))
+		})
+	})
+})
+
+var _ = Describe("Enabled", func() {
+	var monitoring *greenplumv1.GreenplumMonitoringSpec
+	BeforeEach(func() {
+		monitoring = &greenplumv1.GreenplumMonitoringSpec{
+			Prometheus: &greenplumv1.GreenplumPrometheusSpec{Enabled: true},
+		}
+	})
+	It("runs the exporter of the master only by default", func() {
+		Expect(exporter.Enabled(monitoring, exporter.RoleMaster)).To(BeTrue())
+		Expect(exporter.Enabled(monitoring, exporter.RolePrimary)).To(BeFalse())
+		Expect(exporter.Enabled(monitoring, exporter.RoleMirror)).To(BeFalse())
+		Expect(exporter.SegmentsEnabled(monitoring)).To(BeFalse())
+	})
+	It("runs the exporter of each segment role that is enabled", func() {
+		monitoring.Prometheus.Segments = &greenplumv1.GreenplumPrometheusSegmentsSpec{Primary: true}
+		Expect(exporter.Enabled(monitoring, exporter.RolePrimary)).To(BeTrue())
+		Expect(exporter.Enabled(monitoring, exporter.RoleMirror)).To(BeFalse())
+		Expect(exporter.SegmentsEnabled(monitoring)).To(BeTrue())
+
+		monitoring.Prometheus.Segments = &greenplumv1.GreenplumPrometheusSegmentsSpec{Mirror: true}
+		Expect(exporter.Enabled(monitoring, exporter.RolePrimary)).To(BeFalse())
+		Expect(exporter.Enabled(monitoring, exporter.RoleMirror)).To(BeTrue())
+	})
+	It("runs no exporter when prometheus is disabled", func() {
+		monitoring.Prometheus.Enabled = false
+		monitoring.Prometheus.Segments = &greenplumv1.GreenplumPrometheusSegmentsSpec{Primary: true, Mirror: true}
+		Expect(exporter.Enabled(monitoring, exporter.RoleMaster)).To(BeFalse())
+		Expect(exporter.SegmentsEnabled(monitoring)).To(BeFalse())
+		Expect(exporter.Enabled(nil, exporter.RoleMaster)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Validate", func() {
+	It("accepts custom queries", func() {
+		Expect(exporter.Validate(customQueries)).To(Succeed())
+	})
+	It("rejects queries that are not YAML", func() {
+		Expect(exporter.Validate("my_query: [")).To(MatchError(HavePrefix("invalid queries: ")))
+	})
+	It("rejects queries that are not a mapping", func() {
+		Expect(exporter.Validate("- my_query")).To(MatchError(HavePrefix("invalid queries: ")))
+	})
+	It("rejects empty queries", func() {
+		Expect(exporter.Validate("")).To(MatchError("no queries"))
+	})
+	It("rejects a query name with the reserved prefix", func() {
+		Expect(exporter.Validate("greenplum_mine:\n  query: SELECT 1\n")).To(MatchError(
+			`query name "greenplum_mine" must not start with greenplum_, which is reserved for the built-in queries`))
+	})
+	It("rejects a query without a query", func() {
+		Expect(exporter.Validate("my_query:\n  metrics: []\n")).To(MatchError(`query "my_query" has no query`))
+	})
+})
+
+var _ = Describe("ValidateCustomQueries", func() {
+	var (
+		ctx            context.Context
+		reactiveClient *reactive.Client
+		spec           greenplumv1.GreenplumCustomQueriesSpec
+		configMap      *corev1.ConfigMap
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		spec = greenplumv1.GreenplumCustomQueriesSpec{ConfigMapName: "my-queries"}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "my-queries"},
+			Data:       map[string]string{"queries.yaml": customQueries},
+		}
+	})
+	JustBeforeEach(func() {
+		if configMap != nil {
+			Expect(reactiveClient.Create(ctx, configMap)).To(Succeed())
+		}
+	})
+
+	It("accepts a ConfigMap holding valid queries", func() {
+		Expect(exporter.ValidateCustomQueries(ctx, reactiveClient, "test-ns", spec)).To(Succeed())
+	})
+	When("the ConfigMap does not exist", func() {
+		BeforeEach(func() {
+			configMap = nil
+		})
+		It("returns an error", func() {
+			Expect(exporter.ValidateCustomQueries(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`ConfigMap "my-queries" not found`))
+		})
+	})
+	When("getting the ConfigMap fails", func() {
+		BeforeEach(func() {
+			reactiveClient.PrependReactor("get", "configmaps", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+		})
+		It("returns an error", func() {
+			Expect(exporter.ValidateCustomQueries(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`getting ConfigMap "my-queries": injected error`))
+		})
+	})
+	When("the ConfigMap does not have the key", func() {
+		BeforeEach(func() {
+			spec.Key = "custom.yaml"
+		})
+		It("returns an error", func() {
+			Expect(exporter.ValidateCustomQueries(ctx, reactiveClient, "test-ns", spec)).To(MatchError(`ConfigMap "my-queries" has no key "custom.yaml"`))
+		})
+	})
+	When("the queries are not valid", func() {
+		BeforeEach(func() {
+			configMap.Data["queries.yaml"] = "greenplum_mine:\n  query: SELECT 1\n"
+		})
+		It("returns an error", func() {
+			Expect(exporter.ValidateCustomQueries(ctx, reactiveClient, "test-ns", spec)).To(MatchError(
+				`ConfigMap "my-queries" key "queries.yaml": query name "greenplum_mine" must not start with greenplum_, which is reserved for the built-in queries`))
+		})
+	})
+})
+
+var _ = Describe("GetCustomQueries", func() {
+	It("returns no queries when there is no customQueries", func() {
+		reactiveClient := reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		Expect(exporter.GetCustomQueries(context.Background(), reactiveClient, "test-ns", nil)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("exporter K8s resources", func() {
+	var labels = map[string]string{
+		"app":               "greenplum-exporter",
+		"greenplum-cluster": "my-greenplum",
+	}
+
+	Describe("ModifyQueriesConfigMap", func() {
+		It("stores the queries of each role", func() {
+			var configMap corev1.ConfigMap
+			exporter.ModifyQueriesConfigMap("my-greenplum", &configMap, "master queries", "segment queries")
+			Expect(configMap.Labels).To(Equal(labels))
+			Expect(configMap.Data).To(Equal(map[string]string{
+				"master.yaml":  "master queries",
+				"segment.yaml": "segment queries",
+			}))
+		})
+	})
+
+	Describe("Volumes", func() {
+		It("mounts the queries and the password, without holding up the pod until they exist", func() {
+			volumes := exporter.Volumes()
+			Expect(volumes).To(HaveLen(2))
+			Expect(volumes[0].Name).To(Equal("exporter-queries"))
+			Expect(volumes[0].ConfigMap.Name).To(Equal("greenplum-exporter-queries"))
+			Expect(volumes[0].ConfigMap.Optional).To(gstruct.PointTo(BeTrue()))
+			Expect(volumes[1].Name).To(Equal("exporter-connection"))
+			Expect(volumes[1].Secret.SecretName).To(Equal("greenplum-connection"))
+			Expect(volumes[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "password", Path: "password"}}))
+			Expect(volumes[1].Secret.Optional).To(gstruct.PointTo(BeTrue()))
+		})
+	})
+
+	Describe("ModifySidecar", func() {
+		var (
+			container corev1.Container
+			spec      greenplumv1.GreenplumPrometheusSpec
+		)
+		BeforeEach(func() {
+			container = corev1.Container{}
+			spec = greenplumv1.GreenplumPrometheusSpec{Enabled: true}
+		})
+		It("runs the exporter with the queries of the master", func() {
+			exporter.ModifySidecar(&container, spec, exporter.RoleMaster, 5432, false)
+
+			Expect(container.Name).To(Equal("greenplum-exporter"))
+			Expect(container.Image).To(Equal("quay.io/prometheuscommunity/postgres-exporter:v0.11.1"))
+			Expect(container.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+			Expect(container.Command).To(HaveLen(4))
+			Expect(container.Command[:2]).To(Equal([]string{"/bin/sh", "-c"}))
+			Expect(container.Command[2]).To(ContainSubstring(`/bin/postgres_exporter "$@" &`))
+			Expect(container.Args).To(Equal([]string{
+				"--disable-default-metrics",
+				"--disable-settings-metrics",
+				"--extend.query-path=/etc/greenplum-exporter/master.yaml",
+				"--web.listen-address=:9187",
+			}))
+			Expect(container.VolumeMounts).To(Equal([]corev1.VolumeMount{
+				{Name: "exporter-queries", MountPath: "/etc/greenplum-exporter", ReadOnly: true},
+				{Name: "exporter-connection", MountPath: "/etc/greenplum-connection", ReadOnly: true},
+			}))
+			Expect(container.Ports).To(Equal([]corev1.ContainerPort{
+				{Name: "metrics", ContainerPort: 9187, Protocol: corev1.ProtocolTCP},
+			}))
+			Expect(container.ReadinessProbe).To(BeNil())
+		})
+		It("connects to the local master with the credentials in the connection Secret", func() {
+			exporter.ModifySidecar(&container, spec, exporter.RoleMaster, 5432, false)
+
+			Expect(container.Env).To(HaveLen(3))
+			Expect(container.Env[0]).To(Equal(corev1.EnvVar{
+				Name:  "DATA_SOURCE_URI",
+				Value: "localhost:5432/postgres?sslmode=disable",
+			}))
+			Expect(container.Env[1].Name).To(Equal("DATA_SOURCE_USER"))
+			Expect(container.Env[1].ValueFrom.SecretKeyRef.Name).To(Equal("greenplum-connection"))
+			Expect(container.Env[1].ValueFrom.SecretKeyRef.Key).To(Equal("username"))
+			Expect(container.Env[1].ValueFrom.SecretKeyRef.Optional).To(gstruct.PointTo(BeTrue()))
+			Expect(container.Env[2]).To(Equal(corev1.EnvVar{
+				Name:  "DATA_SOURCE_PASS_FILE",
+				Value: "/etc/greenplum-connection/password",
+			}))
+		})
+		It("requires TLS when the master requires it", func() {
+			exporter.ModifySidecar(&container, spec, exporter.RoleMaster, 5432, true)
+
+			Expect(container.Env[0].Value).To(HaveSuffix("?sslmode=require"))
+		})
+		DescribeTable("connects to a segment in utility mode, with the queries of a segment",
+			func(role exporter.Role, port int, expectedURI string) {
+				exporter.ModifySidecar(&container, spec, role, port, false)
+
+				Expect(container.Env[0].Value).To(Equal(expectedURI))
+				Expect(container.Args).To(ContainElement("--extend.query-path=/etc/greenplum-exporter/segment.yaml"))
+			},
+			Entry("primary", exporter.RolePrimary, 40000, "localhost:40000/postgres?sslmode=disable&options=-c%20gp_session_role%3Dutility"),
+			Entry("mirror", exporter.RoleMirror, 50000, "localhost:50000/postgres?sslmode=disable&options=-c%20gp_session_role%3Dutility"),
+		)
+		It("uses the given image", func() {
+			spec.Image = "my-registry/postgres-exporter:latest"
+			exporter.ModifySidecar(&container, spec, exporter.RoleMaster, 5432, false)
+
+			Expect(container.Image).To(Equal("my-registry/postgres-exporter:latest"))
+		})
+	})
+
+	Describe("ModifyService", func() {
+		It("exposes the metrics port of each pod for Prometheus to scrape", func() {
+			var service corev1.Service
+			exporter.ModifyService("my-greenplum", &service)
+
+			Expect(service.Labels).To(Equal(labels))
+			Expect(service.Annotations).To(Equal(map[string]string{
+				"prometheus.io/scrape": "true",
+				"prometheus.io/port":   "9187",
+				"prometheus.io/path":   "/metrics",
+			}))
+			Expect(service.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(service.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			Expect(service.Spec.Selector).To(Equal(map[string]string{
+				"app":               "greenplum",
+				"greenplum-cluster": "my-greenplum",
+			}))
+			Expect(service.Spec.Ports).To(Equal([]corev1.ServicePort{
+				{Name: "metrics", Port: 9187, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromString("metrics")},
+			}))
+		})
+		It("keeps other annotations", func() {
+			service := corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"other": "annotation"}},
+			}
+			exporter.ModifyService("my-greenplum", &service)
+
+			Expect(service.Annotations).To(HaveKeyWithValue("other", "annotation"))
+		})
+	})
+})
@@ -8,7 +8,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-func GenerateJob(image, hostname string, newSegCount int32) (job batchv1.Job) {
+// GenerateJob returns a job that adds segments up to newSegCount. If redistribute is set, it then
+// redistributes table data onto the new segments and removes the expansion schema.
+func GenerateJob(image, hostname string, newSegCount int32, redistribute bool) (job batchv1.Job) {
 	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
 
 	gpexpandPod := &job.Spec.Template.Spec
@@ -48,6 +50,10 @@ func GenerateJob(image, hostname string, newSegCount int32) (job batchv1.Job) {
 					Value:     strconv.FormatInt(int64(newSegCount), 10),
 					ValueFrom: nil,
 				},
+				{
+					Name:  "REDISTRIBUTE",
+					Value: strconv.FormatBool(redistribute),
+				},
 			},
 			ImagePullPolicy: corev1.PullIfNotPresent,
 			VolumeMounts: []corev1.VolumeMount{
@@ -9,7 +9,7 @@ import (
 
 var _ = Describe("GenerateJob", func() {
 	It("sets properties on the job", func() {
-		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0.agent.default.svc.cluster.local", 2)
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0.agent.default.svc.cluster.local", 2, false)
 		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
 
 		gpexpandPod := job.Spec.Template.Spec
@@ -27,6 +27,8 @@ var _ = Describe("GenerateJob", func() {
 		Expect(gpexpandContainer.Env[0].Value).To(Equal("master-0.agent.default.svc.cluster.local"))
 		Expect(gpexpandContainer.Env[1].Name).To(Equal("NEW_SEG_COUNT"))
 		Expect(gpexpandContainer.Env[1].Value).To(Equal("2"))
+		Expect(gpexpandContainer.Env[2].Name).To(Equal("REDISTRIBUTE"))
+		Expect(gpexpandContainer.Env[2].Value).To(Equal("false"))
 		Expect(gpexpandContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
 		Expect(gpexpandContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
 		Expect(gpexpandContainer.Command).To(Equal([]string{
@@ -37,4 +39,11 @@ var _ = Describe("GenerateJob", func() {
 		Expect(sshSecretVolumeMount.Name).To(Equal("ssh-key"))
 		Expect(sshSecretVolumeMount.MountPath).To(Equal("/etc/ssh-key"))
 	})
+	It("asks the job to redistribute when requested", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0.agent.default.svc.cluster.local", 2, true)
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+			Name:  "REDISTRIBUTE",
+			Value: "true",
+		}))
+	})
 })
@@ -0,0 +1,198 @@
+package interconnecttls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// CASecretName holds the CA certificate and key. It is never mounted into pods
+	CASecretName = "interconnect-tls-ca"
+	// SecretName holds the CA bundle and a certificate and key for every pod, named after the pod
+	SecretName = "interconnect-tls"
+
+	CACertKey         = "ca.crt"
+	CAPrivateKeyKey   = "ca.key"
+	PreviousCACertKey = "previous-ca.crt"
+
+	MountPath = "/etc/interconnect-tls"
+
+	// IssuedAtAnnotation on the pod certificates secret is when certificates in it were last issued. Pods that
+	// started before then still use the previous ones
+	IssuedAtAnnotation = "greenplum.pivotal.io/interconnect-certificates-issued-at"
+
+	DefaultCertificateValidity = 90 * 24 * time.Hour
+	// Certificates are valid from slightly before they are issued, to tolerate clock skew between nodes
+	notBeforeSkew = 5 * time.Minute
+	// The CA outlives many pod certificates, so that reissuing them does not change what pods trust
+	caValidityFactor = 10
+
+	volumeName = "interconnect-tls"
+)
+
+// CertificateValidity returns how long pod certificates are valid for
+func CertificateValidity(spec greenplumv1.GreenplumInterconnectTLSSpec) time.Duration {
+	if spec.CertificateValidity == nil || spec.CertificateValidity.Duration <= 0 {
+		return DefaultCertificateValidity
+	}
+	return spec.CertificateValidity.Duration
+}
+
+// CAValidity returns how long the CA certificate is valid for
+func CAValidity(spec greenplumv1.GreenplumInterconnectTLSSpec) time.Duration {
+	return caValidityFactor * CertificateValidity(spec)
+}
+
+// PodNames lists the Greenplum pods of cluster that get a certificate
+func PodNames(cluster *greenplumv1.GreenplumCluster) []string {
+	podNames := []string{"master-0"}
+	if cluster.Spec.MasterAndStandby.Standby == "yes" {
+		podNames = append(podNames, "master-1")
+	}
+	for i := int32(0); i < cluster.Spec.Segments.PrimarySegmentCount; i++ {
+		podNames = append(podNames, fmt.Sprintf("segment-a-%d", i))
+	}
+	if cluster.Spec.Segments.Mirrors == "yes" {
+		for i := int32(0); i < cluster.Spec.Segments.PrimarySegmentCount; i++ {
+			podNames = append(podNames, fmt.Sprintf("segment-b-%d", i))
+		}
+	}
+	return podNames
+}
+
+func CertificateKey(podName string) string {
+	return podName + ".crt"
+}
+
+func PrivateKeyKey(podName string) string {
+	return podName + ".key"
+}
+
+// GenerateCA returns a new self-signed CA certificate and its private key, PEM-encoded
+func GenerateCA(clusterName string, now time.Time, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: clusterName + " interconnect CA"},
+		NotBefore:             now.Add(-notBeforeSkew),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return createCertificate(template, nil, nil)
+}
+
+// GenerateCertificate returns a new certificate for podName signed by the CA, and its private key, PEM-encoded.
+// The certificate is for both ends of a connection, since the master is a client of the segments. It is also valid
+// for localhost, since Greenplum utilities on the pod connect to it that way while verifying the host name
+func GenerateCertificate(caCertPEM, caKeyPEM []byte, podName, namespace string, now time.Time, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	caCert, err := parseCertificate(caCertPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing CA certificate: %w", err)
+	}
+	block, _ := pem.Decode(caKeyPEM)
+	if block == nil {
+		return nil, nil, errors.New("parsing CA private key: no PEM data found")
+	}
+	caKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing CA private key: %w", err)
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: podName},
+		DNSNames:    []string{podName, podName + ".agent." + namespace + ".svc.cluster.local", "localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:   now.Add(-notBeforeSkew),
+		NotAfter:    now.Add(validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	return createCertificate(template, caCert, caKey)
+}
+
+// RenewalTime returns when a certificate should be reissued: once a third of its validity is left
+func RenewalTime(certPEM []byte) (time.Time, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	issuedAt := cert.NotBefore.Add(notBeforeSkew)
+	return cert.NotAfter.Add(-cert.NotAfter.Sub(issuedAt) / 3), nil
+}
+
+// NeedsRenewal is true if certPEM is missing, cannot be parsed or is due for renewal at now
+func NeedsRenewal(certPEM []byte, now time.Time) bool {
+	renewAt, err := RenewalTime(certPEM)
+	return err != nil || !now.Before(renewAt)
+}
+
+// SSLModeEnv makes libpq in the Greenplum container verify the certificate and host name of the server it connects to
+func SSLModeEnv() corev1.EnvVar {
+	return corev1.EnvVar{Name: "PGSSLMODE", Value: "verify-full"}
+}
+
+// Volume projects the pod certificates for VolumeMount
+func Volume() corev1.Volume {
+	return corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  SecretName,
+				DefaultMode: heapvalue.NewInt32(0444),
+			},
+		},
+	}
+}
+
+// VolumeMount mounts the pod certificates, which the container copies into place on startup
+func VolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: MountPath,
+		ReadOnly:  true,
+	}
+}
+
+func createCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating private key: %w", err)
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating serial number: %w", err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM-encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,13 @@
+package interconnecttls_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestInterconnecttls(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "interconnecttls Suite")
+}
@@ -0,0 +1,142 @@
+package interconnecttls_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/interconnecttls"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func parseCertificate(certPEM []byte) *x509.Certificate {
+	block, _ := pem.Decode(certPEM)
+	Expect(block).NotTo(BeNil())
+	Expect(block.Type).To(Equal("CERTIFICATE"))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	return cert
+}
+
+var _ = Describe("CertificateValidity", func() {
+	It("defaults to 90 days", func() {
+		Expect(interconnecttls.CertificateValidity(greenplumv1.GreenplumInterconnectTLSSpec{})).To(Equal(90 * 24 * time.Hour))
+	})
+	It("uses the given validity", func() {
+		spec := greenplumv1.GreenplumInterconnectTLSSpec{CertificateValidity: &metav1.Duration{Duration: 720 * time.Hour}}
+		Expect(interconnecttls.CertificateValidity(spec)).To(Equal(720 * time.Hour))
+		Expect(interconnecttls.CAValidity(spec)).To(Equal(7200 * time.Hour))
+	})
+})
+
+var _ = Describe("PodNames", func() {
+	var cluster *greenplumv1.GreenplumCluster
+	BeforeEach(func() {
+		cluster = &greenplumv1.GreenplumCluster{}
+		cluster.Spec.Segments.PrimarySegmentCount = 2
+	})
+	It("lists the master and primary segments", func() {
+		Expect(interconnecttls.PodNames(cluster)).To(Equal([]string{"master-0", "segment-a-0", "segment-a-1"}))
+	})
+	It("lists the standby and mirror segments", func() {
+		cluster.Spec.MasterAndStandby.Standby = "yes"
+		cluster.Spec.Segments.Mirrors = "yes"
+		Expect(interconnecttls.PodNames(cluster)).To(Equal([]string{
+			"master-0", "master-1", "segment-a-0", "segment-a-1", "segment-b-0", "segment-b-1"}))
+	})
+})
+
+var _ = Describe("certificates", func() {
+	var (
+		now       time.Time
+		caCertPEM []byte
+		caKeyPEM  []byte
+	)
+	BeforeEach(func() {
+		now = time.Now()
+		var err error
+		caCertPEM, caKeyPEM, err = interconnecttls.GenerateCA("my-greenplum", now, 900*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("generates a CA", func() {
+		caCert := parseCertificate(caCertPEM)
+		Expect(caCert.IsCA).To(BeTrue())
+		Expect(caCert.Subject.CommonName).To(Equal("my-greenplum interconnect CA"))
+		Expect(caCert.NotAfter).To(BeTemporally("~", now.Add(900*time.Hour), time.Second))
+		block, _ := pem.Decode(caKeyPEM)
+		Expect(block.Type).To(Equal("EC PRIVATE KEY"))
+	})
+
+	It("generates a pod certificate signed by the CA", func() {
+		certPEM, keyPEM, err := interconnecttls.GenerateCertificate(caCertPEM, caKeyPEM, "segment-a-0", "test-ns", now, 90*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keyPEM).NotTo(BeEmpty())
+		cert := parseCertificate(certPEM)
+		Expect(cert.Subject.CommonName).To(Equal("segment-a-0"))
+		Expect(cert.DNSNames).To(Equal([]string{"segment-a-0", "segment-a-0.agent.test-ns.svc.cluster.local", "localhost"}))
+		Expect(cert.IPAddresses).To(HaveLen(2))
+		Expect(cert.IPAddresses[0].IsLoopback()).To(BeTrue())
+		Expect(cert.IPAddresses[1].IsLoopback()).To(BeTrue())
+		Expect(cert.ExtKeyUsage).To(ConsistOf(x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth))
+		Expect(cert.NotAfter).To(BeTemporally("~", now.Add(90*time.Hour), time.Second))
+
+		roots := x509.NewCertPool()
+		roots.AddCert(parseCertificate(caCertPEM))
+		_, err = cert.Verify(x509.VerifyOptions{
+			DNSName:     "segment-a-0",
+			Roots:       roots,
+			CurrentTime: now,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("fails to sign with an invalid CA key", func() {
+		_, _, err := interconnecttls.GenerateCertificate(caCertPEM, []byte("not a key"), "segment-a-0", "test-ns", now, time.Hour)
+		Expect(err).To(MatchError("parsing CA private key: no PEM data found"))
+	})
+
+	Describe("RenewalTime", func() {
+		It("is two thirds of the validity after the certificate was issued", func() {
+			certPEM, _, err := interconnecttls.GenerateCertificate(caCertPEM, caKeyPEM, "master-0", "test-ns", now, 90*time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			renewAt, err := interconnecttls.RenewalTime(certPEM)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(renewAt).To(BeTemporally("~", now.Add(60*time.Hour), time.Second))
+		})
+	})
+
+	Describe("NeedsRenewal", func() {
+		var certPEM []byte
+		BeforeEach(func() {
+			var err error
+			certPEM, _, err = interconnecttls.GenerateCertificate(caCertPEM, caKeyPEM, "master-0", "test-ns", now, 90*time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("is false while more than a third of the validity is left", func() {
+			Expect(interconnecttls.NeedsRenewal(certPEM, now.Add(59*time.Hour))).To(BeFalse())
+		})
+		It("is true once a third of the validity is left", func() {
+			Expect(interconnecttls.NeedsRenewal(certPEM, now.Add(61*time.Hour))).To(BeTrue())
+		})
+		It("is true for a missing certificate", func() {
+			Expect(interconnecttls.NeedsRenewal(nil, now)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Volume", func() {
+	It("mounts the pod certificates secret read-only", func() {
+		volume := interconnecttls.Volume()
+		Expect(volume.Secret).NotTo(BeNil())
+		Expect(volume.Secret.SecretName).To(Equal("interconnect-tls"))
+		mount := interconnecttls.VolumeMount()
+		Expect(mount.Name).To(Equal(volume.Name))
+		Expect(mount.MountPath).To(Equal("/etc/interconnect-tls"))
+		Expect(mount.ReadOnly).To(BeTrue())
+	})
+})
@@ -0,0 +1,194 @@
+package ldapauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ModeSimpleBind = "simpleBind"
+	ModeSearchBind = "searchBind"
+
+	// UsernamePlaceholder stands for the user name in bindDNTemplate
+	UsernamePlaceholder = "$username"
+
+	// DefaultBindPasswordKey is the key of the password in the searchBindPassword Secret
+	DefaultBindPasswordKey = "password"
+
+	defaultPort = 389
+)
+
+var hostnameRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+var searchAttributeRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// ParseServerURL returns the host and port of an ldap:// URL. The port defaults to 389
+func ParseServerURL(serverURL string) (host string, port int, err error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid serverURL: %w", err)
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		return "", 0, errors.New("serverURL: ldaps is not supported by Greenplum 6; use ldap:// with tls.startTLS")
+	default:
+		return "", 0, fmt.Errorf(`invalid serverURL "%s": must be ldap://host[:port]`, serverURL)
+	}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", 0, fmt.Errorf(`invalid serverURL "%s": must be ldap://host[:port]`, serverURL)
+	}
+	host = u.Hostname()
+	if net.ParseIP(host) == nil && !hostnameRegexp.MatchString(host) {
+		return "", 0, fmt.Errorf(`invalid serverURL "%s": invalid host "%s"`, serverURL, host)
+	}
+	port = defaultPort
+	if u.Port() != "" {
+		port, err = strconv.Atoi(u.Port())
+		if err != nil || port < 1 || port > 65535 {
+			return "", 0, fmt.Errorf(`invalid serverURL "%s": invalid port "%s"`, serverURL, u.Port())
+		}
+	}
+	return host, port, nil
+}
+
+// Validate checks that spec has the fields its mode requires and none of the other mode's, and that the values
+// can be written to pg_hba.conf
+func Validate(spec greenplumv1.GreenplumLDAPSpec) error {
+	if _, _, err := ParseServerURL(spec.ServerURL); err != nil {
+		return err
+	}
+	switch spec.Mode {
+	case ModeSimpleBind:
+		if spec.BaseDN != "" || spec.SearchAttribute != "" || spec.SearchBindDN != "" || spec.SearchBindPassword != nil {
+			return errors.New("baseDN, searchAttribute, searchBindDN and searchBindPassword are only used in searchBind mode")
+		}
+		if spec.BindDNTemplate == "" {
+			return errors.New("bindDNTemplate is required in simpleBind mode")
+		}
+		if strings.Count(spec.BindDNTemplate, UsernamePlaceholder) != 1 {
+			return fmt.Errorf("bindDNTemplate must contain %s exactly once", UsernamePlaceholder)
+		}
+		if err := validateValue("bindDNTemplate", spec.BindDNTemplate); err != nil {
+			return err
+		}
+	case ModeSearchBind:
+		if spec.BindDNTemplate != "" {
+			return errors.New("bindDNTemplate is only used in simpleBind mode")
+		}
+		if spec.BaseDN == "" {
+			return errors.New("baseDN is required in searchBind mode")
+		}
+		if err := validateValue("baseDN", spec.BaseDN); err != nil {
+			return err
+		}
+		if spec.SearchAttribute != "" && !searchAttributeRegexp.MatchString(spec.SearchAttribute) {
+			return fmt.Errorf(`invalid searchAttribute "%s"`, spec.SearchAttribute)
+		}
+		if err := validateValue("searchBindDN", spec.SearchBindDN); err != nil {
+			return err
+		}
+		if spec.SearchBindPassword != nil {
+			if spec.SearchBindDN == "" {
+				return errors.New("searchBindPassword requires searchBindDN")
+			}
+			if spec.SearchBindPassword.SecretName == "" {
+				return errors.New("searchBindPassword secretName must be specified")
+			}
+		}
+	default:
+		return fmt.Errorf(`invalid mode "%s": must be simpleBind or searchBind`, spec.Mode)
+	}
+	if spec.CreateMissingRoles && len(spec.Roles) == 0 {
+		return errors.New("createMissingRoles requires roles to be listed")
+	}
+	return nil
+}
+
+// validateValue checks that value can be double-quoted in pg_hba.conf, which has no escape for a double quote
+func validateValue(field, value string) error {
+	if strings.ContainsAny(value, "\"\\") || strings.IndexFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return fmt.Errorf("%s must not contain double quotes, backslashes or control characters", field)
+	}
+	return nil
+}
+
+// GetBindPassword returns the password in the searchBindPassword Secret, or "" if spec has none
+func GetBindPassword(ctx context.Context, reader client.Reader, namespace string, spec greenplumv1.GreenplumLDAPSpec) (string, error) {
+	passwordSpec := spec.SearchBindPassword
+	if passwordSpec == nil {
+		return "", nil
+	}
+	key := passwordSpec.Key
+	if key == "" {
+		key = DefaultBindPasswordKey
+	}
+	var secret corev1.Secret
+	secretKey := types.NamespacedName{Namespace: namespace, Name: passwordSpec.SecretName}
+	if err := reader.Get(ctx, secretKey, &secret); err != nil {
+		if apierrs.IsNotFound(err) {
+			return "", fmt.Errorf(`Secret "%s" not found`, passwordSpec.SecretName)
+		}
+		return "", fmt.Errorf(`getting Secret "%s": %w`, passwordSpec.SecretName, err)
+	}
+	password, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf(`Secret "%s" has no key "%s"`, passwordSpec.SecretName, key)
+	}
+	if err := validateValue(fmt.Sprintf(`key "%s" of Secret "%s"`, key, passwordSpec.SecretName), string(password)); err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// PgHbaEntries returns the pg_hba.conf records that authenticate spec.roles, or all roles but gpadmin, with LDAP
+// from any address. spec must have passed Validate
+func PgHbaEntries(spec greenplumv1.GreenplumLDAPSpec, bindPassword string) []string {
+	host, port, _ := ParseServerURL(spec.ServerURL)
+	options := []string{fmt.Sprintf("ldapserver=%s", host), fmt.Sprintf("ldapport=%d", port)}
+	if spec.TLS != nil && spec.TLS.StartTLS {
+		options = append(options, "ldaptls=1")
+	}
+	if spec.Mode == ModeSimpleBind {
+		placeholder := strings.Index(spec.BindDNTemplate, UsernamePlaceholder)
+		options = append(options,
+			fmt.Sprintf(`ldapprefix="%s"`, spec.BindDNTemplate[:placeholder]),
+			fmt.Sprintf(`ldapsuffix="%s"`, spec.BindDNTemplate[placeholder+len(UsernamePlaceholder):]))
+	} else {
+		options = append(options, fmt.Sprintf(`ldapbasedn="%s"`, spec.BaseDN))
+		if spec.SearchAttribute != "" {
+			options = append(options, fmt.Sprintf("ldapsearchattribute=%s", spec.SearchAttribute))
+		}
+		if spec.SearchBindDN != "" {
+			options = append(options, fmt.Sprintf(`ldapbinddn="%s"`, spec.SearchBindDN))
+		}
+		if bindPassword != "" {
+			options = append(options, fmt.Sprintf(`ldapbindpasswd="%s"`, bindPassword))
+		}
+	}
+
+	// gpadmin keeps authenticating with its password, since the operator manages it
+	users := "all"
+	var entries []string
+	if len(spec.Roles) > 0 {
+		users = strings.Join(spec.Roles, ",")
+	} else {
+		entries = append(entries, "host all gpadmin 0.0.0.0/0 md5", "host all gpadmin ::/0 md5")
+	}
+	for _, address := range []string{"0.0.0.0/0", "::/0"} {
+		entries = append(entries, fmt.Sprintf("host all %s %s ldap %s", users, address, strings.Join(options, " ")))
+	}
+	return entries
+}
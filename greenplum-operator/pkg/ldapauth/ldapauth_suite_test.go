@@ -0,0 +1,13 @@
+package ldapauth_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLdapauth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ldapauth Suite")
+}
@@ -0,0 +1,210 @@
+package ldapauth_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/ldapauth"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func simpleBindSpec() greenplumv1.GreenplumLDAPSpec {
+	return greenplumv1.GreenplumLDAPSpec{
+		ServerURL:      "ldap://ldap.example.com",
+		Mode:           "simpleBind",
+		BindDNTemplate: "uid=$username,ou=people,dc=example,dc=com",
+	}
+}
+
+func searchBindSpec() greenplumv1.GreenplumLDAPSpec {
+	return greenplumv1.GreenplumLDAPSpec{
+		ServerURL:          "ldap://ldap.example.com:3389",
+		Mode:               "searchBind",
+		BaseDN:             "ou=people,dc=example,dc=com",
+		SearchAttribute:    "sAMAccountName",
+		SearchBindDN:       "cn=greenplum,dc=example,dc=com",
+		SearchBindPassword: &greenplumv1.GreenplumLDAPBindPasswordSpec{SecretName: "ldap-bind"},
+	}
+}
+
+var _ = Describe("ParseServerURL", func() {
+	DescribeTable("returns the host and port",
+		func(serverURL, host string, port int) {
+			parsedHost, parsedPort, err := ldapauth.ParseServerURL(serverURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsedHost).To(Equal(host))
+			Expect(parsedPort).To(Equal(port))
+		},
+		Entry("default port", "ldap://ldap.example.com", "ldap.example.com", 389),
+		Entry("explicit port", "ldap://ldap.example.com:3389/", "ldap.example.com", 3389),
+		Entry("IP address", "ldap://10.0.0.5", "10.0.0.5", 389),
+		Entry("IPv6 address", "ldap://[fd00::5]:389", "fd00::5", 389),
+	)
+	DescribeTable("rejects URLs that Greenplum cannot connect to",
+		func(serverURL, message string) {
+			_, _, err := ldapauth.ParseServerURL(serverURL)
+			Expect(err).To(MatchError(message))
+		},
+		Entry("ldaps", "ldaps://ldap.example.com", "serverURL: ldaps is not supported by Greenplum 6; use ldap:// with tls.startTLS"),
+		Entry("other scheme", "http://ldap.example.com", `invalid serverURL "http://ldap.example.com": must be ldap://host[:port]`),
+		Entry("no scheme", "ldap.example.com", `invalid serverURL "ldap.example.com": must be ldap://host[:port]`),
+		Entry("base DN in the path", "ldap://ldap.example.com/dc=example,dc=com", `invalid serverURL "ldap://ldap.example.com/dc=example,dc=com": must be ldap://host[:port]`),
+		Entry("invalid host", "ldap://ldap_server", `invalid serverURL "ldap://ldap_server": invalid host "ldap_server"`),
+		Entry("invalid port", "ldap://ldap.example.com:70000", `invalid serverURL "ldap://ldap.example.com:70000": invalid port "70000"`),
+	)
+})
+
+var _ = Describe("Validate", func() {
+	It("accepts a simpleBind spec", func() {
+		Expect(ldapauth.Validate(simpleBindSpec())).To(Succeed())
+	})
+	It("accepts a searchBind spec", func() {
+		Expect(ldapauth.Validate(searchBindSpec())).To(Succeed())
+	})
+	It("accepts an anonymous searchBind spec", func() {
+		spec := searchBindSpec()
+		spec.SearchBindDN = ""
+		spec.SearchBindPassword = nil
+		Expect(ldapauth.Validate(spec)).To(Succeed())
+	})
+
+	DescribeTable("rejects invalid specs",
+		func(mutate func(spec *greenplumv1.GreenplumLDAPSpec), message string) {
+			spec := simpleBindSpec()
+			if mutate != nil {
+				mutate(&spec)
+			}
+			Expect(ldapauth.Validate(spec)).To(MatchError(message))
+		},
+		Entry("invalid server URL", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.ServerURL = "ldaps://ldap.example.com" },
+			"serverURL: ldaps is not supported by Greenplum 6; use ldap:// with tls.startTLS"),
+		Entry("unknown mode", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.Mode = "bind" },
+			`invalid mode "bind": must be simpleBind or searchBind`),
+		Entry("simpleBind without bindDNTemplate", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.BindDNTemplate = "" },
+			"bindDNTemplate is required in simpleBind mode"),
+		Entry("bindDNTemplate without the placeholder", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.BindDNTemplate = "ou=people,dc=example,dc=com" },
+			"bindDNTemplate must contain $username exactly once"),
+		Entry("bindDNTemplate with a double quote", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.BindDNTemplate = `uid=$username,o="Example"` },
+			"bindDNTemplate must not contain double quotes, backslashes or control characters"),
+		Entry("simpleBind with search fields", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.BaseDN = "dc=example,dc=com" },
+			"baseDN, searchAttribute, searchBindDN and searchBindPassword are only used in searchBind mode"),
+		Entry("searchBind without baseDN", func(spec *greenplumv1.GreenplumLDAPSpec) {
+			*spec = searchBindSpec()
+			spec.BaseDN = ""
+		}, "baseDN is required in searchBind mode"),
+		Entry("searchBind with bindDNTemplate", func(spec *greenplumv1.GreenplumLDAPSpec) {
+			spec.Mode = "searchBind"
+			spec.BaseDN = "dc=example,dc=com"
+		}, "bindDNTemplate is only used in simpleBind mode"),
+		Entry("invalid searchAttribute", func(spec *greenplumv1.GreenplumLDAPSpec) {
+			*spec = searchBindSpec()
+			spec.SearchAttribute = "uid name"
+		}, `invalid searchAttribute "uid name"`),
+		Entry("searchBindPassword without searchBindDN", func(spec *greenplumv1.GreenplumLDAPSpec) {
+			*spec = searchBindSpec()
+			spec.SearchBindDN = ""
+		}, "searchBindPassword requires searchBindDN"),
+		Entry("createMissingRoles without roles", func(spec *greenplumv1.GreenplumLDAPSpec) { spec.CreateMissingRoles = true },
+			"createMissingRoles requires roles to be listed"),
+	)
+})
+
+var _ = Describe("GetBindPassword", func() {
+	var (
+		ctx            context.Context
+		reactiveClient *reactive.Client
+		spec           greenplumv1.GreenplumLDAPSpec
+		secret         *corev1.Secret
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		reactiveClient = reactive.NewClient(fakeClient.NewFakeClientWithScheme(scheme.Scheme))
+		spec = searchBindSpec()
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "ldap-bind"},
+			Data:       map[string][]byte{"password": []byte("s3cret")},
+		}
+	})
+	JustBeforeEach(func() {
+		if secret != nil {
+			Expect(reactiveClient.Create(ctx, secret)).To(Succeed())
+		}
+	})
+
+	It("returns the password", func() {
+		Expect(ldapauth.GetBindPassword(ctx, reactiveClient, "test-ns", spec)).To(Equal("s3cret"))
+	})
+	When("the key is given", func() {
+		BeforeEach(func() {
+			spec.SearchBindPassword.Key = "bindPassword"
+			secret.Data = map[string][]byte{"bindPassword": []byte("other")}
+		})
+		It("returns the password under that key", func() {
+			Expect(ldapauth.GetBindPassword(ctx, reactiveClient, "test-ns", spec)).To(Equal("other"))
+		})
+	})
+	When("there is no searchBindPassword", func() {
+		BeforeEach(func() {
+			spec.SearchBindPassword = nil
+		})
+		It("returns an empty password", func() {
+			Expect(ldapauth.GetBindPassword(ctx, reactiveClient, "test-ns", spec)).To(BeEmpty())
+		})
+	})
+	When("the Secret does not exist", func() {
+		BeforeEach(func() {
+			secret = nil
+		})
+		It("returns an error", func() {
+			_, err := ldapauth.GetBindPassword(ctx, reactiveClient, "test-ns", spec)
+			Expect(err).To(MatchError(`Secret "ldap-bind" not found`))
+		})
+	})
+	When("the Secret has no password", func() {
+		BeforeEach(func() {
+			secret.Data = nil
+		})
+		It("returns an error", func() {
+			_, err := ldapauth.GetBindPassword(ctx, reactiveClient, "test-ns", spec)
+			Expect(err).To(MatchError(`Secret "ldap-bind" has no key "password"`))
+		})
+	})
+	When("the password cannot be written to pg_hba.conf", func() {
+		BeforeEach(func() {
+			secret.Data["password"] = []byte(`pass"word`)
+		})
+		It("returns an error", func() {
+			_, err := ldapauth.GetBindPassword(ctx, reactiveClient, "test-ns", spec)
+			Expect(err).To(MatchError(`key "password" of Secret "ldap-bind" must not contain double quotes, backslashes or control characters`))
+		})
+	})
+})
+
+var _ = Describe("PgHbaEntries", func() {
+	It("binds as the DN from the template in simpleBind mode, keeping gpadmin on md5", func() {
+		Expect(ldapauth.PgHbaEntries(simpleBindSpec(), "")).To(Equal([]string{
+			"host all gpadmin 0.0.0.0/0 md5",
+			"host all gpadmin ::/0 md5",
+			`host all all 0.0.0.0/0 ldap ldapserver=ldap.example.com ldapport=389 ldapprefix="uid=" ldapsuffix=",ou=people,dc=example,dc=com"`,
+			`host all all ::/0 ldap ldapserver=ldap.example.com ldapport=389 ldapprefix="uid=" ldapsuffix=",ou=people,dc=example,dc=com"`,
+		}))
+	})
+	It("searches as the bind DN in searchBind mode", func() {
+		spec := searchBindSpec()
+		spec.TLS = &greenplumv1.GreenplumLDAPTLSSpec{StartTLS: true}
+		spec.Roles = []string{"analyst", "etl_user"}
+		Expect(ldapauth.PgHbaEntries(spec, "s3cret")).To(Equal([]string{
+			`host all analyst,etl_user 0.0.0.0/0 ldap ldapserver=ldap.example.com ldapport=3389 ldaptls=1 ldapbasedn="ou=people,dc=example,dc=com" ` +
+				`ldapsearchattribute=sAMAccountName ldapbinddn="cn=greenplum,dc=example,dc=com" ldapbindpasswd="s3cret"`,
+			`host all analyst,etl_user ::/0 ldap ldapserver=ldap.example.com ldapport=3389 ldaptls=1 ldapbasedn="ou=people,dc=example,dc=com" ` +
+				`ldapsearchattribute=sAMAccountName ldapbinddn="cn=greenplum,dc=example,dc=com" ldapbindpasswd="s3cret"`,
+		}))
+	})
+})
@@ -0,0 +1,125 @@
+package perfcheckjob
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var DefaultDiskFileSize = resource.MustParse("1Gi")
+
+// GenerateJob returns a Job that runs gpcheckperf from the master at hostname across the segment hosts.
+// It runs the disk test when spec has a disk threshold, and the network test when it has a network threshold
+func GenerateJob(image, hostname string, spec greenplumv1.GreenplumPerfCheckSpec) (job batchv1.Job) {
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	gpcheckperfPod := &job.Spec.Template.Spec
+	gpcheckperfPod.RestartPolicy = corev1.RestartPolicyNever
+
+	gpcheckperfPod.Volumes = []corev1.Volume{
+		{
+			Name: "ssh-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  "ssh-secrets",
+					DefaultMode: heapvalue.NewInt32(0444),
+				},
+			},
+		},
+	}
+	gpcheckperfPod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+	gpcheckperfPod.Containers = []corev1.Container{
+		{
+			Name:  "gpcheckperf",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gpcheckperf_job.sh",
+			},
+			Env:             gpcheckperfEnv(hostname, spec),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ssh-key",
+					ReadOnly:  false,
+					MountPath: "/etc/ssh-key",
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func gpcheckperfEnv(hostname string, spec greenplumv1.GreenplumPerfCheckSpec) []corev1.EnvVar {
+	env := []corev1.EnvVar{{Name: "GPCHECKPERF_HOST", Value: hostname}}
+	if spec.MinDiskWriteMBps > 0 || spec.MinDiskReadMBps > 0 {
+		fileSize := DefaultDiskFileSize
+		if spec.DiskFileSize != nil {
+			fileSize = *spec.DiskFileSize
+		}
+		// gpcheckperf -S takes the size in its own units, where 1MB is 1024*1024 bytes
+		env = append(env, corev1.EnvVar{
+			Name:  "GPCHECKPERF_DISK_FILE_SIZE",
+			Value: fmt.Sprintf("%dMB", fileSize.Value()/(1024*1024)),
+		})
+	}
+	if spec.MinNetworkMBps > 0 {
+		env = append(env, corev1.EnvVar{Name: "GPCHECKPERF_NETWORK", Value: "true"})
+	}
+	return env
+}
+
+// Results reads the throughput that gpcheckperf_job.sh writes to the termination message of the gpcheckperf
+// container, truncated to whole MB/s. It returns false while the container has not terminated
+func Results(pod corev1.Pod) (results greenplumv1.GreenplumPerfCheckStatus, ok bool) {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name != "gpcheckperf" || containerStatus.State.Terminated == nil {
+			continue
+		}
+		for _, line := range strings.Split(containerStatus.State.Terminated.Message, "\n") {
+			key, value, found := strings.Cut(line, "=")
+			if !found {
+				continue
+			}
+			mbps, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "disk_write_mbps":
+				results.DiskWriteMBps = int32(mbps)
+			case "disk_read_mbps":
+				results.DiskReadMBps = int32(mbps)
+			case "network_mbps":
+				results.NetworkMBps = int32(mbps)
+			}
+		}
+		return results, true
+	}
+	return results, false
+}
+
+// BelowThresholds describes each throughput in results that is below its threshold in spec.
+// A throughput that was not measured, e.g. the network throughput of a single segment host, is skipped
+func BelowThresholds(spec greenplumv1.GreenplumPerfCheckSpec, results greenplumv1.GreenplumPerfCheckStatus) []string {
+	var degraded []string
+	check := func(name string, measured, threshold int32) {
+		if threshold > 0 && measured > 0 && measured < threshold {
+			degraded = append(degraded, fmt.Sprintf("%s throughput %d MB/s is below %d MB/s", name, measured, threshold))
+		}
+	}
+	check("disk write", results.DiskWriteMBps, spec.MinDiskWriteMBps)
+	check("disk read", results.DiskReadMBps, spec.MinDiskReadMBps)
+	check("network", results.NetworkMBps, spec.MinNetworkMBps)
+	return degraded
+}
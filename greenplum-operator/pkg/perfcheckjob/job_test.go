@@ -0,0 +1,117 @@
+package perfcheckjob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("GenerateJob", func() {
+	It("sets properties on the job", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0.agent.test-ns.svc.cluster.local", greenplumv1.GreenplumPerfCheckSpec{MinDiskWriteMBps: 200})
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		gpcheckperfPod := job.Spec.Template.Spec
+		Expect(gpcheckperfPod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+
+		sshSecretVolume := gpcheckperfPod.Volumes[0]
+		Expect(sshSecretVolume.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolume.VolumeSource.Secret.SecretName).To(Equal("ssh-secrets"))
+		Expect(sshSecretVolume.VolumeSource.Secret.DefaultMode).To(gstruct.PointTo(Equal(int32(0444))))
+
+		Expect(gpcheckperfPod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+		gpcheckperfContainer := gpcheckperfPod.Containers[0]
+		Expect(gpcheckperfContainer.Name).To(Equal("gpcheckperf"))
+		Expect(gpcheckperfContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(gpcheckperfContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(gpcheckperfContainer.Command).To(Equal([]string{
+			"/home/gpadmin/tools/gpcheckperf_job.sh",
+		}))
+
+		sshSecretVolumeMount := gpcheckperfContainer.VolumeMounts[0]
+		Expect(sshSecretVolumeMount.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolumeMount.MountPath).To(Equal("/etc/ssh-key"))
+	})
+
+	It("only runs the disk test with a 1GB file for disk thresholds", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumPerfCheckSpec{MinDiskReadMBps: 200})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "GPCHECKPERF_HOST", Value: "master-0"},
+			{Name: "GPCHECKPERF_DISK_FILE_SIZE", Value: "1024MB"},
+		}))
+	})
+
+	It("only runs the network test for a network threshold", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumPerfCheckSpec{MinNetworkMBps: 100})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "GPCHECKPERF_HOST", Value: "master-0"},
+			{Name: "GPCHECKPERF_NETWORK", Value: "true"},
+		}))
+	})
+
+	It("uses the given disk file size", func() {
+		fileSize := resource.MustParse("4Gi")
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0", greenplumv1.GreenplumPerfCheckSpec{
+			DiskFileSize:     &fileSize,
+			MinDiskWriteMBps: 200,
+			MinNetworkMBps:   100,
+		})
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "GPCHECKPERF_HOST", Value: "master-0"},
+			{Name: "GPCHECKPERF_DISK_FILE_SIZE", Value: "4096MB"},
+			{Name: "GPCHECKPERF_NETWORK", Value: "true"},
+		}))
+	})
+})
+
+var _ = Describe("Results", func() {
+	terminatedPod := func(message string) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "gpcheckperf",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+		}}}}
+	}
+
+	It("reads the throughput from the termination message", func() {
+		results, ok := Results(terminatedPod("disk_write_mbps=180.75\ndisk_read_mbps=412.01\nnetwork_mbps=1093.09\n"))
+		Expect(ok).To(BeTrue())
+		Expect(results).To(Equal(greenplumv1.GreenplumPerfCheckStatus{
+			DiskWriteMBps: 180,
+			DiskReadMBps:  412,
+			NetworkMBps:   1093,
+		}))
+	})
+
+	It("leaves out the tests that were not run or could not be parsed", func() {
+		results, ok := Results(terminatedPod("disk_write_mbps=\nnetwork_mbps=1093.09\n"))
+		Expect(ok).To(BeTrue())
+		Expect(results).To(Equal(greenplumv1.GreenplumPerfCheckStatus{NetworkMBps: 1093}))
+	})
+
+	It("returns false while the container is running", func() {
+		_, ok := Results(corev1.Pod{})
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("BelowThresholds", func() {
+	spec := greenplumv1.GreenplumPerfCheckSpec{MinDiskWriteMBps: 200, MinDiskReadMBps: 300, MinNetworkMBps: 1000}
+
+	It("describes each throughput below its threshold", func() {
+		Expect(BelowThresholds(spec, greenplumv1.GreenplumPerfCheckStatus{DiskWriteMBps: 180, DiskReadMBps: 300, NetworkMBps: 900})).To(Equal([]string{
+			"disk write throughput 180 MB/s is below 200 MB/s",
+			"network throughput 900 MB/s is below 1000 MB/s",
+		}))
+	})
+
+	It("returns nothing when every throughput meets its threshold", func() {
+		Expect(BelowThresholds(spec, greenplumv1.GreenplumPerfCheckStatus{DiskWriteMBps: 200, DiskReadMBps: 400, NetworkMBps: 1000})).To(BeEmpty())
+	})
+
+	It("skips throughputs without a threshold, or that were not measured", func() {
+		Expect(BelowThresholds(greenplumv1.GreenplumPerfCheckSpec{MinNetworkMBps: 1000}, greenplumv1.GreenplumPerfCheckStatus{DiskWriteMBps: 1})).To(BeEmpty())
+	})
+})
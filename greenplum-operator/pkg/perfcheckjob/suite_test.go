@@ -0,0 +1,13 @@
+package perfcheckjob
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPerfcheckjob(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "perfcheckjob Suite")
+}
@@ -0,0 +1,45 @@
+package perfcheckjob
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	DefaultInterval = 24 * time.Hour
+
+	// Every run loads the disks and the network of all the segment hosts, so it should not run often
+	MinInterval = time.Hour
+)
+
+var minDiskFileSize = resource.MustParse("1Mi")
+
+// ValidateSpec checks that spec sets at least one threshold, and that its thresholds, interval and disk file size are in range
+func ValidateSpec(spec greenplumv1.GreenplumPerfCheckSpec) error {
+	if spec.MinDiskWriteMBps == 0 && spec.MinDiskReadMBps == 0 && spec.MinNetworkMBps == 0 {
+		return errors.New("at least one of minDiskWriteMBps, minDiskReadMBps and minNetworkMBps must be specified")
+	}
+	for _, threshold := range []struct {
+		name  string
+		value int32
+	}{
+		{"minDiskWriteMBps", spec.MinDiskWriteMBps},
+		{"minDiskReadMBps", spec.MinDiskReadMBps},
+		{"minNetworkMBps", spec.MinNetworkMBps},
+	} {
+		if threshold.value < 0 {
+			return fmt.Errorf(`invalid %s value: "%d": must be greater than 0`, threshold.name, threshold.value)
+		}
+	}
+	if interval := spec.Interval; interval != nil && interval.Duration < MinInterval {
+		return fmt.Errorf(`invalid interval value: "%s": must be at least %s`, interval.Duration, MinInterval)
+	}
+	if fileSize := spec.DiskFileSize; fileSize != nil && fileSize.Cmp(minDiskFileSize) < 0 {
+		return fmt.Errorf(`invalid diskFileSize value: "%s": must be at least %s`, fileSize.String(), minDiskFileSize.String())
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package perfcheckjob
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ValidateSpec", func() {
+	It("accepts a spec with a threshold", func() {
+		fileSize := resource.MustParse("512Mi")
+		Expect(ValidateSpec(greenplumv1.GreenplumPerfCheckSpec{
+			Interval:       &metav1.Duration{Duration: time.Hour},
+			DiskFileSize:   &fileSize,
+			MinNetworkMBps: 100,
+		})).To(Succeed())
+	})
+
+	It("requires a threshold", func() {
+		Expect(ValidateSpec(greenplumv1.GreenplumPerfCheckSpec{})).
+			To(MatchError("at least one of minDiskWriteMBps, minDiskReadMBps and minNetworkMBps must be specified"))
+	})
+
+	It("rejects negative thresholds", func() {
+		Expect(ValidateSpec(greenplumv1.GreenplumPerfCheckSpec{MinDiskWriteMBps: 200, MinDiskReadMBps: -1})).
+			To(MatchError(`invalid minDiskReadMBps value: "-1": must be greater than 0`))
+	})
+
+	It("rejects an interval shorter than an hour", func() {
+		Expect(ValidateSpec(greenplumv1.GreenplumPerfCheckSpec{
+			Interval:         &metav1.Duration{Duration: 10 * time.Minute},
+			MinDiskWriteMBps: 200,
+		})).To(MatchError(`invalid interval value: "10m0s": must be at least 1h0m0s`))
+	})
+
+	It("rejects a disk file size below 1Mi", func() {
+		fileSize := resource.MustParse("100Ki")
+		Expect(ValidateSpec(greenplumv1.GreenplumPerfCheckSpec{DiskFileSize: &fileSize, MinDiskWriteMBps: 200})).
+			To(MatchError(`invalid diskFileSize value: "100Ki": must be at least 1Mi`))
+	})
+})
@@ -0,0 +1,150 @@
+package pghba
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Path of pg_hba.conf on the master and standby
+	Path = "/greenplum/data-1/pg_hba.conf"
+
+	// BeginMarker and EndMarker enclose config.pgHbaEntries at the end of pg_hba.conf, so that the operator
+	// can replace them without touching the entries before them
+	BeginMarker = "# BEGIN greenplum-operator pgHbaEntries"
+	EndMarker   = "# END greenplum-operator pgHbaEntries"
+)
+
+var connectionTypes = map[string]bool{
+	"local":     true,
+	"host":      true,
+	"hostssl":   true,
+	"hostnossl": true,
+}
+
+// The authentication methods of Greenplum 6
+var authMethods = map[string]bool{
+	"trust":    true,
+	"reject":   true,
+	"md5":      true,
+	"password": true,
+	"gss":      true,
+	"sspi":     true,
+	"ident":    true,
+	"peer":     true,
+	"ldap":     true,
+	"radius":   true,
+	"cert":     true,
+	"pam":      true,
+}
+
+var hostnameRegexp = regexp.MustCompile(`^\.?[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// Block renders entries between BeginMarker and EndMarker, the way they appear in pg_hba.conf.
+// It is empty when there are no entries
+func Block(entries []string) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	lines := append([]string{BeginMarker}, entries...)
+	lines = append(lines, EndMarker)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Hash returns the hex-encoded SHA-256 of a block, or "" for an empty block
+func Hash(block string) string {
+	if block == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(block))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate checks that entry is a pg_hba.conf record: a connection type, database, user, an address unless the type
+// is local, an authentication method and name=value options. trust is only allowed over the local socket or from
+// loopback addresses, unless allowInsecureAuth is set
+func Validate(entry string, allowInsecureAuth bool) error {
+	if strings.ContainsAny(entry, "\n\r\x00") {
+		return errors.New("must be a single line")
+	}
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return errors.New("must not be empty")
+	}
+	if strings.HasPrefix(fields[0], "#") {
+		return errors.New("must not be a comment")
+	}
+	connectionType := fields[0]
+	if !connectionTypes[connectionType] {
+		return fmt.Errorf(`unknown connection type "%s": must be local, host, hostssl or hostnossl`, connectionType)
+	}
+
+	methodIndex := 3
+	loopback := true
+	if connectionType != "local" {
+		if len(fields) < 5 {
+			return errors.New("must have a connection type, database, user, address and authentication method")
+		}
+		methodIndex = 4
+		address := fields[3]
+		if ip := net.ParseIP(address); ip != nil {
+			// an IP address is followed by its netmask
+			if len(fields) < 6 {
+				return fmt.Errorf(`address "%s" must be followed by a netmask and an authentication method`, address)
+			}
+			mask := net.ParseIP(fields[4])
+			if mask == nil {
+				return fmt.Errorf(`invalid netmask "%s"`, fields[4])
+			}
+			if ip.To4() != nil {
+				mask = mask.To4()
+			}
+			ones, bits := net.IPMask(mask).Size()
+			if bits == 0 {
+				return fmt.Errorf(`invalid netmask "%s"`, fields[4])
+			}
+			methodIndex = 5
+			loopback = isLoopbackNetwork(ip, ones, bits)
+		} else if ip, network, err := net.ParseCIDR(address); err == nil {
+			ones, bits := network.Mask.Size()
+			loopback = isLoopbackNetwork(ip, ones, bits)
+		} else if address == "all" || address == "samehost" || address == "samenet" || hostnameRegexp.MatchString(address) {
+			loopback = address == "localhost"
+		} else {
+			return fmt.Errorf(`invalid address "%s": must be a CIDR address, an IP address and netmask, or a host name`, address)
+		}
+	}
+	if len(fields) <= methodIndex {
+		return errors.New("must have a connection type, database, user and authentication method")
+	}
+
+	method := fields[methodIndex]
+	if !authMethods[method] {
+		return fmt.Errorf(`unknown authentication method "%s"`, method)
+	}
+	for _, option := range fields[methodIndex+1:] {
+		if !strings.Contains(option, "=") {
+			return fmt.Errorf(`invalid authentication option "%s": must be name=value`, option)
+		}
+	}
+	if method == "trust" && !loopback && !allowInsecureAuth {
+		return errors.New("trust is only allowed from loopback addresses unless allowInsecureAuth is set")
+	}
+	return nil
+}
+
+// isLoopbackNetwork reports whether every address of the network is a loopback address
+func isLoopbackNetwork(ip net.IP, ones, bits int) bool {
+	if !ip.IsLoopback() {
+		return false
+	}
+	if ip.To4() != nil {
+		return ones >= 8
+	}
+	return ones == bits
+}
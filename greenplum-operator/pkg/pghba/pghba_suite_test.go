@@ -0,0 +1,13 @@
+package pghba_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPghba(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "pghba Suite")
+}
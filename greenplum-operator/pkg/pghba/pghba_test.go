@@ -0,0 +1,73 @@
+package pghba_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/pghba"
+)
+
+var _ = Describe("Block", func() {
+	It("encloses the entries in the markers", func() {
+		Expect(pghba.Block([]string{"host all app 10.0.0.0/16 md5", "hostssl all all 0.0.0.0/0 cert"})).To(Equal(
+			"# BEGIN greenplum-operator pgHbaEntries\n" +
+				"host all app 10.0.0.0/16 md5\n" +
+				"hostssl all all 0.0.0.0/0 cert\n" +
+				"# END greenplum-operator pgHbaEntries\n"))
+	})
+	It("is empty without entries", func() {
+		Expect(pghba.Block(nil)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Hash", func() {
+	It("returns the SHA-256 of the block", func() {
+		Expect(pghba.Hash("host all app 10.0.0.0/16 md5\n")).To(HaveLen(64))
+		Expect(pghba.Hash("a")).To(Equal("ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"))
+	})
+	It("is empty for an empty block", func() {
+		Expect(pghba.Hash("")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Validate", func() {
+	DescribeTable("accepts valid entries",
+		func(entry string) {
+			Expect(pghba.Validate(entry, false)).To(Succeed())
+		},
+		Entry("CIDR address", "host all app_user 10.0.0.0/16 md5"),
+		Entry("IPv6 CIDR address", "hostssl sales all fd00::/8 cert"),
+		Entry("IP address and netmask", "hostnossl all all 192.168.1.0 255.255.255.0 md5"),
+		Entry("host name", "host all all .example.com ldap ldapserver=ldap.example.com ldapprefix=cn="),
+		Entry("replication", "host replication gpadmin 10.1.0.0/16 md5"),
+		Entry("local socket", "local all gpadmin trust"),
+		Entry("trust from IPv4 loopback", "host all all 127.0.0.1/32 trust"),
+		Entry("trust from IPv6 loopback", "host all all ::1/128 trust"),
+		Entry("trust from localhost", "host all all localhost trust"),
+		Entry("reject", "host all all 0.0.0.0/0 reject"),
+	)
+
+	DescribeTable("rejects invalid entries",
+		func(entry, expectedErr string) {
+			Expect(pghba.Validate(entry, false)).To(MatchError(expectedErr))
+		},
+		Entry("empty", "  ", "must not be empty"),
+		Entry("comment", "# host all all 0.0.0.0/0 md5", "must not be a comment"),
+		Entry("multiple lines", "host all all 10.0.0.0/8 md5\nhost all all 0.0.0.0/0 trust", "must be a single line"),
+		Entry("unknown connection type", "hostgssenc all all 10.0.0.0/8 md5", `unknown connection type "hostgssenc": must be local, host, hostssl or hostnossl`),
+		Entry("missing method", "host all all 10.0.0.0/8", "must have a connection type, database, user, address and authentication method"),
+		Entry("missing local method", "local all gpadmin", "must have a connection type, database, user and authentication method"),
+		Entry("invalid address", "host all all 10.0.0.0/33 md5", `invalid address "10.0.0.0/33": must be a CIDR address, an IP address and netmask, or a host name`),
+		Entry("IP address without netmask", "host all all 10.0.0.1 md5", `address "10.0.0.1" must be followed by a netmask and an authentication method`),
+		Entry("non-contiguous netmask", "host all all 10.0.0.1 255.0.255.0 md5", `invalid netmask "255.0.255.0"`),
+		Entry("unknown method", "host all all 10.0.0.0/8 scram-sha-256", `unknown authentication method "scram-sha-256"`),
+		Entry("option without value", "hostssl all all 10.0.0.0/8 cert clientcert", `invalid authentication option "clientcert": must be name=value`),
+		Entry("trust from a network", "host all all 10.0.0.0/8 trust", "trust is only allowed from loopback addresses unless allowInsecureAuth is set"),
+		Entry("trust from anywhere", "host all all all trust", "trust is only allowed from loopback addresses unless allowInsecureAuth is set"),
+		Entry("trust from a network containing loopback", "host all all 0.0.0.0/0 trust", "trust is only allowed from loopback addresses unless allowInsecureAuth is set"),
+	)
+
+	It("allows trust from other addresses with allowInsecureAuth", func() {
+		Expect(pghba.Validate("host all all 10.0.0.0/8 trust", true)).To(Succeed())
+	})
+})
@@ -0,0 +1,57 @@
+package rebalancejob
+
+import (
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GenerateJob returns a job that runs gprecoverseg -r on the master at hostname, returning
+// segments that have failed over to their preferred roles
+func GenerateJob(image, hostname string) (job batchv1.Job) {
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	rebalancePod := &job.Spec.Template.Spec
+	rebalancePod.RestartPolicy = corev1.RestartPolicyNever
+
+	rebalancePod.Volumes = []corev1.Volume{
+		{
+			Name: "ssh-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  "ssh-secrets",
+					DefaultMode: heapvalue.NewInt32(0444),
+				},
+			},
+		},
+	}
+	rebalancePod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+	rebalancePod.Containers = []corev1.Container{
+		{
+			Name:  "gprecoverseg",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gprecoverseg_rebalance_job.sh",
+			},
+			Env: []corev1.EnvVar{
+				{
+					Name:  "GPRECOVERSEG_HOST",
+					Value: hostname,
+				},
+			},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ssh-key",
+					MountPath: "/etc/ssh-key",
+				},
+			},
+		},
+	}
+
+	return
+}
@@ -0,0 +1,40 @@
+package rebalancejob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("GenerateJob", func() {
+	It("sets properties on the job", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "master-0.agent.default.svc.cluster.local")
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		rebalancePod := job.Spec.Template.Spec
+		Expect(rebalancePod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+
+		sshSecretVolume := rebalancePod.Volumes[0]
+		Expect(sshSecretVolume.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolume.VolumeSource.Secret.SecretName).To(Equal("ssh-secrets"))
+		Expect(sshSecretVolume.VolumeSource.Secret.DefaultMode).To(gstruct.PointTo(Equal(int32(0444))))
+
+		Expect(rebalancePod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+		rebalanceContainer := rebalancePod.Containers[0]
+		Expect(rebalanceContainer.Name).To(Equal("gprecoverseg"))
+		Expect(rebalanceContainer.Env).To(ConsistOf(corev1.EnvVar{
+			Name:  "GPRECOVERSEG_HOST",
+			Value: "master-0.agent.default.svc.cluster.local",
+		}))
+		Expect(rebalanceContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(rebalanceContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(rebalanceContainer.Command).To(Equal([]string{
+			"/home/gpadmin/tools/gprecoverseg_rebalance_job.sh",
+		}))
+
+		sshSecretVolumeMount := rebalanceContainer.VolumeMounts[0]
+		Expect(sshSecretVolumeMount.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolumeMount.MountPath).To(Equal("/etc/ssh-key"))
+	})
+})
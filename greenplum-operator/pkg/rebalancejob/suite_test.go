@@ -0,0 +1,13 @@
+package rebalancejob
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRebalancejob(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "rebalancejob Suite")
+}
@@ -0,0 +1,91 @@
+package replicationjob
+
+import (
+	"strconv"
+
+	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GenerateJob returns a Job that copies every database of the cluster whose master is sourceHost:sourcePort
+// into the cluster whose master is destinationHost, truncating and reloading tables that already exist.
+// gpcopy connects to the source as the role in credentialsSecretName, or as gpadmin if it is empty
+func GenerateJob(image, sourceHost string, sourcePort int32, credentialsSecretName, destinationHost string) (job batchv1.Job) {
+	job.Spec.BackoffLimit = heapvalue.NewInt32(0)
+
+	gpcopyPod := &job.Spec.Template.Spec
+	gpcopyPod.RestartPolicy = corev1.RestartPolicyNever
+
+	gpcopyPod.Volumes = []corev1.Volume{
+		{
+			Name: "ssh-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  "ssh-secrets",
+					DefaultMode: heapvalue.NewInt32(0444),
+				},
+			},
+		},
+	}
+	gpcopyPod.ImagePullSecrets = []corev1.LocalObjectReference{
+		{
+			Name: "regsecret",
+		},
+	}
+	gpcopyPod.Containers = []corev1.Container{
+		{
+			Name:  "gpcopy",
+			Image: image,
+			Command: []string{
+				"/home/gpadmin/tools/gpcopy_job.sh",
+			},
+			Env: []corev1.EnvVar{
+				{
+					Name:      "GPCOPY_SOURCE_HOST",
+					Value:     sourceHost,
+					ValueFrom: nil,
+				},
+				{
+					Name:      "GPCOPY_SOURCE_PORT",
+					Value:     strconv.Itoa(int(sourcePort)),
+					ValueFrom: nil,
+				},
+				{
+					Name:      "GPCOPY_DEST_HOST",
+					Value:     destinationHost,
+					ValueFrom: nil,
+				},
+			},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ssh-key",
+					ReadOnly:  false,
+					MountPath: "/etc/ssh-key",
+				},
+			},
+		},
+	}
+
+	if credentialsSecretName != "" {
+		gpcopyContainer := &gpcopyPod.Containers[0]
+		gpcopyContainer.Env = append(gpcopyContainer.Env,
+			secretKeyEnv("GPCOPY_SOURCE_USER", credentialsSecretName, corev1.BasicAuthUsernameKey),
+			secretKeyEnv("GPCOPY_SOURCE_PASSWORD", credentialsSecretName, corev1.BasicAuthPasswordKey))
+	}
+
+	return
+}
+
+func secretKeyEnv(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package replicationjob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("GenerateJob", func() {
+	It("sets properties on the job", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "greenplum.primary-ns.svc", 5432, "", "master-0.agent.dr-ns.svc.cluster.local")
+		Expect(job.Spec.BackoffLimit).To(gstruct.PointTo(Equal(int32(0))))
+
+		gpcopyPod := job.Spec.Template.Spec
+		Expect(gpcopyPod.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+
+		sshSecretVolume := gpcopyPod.Volumes[0]
+		Expect(sshSecretVolume.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolume.VolumeSource.Secret.SecretName).To(Equal("ssh-secrets"))
+		Expect(sshSecretVolume.VolumeSource.Secret.DefaultMode).To(gstruct.PointTo(Equal(int32(0444))))
+
+		Expect(gpcopyPod.ImagePullSecrets[0].Name).To(Equal("regsecret"))
+		gpcopyContainer := gpcopyPod.Containers[0]
+		Expect(gpcopyContainer.Name).To(Equal("gpcopy"))
+		Expect(gpcopyContainer.Env).To(Equal([]corev1.EnvVar{
+			{Name: "GPCOPY_SOURCE_HOST", Value: "greenplum.primary-ns.svc"},
+			{Name: "GPCOPY_SOURCE_PORT", Value: "5432"},
+			{Name: "GPCOPY_DEST_HOST", Value: "master-0.agent.dr-ns.svc.cluster.local"},
+		}))
+		Expect(gpcopyContainer.Image).To(Equal("greenplum-for-kubernetes:magic"))
+		Expect(gpcopyContainer.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(gpcopyContainer.Command).To(Equal([]string{
+			"/home/gpadmin/tools/gpcopy_job.sh",
+		}))
+
+		sshSecretVolumeMount := gpcopyContainer.VolumeMounts[0]
+		Expect(sshSecretVolumeMount.Name).To(Equal("ssh-key"))
+		Expect(sshSecretVolumeMount.MountPath).To(Equal("/etc/ssh-key"))
+	})
+
+	It("connects to the source with the credentials from a Secret", func() {
+		job := GenerateJob("greenplum-for-kubernetes:magic", "primary.example.com", 6432, "primary-credentials", "master-0.agent.dr-ns.svc.cluster.local")
+		secretKeyRef := func(key string) *corev1.EnvVarSource {
+			return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "primary-credentials"},
+				Key:                  key,
+			}}
+		}
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "GPCOPY_SOURCE_HOST", Value: "primary.example.com"},
+			{Name: "GPCOPY_SOURCE_PORT", Value: "6432"},
+			{Name: "GPCOPY_DEST_HOST", Value: "master-0.agent.dr-ns.svc.cluster.local"},
+			{Name: "GPCOPY_SOURCE_USER", ValueFrom: secretKeyRef("username")},
+			{Name: "GPCOPY_SOURCE_PASSWORD", ValueFrom: secretKeyRef("password")},
+		}))
+	})
+})
@@ -0,0 +1,13 @@
+package replicationjob
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestReplicationjob(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "replicationjob Suite")
+}
@@ -29,4 +29,7 @@ func ModifyGreenplumAgentService(clusterName string, agentService *corev1.Servic
 	agentService.Spec.Selector = labels
 	agentService.Spec.Type = corev1.ServiceTypeClusterIP
 	agentService.Spec.ClusterIP = corev1.ClusterIPNone
+	// The instances reach each other through the DNS names of this service, which must not go away while a pod is
+	// not ready: a primary segment is not ready while its mirror is down, and must stay reachable from the master
+	agentService.Spec.PublishNotReadyAddresses = true
 }
@@ -24,6 +24,7 @@ var _ = Describe("GreenplumCluster agent service spec", func() {
 		Expect(agentService.Namespace).To(Equal(NamespaceName))
 		Expect(agentService.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
 		Expect(agentService.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+		Expect(agentService.Spec.PublishNotReadyAddresses).To(BeTrue())
 		Expect(agentService.Spec.Selector["app"]).To(Equal(AppName))
 		Expect(agentService.Spec.Selector["greenplum-cluster"]).To(Equal(ClusterName))
 		Expect(agentService.Spec.Ports[0].Port).To(Equal(int32(22)))
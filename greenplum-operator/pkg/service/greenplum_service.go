@@ -6,7 +6,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-func ModifyGreenplumService(clusterName string, greenplumService *corev1.Service) {
+// ModifyGreenplumService points the greenplum service at activeMaster, or master-0 when it is empty
+func ModifyGreenplumService(clusterName, activeMaster string, greenplumService *corev1.Service) {
 	labels := map[string]string{
 		"app":               greenplumv1.AppName,
 		"greenplum-cluster": clusterName,
@@ -33,8 +34,11 @@ func ModifyGreenplumService(clusterName string, greenplumService *corev1.Service
 	psqlPort.Protocol = corev1.ProtocolTCP
 	psqlPort.TargetPort = intstr.IntOrString{IntVal: 5432}
 
+	if activeMaster == "" {
+		activeMaster = "master-0"
+	}
 	greenplumService.Spec.Selector = map[string]string{
-		"statefulset.kubernetes.io/pod-name": "master-0",
+		"statefulset.kubernetes.io/pod-name": activeMaster,
 	}
 	greenplumService.Spec.Type = corev1.ServiceTypeLoadBalancer
 	greenplumService.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
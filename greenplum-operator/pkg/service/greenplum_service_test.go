@@ -21,7 +21,7 @@ var _ = Describe("GreenplumCluster service spec", func() {
 		}
 	})
 	It("adds the psql port to a new greenplum service", func() {
-		service.ModifyGreenplumService(ClusterName, greenplumService)
+		service.ModifyGreenplumService(ClusterName, "", greenplumService)
 		Expect(greenplumService.Name).To(Equal("greenplum"))
 		Expect(greenplumService.Namespace).To(Equal(NamespaceName))
 		Expect(greenplumService.Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
@@ -35,6 +35,10 @@ var _ = Describe("GreenplumCluster service spec", func() {
 		Expect(greenplumService.ObjectMeta.Labels["app"]).To(Equal("greenplum"))
 		Expect(greenplumService.ObjectMeta.Labels["greenplum-cluster"]).To(Equal("my-greenplum"))
 	})
+	It("selects the active master after a failover", func() {
+		service.ModifyGreenplumService(ClusterName, "master-1", greenplumService)
+		Expect(greenplumService.Spec.Selector).To(Equal(map[string]string{"statefulset.kubernetes.io/pod-name": "master-1"}))
+	})
 	When("the greenplum service already has another port, but the psql port does not exist", func() {
 		BeforeEach(func() {
 			greenplumService.Spec.Ports = []corev1.ServicePort{
@@ -47,7 +51,7 @@ var _ = Describe("GreenplumCluster service spec", func() {
 			}
 		})
 		It("adds the psql port", func() {
-			service.ModifyGreenplumService(ClusterName, greenplumService)
+			service.ModifyGreenplumService(ClusterName, "", greenplumService)
 			Expect(greenplumService.Spec.Ports).To(HaveLen(2))
 			Expect(greenplumService.Spec.Ports[0].Name).To(Equal("somethingelse"))
 			Expect(greenplumService.Spec.Ports[0].Port).To(Equal(int32(9999)))
@@ -76,7 +80,7 @@ var _ = Describe("GreenplumCluster service spec", func() {
 					TargetPort: intstr.IntOrString{IntVal: targetPort},
 				},
 			}
-			service.ModifyGreenplumService(ClusterName, greenplumService)
+			service.ModifyGreenplumService(ClusterName, "", greenplumService)
 			Expect(greenplumService.Spec.Ports).To(HaveLen(2))
 			Expect(greenplumService.Spec.Ports[0].Name).To(Equal("somethingelse"))
 			Expect(greenplumService.Spec.Ports[0].Port).To(Equal(int32(9999)))
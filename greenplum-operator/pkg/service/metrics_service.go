@@ -0,0 +1,37 @@
+package service
+
+import (
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/sset"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ModifyGreenplumMetricsService sets up a headless service that selects every pod in the
+// cluster on the health endpoint sidecar's port, so that scrapers get one endpoint per pod
+// and never go through the client-facing greenplum LoadBalancer.
+func ModifyGreenplumMetricsService(clusterName string, metricsService *corev1.Service) {
+	labels := map[string]string{
+		"app":               greenplumv1.AppName,
+		"greenplum-cluster": clusterName,
+	}
+	if metricsService.Labels == nil {
+		metricsService.Labels = make(map[string]string)
+	}
+	for key, value := range labels {
+		metricsService.Labels[key] = value
+	}
+
+	if len(metricsService.Spec.Ports) != 1 {
+		metricsService.Spec.Ports = make([]corev1.ServicePort, 1)
+	}
+	healthPort := &metricsService.Spec.Ports[0]
+	healthPort.Name = "health"
+	healthPort.Port = int32(sset.HealthEndpointPort)
+	healthPort.Protocol = corev1.ProtocolTCP
+	healthPort.TargetPort = intstr.FromString("health")
+
+	metricsService.Spec.Selector = labels
+	metricsService.Spec.Type = corev1.ServiceTypeClusterIP
+	metricsService.Spec.ClusterIP = corev1.ClusterIPNone
+}
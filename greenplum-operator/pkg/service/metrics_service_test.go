@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/service"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("GreenplumCluster metrics service spec", func() {
+	var metricsService *corev1.Service
+	BeforeEach(func() {
+		metricsService = &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "greenplum-metrics",
+				Namespace: NamespaceName,
+			},
+		}
+	})
+	It("creates a headless service for every pod in the cluster", func() {
+		service.ModifyGreenplumMetricsService(ClusterName, metricsService)
+		Expect(metricsService.Name).To(Equal("greenplum-metrics"))
+		Expect(metricsService.Namespace).To(Equal(NamespaceName))
+		Expect(metricsService.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+		Expect(metricsService.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+		Expect(metricsService.Spec.Selector).To(Equal(map[string]string{
+			"app":               AppName,
+			"greenplum-cluster": ClusterName,
+		}))
+		Expect(metricsService.ObjectMeta.Labels["app"]).To(Equal(AppName))
+		Expect(metricsService.ObjectMeta.Labels["greenplum-cluster"]).To(Equal(ClusterName))
+	})
+	It("exposes the health endpoint port", func() {
+		service.ModifyGreenplumMetricsService(ClusterName, metricsService)
+		Expect(metricsService.Spec.Ports).To(HaveLen(1))
+		Expect(metricsService.Spec.Ports[0].Name).To(Equal("health"))
+		Expect(metricsService.Spec.Ports[0].Port).To(Equal(int32(8008)))
+		Expect(metricsService.Spec.Ports[0].Protocol).To(Equal(corev1.ProtocolTCP))
+		Expect(metricsService.Spec.Ports[0].TargetPort).To(Equal(intstr.FromString("health")))
+	})
+	When("the metrics service already has other ports", func() {
+		BeforeEach(func() {
+			metricsService.Spec.Ports = []corev1.ServicePort{
+				{Name: "psql", Port: 5432},
+				{Name: "somethingelse", Port: 9999},
+			}
+		})
+		It("replaces them with the health endpoint port", func() {
+			service.ModifyGreenplumMetricsService(ClusterName, metricsService)
+			Expect(metricsService.Spec.Ports).To(HaveLen(1))
+			Expect(metricsService.Spec.Ports[0].Name).To(Equal("health"))
+			Expect(metricsService.Spec.Ports[0].Port).To(Equal(int32(8008)))
+		})
+	})
+})
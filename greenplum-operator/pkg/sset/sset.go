@@ -4,15 +4,39 @@ import (
 	"fmt"
 
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/cabundle"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/clienttls"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/entrypointscript"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/exporter"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/interconnecttls"
 	"github.com/pivotal/greenplum-for-kubernetes/pkg/heapvalue"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-const headlessServiceName = "agent"
+const (
+	headlessServiceName = "agent"
+	HealthEndpointPort  = 8008
+)
+
+const spillVolumeName = "spill"
+
+const healthEndpointContainerName = "health-endpoint"
+
+const readinessProbeTimeoutSeconds = 10
+
+// spillVolumeInitScript recreates <location>/<dbid>/GPDB_6_<catalog version>, the directory of the temp tablespace
+// on a Greenplum 6 instance, for the symlinks in pg_tblspc that point into the spill volume
+const spillVolumeInitScript = `sudo chown gpadmin:gpadmin ` + greenplumv1.TempTablespaceLocation + ` && source /usr/local/greenplum-db/greenplum_path.sh && ` +
+	`for link in /greenplum/data*/pg_tblspc/*; do ` +
+	`target=$(readlink "$link") || continue; ` +
+	`case "$target" in ` + greenplumv1.TempTablespaceLocation + `/*) ;; *) continue ;; esac; ` +
+	`catalogVersion=$(pg_controldata "${link%/pg_tblspc/*}" | awk '/^Catalog version number:/ {print $4}'); ` +
+	`mkdir -p "$target/GPDB_6_$catalogVersion" || exit 1; ` +
+	`done`
 
 type StatefulSetType string
 
@@ -23,16 +47,31 @@ const (
 )
 
 type GreenplumStatefulSetParams struct {
-	Type          StatefulSetType
-	ClusterName   string
-	Replicas      int32
-	InstanceImage string
-	GpPodSpec     greenplumv1.GreenplumPodSpec
+	Type             StatefulSetType
+	ClusterName      string
+	Replicas         int32
+	InstanceImage    string
+	GpPodSpec        greenplumv1.GreenplumPodSpec
+	GpadminHome      *greenplumv1.GreenplumGpadminHomeSpec
+	TempTablespace   *greenplumv1.GreenplumTempTablespaceSpec
+	SpillVolume      *greenplumv1.GreenplumSpillVolumeSpec
+	CABundle         *greenplumv1.GreenplumCABundleSpec
+	EntrypointScript *greenplumv1.GreenplumEntrypointScriptSpec
+	InterconnectTLS  bool
+	ClientTLS        *greenplumv1.GreenplumTLSSpec
+	SchedulerName    string
+	Sysctls          []corev1.Sysctl
+	// Exporter is set when the pods run the exporter sidecar
+	Exporter *greenplumv1.GreenplumPrometheusSpec
+	// PersistentVolumeNodeSelectorTerms keeps pods on the nodes that the data PVs of the statefulset are restricted to
+	PersistentVolumeNodeSelectorTerms []corev1.NodeSelectorTerm
 }
 
 func GenerateStatefulSetParams(ssetType StatefulSetType, cluster *greenplumv1.GreenplumCluster, instanceImage string) *GreenplumStatefulSetParams {
 	var replicaCount int32
 	var gpPodSpec greenplumv1.GreenplumPodSpec
+	var gpadminHome *greenplumv1.GreenplumGpadminHomeSpec
+	var clientTLS *greenplumv1.GreenplumTLSSpec
 
 	if ssetType == TypeMaster {
 		if cluster.Spec.MasterAndStandby.Standby == "yes" {
@@ -41,22 +80,41 @@ func GenerateStatefulSetParams(ssetType StatefulSetType, cluster *greenplumv1.Gr
 			replicaCount = 1
 		}
 		gpPodSpec = cluster.Spec.MasterAndStandby.GreenplumPodSpec
+		gpadminHome = cluster.Spec.MasterAndStandby.GpadminHome
+		clientTLS = cluster.Spec.TLS
 	} else {
 		replicaCount = cluster.Spec.Segments.PrimarySegmentCount
 		gpPodSpec = cluster.Spec.Segments.GreenplumPodSpec
 	}
 
 	return &GreenplumStatefulSetParams{
-		Type:          ssetType,
-		ClusterName:   cluster.Name,
-		Replicas:      replicaCount,
-		InstanceImage: instanceImage,
-		GpPodSpec:     gpPodSpec,
+		Type:             ssetType,
+		ClusterName:      cluster.Name,
+		Replicas:         replicaCount,
+		InstanceImage:    instanceImage,
+		GpPodSpec:        gpPodSpec,
+		GpadminHome:      gpadminHome,
+		TempTablespace:   cluster.Spec.TempTablespace,
+		SpillVolume:      cluster.Spec.SpillVolume,
+		CABundle:         cluster.Spec.CABundle,
+		EntrypointScript: cluster.Spec.EntrypointScript,
+		InterconnectTLS:  cluster.Spec.InterconnectTLS != nil,
+		ClientTLS:        clientTLS,
+		SchedulerName:    cluster.Spec.SchedulerName,
+		Sysctls:          cluster.Spec.Sysctls,
+		Exporter:         exporterSpec(ssetType, cluster.Spec.Monitoring),
 	}
 }
 
+func exporterSpec(ssetType StatefulSetType, monitoring *greenplumv1.GreenplumMonitoringSpec) *greenplumv1.GreenplumPrometheusSpec {
+	if !exporter.Enabled(monitoring, exporter.Role(greenplumRole(ssetType))) {
+		return nil
+	}
+	return monitoring.Prometheus
+}
+
 func ModifyGreenplumStatefulSet(params *GreenplumStatefulSetParams, sset *appsv1.StatefulSet) {
-	labels := generateGPClusterLabels(sset.Name, params.ClusterName)
+	labels := GenerateGPClusterLabels(sset.Name, params.ClusterName)
 
 	if sset.Labels == nil {
 		sset.Labels = make(map[string]string)
@@ -84,40 +142,201 @@ func ModifyGreenplumStatefulSet(params *GreenplumStatefulSetParams, sset *appsv1
 	templateSpec.DNSConfig = &corev1.PodDNSConfig{
 		Searches: []string{headlessServiceName + "." + sset.Namespace + ".svc.cluster.local"},
 	}
-	if len(params.GpPodSpec.WorkerSelector) > 0 {
-		templateSpec.NodeSelector = params.GpPodSpec.WorkerSelector
-	}
+	templateSpec.NodeSelector = NodeSelector(params.GpPodSpec)
+	templateSpec.Tolerations = params.GpPodSpec.Tolerations
 	templateSpec.ImagePullSecrets = []corev1.LocalObjectReference{
 		{
 			Name: "regsecret",
 		},
 	}
+	templateSpec.InitContainers = getInitContainerDefinition(params)
 	templateSpec.Containers = modifyGreenplumContainer(params, templateSpec.Containers)
+	templateSpec.Containers = modifySidecars(params, templateSpec.Containers)
 	templateSpec.Volumes = getVolumeDefinition()
+	if params.SpillVolume != nil {
+		templateSpec.Volumes = append(templateSpec.Volumes, spillVolume(*params.SpillVolume))
+	}
+	if params.CABundle != nil {
+		templateSpec.Volumes = append(templateSpec.Volumes, cabundle.Volume(*params.CABundle))
+	}
+	if params.EntrypointScript != nil {
+		templateSpec.Volumes = append(templateSpec.Volumes, entrypointscript.Volume(*params.EntrypointScript))
+	}
+	if params.InterconnectTLS {
+		templateSpec.Volumes = append(templateSpec.Volumes, interconnecttls.Volume())
+	}
+	if params.ClientTLS != nil {
+		templateSpec.Volumes = append(templateSpec.Volumes, clienttls.Volume(*params.ClientTLS))
+	}
+	if params.Exporter != nil {
+		templateSpec.Volumes = append(templateSpec.Volumes, exporter.Volumes()...)
+	}
 	if params.GpPodSpec.AntiAffinity == "yes" {
 		templateSpec.Affinity = getAffinityDefinition(params.Type, sset.Namespace)
+	} else {
+		// Drops terms left over from PVs that no longer restrict the statefulset
+		templateSpec.Affinity = nil
+		if params.Type == TypeSegmentB {
+			templateSpec.Affinity = &corev1.Affinity{PodAntiAffinity: getMirrorAntiAffinity(params.ClusterName)}
+		}
+	}
+	if len(params.PersistentVolumeNodeSelectorTerms) > 0 {
+		if templateSpec.Affinity == nil {
+			templateSpec.Affinity = &corev1.Affinity{}
+		}
+		templateSpec.Affinity.NodeAffinity = withNodeSelectorTerms(templateSpec.Affinity.NodeAffinity, params.PersistentVolumeNodeSelectorTerms)
 	}
+	templateSpec.Affinity = withUserAffinity(templateSpec.Affinity, params.GpPodSpec.Affinity)
 	templateSpec.ServiceAccountName = "greenplum-system-pod"
+	templateSpec.SchedulerName = params.SchedulerName
+	if len(params.Sysctls) > 0 || templateSpec.SecurityContext != nil {
+		if templateSpec.SecurityContext == nil {
+			templateSpec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		templateSpec.SecurityContext.Sysctls = params.Sysctls
+	}
 }
 
 func modifyGreenplumPVC(params *GreenplumStatefulSetParams, pvcs []corev1.PersistentVolumeClaim) []corev1.PersistentVolumeClaim {
-	var pvc *corev1.PersistentVolumeClaim
-	if len(pvcs) == 0 {
-		pvcs = make([]corev1.PersistentVolumeClaim, 1)
+	pvcCount := 1
+	if params.GpadminHome != nil {
+		pvcCount++
+	}
+	if params.TempTablespace != nil {
+		pvcCount++
+	}
+	for len(pvcs) < pvcCount {
+		pvcs = append(pvcs, corev1.PersistentVolumeClaim{})
+	}
+	pvcs = pvcs[:pvcCount]
+
+	modifyPVCTemplate(&pvcs[0], DataVolumeClaimName(params.ClusterName), params.GpPodSpec.StorageClassName, params.GpPodSpec.Storage)
+	next := 1
+	if params.GpadminHome != nil {
+		modifyPVCTemplate(&pvcs[next], gpadminHomeVolumeName(params), params.GpadminHome.StorageClassName, params.GpadminHome.Storage)
+		next++
+	}
+	if params.TempTablespace != nil {
+		modifyPVCTemplate(&pvcs[next], tempTablespaceVolumeName(params), params.TempTablespace.StorageClassName, params.TempTablespace.Storage)
 	}
-	pvc = &pvcs[0]
-	pvc.Name = params.ClusterName + "-pgdata"
-	pvc.Spec.StorageClassName = &params.GpPodSpec.StorageClassName
+	return pvcs
+}
+
+// modifyPVCTemplate keeps the storage of an existing template, since volumeClaimTemplates cannot be changed;
+// the operator resizes the PVCs themselves instead
+func modifyPVCTemplate(pvc *corev1.PersistentVolumeClaim, name, storageClassName string, storage resource.Quantity) {
+	if existing, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok && pvc.Name == name {
+		storage = existing
+	}
+	pvc.Name = name
+	pvc.Spec.StorageClassName = &storageClassName
 	pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
 	pvc.Spec.Resources = corev1.ResourceRequirements{
 		Limits: corev1.ResourceList{
-			corev1.ResourceStorage: params.GpPodSpec.Storage,
+			corev1.ResourceStorage: storage,
 		},
 		Requests: corev1.ResourceList{
-			corev1.ResourceStorage: params.GpPodSpec.Storage,
+			corev1.ResourceStorage: storage,
+		},
+	}
+}
+
+// DataVolumeClaimName is the name of the volumeClaimTemplate of the Greenplum data directory
+func DataVolumeClaimName(clusterName string) string {
+	return clusterName + "-pgdata"
+}
+
+func gpadminHomeVolumeName(params *GreenplumStatefulSetParams) string {
+	return params.ClusterName + "-gpadmin-home"
+}
+
+func tempTablespaceVolumeName(params *GreenplumStatefulSetParams) string {
+	return params.ClusterName + "-temp"
+}
+
+func spillVolume(spec greenplumv1.GreenplumSpillVolumeSpec) corev1.Volume {
+	sizeLimit := spec.SizeLimit.DeepCopy()
+	return corev1.Volume{
+		Name: spillVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    spec.Medium,
+				SizeLimit: &sizeLimit,
+			},
 		},
 	}
-	return pvcs
+}
+
+func getInitContainerDefinition(params *GreenplumStatefulSetParams) []corev1.Container {
+	var initContainers []corev1.Container
+	// The gpadmin home PV hides the image's /home/gpadmin when mounted, so seed it
+	// with the image's contents without clobbering anything already persisted.
+	// The tools the operator runs in jobs belong to the image, not to the user, so they are
+	// replaced on every start to keep them in step with the image after an upgrade.
+	if params.GpadminHome != nil {
+		initContainers = append(initContainers, corev1.Container{
+			Name:            "gpadmin-home-init",
+			Image:           params.InstanceImage,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command: []string{
+				"/bin/bash",
+				"-c",
+				"sudo chown gpadmin:gpadmin /mnt/gpadmin-home && cp -an /home/gpadmin/. /mnt/gpadmin-home/ && " +
+					"rm -rf /mnt/gpadmin-home/tools && cp -a /home/gpadmin/tools /mnt/gpadmin-home/tools",
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      gpadminHomeVolumeName(params),
+					MountPath: "/mnt/gpadmin-home",
+				},
+			},
+		})
+	}
+	// CREATE TABLESPACE needs its location to be owned by gpadmin on every pod
+	if params.TempTablespace != nil {
+		initContainers = append(initContainers, corev1.Container{
+			Name:            "temp-tablespace-init",
+			Image:           params.InstanceImage,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command: []string{
+				"/bin/bash",
+				"-c",
+				"sudo chown gpadmin:gpadmin " + greenplumv1.TempTablespaceLocation,
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      tempTablespaceVolumeName(params),
+					MountPath: greenplumv1.TempTablespaceLocation,
+				},
+			},
+		})
+	}
+	// The spill volume is empty whenever a pod starts, so besides giving gpadmin ownership of it, recreate the
+	// directories of the temp tablespace that its symlink in pg_tblspc points to. Without them Greenplum quietly
+	// spills into the data directory instead.
+	if params.SpillVolume != nil {
+		initContainers = append(initContainers, corev1.Container{
+			Name:            "spill-volume-init",
+			Image:           params.InstanceImage,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command: []string{
+				"/bin/bash",
+				"-c",
+				spillVolumeInitScript,
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      spillVolumeName,
+					MountPath: greenplumv1.TempTablespaceLocation,
+				},
+				{
+					Name:      DataVolumeClaimName(params.ClusterName),
+					MountPath: "/greenplum",
+				},
+			},
+		})
+	}
+	return initContainers
 }
 
 func modifyGreenplumContainer(params *GreenplumStatefulSetParams, containers []corev1.Container) []corev1.Container {
@@ -141,20 +360,23 @@ func modifyGreenplumContainer(params *GreenplumStatefulSetParams, containers []c
 		container.ReadinessProbe = &corev1.Probe{}
 	}
 	container.ReadinessProbe.ProbeHandler = corev1.ProbeHandler{
-		TCPSocket: &corev1.TCPSocketAction{
-			Port: intstr.FromInt(22),
+		Exec: &corev1.ExecAction{
+			Command: []string{"/home/gpadmin/tools/readinessProbe",
+				"--role", greenplumRole(params.Type), "--port", fmt.Sprint(greenplumPort(params.Type))},
 		},
 	}
 	container.ReadinessProbe.InitialDelaySeconds = 5
-
-	if container.Resources.Limits == nil {
-		container.Resources.Limits = make(map[corev1.ResourceName]resource.Quantity)
+	// The probe runs psql, which can take longer than the default 1 second timeout on a loaded cluster
+	if container.ReadinessProbe.TimeoutSeconds < readinessProbeTimeoutSeconds {
+		container.ReadinessProbe.TimeoutSeconds = readinessProbeTimeoutSeconds
 	}
-	if params.GpPodSpec.Memory.Cmp(container.Resources.Limits[corev1.ResourceMemory]) != 0 {
-		container.Resources.Limits[corev1.ResourceMemory] = params.GpPodSpec.Memory
+	if params.GpPodSpec.ReadinessFailureThreshold != 0 {
+		container.ReadinessProbe.FailureThreshold = params.GpPodSpec.ReadinessFailureThreshold
 	}
-	if params.GpPodSpec.CPU.Cmp(container.Resources.Limits[corev1.ResourceCPU]) != 0 {
-		container.Resources.Limits[corev1.ResourceCPU] = params.GpPodSpec.CPU
+
+	// Left alone when unchanged, since any change to the pod template restarts the pods
+	if resources := Resources(params.GpPodSpec); !equality.Semantic.DeepEqual(container.Resources, resources) {
+		container.Resources = resources
 	}
 
 	container.Env = []corev1.EnvVar{
@@ -164,6 +386,10 @@ func modifyGreenplumContainer(params *GreenplumStatefulSetParams, containers []c
 			ValueFrom: nil,
 		},
 	}
+	if params.InterconnectTLS {
+		// The master verifies the segments it dispatches to, and never falls back to a plaintext connection
+		container.Env = append(container.Env, interconnecttls.SSLModeEnv())
+	}
 
 	container.VolumeMounts = []corev1.VolumeMount{
 		{
@@ -175,7 +401,7 @@ func modifyGreenplumContainer(params *GreenplumStatefulSetParams, containers []c
 			MountPath: "/etc/config",
 		},
 		{
-			Name:      params.ClusterName + "-pgdata",
+			Name:      DataVolumeClaimName(params.ClusterName),
 			MountPath: "/greenplum",
 		},
 		{
@@ -187,10 +413,111 @@ func modifyGreenplumContainer(params *GreenplumStatefulSetParams, containers []c
 			MountPath: "/etc/podinfo",
 		},
 	}
+	if params.GpadminHome != nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      gpadminHomeVolumeName(params),
+			MountPath: "/home/gpadmin",
+		})
+	}
+	if params.TempTablespace != nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      tempTablespaceVolumeName(params),
+			MountPath: greenplumv1.TempTablespaceLocation,
+		})
+	}
+	if params.SpillVolume != nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      spillVolumeName,
+			MountPath: greenplumv1.TempTablespaceLocation,
+		})
+	}
+	if params.CABundle != nil {
+		container.VolumeMounts = append(container.VolumeMounts, cabundle.VolumeMount())
+	}
+	if params.EntrypointScript != nil {
+		container.VolumeMounts = append(container.VolumeMounts, entrypointscript.VolumeMount())
+	}
+	if params.InterconnectTLS {
+		container.VolumeMounts = append(container.VolumeMounts, interconnecttls.VolumeMount())
+	}
+	if params.ClientTLS != nil {
+		container.VolumeMounts = append(container.VolumeMounts, clienttls.VolumeMount())
+	}
 
 	return containers
 }
 
+// modifySidecars sets up the enabled containers that run beside Greenplum, after the greenplum container. Each keeps
+// the fields of the container of the same name already in the pod template
+func modifySidecars(params *GreenplumStatefulSetParams, containers []corev1.Container) []corev1.Container {
+	existing := append([]corev1.Container(nil), containers[1:]...)
+	sidecar := func(name string) corev1.Container {
+		for _, container := range existing {
+			if container.Name == name {
+				return container
+			}
+		}
+		return corev1.Container{Name: name}
+	}
+
+	containers = containers[:1]
+	if params.GpPodSpec.HealthEndpoint == "yes" {
+		container := sidecar(healthEndpointContainerName)
+		modifyHealthEndpointContainer(params, &container)
+		containers = append(containers, container)
+	}
+	if params.Exporter != nil {
+		container := sidecar(exporter.Name)
+		exporter.ModifySidecar(&container, *params.Exporter, exporter.Role(greenplumRole(params.Type)),
+			greenplumPort(params.Type), params.ClientTLS != nil)
+		containers = append(containers, container)
+	}
+	return containers
+}
+
+func modifyHealthEndpointContainer(params *GreenplumStatefulSetParams, container *corev1.Container) {
+	container.Name = healthEndpointContainerName
+	container.Image = params.InstanceImage
+	container.ImagePullPolicy = corev1.PullIfNotPresent
+	container.Args = []string{"/home/gpadmin/tools/healthEndpoint", "--port", fmt.Sprint(greenplumPort(params.Type))}
+	switch params.Type {
+	case TypeMaster:
+		container.Args = append(container.Args, "--activity")
+	case TypeSegmentB:
+		container.Args = append(container.Args, "--mirror")
+	}
+	container.Ports = []corev1.ContainerPort{
+		{
+			Name:          "health",
+			ContainerPort: HealthEndpointPort,
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+}
+
+// greenplumRole is the role that the pods of a statefulset have when the cluster is balanced
+func greenplumRole(typ StatefulSetType) string {
+	switch typ {
+	case TypeSegmentA:
+		return "primary"
+	case TypeSegmentB:
+		return "mirror"
+	default:
+		return "master"
+	}
+}
+
+func greenplumPort(typ StatefulSetType) int {
+	switch typ {
+	case TypeSegmentA:
+		return 40000
+	case TypeSegmentB:
+		return 50000
+	default:
+		return 5432
+	}
+}
+
 func getVolumeDefinition() []corev1.Volume {
 	return []corev1.Volume{
 		{
@@ -303,7 +630,150 @@ func getAffinityDefinition(typ StatefulSetType, namespace string) *corev1.Affini
 	}
 }
 
-func generateGPClusterLabels(typ, clusterName string) map[string]string {
+// getMirrorAntiAffinity prefers to keep mirrors off nodes running primaries of the same cluster when antiAffinity
+// does not already separate them. The operator moves any mirror that still lands beside its own primary.
+func getMirrorAntiAffinity(clusterName string) *corev1.PodAntiAffinity {
+	return &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+			{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"greenplum-cluster": clusterName,
+							"type":              string(TypeSegmentA),
+						},
+					},
+					TopologyKey: "kubernetes.io/hostname",
+				},
+			},
+		},
+	}
+}
+
+// withNodeSelectorTerms requires both the existing node affinity and one of terms. Node selector terms are ORed,
+// so every term is combined with every existing term.
+func withNodeSelectorTerms(nodeAffinity *corev1.NodeAffinity, terms []corev1.NodeSelectorTerm) *corev1.NodeAffinity {
+	if nodeAffinity == nil || nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: terms,
+			},
+		}
+	}
+	var combinedTerms []corev1.NodeSelectorTerm
+	for _, existingTerm := range nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, term := range terms {
+			combinedTerm := *term.DeepCopy()
+			combinedTerm.MatchExpressions = append(combinedTerm.MatchExpressions, existingTerm.MatchExpressions...)
+			combinedTerm.MatchFields = append(combinedTerm.MatchFields, existingTerm.MatchFields...)
+			combinedTerms = append(combinedTerms, combinedTerm)
+		}
+	}
+	nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = combinedTerms
+	return nodeAffinity
+}
+
+// Resources gives the resources of the greenplum container: those in the pod spec, with the memory and cpu limits
+// defaulting to memory and cpu
+func Resources(gpPodSpec greenplumv1.GreenplumPodSpec) corev1.ResourceRequirements {
+	resources := *gpPodSpec.Resources.DeepCopy()
+	if resources.Limits == nil {
+		resources.Limits = make(corev1.ResourceList)
+	}
+	if _, ok := resources.Limits[corev1.ResourceMemory]; !ok && !gpPodSpec.Memory.IsZero() {
+		resources.Limits[corev1.ResourceMemory] = gpPodSpec.Memory.DeepCopy()
+	}
+	if _, ok := resources.Limits[corev1.ResourceCPU]; !ok && !gpPodSpec.CPU.IsZero() {
+		resources.Limits[corev1.ResourceCPU] = gpPodSpec.CPU.DeepCopy()
+	}
+	return resources
+}
+
+// NodeSelector merges nodeSelector into workerSelector to give the labels of the nodes the pods may run on.
+// workerSelector wins for a key in both, though admission rejects a key given different values
+func NodeSelector(gpPodSpec greenplumv1.GreenplumPodSpec) map[string]string {
+	if len(gpPodSpec.WorkerSelector) == 0 && len(gpPodSpec.NodeSelector) == 0 {
+		return nil
+	}
+	nodeSelector := make(map[string]string, len(gpPodSpec.WorkerSelector)+len(gpPodSpec.NodeSelector))
+	for key, value := range gpPodSpec.NodeSelector {
+		nodeSelector[key] = value
+	}
+	for key, value := range gpPodSpec.WorkerSelector {
+		nodeSelector[key] = value
+	}
+	return nodeSelector
+}
+
+// IsNodeSchedulable reports whether new pods can be scheduled on node: it must be ready, not cordoned, and free of
+// NoSchedule and NoExecute taints
+func IsNodeSchedulable(node corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// withUserAffinity adds the affinity given in the GreenplumCluster to the affinity set by the operator. Required
+// node selector terms are ANDed with the operator's; every other term is added to the operator's terms.
+func withUserAffinity(affinity, userAffinity *corev1.Affinity) *corev1.Affinity {
+	if userAffinity == nil {
+		return affinity
+	}
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	}
+	userAffinity = userAffinity.DeepCopy()
+
+	if userNodeAffinity := userAffinity.NodeAffinity; userNodeAffinity != nil {
+		if required := userNodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil && len(required.NodeSelectorTerms) > 0 {
+			affinity.NodeAffinity = withNodeSelectorTerms(affinity.NodeAffinity, required.NodeSelectorTerms)
+		}
+		if len(userNodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) > 0 {
+			if affinity.NodeAffinity == nil {
+				affinity.NodeAffinity = &corev1.NodeAffinity{}
+			}
+			affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+				affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, userNodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+		}
+	}
+
+	if userPodAffinity := userAffinity.PodAffinity; userPodAffinity != nil {
+		if affinity.PodAffinity == nil {
+			affinity.PodAffinity = &corev1.PodAffinity{}
+		}
+		affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, userPodAffinity.RequiredDuringSchedulingIgnoredDuringExecution...)
+		affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, userPodAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+
+	if userPodAntiAffinity := userAffinity.PodAntiAffinity; userPodAntiAffinity != nil {
+		if affinity.PodAntiAffinity == nil {
+			affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, userPodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution...)
+		affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, userPodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+	return affinity
+}
+
+// GenerateGPClusterLabels returns the labels of the StatefulSet of the given type, which are also its selector,
+// and so the labels of its pods and PVCs
+func GenerateGPClusterLabels(typ, clusterName string) map[string]string {
 	return map[string]string{
 		"app":               greenplumv1.AppName,
 		"type":              typ,
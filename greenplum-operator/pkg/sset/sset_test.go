@@ -2,6 +2,7 @@ package sset_test
 
 import (
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gstruct"
 	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
@@ -82,6 +83,53 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 		Expect(subject.Spec.Template.Spec.NodeSelector).To(BeNil())
 	})
 
+	It("uses the default scheduler by default", func() {
+		Expect(subject.Spec.Template.Spec.SchedulerName).To(BeEmpty())
+	})
+
+	When("schedulerName is specified", func() {
+		BeforeEach(func() {
+			greenplumParams.SchedulerName = "volcano"
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+
+		It("sets the pod schedulerName", func() {
+			Expect(subject.Spec.Template.Spec.SchedulerName).To(Equal("volcano"))
+		})
+	})
+
+	It("does not set a pod securityContext by default", func() {
+		Expect(subject.Spec.Template.Spec.SecurityContext).To(BeNil())
+	})
+
+	When("sysctls are specified", func() {
+		BeforeEach(func() {
+			greenplumParams.Sysctls = []corev1.Sysctl{
+				{Name: "net.ipv4.ip_local_port_range", Value: "10000 65535"},
+				{Name: "kernel.sem", Value: "500 2048000 200 40960"},
+			}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+
+		It("sets the pod securityContext sysctls", func() {
+			Expect(subject.Spec.Template.Spec.SecurityContext.Sysctls).To(Equal([]corev1.Sysctl{
+				{Name: "net.ipv4.ip_local_port_range", Value: "10000 65535"},
+				{Name: "kernel.sem", Value: "500 2048000 200 40960"},
+			}))
+		})
+
+		When("sysctls are removed", func() {
+			BeforeEach(func() {
+				greenplumParams.Sysctls = nil
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			})
+
+			It("clears the pod securityContext sysctls", func() {
+				Expect(subject.Spec.Template.Spec.SecurityContext.Sysctls).To(BeEmpty())
+			})
+		})
+	})
+
 	When("workerSelector is specified", func() {
 		BeforeEach(func() {
 			greenplumParams.GpPodSpec.WorkerSelector = map[string]string{
@@ -95,6 +143,115 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 		})
 	})
 
+	When("nodeSelector is specified", func() {
+		BeforeEach(func() {
+			greenplumParams.GpPodSpec.WorkerSelector = map[string]string{
+				"worker": "my-greenplum-master",
+			}
+			greenplumParams.GpPodSpec.NodeSelector = map[string]string{
+				"disk":   "nvme",
+				"worker": "my-greenplum-master",
+			}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+
+		It("merges it with the WorkerSelector labels", func() {
+			Expect(subject.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{
+				"disk":   "nvme",
+				"worker": "my-greenplum-master",
+			}))
+		})
+
+		It("removes it once it is no longer given", func() {
+			greenplumParams.GpPodSpec.WorkerSelector = nil
+			greenplumParams.GpPodSpec.NodeSelector = nil
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.NodeSelector).To(BeNil())
+		})
+	})
+
+	When("tolerations are specified", func() {
+		It("sets them on the pod template", func() {
+			tolerations := []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "greenplum", Effect: corev1.TaintEffectNoSchedule},
+			}
+			greenplumParams.GpPodSpec.Tolerations = tolerations
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Tolerations).To(Equal(tolerations))
+		})
+	})
+
+	When("affinity is specified", func() {
+		var userNodeTerm corev1.NodeSelectorTerm
+		var userPodAntiAffinityTerm corev1.PodAffinityTerm
+		BeforeEach(func() {
+			userNodeTerm = corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"nvme"}},
+			}}
+			userPodAntiAffinityTerm = corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "etl"}},
+				TopologyKey:   "kubernetes.io/hostname",
+			}
+			greenplumParams.GpPodSpec.Affinity = &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{userNodeTerm},
+					},
+				},
+				PodAntiAffinity: &corev1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{userPodAntiAffinityTerm},
+				},
+			}
+		})
+
+		It("uses it when the operator sets no affinity", func() {
+			greenplumParams.Type = sset.TypeSegmentA
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Affinity).To(Equal(greenplumParams.GpPodSpec.Affinity))
+		})
+
+		It("combines it with the affinity for antiAffinity", func() {
+			greenplumParams.Type = sset.TypeMaster
+			greenplumParams.GpPodSpec.AntiAffinity = "yes"
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+
+			affinity := subject.Spec.Template.Spec.Affinity
+			nodeSelectorTerms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			Expect(nodeSelectorTerms).To(HaveLen(1))
+			Expect(nodeSelectorTerms[0].MatchExpressions).To(ConsistOf(
+				userNodeTerm.MatchExpressions[0],
+				corev1.NodeSelectorRequirement{
+					Key: "greenplum-affinity-test-namespace-master", Operator: corev1.NodeSelectorOpIn, Values: []string{"true"},
+				},
+			))
+			podAntiAffinityTerms := affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+			Expect(podAntiAffinityTerms).To(HaveLen(2))
+			Expect(podAntiAffinityTerms[0].LabelSelector.MatchExpressions[0].Values).To(Equal([]string{"master"}))
+			Expect(podAntiAffinityTerms[1]).To(Equal(userPodAntiAffinityTerm))
+		})
+
+		It("keeps the preferred mirror anti-affinity", func() {
+			greenplumParams.Type = sset.TypeSegmentB
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+
+			podAntiAffinity := subject.Spec.Template.Spec.Affinity.PodAntiAffinity
+			Expect(podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+			Expect(podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.LabelSelector.MatchLabels).To(
+				HaveKeyWithValue("type", "segment-a"))
+			Expect(podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(Equal([]corev1.PodAffinityTerm{userPodAntiAffinityTerm}))
+		})
+
+		It("does not modify the affinity it was given", func() {
+			greenplumParams.Type = sset.TypeMaster
+			greenplumParams.GpPodSpec.AntiAffinity = "yes"
+			given := greenplumParams.GpPodSpec.Affinity.DeepCopy()
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(greenplumParams.GpPodSpec.Affinity).To(Equal(given))
+			Expect(subject.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(HaveLen(2))
+		})
+	})
+
 	When("antiAffinity is specified", func() {
 		BeforeEach(func() {
 			greenplumParams.GpPodSpec.AntiAffinity = "yes"
@@ -139,6 +296,76 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 		})
 	})
 
+	When("antiAffinity is not specified", func() {
+		It("prefers to schedule segment-b pods away from segment-a pods of the same cluster", func() {
+			greenplumParams.Type = sset.TypeSegmentB
+
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Affinity).ToNot(BeNil())
+			Expect(subject.Spec.Template.Spec.Affinity.NodeAffinity).To(BeNil())
+			Expect(subject.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(BeEmpty())
+			Expect(subject.Spec.Template.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(ConsistOf(
+				corev1.WeightedPodAffinityTerm{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"greenplum-cluster": "my-greenplum", "type": "segment-a"},
+						},
+						TopologyKey: "kubernetes.io/hostname",
+					},
+				},
+			))
+		})
+		It("does not set an affinity for segment-a pods", func() {
+			greenplumParams.Type = sset.TypeSegmentA
+
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Affinity).To(BeNil())
+		})
+	})
+
+	When("the data PVs restrict the nodes", func() {
+		var pvTerms []corev1.NodeSelectorTerm
+		BeforeEach(func() {
+			greenplumParams.Type = sset.TypeSegmentA
+			pvTerms = []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: "kubernetes.io/hostname", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-1"}},
+				}},
+				{MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: "kubernetes.io/hostname", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-2"}},
+				}},
+			}
+			greenplumParams.PersistentVolumeNodeSelectorTerms = pvTerms
+		})
+
+		It("requires one of the PV node selector terms", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Affinity).NotTo(BeNil())
+			Expect(subject.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(Equal(pvTerms))
+			Expect(subject.Spec.Template.Spec.Affinity.PodAntiAffinity).To(BeNil())
+		})
+
+		It("combines them with antiAffinity", func() {
+			greenplumParams.GpPodSpec.AntiAffinity = "yes"
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			antiAffinityRequirement := corev1.NodeSelectorRequirement{
+				Key: "greenplum-affinity-test-namespace-segment", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"},
+			}
+			nodeSelectorTerms := subject.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			Expect(nodeSelectorTerms).To(HaveLen(2))
+			Expect(nodeSelectorTerms[0].MatchExpressions).To(Equal(append(pvTerms[0].MatchExpressions, antiAffinityRequirement)))
+			Expect(nodeSelectorTerms[1].MatchExpressions).To(Equal(append(pvTerms[1].MatchExpressions, antiAffinityRequirement)))
+		})
+
+		It("removes them once they are no longer given", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			greenplumParams.PersistentVolumeNodeSelectorTerms = nil
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Affinity).To(BeNil())
+		})
+	})
+
 	It("has container spec with correct parameters", func() {
 		expectedPort := []corev1.ContainerPort{
 			{
@@ -148,11 +375,12 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 		}
 		expectedProbe := &corev1.Probe{
 			ProbeHandler: corev1.ProbeHandler{
-				TCPSocket: &corev1.TCPSocketAction{
-					Port: intstr.FromInt(22),
+				Exec: &corev1.ExecAction{
+					Command: []string{"/home/gpadmin/tools/readinessProbe", "--role", "master", "--port", "5432"},
 				},
 			},
 			InitialDelaySeconds: 5,
+			TimeoutSeconds:      10,
 		}
 		expectedVolumeMounts := []corev1.VolumeMount{
 			{
@@ -218,19 +446,40 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 		})
 		It("reconciles only the fields we care about", func() {
 			reconciledProbe := subject.Spec.Template.Spec.Containers[0].ReadinessProbe
-			Expect(reconciledProbe.ProbeHandler.Exec).To(BeNil(), "should be deleted")
-			Expect(reconciledProbe.ProbeHandler.HTTPGet).To(BeNil(), "should be deleted")
-			Expect(reconciledProbe.ProbeHandler.TCPSocket).To(gstruct.PointTo(Equal(corev1.TCPSocketAction{
-				Port: intstr.FromInt(22), // overwrite
+			Expect(reconciledProbe.ProbeHandler.Exec).To(gstruct.PointTo(Equal(corev1.ExecAction{
+				Command: []string{"/home/gpadmin/tools/readinessProbe", "--role", "master", "--port", "5432"}, // overwrite
 			})))
+			Expect(reconciledProbe.ProbeHandler.HTTPGet).To(BeNil(), "should be deleted")
+			Expect(reconciledProbe.ProbeHandler.TCPSocket).To(BeNil(), "should be deleted")
 			Expect(reconciledProbe.InitialDelaySeconds).To(BeNumerically("==", 5), "overwrite")
 			Expect(reconciledProbe.TimeoutSeconds).To(BeNumerically("==", 10), "preserve")
 			Expect(reconciledProbe.PeriodSeconds).To(BeNumerically("==", 11), "preserve")
 			Expect(reconciledProbe.SuccessThreshold).To(BeNumerically("==", 12), "preserve")
 			Expect(reconciledProbe.FailureThreshold).To(BeNumerically("==", 13), "preserve")
 		})
+		It("raises a shorter timeout", func() {
+			subject.Spec.Template.Spec.Containers[0].ReadinessProbe.TimeoutSeconds = 1
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers[0].ReadinessProbe.TimeoutSeconds).To(BeNumerically("==", 10))
+		})
+		It("overwrites the failure threshold when readinessFailureThreshold is specified", func() {
+			greenplumParams.GpPodSpec.ReadinessFailureThreshold = 30
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers[0].ReadinessProbe.FailureThreshold).To(BeNumerically("==", 30))
+		})
 	})
 
+	DescribeTable("runs the readiness probe for the role of the statefulset",
+		func(typ sset.StatefulSetType, expectedCommand []string) {
+			greenplumParams.Type = typ
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers[0].ReadinessProbe.Exec.Command).To(Equal(expectedCommand))
+		},
+		Entry("master", sset.TypeMaster, []string{"/home/gpadmin/tools/readinessProbe", "--role", "master", "--port", "5432"}),
+		Entry("segment-a", sset.TypeSegmentA, []string{"/home/gpadmin/tools/readinessProbe", "--role", "primary", "--port", "40000"}),
+		Entry("segment-b", sset.TypeSegmentB, []string{"/home/gpadmin/tools/readinessProbe", "--role", "mirror", "--port", "50000"}),
+	)
+
 	It("creates all needed volume sources", func() {
 		expectedVolumes := []corev1.Volume{
 			{
@@ -320,6 +569,501 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 		Expect(volumeClaimTemplate).To(Equal(expectedVolumeClaimTemplate))
 	})
 
+	When("the storage is increased", func() {
+		BeforeEach(func() {
+			greenplumParams.GpPodSpec.Storage = resource.MustParse("10G")
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+
+		It("keeps the storage of the existing persistent volume claim template, which cannot change", func() {
+			resources := subject.Spec.VolumeClaimTemplates[0].Spec.Resources
+			Expect(resources.Requests[corev1.ResourceStorage]).To(Equal(resource.MustParse("5G")))
+			Expect(resources.Limits[corev1.ResourceStorage]).To(Equal(resource.MustParse("5G")))
+		})
+	})
+
+	It("does not create a gpadmin home volume by default", func() {
+		Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(1))
+		Expect(subject.Spec.Template.Spec.InitContainers).To(BeEmpty())
+		for _, mount := range subject.Spec.Template.Spec.Containers[0].VolumeMounts {
+			Expect(mount.MountPath).NotTo(Equal("/home/gpadmin"))
+		}
+	})
+
+	When("a gpadmin home volume is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+				StorageClassName: "homeStorageClassName",
+				Storage:          resource.MustParse("1G"),
+			}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("creates a persistent volume claim for the gpadmin home", func() {
+			var name = "homeStorageClassName"
+			var storageSize = resource.MustParse("1G")
+			expectedVolumeClaimTemplate := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-greenplum-gpadmin-home",
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{
+						corev1.ReadWriteOnce,
+					},
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							corev1.ResourceStorage: storageSize,
+						},
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: storageSize,
+						},
+					},
+					StorageClassName: &name,
+				},
+			}
+
+			Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(2))
+			Expect(subject.Spec.VolumeClaimTemplates[0].Name).To(Equal("my-greenplum-pgdata"))
+			Expect(subject.Spec.VolumeClaimTemplates[1]).To(Equal(expectedVolumeClaimTemplate))
+		})
+		It("mounts the gpadmin home volume into the greenplum container", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "my-greenplum-gpadmin-home",
+				MountPath: "/home/gpadmin",
+			}))
+		})
+		It("seeds the gpadmin home volume from the image, replacing the tools, with an init container", func() {
+			initContainers := subject.Spec.Template.Spec.InitContainers
+			Expect(initContainers).To(HaveLen(1))
+			Expect(initContainers[0].Name).To(Equal("gpadmin-home-init"))
+			Expect(initContainers[0].Image).To(Equal("my-repo:my-tag"))
+			Expect(initContainers[0].Command).To(Equal([]string{
+				"/bin/bash",
+				"-c",
+				"sudo chown gpadmin:gpadmin /mnt/gpadmin-home && cp -an /home/gpadmin/. /mnt/gpadmin-home/ && " +
+					"rm -rf /mnt/gpadmin-home/tools && cp -a /home/gpadmin/tools /mnt/gpadmin-home/tools",
+			}))
+			Expect(initContainers[0].VolumeMounts).To(Equal([]corev1.VolumeMount{
+				{
+					Name:      "my-greenplum-gpadmin-home",
+					MountPath: "/mnt/gpadmin-home",
+				},
+			}))
+		})
+		It("does not duplicate the gpadmin home volume on repeated reconciles", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(2))
+			Expect(subject.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(HaveLen(6))
+		})
+	})
+
+	It("does not create a temp tablespace volume by default", func() {
+		for _, mount := range subject.Spec.Template.Spec.Containers[0].VolumeMounts {
+			Expect(mount.MountPath).NotTo(Equal("/greenplum-temp"))
+		}
+	})
+
+	It("does not mount a CA bundle by default", func() {
+		for _, volume := range subject.Spec.Template.Spec.Volumes {
+			Expect(volume.Name).NotTo(Equal("ca-bundle"))
+		}
+	})
+
+	When("a CA bundle is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca"}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("adds a volume for the CA bundle ConfigMap", func() {
+			Expect(subject.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: "ca-bundle",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "corporate-ca"},
+						Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "greenplum-ca-bundle.crt"}},
+						DefaultMode:          heapvalue.NewInt32(0444),
+					},
+				},
+			}))
+		})
+		It("mounts the CA bundle into the trust store of the greenplum container", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "ca-bundle",
+				MountPath: "/etc/ssl/certs/greenplum-ca-bundle.crt",
+				SubPath:   "greenplum-ca-bundle.crt",
+				ReadOnly:  true,
+			}))
+		})
+		It("removes the CA bundle when it is no longer requested", func() {
+			greenplumParams.CABundle = nil
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			for _, volume := range subject.Spec.Template.Spec.Volumes {
+				Expect(volume.Name).NotTo(Equal("ca-bundle"))
+			}
+			for _, mount := range subject.Spec.Template.Spec.Containers[0].VolumeMounts {
+				Expect(mount.Name).NotTo(Equal("ca-bundle"))
+			}
+		})
+	})
+
+	It("does not mount an entrypoint script by default", func() {
+		for _, volume := range subject.Spec.Template.Spec.Volumes {
+			Expect(volume.Name).NotTo(Equal("entrypoint-script"))
+		}
+	})
+
+	When("an entrypoint script is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint"}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("adds an executable volume for the script ConfigMap", func() {
+			Expect(subject.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: "entrypoint-script",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "my-entrypoint"},
+						Items:                []corev1.KeyToPath{{Key: "entrypoint.sh", Path: "entrypoint.sh"}},
+						DefaultMode:          heapvalue.NewInt32(0555),
+					},
+				},
+			}))
+		})
+		It("mounts the script where the greenplum container runs it from", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "entrypoint-script",
+				MountPath: "/etc/greenplum-entrypoint/",
+				ReadOnly:  true,
+			}))
+		})
+		It("removes the script when it is no longer requested", func() {
+			greenplumParams.EntrypointScript = nil
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			for _, volume := range subject.Spec.Template.Spec.Volumes {
+				Expect(volume.Name).NotTo(Equal("entrypoint-script"))
+			}
+			for _, mount := range subject.Spec.Template.Spec.Containers[0].VolumeMounts {
+				Expect(mount.Name).NotTo(Equal("entrypoint-script"))
+			}
+		})
+	})
+
+	When("interconnect TLS is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.InterconnectTLS = true
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("adds a volume for the interconnect TLS secret", func() {
+			Expect(subject.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: "interconnect-tls",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName:  "interconnect-tls",
+						DefaultMode: heapvalue.NewInt32(0444),
+					},
+				},
+			}))
+		})
+		It("mounts the certificates into the greenplum container", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "interconnect-tls",
+				MountPath: "/etc/interconnect-tls",
+				ReadOnly:  true,
+			}))
+		})
+		It("makes libpq verify the servers it connects to", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "PGSSLMODE",
+				Value: "verify-full",
+			}))
+		})
+	})
+
+	When("client TLS is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.ClientTLS = &greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("adds a volume for the TLS secret", func() {
+			Expect(subject.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: "greenplum-tls",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName:  "greenplum-server-tls",
+						DefaultMode: heapvalue.NewInt32(0444),
+					},
+				},
+			}))
+		})
+		It("mounts the certificate into the greenplum container", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "greenplum-tls",
+				MountPath: "/etc/greenplum-tls",
+				ReadOnly:  true,
+			}))
+		})
+	})
+
+	When("a temp tablespace is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+				StorageClassName: "local-ssd",
+				Storage:          resource.MustParse("100G"),
+			}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("creates a persistent volume claim for the temp tablespace", func() {
+			var name = "local-ssd"
+			var storageSize = resource.MustParse("100G")
+			expectedVolumeClaimTemplate := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-greenplum-temp",
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{
+						corev1.ReadWriteOnce,
+					},
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							corev1.ResourceStorage: storageSize,
+						},
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: storageSize,
+						},
+					},
+					StorageClassName: &name,
+				},
+			}
+
+			Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(2))
+			Expect(subject.Spec.VolumeClaimTemplates[0].Name).To(Equal("my-greenplum-pgdata"))
+			Expect(subject.Spec.VolumeClaimTemplates[1]).To(Equal(expectedVolumeClaimTemplate))
+		})
+		It("mounts the temp tablespace volume into the greenplum container", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "my-greenplum-temp",
+				MountPath: "/greenplum-temp",
+			}))
+		})
+		It("gives gpadmin ownership of the temp tablespace volume with an init container", func() {
+			initContainers := subject.Spec.Template.Spec.InitContainers
+			Expect(initContainers).To(HaveLen(1))
+			Expect(initContainers[0].Name).To(Equal("temp-tablespace-init"))
+			Expect(initContainers[0].Image).To(Equal("my-repo:my-tag"))
+			Expect(initContainers[0].Command).To(Equal([]string{
+				"/bin/bash",
+				"-c",
+				"sudo chown gpadmin:gpadmin /greenplum-temp",
+			}))
+			Expect(initContainers[0].VolumeMounts).To(Equal([]corev1.VolumeMount{
+				{
+					Name:      "my-greenplum-temp",
+					MountPath: "/greenplum-temp",
+				},
+			}))
+		})
+		When("a gpadmin home volume is also requested", func() {
+			BeforeEach(func() {
+				greenplumParams.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+					StorageClassName: "homeStorageClassName",
+					Storage:          resource.MustParse("1G"),
+				}
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			})
+			It("creates the temp tablespace claim after the gpadmin home claim", func() {
+				Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(3))
+				Expect(subject.Spec.VolumeClaimTemplates[1].Name).To(Equal("my-greenplum-gpadmin-home"))
+				Expect(*subject.Spec.VolumeClaimTemplates[1].Spec.StorageClassName).To(Equal("homeStorageClassName"))
+				Expect(subject.Spec.VolumeClaimTemplates[2].Name).To(Equal("my-greenplum-temp"))
+				Expect(*subject.Spec.VolumeClaimTemplates[2].Spec.StorageClassName).To(Equal("local-ssd"))
+			})
+			It("runs both init containers", func() {
+				initContainers := subject.Spec.Template.Spec.InitContainers
+				Expect(initContainers).To(HaveLen(2))
+				Expect(initContainers[0].Name).To(Equal("gpadmin-home-init"))
+				Expect(initContainers[1].Name).To(Equal("temp-tablespace-init"))
+			})
+		})
+		It("does not duplicate the temp tablespace volume on repeated reconciles", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(2))
+			Expect(subject.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(HaveLen(6))
+		})
+	})
+
+	When("a spill volume is requested", func() {
+		BeforeEach(func() {
+			greenplumParams.SpillVolume = &greenplumv1.GreenplumSpillVolumeSpec{
+				SizeLimit: resource.MustParse("50Gi"),
+			}
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+		})
+		It("adds an emptyDir volume limited to the requested size", func() {
+			sizeLimit := resource.MustParse("50Gi")
+			Expect(subject.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: "spill",
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{
+						SizeLimit: &sizeLimit,
+					},
+				},
+			}))
+		})
+		It("does not create a persistent volume claim for it", func() {
+			Expect(subject.Spec.VolumeClaimTemplates).To(HaveLen(1))
+		})
+		It("mounts the spill volume at the temp tablespace location", func() {
+			Expect(subject.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "spill",
+				MountPath: "/greenplum-temp",
+			}))
+		})
+		It("recreates the temp tablespace directory in the spill volume with an init container", func() {
+			initContainers := subject.Spec.Template.Spec.InitContainers
+			Expect(initContainers).To(HaveLen(1))
+			Expect(initContainers[0].Name).To(Equal("spill-volume-init"))
+			Expect(initContainers[0].Image).To(Equal("my-repo:my-tag"))
+			Expect(initContainers[0].Command).To(HaveLen(3))
+			Expect(initContainers[0].Command[2]).To(HavePrefix("sudo chown gpadmin:gpadmin /greenplum-temp && "))
+			Expect(initContainers[0].Command[2]).To(ContainSubstring("for link in /greenplum/data*/pg_tblspc/*; do "))
+			Expect(initContainers[0].Command[2]).To(ContainSubstring(`mkdir -p "$target/GPDB_6_$catalogVersion"`))
+			Expect(initContainers[0].VolumeMounts).To(Equal([]corev1.VolumeMount{
+				{
+					Name:      "spill",
+					MountPath: "/greenplum-temp",
+				},
+				{
+					Name:      "my-greenplum-pgdata",
+					MountPath: "/greenplum",
+				},
+			}))
+		})
+		When("the volume is backed by memory", func() {
+			BeforeEach(func() {
+				greenplumParams.SpillVolume.Medium = corev1.StorageMediumMemory
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			})
+			It("uses a tmpfs", func() {
+				var spill corev1.Volume
+				for _, volume := range subject.Spec.Template.Spec.Volumes {
+					if volume.Name == "spill" {
+						spill = volume
+					}
+				}
+				Expect(spill.EmptyDir).NotTo(BeNil())
+				Expect(spill.EmptyDir.Medium).To(Equal(corev1.StorageMediumMemory))
+			})
+		})
+	})
+
+	It("does not create a health endpoint sidecar by default", func() {
+		Expect(subject.Spec.Template.Spec.Containers).To(HaveLen(1))
+	})
+
+	When("the health endpoint is enabled", func() {
+		BeforeEach(func() {
+			greenplumParams.GpPodSpec.HealthEndpoint = "yes"
+		})
+		DescribeTable("generates a health endpoint sidecar checking the instance port",
+			func(typ sset.StatefulSetType, expectedArgs []string) {
+				greenplumParams.Type = typ
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+
+				containers := subject.Spec.Template.Spec.Containers
+				Expect(containers).To(HaveLen(2))
+				Expect(containers[0].Name).To(Equal("greenplum"))
+				Expect(containers[1].Name).To(Equal("health-endpoint"))
+				Expect(containers[1].Image).To(Equal("my-repo:my-tag"))
+				Expect(containers[1].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+				Expect(containers[1].Args).To(Equal(expectedArgs))
+				Expect(containers[1].Ports).To(Equal([]corev1.ContainerPort{
+					{
+						Name:          "health",
+						ContainerPort: 8008,
+						Protocol:      corev1.ProtocolTCP,
+					},
+				}))
+			},
+			Entry("master", sset.TypeMaster, []string{"/home/gpadmin/tools/healthEndpoint", "--port", "5432", "--activity"}),
+			Entry("segment-a", sset.TypeSegmentA, []string{"/home/gpadmin/tools/healthEndpoint", "--port", "40000"}),
+			Entry("segment-b", sset.TypeSegmentB, []string{"/home/gpadmin/tools/healthEndpoint", "--port", "50000", "--mirror"}),
+		)
+		It("removes the sidecar when disabled again", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers).To(HaveLen(2))
+
+			greenplumParams.GpPodSpec.HealthEndpoint = "no"
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(subject.Spec.Template.Spec.Containers[0].Name).To(Equal("greenplum"))
+		})
+	})
+
+	When("the exporter is enabled", func() {
+		BeforeEach(func() {
+			greenplumParams.Exporter = &greenplumv1.GreenplumPrometheusSpec{Enabled: true}
+		})
+		DescribeTable("generates an exporter sidecar connecting to the instance port",
+			func(typ sset.StatefulSetType, expectedQueries, expectedURI string) {
+				greenplumParams.Type = typ
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+
+				containers := subject.Spec.Template.Spec.Containers
+				Expect(containers).To(HaveLen(2))
+				Expect(containers[1].Name).To(Equal("greenplum-exporter"))
+				Expect(containers[1].Args).To(ContainElement("--extend.query-path=/etc/greenplum-exporter/" + expectedQueries))
+				Expect(containers[1].Env).To(ContainElement(corev1.EnvVar{Name: "DATA_SOURCE_URI", Value: expectedURI}))
+				Expect(containers[1].Ports).To(Equal([]corev1.ContainerPort{
+					{
+						Name:          "metrics",
+						ContainerPort: 9187,
+						Protocol:      corev1.ProtocolTCP,
+					},
+				}))
+			},
+			Entry("master", sset.TypeMaster, "master.yaml", "localhost:5432/postgres?sslmode=disable"),
+			Entry("segment-a", sset.TypeSegmentA, "segment.yaml",
+				"localhost:40000/postgres?sslmode=disable&options=-c%20gp_session_role%3Dutility"),
+			Entry("segment-b", sset.TypeSegmentB, "segment.yaml",
+				"localhost:50000/postgres?sslmode=disable&options=-c%20gp_session_role%3Dutility"),
+		)
+		It("mounts the queries and the connection secret", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+
+			var volumeNames []string
+			for _, volume := range subject.Spec.Template.Spec.Volumes {
+				volumeNames = append(volumeNames, volume.Name)
+			}
+			Expect(volumeNames).To(ContainElements("exporter-queries", "exporter-connection"))
+		})
+		It("runs after the health endpoint", func() {
+			greenplumParams.GpPodSpec.HealthEndpoint = "yes"
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+
+			containers := subject.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(3))
+			Expect(containers[1].Name).To(Equal("health-endpoint"))
+			Expect(containers[2].Name).To(Equal("greenplum-exporter"))
+
+			greenplumParams.GpPodSpec.HealthEndpoint = "no"
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers).To(HaveLen(2))
+			Expect(subject.Spec.Template.Spec.Containers[1].Name).To(Equal("greenplum-exporter"))
+		})
+		It("removes the sidecar and its volumes when disabled again", func() {
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers).To(HaveLen(2))
+
+			greenplumParams.Exporter = nil
+			sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			Expect(subject.Spec.Template.Spec.Containers).To(HaveLen(1))
+			for _, volume := range subject.Spec.Template.Spec.Volumes {
+				Expect(volume.Name).NotTo(HavePrefix("exporter-"))
+			}
+		})
+	})
+
 	Context("resource limits tests", func() {
 		When("resource limits are not provided", func() {
 			It("does not apply pod resource limits if none are provided", func() {
@@ -372,6 +1116,46 @@ var _ = Describe("GreenplumCluster StatefulSet spec", func() {
 				Expect(resourceLimitsDef.Memory().String()).To(Equal("500Gi"))
 			})
 		})
+
+		When("resources are provided", func() {
+			BeforeEach(func() {
+				greenplumParams.GpPodSpec.Memory = resource.MustParse("2Gi")
+				greenplumParams.GpPodSpec.CPU = resource.MustParse("2")
+				greenplumParams.GpPodSpec.Resources = corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("4"),
+					},
+				}
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+			})
+			It("applies the requests, and limits defaulting to memory and cpu", func() {
+				Expect(subject.Spec.Template.Spec.Containers[0].Resources).To(Equal(corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("2Gi"),
+						corev1.ResourceCPU:    resource.MustParse("4"),
+					},
+				}))
+			})
+			It("updates the resources in place when they change", func() {
+				greenplumParams.GpPodSpec.Resources.Requests[corev1.ResourceMemory] = resource.MustParse("1536Mi")
+				volumeClaimTemplates := subject.Spec.VolumeClaimTemplates
+				sset.ModifyGreenplumStatefulSet(greenplumParams, subject)
+				Expect(subject.Spec.Template.Spec.Containers[0].Resources.Requests.Memory().String()).To(Equal("1536Mi"))
+				Expect(subject.Spec.VolumeClaimTemplates).To(Equal(volumeClaimTemplates))
+			})
+			It("does not share the maps of the pod spec", func() {
+				subject.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("3")
+				Expect(greenplumParams.GpPodSpec.Resources.Requests.Cpu().String()).To(Equal("500m"))
+			})
+		})
 	})
 })
 
@@ -414,6 +1198,15 @@ var _ = Describe("GenerateStatefulSetParams", func() {
 
 			Expect(params.GpPodSpec.Storage).To(Equal(resource.MustParse("10Gi")))
 		})
+		It("gets the gpadmin home spec", func() {
+			cluster.Spec.MasterAndStandby.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+				StorageClassName: "standard",
+				Storage:          resource.MustParse("1Gi"),
+			}
+			params := sset.GenerateStatefulSetParams(sset.TypeMaster, cluster, instanceImage)
+
+			Expect(params.GpadminHome).To(Equal(cluster.Spec.MasterAndStandby.GpadminHome))
+		})
 		When("standby = yes", func() {
 			BeforeEach(func() {
 				cluster.Spec.MasterAndStandby.Standby = "yes"
@@ -443,11 +1236,83 @@ var _ = Describe("GenerateStatefulSetParams", func() {
 			Expect(params.ClusterName).To(Equal("my-greenplum"))
 			Expect(params.InstanceImage).To(Equal(instanceImage))
 		})
+		It("gets the schedulerName", func() {
+			cluster.Spec.SchedulerName = "volcano"
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.SchedulerName).To(Equal("volcano"))
+		})
+		It("gets the sysctls", func() {
+			cluster.Spec.Sysctls = []corev1.Sysctl{{Name: "kernel.sem", Value: "500 2048000 200 40960"}}
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.Sysctls).To(Equal([]corev1.Sysctl{{Name: "kernel.sem", Value: "500 2048000 200 40960"}}))
+		})
 		It("gets the segments pod spec", func() {
 			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
 
 			Expect(params.GpPodSpec.Storage).To(Equal(resource.MustParse("20Gi")))
 		})
+		It("gets the temp tablespace spec", func() {
+			cluster.Spec.TempTablespace = &greenplumv1.GreenplumTempTablespaceSpec{
+				StorageClassName: "local-ssd",
+				Storage:          resource.MustParse("100Gi"),
+			}
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.TempTablespace).To(Equal(cluster.Spec.TempTablespace))
+		})
+		It("gets the spill volume spec", func() {
+			cluster.Spec.SpillVolume = &greenplumv1.GreenplumSpillVolumeSpec{SizeLimit: resource.MustParse("50Gi")}
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.SpillVolume).To(Equal(cluster.Spec.SpillVolume))
+		})
+		It("gets the TLS spec for the master only", func() {
+			cluster.Spec.TLS = &greenplumv1.GreenplumTLSSpec{SecretName: "greenplum-server-tls"}
+
+			Expect(sset.GenerateStatefulSetParams(sset.TypeMaster, cluster, instanceImage).ClientTLS).To(Equal(cluster.Spec.TLS))
+			Expect(sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage).ClientTLS).To(BeNil())
+		})
+		It("gets the CA bundle spec", func() {
+			cluster.Spec.CABundle = &greenplumv1.GreenplumCABundleSpec{ConfigMapName: "corporate-ca", Key: "bundle.pem"}
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.CABundle).To(Equal(cluster.Spec.CABundle))
+		})
+		It("gets the entrypoint script spec", func() {
+			cluster.Spec.EntrypointScript = &greenplumv1.GreenplumEntrypointScriptSpec{ConfigMapName: "my-entrypoint", Key: "setup.sh"}
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.EntrypointScript).To(Equal(cluster.Spec.EntrypointScript))
+		})
+		It("enables interconnect TLS when it is requested", func() {
+			Expect(sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage).InterconnectTLS).To(BeFalse())
+			cluster.Spec.InterconnectTLS = &greenplumv1.GreenplumInterconnectTLSSpec{}
+			Expect(sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage).InterconnectTLS).To(BeTrue())
+		})
+		It("does not set a gpadmin home spec", func() {
+			cluster.Spec.MasterAndStandby.GpadminHome = &greenplumv1.GreenplumGpadminHomeSpec{
+				StorageClassName: "standard",
+				Storage:          resource.MustParse("1Gi"),
+			}
+			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
+
+			Expect(params.GpadminHome).To(BeNil())
+		})
+		It("enables the exporter of the roles that it is enabled for", func() {
+			Expect(sset.GenerateStatefulSetParams(sset.TypeMaster, cluster, instanceImage).Exporter).To(BeNil())
+
+			cluster.Spec.Monitoring = &greenplumv1.GreenplumMonitoringSpec{
+				Prometheus: &greenplumv1.GreenplumPrometheusSpec{
+					Enabled:  true,
+					Segments: &greenplumv1.GreenplumPrometheusSegmentsSpec{Mirror: true},
+				},
+			}
+			Expect(sset.GenerateStatefulSetParams(sset.TypeMaster, cluster, instanceImage).Exporter).To(Equal(cluster.Spec.Monitoring.Prometheus))
+			Expect(sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage).Exporter).To(BeNil())
+			Expect(sset.GenerateStatefulSetParams(sset.TypeSegmentB, cluster, instanceImage).Exporter).To(Equal(cluster.Spec.Monitoring.Prometheus))
+		})
 		It("sets replicas to primarySegmentCount", func() {
 			params := sset.GenerateStatefulSetParams(sset.TypeSegmentA, cluster, instanceImage)
 
@@ -455,3 +1320,41 @@ var _ = Describe("GenerateStatefulSetParams", func() {
 		})
 	})
 })
+
+var _ = Describe("IsNodeSchedulable", func() {
+	var node corev1.Node
+	BeforeEach(func() {
+		node = corev1.Node{
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+	})
+	It("is true for a ready node", func() {
+		Expect(sset.IsNodeSchedulable(node)).To(BeTrue())
+	})
+	It("is false for a cordoned node", func() {
+		node.Spec.Unschedulable = true
+		Expect(sset.IsNodeSchedulable(node)).To(BeFalse())
+	})
+	DescribeTable("taints",
+		func(effect corev1.TaintEffect, schedulable bool) {
+			node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Effect: effect}}
+			Expect(sset.IsNodeSchedulable(node)).To(Equal(schedulable))
+		},
+		Entry("NoSchedule", corev1.TaintEffectNoSchedule, false),
+		Entry("NoExecute", corev1.TaintEffectNoExecute, false),
+		Entry("PreferNoSchedule", corev1.TaintEffectPreferNoSchedule, true),
+	)
+	It("is false for a node that is not ready", func() {
+		node.Status.Conditions[1].Status = corev1.ConditionUnknown
+		Expect(sset.IsNodeSchedulable(node)).To(BeFalse())
+	})
+	It("is false for a node that has not reported whether it is ready", func() {
+		node.Status.Conditions = node.Status.Conditions[:1]
+		Expect(sset.IsNodeSchedulable(node)).To(BeFalse())
+	})
+})
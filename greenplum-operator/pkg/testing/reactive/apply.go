@@ -0,0 +1,386 @@
+package reactive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ApplyPatchAction is the action recorded for a server-side apply. PatchActionImpl has nowhere to keep
+// the patch options, so the field manager and force flag are carried alongside it.
+type ApplyPatchAction struct {
+	testing.PatchActionImpl
+	FieldManager string
+	Force        bool
+}
+
+var _ testing.PatchAction = ApplyPatchAction{}
+
+func (a ApplyPatchAction) DeepCopy() testing.Action {
+	return ApplyPatchAction{
+		PatchActionImpl: a.PatchActionImpl.DeepCopy().(testing.PatchActionImpl),
+		FieldManager:    a.FieldManager,
+		Force:           a.Force,
+	}
+}
+
+// fieldPath is the path of a field from the root of an object, e.g. [metadata labels app]
+type fieldPath []string
+
+func (p fieldPath) String() string {
+	return "." + strings.Join(p, ".")
+}
+
+// fieldSet holds field paths keyed by their JSON encoding, which unlike String is unambiguous
+// for keys containing dots, such as label names
+type fieldSet map[string]fieldPath
+
+func (s fieldSet) insert(path fieldPath) {
+	key, _ := json.Marshal(path)
+	s[string(key)] = append(fieldPath{}, path...)
+}
+
+func (s fieldSet) has(path fieldPath) bool {
+	key, _ := json.Marshal(path)
+	_, ok := s[string(key)]
+	return ok
+}
+
+func (s fieldSet) remove(path fieldPath) {
+	key, _ := json.Marshal(path)
+	delete(s, string(key))
+}
+
+func (s fieldSet) sorted() []fieldPath {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	paths := make([]fieldPath, len(keys))
+	for i, key := range keys {
+		paths[i] = s[key]
+	}
+	return paths
+}
+
+// untrackedFields identify the object, or are set by the apiserver, so no manager owns them
+var untrackedFields = map[string]bool{
+	".apiVersion":                 true,
+	".kind":                       true,
+	".metadata.name":              true,
+	".metadata.namespace":         true,
+	".metadata.uid":               true,
+	".metadata.resourceVersion":   true,
+	".metadata.generation":        true,
+	".metadata.creationTimestamp": true,
+	".metadata.deletionTimestamp": true,
+	".metadata.managedFields":     true,
+	".metadata.selfLink":          true,
+}
+
+// appliedFields returns the leaf fields set in content. Lists are atomic, so a list is a single leaf.
+// Null values and empty maps, as a typed object marshals its unset fields, set nothing.
+func appliedFields(content map[string]interface{}) fieldSet {
+	fields := fieldSet{}
+	var walk func(path fieldPath, value interface{})
+	walk = func(path fieldPath, value interface{}) {
+		if untrackedFields[path.String()] || value == nil {
+			return
+		}
+		if m, ok := value.(map[string]interface{}); ok {
+			for key, child := range m {
+				walk(append(path, key), child)
+			}
+			return
+		}
+		fields.insert(path)
+	}
+	for key, value := range content {
+		walk(fieldPath{key}, value)
+	}
+	return fields
+}
+
+func lookupField(content map[string]interface{}, path fieldPath) (interface{}, bool) {
+	var value interface{} = content
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func setField(content map[string]interface{}, path fieldPath, value interface{}) {
+	m := content
+	for _, key := range path[:len(path)-1] {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			m[key] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+// removeField deletes the field at path, and any maps on the path that it leaves empty
+func removeField(content map[string]interface{}, path fieldPath) {
+	parent, ok := lookupField(content, path[:len(path)-1])
+	if !ok {
+		return
+	}
+	m, ok := parent.(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(m, path[len(path)-1])
+	if len(m) == 0 && len(path) > 1 {
+		removeField(content, path[:len(path)-1])
+	}
+}
+
+// encodeFieldsV1 renders fields in the FieldsV1 format of managedFields, e.g. {"f:metadata":{"f:labels":{"f:app":{}}}}
+func encodeFieldsV1(fields fieldSet) (*metav1.FieldsV1, error) {
+	root := map[string]interface{}{}
+	for _, path := range fields {
+		node := root
+		for _, key := range path {
+			child, ok := node["f:"+key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node["f:"+key] = child
+			}
+			node = child
+		}
+	}
+	raw, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.FieldsV1{Raw: raw}, nil
+}
+
+// decodeFieldsV1 returns the leaf fields of a FieldsV1. Keys other than field names, such as the
+// k: keys of list items, are not written by apply here and are skipped.
+func decodeFieldsV1(fieldsV1 *metav1.FieldsV1) (fieldSet, error) {
+	fields := fieldSet{}
+	if fieldsV1 == nil || len(fieldsV1.Raw) == 0 {
+		return fields, nil
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(fieldsV1.Raw, &root); err != nil {
+		return nil, err
+	}
+	var walk func(path fieldPath, node map[string]interface{})
+	walk = func(path fieldPath, node map[string]interface{}) {
+		isLeaf := true
+		for key, child := range node {
+			if !strings.HasPrefix(key, "f:") {
+				continue
+			}
+			isLeaf = false
+			childNode, _ := child.(map[string]interface{})
+			walk(append(path, strings.TrimPrefix(key, "f:")), childNode)
+		}
+		if isLeaf && len(path) > 0 {
+			fields.insert(path)
+		}
+	}
+	walk(nil, root)
+	return fields, nil
+}
+
+// toContent round-trips obj through JSON, so that its values compare equal to those of a decoded patch
+func toContent(obj interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// apply emulates server-side apply for fieldManager, recording the fields each manager applied in
+// metadata.managedFields. Fields that fieldManager applied before but left out of patch are removed,
+// unless another manager also owns them; fields owned by other managers are left alone. Applying a
+// different value to a field that another manager owns is a conflict, unless force takes it over.
+// Writes other than apply do not take ownership of fields.
+func (r *Client) apply(ctx context.Context, obj client.Object, patch []byte, fieldManager string, force bool) error {
+	if fieldManager == "" {
+		return apierrs.NewBadRequest("PATCH, fieldManager is required for apply requests")
+	}
+	var applied map[string]interface{}
+	if err := yaml.Unmarshal(patch, &applied); err != nil {
+		return apierrs.NewBadRequest(fmt.Sprintf("invalid apply patch: %s", err))
+	}
+	fields := appliedFields(applied)
+	kind := r.kindForObject(obj)
+
+	stored := r.newNamedObject(kind, obj.GetNamespace(), obj.GetName())
+	err := r.delegate.Get(ctx, client.ObjectKeyFromObject(obj), stored)
+	if apierrs.IsNotFound(err) {
+		created := r.newNamedObject(kind, obj.GetNamespace(), obj.GetName())
+		if err := r.fromContent(applied, created); err != nil {
+			return err
+		}
+		created.SetNamespace(obj.GetNamespace())
+		created.SetName(obj.GetName())
+		managedFields, err := applyManagedFields(nil, nil, map[string]fieldSet{fieldManager: fields}, kind)
+		if err != nil {
+			return err
+		}
+		created.SetManagedFields(managedFields)
+		return r.delegate.Create(ctx, created)
+	} else if err != nil {
+		return err
+	}
+
+	original, err := toContent(stored)
+	if err != nil {
+		return err
+	}
+	content, err := toContent(stored)
+	if err != nil {
+		return err
+	}
+	owners := map[string]fieldSet{}
+	var otherEntries []metav1.ManagedFieldsEntry
+	previousEntries := map[string]metav1.ManagedFieldsEntry{}
+	for _, entry := range stored.GetManagedFields() {
+		if entry.Operation != metav1.ManagedFieldsOperationApply {
+			otherEntries = append(otherEntries, entry)
+			continue
+		}
+		previousEntries[entry.Manager] = entry
+		if owners[entry.Manager], err = decodeFieldsV1(entry.FieldsV1); err != nil {
+			return err
+		}
+	}
+	previousFields := owners[fieldManager]
+	delete(owners, fieldManager)
+
+	var managers []string
+	for manager := range owners {
+		managers = append(managers, manager)
+	}
+	sort.Strings(managers)
+	var conflicts []metav1.StatusCause
+	for _, path := range fields.sorted() {
+		appliedValue, _ := lookupField(applied, path)
+		storedValue, _ := lookupField(content, path)
+		for _, manager := range managers {
+			if !owners[manager].has(path) || reflect.DeepEqual(appliedValue, storedValue) {
+				continue
+			}
+			if force {
+				owners[manager].remove(path)
+				continue
+			}
+			conflicts = append(conflicts, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: fmt.Sprintf("conflict with %q", manager),
+				Field:   path.String(),
+			})
+		}
+	}
+	if len(conflicts) > 0 {
+		var messages []string
+		for _, conflict := range conflicts {
+			messages = append(messages, fmt.Sprintf("%s: %s", conflict.Message, conflict.Field))
+		}
+		return apierrs.NewApplyConflict(conflicts, fmt.Sprintf("Apply failed with %d conflicts: %s", len(conflicts), strings.Join(messages, "\n")))
+	}
+
+	for _, path := range previousFields.sorted() {
+		if fields.has(path) {
+			continue
+		}
+		ownedByOther := false
+		for _, manager := range managers {
+			ownedByOther = ownedByOther || owners[manager].has(path)
+		}
+		if !ownedByOther {
+			removeField(content, path)
+		}
+	}
+	for _, path := range fields.sorted() {
+		value, _ := lookupField(applied, path)
+		setField(content, path, value)
+	}
+
+	updated := r.newNamedObject(kind, obj.GetNamespace(), obj.GetName())
+	if err := r.fromContent(content, updated); err != nil {
+		return err
+	}
+	owners[fieldManager] = fields
+	managedFields, err := applyManagedFields(otherEntries, previousEntries, owners, kind)
+	if err != nil {
+		return err
+	}
+	updated.SetManagedFields(managedFields)
+	// Like the apiserver, an apply that changes nothing does not write the object
+	if reflect.DeepEqual(content, original) && reflect.DeepEqual(managedFields, stored.GetManagedFields()) {
+		return nil
+	}
+	return r.delegate.Update(ctx, updated)
+}
+
+func (r *Client) fromContent(content map[string]interface{}, obj client.Object) error {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, obj)
+}
+
+// applyManagedFields returns otherEntries followed by an Apply entry for each manager that owns any fields, by manager name.
+// The entry of a manager whose fields are unchanged is kept as it was in previousEntries.
+func applyManagedFields(otherEntries []metav1.ManagedFieldsEntry, previousEntries map[string]metav1.ManagedFieldsEntry, owners map[string]fieldSet, kind schema.GroupVersionKind) ([]metav1.ManagedFieldsEntry, error) {
+	var managers []string
+	for manager, fields := range owners {
+		if len(fields) > 0 {
+			managers = append(managers, manager)
+		}
+	}
+	sort.Strings(managers)
+	now := metav1.Now()
+	managedFields := append([]metav1.ManagedFieldsEntry{}, otherEntries...)
+	for _, manager := range managers {
+		fieldsV1, err := encodeFieldsV1(owners[manager])
+		if err != nil {
+			return nil, err
+		}
+		if previous, ok := previousEntries[manager]; ok && previous.FieldsV1 != nil && string(previous.FieldsV1.Raw) == string(fieldsV1.Raw) {
+			managedFields = append(managedFields, previous)
+			continue
+		}
+		managedFields = append(managedFields, metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: kind.GroupVersion().String(),
+			Time:       &now,
+			FieldsType: "FieldsV1",
+			FieldsV1:   fieldsV1,
+		})
+	}
+	return managedFields, nil
+}
@@ -1,26 +1,82 @@
 package reactive
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
 )
 
 type Client struct {
 	testing.Fake
 	delegate   client.Client
 	restMapper meta.RESTMapper
+
+	contextsLock   sync.Mutex
+	actionContexts []ActionContext
+
+	// invokeLock serializes calls through the reactors, so that the reactor passing actions on to
+	// the delegate can use the caller's invokeCtx
+	invokeLock sync.Mutex
+	invokeCtx  context.Context
+
+	cacheLock sync.Mutex
+	cache     map[cacheKey]runtime.Object
+	staleGets int
+
+	indexLock sync.Mutex
+	indexes   map[schema.GroupVersionKind]map[string]client.IndexerFunc
+}
+
+var (
+	_ client.WithWatch    = &Client{}
+	_ client.FieldIndexer = &Client{}
+)
+
+type cacheKey struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// ActionContext pairs an action from the action log with the context the caller passed in,
+// so tests can assert that deadlines and values were propagated.
+type ActionContext struct {
+	testing.Action
+	Context context.Context
+}
+
+func (a ActionContext) Deadline() (time.Time, bool) {
+	return a.Context.Deadline()
 }
 
 var _ client.Client = &Client{}
@@ -39,22 +95,60 @@ type workaroundListAction interface {
 	GetKind() schema.GroupVersionKind
 }
 
-func NewClient(delegate client.Client) *Client {
+// clusterScopedKinds are the built-in kinds that are not namespaced. The scheme does not record scope, so any
+// other cluster-scoped kind must be passed to NewClient.
+var clusterScopedKinds = []schema.GroupKind{
+	{Group: "", Kind: "Namespace"},
+	{Group: "", Kind: "Node"},
+	{Group: "", Kind: "PersistentVolume"},
+	{Group: "", Kind: "ComponentStatus"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+	{Group: "storage.k8s.io", Kind: "StorageClass"},
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"},
+	{Group: "storage.k8s.io", Kind: "CSINode"},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"},
+	{Group: "policy", Kind: "PodSecurityPolicy"},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"},
+	{Group: "networking.k8s.io", Kind: "IngressClass"},
+}
+
+// NewClient returns a Client that passes actions on to delegate after the reactors. Kinds in clusterScoped are
+// treated as cluster-scoped in addition to the built-in clusterScopedKinds; everything else is namespaced.
+func NewClient(delegate client.Client, clusterScoped ...schema.GroupKind) *Client {
 	clientScheme := delegate.Scheme()
 	gvs := clientScheme.PrioritizedVersionsAllGroups()
 	restMapper := meta.NewDefaultRESTMapper(gvs)
+	rootKinds := map[schema.GroupKind]bool{}
+	for _, gk := range append(clusterScopedKinds, clusterScoped...) {
+		rootKinds[gk] = true
+	}
 	knownTypes := clientScheme.AllKnownTypes()
 	for gvk := range knownTypes {
-		restMapper.Add(gvk, meta.RESTScopeNamespace)
+		// List kinds share the scope of their items
+		if rootKinds[gvk.GroupKind()] || rootKinds[schema.GroupKind{Group: gvk.Group, Kind: strings.TrimSuffix(gvk.Kind, "List")}] {
+			restMapper.Add(gvk, meta.RESTScopeRoot)
+		} else {
+			restMapper.Add(gvk, meta.RESTScopeNamespace)
+		}
 	}
 
 	r := &Client{
 		delegate:   delegate,
 		restMapper: restMapper,
+		cache:      map[cacheKey]runtime.Object{},
+		indexes:    map[schema.GroupVersionKind]map[string]client.IndexerFunc{},
 	}
 
 	r.PrependReactor("*", "*", func(action testing.Action) (bool, runtime.Object, error) {
-		ctx := context.TODO()
+		ctx := r.invokeCtx
 		switch action.GetVerb() {
 		case "get":
 			a := action.(testing.GetAction)
@@ -72,15 +166,38 @@ func NewClient(delegate client.Client) *Client {
 		case "delete":
 			a := action.(testing.DeleteAction)
 			obj := r.newNamedObject(r.kindForResource(a.GetResource()), a.GetNamespace(), a.GetName())
-			err := r.delegate.Delete(ctx, obj)
+			if err := r.addPropagationFinalizer(ctx, obj, a.GetDeleteOptions().PropagationPolicy); err != nil {
+				return true, nil, err
+			}
+			deleteOptions := a.GetDeleteOptions()
+			err := r.delegate.Delete(ctx, obj, &client.DeleteOptions{
+				GracePeriodSeconds: deleteOptions.GracePeriodSeconds,
+				Preconditions:      deleteOptions.Preconditions,
+				PropagationPolicy:  deleteOptions.PropagationPolicy,
+				DryRun:             deleteOptions.DryRun,
+			})
 			return true, nil, err
 		case "update":
 			a := action.(testing.UpdateAction)
+			if a.GetSubresource() == statusSubresource {
+				updated, err := r.updateStatus(ctx, a.GetObject().(client.Object))
+				return true, updated, err
+			}
 			err := r.delegate.Update(ctx, a.GetObject().(client.Object))
 			return true, nil, err
 		case "patch":
 			a := action.(testing.PatchAction)
 			obj := r.newNamedObject(r.kindForResource(a.GetResource()), a.GetNamespace(), a.GetName())
+			if err := r.checkPatchPrecondition(ctx, obj, a.GetResource(), a.GetPatchType(), a.GetPatch()); err != nil {
+				return true, nil, err
+			}
+			if a.GetSubresource() == statusSubresource {
+				patched, err := r.patchStatus(ctx, obj, a.GetPatchType(), a.GetPatch())
+				return true, patched, err
+			}
+			if applyAction, ok := a.(ApplyPatchAction); ok {
+				return true, nil, r.apply(ctx, obj, a.GetPatch(), applyAction.FieldManager, applyAction.Force)
+			}
 			patch := client.RawPatch(a.GetPatchType(), a.GetPatch())
 			err := r.delegate.Patch(ctx, obj, patch)
 			return true, nil, err
@@ -92,15 +209,136 @@ func NewClient(delegate client.Client) *Client {
 				client.MatchingLabelsSelector{Selector: a.GetListRestrictions().Labels},
 				client.InNamespace(a.GetNamespace()),
 			)
-			return true, obj, err
+			if err != nil {
+				return true, obj, err
+			}
+			// The delegate ignores field selectors, so they are applied here using the registered indexes
+			return true, obj, r.filterByFields(obj, a.GetKind(), a.GetListRestrictions().Fields)
+		case "delete-collection":
+			// The delegate only filters by namespace and labels; unlike for list, field selectors are only recorded on the action
+			a := action.(testing.DeleteCollectionAction)
+			obj := r.newNamedObject(r.kindForResource(a.GetResource()), a.GetNamespace(), "")
+			err := r.delegate.DeleteAllOf(ctx, obj,
+				client.InNamespace(a.GetNamespace()),
+				client.MatchingLabelsSelector{Selector: a.GetListRestrictions().Labels},
+				client.MatchingFieldsSelector{Selector: a.GetListRestrictions().Fields},
+			)
+			return true, nil, err
 		default:
 			return true, nil, fmt.Errorf("unsupported action for verb %#v", action.GetVerb())
 		}
 	})
 
+	r.PrependWatchReactor("*", func(action testing.Action) (bool, watch.Interface, error) {
+		w, err := r.watchDelegate(r.invokeCtx, action.(testing.WatchAction))
+		return true, w, err
+	})
+
 	return r
 }
 
+// ActionContexts returns the actions in the order they were invoked, along with the caller's context for each.
+func (r *Client) ActionContexts() []ActionContext {
+	r.contextsLock.Lock()
+	defer r.contextsLock.Unlock()
+	actionContexts := make([]ActionContext, len(r.actionContexts))
+	copy(actionContexts, r.actionContexts)
+	return actionContexts
+}
+
+func (r *Client) ClearActions() {
+	r.Fake.ClearActions()
+	r.contextsLock.Lock()
+	defer r.contextsLock.Unlock()
+	r.actionContexts = nil
+}
+
+// MutatingActions returns the recorded actions that may have changed an object: everything
+// except gets, lists, watches and empty patches.
+func (r *Client) MutatingActions() []testing.Action {
+	var mutatingActions []testing.Action
+	for _, action := range r.Actions() {
+		switch action.GetVerb() {
+		case "get", "list", "watch":
+			continue
+		case "patch":
+			if string(bytes.TrimSpace(action.(testing.PatchAction).GetPatch())) == "{}" {
+				continue
+			}
+		}
+		mutatingActions = append(mutatingActions, action)
+	}
+	return mutatingActions
+}
+
+// ExpectIdempotentReconcile runs reconcile twice and asserts that both runs succeed and that
+// the second run did not change any objects.
+func (r *Client) ExpectIdempotentReconcile(reconcile func() error) {
+	ExpectWithOffset(1, reconcile()).To(Succeed(), "first reconcile failed")
+	r.ClearActions()
+	ExpectWithOffset(1, reconcile()).To(Succeed(), "second reconcile failed")
+	var mutations []string
+	for _, action := range r.MutatingActions() {
+		mutations = append(mutations, describeAction(action))
+	}
+	ExpectWithOffset(1, mutations).To(BeEmpty(), "second reconcile was not idempotent")
+}
+
+// Eventually re-gets the object at key into obj until predicate holds for it, and fails the test
+// if it does not before Gomega's Eventually times out. intervals are passed on to Eventually,
+// e.g. "5s", "100ms" for the timeout and polling interval. An object that does not exist yet is polled for.
+func (r *Client) Eventually(key client.ObjectKey, obj client.Object, predicate func(client.Object) bool, intervals ...interface{}) {
+	EventuallyWithOffset(1, func() (bool, error) {
+		if err := r.Get(context.Background(), key, obj); err != nil {
+			return false, err
+		}
+		return predicate(obj), nil
+	}, intervals...).Should(BeTrue(), "%s never satisfied the predicate", key)
+}
+
+func describeAction(action testing.Action) string {
+	return fmt.Sprintf("%s %s %s/%s", action.GetVerb(), action.GetResource().Resource, action.GetNamespace(), actionName(action))
+}
+
+// actionName returns the name of the object an action is for, or "" for lists and watches
+func actionName(action testing.Action) string {
+	switch a := action.(type) {
+	case interface{ GetName() string }:
+		return a.GetName()
+	case interface{ GetObject() runtime.Object }:
+		if object, err := meta.Accessor(a.GetObject()); err == nil {
+			return object.GetName()
+		}
+	}
+	return ""
+}
+
+func (r *Client) invokes(ctx context.Context, action testing.Action) (runtime.Object, error) {
+	r.contextsLock.Lock()
+	r.actionContexts = append(r.actionContexts, ActionContext{Action: action.DeepCopy(), Context: ctx})
+	r.contextsLock.Unlock()
+
+	r.invokeLock.Lock()
+	defer r.invokeLock.Unlock()
+	r.invokeCtx = ctx
+	defer func() { r.invokeCtx = nil }()
+	// Invokes records and reacts to deep copies of the action, so neither the delegate nor the recorded
+	// actions alias the object the caller passed in
+	return r.Invokes(action, nil)
+}
+
+func (r *Client) invokesWatch(ctx context.Context, action testing.WatchAction) (watch.Interface, error) {
+	r.contextsLock.Lock()
+	r.actionContexts = append(r.actionContexts, ActionContext{Action: action.DeepCopy(), Context: ctx})
+	r.contextsLock.Unlock()
+
+	r.invokeLock.Lock()
+	defer r.invokeLock.Unlock()
+	r.invokeCtx = ctx
+	defer func() { r.invokeCtx = nil }()
+	return r.InvokesWatch(action)
+}
+
 func (r *Client) gvrForObject(obj client.Object) schema.GroupVersionResource {
 	defer GinkgoRecover()
 	kinds, _, err := r.Scheme().ObjectKinds(obj)
@@ -108,13 +346,26 @@ func (r *Client) gvrForObject(obj client.Object) schema.GroupVersionResource {
 	Expect(kinds).To(HaveLen(1))
 	gvk := kinds[0]
 
-	rm, err := r.restMapper.RESTMapping(gvk.GroupKind())
+	rm, err := r.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	Expect(err).NotTo(HaveOccurred())
 	gvr := rm.Resource
 
 	return gvr
 }
 
+// namespaceIfScoped drops namespace for cluster-scoped resources, as the apiserver does
+func (r *Client) namespaceIfScoped(resource schema.GroupVersionResource, namespace string) string {
+	kind, err := r.restMapper.KindFor(resource)
+	if err != nil {
+		return namespace
+	}
+	mapping, err := r.restMapper.RESTMapping(kind.GroupKind(), kind.Version)
+	if err != nil || mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return namespace
+	}
+	return metav1.NamespaceNone
+}
+
 func (r *Client) kindForResource(resource schema.GroupVersionResource) schema.GroupVersionKind {
 	defer GinkgoRecover()
 	kind, err := r.restMapper.KindFor(resource)
@@ -155,13 +406,75 @@ func (r *Client) populateGVK(obj client.Object) {
 	obj.GetObjectKind().SetGroupVersionKind(gvk)
 }
 
+// SimulateStaleCache makes the next staleGets calls to Get behave like an informer cache that has not
+// caught up: they return the object as of the last up-to-date Get, or NotFound if it was never fetched.
+// Later Gets see the current state again.
+func (r *Client) SimulateStaleCache(staleGets int) {
+	r.cacheLock.Lock()
+	defer r.cacheLock.Unlock()
+	r.staleGets = staleGets
+}
+
+// StaleGetsRemaining returns how many more calls to Get will return stale objects.
+func (r *Client) StaleGetsRemaining() int {
+	r.cacheLock.Lock()
+	defer r.cacheLock.Unlock()
+	return r.staleGets
+}
+
+// AddErrorReactor makes the next times actions with verb on resource fail with err, e.g. apierrs.NewConflict to
+// test that a reconcile is retried. The reactor goes ahead of the one that passes actions on to the delegate, so
+// the failing actions are recorded but do not reach it. "*" matches any verb or resource.
+func (r *Client) AddErrorReactor(verb, resource string, times int, err error) {
+	r.AddNamedErrorReactor(verb, resource, "", times, err)
+}
+
+// AddNamedErrorReactor is AddErrorReactor for actions on the object called name only.
+func (r *Client) AddNamedErrorReactor(verb, resource, name string, times int, err error) {
+	// Fake.Invokes holds its lock while the reactors run, so remaining needs no lock of its own
+	remaining := times
+	r.PrependReactor(verb, resource, func(action testing.Action) (bool, runtime.Object, error) {
+		if remaining <= 0 || (name != "" && actionName(action) != name) {
+			return false, nil, nil
+		}
+		remaining--
+		return true, nil, err
+	})
+}
+
+func (r *Client) cachedGet(action testing.GetAction, obj runtime.Object, err error) (runtime.Object, error) {
+	if err != nil && !apierrs.IsNotFound(err) {
+		return obj, err
+	}
+	r.cacheLock.Lock()
+	defer r.cacheLock.Unlock()
+	key := cacheKey{resource: action.GetResource(), namespace: action.GetNamespace(), name: action.GetName()}
+	if r.staleGets > 0 {
+		r.staleGets--
+		if cached, ok := r.cache[key]; ok {
+			return cached.DeepCopyObject(), nil
+		}
+		return nil, apierrs.NewNotFound(action.GetResource().GroupResource(), action.GetName())
+	}
+	if err != nil || obj == nil {
+		delete(r.cache, key)
+		return obj, err
+	}
+	r.cache[key] = obj.DeepCopyObject()
+	return obj, nil
+}
+
+// Get and List copy what a reactor returns into the caller's object, so callers can
+// mutate results without changing the tracker, the stale cache or a reactor's shared object.
 func (r *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
-	action := testing.NewGetAction(r.gvrForObject(obj), key.Namespace, key.Name)
-	retrievedObj, err := r.Invokes(action, nil)
+	gvr := r.gvrForObject(obj)
+	action := testing.NewGetAction(gvr, r.namespaceIfScoped(gvr, key.Namespace), key.Name)
+	retrievedObj, err := r.invokes(ctx, action)
+	retrievedObj, err = r.cachedGet(action, retrievedObj, err)
 	if err != nil {
 		return err
 	}
-	return r.Scheme().Convert(retrievedObj, obj, nil)
+	return r.copyInto(retrievedObj, obj)
 }
 
 func (r *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
@@ -170,26 +483,371 @@ func (r *Client) List(ctx context.Context, list client.ObjectList, opts ...clien
 	listOpts := client.ListOptions{}
 	listOpts.ApplyOptions(opts)
 
-	listGvk, err := apiutil.GVKForObject(list, r.Scheme())
+	listGvk, gvr, err := r.listResource(list)
 	if err != nil {
 		return err
 	}
 
+	selectorOpts, err := parseSelectors(listOpts)
+	if err != nil {
+		return err
+	}
+	namespace := r.namespaceIfScoped(gvr, listOpts.Namespace)
+	action := testing.NewListAction(gvr, listGvk, namespace, selectorOpts)
+	retrievedObj, err := r.invokes(ctx, action)
+	if err != nil {
+		return err
+	}
+	if err := r.copyInto(retrievedObj, list); err != nil {
+		return err
+	}
+	return paginate(list, namespace, listOpts.Limit, listOpts.Continue)
+}
+
+// parseSelectors converts listOpts for a list or watch action. The selectors go to the reactors as strings,
+// as they would to the apiserver, so one that does not parse again is a BadRequest rather than a panic in
+// the action.
+func parseSelectors(listOpts client.ListOptions) (metav1.ListOptions, error) {
+	opts := *listOpts.AsListOptions()
+	if _, err := labels.Parse(opts.LabelSelector); err != nil {
+		return opts, apierrs.NewBadRequest(fmt.Sprintf("invalid label selector %q: %v", opts.LabelSelector, err))
+	}
+	if _, err := fields.ParseSelector(opts.FieldSelector); err != nil {
+		return opts, apierrs.NewBadRequest(fmt.Sprintf("invalid field selector %q: %v", opts.FieldSelector, err))
+	}
+	return opts, nil
+}
+
+func (r *Client) listResource(list client.ObjectList) (schema.GroupVersionKind, schema.GroupVersionResource, error) {
+	listGvk, err := apiutil.GVKForObject(list, r.Scheme())
+	if err != nil {
+		return schema.GroupVersionKind{}, schema.GroupVersionResource{}, err
+	}
+
 	if !strings.HasSuffix(listGvk.Kind, "List") {
-		return fmt.Errorf("non-list type %T (kind %q) passed as output", list, listGvk)
+		return schema.GroupVersionKind{}, schema.GroupVersionResource{}, fmt.Errorf("non-list type %T (kind %q) passed as output", list, listGvk)
 	}
 	// we need the non-list GVK, so chop off the "List" from the end of the kind
 	gvk := listGvk
 	gvk.Kind = gvk.Kind[:len(gvk.Kind)-len("List")]
 
 	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return listGvk, gvr, nil
+}
+
+// Watch goes through the watch reactors. By default it watches the delegate, which must implement
+// client.WithWatch, and reports the changes to matching objects from then on.
+func (r *Client) Watch(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	_, gvr, err := r.listResource(list)
+	if err != nil {
+		return nil, err
+	}
+
+	selectorOpts, err := parseSelectors(listOpts)
+	if err != nil {
+		return nil, err
+	}
+	var action testing.WatchActionImpl
+	if namespace := r.namespaceIfScoped(gvr, listOpts.Namespace); namespace == metav1.NamespaceAll {
+		action = testing.NewRootWatchAction(gvr, selectorOpts)
+	} else {
+		action = testing.NewWatchAction(gvr, namespace, selectorOpts)
+	}
+	return r.invokesWatch(ctx, action)
+}
+
+// watchDelegate starts a watch on the delegate for the action, filtered by its label and field selectors,
+// which the delegate ignores
+func (r *Client) watchDelegate(ctx context.Context, action testing.WatchAction) (watch.Interface, error) {
+	watcher, ok := r.delegate.(client.WithWatch)
+	if !ok {
+		return nil, fmt.Errorf("delegate %T does not support watches", r.delegate)
+	}
+	kind := r.kindForResource(action.GetResource())
+	restrictions := action.GetWatchRestrictions()
+	var requirements fields.Requirements
+	if restrictions.Fields != nil {
+		requirements = restrictions.Fields.Requirements()
+	}
+	extractors, err := r.fieldExtractors(kind, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	listKind := kind
+	listKind.Kind += "List"
+	delegateWatch, err := watcher.Watch(ctx, r.newObjectList(listKind), client.InNamespace(action.GetNamespace()))
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(delegateWatch, func(event watch.Event) (watch.Event, bool) {
+		obj, ok := event.Object.(client.Object)
+		if !ok {
+			// e.g. the Status of an Error event
+			return event, true
+		}
+		if restrictions.Labels != nil && !restrictions.Labels.Matches(labels.Set(obj.GetLabels())) {
+			return event, false
+		}
+		return event, matchesFields(obj, requirements, extractors)
+	}), nil
+}
+
+// continueToken is the state encoded in ListMeta.Continue. Like the apiserver, a continued List resumes
+// after the key of the last item returned, so objects created or deleted between pages do not shift it.
+type continueToken struct {
+	Namespace string `json:"namespace"`
+	After     string `json:"after"`
+}
+
+// paginate trims list to the page described by limit and the continue token from a previous List.
+// The delegate returns items in no particular order, so paged lists are sorted by namespace and name.
+func paginate(list client.ObjectList, namespace string, limit int64, continueFrom string) error {
+	if limit <= 0 && continueFrom == "" {
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, len(items))
+	for i, item := range items {
+		object, err := meta.Accessor(item)
+		if err != nil {
+			return err
+		}
+		keys[i] = object.GetNamespace() + "/" + object.GetName()
+	}
+	sort.Sort(byKey{items: items, keys: keys})
+
+	start := 0
+	if continueFrom != "" {
+		token, err := decodeContinueToken(continueFrom)
+		if err != nil {
+			return err
+		}
+		if token.Namespace != namespace {
+			return apierrs.NewBadRequest(fmt.Sprintf("continue token is for namespace %q, not %q", token.Namespace, namespace))
+		}
+		start = sort.SearchStrings(keys, token.After)
+		if start < len(keys) && keys[start] == token.After {
+			start++
+		}
+	}
+	end := len(items)
+	continueTo := ""
+	if limit > 0 && int64(end-start) > limit {
+		end = start + int(limit)
+		continueTo, err = encodeContinueToken(continueToken{Namespace: namespace, After: keys[end-1]})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := meta.SetList(list, items[start:end]); err != nil {
+		return err
+	}
+	list.SetContinue(continueTo)
+	return nil
+}
+
+func encodeContinueToken(token continueToken) (string, error) {
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenJSON), nil
+}
+
+func decodeContinueToken(encoded string) (continueToken, error) {
+	var token continueToken
+	tokenJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(tokenJSON, &token)
+	}
+	if err != nil {
+		return token, apierrs.NewBadRequest(fmt.Sprintf("invalid continue token %q: %s", encoded, err))
+	}
+	return token, nil
+}
+
+// byKey sorts list items along with their namespace/name keys
+type byKey struct {
+	items []runtime.Object
+	keys  []string
+}
+
+func (b byKey) Len() int           { return len(b.items) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.items[i], b.items[j] = b.items[j], b.items[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
 
-	action := testing.NewListAction(gvr, listGvk, listOpts.Namespace, *listOpts.AsListOptions())
-	retrievedObj, err := r.Invokes(action, nil)
+// IndexField registers extractValue as the index of field for the kind of obj, so that a List with a field
+// selector on it returns only the matching objects, as it would from the cache. Like the apiserver,
+// metadata.name and metadata.namespace, and the fields in builtinKindIndexes, can be selected on without an index.
+func (r *Client) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme())
 	if err != nil {
 		return err
 	}
-	return r.Scheme().Convert(retrievedObj, list, nil)
+	r.indexLock.Lock()
+	defer r.indexLock.Unlock()
+	if _, ok := r.indexes[gvk][field]; ok {
+		return fmt.Errorf("indexer conflict: field %s is already indexed for %s", field, gvk.Kind)
+	}
+	if r.indexes[gvk] == nil {
+		r.indexes[gvk] = map[string]client.IndexerFunc{}
+	}
+	r.indexes[gvk][field] = extractValue
+	return nil
+}
+
+var builtinIndexes = map[string]client.IndexerFunc{
+	"metadata.name":      func(obj client.Object) []string { return []string{obj.GetName()} },
+	"metadata.namespace": func(obj client.Object) []string { return []string{obj.GetNamespace()} },
+}
+
+// builtinKindIndexes are the other field selectors the apiserver supports for the kinds the operator lists
+var builtinKindIndexes = map[schema.GroupKind]map[string]client.IndexerFunc{
+	{Kind: "Pod"}: {
+		"spec.nodeName":            podField(func(pod *corev1.Pod) string { return pod.Spec.NodeName }),
+		"spec.restartPolicy":       podField(func(pod *corev1.Pod) string { return string(pod.Spec.RestartPolicy) }),
+		"spec.schedulerName":       podField(func(pod *corev1.Pod) string { return pod.Spec.SchedulerName }),
+		"spec.serviceAccountName":  podField(func(pod *corev1.Pod) string { return pod.Spec.ServiceAccountName }),
+		"status.phase":             podField(func(pod *corev1.Pod) string { return string(pod.Status.Phase) }),
+		"status.podIP":             podField(func(pod *corev1.Pod) string { return pod.Status.PodIP }),
+		"status.nominatedNodeName": podField(func(pod *corev1.Pod) string { return pod.Status.NominatedNodeName }),
+	},
+	{Kind: "Node"}: {
+		"spec.unschedulable": func(obj client.Object) []string {
+			if node, ok := obj.(*corev1.Node); ok {
+				return []string{strconv.FormatBool(node.Spec.Unschedulable)}
+			}
+			return nil
+		},
+	},
+	{Kind: "Event"}: {
+		"involvedObject.kind":      eventField(func(event *corev1.Event) string { return event.InvolvedObject.Kind }),
+		"involvedObject.namespace": eventField(func(event *corev1.Event) string { return event.InvolvedObject.Namespace }),
+		"involvedObject.name":      eventField(func(event *corev1.Event) string { return event.InvolvedObject.Name }),
+		"involvedObject.uid":       eventField(func(event *corev1.Event) string { return string(event.InvolvedObject.UID) }),
+		"reason":                   eventField(func(event *corev1.Event) string { return event.Reason }),
+		"type":                     eventField(func(event *corev1.Event) string { return event.Type }),
+	},
+	{Kind: "Secret"}: {
+		"type": func(obj client.Object) []string {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				return []string{string(secret.Type)}
+			}
+			return nil
+		},
+	},
+	{Group: "batch", Kind: "Job"}: {
+		"status.successful": func(obj client.Object) []string {
+			if job, ok := obj.(*batchv1.Job); ok {
+				return []string{strconv.Itoa(int(job.Status.Succeeded))}
+			}
+			return nil
+		},
+	},
+}
+
+func podField(value func(pod *corev1.Pod) string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			return []string{value(pod)}
+		}
+		return nil
+	}
+}
+
+func eventField(value func(event *corev1.Event) string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		if event, ok := obj.(*corev1.Event); ok {
+			return []string{value(event)}
+		}
+		return nil
+	}
+}
+
+// filterByFields removes the items of list that do not match every requirement of selector
+func (r *Client) filterByFields(list client.ObjectList, listKind schema.GroupVersionKind, selector fields.Selector) error {
+	if selector == nil || selector.Empty() {
+		return nil
+	}
+	kind := listKind
+	kind.Kind = strings.TrimSuffix(kind.Kind, "List")
+	requirements := selector.Requirements()
+	extractors, err := r.fieldExtractors(kind, requirements)
+	if err != nil {
+		return err
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var matchingItems []runtime.Object
+	for _, item := range items {
+		object, ok := item.(client.Object)
+		if !ok {
+			return fmt.Errorf("cannot select on the fields of %T", item)
+		}
+		if matchesFields(object, requirements, extractors) {
+			matchingItems = append(matchingItems, item)
+		}
+	}
+	return meta.SetList(list, matchingItems)
+}
+
+// fieldExtractors returns the index of kind for each field in requirements
+func (r *Client) fieldExtractors(kind schema.GroupVersionKind, requirements fields.Requirements) ([]client.IndexerFunc, error) {
+	r.indexLock.Lock()
+	defer r.indexLock.Unlock()
+	extractors := make([]client.IndexerFunc, len(requirements))
+	for i, requirement := range requirements {
+		extractors[i] = r.indexes[kind][requirement.Field]
+		if extractors[i] == nil {
+			extractors[i] = builtinIndexes[requirement.Field]
+		}
+		if extractors[i] == nil {
+			extractors[i] = builtinKindIndexes[kind.GroupKind()][requirement.Field]
+		}
+		if extractors[i] == nil {
+			return nil, apierrs.NewBadRequest(fmt.Sprintf("field label not supported: %s: no index is registered for it on %s", requirement.Field, kind.Kind))
+		}
+	}
+	return extractors, nil
+}
+
+func matchesFields(obj client.Object, requirements fields.Requirements, extractors []client.IndexerFunc) bool {
+	for i, requirement := range requirements {
+		indexed := false
+		for _, value := range extractors[i](obj) {
+			if value == requirement.Value {
+				indexed = true
+				break
+			}
+		}
+		if indexed == (requirement.Operator == selection.NotEquals) {
+			return false
+		}
+	}
+	return true
+}
+
+// Converting between identical types already deep-copies, but converting into
+// Unstructured shares the content, so copy first to be sure nothing is aliased.
+func (r *Client) copyInto(retrievedObj runtime.Object, obj runtime.Object) error {
+	if retrievedObj == nil {
+		return fmt.Errorf("reactor returned no object for %T", obj)
+	}
+	return r.Scheme().Convert(retrievedObj.DeepCopyObject(), obj, nil)
 }
 
 func (r *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
@@ -202,17 +860,18 @@ func (r *Client) Create(ctx context.Context, obj client.Object, opts ...client.C
 
 	r.populateGVK(obj)
 
-	action := testing.NewCreateAction(r.gvrForObject(obj), object.GetNamespace(), obj)
-	_, err = r.Invokes(action, nil)
+	gvr := r.gvrForObject(obj)
+	object.SetNamespace(r.namespaceIfScoped(gvr, object.GetNamespace()))
+	action := testing.NewCreateAction(gvr, object.GetNamespace(), obj)
+	_, err = r.invokes(ctx, action)
 	return err
 }
 
 func (r *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
 	defer GinkgoRecover()
 
-	// TODO: We are just dropping these options on the floor... this is the same thing
-	//       that the controller-runtime fake client does, so it doesn't seem too unusual
-	//       but is that really the right thing to do here?
+	// The options are recorded on the action and passed on to the delegate. The reactor honors the
+	// propagation policy itself, since the controller-runtime fake client ignores it.
 	deleteOpts := client.DeleteOptions{}
 	deleteOpts.ApplyOptions(opts)
 
@@ -221,13 +880,57 @@ func (r *Client) Delete(ctx context.Context, obj client.Object, opts ...client.D
 		return errors.Wrap(err, "failed deleting object")
 	}
 
-	action := testing.NewDeleteAction(r.gvrForObject(obj), object.GetNamespace(), object.GetName())
-	_, err = r.Invokes(action, nil)
+	gvr := r.gvrForObject(obj)
+	action := testing.NewDeleteActionWithOptions(gvr, r.namespaceIfScoped(gvr, object.GetNamespace()), object.GetName(), *deleteOpts.AsDeleteOptions())
+	_, err = r.invokes(ctx, action)
 	return err
 }
 
+// propagationFinalizers are the finalizers the apiserver adds to an object deleted with a propagation
+// policy. They keep the object, with a deletionTimestamp, until the garbage collector has dealt with
+// its dependents. There is no garbage collector here, so the object persists until a test clears them.
+var propagationFinalizers = map[metav1.DeletionPropagation]string{
+	metav1.DeletePropagationForeground: metav1.FinalizerDeleteDependents,
+	metav1.DeletePropagationOrphan:     metav1.FinalizerOrphanDependents,
+}
+
+// addPropagationFinalizer adds the finalizer for policy to obj before it is deleted, so that the
+// delegate sets its deletionTimestamp instead of removing it
+func (r *Client) addPropagationFinalizer(ctx context.Context, obj client.Object, policy *metav1.DeletionPropagation) error {
+	if policy == nil {
+		return nil
+	}
+	finalizer, ok := propagationFinalizers[*policy]
+	if !ok {
+		return nil
+	}
+	if err := r.delegate.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	if controllerutil.ContainsFinalizer(obj, finalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(obj, finalizer)
+	return r.delegate.Update(ctx, obj)
+}
+
+// DeleteAllOf resolves the resource from obj itself rather than asserting on it, so an unknown kind is an error
 func (r *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
-	panic("implement me")
+	deleteAllOfOpts := client.DeleteAllOfOptions{}
+	deleteAllOfOpts.ApplyOptions(opts)
+
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme())
+	if err != nil {
+		return errors.Wrap(err, "failed deleting objects")
+	}
+	mapping, err := r.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return errors.Wrap(err, "failed deleting objects")
+	}
+
+	action := testing.NewDeleteCollectionAction(mapping.Resource, r.namespaceIfScoped(mapping.Resource, deleteAllOfOpts.Namespace), *deleteAllOfOpts.AsListOptions())
+	_, err = r.invokes(ctx, action)
+	return err
 }
 
 func (r *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -240,27 +943,225 @@ func (r *Client) Update(ctx context.Context, obj client.Object, opts ...client.U
 
 	r.populateGVK(obj)
 
-	action := testing.NewUpdateAction(r.gvrForObject(obj), object.GetNamespace(), obj)
-	_, err = r.Invokes(action, nil)
+	gvr := r.gvrForObject(obj)
+	object.SetNamespace(r.namespaceIfScoped(gvr, object.GetNamespace()))
+	action := testing.NewUpdateAction(gvr, object.GetNamespace(), obj)
+	_, err = r.invokes(ctx, action)
 	return err
 }
 
+// Patch only takes options for server-side apply: client.FieldOwner, which is required, and client.ForceOwnership.
+// See apply for how field ownership is tracked.
 func (r *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
 	defer GinkgoRecover()
-	Expect(opts).To(BeEmpty(), "we can't handle opts")
+	if patch.Type() != types.ApplyPatchType {
+		Expect(opts).To(BeEmpty(), "we can't handle opts")
+	}
 	object, err := meta.Accessor(obj)
 	if err != nil {
 		return errors.Wrap(err, "failed patching object")
 	}
+	if patch.Type() == types.ApplyPatchType {
+		// the apiserver needs the apiVersion and kind of an applied object
+		r.populateGVK(obj)
+	}
 	p, err := patch.Data(obj)
 	if err != nil {
 		return errors.Wrap(err, "failed patching object")
 	}
-	action := testing.NewPatchAction(r.gvrForObject(obj), object.GetNamespace(), object.GetName(), patch.Type(), p)
-	_, err = r.Invokes(action, nil)
+	gvr := r.gvrForObject(obj)
+	var action testing.Action = testing.NewPatchAction(gvr, r.namespaceIfScoped(gvr, object.GetNamespace()), object.GetName(), patch.Type(), p)
+	if patch.Type() == types.ApplyPatchType {
+		patchOpts := client.PatchOptions{}
+		patchOpts.ApplyOptions(opts)
+		Expect(patchOpts.DryRun).To(BeEmpty(), "we can't handle dry runs")
+		action = ApplyPatchAction{
+			PatchActionImpl: action.(testing.PatchActionImpl),
+			FieldManager:    patchOpts.FieldManager,
+			Force:           patchOpts.Force != nil && *patchOpts.Force,
+		}
+	}
+	_, err = r.invokes(ctx, action)
 	return err
 }
 
+const statusSubresource = "status"
+
+// Status returns a writer for the status subresource. Its actions carry the "status" subresource, and only
+// change the status of the stored object, as the apiserver does for resources with a status subresource.
 func (r *Client) Status() client.StatusWriter {
-	return r
+	return &statusWriter{client: r}
+}
+
+type statusWriter struct {
+	client *Client
+}
+
+var _ client.StatusWriter = &statusWriter{}
+
+func (w *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	defer GinkgoRecover()
+	Expect(opts).To(BeEmpty(), "we can't handle opts")
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed updating object status")
+	}
+
+	w.client.populateGVK(obj)
+
+	gvr := w.client.gvrForObject(obj)
+	object.SetNamespace(w.client.namespaceIfScoped(gvr, object.GetNamespace()))
+	action := testing.NewUpdateSubresourceAction(gvr, statusSubresource, object.GetNamespace(), obj)
+	updated, err := w.client.invokes(ctx, action)
+	if err != nil {
+		return err
+	}
+	// Like the apiserver, hand back the object as stored
+	return w.client.copyInto(updated, obj)
+}
+
+func (w *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	defer GinkgoRecover()
+	Expect(opts).To(BeEmpty(), "we can't handle opts")
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed patching object status")
+	}
+	p, err := patch.Data(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed patching object status")
+	}
+	gvr := w.client.gvrForObject(obj)
+	action := testing.NewPatchSubresourceAction(gvr, w.client.namespaceIfScoped(gvr, object.GetNamespace()), object.GetName(), patch.Type(), p, statusSubresource)
+	patched, err := w.client.invokes(ctx, action)
+	if err != nil {
+		return err
+	}
+	return w.client.copyInto(patched, obj)
+}
+
+// updateStatus stores the status of obj on the stored object, leaving the rest of it unchanged, and returns the
+// object as stored. The delegate has no notion of a status subresource, so the merged object is written with an
+// ordinary update.
+func (r *Client) updateStatus(ctx context.Context, obj client.Object) (client.Object, error) {
+	stored := r.newNamedObject(r.kindForObject(obj), obj.GetNamespace(), obj.GetName())
+	if err := r.delegate.Get(ctx, client.ObjectKeyFromObject(obj), stored); err != nil {
+		return nil, err
+	}
+	updated, err := r.withStatus(stored, obj)
+	if err != nil {
+		return nil, err
+	}
+	// Conflicts are detected against the version the caller read
+	updated.SetResourceVersion(obj.GetResourceVersion())
+	if err := r.delegate.Update(ctx, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// checkPatchPrecondition fails a merge patch that carries a resourceVersion, as client.MergeFromWithOptimisticLock
+// adds, with a conflict unless it is the resourceVersion of the stored object, like the apiserver does. So does
+// an applied object that carries a resourceVersion
+func (r *Client) checkPatchPrecondition(ctx context.Context, obj client.Object, resource schema.GroupVersionResource, patchType types.PatchType, patch []byte) error {
+	if patchType != types.MergePatchType && patchType != types.StrategicMergePatchType && patchType != types.ApplyPatchType {
+		return nil
+	}
+	var precondition struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	// an applied object may be YAML, of which JSON is a subset
+	if err := yaml.Unmarshal(patch, &precondition); err != nil {
+		return apierrs.NewBadRequest(fmt.Sprintf("invalid patch: %s", err))
+	}
+	if precondition.Metadata.ResourceVersion == "" {
+		return nil
+	}
+	stored := r.newNamedObject(r.kindForObject(obj), obj.GetNamespace(), obj.GetName())
+	if err := r.delegate.Get(ctx, client.ObjectKeyFromObject(obj), stored); err != nil {
+		return err
+	}
+	if stored.GetResourceVersion() != precondition.Metadata.ResourceVersion {
+		return apierrs.NewConflict(resource.GroupResource(), obj.GetName(),
+			errors.New("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	return nil
+}
+
+// patchStatus applies the patch to the stored object, keeps only the resulting status, and returns the object as stored
+func (r *Client) patchStatus(ctx context.Context, obj client.Object, patchType types.PatchType, patch []byte) (client.Object, error) {
+	if err := r.delegate.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return nil, err
+	}
+	storedJSON, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var patchedJSON []byte
+	switch patchType {
+	case types.JSONPatchType:
+		jsonPatch, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, err
+		}
+		patchedJSON, err = jsonPatch.Apply(storedJSON)
+		if err != nil {
+			return nil, err
+		}
+	case types.MergePatchType:
+		patchedJSON, err = jsonpatch.MergePatch(storedJSON, patch)
+		if err != nil {
+			return nil, err
+		}
+	case types.StrategicMergePatchType:
+		patchedJSON, err = strategicpatch.StrategicMergePatch(storedJSON, patch, r.newRuntimeObject(r.kindForObject(obj)))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %s for the status subresource", patchType)
+	}
+	patched := r.newNamedObject(r.kindForObject(obj), obj.GetNamespace(), obj.GetName())
+	if err := json.Unmarshal(patchedJSON, patched); err != nil {
+		return nil, err
+	}
+	updated, err := r.withStatus(obj, patched)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.delegate.Update(ctx, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// withStatus returns a copy of stored with the status of from
+func (r *Client) withStatus(stored, from client.Object) (client.Object, error) {
+	storedContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(stored)
+	if err != nil {
+		return nil, err
+	}
+	fromContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(from)
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := fromContent[statusSubresource]; ok {
+		storedContent[statusSubresource] = status
+	} else {
+		delete(storedContent, statusSubresource)
+	}
+	updated := r.newNamedObject(r.kindForObject(stored), stored.GetNamespace(), stored.GetName())
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(storedContent, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *Client) kindForObject(obj client.Object) schema.GroupVersionKind {
+	defer GinkgoRecover()
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme())
+	Expect(err).NotTo(HaveOccurred())
+	return gvk
 }
@@ -0,0 +1,1392 @@
+package reactive_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	greenplumv1 "github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/api/v1"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/scheme"
+	"github.com/pivotal/greenplum-for-kubernetes/greenplum-operator/pkg/testing/reactive"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type contextKey string
+
+// deleteRecordingClient records the options of each Delete before passing it on
+type deleteRecordingClient struct {
+	client.Client
+	deleteOptions []client.DeleteOptions
+}
+
+func (c *deleteRecordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.ApplyOptions(opts)
+	c.deleteOptions = append(c.deleteOptions, deleteOptions)
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// contextRecordingClient records the context of each Get, and fails it once the context is done
+type contextRecordingClient struct {
+	client.Client
+	getContexts []context.Context
+}
+
+func (c *contextRecordingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	c.getContexts = append(c.getContexts, ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+var _ = Describe("reactive.Client", func() {
+	var (
+		subject *reactive.Client
+		podKey  types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		subject = reactive.NewClient(fake.NewFakeClientWithScheme(scheme.Scheme))
+		podKey = types.NamespacedName{Namespace: "test-ns", Name: "master-0"}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name},
+		}
+		Expect(subject.Create(context.Background(), pod)).To(Succeed())
+		subject.ClearActions()
+	})
+
+	Describe("ActionContexts", func() {
+		It("records the deadline of the context passed to Get", func() {
+			deadline := time.Now().Add(time.Minute)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+
+			actionContexts := subject.ActionContexts()
+			Expect(actionContexts).To(HaveLen(1))
+			Expect(actionContexts[0].GetVerb()).To(Equal("get"))
+			Expect(actionContexts[0].GetResource().Resource).To(Equal("pods"))
+			recordedDeadline, ok := actionContexts[0].Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(recordedDeadline).To(Equal(deadline))
+		})
+
+		It("records the values of the context passed to the client", func() {
+			ctx := context.WithValue(context.Background(), contextKey("reconcileID"), "abc123")
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			Expect(subject.Delete(ctx, &pod)).To(Succeed())
+
+			actionContexts := subject.ActionContexts()
+			Expect(actionContexts).To(HaveLen(2))
+			Expect(actionContexts[1].GetVerb()).To(Equal("delete"))
+			for _, actionContext := range actionContexts {
+				Expect(actionContext.Context.Value(contextKey("reconcileID"))).To(Equal("abc123"))
+			}
+		})
+
+		It("records that no deadline was set", func() {
+			var pod corev1.Pod
+			Expect(subject.Get(context.Background(), podKey, &pod)).To(Succeed())
+
+			actionContexts := subject.ActionContexts()
+			Expect(actionContexts).To(HaveLen(1))
+			_, ok := actionContexts[0].Deadline()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("keeps the action contexts in step with the action log", func() {
+			var pod corev1.Pod
+			Expect(subject.Get(context.Background(), podKey, &pod)).To(Succeed())
+			Expect(subject.ActionContexts()).To(HaveLen(len(subject.Actions())))
+
+			subject.ClearActions()
+			Expect(subject.Actions()).To(BeEmpty())
+			Expect(subject.ActionContexts()).To(BeEmpty())
+		})
+	})
+
+	Describe("the context passed to the delegate", func() {
+		var delegate *contextRecordingClient
+		BeforeEach(func() {
+			delegate = &contextRecordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+			subject = reactive.NewClient(delegate)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name},
+			}
+			Expect(subject.Create(context.Background(), pod)).To(Succeed())
+		})
+
+		It("is the caller's context", func() {
+			ctx := context.WithValue(context.Background(), contextKey("reconcileID"), "abc123")
+			Expect(subject.Get(ctx, podKey, &corev1.Pod{})).To(Succeed())
+			Expect(delegate.getContexts).To(HaveLen(1))
+			Expect(delegate.getContexts[0].Value(contextKey("reconcileID"))).To(Equal("abc123"))
+		})
+
+		It("carries the cancellation of the caller's context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := subject.Get(ctx, podKey, &corev1.Pod{})
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(delegate.getContexts).To(HaveLen(1))
+			Expect(delegate.getContexts[0].Err()).To(MatchError(context.Canceled))
+		})
+	})
+
+	Describe("ExpectIdempotentReconcile", func() {
+		It("passes when the second reconcile only reads objects", func() {
+			reconcile := func() error {
+				var pod corev1.Pod
+				if err := subject.Get(context.Background(), podKey, &pod); err != nil {
+					return err
+				}
+				if pod.Labels["reconciled"] == "true" {
+					return nil
+				}
+				pod.Labels = map[string]string{"reconciled": "true"}
+				return subject.Update(context.Background(), &pod)
+			}
+			Expect(InterceptGomegaFailures(func() {
+				subject.ExpectIdempotentReconcile(reconcile)
+			})).To(BeEmpty())
+		})
+
+		It("passes when the second reconcile sends an empty patch", func() {
+			reconcile := func() error {
+				var pod corev1.Pod
+				if err := subject.Get(context.Background(), podKey, &pod); err != nil {
+					return err
+				}
+				originalPod := pod.DeepCopy()
+				pod.Labels = map[string]string{"reconciled": "true"}
+				return subject.Patch(context.Background(), &pod, client.MergeFrom(originalPod))
+			}
+			Expect(InterceptGomegaFailures(func() {
+				subject.ExpectIdempotentReconcile(reconcile)
+			})).To(BeEmpty())
+		})
+
+		It("fails when the second reconcile changes objects", func() {
+			generation := 0
+			reconcile := func() error {
+				var pod corev1.Pod
+				if err := subject.Get(context.Background(), podKey, &pod); err != nil {
+					return err
+				}
+				generation++
+				pod.Labels = map[string]string{"generation": strconv.Itoa(generation)}
+				return subject.Update(context.Background(), &pod)
+			}
+			failures := InterceptGomegaFailures(func() {
+				subject.ExpectIdempotentReconcile(reconcile)
+			})
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0]).To(ContainSubstring("second reconcile was not idempotent"))
+			Expect(failures[0]).To(ContainSubstring("update pods test-ns/master-0"))
+		})
+
+		It("fails when a reconcile returns an error", func() {
+			failures := InterceptGomegaFailures(func() {
+				subject.ExpectIdempotentReconcile(func() error { return errors.New("injected error") })
+			})
+			Expect(failures).NotTo(BeEmpty())
+			Expect(failures[0]).To(ContainSubstring("first reconcile failed"))
+		})
+	})
+
+	Describe("Eventually", func() {
+		hasLabel := func(obj client.Object) bool {
+			return obj.GetLabels()["reconciled"] == "true"
+		}
+
+		It("passes once the object converges", func() {
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(20 * time.Millisecond)
+				var pod corev1.Pod
+				Expect(subject.Get(context.Background(), podKey, &pod)).To(Succeed())
+				pod.Labels = map[string]string{"reconciled": "true"}
+				Expect(subject.Update(context.Background(), &pod)).To(Succeed())
+			}()
+			var pod corev1.Pod
+			Expect(InterceptGomegaFailures(func() {
+				subject.Eventually(podKey, &pod, hasLabel, "1s", "5ms")
+			})).To(BeEmpty())
+			Expect(pod.Labels).To(HaveKeyWithValue("reconciled", "true"))
+		})
+
+		It("waits for an object that does not exist yet", func() {
+			otherKey := types.NamespacedName{Namespace: "test-ns", Name: "master-1"}
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(20 * time.Millisecond)
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: otherKey.Namespace, Name: otherKey.Name},
+				}
+				Expect(subject.Create(context.Background(), pod)).To(Succeed())
+			}()
+			var pod corev1.Pod
+			Expect(InterceptGomegaFailures(func() {
+				subject.Eventually(otherKey, &pod, func(client.Object) bool { return true }, "1s", "5ms")
+			})).To(BeEmpty())
+			Expect(pod.Name).To(Equal("master-1"))
+		})
+
+		It("fails when the object does not converge before the timeout", func() {
+			var pod corev1.Pod
+			failures := InterceptGomegaFailures(func() {
+				subject.Eventually(podKey, &pod, hasLabel, "50ms", "5ms")
+			})
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0]).To(ContainSubstring("test-ns/master-0 never satisfied the predicate"))
+		})
+	})
+
+	Describe("returned objects", func() {
+		var ctx context.Context
+		BeforeEach(func() {
+			ctx = context.Background()
+		})
+
+		It("does not let mutating a gotten object change a subsequent Get", func() {
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			pod.Labels = map[string]string{"mutated": "true"}
+			pod.Spec.Hostname = "mutated"
+
+			var refetchedPod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &refetchedPod)).To(Succeed())
+			Expect(refetchedPod.Labels).NotTo(HaveKey("mutated"))
+			Expect(refetchedPod.Spec.Hostname).To(BeEmpty())
+		})
+
+		It("does not let mutating a listed object change a subsequent Get", func() {
+			var podList corev1.PodList
+			Expect(subject.List(ctx, &podList, client.InNamespace("test-ns"))).To(Succeed())
+			Expect(podList.Items).To(HaveLen(1))
+			podList.Items[0].Labels = map[string]string{"mutated": "true"}
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			Expect(pod.Labels).NotTo(HaveKey("mutated"))
+		})
+
+		It("does not let mutating a created object change a subsequent Get", func() {
+			otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "master-1"}}
+			Expect(subject.Create(ctx, otherPod)).To(Succeed())
+			otherPod.Labels = map[string]string{"mutated": "true"}
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, types.NamespacedName{Namespace: "test-ns", Name: "master-1"}, &pod)).To(Succeed())
+			Expect(pod.Labels).NotTo(HaveKey("mutated"))
+		})
+
+		It("does not let mutating an updated object change a subsequent Get", func() {
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			pod.Spec.Hostname = "updated"
+			Expect(subject.Update(ctx, &pod)).To(Succeed())
+			pod.Spec.Hostname = "mutated"
+
+			var refetchedPod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &refetchedPod)).To(Succeed())
+			Expect(refetchedPod.Spec.Hostname).To(Equal("updated"))
+		})
+
+		It("does not let mutating a created object change the recorded action", func() {
+			subject.ClearActions()
+			otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "master-1"}}
+			Expect(subject.Create(ctx, otherPod)).To(Succeed())
+			otherPod.Labels = map[string]string{"mutated": "true"}
+
+			Expect(subject.MutatingActions()).To(HaveLen(1))
+			recordedPod := subject.MutatingActions()[0].(testing.CreateAction).GetObject().(*corev1.Pod)
+			Expect(recordedPod.Labels).NotTo(HaveKey("mutated"))
+		})
+
+		It("does not let mutating a gotten object change the object a reactor returns", func() {
+			sharedPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name, Labels: map[string]string{"shared": "true"}},
+			}
+			subject.PrependReactor("get", "pods", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, sharedPod, nil
+			})
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			pod.Labels["shared"] = "mutated"
+
+			Expect(sharedPod.Labels).To(HaveKeyWithValue("shared", "true"))
+			var refetchedPod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &refetchedPod)).To(Succeed())
+			Expect(refetchedPod.Labels).To(HaveKeyWithValue("shared", "true"))
+		})
+
+		It("does not let mutating a stale object change a subsequent stale Get", func() {
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			subject.SimulateStaleCache(2)
+
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			pod.Labels = map[string]string{"mutated": "true"}
+
+			var stalePod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &stalePod)).To(Succeed())
+			Expect(stalePod.Labels).NotTo(HaveKey("mutated"))
+		})
+	})
+
+	Describe("kinds served in several versions", func() {
+		It("gets the object in the version it was created in", func() {
+			ctx := context.Background()
+			allowExpansion := true
+			storageClass := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+				Provisioner:          "kubernetes.io/no-provisioner",
+				AllowVolumeExpansion: &allowExpansion,
+			}
+			Expect(subject.Create(ctx, storageClass)).To(Succeed())
+
+			var gotten storagev1.StorageClass
+			Expect(subject.Get(ctx, types.NamespacedName{Name: "standard"}, &gotten)).To(Succeed())
+			Expect(gotten.AllowVolumeExpansion).To(PointTo(BeTrue()))
+			Expect(subject.Actions()[len(subject.Actions())-1].GetResource()).To(Equal(storagev1.SchemeGroupVersion.WithResource("storageclasses")))
+		})
+	})
+
+	Describe("List pagination", func() {
+		var ctx context.Context
+		BeforeEach(func() {
+			ctx = context.Background()
+			// With master-0, there are 5 pods in test-ns
+			for i := 0; i < 4; i++ {
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "segment-a-" + strconv.Itoa(i)}}
+				Expect(subject.Create(ctx, pod)).To(Succeed())
+			}
+			otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "other-ns", Name: "master-0"}}
+			Expect(subject.Create(ctx, otherPod)).To(Succeed())
+		})
+
+		podNames := func(podList corev1.PodList) []string {
+			var names []string
+			for _, pod := range podList.Items {
+				names = append(names, pod.Name)
+			}
+			return names
+		}
+
+		It("pages through all of the objects without duplicates", func() {
+			var firstPage, secondPage, lastPage corev1.PodList
+			Expect(subject.List(ctx, &firstPage, client.InNamespace("test-ns"), client.Limit(2))).To(Succeed())
+			Expect(podNames(firstPage)).To(Equal([]string{"master-0", "segment-a-0"}))
+			Expect(firstPage.Continue).NotTo(BeEmpty())
+
+			Expect(subject.List(ctx, &secondPage, client.InNamespace("test-ns"), client.Limit(2), client.Continue(firstPage.Continue))).To(Succeed())
+			Expect(podNames(secondPage)).To(Equal([]string{"segment-a-1", "segment-a-2"}))
+			Expect(secondPage.Continue).NotTo(BeEmpty())
+
+			Expect(subject.List(ctx, &lastPage, client.InNamespace("test-ns"), client.Limit(2), client.Continue(secondPage.Continue))).To(Succeed())
+			Expect(podNames(lastPage)).To(Equal([]string{"segment-a-3"}))
+			Expect(lastPage.Continue).To(BeEmpty())
+		})
+
+		It("returns the same page for the same continue token", func() {
+			var firstPage, secondPage, secondPageAgain corev1.PodList
+			Expect(subject.List(ctx, &firstPage, client.InNamespace("test-ns"), client.Limit(2))).To(Succeed())
+			Expect(subject.List(ctx, &secondPage, client.InNamespace("test-ns"), client.Limit(2), client.Continue(firstPage.Continue))).To(Succeed())
+			Expect(subject.List(ctx, &secondPageAgain, client.InNamespace("test-ns"), client.Limit(2), client.Continue(firstPage.Continue))).To(Succeed())
+			Expect(secondPageAgain.Items).To(Equal(secondPage.Items))
+			Expect(secondPageAgain.Continue).To(Equal(secondPage.Continue))
+		})
+
+		It("resumes after the last object returned when objects are deleted between pages", func() {
+			var firstPage, secondPage corev1.PodList
+			Expect(subject.List(ctx, &firstPage, client.InNamespace("test-ns"), client.Limit(2))).To(Succeed())
+			Expect(subject.Delete(ctx, &firstPage.Items[0])).To(Succeed())
+
+			Expect(subject.List(ctx, &secondPage, client.InNamespace("test-ns"), client.Limit(2), client.Continue(firstPage.Continue))).To(Succeed())
+			Expect(podNames(secondPage)).To(Equal([]string{"segment-a-1", "segment-a-2"}))
+		})
+
+		It("returns everything when the limit is not reached", func() {
+			var podList corev1.PodList
+			Expect(subject.List(ctx, &podList, client.InNamespace("test-ns"), client.Limit(5))).To(Succeed())
+			Expect(podList.Items).To(HaveLen(5))
+			Expect(podList.Continue).To(BeEmpty())
+		})
+
+		It("rejects a continue token from a List of another namespace", func() {
+			var firstPage, otherPage corev1.PodList
+			Expect(subject.List(ctx, &firstPage, client.InNamespace("test-ns"), client.Limit(2))).To(Succeed())
+			err := subject.List(ctx, &otherPage, client.InNamespace("other-ns"), client.Limit(2), client.Continue(firstPage.Continue))
+			Expect(apierrs.IsBadRequest(err)).To(BeTrue(), "expected a BadRequest, got %v", err)
+		})
+
+		It("rejects a malformed continue token", func() {
+			var podList corev1.PodList
+			err := subject.List(ctx, &podList, client.InNamespace("test-ns"), client.Continue("not a token"))
+			Expect(apierrs.IsBadRequest(err)).To(BeTrue(), "expected a BadRequest, got %v", err)
+		})
+	})
+
+	Describe("field selectors", func() {
+		var ctx context.Context
+		createPod := func(name, major string) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-ns",
+				Name:      name,
+				Labels:    map[string]string{"greenplum-major-version": major},
+			}}
+			Expect(subject.Create(ctx, pod)).To(Succeed())
+		}
+		listPodNames := func(opts ...client.ListOption) []string {
+			var podList corev1.PodList
+			Expect(subject.List(ctx, &podList, append(opts, client.InNamespace("test-ns"))...)).To(Succeed())
+			var names []string
+			for _, pod := range podList.Items {
+				names = append(names, pod.Name)
+			}
+			return names
+		}
+		BeforeEach(func() {
+			ctx = context.Background()
+			createPod("segment-a-0", "6")
+			createPod("segment-a-1", "6")
+			createPod("segment-a-2", "7")
+			Expect(subject.IndexField(ctx, &corev1.Pod{}, "greenplum-major", func(obj client.Object) []string {
+				return []string{obj.GetLabels()["greenplum-major-version"]}
+			})).To(Succeed())
+		})
+
+		It("lists only the objects whose registered field matches", func() {
+			Expect(listPodNames(client.MatchingFields{"greenplum-major": "6"})).To(ConsistOf("segment-a-0", "segment-a-1"))
+			Expect(listPodNames(client.MatchingFields{"greenplum-major": "7"})).To(ConsistOf("segment-a-2"))
+			Expect(listPodNames(client.MatchingFields{"greenplum-major": "5"})).To(BeEmpty())
+		})
+
+		It("requires every selector to match", func() {
+			Expect(listPodNames(client.MatchingFields{"greenplum-major": "6", "metadata.name": "segment-a-1"})).To(ConsistOf("segment-a-1"))
+			Expect(listPodNames(client.MatchingFields{"greenplum-major": "7", "metadata.name": "segment-a-1"})).To(BeEmpty())
+		})
+
+		It("supports != requirements", func() {
+			selector, err := fields.ParseSelector("greenplum-major!=6")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listPodNames(client.MatchingFieldsSelector{Selector: selector})).To(ConsistOf("master-0", "segment-a-2"))
+		})
+
+		It("selects on metadata.name and metadata.namespace without an index", func() {
+			Expect(listPodNames(client.MatchingFields{"metadata.name": "master-0"})).To(ConsistOf("master-0"))
+			Expect(listPodNames(client.MatchingFields{"metadata.namespace": "test-ns"})).To(HaveLen(4))
+		})
+
+		It("returns an error for a field that is not indexed", func() {
+			var podList corev1.PodList
+			err := subject.List(ctx, &podList, client.InNamespace("test-ns"), client.MatchingFields{"spec.hostname": "master-0"})
+			Expect(apierrs.IsBadRequest(err)).To(BeTrue(), "expected a BadRequest, got %v", err)
+			Expect(err).To(MatchError("field label not supported: spec.hostname: no index is registered for it on Pod"))
+		})
+
+		When("pods have a phase and a node", func() {
+			setPod := func(name string, phase corev1.PodPhase, nodeName string) {
+				var pod corev1.Pod
+				Expect(subject.Get(ctx, types.NamespacedName{Namespace: "test-ns", Name: name}, &pod)).To(Succeed())
+				pod.Spec.NodeName = nodeName
+				pod.Status.Phase = phase
+				Expect(subject.Update(ctx, &pod)).To(Succeed())
+			}
+			BeforeEach(func() {
+				setPod("master-0", corev1.PodRunning, "node-1")
+				setPod("segment-a-0", corev1.PodRunning, "node-2")
+				setPod("segment-a-1", corev1.PodPending, "")
+				setPod("segment-a-2", corev1.PodFailed, "node-1")
+			})
+
+			It("selects on the fields the apiserver supports without an index", func() {
+				Expect(listPodNames(client.MatchingFields{"status.phase": "Running"})).To(ConsistOf("master-0", "segment-a-0"))
+				Expect(listPodNames(client.MatchingFields{"spec.nodeName": "node-1"})).To(ConsistOf("master-0", "segment-a-2"))
+				Expect(listPodNames(client.MatchingFields{"spec.nodeName": ""})).To(ConsistOf("segment-a-1"))
+			})
+
+			It("parses field selectors with ==, != and several requirements", func() {
+				selector, err := fields.ParseSelector("status.phase==Running,spec.nodeName!=node-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(listPodNames(client.MatchingFieldsSelector{Selector: selector})).To(ConsistOf("segment-a-0"))
+			})
+
+			It("combines field selectors with label selectors", func() {
+				Expect(listPodNames(client.MatchingFields{"status.phase": "Running"}, client.MatchingLabels{"greenplum-major-version": "6"})).
+					To(ConsistOf("segment-a-0"))
+			})
+
+			It("records the selectors on the list action", func() {
+				subject.ClearActions()
+				listPodNames(client.MatchingFields{"status.phase": "Running"})
+				action := subject.Actions()[0].(testing.ListAction)
+				Expect(action.GetListRestrictions().Fields.String()).To(Equal("status.phase=Running"))
+			})
+		})
+
+		It("selects on the fields of other built-in kinds", func() {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: corev1.NodeSpec{Unschedulable: true}}
+			Expect(subject.Create(ctx, node)).To(Succeed())
+			Expect(subject.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}})).To(Succeed())
+			var nodeList corev1.NodeList
+			Expect(subject.List(ctx, &nodeList, client.MatchingFields{"spec.unschedulable": "true"})).To(Succeed())
+			Expect(nodeList.Items).To(ConsistOf(HaveField("Name", "node-1")))
+		})
+
+		It("selects events on their involved object", func() {
+			createEvent := func(name, kind, involvedName string) {
+				event := &corev1.Event{
+					ObjectMeta:     metav1.ObjectMeta{Namespace: "test-ns", Name: name},
+					InvolvedObject: corev1.ObjectReference{Kind: kind, Namespace: "test-ns", Name: involvedName},
+				}
+				Expect(subject.Create(ctx, event)).To(Succeed())
+			}
+			createEvent("pvc-event", "PersistentVolumeClaim", "pgdata-segment-a-0")
+			createEvent("other-pvc-event", "PersistentVolumeClaim", "pgdata-segment-a-1")
+			createEvent("pod-event", "Pod", "pgdata-segment-a-0")
+			var eventList corev1.EventList
+			Expect(subject.List(ctx, &eventList, client.InNamespace("test-ns"), client.MatchingFields{
+				"involvedObject.kind": "PersistentVolumeClaim",
+				"involvedObject.name": "pgdata-segment-a-0",
+			})).To(Succeed())
+			Expect(eventList.Items).To(ConsistOf(HaveField("Name", "pvc-event")))
+		})
+
+		It("does not use the index of another kind", func() {
+			var serviceList corev1.ServiceList
+			err := subject.List(ctx, &serviceList, client.InNamespace("test-ns"), client.MatchingFields{"greenplum-major": "6"})
+			Expect(err).To(MatchError("field label not supported: greenplum-major: no index is registered for it on Service"))
+		})
+
+		It("does not allow indexing a field twice", func() {
+			err := subject.IndexField(ctx, &corev1.Pod{}, "greenplum-major", func(obj client.Object) []string { return nil })
+			Expect(err).To(MatchError("indexer conflict: field greenplum-major is already indexed for Pod"))
+		})
+	})
+
+	Describe("label selectors", func() {
+		var ctx context.Context
+		createPod := func(name string, podLabels map[string]string) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: name, Labels: podLabels}}
+			Expect(subject.Create(ctx, pod)).To(Succeed())
+		}
+		listPodNames := func(selector string) []string {
+			parsed, err := labels.Parse(selector)
+			Expect(err).NotTo(HaveOccurred())
+			var podList corev1.PodList
+			Expect(subject.List(ctx, &podList, client.InNamespace("test-ns"), client.MatchingLabelsSelector{Selector: parsed})).To(Succeed())
+			var names []string
+			for _, pod := range podList.Items {
+				names = append(names, pod.Name)
+			}
+			return names
+		}
+		BeforeEach(func() {
+			ctx = context.Background()
+			createPod("segment-a-0", map[string]string{"type": "segment", "role": "primary"})
+			createPod("segment-b-0", map[string]string{"type": "segment", "role": "mirror"})
+			createPod("master-1", map[string]string{"type": "master"})
+		})
+
+		DescribeTable("applies set-based requirements",
+			func(selector string, expected ...string) {
+				names := listPodNames(selector)
+				if len(expected) == 0 {
+					Expect(names).To(BeEmpty())
+				} else {
+					Expect(names).To(ConsistOf(expected))
+				}
+			},
+			Entry("in", "role in (primary,mirror)", "segment-a-0", "segment-b-0"),
+			Entry("notin, which matches objects without the label", "role notin (mirror)", "master-0", "master-1", "segment-a-0"),
+			Entry("exists", "role", "segment-a-0", "segment-b-0"),
+			Entry("does not exist", "!role", "master-0", "master-1"),
+			Entry("!=", "type!=segment", "master-0", "master-1"),
+			Entry("several requirements", "type=segment,role notin (primary)", "segment-b-0"),
+			Entry("in with no match", "type in (standby)"),
+		)
+
+		It("rejects a selector the apiserver could not parse", func() {
+			var podList corev1.PodList
+			invalid := labels.SelectorFromValidatedSet(labels.Set{"type": "not a valid value"})
+			err := subject.List(ctx, &podList, client.InNamespace("test-ns"), client.MatchingLabelsSelector{Selector: invalid})
+			Expect(apierrs.IsBadRequest(err)).To(BeTrue(), "expected a BadRequest, got %v", err)
+		})
+	})
+
+	Describe("Watch", func() {
+		var (
+			ctx     context.Context
+			watcher watch.Interface
+		)
+		newCluster := func(namespace, name string, labels map[string]string) *greenplumv1.GreenplumCluster {
+			return &greenplumv1.GreenplumCluster{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+		}
+		nextEvent := func() watch.Event {
+			var event watch.Event
+			Eventually(watcher.ResultChan()).Should(Receive(&event))
+			return event
+		}
+		BeforeEach(func() {
+			ctx = context.Background()
+			watcher = nil
+		})
+		AfterEach(func() {
+			if watcher != nil {
+				watcher.Stop()
+			}
+		})
+
+		It("reports an Added event for a GreenplumCluster created after the watch started", func() {
+			var err error
+			watcher, err = subject.Watch(ctx, &greenplumv1.GreenplumClusterList{}, client.InNamespace("test-ns"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(subject.Create(ctx, newCluster("test-ns", "my-greenplum", nil))).To(Succeed())
+
+			event := nextEvent()
+			Expect(event.Type).To(Equal(watch.Added))
+			Expect(event.Object).To(BeAssignableToTypeOf(&greenplumv1.GreenplumCluster{}))
+			Expect(event.Object.(*greenplumv1.GreenplumCluster).Name).To(Equal("my-greenplum"))
+		})
+
+		It("reports Modified and Deleted events", func() {
+			var err error
+			watcher, err = subject.Watch(ctx, &greenplumv1.GreenplumClusterList{}, client.InNamespace("test-ns"))
+			Expect(err).NotTo(HaveOccurred())
+
+			cluster := newCluster("test-ns", "my-greenplum", nil)
+			Expect(subject.Create(ctx, cluster)).To(Succeed())
+			Expect(nextEvent().Type).To(Equal(watch.Added))
+
+			Expect(subject.Get(ctx, client.ObjectKeyFromObject(cluster), cluster)).To(Succeed())
+			cluster.Spec.Segments.PrimarySegmentCount = 2
+			Expect(subject.Update(ctx, cluster)).To(Succeed())
+			event := nextEvent()
+			Expect(event.Type).To(Equal(watch.Modified))
+			Expect(event.Object.(*greenplumv1.GreenplumCluster).Spec.Segments.PrimarySegmentCount).To(Equal(int32(2)))
+
+			Expect(subject.Delete(ctx, cluster)).To(Succeed())
+			Expect(nextEvent().Type).To(Equal(watch.Deleted))
+		})
+
+		It("only reports objects in the namespace that match the label selector", func() {
+			var err error
+			watcher, err = subject.Watch(ctx, &greenplumv1.GreenplumClusterList{},
+				client.InNamespace("test-ns"), client.MatchingLabels{"team": "analytics"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(subject.Create(ctx, newCluster("other-ns", "other-namespace", map[string]string{"team": "analytics"}))).To(Succeed())
+			Expect(subject.Create(ctx, newCluster("test-ns", "other-team", map[string]string{"team": "finance"}))).To(Succeed())
+			Expect(subject.Create(ctx, newCluster("test-ns", "analytics", map[string]string{"team": "analytics"}))).To(Succeed())
+
+			Expect(nextEvent().Object.(*greenplumv1.GreenplumCluster).Name).To(Equal("analytics"))
+			Consistently(watcher.ResultChan()).ShouldNot(Receive())
+		})
+
+		It("reports objects in every namespace when no namespace is given", func() {
+			var err error
+			watcher, err = subject.Watch(ctx, &greenplumv1.GreenplumClusterList{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(subject.Create(ctx, newCluster("other-ns", "my-greenplum", nil))).To(Succeed())
+			Expect(nextEvent().Object.(*greenplumv1.GreenplumCluster).Namespace).To(Equal("other-ns"))
+		})
+
+		It("records a watch action", func() {
+			var err error
+			watcher, err = subject.Watch(ctx, &greenplumv1.GreenplumClusterList{}, client.InNamespace("test-ns"), client.MatchingLabels{"team": "analytics"})
+			Expect(err).NotTo(HaveOccurred())
+			actions := subject.Actions()
+			Expect(actions).To(HaveLen(1))
+			action := actions[0].(testing.WatchAction)
+			Expect(action.GetVerb()).To(Equal("watch"))
+			Expect(action.GetResource().Resource).To(Equal("greenplumclusters"))
+			Expect(action.GetNamespace()).To(Equal("test-ns"))
+			Expect(action.GetWatchRestrictions().Labels.String()).To(Equal("team=analytics"))
+		})
+
+		It("goes through the watch reactors", func() {
+			fakeWatcher := watch.NewFake()
+			subject.PrependWatchReactor("greenplumclusters", func(action testing.Action) (bool, watch.Interface, error) {
+				return true, fakeWatcher, nil
+			})
+			var err error
+			watcher, err = subject.Watch(ctx, &greenplumv1.GreenplumClusterList{}, client.InNamespace("test-ns"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(watcher).To(BeIdenticalTo(fakeWatcher))
+		})
+
+		It("returns an error for a field selector that is not indexed", func() {
+			_, err := subject.Watch(ctx, &greenplumv1.GreenplumClusterList{}, client.MatchingFields{"status.phase": "Running"})
+			Expect(apierrs.IsBadRequest(err)).To(BeTrue(), "expected a BadRequest, got %v", err)
+		})
+	})
+
+	Describe("Delete", func() {
+		var ctx context.Context
+		BeforeEach(func() {
+			ctx = context.Background()
+		})
+
+		It("removes the object right away by default", func() {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name}}
+			Expect(subject.Delete(ctx, &pod)).To(Succeed())
+			Expect(apierrs.IsNotFound(subject.Get(ctx, podKey, &pod))).To(BeTrue())
+		})
+
+		It("removes the object right away for background deletion", func() {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name}}
+			Expect(subject.Delete(ctx, &pod, client.PropagationPolicy(metav1.DeletePropagationBackground))).To(Succeed())
+			Expect(apierrs.IsNotFound(subject.Get(ctx, podKey, &pod))).To(BeTrue())
+		})
+
+		It("passes the options on to the delegate", func() {
+			delegate := &deleteRecordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+			subject = reactive.NewClient(delegate)
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name}}
+			Expect(subject.Create(ctx, &pod)).To(Succeed())
+
+			Expect(subject.Delete(ctx, &pod, client.PropagationPolicy(metav1.DeletePropagationForeground), client.GracePeriodSeconds(30))).To(Succeed())
+			Expect(delegate.deleteOptions).To(HaveLen(1))
+			Expect(delegate.deleteOptions[0].PropagationPolicy).To(PointTo(Equal(metav1.DeletePropagationForeground)))
+			Expect(delegate.deleteOptions[0].GracePeriodSeconds).To(PointTo(Equal(int64(30))))
+		})
+
+		It("records the propagation policy on the action", func() {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name}}
+			Expect(subject.Delete(ctx, &pod, client.PropagationPolicy(metav1.DeletePropagationForeground))).To(Succeed())
+			actions := subject.Actions()
+			Expect(actions).To(HaveLen(1))
+			deleteOptions := actions[0].(testing.DeleteAction).GetDeleteOptions()
+			Expect(deleteOptions.PropagationPolicy).To(PointTo(Equal(metav1.DeletePropagationForeground)))
+		})
+
+		When("deleting in the foreground", func() {
+			var deletedPod corev1.Pod
+			BeforeEach(func() {
+				pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name}}
+				Expect(subject.Delete(ctx, &pod, client.PropagationPolicy(metav1.DeletePropagationForeground))).To(Succeed())
+				Expect(subject.Get(ctx, podKey, &deletedPod)).To(Succeed())
+			})
+
+			It("sets a deletionTimestamp and the foregroundDeletion finalizer", func() {
+				Expect(deletedPod.DeletionTimestamp).NotTo(BeNil())
+				Expect(deletedPod.Finalizers).To(ConsistOf(metav1.FinalizerDeleteDependents))
+			})
+
+			It("keeps the object until its finalizers are cleared", func() {
+				deletedPod.Finalizers = nil
+				Expect(subject.Update(ctx, &deletedPod)).To(Succeed())
+				var pod corev1.Pod
+				Expect(apierrs.IsNotFound(subject.Get(ctx, podKey, &pod))).To(BeTrue())
+			})
+
+			It("does not add the finalizer twice when deleted again", func() {
+				Expect(subject.Delete(ctx, &deletedPod, client.PropagationPolicy(metav1.DeletePropagationForeground))).To(Succeed())
+				var pod corev1.Pod
+				Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+				Expect(pod.Finalizers).To(ConsistOf(metav1.FinalizerDeleteDependents))
+			})
+		})
+
+		When("the object has finalizers of its own", func() {
+			BeforeEach(func() {
+				var pod corev1.Pod
+				Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+				pod.Finalizers = []string{"example.com/cleanup"}
+				Expect(subject.Update(ctx, &pod)).To(Succeed())
+			})
+
+			It("keeps them alongside the orphan finalizer", func() {
+				pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: podKey.Name}}
+				Expect(subject.Delete(ctx, &pod, client.PropagationPolicy(metav1.DeletePropagationOrphan))).To(Succeed())
+				var deletedPod corev1.Pod
+				Expect(subject.Get(ctx, podKey, &deletedPod)).To(Succeed())
+				Expect(deletedPod.DeletionTimestamp).NotTo(BeNil())
+				Expect(deletedPod.Finalizers).To(ConsistOf("example.com/cleanup", metav1.FinalizerOrphanDependents))
+			})
+		})
+
+		It("returns NotFound for a missing object deleted in the foreground", func() {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "missing"}}
+			err := subject.Delete(ctx, &pod, client.PropagationPolicy(metav1.DeletePropagationForeground))
+			Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("DeleteAllOf", func() {
+		var ctx context.Context
+		createPod := func(namespace, name string, labels map[string]string) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+			Expect(subject.Create(ctx, pod)).To(Succeed())
+		}
+		podNames := func() []string {
+			var pods corev1.PodList
+			Expect(subject.List(ctx, &pods)).To(Succeed())
+			var names []string
+			for _, pod := range pods.Items {
+				names = append(names, pod.Namespace+"/"+pod.Name)
+			}
+			return names
+		}
+		clusterLabels := map[string]string{"greenplum-cluster": "my-greenplum"}
+		BeforeEach(func() {
+			ctx = context.Background()
+			createPod("test-ns", "segment-a-0", clusterLabels)
+			createPod("test-ns", "segment-a-1", clusterLabels)
+			createPod("other-ns", "segment-a-0", clusterLabels)
+			createPod("other-ns", "unrelated", nil)
+			subject.ClearActions()
+		})
+
+		It("removes only the matching objects in the namespace", func() {
+			Expect(subject.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("test-ns"), client.MatchingLabels(clusterLabels))).To(Succeed())
+			Expect(podNames()).To(ConsistOf("test-ns/master-0", "other-ns/segment-a-0", "other-ns/unrelated"))
+		})
+
+		It("removes the matching objects in every namespace when no namespace is given", func() {
+			Expect(subject.DeleteAllOf(ctx, &corev1.Pod{}, client.MatchingLabels(clusterLabels))).To(Succeed())
+			Expect(podNames()).To(ConsistOf("test-ns/master-0", "other-ns/unrelated"))
+		})
+
+		It("records a delete-collection action with the options", func() {
+			Expect(subject.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("test-ns"),
+				client.MatchingLabels(clusterLabels), client.MatchingFields{"metadata.name": "segment-a-0"})).To(Succeed())
+			actions := subject.Actions()
+			Expect(actions).To(HaveLen(1))
+			action := actions[0].(testing.DeleteCollectionAction)
+			Expect(action.GetVerb()).To(Equal("delete-collection"))
+			Expect(action.GetResource().Resource).To(Equal("pods"))
+			Expect(action.GetNamespace()).To(Equal("test-ns"))
+			Expect(action.GetListRestrictions().Labels.String()).To(Equal("greenplum-cluster=my-greenplum"))
+			Expect(action.GetListRestrictions().Fields.String()).To(Equal("metadata.name=segment-a-0"))
+		})
+
+		It("goes through the reactors", func() {
+			subject.PrependReactor("delete-collection", "pods", func(action testing.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("injected error")
+			})
+			Expect(subject.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("test-ns"))).To(MatchError("injected error"))
+			Expect(podNames()).To(HaveLen(5))
+		})
+
+		It("returns an error for a kind it cannot resolve", func() {
+			widget := &unstructured.Unstructured{}
+			widget.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+			err := subject.DeleteAllOf(ctx, widget, client.InNamespace("test-ns"))
+			Expect(err).To(MatchError(ContainSubstring("failed deleting objects: ")))
+			Expect(subject.Actions()).To(BeEmpty())
+		})
+	})
+
+	Describe("Patch", func() {
+		var (
+			ctx        context.Context
+			clusterKey types.NamespacedName
+		)
+		getCluster := func() *greenplumv1.GreenplumCluster {
+			var cluster greenplumv1.GreenplumCluster
+			Expect(subject.Get(ctx, clusterKey, &cluster)).To(Succeed())
+			return &cluster
+		}
+		BeforeEach(func() {
+			ctx = context.Background()
+			clusterKey = types.NamespacedName{Namespace: "test-ns", Name: "my-greenplum"}
+			cluster := &greenplumv1.GreenplumCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: clusterKey.Namespace, Name: clusterKey.Name},
+			}
+			cluster.Spec.Segments.PrimarySegmentCount = 1
+			Expect(subject.Create(ctx, cluster)).To(Succeed())
+		})
+
+		When("the patch has a resourceVersion precondition", func() {
+			optimisticPatch := func(cluster *greenplumv1.GreenplumCluster) error {
+				original := cluster.DeepCopy()
+				cluster.Spec.Segments.PrimarySegmentCount = 3
+				return subject.Patch(ctx, cluster, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+			}
+
+			It("succeeds when the resourceVersion is current", func() {
+				Expect(optimisticPatch(getCluster())).To(Succeed())
+				Expect(getCluster().Spec.Segments.PrimarySegmentCount).To(Equal(int32(3)))
+			})
+
+			It("fails with a conflict when the resourceVersion is stale", func() {
+				cluster := getCluster()
+				otherCluster := getCluster()
+				otherCluster.Spec.Segments.PrimarySegmentCount = 2
+				Expect(subject.Update(ctx, otherCluster)).To(Succeed())
+
+				err := optimisticPatch(cluster)
+				Expect(apierrs.IsConflict(err)).To(BeTrue(), "expected a conflict, got %v", err)
+				Expect(getCluster().Spec.Segments.PrimarySegmentCount).To(Equal(int32(2)))
+			})
+
+			It("fails with a conflict when the status is patched with a stale resourceVersion", func() {
+				cluster := getCluster()
+				Expect(subject.Update(ctx, getCluster())).To(Succeed())
+
+				original := cluster.DeepCopy()
+				cluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+				err := subject.Status().Patch(ctx, cluster, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+				Expect(apierrs.IsConflict(err)).To(BeTrue(), "expected a conflict, got %v", err)
+				Expect(getCluster().Status.Phase).To(BeEmpty())
+			})
+		})
+
+		It("applies a patch without a precondition over a newer version", func() {
+			cluster := getCluster()
+			otherCluster := getCluster()
+			otherCluster.Labels = map[string]string{"updated": "true"}
+			Expect(subject.Update(ctx, otherCluster)).To(Succeed())
+
+			original := cluster.DeepCopy()
+			cluster.Spec.Segments.PrimarySegmentCount = 3
+			Expect(subject.Patch(ctx, cluster, client.MergeFrom(original))).To(Succeed())
+			stored := getCluster()
+			Expect(stored.Spec.Segments.PrimarySegmentCount).To(Equal(int32(3)))
+			Expect(stored.Labels).To(HaveKeyWithValue("updated", "true"))
+		})
+	})
+
+	Describe("server-side apply", func() {
+		var (
+			ctx          context.Context
+			configMapKey types.NamespacedName
+		)
+		applyConfigMap := func(manager string, labels, data map[string]string, opts ...client.PatchOption) error {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: configMapKey.Namespace, Name: configMapKey.Name, Labels: labels},
+				Data:       data,
+			}
+			return subject.Patch(ctx, configMap, client.Apply, append(opts, client.FieldOwner(manager))...)
+		}
+		getConfigMap := func() *corev1.ConfigMap {
+			var configMap corev1.ConfigMap
+			Expect(subject.Get(ctx, configMapKey, &configMap)).To(Succeed())
+			return &configMap
+		}
+		managedFieldsOf := func(manager string) string {
+			for _, entry := range getConfigMap().ManagedFields {
+				if entry.Manager == manager {
+					Expect(entry.Operation).To(Equal(metav1.ManagedFieldsOperationApply))
+					return string(entry.FieldsV1.Raw)
+				}
+			}
+			return ""
+		}
+		BeforeEach(func() {
+			ctx = context.Background()
+			configMapKey = types.NamespacedName{Namespace: "test-ns", Name: "greenplum-config"}
+		})
+
+		It("creates the object and records the fields of its manager", func() {
+			Expect(applyConfigMap("operator", map[string]string{"app": "greenplum"}, map[string]string{"hostfile": "master-0"})).To(Succeed())
+			configMap := getConfigMap()
+			Expect(configMap.Labels).To(Equal(map[string]string{"app": "greenplum"}))
+			Expect(configMap.Data).To(Equal(map[string]string{"hostfile": "master-0"}))
+			Expect(managedFieldsOf("operator")).To(MatchJSON(`{"f:data":{"f:hostfile":{}},"f:metadata":{"f:labels":{"f:app":{}}}}`))
+		})
+
+		It("records the field manager and force flag on the action", func() {
+			Expect(applyConfigMap("operator", nil, map[string]string{"hostfile": "master-0"}, client.ForceOwnership)).To(Succeed())
+			actions := subject.Actions()
+			Expect(actions).To(HaveLen(1))
+			action, ok := actions[0].(reactive.ApplyPatchAction)
+			Expect(ok).To(BeTrue(), "expected an ApplyPatchAction, got %T", actions[0])
+			Expect(action.GetPatchType()).To(Equal(types.ApplyPatchType))
+			Expect(action.FieldManager).To(Equal("operator"))
+			Expect(action.Force).To(BeTrue())
+		})
+
+		It("requires a field manager", func() {
+			configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: configMapKey.Namespace, Name: configMapKey.Name}}
+			err := subject.Patch(ctx, configMap, client.Apply)
+			Expect(apierrs.IsBadRequest(err)).To(BeTrue(), "expected a bad request, got %v", err)
+		})
+
+		When("another manager owns some of the fields", func() {
+			BeforeEach(func() {
+				Expect(applyConfigMap("user", map[string]string{"team": "data"}, map[string]string{"notes": "do not delete"})).To(Succeed())
+				Expect(applyConfigMap("operator", map[string]string{"app": "greenplum"}, map[string]string{"hostfile": "master-0", "gucs": "on"})).To(Succeed())
+			})
+
+			It("keeps the fields of both managers", func() {
+				configMap := getConfigMap()
+				Expect(configMap.Labels).To(Equal(map[string]string{"app": "greenplum", "team": "data"}))
+				Expect(configMap.Data).To(Equal(map[string]string{"notes": "do not delete", "hostfile": "master-0", "gucs": "on"}))
+			})
+
+			It("removes only the fields the applying manager no longer applies", func() {
+				Expect(applyConfigMap("operator", nil, map[string]string{"hostfile": "master-0"})).To(Succeed())
+				configMap := getConfigMap()
+				Expect(configMap.Labels).To(Equal(map[string]string{"team": "data"}))
+				Expect(configMap.Data).To(Equal(map[string]string{"notes": "do not delete", "hostfile": "master-0"}))
+				Expect(managedFieldsOf("operator")).To(MatchJSON(`{"f:data":{"f:hostfile":{}}}`))
+				Expect(managedFieldsOf("user")).To(MatchJSON(`{"f:data":{"f:notes":{}},"f:metadata":{"f:labels":{"f:team":{}}}}`))
+			})
+
+			It("keeps a field that both managers applied with the same value", func() {
+				Expect(applyConfigMap("user", map[string]string{"team": "data"}, map[string]string{"notes": "do not delete", "gucs": "on"})).To(Succeed())
+				Expect(applyConfigMap("operator", map[string]string{"app": "greenplum"}, map[string]string{"hostfile": "master-0"})).To(Succeed())
+				Expect(getConfigMap().Data).To(HaveKeyWithValue("gucs", "on"))
+			})
+
+			It("fails with a conflict when applying a different value to a field of the other manager", func() {
+				err := applyConfigMap("operator", map[string]string{"app": "greenplum"}, map[string]string{"hostfile": "master-0", "notes": "replaced"})
+				Expect(apierrs.IsConflict(err)).To(BeTrue(), "expected a conflict, got %v", err)
+				Expect(err).To(MatchError(`Apply failed with 1 conflicts: conflict with "user": .data.notes`))
+				Expect(getConfigMap().Data).To(HaveKeyWithValue("notes", "do not delete"))
+			})
+
+			It("takes over the field of the other manager when forced", func() {
+				Expect(applyConfigMap("operator", map[string]string{"app": "greenplum"}, map[string]string{"hostfile": "master-0", "notes": "replaced"}, client.ForceOwnership)).To(Succeed())
+				Expect(getConfigMap().Data).To(HaveKeyWithValue("notes", "replaced"))
+				Expect(managedFieldsOf("user")).To(MatchJSON(`{"f:metadata":{"f:labels":{"f:team":{}}}}`))
+			})
+
+			It("does not write the object when nothing changed", func() {
+				resourceVersion := getConfigMap().ResourceVersion
+				Expect(applyConfigMap("operator", map[string]string{"app": "greenplum"}, map[string]string{"hostfile": "master-0", "gucs": "on"})).To(Succeed())
+				Expect(getConfigMap().ResourceVersion).To(Equal(resourceVersion))
+			})
+		})
+
+		It("leaves fields written by an update to the object", func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: configMapKey.Namespace, Name: configMapKey.Name},
+				Data:       map[string]string{"created": "by update"},
+			}
+			Expect(subject.Create(ctx, configMap)).To(Succeed())
+			Expect(applyConfigMap("operator", nil, map[string]string{"hostfile": "master-0"})).To(Succeed())
+			Expect(applyConfigMap("operator", nil, map[string]string{"gucs": "on"})).To(Succeed())
+			Expect(getConfigMap().Data).To(Equal(map[string]string{"created": "by update", "gucs": "on"}))
+		})
+	})
+
+	Describe("Status", func() {
+		var (
+			ctx        context.Context
+			clusterKey types.NamespacedName
+		)
+		getCluster := func() *greenplumv1.GreenplumCluster {
+			var cluster greenplumv1.GreenplumCluster
+			Expect(subject.Get(ctx, clusterKey, &cluster)).To(Succeed())
+			return &cluster
+		}
+		BeforeEach(func() {
+			ctx = context.Background()
+			clusterKey = types.NamespacedName{Namespace: "test-ns", Name: "my-greenplum"}
+			cluster := &greenplumv1.GreenplumCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: clusterKey.Namespace, Name: clusterKey.Name},
+			}
+			cluster.Spec.Segments.PrimarySegmentCount = 1
+			Expect(subject.Create(ctx, cluster)).To(Succeed())
+			subject.ClearActions()
+		})
+
+		Describe("Update", func() {
+			It("updates the status and leaves the rest of the object unchanged", func() {
+				cluster := getCluster()
+				cluster.Spec.Segments.PrimarySegmentCount = 3
+				cluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+				Expect(subject.Status().Update(ctx, cluster)).To(Succeed())
+
+				stored := getCluster()
+				Expect(stored.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+				Expect(stored.Spec.Segments.PrimarySegmentCount).To(Equal(int32(1)))
+				Expect(cluster.Spec.Segments.PrimarySegmentCount).To(Equal(int32(1)), "expected the stored object to be returned")
+				Expect(cluster.ResourceVersion).To(Equal(stored.ResourceVersion))
+			})
+
+			It("records an update action for the status subresource", func() {
+				cluster := getCluster()
+				subject.ClearActions()
+				Expect(subject.Status().Update(ctx, cluster)).To(Succeed())
+				actions := subject.Actions()
+				Expect(actions).To(HaveLen(1))
+				Expect(actions[0].GetVerb()).To(Equal("update"))
+				Expect(actions[0].GetResource().Resource).To(Equal("greenplumclusters"))
+				Expect(actions[0].GetSubresource()).To(Equal("status"))
+			})
+
+			It("fails on a stale resourceVersion", func() {
+				cluster := getCluster()
+				Expect(subject.Update(ctx, getCluster())).To(Succeed())
+				err := subject.Status().Update(ctx, cluster)
+				Expect(apierrs.IsConflict(err)).To(BeTrue(), "expected a conflict, got %v", err)
+			})
+
+			It("goes through the reactors", func() {
+				subject.PrependReactor("update", "greenplumclusters", func(action testing.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() == "status" {
+						return true, nil, errors.New("injected error")
+					}
+					return false, nil, nil
+				})
+				Expect(subject.Status().Update(ctx, getCluster())).To(MatchError("injected error"))
+			})
+		})
+
+		Describe("Patch", func() {
+			It("patches the status and leaves the rest of the object unchanged", func() {
+				cluster := getCluster()
+				original := cluster.DeepCopy()
+				cluster.Spec.Segments.PrimarySegmentCount = 3
+				cluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+				Expect(subject.Status().Patch(ctx, cluster, client.MergeFrom(original))).To(Succeed())
+
+				stored := getCluster()
+				Expect(stored.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+				Expect(stored.Spec.Segments.PrimarySegmentCount).To(Equal(int32(1)))
+			})
+
+			It("applies JSON patches to the status", func() {
+				patch := client.RawPatch(types.JSONPatchType, []byte(`[{"op":"add","path":"/status/phase","value":"Running"},{"op":"replace","path":"/spec/segments/primarySegmentCount","value":3}]`))
+				Expect(subject.Status().Patch(ctx, getCluster(), patch)).To(Succeed())
+
+				stored := getCluster()
+				Expect(stored.Status.Phase).To(Equal(greenplumv1.GreenplumClusterPhaseRunning))
+				Expect(stored.Spec.Segments.PrimarySegmentCount).To(Equal(int32(1)))
+			})
+
+			It("records a patch action for the status subresource", func() {
+				cluster := getCluster()
+				original := cluster.DeepCopy()
+				cluster.Status.Phase = greenplumv1.GreenplumClusterPhaseRunning
+				subject.ClearActions()
+				Expect(subject.Status().Patch(ctx, cluster, client.MergeFrom(original))).To(Succeed())
+				actions := subject.Actions()
+				Expect(actions).To(HaveLen(1))
+				action := actions[0].(testing.PatchAction)
+				Expect(action.GetVerb()).To(Equal("patch"))
+				Expect(action.GetResource().Resource).To(Equal("greenplumclusters"))
+				Expect(action.GetSubresource()).To(Equal("status"))
+				Expect(action.GetPatchType()).To(Equal(types.MergePatchType))
+				Expect(string(action.GetPatch())).To(Equal(`{"status":{"phase":"Running"}}`))
+			})
+
+			It("rejects apply patches", func() {
+				patch := client.RawPatch(types.ApplyPatchType, []byte(`{}`))
+				Expect(subject.Status().Patch(ctx, getCluster(), patch)).To(MatchError("unsupported patch type application/apply-patch+yaml for the status subresource"))
+			})
+		})
+	})
+
+	Describe("cluster-scoped resources", func() {
+		var ctx context.Context
+		BeforeEach(func() {
+			ctx = context.Background()
+		})
+
+		It("creates and gets them without a namespace", func() {
+			pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "local-pv-1"}}
+			Expect(subject.Create(ctx, pv)).To(Succeed())
+
+			var retrieved corev1.PersistentVolume
+			Expect(subject.Get(ctx, types.NamespacedName{Name: "local-pv-1"}, &retrieved)).To(Succeed())
+			Expect(retrieved.Name).To(Equal("local-pv-1"))
+			Expect(retrieved.Namespace).To(BeEmpty())
+
+			actions := subject.Actions()
+			Expect(actions).To(HaveLen(2))
+			Expect(actions[0].GetVerb()).To(Equal("create"))
+			Expect(actions[0].GetNamespace()).To(BeEmpty())
+			Expect(actions[1].GetVerb()).To(Equal("get"))
+			Expect(actions[1].GetNamespace()).To(BeEmpty())
+		})
+
+		It("ignores a namespace given for them, like the apiserver", func() {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "node-1"}}
+			Expect(subject.Create(ctx, node)).To(Succeed())
+			Expect(node.Namespace).To(BeEmpty())
+
+			var retrieved corev1.Node
+			Expect(subject.Get(ctx, types.NamespacedName{Namespace: "test-ns", Name: "node-1"}, &retrieved)).To(Succeed())
+			var nodes corev1.NodeList
+			Expect(subject.List(ctx, &nodes, client.InNamespace("test-ns"))).To(Succeed())
+			Expect(nodes.Items).To(HaveLen(1))
+			Expect(subject.Delete(ctx, &retrieved)).To(Succeed())
+
+			for _, action := range subject.Actions() {
+				Expect(action.GetNamespace()).To(BeEmpty(), "expected no namespace on %s", action.GetVerb())
+			}
+			Expect(apierrs.IsNotFound(subject.Get(ctx, types.NamespacedName{Name: "node-1"}, &corev1.Node{}))).To(BeTrue())
+		})
+
+		It("keeps the namespace of namespaced resources", func() {
+			Expect(subject.Get(ctx, podKey, &corev1.Pod{})).To(Succeed())
+			Expect(subject.Actions()[0].GetNamespace()).To(Equal("test-ns"))
+		})
+
+		It("treats the kinds passed to NewClient as cluster-scoped", func() {
+			subject = reactive.NewClient(fake.NewFakeClientWithScheme(scheme.Scheme), greenplumv1.GroupVersion.WithKind("GreenplumClusterDefaults").GroupKind())
+			defaults := &greenplumv1.GreenplumClusterDefaults{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: greenplumv1.GreenplumClusterDefaultsName},
+			}
+			Expect(subject.Create(ctx, defaults)).To(Succeed())
+			Expect(subject.Get(ctx, types.NamespacedName{Name: greenplumv1.GreenplumClusterDefaultsName}, &greenplumv1.GreenplumClusterDefaults{})).To(Succeed())
+			Expect(subject.Actions()[0].GetNamespace()).To(BeEmpty())
+		})
+	})
+
+	Describe("AddErrorReactor", func() {
+		var (
+			ctx         context.Context
+			conflictErr error
+		)
+		BeforeEach(func() {
+			ctx = context.Background()
+			conflictErr = apierrs.NewConflict(schema.GroupResource{Resource: "pods"}, podKey.Name, errors.New("injected conflict"))
+		})
+
+		updatePod := func(name string) error {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: name}}
+			if err := subject.Get(ctx, types.NamespacedName{Namespace: podKey.Namespace, Name: name}, pod); err != nil {
+				return err
+			}
+			pod.Labels = map[string]string{"updated": "true"}
+			return subject.Update(ctx, pod)
+		}
+
+		It("fails the given number of matching actions, then passes them on to the delegate", func() {
+			subject.AddErrorReactor("update", "pods", 2, conflictErr)
+
+			Expect(updatePod(podKey.Name)).To(MatchError(conflictErr))
+			Expect(updatePod(podKey.Name)).To(MatchError(conflictErr))
+			Expect(updatePod(podKey.Name)).To(Succeed())
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			Expect(pod.Labels).To(HaveKeyWithValue("updated", "true"))
+		})
+
+		It("records the failing actions", func() {
+			subject.AddErrorReactor("update", "pods", 1, conflictErr)
+			Expect(updatePod(podKey.Name)).NotTo(Succeed())
+			Expect(subject.Actions()).To(HaveLen(2))
+			Expect(subject.Actions()[1].GetVerb()).To(Equal("update"))
+		})
+
+		It("does not fail other verbs or resources", func() {
+			subject.AddErrorReactor("update", "configmaps", 1, conflictErr)
+			subject.AddErrorReactor("delete", "pods", 1, conflictErr)
+			Expect(updatePod(podKey.Name)).To(Succeed())
+		})
+
+		It("matches any verb with *", func() {
+			subject.AddErrorReactor("*", "pods", 1, conflictErr)
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(MatchError(conflictErr))
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+		})
+
+		When("a name is given", func() {
+			BeforeEach(func() {
+				otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: "master-1"}}
+				Expect(subject.Create(ctx, otherPod)).To(Succeed())
+				subject.AddNamedErrorReactor("update", "pods", "master-1", 1, conflictErr)
+			})
+
+			It("fails only actions on the named object", func() {
+				Expect(updatePod(podKey.Name)).To(Succeed())
+				Expect(updatePod("master-1")).To(MatchError(conflictErr))
+				Expect(updatePod("master-1")).To(Succeed())
+			})
+
+			It("matches creates by the name of the object", func() {
+				subject.AddNamedErrorReactor("create", "pods", "master-2", 1, conflictErr)
+				newPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: podKey.Namespace, Name: "master-2"}}
+				Expect(subject.Create(ctx, newPod)).To(MatchError(conflictErr))
+			})
+		})
+	})
+
+	Describe("SimulateStaleCache", func() {
+		var ctx context.Context
+		BeforeEach(func() {
+			ctx = context.Background()
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			pod.Labels = map[string]string{"updated": "true"}
+			Expect(subject.Update(ctx, &pod)).To(Succeed())
+		})
+
+		It("returns the previous version for the configured number of Gets, then converges", func() {
+			subject.SimulateStaleCache(2)
+
+			var pod corev1.Pod
+			for i := 0; i < 2; i++ {
+				Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+				Expect(pod.Labels).NotTo(HaveKey("updated"))
+			}
+			Expect(subject.StaleGetsRemaining()).To(BeZero())
+
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			Expect(pod.Labels).To(HaveKeyWithValue("updated", "true"))
+		})
+
+		It("returns NotFound for objects that were never fetched", func() {
+			otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "master-1"}}
+			Expect(subject.Create(ctx, otherPod)).To(Succeed())
+			subject.SimulateStaleCache(1)
+
+			var pod corev1.Pod
+			err := subject.Get(ctx, types.NamespacedName{Namespace: "test-ns", Name: "master-1"}, &pod)
+			Expect(apierrs.IsNotFound(err)).To(BeTrue(), "expected NotFound, got %v", err)
+			Expect(subject.Get(ctx, types.NamespacedName{Namespace: "test-ns", Name: "master-1"}, &pod)).To(Succeed())
+		})
+
+		It("lets a reconcile that retries on conflict converge", func() {
+			reconcile := func() error {
+				var pod corev1.Pod
+				if err := subject.Get(ctx, podKey, &pod); err != nil {
+					return err
+				}
+				if pod.Labels["reconciled"] == "true" {
+					return nil
+				}
+				pod.Labels = map[string]string{"updated": "true", "reconciled": "true"}
+				return subject.Update(ctx, &pod)
+			}
+			Expect(reconcile()).To(Succeed())
+			subject.SimulateStaleCache(1)
+
+			err := reconcile()
+			Expect(apierrs.IsConflict(err)).To(BeTrue(), "expected a conflict from the stale update, got %v", err)
+			Eventually(reconcile).Should(Succeed())
+
+			var pod corev1.Pod
+			Expect(subject.Get(ctx, podKey, &pod)).To(Succeed())
+			Expect(pod.Labels).To(HaveKeyWithValue("reconciled", "true"))
+			subject.ExpectIdempotentReconcile(reconcile)
+		})
+	})
+})
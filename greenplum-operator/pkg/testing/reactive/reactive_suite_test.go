@@ -0,0 +1,13 @@
+package reactive_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestReactive(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Reactive Client Suite")
+}
@@ -12,6 +12,7 @@ type HookableFilesystem struct {
 	SymlinkHook  func(oldname, newname string) error
 	MkdirHook    func(name string, perm os.FileMode) error
 	StatHook     func(name string) (os.FileInfo, error)
+	RemoveHook   func(name string) error
 }
 
 func (fs *HookableFilesystem) OpenFile(name string, flag int, perm os.FileMode) (vfs.File, error) {
@@ -41,3 +42,10 @@ func (fs *HookableFilesystem) Stat(name string) (os.FileInfo, error) {
 	}
 	return fs.Filesystem.Stat(name)
 }
+
+func (fs *HookableFilesystem) Remove(name string) error {
+	if fs.RemoveHook != nil {
+		return fs.RemoveHook(name)
+	}
+	return fs.Filesystem.Remove(name)
+}
@@ -23,6 +23,10 @@ func NewString(s string) *string {
 	return &s
 }
 
+func NewBool(b bool) *bool {
+	return &b
+}
+
 func NewHostPathType(pathType corev1.HostPathType) *corev1.HostPathType {
 	return &pathType
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/blang/vfs"
 )
@@ -11,6 +12,12 @@ import (
 const ConfigMapPathPrefix = "/etc/config/"
 const PodInfoPathPrefix = "/etc/podinfo/"
 
+// EntrypointScriptDir is where the operator mounts the script from spec.entrypointScript, as EntrypointScriptFileName
+const (
+	EntrypointScriptDir      = "/etc/greenplum-entrypoint/"
+	EntrypointScriptFileName = "entrypoint.sh"
+)
+
 type ConfigValues struct {
 	Namespace            string
 	GreenplumClusterName string
@@ -27,6 +34,13 @@ type Reader interface {
 	GetMirrors() (bool, error)
 	GetStandby() (bool, error)
 	GetPXFServiceName() (string, error)
+	GetTempTablespaceLocation() (string, error)
+	GetArrayName() (string, error)
+	GetPgStatStatements() (bool, error)
+	GetRoleSettings() ([]string, error)
+	GetExtensions() ([]string, error)
+	GetResourceQueues() ([]string, error)
+	GetFencedMaster() (string, error)
 	GetConfigValues() (ConfigValues, error)
 }
 
@@ -64,6 +78,35 @@ func (cr *fsReader) GetPXFServiceName() (string, error) {
 	return cr.readOptionalString(ConfigMapPathPrefix, "pxfServiceName")
 }
 
+func (cr *fsReader) GetTempTablespaceLocation() (string, error) {
+	return cr.readOptionalString(ConfigMapPathPrefix, "tempTablespace")
+}
+
+func (cr *fsReader) GetArrayName() (string, error) {
+	return cr.readOptionalString(ConfigMapPathPrefix, "arrayName")
+}
+
+func (cr *fsReader) GetPgStatStatements() (bool, error) {
+	return cr.readBool(ConfigMapPathPrefix, "pgStatStatements")
+}
+
+func (cr *fsReader) GetRoleSettings() ([]string, error) {
+	return cr.readOptionalLines(ConfigMapPathPrefix, "roleSettings")
+}
+
+func (cr *fsReader) GetExtensions() ([]string, error) {
+	return cr.readOptionalLines(ConfigMapPathPrefix, "extensions")
+}
+
+func (cr *fsReader) GetResourceQueues() ([]string, error) {
+	return cr.readOptionalLines(ConfigMapPathPrefix, "resourceQueues")
+}
+
+// GetFencedMaster returns the master pod that autoFailover fenced before promoting the standby, if any
+func (cr *fsReader) GetFencedMaster() (string, error) {
+	return cr.readOptionalString(ConfigMapPathPrefix, "fencedMaster")
+}
+
 func (cr *fsReader) GetConfigValues() (ConfigValues, error) {
 	configValues := ConfigValues{}
 	var err error
@@ -140,6 +183,21 @@ func (cr *fsReader) readOptionalString(pathPrefix, configKey string) (string, er
 	return cr.readStringHelper(pathPrefix, configKey, false)
 }
 
+// readOptionalLines reads the non-blank lines of an optional value
+func (cr *fsReader) readOptionalLines(pathPrefix, configKey string) ([]string, error) {
+	value, err := cr.readOptionalString(pathPrefix, configKey)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
 func (cr *fsReader) readStringHelper(pathPrefix, configKey string, required bool) (string, error) {
 	configFilename := pathPrefix + configKey
 	b, err := vfs.ReadFile(cr.fs, configFilename)
@@ -153,6 +153,20 @@ var _ = Describe("instanceconfig.Reader", func() {
 		})
 	})
 
+	Describe("GetPgStatStatements", func() {
+		It("reads a boolean", func() {
+			Expect(vfs.WriteFile(memoryfs, "/etc/config/pgStatStatements", []byte("true"), 0777)).To(Succeed())
+			pgStatStatements, err := subject.GetPgStatStatements()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pgStatStatements).To(BeTrue())
+		})
+		It("fails when pgStatStatements is not a boolean", func() {
+			Expect(vfs.WriteFile(memoryfs, "/etc/config/pgStatStatements", []byte("yes please"), 0777)).To(Succeed())
+			_, err := subject.GetPgStatStatements()
+			Expect(err).To(MatchError("error parsing pgStatStatements, must be a boolean, got: yes please"))
+		})
+	})
+
 	Describe("GetPXFServiceName", func() {
 		When("pxfServiceName is defined", func() {
 			It("reads a string successfully", func() {
@@ -171,6 +185,126 @@ var _ = Describe("instanceconfig.Reader", func() {
 		})
 	})
 
+	Describe("GetTempTablespaceLocation", func() {
+		When("tempTablespace is defined", func() {
+			It("reads a string successfully", func() {
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/tempTablespace", []byte("/greenplum-temp"), 0777)).To(Succeed())
+				location, err := subject.GetTempTablespaceLocation()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(location).To(Equal("/greenplum-temp"))
+			})
+		})
+		When("tempTablespace is not defined", func() {
+			It("returns empty string without error", func() {
+				location, err := subject.GetTempTablespaceLocation()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(location).To(Equal(""))
+			})
+		})
+	})
+
+	Describe("GetArrayName", func() {
+		When("arrayName is defined", func() {
+			It("reads a string successfully", func() {
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/arrayName", []byte("Analytics Warehouse"), 0777)).To(Succeed())
+				arrayName, err := subject.GetArrayName()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(arrayName).To(Equal("Analytics Warehouse"))
+			})
+		})
+		When("arrayName is not defined", func() {
+			It("returns empty string without error", func() {
+				arrayName, err := subject.GetArrayName()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(arrayName).To(Equal(""))
+			})
+		})
+	})
+
+	Describe("GetRoleSettings", func() {
+		When("roleSettings is defined", func() {
+			It("reads one statement per line", func() {
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/roleSettings",
+					[]byte("ALTER ROLE \"analyst\" SET search_path = sales, public\nALTER ROLE \"gpadmin\" SET statement_timeout = 0\n"), 0777)).To(Succeed())
+				statements, err := subject.GetRoleSettings()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(Equal([]string{
+					`ALTER ROLE "analyst" SET search_path = sales, public`,
+					`ALTER ROLE "gpadmin" SET statement_timeout = 0`,
+				}))
+			})
+		})
+		When("roleSettings is empty or not defined", func() {
+			It("returns no statements without error", func() {
+				statements, err := subject.GetRoleSettings()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(BeEmpty())
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/roleSettings", []byte(""), 0777)).To(Succeed())
+				statements, err = subject.GetRoleSettings()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("GetResourceQueues", func() {
+		When("resourceQueues is defined", func() {
+			It("reads one statement per line", func() {
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/resourceQueues",
+					[]byte("CREATE RESOURCE QUEUE \"reporting\" WITH (ACTIVE_STATEMENTS=5)\nCREATE ROLE \"analyst\" RESOURCE QUEUE \"reporting\"\n"), 0777)).To(Succeed())
+				statements, err := subject.GetResourceQueues()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(Equal([]string{
+					`CREATE RESOURCE QUEUE "reporting" WITH (ACTIVE_STATEMENTS=5)`,
+					`CREATE ROLE "analyst" RESOURCE QUEUE "reporting"`,
+				}))
+			})
+		})
+		When("resourceQueues is not defined", func() {
+			It("returns no statements without error", func() {
+				statements, err := subject.GetResourceQueues()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("GetExtensions", func() {
+		When("extensions is defined", func() {
+			It("reads one extension per line", func() {
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/extensions", []byte("pgcrypto\nuuid-ossp\n"), 0777)).To(Succeed())
+				extensions, err := subject.GetExtensions()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(extensions).To(Equal([]string{"pgcrypto", "uuid-ossp"}))
+			})
+		})
+		When("extensions is not defined", func() {
+			It("returns no extensions without error", func() {
+				extensions, err := subject.GetExtensions()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(extensions).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("GetFencedMaster", func() {
+		When("fencedMaster is defined", func() {
+			It("returns the fenced master pod", func() {
+				Expect(vfs.WriteFile(memoryfs, "/etc/config/fencedMaster", []byte("master-1"), 0777)).To(Succeed())
+				fencedMaster, err := subject.GetFencedMaster()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fencedMaster).To(Equal("master-1"))
+			})
+		})
+		When("fencedMaster is not defined", func() {
+			It("returns an empty string without error", func() {
+				fencedMaster, err := subject.GetFencedMaster()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fencedMaster).To(BeEmpty())
+			})
+		})
+	})
+
 	Describe("GetConfigValues", func() {
 		BeforeEach(func() {
 			Expect(vfs.WriteFile(memoryfs, "/etc/podinfo/namespace", []byte("testns"), 0777)).To(Succeed())
@@ -20,9 +20,30 @@ type MockReader struct {
 	PXFServiceName    string
 	PXFServiceNameErr error
 
+	TempTablespaceLocation    string
+	TempTablespaceLocationErr error
+
+	ArrayName    string
+	ArrayNameErr error
+
+	PgStatStatements    bool
+	PgStatStatementsErr error
+
+	RoleSettings    []string
+	RoleSettingsErr error
+
+	Extensions    []string
+	ExtensionsErr error
+
+	ResourceQueues    []string
+	ResourceQueuesErr error
+
 	Standby    bool
 	StandbyErr error
 
+	FencedMaster    string
+	FencedMasterErr error
+
 	ConfigMapValuesErr error
 }
 
@@ -48,10 +69,38 @@ func (cr *MockReader) GetPXFServiceName() (string, error) {
 	return cr.PXFServiceName, cr.PXFServiceNameErr
 }
 
+func (cr *MockReader) GetTempTablespaceLocation() (string, error) {
+	return cr.TempTablespaceLocation, cr.TempTablespaceLocationErr
+}
+
+func (cr *MockReader) GetArrayName() (string, error) {
+	return cr.ArrayName, cr.ArrayNameErr
+}
+
+func (cr *MockReader) GetPgStatStatements() (bool, error) {
+	return cr.PgStatStatements, cr.PgStatStatementsErr
+}
+
+func (cr *MockReader) GetRoleSettings() ([]string, error) {
+	return cr.RoleSettings, cr.RoleSettingsErr
+}
+
+func (cr *MockReader) GetExtensions() ([]string, error) {
+	return cr.Extensions, cr.ExtensionsErr
+}
+
+func (cr *MockReader) GetResourceQueues() ([]string, error) {
+	return cr.ResourceQueues, cr.ResourceQueuesErr
+}
+
 func (cr *MockReader) GetStandby() (bool, error) {
 	return cr.Standby, cr.StandbyErr
 }
 
+func (cr *MockReader) GetFencedMaster() (string, error) {
+	return cr.FencedMaster, cr.FencedMasterErr
+}
+
 func (cr *MockReader) GetConfigValues() (instanceconfig.ConfigValues, error) {
 	return instanceconfig.ConfigValues{
 		Namespace:            cr.NamespaceName,